@@ -39,4 +39,10 @@ Create the name of the service account to use
 {{- else -}}
     {{ default "default" .Values.serviceAccount.name }}
 {{- end -}}
-{{- end -}}
\ No newline at end of file
+{{- end -}}
+{{/*
+Create the name of the webhook certificate secret to use
+*/}}
+{{- define "flagger.webhookSecretName" -}}
+{{- default (printf "%s-webhook-cert" (include "flagger.fullname" .)) .Values.webhook.secretName -}}
+{{- end -}}
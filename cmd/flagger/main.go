@@ -23,13 +23,17 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/dynamic"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/tools/cache"
@@ -40,17 +44,23 @@ import (
 	_ "k8s.io/code-generator/cmd/client-gen/generators"
 	"k8s.io/klog/v2"
 
+	"github.com/fluxcd/flagger/pkg/api"
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 	"github.com/fluxcd/flagger/pkg/canary"
+	"github.com/fluxcd/flagger/pkg/chaos"
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 	informers "github.com/fluxcd/flagger/pkg/client/informers/externalversions"
 	"github.com/fluxcd/flagger/pkg/controller"
 	"github.com/fluxcd/flagger/pkg/logger"
 	"github.com/fluxcd/flagger/pkg/metrics/observers"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
 	"github.com/fluxcd/flagger/pkg/notifier"
 	"github.com/fluxcd/flagger/pkg/router"
 	"github.com/fluxcd/flagger/pkg/server"
 	"github.com/fluxcd/flagger/pkg/signals"
+	"github.com/fluxcd/flagger/pkg/tracing"
 	"github.com/fluxcd/flagger/pkg/version"
+	"github.com/fluxcd/flagger/pkg/webhook"
 )
 
 var (
@@ -70,6 +80,7 @@ var (
 	slackUser                string
 	slackChannel             string
 	eventWebhook             string
+	cloudEventsSink          string
 	threadiness              int
 	zapReplaceGlobals        bool
 	zapEncoding              string
@@ -78,13 +89,47 @@ var (
 	selectorLabels           string
 	ingressAnnotationsPrefix string
 	ingressClass             string
+	clusterDomain            string
 	enableLeaderElection     bool
 	leaderElectionNamespace  string
+	leaderHandoverTimeout    time.Duration
 	enableConfigTracking     bool
 	ver                      bool
 	kubeconfigServiceMesh    string
 	clusterName              string
 	noCrossNamespaceRefs     bool
+	chaosProviderTimeoutRate float64
+	chaosRouterErrorRate     float64
+	chaosWebhookErrorRate    float64
+	otlpEndpoint             string
+	metricsQueryCacheTTL     time.Duration
+	metricsQueryQPS          float64
+	metricsQueryRetries      int
+	metricsScrapeInterval    time.Duration
+	metricsQueryJitter       time.Duration
+	builtinMetricsPath       string
+	freezeWindowsPath        string
+	shardID                  int
+	shardCount               int
+	shardSelector            string
+	maxConcurrentAnalysis    int
+	workqueueBaseDelay       time.Duration
+	workqueueMaxDelay        time.Duration
+	workqueueQPS             float64
+	workqueueBurst           int
+	orphansPolicy            string
+	enableAuditTrail         bool
+	auditMaxRuns             int
+	auditMaxRecords          int
+	namespaceScopePath       string
+	webhookPort              string
+	webhookCertDir           string
+	webhookServiceName       string
+	webhookServiceNamespace  string
+	enableWebhookValidation  bool
+	apiPort                  string
+	apiTokenFile             string
+	enableDashboard          bool
 )
 
 func init() {
@@ -93,32 +138,67 @@ func init() {
 	flag.IntVar(&kubeconfigBurst, "kubeconfig-burst", 250, "Set Burst for kubeconfig.")
 	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&metricsServer, "metrics-server", "http://prometheus:9090", "Prometheus URL.")
+	flag.DurationVar(&metricsQueryCacheTTL, "metrics-query-cache-ttl", 0, "Duration the metric query results are cached and shared by the canaries, disabled when set to zero.")
+	flag.Float64Var(&metricsQueryQPS, "metrics-query-qps", 0, "Maximum number of queries per second sent to each metrics provider, unlimited when set to zero.")
+	flag.StringVar(&builtinMetricsPath, "builtin-metrics", "", "Path to a YAML file mounted from a ConfigMap that registers additional builtin metrics with a query for each provider.")
+	flag.StringVar(&freezeWindowsPath, "freeze-windows", "", "Path to a YAML file mounted from a ConfigMap that lists the change freeze windows during which the canaries are held and the promotions deferred.")
+	flag.IntVar(&metricsQueryRetries, "metrics-query-retries", 2, "Maximum number of retries of the metric queries failed with a timeout, a server error or throttling, disabled when set to zero.")
+	flag.DurationVar(&metricsScrapeInterval, "metrics-scrape-interval", 0, "Scrape interval of the metrics providers, the metric intervals are rounded up to two scrapes or more, disabled when set to zero.")
+	flag.DurationVar(&metricsQueryJitter, "metrics-query-jitter", 0, "Maximum delay added to the analysis schedule of each canary to spread the metric queries, disabled when set to zero.")
 	flag.DurationVar(&controlLoopInterval, "control-loop-interval", 10*time.Second, "Kubernetes API sync interval.")
 	flag.StringVar(&logLevel, "log-level", "debug", "Log level can be: debug, info, warning, error.")
 	flag.StringVar(&port, "port", "8080", "Port to listen on.")
+	flag.StringVar(&webhookPort, "webhook-port", "", "Port of the HTTPS server of the Canary conversion webhook, disabled when empty.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "/etc/flagger/webhook-certs", "Directory of the webhook serving certificate tls.crt, key tls.key and CA bundle ca.crt.")
+	flag.StringVar(&webhookServiceName, "webhook-service-name", "flagger", "Name of the service of the webhook server set in the Canary CRD conversion.")
+	flag.StringVar(&webhookServiceNamespace, "webhook-service-namespace", "", "Namespace of the service of the webhook server, defaults to the POD_NAMESPACE env var.")
+	flag.BoolVar(&enableWebhookValidation, "enable-webhook-validation", false, "Reject the canaries, metric templates and alert providers with an invalid spec at apply time, requires the webhook server.")
+	flag.StringVar(&apiPort, "api-port", "", "Port of the HTTP server of the canary API, disabled when empty.")
+	flag.StringVar(&apiTokenFile, "api-token-file", "/etc/flagger/api/token", "File with the bearer token of the canary API.")
+	flag.BoolVar(&enableDashboard, "enable-dashboard", false, "Serve the read-only web dashboard on the API server port, requires the API server.")
 	flag.StringVar(&slackURL, "slack-url", "", "Slack hook URL.")
 	flag.StringVar(&slackProxyURL, "slack-proxy-url", "", "Slack proxy URL.")
 	flag.StringVar(&slackUser, "slack-user", "flagger", "Slack user name.")
 	flag.StringVar(&slackChannel, "slack-channel", "", "Slack channel.")
 	flag.StringVar(&eventWebhook, "event-webhook", "", "Webhook for publishing flagger events")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint of the OpenTelemetry collector e.g. http://otel-collector:4318, tracing is disabled when empty. Overridden by the OTEL_EXPORTER_OTLP_ENDPOINT env var.")
+	flag.StringVar(&cloudEventsSink, "cloudevents-sink", "", "HTTP sink for publishing the canary lifecycle events as CloudEvents, overridden by the K_SINK env var.")
 	flag.StringVar(&msteamsURL, "msteams-url", "", "MS Teams incoming webhook URL.")
 	flag.StringVar(&msteamsProxyURL, "msteams-proxy-url", "", "MS Teams proxy URL.")
 	flag.StringVar(&includeLabelPrefix, "include-label-prefix", "", "List of prefixes of labels that are copied when creating primary deployments or daemonsets. Use * to include all.")
 	flag.IntVar(&threadiness, "threadiness", 2, "Worker concurrency.")
+	flag.IntVar(&maxConcurrentAnalysis, "max-concurrent-analysis", 0, "Max number of canaries analysed in parallel, unlimited when zero.")
+	flag.DurationVar(&workqueueBaseDelay, "workqueue-base-delay", 5*time.Millisecond, "Initial delay of the per-canary exponential backoff of the workqueue.")
+	flag.DurationVar(&workqueueMaxDelay, "workqueue-max-delay", 1000*time.Second, "Max delay of the per-canary exponential backoff of the workqueue.")
+	flag.Float64Var(&workqueueQPS, "workqueue-qps", 10, "Overall rate at which the canaries are reconciled by the workqueue.")
+	flag.IntVar(&workqueueBurst, "workqueue-burst", 100, "Burst of the overall workqueue rate limit.")
+	flag.BoolVar(&enableAuditTrail, "enable-audit-trail", false, "Record the weight changes, metric checks, webhook calls and gate decisions of each analysis run in a CanaryRun.")
+	flag.IntVar(&auditMaxRuns, "audit-max-runs", 20, "Max number of CanaryRuns kept for each canary, the oldest runs are deleted.")
+	flag.IntVar(&auditMaxRecords, "audit-max-records", 500, "Max number of records kept in a CanaryRun, the oldest records are dropped.")
+	flag.StringVar(&orphansPolicy, "orphans-policy", "ignore", "Ignore, report or delete the workloads, services and autoscalers generated for canaries that no longer exist.")
 	flag.BoolVar(&zapReplaceGlobals, "zap-replace-globals", false, "Whether to change the logging level of the global zap logger.")
 	flag.StringVar(&zapEncoding, "zap-encoding", "json", "Zap logger encoding.")
 	flag.StringVar(&namespace, "namespace", "", "Namespace that flagger would watch canary object.")
+	flag.StringVar(&namespaceScopePath, "namespace-scope", "", "Path to a YAML file mounted from a ConfigMap that lists the included and excluded namespaces, reloaded when it changes.")
 	flag.StringVar(&meshProvider, "mesh-provider", "istio", "Service mesh provider, can be istio, linkerd, appmesh, contour, gloo, nginx, skipper, traefik, osm or kuma.")
 	flag.StringVar(&selectorLabels, "selector-labels", "app,name,app.kubernetes.io/name", "List of pod labels that Flagger uses to create pod selectors.")
 	flag.StringVar(&ingressAnnotationsPrefix, "ingress-annotations-prefix", "nginx.ingress.kubernetes.io", "Annotations prefix for NGINX ingresses.")
 	flag.StringVar(&ingressClass, "ingress-class", "", "Ingress class used for annotating HTTPProxy objects.")
+	flag.StringVar(&clusterDomain, "cluster-domain", router.DefaultClusterDomain, "Kubernetes cluster DNS domain used to build the services FQDN.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false, "Enable leader election.")
 	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "kube-system", "Namespace used to create the leader election config map.")
+	flag.DurationVar(&leaderHandoverTimeout, "leader-handover-timeout", 20*time.Second, "Max time to wait for the in-flight canary analysis to be persisted before releasing the leadership.")
+	flag.IntVar(&shardID, "shard-id", 0, "ID of the shard processed by this instance, between 0 and the shard count minus one.")
+	flag.IntVar(&shardCount, "shard-count", 1, "Number of shards the canaries are partitioned into by consistent hashing of their UID.")
+	flag.StringVar(&shardSelector, "shard-selector", "", "Label selector of the canaries processed by this instance, all the canaries are processed when empty.")
 	flag.BoolVar(&enableConfigTracking, "enable-config-tracking", true, "Enable secrets and configmaps tracking.")
 	flag.BoolVar(&ver, "version", false, "Print version")
 	flag.StringVar(&kubeconfigServiceMesh, "kubeconfig-service-mesh", "", "Path to a kubeconfig for the service mesh control plane cluster.")
 	flag.StringVar(&clusterName, "cluster-name", "", "Cluster name to be included in alert msgs.")
 	flag.BoolVar(&noCrossNamespaceRefs, "no-cross-namespace-refs", false, "When set to true, Flagger can only refer to resources in the same namespace.")
+	flag.Float64Var(&chaosProviderTimeoutRate, "chaos-provider-timeout-rate", 0, "Rate between 0 and 1 of metric queries failing with a synthetic timeout. For testing alerting and rollbacks only.")
+	flag.Float64Var(&chaosRouterErrorRate, "chaos-router-error-rate", 0, "Rate between 0 and 1 of router updates failing with a synthetic error. For testing alerting and rollbacks only.")
+	flag.Float64Var(&chaosWebhookErrorRate, "chaos-webhook-error-rate", 0, "Rate between 0 and 1 of webhook calls failing with a synthetic 500 error. For testing alerting and rollbacks only.")
 }
 
 func main() {
@@ -145,6 +225,8 @@ func main() {
 	stopCh := signals.SetupSignalHandler()
 
 	logger.Infof("Starting flagger version %s revision %s mesh provider %s", version.VERSION, version.REVISION, meshProvider)
+	logger.Infof("Routers included in this build: %s", strings.Join(router.MeshRouters(), ", "))
+	logger.Infof("Metric providers included in this build: %s", strings.Join(providers.Providers(), ", "))
 
 	cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
 	if err != nil {
@@ -164,6 +246,11 @@ func main() {
 		logger.Fatalf("Error building flagger clientset: %s", err.Error())
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		logger.Fatalf("Error building dynamic client: %v", err)
+	}
+
 	// use a remote cluster for routing if a service mesh kubeconfig is specified
 	if kubeconfigServiceMesh == "" {
 		kubeconfigServiceMesh = kubeconfig
@@ -183,7 +270,7 @@ func main() {
 
 	verifyCRDs(flaggerClient, logger)
 	verifyKubernetesVersion(kubeClient, logger)
-	infos := startInformers(flaggerClient, logger, stopCh)
+	infos := startInformers(kubeClient, flaggerClient, logger, stopCh)
 
 	labels := strings.Split(selectorLabels, ",")
 	if len(labels) < 1 {
@@ -194,11 +281,91 @@ func main() {
 		logger.Infof("Watching namespace %s", namespace)
 	}
 
+	var namespaceScope *controller.NamespaceScope
+	if namespaceScopePath != "" {
+		namespaceScope, err = controller.LoadNamespaceScope(namespaceScopePath)
+		if err != nil {
+			logger.Fatalf("Error loading namespace scope: %s", err.Error())
+		}
+		logger.Infof("Managing the canaries of %s", namespaceScope)
+	}
+
+	shard, err := controller.NewShard(shardID, shardCount, shardSelector)
+	if err != nil {
+		logger.Fatalf("Error building shard: %s", err.Error())
+	}
+	if shard.IsSharded() {
+		logger.Infof("Processing the canaries of shard %s", shard)
+	}
+
+	concurrency, err := controller.NewConcurrency(maxConcurrentAnalysis, workqueueBaseDelay, workqueueMaxDelay, workqueueQPS, workqueueBurst)
+	if err != nil {
+		logger.Fatalf("Error building concurrency limits: %s", err.Error())
+	}
+	if maxConcurrentAnalysis > 0 {
+		logger.Infof("Analysing at most %d canaries in parallel", maxConcurrentAnalysis)
+	}
+
+	orphans, err := controller.NewOrphansPolicy(orphansPolicy)
+	if err != nil {
+		logger.Fatalf("Error parsing orphans policy: %s", err.Error())
+	}
+
+	var auditTrail *controller.AuditTrail
+	if enableAuditTrail {
+		auditTrail, err = controller.NewAuditTrail(auditMaxRuns, auditMaxRecords)
+		if err != nil {
+			logger.Fatalf("Error building audit trail: %s", err.Error())
+		}
+		logger.Infof("Audit trail enabled, keeping the last %d runs of each canary", auditMaxRuns)
+	}
+
+	faultInjector, err := chaos.NewInjector(chaosProviderTimeoutRate, chaosRouterErrorRate, chaosWebhookErrorRate)
+	if err != nil {
+		logger.Fatalf("Error building fault injector: %s", err.Error())
+	}
+	if faultInjector != nil {
+		logger.Warnf("Failure injection is enabled with %s", faultInjector)
+	}
+
+	tracer := tracing.NewTracer(fromEnv("OTEL_EXPORTER_OTLP_ENDPOINT", otlpEndpoint), fromEnv("OTEL_SERVICE_NAME", "flagger"), logger)
+	if tracer != nil {
+		go tracer.Run(5*time.Second, stopCh)
+		logger.Info("Tracing is enabled, exporting the spans with OTLP/HTTP")
+	}
+
 	observerFactory, err := observers.NewFactory(metricsServer)
 	if err != nil {
 		logger.Fatalf("Error building prometheus client: %s", err.Error())
 	}
 
+	queryCache, err := providers.NewQueryCache(metricsQueryCacheTTL, metricsQueryQPS)
+	if err != nil {
+		logger.Fatalf("Error building metrics query cache: %s", err.Error())
+	}
+	observerFactory.Client = queryCache.Provider("", flaggerv1.MetricTemplateProvider{
+		Type:    "prometheus",
+		Address: metricsServer,
+	}, faultInjector.Provider(observerFactory.Client))
+
+	var builtinMetrics observers.BuiltinMetrics
+	if builtinMetricsPath != "" {
+		builtinMetrics, err = observers.LoadBuiltinMetrics(builtinMetricsPath)
+		if err != nil {
+			logger.Fatalf("Error loading builtin metrics: %s", err.Error())
+		}
+		logger.Infof("Loaded %d builtin metrics from %s", len(builtinMetrics), builtinMetricsPath)
+	}
+
+	var freezeWindows *controller.FreezeWindows
+	if freezeWindowsPath != "" {
+		freezeWindows, err = controller.LoadFreezeWindows(freezeWindowsPath)
+		if err != nil {
+			logger.Fatalf("Error loading freeze windows: %s", err.Error())
+		}
+		logger.Infof("Loaded %d freeze windows from %s", freezeWindows.Len(), freezeWindowsPath)
+	}
+
 	ok, err := observerFactory.Client.IsOnline()
 	if ok {
 		logger.Infof("Connected to metrics server %s", metricsServer)
@@ -212,7 +379,28 @@ func main() {
 	// start HTTP server
 	go server.ListenAndServe(port, 3*time.Second, logger, stopCh)
 
-	routerFactory := router.NewFactory(cfg, kubeClient, flaggerClient, ingressAnnotationsPrefix, ingressClass, logger, meshClient)
+	// start the canary API server
+	if apiPort != "" {
+		apiServer, err := api.NewServer(apiPort, apiTokenFile, namespace, kubeClient, flaggerClient, logger)
+		if err != nil {
+			logger.Fatalf("Error configuring the API server: %v", err)
+		}
+		if enableDashboard {
+			apiServer.EnableDashboard()
+		}
+		go apiServer.ListenAndServe(3*time.Second, stopCh)
+	} else if enableDashboard {
+		logger.Warn("Dashboard is disabled, the API port is not set")
+	}
+
+	// start the conversion webhook server
+	if webhookPort != "" {
+		startWebhookServer(kubeClient, logger, stopCh)
+	} else if enableWebhookValidation {
+		logger.Warn("Validating webhook is disabled, the webhook port is not set")
+	}
+
+	routerFactory := router.NewFactory(cfg, kubeClient, flaggerClient, ingressAnnotationsPrefix, ingressClass, clusterDomain, logger, meshClient)
 
 	var configTracker canary.Tracker
 	if enableConfigTracking {
@@ -227,7 +415,8 @@ func main() {
 
 	includeLabelPrefixArray := strings.Split(includeLabelPrefix, ",")
 
-	canaryFactory := canary.NewFactory(kubeClient, flaggerClient, configTracker, labels, includeLabelPrefixArray, logger)
+	canaryFactory := canary.NewFactory(kubeClient, flaggerClient, configTracker, labels, includeLabelPrefixArray, logger).
+		WithDynamicClient(dynamicClient)
 
 	c := controller.NewController(
 		kubeClient,
@@ -244,6 +433,23 @@ func main() {
 		fromEnv("EVENT_WEBHOOK_URL", eventWebhook),
 		clusterName,
 		noCrossNamespaceRefs,
+		controller.Options{
+			FaultInjector:    faultInjector,
+			QueryCache:       queryCache,
+			QueryRetries:     metricsQueryRetries,
+			BuiltinMetrics:   builtinMetrics,
+			ScrapeInterval:   metricsScrapeInterval,
+			QueryJitter:      metricsQueryJitter,
+			FreezeWindows:    freezeWindows,
+			Shard:            shard,
+			NamespaceScope:   namespaceScope,
+			CloudEventsSink:  fromEnv("K_SINK", cloudEventsSink),
+			Tracer:           tracer,
+			Concurrency:      concurrency,
+			OrphansNamespace: namespace,
+			OrphansPolicy:    orphans,
+			AuditTrail:       auditTrail,
+		},
 	)
 
 	// leader election context
@@ -253,15 +459,43 @@ func main() {
 	// prevents new requests when leadership is lost
 	cfg.Wrap(transport.ContextCanceller(ctx, fmt.Errorf("the leader is shutting down")))
 
+	// closed after the controller has persisted the state of the in-flight analysis
+	var controllerStarted int32
+	controllerDone := make(chan struct{})
+	waitForHandover := func() {
+		if atomic.LoadInt32(&controllerStarted) == 0 {
+			return
+		}
+		select {
+		case <-controllerDone:
+		case <-time.After(leaderHandoverTimeout):
+			logger.Errorf("Canaries handover timed out after %v", leaderHandoverTimeout)
+		}
+	}
+
 	// cancel leader election context on shutdown signals
+	// after the controller has handed over the canaries
 	go func() {
 		<-stopCh
+		waitForHandover()
 		cancel()
 	}()
 
-	// wrap controller run
-	runController := func() {
-		if err := c.Run(threadiness, stopCh); err != nil {
+	// wrap controller run, the controller stops on shutdown signals or when the leadership is lost
+	runController := func(leadershipLost <-chan struct{}) {
+		atomic.StoreInt32(&controllerStarted, 1)
+		defer close(controllerDone)
+
+		controllerStopCh := make(chan struct{})
+		go func() {
+			select {
+			case <-stopCh:
+			case <-leadershipLost:
+			}
+			close(controllerStopCh)
+		}()
+
+		if err := c.Run(threadiness, controllerStopCh); err != nil {
 			logger.Fatalf("Error running controller: %v", err)
 		}
 	}
@@ -272,13 +506,45 @@ func main() {
 		if namespace != "" {
 			ns = namespace
 		}
-		startLeaderElection(ctx, runController, ns, kubeClient, logger)
+		startLeaderElection(ctx, runController, waitForHandover, ns, shard, kubeClient, logger)
 	} else {
-		runController()
+		runController(nil)
 	}
 }
 
-func startInformers(flaggerClient clientset.Interface, logger *zap.SugaredLogger, stopCh <-chan struct{}) controller.Informers {
+func startWebhookServer(kubeClient kubernetes.Interface, logger *zap.SugaredLogger, stopCh <-chan struct{}) {
+	webhookServer := webhook.NewServer(webhookPort, webhookCertDir, logger)
+	caBundle, err := webhookServer.CABundle()
+	if err != nil {
+		logger.Fatalf("Error starting webhook server: %v", err)
+	}
+
+	ns := webhookServiceNamespace
+	if ns == "" {
+		ns = os.Getenv("POD_NAMESPACE")
+	}
+	err = webhook.PatchCanaryConversion(context.Background(), kubeClient.Discovery().RESTClient(), webhookServiceName, ns, caBundle)
+	if err != nil {
+		logger.Fatalf("Error configuring the Canary conversion webhook: %v", err)
+	}
+	logger.Infof("Canary conversion webhook configured with service %s.%s", webhookServiceName, ns)
+
+	if enableWebhookValidation {
+		err = webhook.EnsureValidatingWebhook(context.Background(), kubeClient, webhookServiceName, ns, caBundle, namespace)
+		if err != nil {
+			logger.Fatalf("Error configuring the validating webhook: %v", err)
+		}
+		logger.Infof("Validating webhook configured with service %s.%s", webhookServiceName, ns)
+	}
+
+	go func() {
+		if err := webhookServer.ListenAndServe(3*time.Second, stopCh); err != nil {
+			logger.Fatalf("Error starting webhook server: %v", err)
+		}
+	}()
+}
+
+func startInformers(kubeClient kubernetes.Interface, flaggerClient clientset.Interface, logger *zap.SugaredLogger, stopCh <-chan struct{}) controller.Informers {
 	flaggerInformerFactory := informers.NewSharedInformerFactoryWithOptions(flaggerClient, time.Second*30, informers.WithNamespace(namespace))
 
 	logger.Info("Waiting for canary informer cache to sync")
@@ -302,15 +568,37 @@ func startInformers(flaggerClient clientset.Interface, logger *zap.SugaredLogger
 		logger.Fatalf("failed to wait for cache to sync")
 	}
 
+	logger.Info("Waiting for release group informer cache to sync")
+	releaseGroupInformer := flaggerInformerFactory.Flagger().V1beta1().ReleaseGroups()
+	go releaseGroupInformer.Informer().Run(stopCh)
+	if ok := cache.WaitForNamedCacheSync("flagger", stopCh, releaseGroupInformer.Informer().HasSynced); !ok {
+		logger.Fatalf("failed to wait for cache to sync")
+	}
+
+	logger.Info("Waiting for secret informer cache to sync")
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, time.Second*30, kubeinformers.WithNamespace(namespace))
+	secretInformer := kubeInformerFactory.Core().V1().Secrets()
+	go secretInformer.Informer().Run(stopCh)
+	if ok := cache.WaitForNamedCacheSync("flagger", stopCh, secretInformer.Informer().HasSynced); !ok {
+		logger.Fatalf("failed to wait for cache to sync")
+	}
+
 	return controller.Informers{
-		CanaryInformer: canaryInformer,
-		MetricInformer: metricInformer,
-		AlertInformer:  alertInformer,
+		CanaryInformer:       canaryInformer,
+		MetricInformer:       metricInformer,
+		AlertInformer:        alertInformer,
+		ReleaseGroupInformer: releaseGroupInformer,
+		SecretInformer:       secretInformer,
 	}
 }
 
-func startLeaderElection(ctx context.Context, run func(), ns string, kubeClient kubernetes.Interface, logger *zap.SugaredLogger) {
+func startLeaderElection(ctx context.Context, run func(leadershipLost <-chan struct{}), waitForHandover func(),
+	ns string, shard *controller.Shard, kubeClient kubernetes.Interface, logger *zap.SugaredLogger) {
 	configMapName := "flagger-leader-election"
+	// the replicas of each shard elect their own leader
+	if shard.IsSharded() {
+		configMapName = fmt.Sprintf("%s-%s", configMapName, shard.Name())
+	}
 	id, err := os.Hostname()
 	if err != nil {
 		logger.Fatalf("Error running controller: %v", err)
@@ -341,10 +629,13 @@ func startLeaderElection(ctx context.Context, run func(), ns string, kubeClient
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(ctx context.Context) {
 				logger.Info("Acting as elected leader")
-				run()
+				run(ctx.Done())
 			},
 			OnStoppedLeading: func() {
 				logger.Infof("Leadership lost")
+				// another instance can acquire the lease only after the lease duration,
+				// the state of the in-flight analysis is persisted before it takes over
+				waitForHandover()
 				os.Exit(1)
 			},
 			OnNewLeader: func(identity string) {
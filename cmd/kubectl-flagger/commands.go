@@ -0,0 +1,222 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
+)
+
+// fieldManager is the field manager of the changes made with the CLI
+const fieldManager = "kubectl-flagger"
+
+// usageError is returned for an unknown command or missing arguments
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+type cli struct {
+	client        clientset.Interface
+	dynamicClient dynamic.Interface
+	namespace     string
+	out           io.Writer
+}
+
+// run dispatches the command
+func (c *cli) run(args []string) error {
+	switch args[0] {
+	case "status":
+		if len(args) == 1 {
+			return c.list()
+		}
+		return c.status(args[1])
+	case "promote", "rollback", "pause", "resume":
+		if len(args) != 2 {
+			return usageError(fmt.Sprintf("%s requires the canary name", args[0]))
+		}
+		return c.act(args[0], args[1])
+	case "gate":
+		if len(args) != 3 || (args[1] != "open" && args[1] != "close") {
+			return usageError("gate requires open or close and the canary name")
+		}
+		return c.gate(args[1] == "open", args[2])
+	case "plan":
+		return c.plan(args[1:])
+	}
+	return usageError(fmt.Sprintf("unknown command %s", args[0]))
+}
+
+// list prints the status of the canaries in the namespace
+func (c *cli) list() error {
+	canaries, err := c.client.FlaggerV1beta1().Canaries(c.namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("canaries list query error: %w", err)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPHASE\tWEIGHT\tITERATIONS\tFAILED CHECKS\tGATE\tSUSPENDED\tLAST TRANSITION")
+	for _, cd := range canaries.Items {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%t\t%s\n", cd.Name, cd.Status.Phase, cd.Status.CanaryWeight,
+			cd.Status.Iterations, cd.Status.FailedChecks, gateState(&cd), cd.Spec.Suspend, age(cd.Status.LastTransitionTime))
+	}
+	return w.Flush()
+}
+
+// status prints the status of a canary
+func (c *cli) status(name string) error {
+	cd, err := c.client.FlaggerV1beta1().Canaries(c.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("canary %s.%s get query error: %w", name, c.namespace, err)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Canary:\t%s.%s\n", cd.Name, cd.Namespace)
+	fmt.Fprintf(w, "Target:\t%s/%s\n", cd.Spec.TargetRef.Kind, cd.Spec.TargetRef.Name)
+	fmt.Fprintf(w, "Phase:\t%s\n", cd.Status.Phase)
+	fmt.Fprintf(w, "Canary weight:\t%d\n", cd.Status.CanaryWeight)
+	fmt.Fprintf(w, "Iterations:\t%d\n", cd.Status.Iterations)
+	fmt.Fprintf(w, "Failed checks:\t%d/%d\n", cd.Status.FailedChecks, cd.GetAnalysisThreshold())
+	fmt.Fprintf(w, "Gate:\t%s\n", gateState(cd))
+	fmt.Fprintf(w, "Suspended:\t%t\n", cd.Spec.Suspend)
+	if v, ok := cd.Annotations[flaggerv1.ApproveAnnotation]; ok {
+		fmt.Fprintf(w, "Promotion approved:\t%s\n", v)
+	}
+	if v, ok := cd.Annotations[flaggerv1.RollbackAnnotation]; ok {
+		fmt.Fprintf(w, "Rollback requested:\t%s\n", v)
+	}
+	fmt.Fprintf(w, "Last transition:\t%s\n", age(cd.Status.LastTransitionTime))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(cd.Status.Conditions) > 0 {
+		fmt.Fprintln(c.out, "\nConditions:")
+		w = tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+		for _, cond := range cd.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", cond.Type, cond.Status, cond.Reason, cond.Message)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// act approves, rolls back, pauses or resumes the canary
+func (c *cli) act(action string, name string) error {
+	cd, err := c.client.FlaggerV1beta1().Canaries(c.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("canary %s.%s get query error: %w", name, c.namespace, err)
+	}
+
+	// the approval and the rollback are bound to the revision under analysis
+	revision := cd.Status.LastAppliedSpec
+	if revision == "" {
+		revision = "true"
+	}
+
+	var patch map[string]interface{}
+	var message string
+	switch action {
+	case "promote":
+		if !cd.GetAnalysis().ManualPromotion {
+			fmt.Fprintf(c.out, "Warning: manual promotion is not enabled for %s.%s, the approval is ignored\n", name, c.namespace)
+		}
+		patch = annotationPatch(flaggerv1.ApproveAnnotation, revision)
+		message = "promotion approved"
+	case "rollback":
+		patch = annotationPatch(flaggerv1.RollbackAnnotation, revision)
+		message = "rollback requested"
+	case "pause":
+		patch = map[string]interface{}{"spec": map[string]interface{}{"suspend": true}}
+		message = "analysis paused"
+	case "resume":
+		patch = map[string]interface{}{"spec": map[string]interface{}{"suspend": false}}
+		message = "analysis resumed"
+	}
+
+	if err := c.patch(name, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "canary %s.%s %s\n", name, c.namespace, message)
+	return nil
+}
+
+// gate opens or closes the canary gate
+func (c *cli) gate(open bool, name string) error {
+	patch := annotationPatch(flaggerv1.GateAnnotation, flaggerv1.GateClosed)
+	message := "gate closed"
+	if open {
+		patch = annotationPatch(flaggerv1.GateAnnotation, nil)
+		message = "gate opened"
+	}
+
+	if err := c.patch(name, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "canary %s.%s %s\n", name, c.namespace, message)
+	return nil
+}
+
+// patch applies a JSON merge patch to the canary
+func (c *cli) patch(name string, patch map[string]interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.FlaggerV1beta1().Canaries(c.namespace).Patch(context.TODO(), name, types.MergePatchType, data,
+		metav1.PatchOptions{FieldManager: fieldManager})
+	if err != nil {
+		return fmt.Errorf("canary %s.%s patch error: %w", name, c.namespace, err)
+	}
+	return nil
+}
+
+// annotationPatch sets the annotation, a nil value removes it
+func annotationPatch(annotation string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{annotation: value},
+		},
+	}
+}
+
+func gateState(cd *flaggerv1.Canary) string {
+	if cd.Annotations[flaggerv1.GateAnnotation] == flaggerv1.GateClosed {
+		return "closed"
+	}
+	return "open"
+}
+
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return time.Since(t.Time).Round(time.Second).String() + " ago"
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-flagger drives the canaries through their annotations and spec,
+// the binary can be used standalone or as a kubectl plugin (kubectl flagger status)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+
+	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
+)
+
+const usage = `Usage: kubectl flagger [flags] <command> [args]
+
+Commands:
+  status [name]        list the canaries or show the status of a canary
+  promote <name>       approve the promotion of the revision under analysis
+  rollback <name>      roll back the revision under analysis
+  pause <name>         suspend the analysis, the traffic weights are kept
+  resume <name>        resume the analysis
+  gate open <name>     let the analysis advance
+  gate close <name>    halt the rollout and the promotion
+  plan <name>|-f file  show the objects Flagger would create or update for a canary,
+                       run plan -h for the controller flags
+
+Flags:
+`
+
+var (
+	kubeconfig  string
+	kubecontext string
+	namespace   string
+)
+
+func main() {
+	flags := flag.NewFlagSet("kubectl-flagger", flag.ExitOnError)
+	flags.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig, defaults to the KUBECONFIG env var or ~/.kube/config.")
+	flags.StringVar(&kubecontext, "context", "", "Name of the kubeconfig context to use.")
+	flags.StringVar(&namespace, "n", "", "Namespace of the canaries, defaults to the namespace of the kubeconfig context.")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cli, err := newCLI()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cli.run(flags.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if _, ok := err.(usageError); ok {
+			flags.Usage()
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// newCLI builds the clients and the namespace
+// from the kubeconfig loading rules used by kubectl
+func newCLI() (*cli, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubecontext}
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	ns := namespace
+	if ns == "" {
+		var err error
+		if ns, _, err = config.Namespace(); err != nil {
+			return nil, fmt.Errorf("reading the kubeconfig namespace failed: %w", err)
+		}
+	}
+
+	cfg, err := config.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("building the kubeconfig failed: %w", err)
+	}
+	client, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("building the Flagger clientset failed: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("building the dynamic client failed: %w", err)
+	}
+	return &cli{client: client, dynamicClient: dynamicClient, namespace: ns, out: os.Stdout}, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	flaggerscheme "github.com/fluxcd/flagger/pkg/client/clientset/versioned/scheme"
+	"github.com/fluxcd/flagger/pkg/plan"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// plan prints the objects Flagger would create, update or delete for the canary,
+// the controller flags must match the ones of the Flagger deployment
+func (c *cli) plan(args []string) error {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	flags.SetOutput(c.out)
+	file := flags.String("f", "", "Path to a canary manifest, the canary is read from the cluster when not set.")
+	meshProvider := flags.String("mesh-provider", "istio", "Mesh provider of the Flagger deployment, the canary provider takes precedence.")
+	selectorLabels := flags.String("selector-labels", "app,name,app.kubernetes.io/name", "Selector labels of the Flagger deployment.")
+	includeLabelPrefix := flags.String("include-label-prefix", "", "Label prefixes copied to the primary workload by the Flagger deployment.")
+	ingressAnnotationsPrefix := flags.String("ingress-annotations-prefix", "nginx.ingress.kubernetes.io", "NGINX annotations prefix of the Flagger deployment.")
+	ingressClass := flags.String("ingress-class", "", "Ingress class of the Flagger deployment.")
+	clusterDomain := flags.String("cluster-domain", router.DefaultClusterDomain, "Cluster domain of the Flagger deployment.")
+	configTracking := flags.Bool("enable-config-tracking", true, "Config tracking setting of the Flagger deployment.")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return usageError(err.Error())
+	}
+
+	var cd *flaggerv1.Canary
+	switch {
+	case *file != "" && flags.NArg() == 0:
+		var err error
+		if cd, err = readCanary(*file, c.namespace); err != nil {
+			return err
+		}
+	case *file == "" && flags.NArg() == 1:
+		var err error
+		cd, err = c.client.FlaggerV1beta1().Canaries(c.namespace).Get(context.TODO(), flags.Arg(0), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("canary %s.%s get query error: %w", flags.Arg(0), c.namespace, err)
+		}
+	default:
+		return usageError("plan requires the canary name or a manifest file")
+	}
+
+	var prefixes []string
+	if *includeLabelPrefix != "" {
+		prefixes = strings.Split(*includeLabelPrefix, ",")
+	}
+	planner := plan.NewPlanner(
+		plan.DynamicFetcher(c.dynamicClient, kubescheme.Scheme),
+		plan.DynamicFetcher(c.dynamicClient, flaggerscheme.Scheme),
+		plan.Options{
+			MeshProvider:             *meshProvider,
+			Labels:                   strings.Split(*selectorLabels, ","),
+			IncludeLabelPrefix:       prefixes,
+			IngressAnnotationsPrefix: *ingressAnnotationsPrefix,
+			IngressClass:             *ingressClass,
+			ClusterDomain:            *clusterDomain,
+			ConfigTracking:           *configTracking,
+		},
+		zap.NewNop().Sugar())
+
+	changes, err := planner.Plan(cd)
+	if err != nil {
+		return fmt.Errorf("planning canary %s.%s failed: %w", cd.Name, cd.Namespace, err)
+	}
+
+	counts := make(map[plan.Action]int)
+	for _, change := range changes {
+		counts[change.Action]++
+		fmt.Fprintf(c.out, "%s %s %s.%s\n", change.Action, change.Kind, change.Name, change.Namespace)
+		if change.Diff != "" {
+			fmt.Fprintln(c.out, change.Diff)
+		}
+	}
+	fmt.Fprintf(c.out, "\nPlan: %d to create, %d to update, %d to delete, %d unchanged\n",
+		counts[plan.Create], counts[plan.Update], counts[plan.Delete], counts[plan.Unchanged])
+	return nil
+}
+
+// readCanary decodes the canary manifest, the status is ignored
+// so that the plan shows the objects generated at initialization
+func readCanary(file string, namespace string) (*flaggerv1.Canary, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s failed: %w", file, err)
+	}
+	cd := &flaggerv1.Canary{}
+	if err := yaml.UnmarshalStrict(data, cd); err != nil {
+		return nil, fmt.Errorf("decoding %s failed: %w", file, err)
+	}
+	if cd.Kind != flaggerv1.CanaryKind {
+		return nil, fmt.Errorf("%s is not a canary manifest", file)
+	}
+	if cd.Namespace == "" {
+		cd.Namespace = namespace
+	}
+	cd.Status = flaggerv1.CanaryStatus{}
+	return cd, nil
+}
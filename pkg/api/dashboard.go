@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// DashboardPath is the path of the web dashboard
+const DashboardPath = "/dashboard/"
+
+// dashboardPage is a static page, the canaries are loaded from the API with the token set by the user
+//
+//go:embed dashboard/index.html
+var dashboardPage []byte
+
+// EnableDashboard serves the read-only web dashboard
+func (s *Server) EnableDashboard() {
+	s.dashboard = true
+}
+
+// serveDashboard writes the dashboard page, it doesn't require authentication
+// since the page holds no data
+func (s *Server) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		http.Redirect(w, r, DashboardPath, http.StatusFound)
+		return
+	}
+	if r.URL.Path != DashboardPath {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dashboardPage)
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// fieldManager is the field manager of the changes made through the API
+const fieldManager = "flagger-api"
+
+// Canary is the API representation of a canary
+type Canary struct {
+	Name              string                         `json:"name"`
+	Namespace         string                         `json:"namespace"`
+	TargetRef         flaggerv1.LocalObjectReference `json:"targetRef"`
+	Suspended         bool                           `json:"suspended"`
+	ManualPromotion   bool                           `json:"manualPromotion"`
+	GateClosed        bool                           `json:"gateClosed"`
+	PromotionApproved string                         `json:"promotionApproved,omitempty"`
+	RollbackRequested string                         `json:"rollbackRequested,omitempty"`
+	Status            flaggerv1.CanaryStatus         `json:"status"`
+}
+
+// CanaryList is the API representation of the canaries
+type CanaryList struct {
+	Items []Canary `json:"items"`
+}
+
+// HistoryEvent is an event recorded by Flagger during the analysis of a canary
+type HistoryEvent struct {
+	Type      string      `json:"type"`
+	Reason    string      `json:"reason"`
+	Message   string      `json:"message"`
+	Count     int32       `json:"count"`
+	FirstTime metav1.Time `json:"firstTime"`
+	LastTime  metav1.Time `json:"lastTime"`
+}
+
+// History is the analysis history of a canary
+type History struct {
+	Name      string                          `json:"name"`
+	Namespace string                          `json:"namespace"`
+	RunID     string                          `json:"runID,omitempty"`
+	Events    []HistoryEvent                  `json:"events"`
+	Failures  []flaggerv1.CanaryCheckFailure  `json:"failures,omitempty"`
+	Metrics   []flaggerv1.CanaryMetricStatus  `json:"metrics,omitempty"`
+	Webhooks  []flaggerv1.CanaryWebhookStatus `json:"webhooks,omitempty"`
+}
+
+// ActionResponse is the result of an approve or rollback action
+type ActionResponse struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Action    string `json:"action"`
+	Revision  string `json:"revision"`
+}
+
+func newCanary(cd *flaggerv1.Canary) Canary {
+	return Canary{
+		Name:              cd.Name,
+		Namespace:         cd.Namespace,
+		TargetRef:         cd.Spec.TargetRef,
+		Suspended:         cd.Spec.Suspend,
+		ManualPromotion:   cd.GetAnalysis().ManualPromotion,
+		GateClosed:        cd.Annotations[flaggerv1.GateAnnotation] == flaggerv1.GateClosed,
+		PromotionApproved: cd.Annotations[flaggerv1.ApproveAnnotation],
+		RollbackRequested: cd.Annotations[flaggerv1.RollbackAnnotation],
+		Status:            cd.Status,
+	}
+}
+
+// listCanaries writes the canaries of the namespace, or of all the served namespaces
+func (s *Server) listCanaries(w http.ResponseWriter, r *http.Request, namespace string) {
+	if namespace == "" {
+		namespace = s.namespace
+	}
+	if !s.inScope(namespace) {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("namespace %s is not served", namespace))
+		return
+	}
+
+	canaries, err := s.flaggerClient.FlaggerV1beta1().Canaries(namespace).List(r.Context(), metav1.ListOptions{})
+	if err != nil {
+		s.writeClientError(w, err)
+		return
+	}
+
+	list := CanaryList{Items: make([]Canary, 0, len(canaries.Items))}
+	for i := range canaries.Items {
+		list.Items = append(list.Items, newCanary(&canaries.Items[i]))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) getCanary(w http.ResponseWriter, r *http.Request, namespace string, name string) {
+	cd, ok := s.canary(w, r, namespace, name)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newCanary(cd))
+}
+
+// getHistory writes the events recorded for the canary, oldest first,
+// along with the last results of the checks and webhooks
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request, namespace string, name string) {
+	cd, ok := s.canary(w, r, namespace, name)
+	if !ok {
+		return
+	}
+
+	selector := fields.Set{
+		"involvedObject.kind": flaggerv1.CanaryKind,
+		"involvedObject.name": name,
+	}.AsSelector().String()
+	events, err := s.kubeClient.CoreV1().Events(namespace).List(r.Context(), metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		s.writeClientError(w, err)
+		return
+	}
+
+	history := History{
+		Name:      cd.Name,
+		Namespace: cd.Namespace,
+		RunID:     cd.Status.RunID,
+		Events:    []HistoryEvent{},
+		Failures:  cd.Status.Failures,
+		Metrics:   cd.Status.Metrics,
+		Webhooks:  cd.Status.Webhooks,
+	}
+	for _, e := range events.Items {
+		if e.InvolvedObject.Kind != flaggerv1.CanaryKind || e.InvolvedObject.Name != name || e.InvolvedObject.UID != cd.UID {
+			continue
+		}
+		history.Events = append(history.Events, newHistoryEvent(e))
+	}
+	sort.SliceStable(history.Events, func(i, j int) bool {
+		return history.Events[i].LastTime.Before(&history.Events[j].LastTime)
+	})
+	writeJSON(w, http.StatusOK, history)
+}
+
+func newHistoryEvent(e corev1.Event) HistoryEvent {
+	first, last := e.FirstTimestamp, e.LastTimestamp
+	if last.IsZero() {
+		last = metav1.NewTime(e.EventTime.Time)
+	}
+	if first.IsZero() {
+		first = last
+	}
+	return HistoryEvent{
+		Type:      e.Type,
+		Reason:    e.Reason,
+		Message:   e.Message,
+		Count:     e.Count,
+		FirstTime: first,
+		LastTime:  last,
+	}
+}
+
+// act approves the promotion or requests the rollback of the revision under analysis
+func (s *Server) act(w http.ResponseWriter, r *http.Request, action string, namespace string, name string) {
+	cd, ok := s.canary(w, r, namespace, name)
+	if !ok {
+		return
+	}
+
+	annotation := flaggerv1.RollbackAnnotation
+	if action == "approve" {
+		if !cd.GetAnalysis().ManualPromotion {
+			writeError(w, http.StatusConflict, fmt.Sprintf("manual promotion is not enabled for %s.%s", name, namespace))
+			return
+		}
+		annotation = flaggerv1.ApproveAnnotation
+	}
+
+	// the approval and the rollback are bound to the revision under analysis
+	revision := cd.Status.LastAppliedSpec
+	if revision == "" {
+		revision = "true"
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{annotation: revision},
+		},
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, err = s.flaggerClient.FlaggerV1beta1().Canaries(namespace).Patch(r.Context(), name, types.MergePatchType, patch,
+		metav1.PatchOptions{FieldManager: fieldManager})
+	if err != nil {
+		s.writeClientError(w, err)
+		return
+	}
+
+	s.logger.With("canary", fmt.Sprintf("%s.%s", name, namespace)).
+		Infof("Canary %s requested through the API for revision %s", action, revision)
+	writeJSON(w, http.StatusAccepted, ActionResponse{
+		Name:      name,
+		Namespace: namespace,
+		Action:    action,
+		Revision:  revision,
+	})
+}
+
+// canary fetches the canary, the error response is written when it can't be served
+func (s *Server) canary(w http.ResponseWriter, r *http.Request, namespace string, name string) (*flaggerv1.Canary, bool) {
+	if !s.inScope(namespace) {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("namespace %s is not served", namespace))
+		return nil, false
+	}
+	cd, err := s.flaggerClient.FlaggerV1beta1().Canaries(namespace).Get(r.Context(), name, metav1.GetOptions{})
+	if err != nil {
+		s.writeClientError(w, err)
+		return nil, false
+	}
+	return cd, true
+}
+
+// writeClientError maps the Kubernetes API errors to the response status
+func (s *Server) writeClientError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.IsNotFound(err):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.IsConflict(err):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		s.logger.Errorf("API request failed: %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+
+	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
+)
+
+// Prefix is the path prefix of the canary API
+const Prefix = "/api/v1/"
+
+// Server serves the canary API, the requests are authenticated with a bearer token
+type Server struct {
+	port          string
+	token         []byte
+	namespace     string
+	kubeClient    kubernetes.Interface
+	flaggerClient clientset.Interface
+	logger        *zap.SugaredLogger
+	dashboard     bool
+}
+
+// NewServer creates an API server with the bearer token stored in the file,
+// the canaries outside the namespace are not served when the namespace is set
+func NewServer(port string, tokenFile string, namespace string, kubeClient kubernetes.Interface,
+	flaggerClient clientset.Interface, logger *zap.SugaredLogger) (*Server, error) {
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading API token failed: %w", err)
+	}
+	token := bytes.TrimSpace(data)
+	if len(token) == 0 {
+		return nil, fmt.Errorf("API token file %s is empty", tokenFile)
+	}
+
+	return &Server{
+		port:          port,
+		token:         token,
+		namespace:     namespace,
+		kubeClient:    kubeClient,
+		flaggerClient: flaggerClient,
+		logger:        logger,
+	}, nil
+}
+
+// ListenAndServe starts the API server and waits for SIGTERM
+func (s *Server) ListenAndServe(timeout time.Duration, stopCh <-chan struct{}) {
+	srv := &http.Server{
+		Addr:         ":" + s.port,
+		Handler:      s,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  15 * time.Second,
+	}
+
+	s.logger.Infof("Starting API server on port %s", s.port)
+
+	// run server in background
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			s.logger.Fatalf("API server crashed %v", err)
+		}
+	}()
+
+	// wait for SIGTERM or SIGINT
+	<-stopCh
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		s.logger.Errorf("API server graceful shutdown failed %v", err)
+	} else {
+		s.logger.Info("API server stopped")
+	}
+}
+
+// ServeHTTP serves the dashboard, or authenticates the request and routes it to the canary handlers
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.dashboard && (r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, DashboardPath)) {
+		s.serveDashboard(w, r)
+		return
+	}
+
+	if !s.authenticated(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="flagger"`)
+		writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, Prefix) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/"), "/")
+
+	switch {
+	// /api/v1/canaries
+	case len(parts) == 1 && parts[0] == "canaries":
+		s.route(w, r, http.MethodGet, func() { s.listCanaries(w, r, r.URL.Query().Get("namespace")) })
+	// /api/v1/namespaces/{namespace}/canaries
+	case len(parts) == 3 && parts[0] == "namespaces" && parts[2] == "canaries":
+		s.route(w, r, http.MethodGet, func() { s.listCanaries(w, r, parts[1]) })
+	// /api/v1/namespaces/{namespace}/canaries/{name}
+	case len(parts) == 4 && parts[0] == "namespaces" && parts[2] == "canaries":
+		s.route(w, r, http.MethodGet, func() { s.getCanary(w, r, parts[1], parts[3]) })
+	// /api/v1/namespaces/{namespace}/canaries/{name}/{history,approve,rollback}
+	case len(parts) == 5 && parts[0] == "namespaces" && parts[2] == "canaries":
+		switch parts[4] {
+		case "history":
+			s.route(w, r, http.MethodGet, func() { s.getHistory(w, r, parts[1], parts[3]) })
+		case "approve", "rollback":
+			s.route(w, r, http.MethodPost, func() { s.act(w, r, parts[4], parts[1], parts[3]) })
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// route calls the handler when the request method is allowed
+func (s *Server) route(w http.ResponseWriter, r *http.Request, method string, handler func()) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+	handler()
+}
+
+// authenticated compares the bearer token in constant time
+func (s *Server) authenticated(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := []byte(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+	return subtle.ConstantTimeCompare(token, s.token) == 1
+}
+
+// inScope returns false for the namespaces that are not served
+func (s *Server) inScope(namespace string) bool {
+	return s.namespace == "" || s.namespace == namespace
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
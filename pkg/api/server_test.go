@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	fakeFlagger "github.com/fluxcd/flagger/pkg/client/clientset/versioned/fake"
+)
+
+func newTestServer(t *testing.T, namespace string) *Server {
+	cd := &flaggerv1.Canary{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", UID: "uid-1"},
+		Spec: flaggerv1.CanarySpec{
+			TargetRef: flaggerv1.LocalObjectReference{Kind: "Deployment", Name: "podinfo"},
+			Analysis:  &flaggerv1.CanaryAnalysis{ManualPromotion: true},
+		},
+		Status: flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing, LastAppliedSpec: "rev-1"},
+	}
+	now := time.Now()
+	events := []corev1.Event{
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "e2", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: flaggerv1.CanaryKind, Name: "podinfo", UID: "uid-1"},
+			Type:           corev1.EventTypeNormal,
+			Reason:         "Synced",
+			Message:        "Advance podinfo.default canary weight 10",
+			LastTimestamp:  metav1.NewTime(now),
+		},
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "e1", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: flaggerv1.CanaryKind, Name: "podinfo", UID: "uid-1"},
+			Type:           corev1.EventTypeNormal,
+			Reason:         "Synced",
+			Message:        "New revision detected! Scaling up podinfo.default",
+			LastTimestamp:  metav1.NewTime(now.Add(-time.Minute)),
+		},
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "e0", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: flaggerv1.CanaryKind, Name: "podinfo", UID: "uid-0"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "Synced",
+			Message:        "Canary podinfo.default of a deleted canary",
+			LastTimestamp:  metav1.NewTime(now.Add(-time.Hour)),
+		},
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	kubeClient := fake.NewSimpleClientset(&events[0], &events[1], &events[2])
+	srv, err := NewServer("8081", tokenFile, namespace, kubeClient, fakeFlagger.NewSimpleClientset(cd), zap.NewNop().Sugar())
+	require.NoError(t, err)
+	return srv
+}
+
+func serve(srv *Server, method string, path string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewServer_EmptyToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("\n"), 0600))
+	_, err := NewServer("8081", tokenFile, "", fake.NewSimpleClientset(), fakeFlagger.NewSimpleClientset(), zap.NewNop().Sugar())
+	assert.Error(t, err)
+}
+
+func TestServer_Authentication(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	assert.Equal(t, http.StatusUnauthorized, serve(srv, http.MethodGet, "/api/v1/canaries", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(srv, http.MethodGet, "/api/v1/canaries", "wrong").Code)
+	assert.Equal(t, http.StatusOK, serve(srv, http.MethodGet, "/api/v1/canaries", "secret").Code)
+}
+
+func TestServer_Canaries(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	rec := serve(srv, http.MethodGet, "/api/v1/namespaces/default/canaries", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list CanaryList
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "podinfo", list.Items[0].Name)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, list.Items[0].Status.Phase)
+
+	rec = serve(srv, http.MethodGet, "/api/v1/namespaces/default/canaries/podinfo", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var cd Canary
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cd))
+	assert.True(t, cd.ManualPromotion)
+	assert.Equal(t, "Deployment", cd.TargetRef.Kind)
+
+	assert.Equal(t, http.StatusNotFound, serve(srv, http.MethodGet, "/api/v1/namespaces/default/canaries/missing", "secret").Code)
+	assert.Equal(t, http.StatusNotFound, serve(srv, http.MethodGet, "/api/v1/namespaces/default/canaries/podinfo/unknown", "secret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(srv, http.MethodPost, "/api/v1/namespaces/default/canaries/podinfo", "secret").Code)
+}
+
+func TestServer_History(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	rec := serve(srv, http.MethodGet, "/api/v1/namespaces/default/canaries/podinfo/history", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var history History
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+
+	// the events of a previous canary with the same name are excluded
+	require.Len(t, history.Events, 2)
+	assert.Contains(t, history.Events[0].Message, "New revision detected")
+	assert.Contains(t, history.Events[1].Message, "Advance")
+}
+
+func TestServer_Actions(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(srv, http.MethodGet, "/api/v1/namespaces/default/canaries/podinfo/approve", "secret").Code)
+
+	rec := serve(srv, http.MethodPost, "/api/v1/namespaces/default/canaries/podinfo/approve", "secret")
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	rec = serve(srv, http.MethodPost, "/api/v1/namespaces/default/canaries/podinfo/rollback", "secret")
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	cd, err := srv.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "rev-1", cd.Annotations[flaggerv1.ApproveAnnotation])
+	assert.Equal(t, "rev-1", cd.Annotations[flaggerv1.RollbackAnnotation])
+}
+
+func TestServer_ApproveWithoutManualPromotion(t *testing.T) {
+	srv := newTestServer(t, "")
+	cd, err := srv.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.ManualPromotion = false
+	_, err = srv.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	rec := serve(srv, http.MethodPost, "/api/v1/namespaces/default/canaries/podinfo/approve", "secret")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestServer_NamespaceScope(t *testing.T) {
+	srv := newTestServer(t, "test")
+
+	assert.Equal(t, http.StatusForbidden, serve(srv, http.MethodGet, "/api/v1/namespaces/default/canaries", "secret").Code)
+	assert.Equal(t, http.StatusForbidden, serve(srv, http.MethodGet, "/api/v1/canaries?namespace=default", "secret").Code)
+	assert.Equal(t, http.StatusForbidden, serve(srv, http.MethodPost, "/api/v1/namespaces/default/canaries/podinfo/rollback", "secret").Code)
+
+	rec := serve(srv, http.MethodGet, "/api/v1/canaries", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list CanaryList
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list.Items, 0)
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	srv := newTestServer(t, "")
+	assert.Equal(t, http.StatusUnauthorized, serve(srv, http.MethodGet, DashboardPath, "").Code)
+
+	srv.EnableDashboard()
+	rec := serve(srv, http.MethodGet, DashboardPath, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "/api/v1/canaries")
+
+	rec = serve(srv, http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, DashboardPath, rec.Header().Get("Location"))
+
+	// the API still requires the token
+	assert.Equal(t, http.StatusUnauthorized, serve(srv, http.MethodGet, "/api/v1/canaries", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(srv, http.MethodPost, DashboardPath, "").Code)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Canary is the configuration for a canary release,
+// which automatically manages the bootstrap, analysis, traffic shifting,
+// promotion or rollback of an app revision
+type Canary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CanarySpec           `json:"spec"`
+	Status v1beta1.CanaryStatus `json:"status"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CanaryList is a list of Canary resources
+type CanaryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Canary `json:"items"`
+}
+
+// CanarySpec is the v1beta1 specification without the deprecated fields,
+// the deprecated canaryAnalysis is merged into the analysis
+type CanarySpec struct {
+	v1beta1.CanarySpec `json:",inline"`
+
+	// Analysis defines the validation process of a release
+	Analysis *CanaryAnalysis `json:"analysis,omitempty"`
+}
+
+// CanaryAnalysis is the v1beta1 analysis where the step weights and their
+// durations are replaced by the steps and the metric threshold by the threshold range,
+// the replaced v1beta1 fields are recorded in the ConversionAnnotation
+type CanaryAnalysis struct {
+	v1beta1.CanaryAnalysis `json:",inline"`
+
+	// Steps are the traffic weights of the analysis phase
+	// +optional
+	Steps []CanaryStep `json:"steps,omitempty"`
+}
+
+// CanaryStep is a traffic weight of the analysis phase
+type CanaryStep struct {
+	// Weight of the traffic routed to the canary
+	Weight int `json:"weight"`
+
+	// Duration is the minimum time to hold the weight, defaults to the analysis interval
+	// +optional
+	Duration string `json:"duration,omitempty"`
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// zeroDuration is the hold duration of the steps without a duration
+// when some of the steps have one, it is ignored by the scheduler
+const zeroDuration = "0s"
+
+// ConversionAnnotation records the v1beta1 fields that aren't represented in v1,
+// they are restored when the canary is converted back to v1beta1
+const ConversionAnnotation = "flagger.app/v1beta1-conversion"
+
+// conversionData holds the v1beta1 fields dropped by the conversion to v1
+type conversionData struct {
+	// CanaryAnalysis is set when the analysis was defined with the deprecated canaryAnalysis
+	CanaryAnalysis bool `json:"deprecatedAnalysis,omitempty"`
+	// StepWeightDurations are the durations as defined in v1beta1
+	StepWeightDurations []string `json:"stepWeightDurations,omitempty"`
+	// Thresholds are the deprecated thresholds of the metrics
+	Thresholds map[string]metricThreshold `json:"thresholds,omitempty"`
+}
+
+// metricThreshold is the deprecated threshold of a metric, Range is set
+// when the threshold range of the metric was derived from the threshold
+type metricThreshold struct {
+	Threshold float64 `json:"threshold"`
+	Range     bool    `json:"range,omitempty"`
+}
+
+// thresholdRange returns the range equivalent to the deprecated threshold,
+// the min success rate or the max value of the other metrics
+func thresholdRange(name string, threshold float64) *v1beta1.CanaryThresholdRange {
+	switch name {
+	case "request-success-rate", "grpc-success-rate":
+		return &v1beta1.CanaryThresholdRange{Min: &threshold}
+	default:
+		return &v1beta1.CanaryThresholdRange{Max: &threshold}
+	}
+}
+
+// stepDurations returns the hold duration of each step weight, the zero durations are omitted
+func stepDurations(weights []int, durations []string) []string {
+	out := make([]string, len(weights))
+	for i := range weights {
+		if i < len(durations) && durations[i] != zeroDuration {
+			out[i] = durations[i]
+		}
+	}
+	return out
+}
+
+// ConvertFrom converts the v1beta1 canary to this version
+func (dst *Canary) ConvertFrom(src *v1beta1.Canary) {
+	dst.TypeMeta = metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: v1beta1.CanaryKind}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	src.Status.DeepCopyInto(&dst.Status)
+
+	src.Spec.DeepCopyInto(&dst.Spec.CanarySpec)
+	dst.Spec.CanarySpec.Analysis = nil
+	dst.Spec.CanarySpec.CanaryAnalysis = nil
+	dst.Spec.Analysis = nil
+
+	analysis := src.GetAnalysis()
+	if analysis == nil {
+		return
+	}
+	dst.Spec.Analysis = &CanaryAnalysis{}
+	analysis.DeepCopyInto(&dst.Spec.Analysis.CanaryAnalysis)
+
+	var data conversionData
+	data.CanaryAnalysis = src.Spec.Analysis == nil
+
+	out := &dst.Spec.Analysis.CanaryAnalysis
+	for i, duration := range stepDurations(out.StepWeights, out.StepWeightDurations) {
+		dst.Spec.Analysis.Steps = append(dst.Spec.Analysis.Steps, CanaryStep{Weight: out.StepWeights[i], Duration: duration})
+	}
+	data.StepWeightDurations = out.StepWeightDurations
+	out.StepWeights, out.StepWeightDurations = nil, nil
+
+	// the deprecated threshold is replaced by the equivalent threshold range
+	for i := range out.Metrics {
+		metric := &out.Metrics[i]
+		if metric.Threshold == 0 {
+			continue
+		}
+		if data.Thresholds == nil {
+			data.Thresholds = make(map[string]metricThreshold)
+		}
+		data.Thresholds[metric.Name] = metricThreshold{Threshold: metric.Threshold, Range: metric.ThresholdRange == nil}
+		if metric.ThresholdRange == nil {
+			metric.ThresholdRange = thresholdRange(metric.Name, metric.Threshold)
+		}
+		metric.Threshold = 0
+	}
+
+	delete(dst.Annotations, ConversionAnnotation)
+	if data.CanaryAnalysis || data.StepWeightDurations != nil || data.Thresholds != nil {
+		if raw, err := json.Marshal(data); err == nil {
+			if dst.Annotations == nil {
+				dst.Annotations = make(map[string]string)
+			}
+			dst.Annotations[ConversionAnnotation] = string(raw)
+		}
+	}
+}
+
+// ConvertTo converts this canary to the v1beta1 version
+func (src *Canary) ConvertTo(dst *v1beta1.Canary) {
+	dst.TypeMeta = metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: v1beta1.CanaryKind}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	src.Status.DeepCopyInto(&dst.Status)
+
+	src.Spec.CanarySpec.DeepCopyInto(&dst.Spec)
+	dst.Spec.Analysis = nil
+	dst.Spec.CanaryAnalysis = nil
+
+	var data conversionData
+	if raw, ok := dst.Annotations[ConversionAnnotation]; ok {
+		delete(dst.Annotations, ConversionAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+		_ = json.Unmarshal([]byte(raw), &data)
+	}
+
+	if src.Spec.Analysis == nil {
+		return
+	}
+	dst.Spec.Analysis = src.Spec.Analysis.CanaryAnalysis.DeepCopy()
+	defer func() {
+		if data.CanaryAnalysis {
+			dst.Spec.CanaryAnalysis, dst.Spec.Analysis = dst.Spec.Analysis, nil
+		}
+	}()
+
+	// the deprecated thresholds are restored unless the threshold range was changed in v1
+	for i := range dst.Spec.Analysis.Metrics {
+		metric := &dst.Spec.Analysis.Metrics[i]
+		t, ok := data.Thresholds[metric.Name]
+		if !ok || metric.Threshold != 0 {
+			continue
+		}
+		if !t.Range {
+			metric.Threshold = t.Threshold
+		} else if reflect.DeepEqual(metric.ThresholdRange, thresholdRange(metric.Name, t.Threshold)) {
+			metric.Threshold, metric.ThresholdRange = t.Threshold, nil
+		}
+	}
+
+	steps := src.Spec.Analysis.Steps
+	if len(steps) == 0 {
+		return
+	}
+	dst.Spec.Analysis.StepWeights = make([]int, 0, len(steps))
+	dst.Spec.Analysis.StepWeightDurations = nil
+	durations := make([]string, 0, len(steps))
+	hasDuration := false
+	for _, step := range steps {
+		dst.Spec.Analysis.StepWeights = append(dst.Spec.Analysis.StepWeights, step.Weight)
+		duration := step.Duration
+		if duration == "" {
+			duration = zeroDuration
+		} else {
+			hasDuration = true
+		}
+		durations = append(durations, duration)
+	}
+	if hasDuration {
+		dst.Spec.Analysis.StepWeightDurations = durations
+	}
+
+	// the durations are restored as defined in v1beta1 unless the steps were changed in v1
+	if data.StepWeightDurations != nil {
+		current := make([]string, 0, len(steps))
+		for _, step := range steps {
+			current = append(current, step.Duration)
+		}
+		if reflect.DeepEqual(stepDurations(dst.Spec.Analysis.StepWeights, data.StepWeightDurations), current) {
+			dst.Spec.Analysis.StepWeightDurations = data.StepWeightDurations
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func newTestCanary() *v1beta1.Canary {
+	return &v1beta1.Canary{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec: v1beta1.CanarySpec{
+			Provider: "istio",
+			CanaryAnalysis: &v1beta1.CanaryAnalysis{
+				Interval:            "1m",
+				Threshold:           5,
+				StepWeights:         []int{10, 50},
+				StepWeightDurations: []string{"5m"},
+				Metrics: []v1beta1.CanaryMetric{
+					{Name: "request-success-rate", Threshold: 99},
+					{Name: "request-duration", Threshold: 500},
+				},
+			},
+		},
+		Status: v1beta1.CanaryStatus{Phase: v1beta1.CanaryPhaseSucceeded},
+	}
+}
+
+func TestCanary_ConvertFrom(t *testing.T) {
+	var cd Canary
+	cd.ConvertFrom(newTestCanary())
+
+	assert.Equal(t, "flagger.app/v1", cd.APIVersion)
+	assert.Nil(t, cd.Spec.CanarySpec.CanaryAnalysis)
+	require.NotNil(t, cd.Spec.Analysis)
+	assert.Equal(t, []CanaryStep{{Weight: 10, Duration: "5m"}, {Weight: 50}}, cd.Spec.Analysis.Steps)
+	assert.Nil(t, cd.Spec.Analysis.StepWeights)
+	assert.Equal(t, v1beta1.CanaryPhaseSucceeded, cd.Status.Phase)
+
+	metrics := cd.Spec.Analysis.Metrics
+	assert.Equal(t, float64(99), *metrics[0].ThresholdRange.Min)
+	assert.Equal(t, float64(500), *metrics[1].ThresholdRange.Max)
+	assert.Zero(t, metrics[1].Threshold)
+
+	data, err := json.Marshal(&cd)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"steps":[{"weight":10,"duration":"5m"},{"weight":50}]`)
+	assert.NotContains(t, string(data), "canaryAnalysis")
+	assert.NotContains(t, string(data), "stepWeights")
+}
+
+func TestCanary_ConvertTo(t *testing.T) {
+	var cd Canary
+	cd.ConvertFrom(newTestCanary())
+	// the canary is converted as if it was created with v1
+	delete(cd.Annotations, ConversionAnnotation)
+
+	var out v1beta1.Canary
+	cd.ConvertTo(&out)
+	assert.Equal(t, "flagger.app/v1beta1", out.APIVersion)
+	require.NotNil(t, out.Spec.Analysis)
+	assert.Nil(t, out.Spec.CanaryAnalysis)
+	assert.Equal(t, []int{10, 50}, out.Spec.Analysis.StepWeights)
+	assert.Equal(t, []string{"5m", "0s"}, out.Spec.Analysis.StepWeightDurations)
+	assert.Equal(t, 5, out.Spec.Analysis.Threshold)
+
+	// the durations are not set when none of the steps has one
+	cd.Spec.Analysis.Steps = []CanaryStep{{Weight: 20}, {Weight: 40}}
+	cd.ConvertTo(&out)
+	assert.Equal(t, []int{20, 40}, out.Spec.Analysis.StepWeights)
+	assert.Nil(t, out.Spec.Analysis.StepWeightDurations)
+
+	// converting back gives the same v1 canary
+	var back Canary
+	back.ConvertFrom(&out)
+	assert.Equal(t, cd.Spec, back.Spec)
+}
+
+func TestCanary_ConvertRoundTrip(t *testing.T) {
+	for name, src := range map[string]*v1beta1.Canary{
+		"deprecated analysis": newTestCanary(),
+		"analysis": func() *v1beta1.Canary {
+			cd := newTestCanary()
+			cd.Annotations = map[string]string{"app": "podinfo"}
+			cd.Spec.Analysis, cd.Spec.CanaryAnalysis = cd.Spec.CanaryAnalysis, nil
+			cd.Spec.Analysis.StepWeightDurations = []string{"0s", "0s"}
+			cd.Spec.Analysis.Metrics = append(cd.Spec.Analysis.Metrics, v1beta1.CanaryMetric{
+				Name:           "error-rate",
+				Threshold:      1,
+				ThresholdRange: &v1beta1.CanaryThresholdRange{Max: toFloatPtr(5)},
+			})
+			return cd
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			src.TypeMeta = metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: v1beta1.CanaryKind}
+
+			var cd Canary
+			cd.ConvertFrom(src.DeepCopy())
+			var out v1beta1.Canary
+			cd.ConvertTo(&out)
+			assert.Equal(t, src, &out)
+		})
+	}
+
+	// the threshold range changed in v1 replaces the deprecated threshold
+	var cd Canary
+	cd.ConvertFrom(newTestCanary())
+	cd.Spec.Analysis.Metrics[1].ThresholdRange.Max = toFloatPtr(300)
+	var out v1beta1.Canary
+	cd.ConvertTo(&out)
+	assert.Zero(t, out.Spec.CanaryAnalysis.Metrics[1].Threshold)
+	assert.Equal(t, float64(300), *out.Spec.CanaryAnalysis.Metrics[1].ThresholdRange.Max)
+	assert.Equal(t, float64(99), out.Spec.CanaryAnalysis.Metrics[0].Threshold)
+	assert.Nil(t, out.Spec.CanaryAnalysis.Metrics[0].ThresholdRange)
+}
+
+func toFloatPtr(val float64) *float64 {
+	return &val
+}
@@ -17,11 +17,19 @@ limitations under the License.
 package v1beta1
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/fluxcd/flagger/pkg/apis/gatewayapi/v1alpha2"
 	istiov1alpha3 "github.com/fluxcd/flagger/pkg/apis/istio/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -30,9 +38,38 @@ const (
 	CanaryKind              = "Canary"
 	ProgressDeadlineSeconds = 600
 	AnalysisInterval        = 60 * time.Second
+	AnalysisRetryInterval   = 5 * time.Minute
 	PrimaryReadyThreshold   = 100
 	CanaryReadyThreshold    = 100
 	MetricInterval          = "1m"
+	IstioTelemetryTag       = "flagger_role"
+	SessionAffinityMaxAge   = 86400
+	DefaultKubeConfigKey    = "kubeconfig"
+)
+
+const (
+	// CanaryLabel is set on the objects generated by Flagger to the name of the canary,
+	// names longer than a label value are truncated and suffixed with a hash
+	CanaryLabel = "flagger.app/canary"
+	// CanaryRunIDLabel is set on the objects generated by Flagger to the ID of the analysis run
+	// that last wrote them
+	CanaryRunIDLabel = "flagger.app/run-id"
+	// ApproveAnnotation approves the promotion of a canary with manual promotion enabled,
+	// the value is either "true" or the last applied spec checksum of the canary
+	ApproveAnnotation = "flagger.app/approve"
+	// RollbackAnnotation rolls back the canary under analysis,
+	// the value is either "true" or the last applied spec checksum of the canary
+	RollbackAnnotation = "flagger.app/rollback"
+	// GateAnnotation halts the rollout and the promotion of the canary when set to "closed"
+	GateAnnotation = "flagger.app/gate"
+	// GateClosed is the value of the gate annotation that halts the analysis
+	GateClosed = "closed"
+
+	// maxFieldManagerLength is the max length of a field manager name accepted by the Kubernetes API
+	maxFieldManagerLength = 128
+
+	// maxLabelValueLength is the max length of a label value accepted by the Kubernetes API
+	maxLabelValueLength = 63
 )
 
 // +genclient
@@ -69,12 +106,32 @@ type CanarySpec struct {
 	// +optional
 	MetricsServer string `json:"metricsServer,omitempty"`
 
+	// KubeConfig references a secret containing the kubeconfig of the cluster
+	// where the target workload and the routing objects are managed
+	// +optional
+	KubeConfig *KubeConfigReference `json:"kubeConfig,omitempty"`
+
 	// TargetRef references a target resource
 	TargetRef LocalObjectReference `json:"targetRef"`
 
+	// TargetWorkload describes a custom workload that implements the scale subresource,
+	// it is required when the kind of the target resource isn't supported natively
+	// +optional
+	TargetWorkload *TargetWorkload `json:"targetWorkload,omitempty"`
+
+	// TrackedObjects are namespaced objects, such as feature flag custom resources,
+	// whose changes trigger a canary analysis like the tracked ConfigMaps and Secrets
+	// +optional
+	TrackedObjects []TrackedObjectReference `json:"trackedObjects,omitempty"`
+
+	// ConfigRewrites selects the container args, env values and pod annotations in which
+	// the names of the tracked ConfigMaps and Secrets are replaced with their primary copies
+	// +optional
+	ConfigRewrites []ConfigRewriteRule `json:"configRewrites,omitempty"`
+
 	// AutoscalerRef references an autoscaling resource
 	// +optional
-	AutoscalerRef *LocalObjectReference `json:"autoscalerRef,omitempty"`
+	AutoscalerRef *AutoscalerReference `json:"autoscalerRef,omitempty"`
 
 	// Reference to NGINX ingress resource
 	// +optional
@@ -103,11 +160,310 @@ type CanarySpec struct {
 	// +optional
 	SkipAnalysis bool `json:"skipAnalysis,omitempty"`
 
+	// SkipAnalysisRules promote the revisions matching one of the rules without analysing them
+	// +optional
+	SkipAnalysisRules []SkipAnalysisRule `json:"skipAnalysisRules,omitempty"`
+
+	// FastPath promotes or shortens the analysis of the revisions that only
+	// change allow-listed fields of the pod template
+	// +optional
+	FastPath *FastPath `json:"fastPath,omitempty"`
+
+	// Suspend pauses the analysis, the traffic weights and the analysis progress
+	// are kept until the canary is resumed
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
 	// revert canary mutation on deletion of canary resource
 	// +optional
 	RevertOnDeletion bool `json:"revertOnDeletion,omitempty"`
+
+	// RevertPolicy sets which objects are reverted on deletion when RevertOnDeletion is enabled,
+	// defaults to Adopted
+	// +optional
+	RevertPolicy RevertPolicy `json:"revertPolicy,omitempty"`
+
+	// LifecycleHooksPolicy sets whether the lifecycle hooks of the target containers
+	// are copied to the primary workload, defaults to Copy
+	// +optional
+	LifecycleHooksPolicy LifecycleHooksPolicy `json:"lifecycleHooksPolicy,omitempty"`
+
+	// ImageTracking selects the containers whose image changes trigger a canary analysis
+	// +optional
+	ImageTracking *ImageTracking `json:"imageTracking,omitempty"`
+
+	// NodePartition restricts the canary DaemonSet to a labeled subset of the nodes,
+	// the share of labeled nodes follows the canary weight
+	// +optional
+	NodePartition *NodePartition `json:"nodePartition,omitempty"`
+
+	// PrimaryNaming sets the templates of the primary objects names and selector label values
+	// +optional
+	PrimaryNaming *PrimaryNaming `json:"primaryNaming,omitempty"`
+
+	// PrimaryAntiAffinity schedules the primary pods apart from the canary pods
+	// +optional
+	PrimaryAntiAffinity *PrimaryAntiAffinity `json:"primaryAntiAffinity,omitempty"`
+
+	// ConflictPolicy sets how the out-of-band changes of the primary pod template are handled,
+	// the changes of the primary and apex services selector and ports are always reverted.
+	// Drift detection is disabled when not set
+	// +optional
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+
+	// MetadataPropagation selects the labels and annotations copied from the target
+	// and its autoscalers to the primary objects and the generated services
+	// +optional
+	MetadataPropagation *MetadataPropagation `json:"metadataPropagation,omitempty"`
+}
+
+// PrimaryAntiAffinity describes the pod anti-affinity added to the primary pods
+// so that the primary and canary replicas land in different topology domains
+type PrimaryAntiAffinity struct {
+	// TopologyKey is the node label of the topology domains, defaults to kubernetes.io/hostname
+	// +optional
+	TopologyKey string `json:"topologyKey,omitempty"`
+
+	// Required makes the anti-affinity a scheduling requirement instead of a preference
+	// +optional
+	Required bool `json:"required,omitempty"`
+}
+
+// PrimaryNaming holds the Go templates used to derive the primary names and label values,
+// the templates are rendered with the .Name of the target object or label value,
+// and the canary .Canary name and .Namespace
+type PrimaryNaming struct {
+	// NameTemplate is the template of the primary objects names, defaults to {{ .Name }}-primary
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
+
+	// LabelTemplate is the template of the primary selector label values, defaults to {{ .Name }}-primary
+	// +optional
+	LabelTemplate string `json:"labelTemplate,omitempty"`
+}
+
+// primaryNamingData is the data the primary naming templates are rendered with
+type primaryNamingData struct {
+	Name      string
+	Canary    string
+	Namespace string
+}
+
+// primaryNamingTemplates caches the parsed primary naming templates by their text,
+// so that the templates are parsed once instead of each time a primary name is derived
+var primaryNamingTemplates sync.Map
+
+// parsePrimaryNaming returns the parsed primary naming template
+func parsePrimaryNaming(tmpl string) (*template.Template, error) {
+	if t, ok := primaryNamingTemplates.Load(tmpl); ok {
+		return t.(*template.Template), nil
+	}
+	t, err := template.New("primary").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("template parsing failed: %w", err)
+	}
+	primaryNamingTemplates.Store(tmpl, t)
+	return t, nil
+}
+
+// RenderPrimaryNaming renders a primary naming template, an empty template appends the -primary suffix
+func (c *Canary) RenderPrimaryNaming(tmpl string, name string) (string, error) {
+	if tmpl == "" {
+		return fmt.Sprintf("%s-primary", name), nil
+	}
+	t, err := parsePrimaryNaming(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, primaryNamingData{Name: name, Canary: c.Name, Namespace: c.Namespace}); err != nil {
+		return "", fmt.Errorf("template rendering failed: %w", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// GetPrimaryName returns the name of the primary object derived from the given name,
+// it returns an error if the name template is invalid or renders an empty name
+func (c *Canary) GetPrimaryName(name string) (string, error) {
+	var tmpl string
+	if c.Spec.PrimaryNaming != nil {
+		tmpl = c.Spec.PrimaryNaming.NameTemplate
+	}
+	out, err := c.RenderPrimaryNaming(tmpl, name)
+	if err != nil {
+		return "", fmt.Errorf("primary name of %s in canary %s.%s: %w", name, c.Name, c.Namespace, err)
+	}
+	if out == "" {
+		return "", fmt.Errorf("primary name of %s in canary %s.%s: the name template rendered an empty name",
+			name, c.Name, c.Namespace)
+	}
+	return out, nil
+}
+
+// GetPrimaryLabelValue returns the primary selector label value derived from the given value,
+// it returns an error if the label template is invalid or renders an empty value
+func (c *Canary) GetPrimaryLabelValue(value string) (string, error) {
+	var tmpl string
+	if c.Spec.PrimaryNaming != nil {
+		tmpl = c.Spec.PrimaryNaming.LabelTemplate
+	}
+	out, err := c.RenderPrimaryNaming(tmpl, value)
+	if err != nil {
+		return "", fmt.Errorf("primary label value of %s in canary %s.%s: %w", value, c.Name, c.Namespace, err)
+	}
+	if out == "" {
+		return "", fmt.Errorf("primary label value of %s in canary %s.%s: the label template rendered an empty value",
+			value, c.Name, c.Namespace)
+	}
+	return out, nil
+}
+
+// ValidatePrimaryNaming returns an error if the primary naming templates can't be rendered for the target
+func (c *Canary) ValidatePrimaryNaming() error {
+	if _, err := c.GetPrimaryName(c.Spec.TargetRef.Name); err != nil {
+		return err
+	}
+	if _, err := c.GetPrimaryLabelValue(c.Spec.TargetRef.Name); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NodePartition selects the nodes that are progressively moved from the primary
+// to the canary DaemonSet during the analysis
+type NodePartition struct {
+	// NodeSelector selects the nodes eligible for the canary DaemonSet, defaults to all the nodes
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Label is the node label key that assigns a node to the canary DaemonSet,
+	// defaults to canary.flagger.app/partition-<hash of the canary namespace and name>
+	// +optional
+	Label string `json:"label,omitempty"`
+}
+
+// NodePartitionLabelValue is the value of the node label that assigns a node to the canary DaemonSet
+const NodePartitionLabelValue = "canary"
+
+// GetNodePartitionLabel returns the node label key that assigns a node to the canary DaemonSet,
+// the default key is derived from a hash of the namespace and name so that it is unique
+// across namespaces and fits the 63 characters limit of the label names
+func (c *Canary) GetNodePartitionLabel() string {
+	if c.Spec.NodePartition == nil {
+		return ""
+	}
+	if c.Spec.NodePartition.Label != "" {
+		return c.Spec.NodePartition.Label
+	}
+	return fmt.Sprintf("canary.flagger.app/partition-%s", shortHash(c.Namespace+"/"+c.Name))
+}
+
+// ImageTracking holds the lists of the containers included or excluded from the image change detection,
+// the image changes of the other containers are promoted along with the next revision
+type ImageTracking struct {
+	// Include lists the containers whose image changes are tracked, defaults to all the containers
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude lists the containers whose image changes are ignored
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// IsTracked returns true if the image changes of the container trigger a canary analysis
+func (t *ImageTracking) IsTracked(container string) bool {
+	if t == nil {
+		return true
+	}
+	for _, name := range t.Exclude {
+		if name == container {
+			return false
+		}
+	}
+	if len(t.Include) == 0 {
+		return true
+	}
+	for _, name := range t.Include {
+		if name == container {
+			return true
+		}
+	}
+	return false
 }
 
+// LifecycleHooksPolicy defines how the containers lifecycle hooks are handled
+type LifecycleHooksPolicy string
+
+const (
+	// CopyLifecycleHooksPolicy copies the postStart and preStop hooks to the primary containers
+	CopyLifecycleHooksPolicy LifecycleHooksPolicy = "Copy"
+	// IgnoreLifecycleHooksPolicy drops the hooks from the primary containers
+	// and ignores the hooks changes when detecting a new revision
+	IgnoreLifecycleHooksPolicy LifecycleHooksPolicy = "Ignore"
+)
+
+// RevertPolicy defines the objects reverted on canary deletion
+type RevertPolicy string
+
+const (
+	// AdoptedRevertPolicy reverts the objects adopted by Flagger and deletes the generated routing objects,
+	// the generated services and autoscalers are garbage collected with the canary
+	AdoptedRevertPolicy RevertPolicy = "Adopted"
+	// FullRevertPolicy also keeps the generated apex service routing to the target
+	// and removes the generated autoscaler before the canary is deleted
+	FullRevertPolicy RevertPolicy = "Full"
+)
+
+// SkipAnalysisRule matches the revisions that are promoted without analysis,
+// a rule matches when all of its conditions match
+type SkipAnalysisRule struct {
+	// ImageTag is a regular expression matched against the image tag of each container
+	// +optional
+	ImageTag string `json:"imageTag,omitempty"`
+
+	// Annotation is the key of a pod template annotation that must be set
+	// +optional
+	Annotation string `json:"annotation,omitempty"`
+
+	// AnnotationValue is a regular expression matched against the annotation value
+	// +optional
+	AnnotationValue string `json:"annotationValue,omitempty"`
+}
+
+// FastPath describes the revisions that don't need a full analysis
+type FastPath struct {
+	// Fields lists the pod template fields whose changes take the fast path, defaults to Resources
+	// +optional
+	Fields []FastPathField `json:"fields,omitempty"`
+
+	// Policy sets how the fast path revisions are rolled out, defaults to Promote
+	// +optional
+	Policy FastPathPolicy `json:"policy,omitempty"`
+}
+
+// FastPathField is a pod template field whose changes take the fast path
+type FastPathField string
+
+const (
+	// ResourcesFastPathField matches the containers resource requests and limits
+	ResourcesFastPathField FastPathField = "Resources"
+	// EnvFastPathField matches the containers environment variables
+	EnvFastPathField FastPathField = "Env"
+	// AnnotationsFastPathField matches the pod template annotations
+	AnnotationsFastPathField FastPathField = "Annotations"
+)
+
+// FastPathPolicy defines how the fast path revisions are rolled out
+type FastPathPolicy string
+
+const (
+	// PromoteFastPathPolicy promotes the revision without analysis
+	PromoteFastPathPolicy FastPathPolicy = "Promote"
+	// ShortAnalysisFastPathPolicy runs a single analysis step at the max weight
+	// or a single iteration before promoting the revision
+	ShortAnalysisFastPathPolicy FastPathPolicy = "ShortAnalysis"
+)
+
 // CanaryService defines how ClusterIP services, service mesh or ingress routing objects are generated
 type CanaryService struct {
 	// Name of the Kubernetes service generated by Flagger
@@ -131,6 +487,31 @@ type CanaryService struct {
 	// PortDiscovery adds all container ports to the generated Kubernetes service
 	PortDiscovery bool `json:"portDiscovery"`
 
+	// AdditionalPorts of the generated Kubernetes services e.g. a gRPC port next to the HTTP one,
+	// the traffic of each port is shifted together with the main port
+	// +optional
+	AdditionalPorts []CanaryServicePort `json:"additionalPorts,omitempty"`
+
+	// ExistingServices are pre-existing Kubernetes services that select the target pods,
+	// Flagger routes them to the primary pods and creates a canary copy of each service
+	// +optional
+	ExistingServices []string `json:"existingServices,omitempty"`
+
+	// IPFamilies of the generated Kubernetes services e.g. IPv6 or IPv4 and IPv6 for dual-stack
+	// Defaults to the cluster IP families
+	// +optional
+	IPFamilies []corev1.IPFamily `json:"ipFamilies,omitempty"`
+
+	// IPFamilyPolicy of the generated Kubernetes services
+	// Defaults to SingleStack
+	// +optional
+	IPFamilyPolicy *corev1.IPFamilyPolicyType `json:"ipFamilyPolicy,omitempty"`
+
+	// ClusterDomain is the DNS domain used to build the services FQDN
+	// Defaults to the domain set with the -cluster-domain flag
+	// +optional
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+
 	// Timeout of the HTTP or gRPC request
 	// +optional
 	Timeout string `json:"timeout,omitempty"`
@@ -172,6 +553,11 @@ type CanaryService struct {
 	// +optional
 	Retries *istiov1alpha3.HTTPRetry `json:"retries,omitempty"`
 
+	// RetryBudget limits the concurrent retries sent to the primary and canary workloads,
+	// supported by Gloo only
+	// +optional
+	RetryBudget *RetryBudget `json:"retryBudget,omitempty"`
+
 	// Headers operations for the generated Istio virtual service
 	// +optional
 	Headers *istiov1alpha3.Headers `json:"headers,omitempty"`
@@ -180,6 +566,10 @@ type CanaryService struct {
 	// +optional
 	CorsPolicy *istiov1alpha3.CorsPolicy `json:"corsPolicy,omitempty"`
 
+	// Telemetry generates Istio Telemetry resources adding the primary/canary dimension to the mesh metrics
+	// +optional
+	Telemetry *IstioTelemetry `json:"telemetry,omitempty"`
+
 	// Mesh name of the generated App Mesh virtual nodes and virtual service
 	// +optional
 	MeshName string `json:"meshName,omitempty"`
@@ -201,6 +591,49 @@ type CanaryService struct {
 	Canary *CustomMetadata `json:"canary,omitempty"`
 }
 
+// CanaryServicePort defines an additional port of the generated Kubernetes services
+type CanaryServicePort struct {
+	// Name of the port e.g. grpc
+	Name string `json:"name"`
+
+	// Port number of the generated Kubernetes services
+	Port int32 `json:"port"`
+
+	// Target port number or name of the generated Kubernetes services
+	// Defaults to CanaryServicePort.Port
+	// +optional
+	TargetPort intstr.IntOrString `json:"targetPort,omitempty"`
+
+	// AppProtocol of the port e.g. http, http2 or grpc
+	// +optional
+	AppProtocol string `json:"appProtocol,omitempty"`
+}
+
+// RetryBudget caps the concurrent retries per workload, so that the retries
+// against a failing canary don't amplify its load and hide its error rate
+type RetryBudget struct {
+	// Primary is the max number of concurrent retries to the primary workload
+	// +optional
+	Primary uint32 `json:"primary,omitempty"`
+
+	// Canary is the max number of concurrent retries to the canary workload
+	// +optional
+	Canary uint32 `json:"canary,omitempty"`
+}
+
+// IstioTelemetry is used to label the mesh metrics of the primary and canary workloads
+type IstioTelemetry struct {
+	// Tag is the name of the metrics dimension holding the workload role
+	// Defaults to flagger_role
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// Providers are the Istio telemetry providers the tag is added to
+	// Defaults to prometheus
+	// +optional
+	Providers []string `json:"providers,omitempty"`
+}
+
 // CanaryAnalysis is used to describe how the analysis should be done
 type CanaryAnalysis struct {
 	// Schedule interval for this canary analysis
@@ -218,6 +651,11 @@ type CanaryAnalysis struct {
 	// +optional
 	MirrorWeight int `json:"mirrorWeight,omitempty"`
 
+	// Number of Blue/Green iterations before the cutover during which the traffic is mirrored
+	// to the canary to warm it up, ignored when mirroring is enabled for the whole analysis
+	// +optional
+	MirrorWarmupIterations int `json:"mirrorWarmupIterations,omitempty"`
+
 	// Max traffic weight routed to canary
 	// +optional
 	MaxWeight int `json:"maxWeight,omitempty"`
@@ -230,6 +668,15 @@ type CanaryAnalysis struct {
 	// +optional
 	StepWeights []int `json:"stepWeights,omitempty"`
 
+	// Minimum time to hold each of the step weights, the durations are matched
+	// to the step weights by index e.g. 30m for the first step
+	// +optional
+	StepWeightDurations []string `json:"stepWeightDurations,omitempty"`
+
+	// Adaptive changes the step weight according to the metrics headroom
+	// +optional
+	Adaptive *AdaptiveProgression `json:"adaptive,omitempty"`
+
 	// Incremental traffic weight step for promotion phase
 	// +optional
 	StepWeightPromotion int `json:"stepWeightPromotion,omitempty"`
@@ -243,6 +690,63 @@ type CanaryAnalysis struct {
 	// Percentage of pods that need to be available to consider canary as ready
 	CanaryReadyThreshold *int `json:"canaryReadyThreshold,omitempty"`
 
+	// Number of times the analysis of a canary rolled back for reaching the failed checks
+	// threshold is retried for the same revision
+	// +optional
+	Retries int `json:"retries,omitempty"`
+
+	// Time to wait after a rollback before retrying the analysis (default 5m)
+	// +optional
+	RetryInterval string `json:"retryInterval,omitempty"`
+
+	// Time to wait for the in-flight requests to complete before scaling down the canary on rollback
+	// +optional
+	RollbackDrainPeriod string `json:"rollbackDrainPeriod,omitempty"`
+
+	// Time to keep the canary running with no traffic after the promotion before scaling it down,
+	// allows the in-flight requests and the long-lived connections to drain
+	// +optional
+	CanaryScaleDownDelay string `json:"canaryScaleDownDelay,omitempty"`
+
+	// RollbackPolicy sets the revision the primary is rolled back to, Primary (default)
+	// keeps the primary as it is and Pinned restores the last promoted revision
+	// +optional
+	RollbackPolicy RollbackPolicy `json:"rollbackPolicy,omitempty"`
+
+	// Time windows when traffic is routed to canary, outside the windows
+	// all traffic is routed to primary and the analysis is paused
+	// +optional
+	TrafficWindows []TimeWindow `json:"trafficWindows,omitempty"`
+
+	// Time windows when the traffic weight can be increased and the canary promoted,
+	// outside the windows the canary is held or rolled back according to the policy
+	// +optional
+	ProgressionWindows []TimeWindow `json:"progressionWindows,omitempty"`
+
+	// ProgressionWindowPolicy sets what happens to a started analysis outside the
+	// progression windows, defaults to Hold
+	// +optional
+	ProgressionWindowPolicy ProgressionWindowPolicy `json:"progressionWindowPolicy,omitempty"`
+
+	// Maximum time a started analysis can take, when exceeded the canary
+	// is rolled back or promoted according to the max duration policy
+	// +optional
+	MaxDuration string `json:"maxDuration,omitempty"`
+
+	// MaxDurationPolicy sets what happens to an analysis that exceeded
+	// the max duration, defaults to Rollback
+	// +optional
+	MaxDurationPolicy MaxDurationPolicy `json:"maxDurationPolicy,omitempty"`
+
+	// Halt the promotion until it's approved with the flagger.app/approve annotation
+	// +optional
+	ManualPromotion bool `json:"manualPromotion,omitempty"`
+
+	// DependsOn lists the canaries that must be promoted before this canary
+	// starts the analysis or is promoted
+	// +optional
+	DependsOn []CanaryDependency `json:"dependsOn,omitempty"`
+
 	// Alert list for this canary analysis
 	Alerts []CanaryAlert `json:"alerts,omitempty"`
 
@@ -250,6 +754,10 @@ type CanaryAnalysis struct {
 	// +optional
 	Metrics []CanaryMetric `json:"metrics,omitempty"`
 
+	// Kayenta delegates the metrics judgement to a Spinnaker Kayenta server
+	// +optional
+	Kayenta *KayentaAnalysis `json:"kayenta,omitempty"`
+
 	// Webhook list for this canary  analysis
 	// +optional
 	Webhooks []CanaryWebhook `json:"webhooks,omitempty"`
@@ -257,84 +765,389 @@ type CanaryAnalysis struct {
 	// A/B testing HTTP header match conditions
 	// +optional
 	Match []istiov1alpha3.HTTPMatchRequest `json:"match,omitempty"`
-}
-
-// CanaryMetric holds the reference to metrics used for canary analysis
-type CanaryMetric struct {
-	// Name of the metric
-	Name string `json:"name"`
 
-	// Interval represents the windows size
-	Interval string `json:"interval,omitempty"`
-
-	// Deprecated: Max value accepted for this metric (replaced by ThresholdRange)
-	Threshold float64 `json:"threshold,omitempty"`
+	// Shift the traffic of all users progressively with the step weights
+	// after the A/B testing iterations have passed
+	// +optional
+	ProgressiveAfterMatch bool `json:"progressiveAfterMatch,omitempty"`
 
-	// Range value accepted for this metric
+	// SessionAffinity pins the users routed to the canary with a cookie
+	// so that they are served by the same version for the rest of the analysis
 	// +optional
-	ThresholdRange *CanaryThresholdRange `json:"thresholdRange,omitempty"`
+	SessionAffinity *SessionAffinity `json:"sessionAffinity,omitempty"`
 
-	// Deprecated: Prometheus query for this metric (replaced by TemplateRef)
+	// DryRun runs the metric checks and webhooks against the canary without
+	// shifting the user traffic or promoting the canary
 	// +optional
-	Query string `json:"query,omitempty"`
+	DryRun bool `json:"dryRun,omitempty"`
 
-	// TemplateRef references a metric template object
+	// TemplateRef references an analysis template, the settings
+	// of this analysis override the ones of the template
 	// +optional
 	TemplateRef *CrossNamespaceObjectReference `json:"templateRef,omitempty"`
 }
 
-// CanaryThresholdRange defines the range used for metrics validation
-type CanaryThresholdRange struct {
-	// Minimum value
+// AdaptiveProgression holds the settings of the adaptive step weight, the step weight is doubled
+// when the metrics headroom is above the target and halved when it's below half of the target
+type AdaptiveProgression struct {
+	// Smallest step weight (defaults to half of the step weight)
 	// +optional
-	Min *float64 `json:"min,omitempty"`
+	MinStepWeight int `json:"minStepWeight,omitempty"`
 
-	// Maximum value
+	// Largest step weight (defaults to four times the step weight)
 	// +optional
-	Max *float64 `json:"max,omitempty"`
+	MaxStepWeight int `json:"maxStepWeight,omitempty"`
+
+	// Headroom is the target distance in percent between the metric values
+	// and the threshold range bounds (default 50)
+	// +optional
+	Headroom int `json:"headroom,omitempty"`
 }
 
-// AlertSeverity defines alert filtering based on severity levels
-type AlertSeverity string
+// SessionAffinity holds the settings of the cookie issued to the users routed to the canary
+type SessionAffinity struct {
+	// Name of the cookie
+	CookieName string `json:"cookieName"`
+
+	// Lifetime of the cookie in seconds (default 86400)
+	// +optional
+	MaxAge int `json:"maxAge,omitempty"`
+}
+
+// ProgressionWindowPolicy defines how a canary is handled outside the progression windows
+type ProgressionWindowPolicy string
 
 const (
-	SeverityInfo  AlertSeverity = "info"
-	SeverityWarn  AlertSeverity = "warn"
-	SeverityError AlertSeverity = "error"
+	// HoldProgressionWindowPolicy keeps the canary weight and runs the checks without advancing
+	HoldProgressionWindowPolicy ProgressionWindowPolicy = "Hold"
+	// RollbackProgressionWindowPolicy rolls back the canaries that have started the analysis
+	RollbackProgressionWindowPolicy ProgressionWindowPolicy = "Rollback"
 )
 
-// CanaryAlert defines an alert for this canary
-type CanaryAlert struct {
-	// Name of the alert
-	Name string `json:"name"`
+// MaxDurationPolicy defines how a canary is handled when the analysis exceeded the max duration
+type MaxDurationPolicy string
 
-	// Severity level: info, warn, error (default info)
-	Severity AlertSeverity `json:"severity,omitempty"`
+const (
+	// RollbackMaxDurationPolicy rolls back the canary
+	RollbackMaxDurationPolicy MaxDurationPolicy = "Rollback"
+	// PromoteMaxDurationPolicy promotes the canary without waiting for the remaining checks and gates
+	PromoteMaxDurationPolicy MaxDurationPolicy = "Promote"
+)
 
-	// Alert provider reference
-	ProviderRef CrossNamespaceObjectReference `json:"providerRef"`
-}
+// ConflictPolicy defines how the out-of-band changes of the primary workload are handled
+type ConflictPolicy string
 
-// HookType can be pre, post or during rollout
-type HookType string
+const (
+	// RevertConflictPolicy restores the pod template applied by Flagger on the primary
+	RevertConflictPolicy ConflictPolicy = "Revert"
+	// RevertWithWarningConflictPolicy restores the pod template applied by Flagger and emits a warning event
+	RevertWithWarningConflictPolicy ConflictPolicy = "RevertWithWarning"
+	// AdoptConflictPolicy keeps the changes and uses them as the new reference of the primary
+	AdoptConflictPolicy ConflictPolicy = "Adopt"
+)
+
+// RollbackPolicy defines the revision the primary is rolled back to
+type RollbackPolicy string
 
 const (
-	// RolloutHook execute webhook during the canary analysis
-	RolloutHook HookType = "rollout"
-	// PreRolloutHook execute webhook before routing traffic to canary
-	PreRolloutHook HookType = "pre-rollout"
-	// PostRolloutHook execute webhook after the canary analysis
-	PostRolloutHook HookType = "post-rollout"
-	// ConfirmRolloutHook halt canary analysis until webhook returns HTTP 200
-	ConfirmRolloutHook HookType = "confirm-rollout"
-	// ConfirmPromotionHook halt canary promotion until webhook returns HTTP 200
-	ConfirmPromotionHook HookType = "confirm-promotion"
-	// EventHook dispatches Flagger events to the specified endpoint
-	EventHook HookType = "event"
-	// RollbackHook rollback canary analysis if webhook returns HTTP 200
-	RollbackHook HookType = "rollback"
-	// ConfirmTrafficIncreaseHook increases traffic weight if webhook returns HTTP 200
-	ConfirmTrafficIncreaseHook = "confirm-traffic-increase"
+	// PrimaryRollbackPolicy keeps the primary as it is
+	PrimaryRollbackPolicy RollbackPolicy = "Primary"
+	// PinnedRollbackPolicy restores the container images of the last promoted revision on the primary
+	PinnedRollbackPolicy RollbackPolicy = "Pinned"
+)
+
+// CanaryDependency references a canary that must be promoted first
+type CanaryDependency struct {
+	// Name of the canary
+	Name string `json:"name"`
+
+	// Namespace of the canary
+	// Defaults to the namespace of the dependent canary
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Gate is the stage held until the canary is promoted, Start or Promotion
+	// Defaults to Start
+	// +optional
+	Gate DependencyGate `json:"gate,omitempty"`
+}
+
+// DependencyGate defines the stage of the analysis held by a dependency
+type DependencyGate string
+
+const (
+	// StartDependencyGate holds the start of the analysis
+	StartDependencyGate DependencyGate = "Start"
+	// PromotionDependencyGate holds the promotion
+	PromotionDependencyGate DependencyGate = "Promotion"
+)
+
+// GetGate returns the dependency gate default value (Start)
+func (d *CanaryDependency) GetGate() DependencyGate {
+	if d.Gate == "" {
+		return StartDependencyGate
+	}
+	return d.Gate
+}
+
+// CanaryMetric holds the reference to metrics used for canary analysis
+type CanaryMetric struct {
+	// Name of the metric
+	Name string `json:"name"`
+
+	// Interval represents the windows size
+	Interval string `json:"interval,omitempty"`
+
+	// Deprecated: Max value accepted for this metric (replaced by ThresholdRange)
+	Threshold float64 `json:"threshold,omitempty"`
+
+	// Range value accepted for this metric
+	// +optional
+	ThresholdRange *CanaryThresholdRange `json:"thresholdRange,omitempty"`
+
+	// FailureThreshold is the number of failed checks of this metric before the canary is rolled back,
+	// when set the failures of the metric are not counted against the analysis threshold
+	// +optional
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+
+	// ConsecutiveFailures resets the failed checks of the metric when it passes,
+	// by default the failed checks are counted over the whole analysis
+	// +optional
+	ConsecutiveFailures bool `json:"consecutiveFailures,omitempty"`
+
+	// WarningRange is the range of values that don't raise a warning,
+	// the values outside of it but within the threshold range hold the advancement
+	// without counting as failed checks
+	// +optional
+	WarningRange *CanaryThresholdRange `json:"warningRange,omitempty"`
+
+	// RelativeThreshold bounds the canary value relative to the value
+	// of the primary over the same interval
+	// +optional
+	RelativeThreshold *CanaryRelativeThreshold `json:"relativeThreshold,omitempty"`
+
+	// Percentile of the request duration builtin metrics e.g. 0.95
+	// Defaults to 0.99
+	// +optional
+	Percentile float64 `json:"percentile,omitempty"`
+
+	// Deprecated: Prometheus query for this metric (replaced by TemplateRef)
+	// +optional
+	Query string `json:"query,omitempty"`
+
+	// TemplateRef references a metric template object
+	// +optional
+	TemplateRef *CrossNamespaceObjectReference `json:"templateRef,omitempty"`
+
+	// Comparison replaces the threshold checks with a statistical test
+	// of the canary samples against the primary samples
+	// +optional
+	Comparison *CanaryMetricComparison `json:"comparison,omitempty"`
+
+	// BurnRate replaces the threshold checks with the error budget burn rate
+	// of the SLI computed over multiple windows
+	// +optional
+	BurnRate *CanaryMetricBurnRate `json:"burnRate,omitempty"`
+}
+
+// CanaryMetricComparison defines the statistical test used to compare
+// the canary and primary samples collected over the metric interval
+type CanaryMetricComparison struct {
+	// Test is the statistical test, only mann-whitney is supported
+	// +optional
+	Test string `json:"test,omitempty"`
+
+	// Alternative hypothesis that halts the advancement when accepted,
+	// can be greater, less or two-sided, defaults to greater
+	// +optional
+	Alternative string `json:"alternative,omitempty"`
+
+	// Significance level of the test, defaults to 0.05
+	// +optional
+	Significance float64 `json:"significance,omitempty"`
+
+	// Step is the resolution of the collected samples, defaults to 15s
+	// +optional
+	Step string `json:"step,omitempty"`
+
+	// MinSamples is the minimum number of samples required for both primary and canary, defaults to 10
+	// +optional
+	MinSamples int `json:"minSamples,omitempty"`
+}
+
+const (
+	// MannWhitneyComparisonTest is the Mann-Whitney U rank test
+	MannWhitneyComparisonTest = "mann-whitney"
+)
+
+// CanaryMetricBurnRate defines the SLO policy used to compute the error budget burn rate,
+// the metric template query must return the ratio of bad events over the interval window
+type CanaryMetricBurnRate struct {
+	// Objective of the SLO in percent e.g. 99.9
+	Objective float64 `json:"objective"`
+
+	// Windows over which the burn rate is computed, defaults to 5m and 1h
+	// +optional
+	Windows []string `json:"windows,omitempty"`
+
+	// MaxBurnRate halts the advancement when the burn rate exceeds it
+	// in all the windows, defaults to 14.4
+	// +optional
+	MaxBurnRate float64 `json:"maxBurnRate,omitempty"`
+}
+
+// KayentaAnalysis holds the Kayenta canary config used to judge
+// the canary metrics against the primary metrics
+type KayentaAnalysis struct {
+	// Address of the Kayenta API e.g. http://kayenta.kayenta:8090
+	Address string `json:"address"`
+
+	// Application name sent to Kayenta
+	// Defaults to flagger
+	// +optional
+	Application string `json:"application,omitempty"`
+
+	// CanaryConfigID is the ID of the Kayenta canary config
+	CanaryConfigID string `json:"canaryConfigId"`
+
+	// MetricsAccountName is the Kayenta account used to query the metrics
+	// +optional
+	MetricsAccountName string `json:"metricsAccountName,omitempty"`
+
+	// StorageAccountName is the Kayenta account used to store the results
+	// +optional
+	StorageAccountName string `json:"storageAccountName,omitempty"`
+
+	// ControlScope selects the primary metrics
+	// Defaults to the primary workload name
+	// +optional
+	ControlScope string `json:"controlScope,omitempty"`
+
+	// ExperimentScope selects the canary metrics
+	// Defaults to the canary workload name
+	// +optional
+	ExperimentScope string `json:"experimentScope,omitempty"`
+
+	// Location of the control and experiment scopes
+	// Defaults to the canary namespace
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// Interval is the time window judged by Kayenta
+	// Defaults to the analysis interval
+	// +optional
+	Interval string `json:"interval,omitempty"`
+
+	// Step is the metrics resolution
+	// Defaults to 1m
+	// +optional
+	Step string `json:"step,omitempty"`
+
+	// PassScore is the min score required to advance the canary
+	// Defaults to 95
+	// +optional
+	PassScore float64 `json:"passScore,omitempty"`
+
+	// MarginalScore is the min score that doesn't count as a failed check
+	// Defaults to 75
+	// +optional
+	MarginalScore float64 `json:"marginalScore,omitempty"`
+
+	// Timeout of the Kayenta judgement
+	// Defaults to 1m
+	// +optional
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// CanaryThresholdRange defines the range used for metrics validation
+type CanaryThresholdRange struct {
+	// Minimum value
+	// +optional
+	Min *float64 `json:"min,omitempty"`
+
+	// Maximum value
+	// +optional
+	Max *float64 `json:"max,omitempty"`
+}
+
+// CanaryRelativeThreshold defines the bounds of the canary value as ratios of the primary value
+type CanaryRelativeThreshold struct {
+	// MinRatio is the minimum canary value as a ratio of the primary value
+	// e.g. 0.99 for a success rate at most 1% lower than the primary
+	// +optional
+	MinRatio *float64 `json:"minRatio,omitempty"`
+
+	// MaxRatio is the maximum canary value as a ratio of the primary value
+	// e.g. 1.2 for a request duration at most 20% higher than the primary
+	// +optional
+	MaxRatio *float64 `json:"maxRatio,omitempty"`
+}
+
+// AlertSeverity defines alert filtering based on severity levels
+type AlertSeverity string
+
+const (
+	SeverityInfo  AlertSeverity = "info"
+	SeverityWarn  AlertSeverity = "warn"
+	SeverityError AlertSeverity = "error"
+)
+
+// CanaryAlert defines an alert for this canary
+type CanaryAlert struct {
+	// Name of the alert
+	Name string `json:"name"`
+
+	// Severity level: info, warn, error (default info)
+	Severity AlertSeverity `json:"severity,omitempty"`
+
+	// Alert provider reference
+	ProviderRef CrossNamespaceObjectReference `json:"providerRef"`
+}
+
+// HookType can be pre, post or during rollout
+type HookType string
+
+const (
+	// RolloutHook execute webhook during the canary analysis
+	RolloutHook HookType = "rollout"
+	// PreRolloutHook execute webhook before routing traffic to canary
+	PreRolloutHook HookType = "pre-rollout"
+	// PostRolloutHook execute webhook after the canary analysis
+	PostRolloutHook HookType = "post-rollout"
+	// ConfirmRolloutHook halt canary analysis until webhook returns HTTP 200
+	ConfirmRolloutHook HookType = "confirm-rollout"
+	// ConfirmPromotionHook halt canary promotion until webhook returns HTTP 200
+	ConfirmPromotionHook HookType = "confirm-promotion"
+	// EventHook dispatches Flagger events to the specified endpoint
+	EventHook HookType = "event"
+	// RollbackHook rollback canary analysis if webhook returns HTTP 200
+	RollbackHook HookType = "rollback"
+	// ConfirmTrafficIncreaseHook increases traffic weight if webhook returns HTTP 200
+	ConfirmTrafficIncreaseHook = "confirm-traffic-increase"
+	// PostRollbackHook execute webhook after the canary rollback with the failure details
+	PostRollbackHook HookType = "post-rollback"
+	// CloudEventsHook publishes the canary lifecycle events to the specified CloudEvents sink
+	CloudEventsHook HookType = "cloudevents"
+)
+
+// RollbackReason is the cause of a canary rollback
+type RollbackReason string
+
+const (
+	// FailedChecksRollbackReason means the failed checks threshold was reached
+	FailedChecksRollbackReason RollbackReason = "FailedChecks"
+	// ProgressDeadlineRollbackReason means the canary failed to progress
+	ProgressDeadlineRollbackReason RollbackReason = "ProgressDeadlineExceeded"
+	// WebhookRollbackReason means a rollback webhook signaled the rollback
+	WebhookRollbackReason RollbackReason = "RollbackWebhook"
+	// ReleaseGroupRollbackReason means another canary of the release group failed
+	ReleaseGroupRollbackReason RollbackReason = "ReleaseGroupFailed"
+	// ProgressionWindowRollbackReason means the canary was outside the progression windows
+	ProgressionWindowRollbackReason RollbackReason = "OutsideProgressionWindow"
+	// MaxDurationRollbackReason means the analysis exceeded the max duration
+	MaxDurationRollbackReason RollbackReason = "MaxDurationExceeded"
+	// ManualRollbackReason means the canary was annotated with flagger.app/rollback
+	ManualRollbackReason RollbackReason = "ManualRollback"
 )
 
 // CanaryWebhook holds the reference to external checks used for canary analysis
@@ -372,6 +1185,27 @@ type CanaryWebhookPayload struct {
 
 	// Metadata (key-value pairs) for this webhook
 	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Rollback holds the failure details sent to the post-rollback webhooks
+	Rollback *CanaryRollbackPayload `json:"rollback,omitempty"`
+}
+
+// CanaryRollbackPayload holds the cause of the rollback and the checks that failed
+type CanaryRollbackPayload struct {
+	// Reason of the rollback
+	Reason RollbackReason `json:"reason"`
+
+	// CanaryWeight is the traffic weight routed to the canary at failure time
+	CanaryWeight int `json:"canaryWeight"`
+
+	// Iterations of the analysis at failure time
+	Iterations int `json:"iterations"`
+
+	// FailedChecks is the number of failed checks at failure time
+	FailedChecks int `json:"failedChecks"`
+
+	// Failures holds the last failure of each metric and webhook
+	Failures []CanaryCheckFailure `json:"failures,omitempty"`
 }
 
 // CrossNamespaceObjectReference contains enough information to let you locate the
@@ -393,6 +1227,42 @@ type CrossNamespaceObjectReference struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+// TimeWindow is a daily time interval, when the end is before the start the window spans midnight
+// and when the start equals the end the window lasts the whole day
+type TimeWindow struct {
+	// Start of the window in the HH:MM format
+	Start string `json:"start"`
+
+	// End of the window in the HH:MM format
+	End string `json:"end"`
+
+	// Days of the week when the window starts e.g. Mon, Tue (defaults to every day)
+	// +optional
+	Days []string `json:"days,omitempty"`
+
+	// TimeZone is the IANA name of the window time zone (default UTC)
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// KubeConfigReference references a kubeconfig stored in a secret in the canary namespace
+type KubeConfigReference struct {
+	// SecretRef references the secret containing the kubeconfig
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+
+	// Key of the kubeconfig in the secret data, defaults to kubeconfig
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// GetKey returns the key of the kubeconfig in the secret data
+func (r *KubeConfigReference) GetKey() string {
+	if r.Key == "" {
+		return DefaultKubeConfigKey
+	}
+	return r.Key
+}
+
 // LocalObjectReference contains enough information to let you locate the typed
 // referenced object in the same namespace.
 type LocalObjectReference struct {
@@ -408,23 +1278,239 @@ type LocalObjectReference struct {
 	Name string `json:"name"`
 }
 
+// AutoscalerReference contains enough information to let you locate the
+// autoscaler of the target in the same namespace.
+type AutoscalerReference struct {
+	// API version of the referent
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the referent
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the referent
+	Name string `json:"name"`
+
+	// PrimaryScalerReplicas overrides the min and max replicas of the primary autoscaler
+	// +optional
+	PrimaryScalerReplicas *ScalerReplicas `json:"primaryScalerReplicas,omitempty"`
+}
+
+// ScalerReplicas holds the min and max replicas of an autoscaler
+type ScalerReplicas struct {
+	// MinReplicas is the lower limit of replicas, defaults to the autoscaler min replicas
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the upper limit of replicas, defaults to the autoscaler max replicas
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
+// TargetWorkload describes how to read the pod template of a custom workload
+type TargetWorkload struct {
+	// Resource is the plural name of the custom workload resource e.g. clonesets
+	Resource string `json:"resource"`
+
+	// PodTemplatePath is the dot separated path of the pod template in the custom workload
+	// Defaults to spec.template
+	// +optional
+	PodTemplatePath string `json:"podTemplatePath,omitempty"`
+}
+
+// GetPodTemplatePath returns the fields of the pod template path, defaults to spec.template
+func (t *TargetWorkload) GetPodTemplatePath() []string {
+	if t.PodTemplatePath == "" {
+		return []string{"spec", "template"}
+	}
+	return strings.Split(t.PodTemplatePath, ".")
+}
+
+// TrackedObjectReference references an object in the canary namespace
+type TrackedObjectReference struct {
+	// API version of the object e.g. flags.example.com/v1
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the object e.g. FeatureFlag
+	Kind string `json:"kind"`
+
+	// Resource is the plural name of the object resource
+	// Defaults to the lowercase kind with the s suffix
+	// +optional
+	Resource string `json:"resource,omitempty"`
+
+	// Name of the object
+	Name string `json:"name"`
+}
+
+// GetResource returns the plural name of the object resource
+func (r *TrackedObjectReference) GetResource() string {
+	if r.Resource != "" {
+		return r.Resource
+	}
+	return strings.ToLower(r.Kind) + "s"
+}
+
+// ConfigRewriteField defines the pod template values rewritten by a config rewrite rule
+type ConfigRewriteField string
+
+const (
+	// ArgsConfigRewriteField rewrites the containers args
+	ArgsConfigRewriteField ConfigRewriteField = "Args"
+	// EnvConfigRewriteField rewrites the containers env var values
+	EnvConfigRewriteField ConfigRewriteField = "Env"
+	// AnnotationsConfigRewriteField rewrites the pod annotations
+	AnnotationsConfigRewriteField ConfigRewriteField = "Annotations"
+)
+
+// ConfigRewriteRule selects the pod template values in which the tracked config names are rewritten
+type ConfigRewriteRule struct {
+	// Field is the kind of values rewritten, can be Args, Env or Annotations
+	Field ConfigRewriteField `json:"field"`
+
+	// Containers restricts the Args and Env rules to the named containers,
+	// the rule applies to all containers and init containers when empty
+	// +optional
+	Containers []string `json:"containers,omitempty"`
+
+	// Keys restricts the Env and Annotations rules to the named env vars or annotation keys,
+	// the rule applies to all values when empty
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+}
+
+// MatchesContainer returns true if the rule applies to the named container
+func (r *ConfigRewriteRule) MatchesContainer(name string) bool {
+	return len(r.Containers) == 0 || containsString(r.Containers, name)
+}
+
+// MatchesKey returns true if the rule applies to the env var or annotation key
+func (r *ConfigRewriteRule) MatchesKey(key string) bool {
+	return len(r.Keys) == 0 || containsString(r.Keys, key)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // CustomMetadata holds labels and annotations to set on generated objects.
 type CustomMetadata struct {
 	Labels      map[string]string `json:"labels,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
+// MetadataPropagation selects the labels and annotations propagated to the generated objects
+type MetadataPropagation struct {
+	// Labels selects the propagated labels, defaults to the labels matching the include label prefixes
+	// +optional
+	Labels *MetadataSelector `json:"labels,omitempty"`
+
+	// Annotations selects the propagated annotations
+	// +optional
+	Annotations *MetadataSelector `json:"annotations,omitempty"`
+}
+
+// MetadataSelector matches labels or annotations by key
+type MetadataSelector struct {
+	// IncludePrefixes selects the keys starting with one of the prefixes, * selects all keys
+	// +optional
+	IncludePrefixes []string `json:"includePrefixes,omitempty"`
+
+	// ExcludePrefixes drops the keys starting with one of the prefixes,
+	// it takes precedence over the included prefixes
+	// +optional
+	ExcludePrefixes []string `json:"excludePrefixes,omitempty"`
+
+	// Keys selects the given keys regardless of the prefixes
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+}
+
+// Matches returns true if the key is selected
+func (s *MetadataSelector) Matches(key string) bool {
+	for _, k := range s.Keys {
+		if k == key {
+			return true
+		}
+	}
+	for _, prefix := range s.ExcludePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	for _, prefix := range s.IncludePrefixes {
+		if prefix == "*" || strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the selected labels or annotations
+func (s *MetadataSelector) Select(meta map[string]string) map[string]string {
+	res := make(map[string]string)
+	for k, v := range meta {
+		if s.Matches(k) {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// GetLabelPropagation returns the selector of the propagated labels or nil if not set
+func (c *Canary) GetLabelPropagation() *MetadataSelector {
+	if c.Spec.MetadataPropagation == nil {
+		return nil
+	}
+	return c.Spec.MetadataPropagation.Labels
+}
+
+// GetAnnotationPropagation returns the selector of the propagated annotations or nil if not set
+func (c *Canary) GetAnnotationPropagation() *MetadataSelector {
+	if c.Spec.MetadataPropagation == nil {
+		return nil
+	}
+	return c.Spec.MetadataPropagation.Annotations
+}
+
 // GetServiceNames returns the apex, primary and canary Kubernetes service names
 func (c *Canary) GetServiceNames() (apexName, primaryName, canaryName string) {
 	apexName = c.Spec.TargetRef.Name
 	if c.Spec.Service.Name != "" {
 		apexName = c.Spec.Service.Name
 	}
-	primaryName = fmt.Sprintf("%s-primary", apexName)
+	// the primary name is left empty when the name template is invalid instead of guessing a name,
+	// the scheduler checks the templates with ValidatePrimaryNaming before reconciling the services
+	primaryName, _ = c.GetPrimaryName(apexName)
 	canaryName = fmt.Sprintf("%s-canary", apexName)
 	return
 }
 
+// GetExistingServiceCanaryName returns the name of the canary copy of an existing service
+func (c *Canary) GetExistingServiceCanaryName(name string) string {
+	return fmt.Sprintf("%s-canary", name)
+}
+
+// GetServicePorts returns the main port followed by the additional ports of the generated services
+func (c *Canary) GetServicePorts() []CanaryServicePort {
+	portName := c.Spec.Service.PortName
+	if portName == "" {
+		portName = "http"
+	}
+	ports := []CanaryServicePort{{
+		Name:       portName,
+		Port:       c.Spec.Service.Port,
+		TargetPort: c.Spec.Service.TargetPort,
+	}}
+	return append(ports, c.Spec.Service.AdditionalPorts...)
+}
+
 // GetProgressDeadlineSeconds returns the progress deadline (default 600s)
 func (c *Canary) GetProgressDeadlineSeconds() int {
 	if c.Spec.ProgressDeadlineSeconds != nil {
@@ -485,11 +1571,217 @@ func (c *Canary) GetAnalysisCanaryReadyThreshold() int {
 	return CanaryReadyThreshold
 }
 
+// GetAnalysisRollbackDrainPeriod returns the time to wait before scaling down the canary on rollback (default 0s)
+func (c *Canary) GetAnalysisRollbackDrainPeriod() time.Duration {
+	if c.GetAnalysis().RollbackDrainPeriod == "" {
+		return 0
+	}
+
+	period, err := time.ParseDuration(c.GetAnalysis().RollbackDrainPeriod)
+	if err != nil || period < 0 {
+		return 0
+	}
+
+	return period
+}
+
+// GetAnalysisCanaryScaleDownDelay returns the time to wait after the promotion before scaling down the canary
+func (c *Canary) GetAnalysisCanaryScaleDownDelay() time.Duration {
+	if c.GetAnalysis().CanaryScaleDownDelay == "" {
+		return 0
+	}
+
+	delay, err := time.ParseDuration(c.GetAnalysis().CanaryScaleDownDelay)
+	if err != nil || delay < 0 {
+		return 0
+	}
+
+	return delay
+}
+
+// GetAnalysisRetryInterval returns the time to wait after a rollback before retrying the analysis (default 5m)
+func (c *Canary) GetAnalysisRetryInterval() time.Duration {
+	if c.GetAnalysis().RetryInterval == "" {
+		return AnalysisRetryInterval
+	}
+
+	interval, err := time.ParseDuration(c.GetAnalysis().RetryInterval)
+	if err != nil || interval < 0 {
+		return AnalysisRetryInterval
+	}
+
+	return interval
+}
+
+// GetAnalysisMaxDuration returns the maximum duration of the analysis or zero if it's not set
+func (c *Canary) GetAnalysisMaxDuration() time.Duration {
+	if c.GetAnalysis().MaxDuration == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(c.GetAnalysis().MaxDuration)
+	if err != nil || duration < 0 {
+		return 0
+	}
+
+	return duration
+}
+
+// GetStepWeightDuration returns the hold duration of the step matching the canary weight
+// or zero if the weight is not one of the step weights or if its duration is not set
+func (c *Canary) GetStepWeightDuration(weight int) time.Duration {
+	analysis := c.GetAnalysis()
+	for i, w := range analysis.StepWeights {
+		if w != weight || i >= len(analysis.StepWeightDurations) {
+			continue
+		}
+		duration, err := time.ParseDuration(analysis.StepWeightDurations[i])
+		if err != nil || duration < 0 {
+			return 0
+		}
+		return duration
+	}
+	return 0
+}
+
 // GetMetricInterval returns the metric interval default value (1m)
 func (c *Canary) GetMetricInterval() string {
 	return MetricInterval
 }
 
+// ValidateIPFamilies checks that the IP families of the generated services
+// are supported by the Kubernetes API and match the IP family policy
+func (s *CanaryService) ValidateIPFamilies() error {
+	families := s.IPFamilies
+	if len(families) > 2 {
+		return fmt.Errorf("ipFamilies can contain at most two entries")
+	}
+	for i, family := range families {
+		if family != corev1.IPv4Protocol && family != corev1.IPv6Protocol {
+			return fmt.Errorf("ipFamilies %s is not supported, must be %s or %s", family, corev1.IPv4Protocol, corev1.IPv6Protocol)
+		}
+		if i > 0 && families[0] == family {
+			return fmt.Errorf("ipFamilies %s is duplicated", family)
+		}
+	}
+
+	if policy := s.IPFamilyPolicy; policy != nil {
+		switch *policy {
+		case corev1.IPFamilyPolicySingleStack:
+			if len(families) > 1 {
+				return fmt.Errorf("ipFamilyPolicy %s allows a single IP family", *policy)
+			}
+		case corev1.IPFamilyPolicyPreferDualStack, corev1.IPFamilyPolicyRequireDualStack:
+		default:
+			return fmt.Errorf("ipFamilyPolicy %s is not supported", *policy)
+		}
+	} else if len(families) > 1 {
+		return fmt.Errorf("ipFamilyPolicy must be set to %s or %s for two IP families",
+			corev1.IPFamilyPolicyPreferDualStack, corev1.IPFamilyPolicyRequireDualStack)
+	}
+
+	return nil
+}
+
+// ValidatePorts checks that the names and numbers of the generated services ports are unique
+func (s *CanaryService) ValidatePorts() error {
+	names := map[string]bool{s.PortName: true}
+	if s.PortName == "" {
+		names["http"] = true
+	}
+	numbers := map[int32]bool{s.Port: true}
+	for _, p := range s.AdditionalPorts {
+		if p.Name == "" || p.Port == 0 {
+			return fmt.Errorf("additionalPorts entries must have a name and a port")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("additionalPorts name %s is duplicated", p.Name)
+		}
+		if numbers[p.Port] {
+			return fmt.Errorf("additionalPorts port %d is duplicated", p.Port)
+		}
+		names[p.Name], numbers[p.Port] = true, true
+	}
+	return nil
+}
+
+// GetTag returns the Istio telemetry tag default value (flagger_role)
+func (t *IstioTelemetry) GetTag() string {
+	if t.Tag == "" {
+		return IstioTelemetryTag
+	}
+	return t.Tag
+}
+
+// IsProgressiveAfterMatch returns true if the A/B testing iterations have passed
+// and the traffic of all users is shifted progressively to the canary
+func (c *Canary) IsProgressiveAfterMatch() bool {
+	analysis := c.GetAnalysis()
+	return analysis.ProgressiveAfterMatch && analysis.Iterations > 0 && c.Status.Iterations > analysis.Iterations
+}
+
+// GetSessionAffinity returns the session affinity settings or nil if the cookie name is not set
+func (c *Canary) GetSessionAffinity() *SessionAffinity {
+	analysis := c.GetAnalysis()
+	if analysis == nil || analysis.SessionAffinity == nil || analysis.SessionAffinity.CookieName == "" {
+		return nil
+	}
+	return analysis.SessionAffinity
+}
+
+// GetCookieValue returns the session affinity cookie value, the value
+// changes with each revision so that the users are not pinned to a later canary
+func (c *Canary) GetCookieValue() string {
+	if c.Status.LastAppliedSpec != "" {
+		return c.Status.LastAppliedSpec
+	}
+	return c.Spec.TargetRef.Name
+}
+
+// GetMaxAge returns the session affinity cookie lifetime in seconds (default 86400)
+func (s *SessionAffinity) GetMaxAge() int {
+	if s.MaxAge > 0 {
+		return s.MaxAge
+	}
+	return SessionAffinityMaxAge
+}
+
+// IsMirrorIteration returns true if the traffic is mirrored to the canary after the given number
+// of Blue/Green iterations, either for the whole analysis or for the warm-up before the cutover
+func (c *Canary) IsMirrorIteration(iterations int) bool {
+	analysis := c.GetAnalysis()
+	if analysis.Mirror {
+		return true
+	}
+	return analysis.MirrorWarmupIterations > 0 && iterations >= analysis.Iterations-analysis.MirrorWarmupIterations
+}
+
+// GetFastPathFields returns the pod template fields whose changes take the fast path
+func (c *Canary) GetFastPathFields() []FastPathField {
+	if c.Spec.FastPath == nil {
+		return nil
+	}
+	if len(c.Spec.FastPath.Fields) == 0 {
+		return []FastPathField{ResourcesFastPathField}
+	}
+	return c.Spec.FastPath.Fields
+}
+
+// GetFastPathPolicy returns the fast path policy, defaults to Promote
+func (c *Canary) GetFastPathPolicy() FastPathPolicy {
+	if c.Spec.FastPath == nil || c.Spec.FastPath.Policy == "" {
+		return PromoteFastPathPolicy
+	}
+	return c.Spec.FastPath.Policy
+}
+
+// IsFastPathRevision returns true if the revision being analysed only changes
+// the fast path fields of the last promoted revision
+func (c *Canary) IsFastPathRevision() bool {
+	return c.Spec.FastPath != nil && c.Status.FastPath &&
+		(c.Status.Phase == CanaryPhaseProgressing || c.Status.Phase == CanaryPhaseWaitingPromotion)
+}
+
 // SkipAnalysis returns true if the analysis is nil
 // or if spec.SkipAnalysis is true
 func (c *Canary) SkipAnalysis() bool {
@@ -498,3 +1790,209 @@ func (c *Canary) SkipAnalysis() bool {
 	}
 	return c.Spec.SkipAnalysis
 }
+
+// MatchSkipAnalysisRules returns the first skip analysis rule matched by the pod template
+func (c *Canary) MatchSkipAnalysisRules(template corev1.PodTemplateSpec) (*SkipAnalysisRule, error) {
+	for i := range c.Spec.SkipAnalysisRules {
+		rule := &c.Spec.SkipAnalysisRules[i]
+		ok, err := rule.Matches(template)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
+// Matches returns true if the pod template matches all the conditions of the rule
+func (r SkipAnalysisRule) Matches(template corev1.PodTemplateSpec) (bool, error) {
+	if r.ImageTag == "" && r.Annotation == "" {
+		return false, fmt.Errorf("skip analysis rule must set an image tag or an annotation")
+	}
+
+	if r.ImageTag != "" {
+		re, err := regexp.Compile(r.ImageTag)
+		if err != nil {
+			return false, fmt.Errorf("skip analysis rule image tag %s is not valid: %w", r.ImageTag, err)
+		}
+		matched := false
+		for _, container := range template.Spec.Containers {
+			if re.MatchString(imageTag(container.Image)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if r.Annotation != "" {
+		value, ok := template.Annotations[r.Annotation]
+		if !ok {
+			return false, nil
+		}
+		if r.AnnotationValue != "" {
+			re, err := regexp.Compile(r.AnnotationValue)
+			if err != nil {
+				return false, fmt.Errorf("skip analysis rule annotation value %s is not valid: %w", r.AnnotationValue, err)
+			}
+			if !re.MatchString(value) {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// String describes the conditions of the rule
+func (r SkipAnalysisRule) String() string {
+	var conditions []string
+	if r.ImageTag != "" {
+		conditions = append(conditions, fmt.Sprintf("image tag %s", r.ImageTag))
+	}
+	if r.Annotation != "" {
+		if r.AnnotationValue != "" {
+			conditions = append(conditions, fmt.Sprintf("annotation %s=%s", r.Annotation, r.AnnotationValue))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("annotation %s", r.Annotation))
+		}
+	}
+	return strings.Join(conditions, " and ")
+}
+
+// imageTag returns the tag of the container image, defaults to latest
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
+// AuditLabels returns the labels that attribute the objects generated by Flagger to this canary
+// and to the analysis run that last wrote them
+func (c *Canary) AuditLabels() map[string]string {
+	labels := map[string]string{
+		CanaryLabel: c.AuditLabelValue(),
+	}
+	if c.Status.RunID != "" {
+		labels[CanaryRunIDLabel] = c.Status.RunID
+	}
+	return labels
+}
+
+// AuditLabelValue returns the value of the canary label, the names that don't fit in a label value
+// are truncated and suffixed with a hash of the full name
+func (c *Canary) AuditLabelValue() string {
+	if len(c.Name) <= maxLabelValueLength {
+		return c.Name
+	}
+	hash := shortHash(c.Name)
+	return c.Name[:maxLabelValueLength-len(hash)-1] + "-" + hash
+}
+
+// shortHash returns the first 8 hex characters of the SHA-256 hash of the value
+func shortHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// FieldManager returns the field manager name used for the writes performed on behalf of this canary,
+// format: flagger/<name>.<namespace>
+// The run ID is left out of the field manager, a distinct manager per run would pile up
+// entries in the managed fields of the objects, the run is recorded by the run-id label instead.
+func (c *Canary) FieldManager() string {
+	manager := fmt.Sprintf("flagger/%s.%s", c.Name, c.Namespace)
+	if len(manager) > maxFieldManagerLength {
+		manager = manager[:maxFieldManagerLength]
+	}
+	return manager
+}
+
+// InTrafficWindow returns true if the traffic windows are not set or the time is inside one of the windows
+func (c *Canary) InTrafficWindow(t time.Time) (bool, error) {
+	return inTimeWindows(c.GetAnalysis().TrafficWindows, t)
+}
+
+// InProgressionWindow returns true if the progression windows are not set or the time is inside one of the windows
+func (c *Canary) InProgressionWindow(t time.Time) (bool, error) {
+	return inTimeWindows(c.GetAnalysis().ProgressionWindows, t)
+}
+
+// inTimeWindows returns true if the windows are not set or the time is inside one of the windows
+func inTimeWindows(windows []TimeWindow, t time.Time) (bool, error) {
+	if len(windows) == 0 {
+		return true, nil
+	}
+
+	for _, w := range windows {
+		ok, err := w.Contains(t)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Contains returns true if the time is inside the window
+func (w TimeWindow) Contains(t time.Time) (bool, error) {
+	loc := time.UTC
+	if w.TimeZone != "" {
+		l, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return false, fmt.Errorf("time window time zone %s is not valid: %w", w.TimeZone, err)
+		}
+		loc = l
+	}
+
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false, fmt.Errorf("time window start %s is not valid, expected HH:MM", w.Start)
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false, fmt.Errorf("time window end %s is not valid, expected HH:MM", w.End)
+	}
+
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	switch {
+	case from == to:
+		// the window lasts the whole day
+		return w.hasDay(t.Weekday()), nil
+	case from < to:
+		return now >= from && now < to && w.hasDay(t.Weekday()), nil
+	case now >= from:
+		return w.hasDay(t.Weekday()), nil
+	case now < to:
+		// the window started the day before
+		return w.hasDay(t.AddDate(0, 0, -1).Weekday()), nil
+	default:
+		return false, nil
+	}
+}
+
+// hasDay returns true if the window days are not set or contain the week day
+func (w TimeWindow) hasDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if len(d) >= 3 && strings.EqualFold(d[:3], day.String()[:3]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	CanaryRunKind = "CanaryRun"
+	// CanaryRunLabel is set on the canary runs to the name of their canary
+	CanaryRunLabel = "flagger.app/canary"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CanaryRun is the audit trail of an analysis run of a canary
+type CanaryRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CanaryRunSpec   `json:"spec"`
+	Status CanaryRunStatus `json:"status"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CanaryRunList is a list of canary run resources
+type CanaryRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []CanaryRun `json:"items"`
+}
+
+// CanaryRunSpec identifies the canary revision of the analysis run
+type CanaryRunSpec struct {
+	// CanaryName is the name of the analysed canary
+	CanaryName string `json:"canaryName"`
+
+	// TargetRef references the target of the canary
+	TargetRef LocalObjectReference `json:"targetRef"`
+
+	// RunID is the ID of the analysis run in the canary status
+	RunID string `json:"runID"`
+
+	// Revision is the checksum of the analysed revision
+	// +optional
+	Revision string `json:"revision,omitempty"`
+}
+
+// CanaryRunStatus holds the decisions made during the analysis run
+type CanaryRunStatus struct {
+	// Phase is the last phase of the canary during the run
+	// +optional
+	Phase CanaryPhase `json:"phase,omitempty"`
+
+	// StartTime is the start time of the analysis run
+	// +optional
+	StartTime metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is set when the revision is promoted or rolled back
+	// +optional
+	CompletionTime metav1.Time `json:"completionTime,omitempty"`
+
+	// Records of the weight changes, metric checks, webhook calls and gate decisions, oldest first
+	// +optional
+	Records []CanaryRunRecord `json:"records,omitempty"`
+
+	// DroppedRecords is the number of oldest records removed to stay under the records limit
+	// +optional
+	DroppedRecords int `json:"droppedRecords,omitempty"`
+}
+
+// CanaryRunRecordType is the type of a decision recorded in the audit trail
+type CanaryRunRecordType string
+
+const (
+	// PhaseRecord is a change of the canary phase
+	PhaseRecord CanaryRunRecordType = "Phase"
+	// WeightRecord is a change of the canary weight
+	WeightRecord CanaryRunRecordType = "Weight"
+	// MetricRecord is a metric evaluation
+	MetricRecord CanaryRunRecordType = "Metric"
+	// WebhookRecord is a webhook call, the confirm hooks act as gates
+	WebhookRecord CanaryRunRecordType = "Webhook"
+	// GateRecord is a manual approval, rollback or gate annotation
+	GateRecord CanaryRunRecordType = "Gate"
+)
+
+// CanaryRunRecord is a decision made during the analysis run
+type CanaryRunRecord struct {
+	// Time of the decision
+	Time metav1.Time `json:"time"`
+
+	// Type of the decision
+	Type CanaryRunRecordType `json:"type"`
+
+	// Name of the metric, webhook or annotation
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Result of the metric check, webhook call or gate
+	// +optional
+	Result CanaryCheckResult `json:"result,omitempty"`
+
+	// Value of the metric
+	// +optional
+	Value *float64 `json:"value,omitempty"`
+
+	// Threshold is the range of the accepted metric values
+	// +optional
+	Threshold string `json:"threshold,omitempty"`
+
+	// CanaryWeight at the time of the decision
+	// +optional
+	CanaryWeight int `json:"canaryWeight,omitempty"`
+
+	// Phase of the canary after the decision
+	// +optional
+	Phase CanaryPhase `json:"phase,omitempty"`
+
+	// Actor is the field manager that set the annotation of a manual decision
+	// +optional
+	Actor string `json:"actor,omitempty"`
+
+	// Message describes the decision
+	// +optional
+	Message string `json:"message,omitempty"`
+}
@@ -25,6 +25,10 @@ import (
 
 const (
 	MetricTemplateKind = "MetricTemplate"
+
+	// MetricTemplateReadyCondition is set by Flagger after validating
+	// the provider and the queries of the metric template
+	MetricTemplateReadyCondition = "Ready"
 )
 
 // +genclient
@@ -56,6 +60,28 @@ type MetricTemplateSpec struct {
 
 	// Query template for this metric
 	Query string `json:"query,omitempty"`
+
+	// Queries are named query templates whose results are combined by the expression
+	// +optional
+	Queries []MetricTemplateQuery `json:"queries,omitempty"`
+
+	// Expression combines the results of the named queries e.g. canary_errors / primary_errors
+	// +optional
+	Expression string `json:"expression,omitempty"`
+}
+
+// MetricTemplateQuery is a named query template
+type MetricTemplateQuery struct {
+	// Name of the query used as a variable in the expression
+	Name string `json:"name"`
+
+	// Query template
+	Query string `json:"query"`
+
+	// Provider of this query
+	// Defaults to the metric template provider
+	// +optional
+	Provider *MetricTemplateProvider `json:"provider,omitempty"`
 }
 
 // MetricProvider is the spec for a MetricProvider resource
@@ -75,9 +101,105 @@ type MetricTemplateProvider struct {
 	// +optional
 	Region string `json:"region,omitempty"`
 
+	// ScrapeInterval is the scrape or rollup interval of the provider e.g. 1m,
+	// the metric intervals are aligned to it, overrides the global scrape interval
+	// +optional
+	ScrapeInterval string `json:"scrapeInterval,omitempty"`
+
 	// InsecureSkipVerify disables certificate verification for the provider
 	// +optional
 	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+
+	// Headers added to the HTTP requests sent to the provider
+	// +optional
+	Headers []MetricTemplateProviderParam `json:"headers,omitempty"`
+
+	// QueryParams added to the HTTP requests sent to the provider
+	// +optional
+	QueryParams []MetricTemplateProviderParam `json:"queryParams,omitempty"`
+
+	// Thanos query options of the Prometheus provider
+	// +optional
+	Thanos *ThanosOptions `json:"thanos,omitempty"`
+
+	// InfluxDB query options of the InfluxDB provider
+	// +optional
+	InfluxDB *InfluxDBOptions `json:"influxdb,omitempty"`
+
+	// Stackdriver query options of the Cloud Monitoring provider
+	// +optional
+	Stackdriver *StackdriverOptions `json:"stackdriver,omitempty"`
+
+	// OAuth2 client credentials flow used to authenticate the requests,
+	// the client ID and secret are read from the provider secret
+	// +optional
+	OAuth2 *OAuth2Options `json:"oauth2,omitempty"`
+}
+
+// OAuth2Options holds the token endpoint and scopes of the OAuth2 client credentials flow
+type OAuth2Options struct {
+	// TokenURL of the authorization server
+	TokenURL string `json:"tokenURL"`
+
+	// Scopes requested for the access token
+	// +optional
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// InfluxDBOptions holds the InfluxDB organization, bucket and query language
+type InfluxDBOptions struct {
+	// QueryLanguage of the metric template queries: flux (v2), sql or influxql (v3)
+	// +optional
+	QueryLanguage string `json:"queryLanguage,omitempty"`
+
+	// Org used by the Flux queries, overrides the org from the provider secret
+	// +optional
+	Org string `json:"org,omitempty"`
+
+	// Bucket used by the Flux health check (default "default")
+	// +optional
+	Bucket string `json:"bucket,omitempty"`
+
+	// Database used by the SQL and InfluxQL queries
+	// +optional
+	Database string `json:"database,omitempty"`
+}
+
+// StackdriverOptions holds the Cloud Monitoring query language
+type StackdriverOptions struct {
+	// QueryLanguage of the metric template queries: mql (default), promql or filter
+	// +optional
+	QueryLanguage string `json:"queryLanguage,omitempty"`
+}
+
+// ThanosOptions holds the Thanos Query specific params
+type ThanosOptions struct {
+	// PartialResponse allows the query to succeed when some store APIs are unavailable (default false)
+	// +optional
+	PartialResponse *bool `json:"partialResponse,omitempty"`
+
+	// Dedup enables the deduplication of the replicated series (default true)
+	// +optional
+	Dedup *bool `json:"dedup,omitempty"`
+
+	// MaxSourceResolution is the max downsampling resolution of the queried data e.g. raw, 5m, 1h or auto
+	// +optional
+	MaxSourceResolution string `json:"maxSourceResolution,omitempty"`
+}
+
+// MetricTemplateProviderParam is an HTTP header or query parameter sent to the provider,
+// the value can be set inline or read from the provider secret
+type MetricTemplateProviderParam struct {
+	// Name of the header or query parameter
+	Name string `json:"name"`
+
+	// Value of the header or query parameter
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// SecretKey is the key of the provider secret holding the value
+	// +optional
+	SecretKey string `json:"secretKey,omitempty"`
 }
 
 // MetricTemplateModel is the query template model
@@ -88,6 +210,16 @@ type MetricTemplateModel struct {
 	Service   string `json:"service"`
 	Ingress   string `json:"ingress"`
 	Interval  string `json:"interval"`
+	// RunID is the unique identifier of the analysis run
+	RunID string `json:"runID,omitempty"`
+	// RunStart is the Unix time in seconds of the analysis run start
+	RunStart string `json:"runStart,omitempty"`
+	// RunDuration is the time elapsed since the analysis run start e.g. 300s
+	RunDuration string `json:"runDuration,omitempty"`
+	// Percentile is the latency quantile e.g. 0.95, defaults to 0.99
+	Percentile string `json:"percentile,omitempty"`
+	// Ports is the regex matching the ports of the generated services e.g. 8080|9090
+	Ports string `json:"ports,omitempty"`
 }
 
 // TemplateFunctions returns a map of functions, one for each model field
@@ -99,10 +231,26 @@ func (mtm *MetricTemplateModel) TemplateFunctions() template.FuncMap {
 		"service":   func() string { return mtm.Service },
 		"ingress":   func() string { return mtm.Ingress },
 		"interval":  func() string { return mtm.Interval },
+
+		"run_id":       func() string { return mtm.RunID },
+		"run_start":    func() string { return mtm.RunStart },
+		"run_duration": func() string { return mtm.RunDuration },
+		"ports":        func() string { return mtm.Ports },
+
+		"percentile": func() string {
+			if mtm.Percentile == "" {
+				return "0.99"
+			}
+			return mtm.Percentile
+		},
 	}
 }
 
 type MetricTemplateStatus struct {
+	// ObservedGeneration is the generation of the metric template last validated
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// Conditions of this status
 	Conditions []MetricTemplateCondition `json:"conditions,omitempty"`
 }
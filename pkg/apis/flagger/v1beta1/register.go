@@ -47,10 +47,16 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Canary{},
 		&CanaryList{},
+		&CanaryRun{},
+		&CanaryRunList{},
 		&MetricTemplate{},
 		&MetricTemplateList{},
 		&AlertProvider{},
 		&AlertProviderList{},
+		&ReleaseGroup{},
+		&ReleaseGroupList{},
+		&AnalysisTemplate{},
+		&AnalysisTemplateList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
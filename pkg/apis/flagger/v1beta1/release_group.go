@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ReleaseGroupKind = "ReleaseGroup"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ReleaseGroup is a set of canaries that are promoted together,
+// a canary is promoted only after all the canaries in the group passed the analysis
+// and it's rolled back when any canary in the group fails
+type ReleaseGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ReleaseGroupSpec `json:"spec"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ReleaseGroupList is a list of release group resources
+type ReleaseGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ReleaseGroup `json:"items"`
+}
+
+// ReleaseGroupSpec is the specification of the desired behavior of the ReleaseGroup
+type ReleaseGroupSpec struct {
+	// Canaries in the namespace of the release group that are promoted together
+	Canaries []corev1.LocalObjectReference `json:"canaries"`
+
+	// Lockstep holds the traffic weight of a canary until the other canaries
+	// under analysis in the group have reached the same weight
+	// +optional
+	Lockstep bool `json:"lockstep,omitempty"`
+}
+
+// HasCanary returns true if the canary is a member of the release group
+func (rg *ReleaseGroup) HasCanary(name string) bool {
+	for _, ref := range rg.Spec.Canaries {
+		if ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,18 @@ type CanaryConditionType string
 const (
 	// PromotedType refers to the result of the last canary analysis
 	PromotedType CanaryConditionType = "Promoted"
+	// RouterReadyType refers to the availability of the mesh or ingress custom resources
+	RouterReadyType CanaryConditionType = "RouterReady"
+	// SuspendedType refers to the pausing of the analysis with spec.suspend
+	SuspendedType CanaryConditionType = "Suspended"
+	// ProgressingType is true while a revision is initialized, analysed or promoted
+	ProgressingType CanaryConditionType = "Progressing"
+	// HealthyType is false when the analysis of the last revision failed
+	HealthyType CanaryConditionType = "Healthy"
+	// RolledBackType is true when the last revision has been rolled back
+	RolledBackType CanaryConditionType = "RolledBack"
+	// StalledType is true when the canary can't progress until a new revision is applied
+	StalledType CanaryConditionType = "Stalled"
 )
 
 // CanaryCondition is a status condition for a Canary
@@ -32,6 +44,10 @@ type CanaryCondition struct {
 
 	// Message associated with this condition
 	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the canary spec the condition was set for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 // CanaryPhase is a label for the condition of a canary at the current time
@@ -59,6 +75,9 @@ const (
 	// CanaryPhaseFailed means the canary analysis failed
 	// and the canary deployment has been scaled to zero
 	CanaryPhaseFailed CanaryPhase = "Failed"
+	// CanaryPhaseDryRunSucceeded means the dry-run analysis has been successful,
+	// the canary deployment has been scaled to zero without being promoted
+	CanaryPhaseDryRunSucceeded CanaryPhase = "DryRunSucceeded"
 	// CanaryPhaseTerminating means the canary has been marked
 	// for deletion and in the finalizing state
 	CanaryPhaseTerminating CanaryPhase = "Terminating"
@@ -79,8 +98,187 @@ type CanaryStatus struct {
 	LastAppliedSpec string `json:"lastAppliedSpec,omitempty"`
 	// +optional
 	LastPromotedSpec string `json:"lastPromotedSpec,omitempty"`
+	// LastPromotedImages maps the containers of the last promoted revision to their image
+	// +optional
+	LastPromotedImages map[string]string `json:"lastPromotedImages,omitempty"`
+	// ChangedImages maps the tracked containers whose image changed in the current revision to their new image
+	// +optional
+	ChangedImages map[string]string `json:"changedImages,omitempty"`
+	// LastAppliedFastPathSpec is the hash of the last applied pod template without the fast path fields
+	// +optional
+	LastAppliedFastPathSpec string `json:"lastAppliedFastPathSpec,omitempty"`
+	// LastPromotedFastPathSpec is the hash of the last promoted pod template without the fast path fields
+	// +optional
+	LastPromotedFastPathSpec string `json:"lastPromotedFastPathSpec,omitempty"`
+	// FastPath is true when the current revision only changes the fast path fields of the last promoted revision
+	// +optional
+	FastPath bool `json:"fastPath,omitempty"`
 	// +optional
 	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// ObservedGeneration is the generation of the canary spec of the last status update
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// RunID uniquely identifies the current or last analysis run
+	// +optional
+	RunID string `json:"runID,omitempty"`
+	// RunStartTime is the start time of the current or last analysis run
+	// +optional
+	RunStartTime metav1.Time `json:"runStartTime,omitempty"`
+	// AdaptiveStepWeight is the step weight set by the adaptive progression
+	// +optional
+	AdaptiveStepWeight int `json:"adaptiveStepWeight,omitempty"`
+	// StepStartTime is the time when the canary weight was last changed
+	// +optional
+	StepStartTime metav1.Time `json:"stepStartTime,omitempty"`
+	// RetryAttempts is the number of times the analysis of the current revision was retried
+	// +optional
+	RetryAttempts int `json:"retryAttempts,omitempty"`
+	// NextRetryTime is the time after which the analysis of a failed canary is retried
+	// +optional
+	NextRetryTime metav1.Time `json:"nextRetryTime,omitempty"`
+	// NextAnalysisTime is the time of the next analysis run,
+	// set by the leader before handing over the canary to another instance
+	// +optional
+	NextAnalysisTime metav1.Time `json:"nextAnalysisTime,omitempty"`
+	// RollbackDrain is set while a rollback waits for the canary traffic to drain
+	// before scaling down the canary
+	// +optional
+	RollbackDrain *CanaryRollbackDrain `json:"rollbackDrain,omitempty"`
 	// +optional
 	Conditions []CanaryCondition `json:"conditions,omitempty"`
+	// +optional
+	Finalization []CanaryFinalizationStatus `json:"finalization,omitempty"`
+	// +optional
+	Metrics []CanaryMetricStatus `json:"metrics,omitempty"`
+	// Failures holds the last failure of each check during the current analysis
+	// +optional
+	Failures []CanaryCheckFailure `json:"failures,omitempty"`
+	// Webhooks holds the last result of each webhook
+	// +optional
+	Webhooks []CanaryWebhookStatus `json:"webhooks,omitempty"`
+}
+
+// CanaryRollbackDrain records a rollback that waits for the rollback drain period
+type CanaryRollbackDrain struct {
+	// Reason of the rollback
+	Reason RollbackReason `json:"reason"`
+
+	// StartTime is the time when the router reported that no traffic is routed to the canary
+	StartTime metav1.Time `json:"startTime"`
+}
+
+// CanaryMetricStatus reports the query retries and failed checks of a metric during the current analysis
+type CanaryMetricStatus struct {
+	// Name of the metric
+	Name string `json:"name"`
+
+	// Retries is the number of queries retried after a transient provider error
+	Retries int `json:"retries"`
+
+	// Warning is set when the last value of the metric was outside the warning range
+	// +optional
+	Warning string `json:"warning,omitempty"`
+
+	// FailedChecks is the number of failed checks of a metric with its own failure threshold
+	// +optional
+	FailedChecks int `json:"failedChecks,omitempty"`
+
+	// Value is the last value of the metric, the request durations are in milliseconds,
+	// it is not set when the last query failed
+	// +optional
+	Value *float64 `json:"value,omitempty"`
+
+	// Threshold is the range of the accepted values of the last check e.g. >= 99
+	// +optional
+	Threshold string `json:"threshold,omitempty"`
+
+	// Result of the last check, can be Passed or Failed
+	// +optional
+	Result CanaryCheckResult `json:"result,omitempty"`
+
+	// LastCheckTime is the time of the last check
+	// +optional
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+// CanaryCheckResult is the result of a metric check or a webhook call
+type CanaryCheckResult string
+
+const (
+	// CheckPassed means the metric value was in the threshold range or the webhook succeeded
+	CheckPassed CanaryCheckResult = "Passed"
+	// CheckFailed means the metric value crossed the threshold, the query or the webhook failed
+	CheckFailed CanaryCheckResult = "Failed"
+)
+
+// CanaryWebhookStatus reports the last result of a webhook
+type CanaryWebhookStatus struct {
+	// Name of the webhook
+	Name string `json:"name"`
+
+	// Type of the webhook
+	Type HookType `json:"type"`
+
+	// Result of the last call, can be Passed or Failed
+	Result CanaryCheckResult `json:"result"`
+
+	// Message holds the error of the last call
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastCheckTime is the time of the last call
+	// +optional
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+// CanaryCheckKind is the kind of an analysis check
+type CanaryCheckKind string
+
+const (
+	// MetricCheckKind is a metric check of the analysis
+	MetricCheckKind CanaryCheckKind = "Metric"
+	// WebhookCheckKind is a rollout webhook of the analysis
+	WebhookCheckKind CanaryCheckKind = "Webhook"
+)
+
+// CanaryCheckFailure reports the last failure of a metric or webhook during the current analysis
+type CanaryCheckFailure struct {
+	// Kind of the check, can be Metric or Webhook
+	Kind CanaryCheckKind `json:"kind"`
+
+	// Name of the metric or webhook
+	Name string `json:"name"`
+
+	// Value is the last value of the metric, it is not set when the query failed
+	// +optional
+	Value *float64 `json:"value,omitempty"`
+
+	// Message holds the threshold that was crossed or the webhook error
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// CanaryWeight is the traffic weight routed to the canary when the check failed
+	CanaryWeight int `json:"canaryWeight"`
+
+	// LastTransitionTime of the failure
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// CanaryFinalizationStatus reports the revert progress of a resource
+// when a canary with revertOnDeletion is deleted
+type CanaryFinalizationStatus struct {
+	// Resource is the kind and name of the reverted resource e.g. Deployment/podinfo
+	Resource string `json:"resource"`
+
+	// Finalized is true when the resource has been reverted
+	Finalized bool `json:"finalized"`
+
+	// Message holds the revert error
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime of the resource finalization
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
 }
@@ -28,6 +28,22 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdaptiveProgression) DeepCopyInto(out *AdaptiveProgression) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdaptiveProgression.
+func (in *AdaptiveProgression) DeepCopy() *AdaptiveProgression {
+	if in == nil {
+		return nil
+	}
+	out := new(AdaptiveProgression)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AlertProvider) DeepCopyInto(out *AlertProvider) {
 	*out = *in
@@ -151,6 +167,104 @@ func (in *AlertProviderStatus) DeepCopy() *AlertProviderStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnalysisTemplate) DeepCopyInto(out *AnalysisTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnalysisTemplate.
+func (in *AnalysisTemplate) DeepCopy() *AnalysisTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(AnalysisTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AnalysisTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnalysisTemplateList) DeepCopyInto(out *AnalysisTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AnalysisTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnalysisTemplateList.
+func (in *AnalysisTemplateList) DeepCopy() *AnalysisTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(AnalysisTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AnalysisTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnalysisTemplateSpec) DeepCopyInto(out *AnalysisTemplateSpec) {
+	*out = *in
+	in.Analysis.DeepCopyInto(&out.Analysis)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnalysisTemplateSpec.
+func (in *AnalysisTemplateSpec) DeepCopy() *AnalysisTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AnalysisTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalerReference) DeepCopyInto(out *AutoscalerReference) {
+	*out = *in
+	if in.PrimaryScalerReplicas != nil {
+		in, out := &in.PrimaryScalerReplicas, &out.PrimaryScalerReplicas
+		*out = new(ScalerReplicas)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalerReference.
+func (in *AutoscalerReference) DeepCopy() *AutoscalerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Canary) DeepCopyInto(out *Canary) {
 	*out = *in
@@ -204,6 +318,16 @@ func (in *CanaryAnalysis) DeepCopyInto(out *CanaryAnalysis) {
 		*out = make([]int, len(*in))
 		copy(*out, *in)
 	}
+	if in.StepWeightDurations != nil {
+		in, out := &in.StepWeightDurations, &out.StepWeightDurations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Adaptive != nil {
+		in, out := &in.Adaptive, &out.Adaptive
+		*out = new(AdaptiveProgression)
+		**out = **in
+	}
 	if in.PrimaryReadyThreshold != nil {
 		in, out := &in.PrimaryReadyThreshold, &out.PrimaryReadyThreshold
 		*out = new(int)
@@ -214,6 +338,25 @@ func (in *CanaryAnalysis) DeepCopyInto(out *CanaryAnalysis) {
 		*out = new(int)
 		**out = **in
 	}
+	if in.TrafficWindows != nil {
+		in, out := &in.TrafficWindows, &out.TrafficWindows
+		*out = make([]TimeWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ProgressionWindows != nil {
+		in, out := &in.ProgressionWindows, &out.ProgressionWindows
+		*out = make([]TimeWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]CanaryDependency, len(*in))
+		copy(*out, *in)
+	}
 	if in.Alerts != nil {
 		in, out := &in.Alerts, &out.Alerts
 		*out = make([]CanaryAlert, len(*in))
@@ -226,6 +369,11 @@ func (in *CanaryAnalysis) DeepCopyInto(out *CanaryAnalysis) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Kayenta != nil {
+		in, out := &in.Kayenta, &out.Kayenta
+		*out = new(KayentaAnalysis)
+		**out = **in
+	}
 	if in.Webhooks != nil {
 		in, out := &in.Webhooks, &out.Webhooks
 		*out = make([]CanaryWebhook, len(*in))
@@ -240,6 +388,16 @@ func (in *CanaryAnalysis) DeepCopyInto(out *CanaryAnalysis) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SessionAffinity != nil {
+		in, out := &in.SessionAffinity, &out.SessionAffinity
+		*out = new(SessionAffinity)
+		**out = **in
+	}
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(CrossNamespaceObjectReference)
+		**out = **in
+	}
 	return
 }
 
@@ -253,6 +411,28 @@ func (in *CanaryAnalysis) DeepCopy() *CanaryAnalysis {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryCheckFailure) DeepCopyInto(out *CanaryCheckFailure) {
+	*out = *in
+	if in.Value != nil {
+		in, out := &in.Value, &out.Value
+		*out = new(float64)
+		**out = **in
+	}
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryCheckFailure.
+func (in *CanaryCheckFailure) DeepCopy() *CanaryCheckFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryCheckFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanaryCondition) DeepCopyInto(out *CanaryCondition) {
 	*out = *in
@@ -271,6 +451,39 @@ func (in *CanaryCondition) DeepCopy() *CanaryCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryFinalizationStatus) DeepCopyInto(out *CanaryFinalizationStatus) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryFinalizationStatus.
+func (in *CanaryFinalizationStatus) DeepCopy() *CanaryFinalizationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryFinalizationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryDependency) DeepCopyInto(out *CanaryDependency) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryDependency.
+func (in *CanaryDependency) DeepCopy() *CanaryDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryDependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanaryList) DeepCopyInto(out *CanaryList) {
 	*out = *in
@@ -312,11 +525,31 @@ func (in *CanaryMetric) DeepCopyInto(out *CanaryMetric) {
 		*out = new(CanaryThresholdRange)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.WarningRange != nil {
+		in, out := &in.WarningRange, &out.WarningRange
+		*out = new(CanaryThresholdRange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RelativeThreshold != nil {
+		in, out := &in.RelativeThreshold, &out.RelativeThreshold
+		*out = new(CanaryRelativeThreshold)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.TemplateRef != nil {
 		in, out := &in.TemplateRef, &out.TemplateRef
 		*out = new(CrossNamespaceObjectReference)
 		**out = **in
 	}
+	if in.Comparison != nil {
+		in, out := &in.Comparison, &out.Comparison
+		*out = new(CanaryMetricComparison)
+		**out = **in
+	}
+	if in.BurnRate != nil {
+		in, out := &in.BurnRate, &out.BurnRate
+		*out = new(CanaryMetricBurnRate)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -331,232 +564,1174 @@ func (in *CanaryMetric) DeepCopy() *CanaryMetric {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CanaryService) DeepCopyInto(out *CanaryService) {
+func (in *CanaryMetricBurnRate) DeepCopyInto(out *CanaryMetricBurnRate) {
 	*out = *in
-	out.TargetPort = in.TargetPort
-	if in.Gateways != nil {
-		in, out := &in.Gateways, &out.Gateways
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.GatewayRefs != nil {
-		in, out := &in.GatewayRefs, &out.GatewayRefs
-		*out = make([]v1alpha2.ParentReference, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Hosts != nil {
-		in, out := &in.Hosts, &out.Hosts
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.TrafficPolicy != nil {
-		in, out := &in.TrafficPolicy, &out.TrafficPolicy
-		*out = new(v1alpha3.TrafficPolicy)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Match != nil {
-		in, out := &in.Match, &out.Match
-		*out = make([]v1alpha3.HTTPMatchRequest, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryMetricBurnRate.
+func (in *CanaryMetricBurnRate) DeepCopy() *CanaryMetricBurnRate {
+	if in == nil {
+		return nil
 	}
-	if in.Rewrite != nil {
-		in, out := &in.Rewrite, &out.Rewrite
-		*out = new(v1alpha3.HTTPRewrite)
-		**out = **in
+	out := new(CanaryMetricBurnRate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryMetricComparison) DeepCopyInto(out *CanaryMetricComparison) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryMetricComparison.
+func (in *CanaryMetricComparison) DeepCopy() *CanaryMetricComparison {
+	if in == nil {
+		return nil
 	}
-	if in.Retries != nil {
-		in, out := &in.Retries, &out.Retries
-		*out = new(v1alpha3.HTTPRetry)
+	out := new(CanaryMetricComparison)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryMetricStatus) DeepCopyInto(out *CanaryMetricStatus) {
+	*out = *in
+	if in.Value != nil {
+		in, out := &in.Value, &out.Value
+		*out = new(float64)
 		**out = **in
 	}
-	if in.Headers != nil {
-		in, out := &in.Headers, &out.Headers
-		*out = new(v1alpha3.Headers)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.CorsPolicy != nil {
-		in, out := &in.CorsPolicy, &out.CorsPolicy
-		*out = new(v1alpha3.CorsPolicy)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Backends != nil {
-		in, out := &in.Backends, &out.Backends
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.Apex != nil {
-		in, out := &in.Apex, &out.Apex
-		*out = new(CustomMetadata)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Primary != nil {
-		in, out := &in.Primary, &out.Primary
-		*out = new(CustomMetadata)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Canary != nil {
-		in, out := &in.Canary, &out.Canary
-		*out = new(CustomMetadata)
-		(*in).DeepCopyInto(*out)
-	}
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryService.
-func (in *CanaryService) DeepCopy() *CanaryService {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryMetricStatus.
+func (in *CanaryMetricStatus) DeepCopy() *CanaryMetricStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(CanaryService)
+	out := new(CanaryMetricStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CanarySpec) DeepCopyInto(out *CanarySpec) {
+func (in *CanaryRelativeThreshold) DeepCopyInto(out *CanaryRelativeThreshold) {
 	*out = *in
-	out.TargetRef = in.TargetRef
-	if in.AutoscalerRef != nil {
-		in, out := &in.AutoscalerRef, &out.AutoscalerRef
-		*out = new(LocalObjectReference)
+	if in.MinRatio != nil {
+		in, out := &in.MinRatio, &out.MinRatio
+		*out = new(float64)
 		**out = **in
 	}
-	if in.IngressRef != nil {
-		in, out := &in.IngressRef, &out.IngressRef
-		*out = new(LocalObjectReference)
+	if in.MaxRatio != nil {
+		in, out := &in.MaxRatio, &out.MaxRatio
+		*out = new(float64)
 		**out = **in
 	}
-	if in.UpstreamRef != nil {
-		in, out := &in.UpstreamRef, &out.UpstreamRef
-		*out = new(CrossNamespaceObjectReference)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRelativeThreshold.
+func (in *CanaryRelativeThreshold) DeepCopy() *CanaryRelativeThreshold {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRelativeThreshold)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRollbackDrain) DeepCopyInto(out *CanaryRollbackDrain) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRollbackDrain.
+func (in *CanaryRollbackDrain) DeepCopy() *CanaryRollbackDrain {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRollbackDrain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRollbackPayload) DeepCopyInto(out *CanaryRollbackPayload) {
+	*out = *in
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]CanaryCheckFailure, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRollbackPayload.
+func (in *CanaryRollbackPayload) DeepCopy() *CanaryRollbackPayload {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRollbackPayload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRun) DeepCopyInto(out *CanaryRun) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRun.
+func (in *CanaryRun) DeepCopy() *CanaryRun {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CanaryRun) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRunList) DeepCopyInto(out *CanaryRunList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CanaryRun, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRunList.
+func (in *CanaryRunList) DeepCopy() *CanaryRunList {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRunList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CanaryRunList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRunRecord) DeepCopyInto(out *CanaryRunRecord) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Value != nil {
+		in, out := &in.Value, &out.Value
+		*out = new(float64)
 		**out = **in
 	}
-	in.Service.DeepCopyInto(&out.Service)
-	if in.Analysis != nil {
-		in, out := &in.Analysis, &out.Analysis
-		*out = new(CanaryAnalysis)
-		(*in).DeepCopyInto(*out)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRunRecord.
+func (in *CanaryRunRecord) DeepCopy() *CanaryRunRecord {
+	if in == nil {
+		return nil
 	}
-	if in.CanaryAnalysis != nil {
-		in, out := &in.CanaryAnalysis, &out.CanaryAnalysis
-		*out = new(CanaryAnalysis)
+	out := new(CanaryRunRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRunSpec) DeepCopyInto(out *CanaryRunSpec) {
+	*out = *in
+	out.TargetRef = in.TargetRef
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRunSpec.
+func (in *CanaryRunSpec) DeepCopy() *CanaryRunSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRunSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRunStatus) DeepCopyInto(out *CanaryRunStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.CompletionTime.DeepCopyInto(&out.CompletionTime)
+	if in.Records != nil {
+		in, out := &in.Records, &out.Records
+		*out = make([]CanaryRunRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRunStatus.
+func (in *CanaryRunStatus) DeepCopy() *CanaryRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryService) DeepCopyInto(out *CanaryService) {
+	*out = *in
+	out.TargetPort = in.TargetPort
+	if in.AdditionalPorts != nil {
+		in, out := &in.AdditionalPorts, &out.AdditionalPorts
+		*out = make([]CanaryServicePort, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExistingServices != nil {
+		in, out := &in.ExistingServices, &out.ExistingServices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPFamilies != nil {
+		in, out := &in.IPFamilies, &out.IPFamilies
+		*out = make([]v1.IPFamily, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPFamilyPolicy != nil {
+		in, out := &in.IPFamilyPolicy, &out.IPFamilyPolicy
+		*out = new(v1.IPFamilyPolicyType)
+		**out = **in
+	}
+	if in.Gateways != nil {
+		in, out := &in.Gateways, &out.Gateways
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GatewayRefs != nil {
+		in, out := &in.GatewayRefs, &out.GatewayRefs
+		*out = make([]v1alpha2.ParentReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TrafficPolicy != nil {
+		in, out := &in.TrafficPolicy, &out.TrafficPolicy
+		*out = new(v1alpha3.TrafficPolicy)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.ProgressDeadlineSeconds != nil {
-		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
-		*out = new(int32)
+	if in.Match != nil {
+		in, out := &in.Match, &out.Match
+		*out = make([]v1alpha3.HTTPMatchRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rewrite != nil {
+		in, out := &in.Rewrite, &out.Rewrite
+		*out = new(v1alpha3.HTTPRewrite)
+		**out = **in
+	}
+	if in.Retries != nil {
+		in, out := &in.Retries, &out.Retries
+		*out = new(v1alpha3.HTTPRetry)
+		**out = **in
+	}
+	if in.RetryBudget != nil {
+		in, out := &in.RetryBudget, &out.RetryBudget
+		*out = new(RetryBudget)
 		**out = **in
 	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = new(v1alpha3.Headers)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CorsPolicy != nil {
+		in, out := &in.CorsPolicy, &out.CorsPolicy
+		*out = new(v1alpha3.CorsPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Telemetry != nil {
+		in, out := &in.Telemetry, &out.Telemetry
+		*out = new(IstioTelemetry)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Apex != nil {
+		in, out := &in.Apex, &out.Apex
+		*out = new(CustomMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Primary != nil {
+		in, out := &in.Primary, &out.Primary
+		*out = new(CustomMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CustomMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryService.
+func (in *CanaryService) DeepCopy() *CanaryService {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryServicePort) DeepCopyInto(out *CanaryServicePort) {
+	*out = *in
+	out.TargetPort = in.TargetPort
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryServicePort.
+func (in *CanaryServicePort) DeepCopy() *CanaryServicePort {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryServicePort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanarySpec) DeepCopyInto(out *CanarySpec) {
+	*out = *in
+	if in.KubeConfig != nil {
+		in, out := &in.KubeConfig, &out.KubeConfig
+		*out = new(KubeConfigReference)
+		**out = **in
+	}
+	out.TargetRef = in.TargetRef
+	if in.TargetWorkload != nil {
+		in, out := &in.TargetWorkload, &out.TargetWorkload
+		*out = new(TargetWorkload)
+		**out = **in
+	}
+	if in.TrackedObjects != nil {
+		in, out := &in.TrackedObjects, &out.TrackedObjects
+		*out = make([]TrackedObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigRewrites != nil {
+		in, out := &in.ConfigRewrites, &out.ConfigRewrites
+		*out = make([]ConfigRewriteRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AutoscalerRef != nil {
+		in, out := &in.AutoscalerRef, &out.AutoscalerRef
+		*out = new(AutoscalerReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IngressRef != nil {
+		in, out := &in.IngressRef, &out.IngressRef
+		*out = new(LocalObjectReference)
+		**out = **in
+	}
+	if in.UpstreamRef != nil {
+		in, out := &in.UpstreamRef, &out.UpstreamRef
+		*out = new(CrossNamespaceObjectReference)
+		**out = **in
+	}
+	in.Service.DeepCopyInto(&out.Service)
+	if in.Analysis != nil {
+		in, out := &in.Analysis, &out.Analysis
+		*out = new(CanaryAnalysis)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CanaryAnalysis != nil {
+		in, out := &in.CanaryAnalysis, &out.CanaryAnalysis
+		*out = new(CanaryAnalysis)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProgressDeadlineSeconds != nil {
+		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SkipAnalysisRules != nil {
+		in, out := &in.SkipAnalysisRules, &out.SkipAnalysisRules
+		*out = make([]SkipAnalysisRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.FastPath != nil {
+		in, out := &in.FastPath, &out.FastPath
+		*out = new(FastPath)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageTracking != nil {
+		in, out := &in.ImageTracking, &out.ImageTracking
+		*out = new(ImageTracking)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodePartition != nil {
+		in, out := &in.NodePartition, &out.NodePartition
+		*out = new(NodePartition)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrimaryNaming != nil {
+		in, out := &in.PrimaryNaming, &out.PrimaryNaming
+		*out = new(PrimaryNaming)
+		**out = **in
+	}
+	if in.PrimaryAntiAffinity != nil {
+		in, out := &in.PrimaryAntiAffinity, &out.PrimaryAntiAffinity
+		*out = new(PrimaryAntiAffinity)
+		**out = **in
+	}
+	if in.MetadataPropagation != nil {
+		in, out := &in.MetadataPropagation, &out.MetadataPropagation
+		*out = new(MetadataPropagation)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanarySpec.
+func (in *CanarySpec) DeepCopy() *CanarySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CanarySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryStatus) DeepCopyInto(out *CanaryStatus) {
+	*out = *in
+	if in.TrackedConfigs != nil {
+		in, out := &in.TrackedConfigs, &out.TrackedConfigs
+		*out = new(map[string]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make(map[string]string, len(*in))
+			for key, val := range *in {
+				(*out)[key] = val
+			}
+		}
+	}
+	if in.LastPromotedImages != nil {
+		in, out := &in.LastPromotedImages, &out.LastPromotedImages
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ChangedImages != nil {
+		in, out := &in.ChangedImages, &out.ChangedImages
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	in.RunStartTime.DeepCopyInto(&out.RunStartTime)
+	in.StepStartTime.DeepCopyInto(&out.StepStartTime)
+	in.NextRetryTime.DeepCopyInto(&out.NextRetryTime)
+	in.NextAnalysisTime.DeepCopyInto(&out.NextAnalysisTime)
+	if in.RollbackDrain != nil {
+		in, out := &in.RollbackDrain, &out.RollbackDrain
+		*out = new(CanaryRollbackDrain)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]CanaryCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Finalization != nil {
+		in, out := &in.Finalization, &out.Finalization
+		*out = make([]CanaryFinalizationStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]CanaryMetricStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]CanaryCheckFailure, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]CanaryWebhookStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStatus.
+func (in *CanaryStatus) DeepCopy() *CanaryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryThresholdRange) DeepCopyInto(out *CanaryThresholdRange) {
+	*out = *in
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		*out = new(float64)
+		**out = **in
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = new(float64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryThresholdRange.
+func (in *CanaryThresholdRange) DeepCopy() *CanaryThresholdRange {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryThresholdRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryWebhook) DeepCopyInto(out *CanaryWebhook) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = new(map[string]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make(map[string]string, len(*in))
+			for key, val := range *in {
+				(*out)[key] = val
+			}
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryWebhook.
+func (in *CanaryWebhook) DeepCopy() *CanaryWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryWebhookPayload) DeepCopyInto(out *CanaryWebhookPayload) {
+	*out = *in
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Rollback != nil {
+		in, out := &in.Rollback, &out.Rollback
+		*out = new(CanaryRollbackPayload)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryWebhookPayload.
+func (in *CanaryWebhookPayload) DeepCopy() *CanaryWebhookPayload {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryWebhookPayload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryWebhookStatus) DeepCopyInto(out *CanaryWebhookStatus) {
+	*out = *in
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryWebhookStatus.
+func (in *CanaryWebhookStatus) DeepCopy() *CanaryWebhookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryWebhookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigRewriteRule) DeepCopyInto(out *ConfigRewriteRule) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigRewriteRule.
+func (in *ConfigRewriteRule) DeepCopy() *ConfigRewriteRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigRewriteRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CrossNamespaceObjectReference) DeepCopyInto(out *CrossNamespaceObjectReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrossNamespaceObjectReference.
+func (in *CrossNamespaceObjectReference) DeepCopy() *CrossNamespaceObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CrossNamespaceObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomMetadata) DeepCopyInto(out *CustomMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomMetadata.
+func (in *CustomMetadata) DeepCopy() *CustomMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KayentaAnalysis) DeepCopyInto(out *KayentaAnalysis) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KayentaAnalysis.
+func (in *KayentaAnalysis) DeepCopy() *KayentaAnalysis {
+	if in == nil {
+		return nil
+	}
+	out := new(KayentaAnalysis)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeConfigReference) DeepCopyInto(out *KubeConfigReference) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeConfigReference.
+func (in *KubeConfigReference) DeepCopy() *KubeConfigReference {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeConfigReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalObjectReference) DeepCopyInto(out *LocalObjectReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalObjectReference.
+func (in *LocalObjectReference) DeepCopy() *LocalObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FastPath) DeepCopyInto(out *FastPath) {
+	*out = *in
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]FastPathField, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FastPath.
+func (in *FastPath) DeepCopy() *FastPath {
+	if in == nil {
+		return nil
+	}
+	out := new(FastPath)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageTracking) DeepCopyInto(out *ImageTracking) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageTracking.
+func (in *ImageTracking) DeepCopy() *ImageTracking {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageTracking)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InfluxDBOptions) DeepCopyInto(out *InfluxDBOptions) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InfluxDBOptions.
+func (in *InfluxDBOptions) DeepCopy() *InfluxDBOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(InfluxDBOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IstioTelemetry) DeepCopyInto(out *IstioTelemetry) {
+	*out = *in
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IstioTelemetry.
+func (in *IstioTelemetry) DeepCopy() *IstioTelemetry {
+	if in == nil {
+		return nil
+	}
+	out := new(IstioTelemetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataPropagation) DeepCopyInto(out *MetadataPropagation) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = new(MetadataSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = new(MetadataSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataPropagation.
+func (in *MetadataPropagation) DeepCopy() *MetadataPropagation {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataPropagation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataSelector) DeepCopyInto(out *MetadataSelector) {
+	*out = *in
+	if in.IncludePrefixes != nil {
+		in, out := &in.IncludePrefixes, &out.IncludePrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludePrefixes != nil {
+		in, out := &in.ExcludePrefixes, &out.ExcludePrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataSelector.
+func (in *MetadataSelector) DeepCopy() *MetadataSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricTemplate) DeepCopyInto(out *MetricTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplate.
+func (in *MetricTemplate) DeepCopy() *MetricTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetricTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricTemplateCondition) DeepCopyInto(out *MetricTemplateCondition) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateCondition.
+func (in *MetricTemplateCondition) DeepCopy() *MetricTemplateCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricTemplateCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricTemplateList) DeepCopyInto(out *MetricTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MetricTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateList.
+func (in *MetricTemplateList) DeepCopy() *MetricTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetricTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricTemplateModel) DeepCopyInto(out *MetricTemplateModel) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateModel.
+func (in *MetricTemplateModel) DeepCopy() *MetricTemplateModel {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricTemplateModel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricTemplateProvider) DeepCopyInto(out *MetricTemplateProvider) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]MetricTemplateProviderParam, len(*in))
+		copy(*out, *in)
+	}
+	if in.QueryParams != nil {
+		in, out := &in.QueryParams, &out.QueryParams
+		*out = make([]MetricTemplateProviderParam, len(*in))
+		copy(*out, *in)
+	}
+	if in.Thanos != nil {
+		in, out := &in.Thanos, &out.Thanos
+		*out = new(ThanosOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InfluxDB != nil {
+		in, out := &in.InfluxDB, &out.InfluxDB
+		*out = new(InfluxDBOptions)
+		**out = **in
+	}
+	if in.Stackdriver != nil {
+		in, out := &in.Stackdriver, &out.Stackdriver
+		*out = new(StackdriverOptions)
+		**out = **in
+	}
+	if in.OAuth2 != nil {
+		in, out := &in.OAuth2, &out.OAuth2
+		*out = new(OAuth2Options)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateProvider.
+func (in *MetricTemplateProvider) DeepCopy() *MetricTemplateProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricTemplateProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricTemplateProviderParam) DeepCopyInto(out *MetricTemplateProviderParam) {
+	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanarySpec.
-func (in *CanarySpec) DeepCopy() *CanarySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateProviderParam.
+func (in *MetricTemplateProviderParam) DeepCopy() *MetricTemplateProviderParam {
 	if in == nil {
 		return nil
 	}
-	out := new(CanarySpec)
+	out := new(MetricTemplateProviderParam)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CanaryStatus) DeepCopyInto(out *CanaryStatus) {
+func (in *MetricTemplateQuery) DeepCopyInto(out *MetricTemplateQuery) {
 	*out = *in
-	if in.TrackedConfigs != nil {
-		in, out := &in.TrackedConfigs, &out.TrackedConfigs
-		*out = new(map[string]string)
-		if **in != nil {
-			in, out := *in, *out
-			*out = make(map[string]string, len(*in))
-			for key, val := range *in {
-				(*out)[key] = val
-			}
-		}
-	}
-	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]CanaryCondition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Provider != nil {
+		in, out := &in.Provider, &out.Provider
+		*out = new(MetricTemplateProvider)
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStatus.
-func (in *CanaryStatus) DeepCopy() *CanaryStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateQuery.
+func (in *MetricTemplateQuery) DeepCopy() *MetricTemplateQuery {
 	if in == nil {
 		return nil
 	}
-	out := new(CanaryStatus)
+	out := new(MetricTemplateQuery)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CanaryThresholdRange) DeepCopyInto(out *CanaryThresholdRange) {
+func (in *MetricTemplateSpec) DeepCopyInto(out *MetricTemplateSpec) {
 	*out = *in
-	if in.Min != nil {
-		in, out := &in.Min, &out.Min
-		*out = new(float64)
-		**out = **in
-	}
-	if in.Max != nil {
-		in, out := &in.Max, &out.Max
-		*out = new(float64)
-		**out = **in
+	in.Provider.DeepCopyInto(&out.Provider)
+	if in.Queries != nil {
+		in, out := &in.Queries, &out.Queries
+		*out = make([]MetricTemplateQuery, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryThresholdRange.
-func (in *CanaryThresholdRange) DeepCopy() *CanaryThresholdRange {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateSpec.
+func (in *MetricTemplateSpec) DeepCopy() *MetricTemplateSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(CanaryThresholdRange)
+	out := new(MetricTemplateSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CanaryWebhook) DeepCopyInto(out *CanaryWebhook) {
+func (in *MetricTemplateStatus) DeepCopyInto(out *MetricTemplateStatus) {
 	*out = *in
-	if in.Metadata != nil {
-		in, out := &in.Metadata, &out.Metadata
-		*out = new(map[string]string)
-		if **in != nil {
-			in, out := *in, *out
-			*out = make(map[string]string, len(*in))
-			for key, val := range *in {
-				(*out)[key] = val
-			}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]MetricTemplateCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryWebhook.
-func (in *CanaryWebhook) DeepCopy() *CanaryWebhook {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateStatus.
+func (in *MetricTemplateStatus) DeepCopy() *MetricTemplateStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(CanaryWebhook)
+	out := new(MetricTemplateStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CanaryWebhookPayload) DeepCopyInto(out *CanaryWebhookPayload) {
+func (in *NodePartition) DeepCopyInto(out *NodePartition) {
 	*out = *in
-	if in.Metadata != nil {
-		in, out := &in.Metadata, &out.Metadata
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
 		*out = make(map[string]string, len(*in))
 		for key, val := range *in {
 			(*out)[key] = val
@@ -565,100 +1740,90 @@ func (in *CanaryWebhookPayload) DeepCopyInto(out *CanaryWebhookPayload) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryWebhookPayload.
-func (in *CanaryWebhookPayload) DeepCopy() *CanaryWebhookPayload {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePartition.
+func (in *NodePartition) DeepCopy() *NodePartition {
 	if in == nil {
 		return nil
 	}
-	out := new(CanaryWebhookPayload)
+	out := new(NodePartition)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CrossNamespaceObjectReference) DeepCopyInto(out *CrossNamespaceObjectReference) {
+func (in *OAuth2Options) DeepCopyInto(out *OAuth2Options) {
 	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrossNamespaceObjectReference.
-func (in *CrossNamespaceObjectReference) DeepCopy() *CrossNamespaceObjectReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2Options.
+func (in *OAuth2Options) DeepCopy() *OAuth2Options {
 	if in == nil {
 		return nil
 	}
-	out := new(CrossNamespaceObjectReference)
+	out := new(OAuth2Options)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CustomMetadata) DeepCopyInto(out *CustomMetadata) {
+func (in *PrimaryAntiAffinity) DeepCopyInto(out *PrimaryAntiAffinity) {
 	*out = *in
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Annotations != nil {
-		in, out := &in.Annotations, &out.Annotations
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomMetadata.
-func (in *CustomMetadata) DeepCopy() *CustomMetadata {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrimaryAntiAffinity.
+func (in *PrimaryAntiAffinity) DeepCopy() *PrimaryAntiAffinity {
 	if in == nil {
 		return nil
 	}
-	out := new(CustomMetadata)
+	out := new(PrimaryAntiAffinity)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LocalObjectReference) DeepCopyInto(out *LocalObjectReference) {
+func (in *PrimaryNaming) DeepCopyInto(out *PrimaryNaming) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalObjectReference.
-func (in *LocalObjectReference) DeepCopy() *LocalObjectReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrimaryNaming.
+func (in *PrimaryNaming) DeepCopy() *PrimaryNaming {
 	if in == nil {
 		return nil
 	}
-	out := new(LocalObjectReference)
+	out := new(PrimaryNaming)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricTemplate) DeepCopyInto(out *MetricTemplate) {
+func (in *ReleaseGroup) DeepCopyInto(out *ReleaseGroup) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplate.
-func (in *MetricTemplate) DeepCopy() *MetricTemplate {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseGroup.
+func (in *ReleaseGroup) DeepCopy() *ReleaseGroup {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricTemplate)
+	out := new(ReleaseGroup)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MetricTemplate) DeepCopyObject() runtime.Object {
+func (in *ReleaseGroup) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -666,31 +1831,13 @@ func (in *MetricTemplate) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricTemplateCondition) DeepCopyInto(out *MetricTemplateCondition) {
-	*out = *in
-	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
-	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
-	return
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateCondition.
-func (in *MetricTemplateCondition) DeepCopy() *MetricTemplateCondition {
-	if in == nil {
-		return nil
-	}
-	out := new(MetricTemplateCondition)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricTemplateList) DeepCopyInto(out *MetricTemplateList) {
+func (in *ReleaseGroupList) DeepCopyInto(out *ReleaseGroupList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]MetricTemplate, len(*in))
+		*out = make([]ReleaseGroup, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -698,18 +1845,18 @@ func (in *MetricTemplateList) DeepCopyInto(out *MetricTemplateList) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateList.
-func (in *MetricTemplateList) DeepCopy() *MetricTemplateList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseGroupList.
+func (in *ReleaseGroupList) DeepCopy() *ReleaseGroupList {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricTemplateList)
+	out := new(ReleaseGroupList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MetricTemplateList) DeepCopyObject() runtime.Object {
+func (in *ReleaseGroupList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -717,78 +1864,191 @@ func (in *MetricTemplateList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricTemplateModel) DeepCopyInto(out *MetricTemplateModel) {
+func (in *ReleaseGroupSpec) DeepCopyInto(out *ReleaseGroupSpec) {
 	*out = *in
+	if in.Canaries != nil {
+		in, out := &in.Canaries, &out.Canaries
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateModel.
-func (in *MetricTemplateModel) DeepCopy() *MetricTemplateModel {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseGroupSpec.
+func (in *ReleaseGroupSpec) DeepCopy() *ReleaseGroupSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricTemplateModel)
+	out := new(ReleaseGroupSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricTemplateProvider) DeepCopyInto(out *MetricTemplateProvider) {
+func (in *RetryBudget) DeepCopyInto(out *RetryBudget) {
 	*out = *in
-	if in.SecretRef != nil {
-		in, out := &in.SecretRef, &out.SecretRef
-		*out = new(v1.LocalObjectReference)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryBudget.
+func (in *RetryBudget) DeepCopy() *RetryBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScalerReplicas) DeepCopyInto(out *ScalerReplicas) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
 		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateProvider.
-func (in *MetricTemplateProvider) DeepCopy() *MetricTemplateProvider {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScalerReplicas.
+func (in *ScalerReplicas) DeepCopy() *ScalerReplicas {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricTemplateProvider)
+	out := new(ScalerReplicas)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricTemplateSpec) DeepCopyInto(out *MetricTemplateSpec) {
+func (in *SessionAffinity) DeepCopyInto(out *SessionAffinity) {
 	*out = *in
-	in.Provider.DeepCopyInto(&out.Provider)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateSpec.
-func (in *MetricTemplateSpec) DeepCopy() *MetricTemplateSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionAffinity.
+func (in *SessionAffinity) DeepCopy() *SessionAffinity {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricTemplateSpec)
+	out := new(SessionAffinity)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricTemplateStatus) DeepCopyInto(out *MetricTemplateStatus) {
+func (in *SkipAnalysisRule) DeepCopyInto(out *SkipAnalysisRule) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]MetricTemplateCondition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SkipAnalysisRule.
+func (in *SkipAnalysisRule) DeepCopy() *SkipAnalysisRule {
+	if in == nil {
+		return nil
 	}
+	out := new(SkipAnalysisRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackdriverOptions) DeepCopyInto(out *StackdriverOptions) {
+	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTemplateStatus.
-func (in *MetricTemplateStatus) DeepCopy() *MetricTemplateStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackdriverOptions.
+func (in *StackdriverOptions) DeepCopy() *StackdriverOptions {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricTemplateStatus)
+	out := new(StackdriverOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetWorkload) DeepCopyInto(out *TargetWorkload) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetWorkload.
+func (in *TargetWorkload) DeepCopy() *TargetWorkload {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetWorkload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThanosOptions) DeepCopyInto(out *ThanosOptions) {
+	*out = *in
+	if in.PartialResponse != nil {
+		in, out := &in.PartialResponse, &out.PartialResponse
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Dedup != nil {
+		in, out := &in.Dedup, &out.Dedup
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThanosOptions.
+func (in *ThanosOptions) DeepCopy() *ThanosOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(ThanosOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeWindow) DeepCopyInto(out *TimeWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeWindow.
+func (in *TimeWindow) DeepCopy() *TimeWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrackedObjectReference) DeepCopyInto(out *TrackedObjectReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrackedObjectReference.
+func (in *TrackedObjectReference) DeepCopy() *TrackedObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(TrackedObjectReference)
 	in.DeepCopyInto(out)
 	return out
 }
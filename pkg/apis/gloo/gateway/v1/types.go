@@ -20,9 +20,22 @@ type RouteTableSpec struct {
 }
 
 type Route struct {
-	Matchers                []Matcher   `json:"matchers,omitempty"`
-	Action                  RouteAction `json:"routeAction,omitempty"`
-	InheritablePathMatchers bool        `json:"inheritablePathMatchers,omitempty"`
+	Matchers                []Matcher     `json:"matchers,omitempty"`
+	Action                  RouteAction   `json:"routeAction,omitempty"`
+	Options                 *RouteOptions `json:"options,omitempty"`
+	InheritablePathMatchers bool          `json:"inheritablePathMatchers,omitempty"`
+}
+
+// RouteOptions are the Gloo plugins applied to a route
+type RouteOptions struct {
+	Retries *RetryPolicy `json:"retries,omitempty"`
+}
+
+// RetryPolicy configures the retries of the requests matching a route
+type RetryPolicy struct {
+	RetryOn       string `json:"retryOn,omitempty"`
+	NumRetries    uint32 `json:"numRetries,omitempty"`
+	PerTryTimeout string `json:"perTryTimeout,omitempty"`
 }
 
 type Matcher struct {
@@ -513,6 +513,9 @@ type DestinationWeight struct {
 	// If there is only destination in a rule, the weight value is assumed to
 	// be 100.
 	Weight int `json:"weight"`
+
+	// Header manipulation rules applied to the traffic forwarded to this destination
+	Headers *Headers `json:"headers,omitempty"`
 }
 
 // PortSelector specifies the number of a port to be used for
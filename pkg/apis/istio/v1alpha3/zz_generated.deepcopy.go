@@ -233,6 +233,11 @@ func (in *DestinationRuleSpec) DeepCopy() *DestinationRuleSpec {
 func (in *DestinationWeight) DeepCopyInto(out *DestinationWeight) {
 	*out = *in
 	in.Destination.DeepCopyInto(&out.Destination)
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = new(Headers)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
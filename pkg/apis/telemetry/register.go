@@ -0,0 +1,5 @@
+package telemetry
+
+const (
+	GroupName = "telemetry.istio.io"
+)
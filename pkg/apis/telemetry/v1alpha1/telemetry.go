@@ -0,0 +1,110 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Telemetry defines how the telemetry is generated for the workloads within a mesh
+type Telemetry struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TelemetrySpec `json:"spec"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TelemetryList is a list of Telemetry resources
+type TelemetryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Telemetry `json:"items"`
+}
+
+// TelemetrySpec is the specification of the Telemetry resource
+type TelemetrySpec struct {
+	// Selector restricts the Telemetry to the workloads matching the labels,
+	// if omitted it applies to all workloads in the namespace.
+	// +optional
+	Selector *WorkloadSelector `json:"selector,omitempty"`
+
+	// Metrics configures the metrics generation behavior.
+	// +optional
+	Metrics []Metrics `json:"metrics,omitempty"`
+}
+
+// WorkloadSelector specifies the criteria used to select the workloads.
+type WorkloadSelector struct {
+	// MatchLabels are the workload labels the Telemetry applies to.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+// Metrics configures the metrics generated by the mesh proxies.
+type Metrics struct {
+	// Providers the configuration applies to, defaults to the mesh config providers.
+	// +optional
+	Providers []ProviderRef `json:"providers,omitempty"`
+
+	// Overrides of the metrics generation behavior.
+	// +optional
+	Overrides []MetricsOverrides `json:"overrides,omitempty"`
+}
+
+// ProviderRef references a telemetry provider defined in the mesh config.
+type ProviderRef struct {
+	// Name of the telemetry provider.
+	Name string `json:"name"`
+}
+
+// MetricsOverrides customizes the metrics matching the selector.
+type MetricsOverrides struct {
+	// Match selects the metrics the override applies to, defaults to all metrics.
+	// +optional
+	Match *MetricSelector `json:"match,omitempty"`
+
+	// TagOverrides to add, modify or remove the dimensions of the matching metrics.
+	// +optional
+	TagOverrides map[string]TagOverride `json:"tagOverrides,omitempty"`
+}
+
+// MetricSelector selects the metrics by name and generation mode.
+type MetricSelector struct {
+	// Metric is one of the Istio standard metrics e.g. REQUEST_COUNT or ALL_METRICS.
+	// +optional
+	Metric string `json:"metric,omitempty"`
+
+	// CustomMetric is the name of a metric not part of the Istio standard metrics.
+	// +optional
+	CustomMetric string `json:"customMetric,omitempty"`
+
+	// Mode is one of CLIENT_AND_SERVER, CLIENT or SERVER.
+	// +optional
+	Mode string `json:"mode,omitempty"`
+}
+
+// TagOverride specifies the operation applied to a metric dimension.
+type TagOverride struct {
+	// Operation is either UPSERT or REMOVE.
+	// +optional
+	Operation string `json:"operation,omitempty"`
+
+	// Value is the expression used to compute the dimension value.
+	// +optional
+	Value string `json:"value,omitempty"`
+}
+
+const (
+	// AllMetrics selects all the Istio standard metrics.
+	AllMetrics = "ALL_METRICS"
+
+	// ClientAndServerMode selects the metrics reported by both the client and server proxies.
+	ClientAndServerMode = "CLIENT_AND_SERVER"
+
+	// UpsertOperation inserts or overwrites the dimension value.
+	UpsertOperation = "UPSERT"
+)
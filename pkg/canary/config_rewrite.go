@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"strings"
+	"unicode"
+
+	corev1 "k8s.io/api/core/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// primaryConfigNames maps the names of the configs that have a primary copy to the primary names
+func primaryConfigNames(cd *flaggerv1.Canary, refs map[string]ConfigRef) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, ref := range refs {
+		switch ref.Type {
+		case ConfigRefMap, ConfigRefSecret, ConfigRefSecretProviderClass:
+			primaryName, err := cd.GetPrimaryName(ref.Name)
+			if err != nil {
+				return nil, err
+			}
+			names[ref.Name] = primaryName
+		}
+	}
+	return names, nil
+}
+
+// rewriteConfigNames replaces the config names found in the value with their primary names,
+// a name is replaced only when it is a whole token bounded by the start or end of the value,
+// '=', ',' or whitespace e.g. --config=podinfo-config is rewritten while
+// /etc/podinfo-config and podinfo-config.yaml are left as is
+func rewriteConfigNames(value string, names map[string]string) string {
+	var b strings.Builder
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		token := value[start:end]
+		if primary, ok := names[token]; ok {
+			token = primary
+		}
+		b.WriteString(token)
+		start = -1
+	}
+	for i, r := range value {
+		if r != '=' && r != ',' && !unicode.IsSpace(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+		b.WriteRune(r)
+	}
+	flush(len(value))
+	return b.String()
+}
+
+// applyConfigRewrites replaces the config names found in the containers args and env values
+// selected by the canary config rewrite rules
+func applyConfigRewrites(cd *flaggerv1.Canary, containers []corev1.Container, names map[string]string) {
+	if len(cd.Spec.ConfigRewrites) == 0 || len(names) == 0 {
+		return
+	}
+
+	for _, rule := range cd.Spec.ConfigRewrites {
+		for c := range containers {
+			container := &containers[c]
+			if !rule.MatchesContainer(container.Name) {
+				continue
+			}
+			switch rule.Field {
+			case flaggerv1.ArgsConfigRewriteField:
+				for i, arg := range container.Args {
+					container.Args[i] = rewriteConfigNames(arg, names)
+				}
+			case flaggerv1.EnvConfigRewriteField:
+				for i, env := range container.Env {
+					if env.ValueFrom == nil && rule.MatchesKey(env.Name) {
+						container.Env[i].Value = rewriteConfigNames(env.Value, names)
+					}
+				}
+			}
+		}
+	}
+}
+
+// primaryConfigAnnotations returns a copy of the pod annotations with the config names
+// replaced in the values selected by the canary config rewrite rules
+func primaryConfigAnnotations(cd *flaggerv1.Canary, annotations map[string]string,
+	refs map[string]ConfigRef) (map[string]string, error) {
+	if len(cd.Spec.ConfigRewrites) == 0 {
+		return annotations, nil
+	}
+	names, err := primaryConfigNames(cd, refs)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return annotations, nil
+	}
+
+	res := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		res[k] = v
+	}
+	for _, rule := range cd.Spec.ConfigRewrites {
+		if rule.Field != flaggerv1.AnnotationsConfigRewriteField {
+			continue
+		}
+		for k, v := range res {
+			if rule.MatchesKey(k) {
+				res[k] = rewriteConfigNames(v, names)
+			}
+		}
+	}
+	return res, nil
+}
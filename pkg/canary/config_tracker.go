@@ -27,7 +27,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -37,6 +39,7 @@ import (
 // ConfigTracker is managing the operations for Kubernetes ConfigMaps and Secrets
 type ConfigTracker struct {
 	KubeClient    kubernetes.Interface
+	DynamicClient dynamic.Interface
 	FlaggerClient clientset.Interface
 	Logger        *zap.SugaredLogger
 }
@@ -44,12 +47,23 @@ type ConfigTracker struct {
 type ConfigRefType string
 
 const (
-	ConfigRefMap    ConfigRefType = "configmap"
-	ConfigRefSecret ConfigRefType = "secret"
+	ConfigRefMap                 ConfigRefType = "configmap"
+	ConfigRefSecret              ConfigRefType = "secret"
+	ConfigRefSecretProviderClass ConfigRefType = "secretproviderclass"
 
 	configTrackingDisabledAnnotationKey = "flagger.app/config-tracking"
+
+	secretsStoreCSIDriver         = "secrets-store.csi.k8s.io"
+	secretProviderClassVolumeAttr = "secretProviderClass"
 )
 
+// SecretProviderClassGVR is the resource of the secrets-store CSI driver provider classes
+var SecretProviderClassGVR = schema.GroupVersionResource{
+	Group:    "secrets-store.csi.x-k8s.io",
+	Version:  "v1",
+	Resource: "secretproviderclasses",
+}
+
 // ConfigRef holds the reference to a tracked Kubernetes ConfigMap or Secret
 type ConfigRef struct {
 	Name     string
@@ -113,6 +127,21 @@ func (ct *ConfigTracker) getRefFromSecret(name string, namespace string) (*Confi
 		return nil, nil
 	}
 
+	// the primary copy of a generated secret is rotated by its own generator,
+	// only the changes of the generator spec trigger a new revision
+	generator, _, err := ct.getSecretGenerator(secret)
+	if err != nil {
+		return nil, err
+	}
+	if generator != nil {
+		spec, _, _ := unstructured.NestedMap(generator.Object, "spec")
+		return &ConfigRef{
+			Name:     secret.Name,
+			Type:     ConfigRefSecret,
+			Checksum: checksum(spec),
+		}, nil
+	}
+
 	return &ConfigRef{
 		Name:     secret.Name,
 		Type:     ConfigRefSecret,
@@ -120,6 +149,29 @@ func (ct *ConfigTracker) getRefFromSecret(name string, namespace string) (*Confi
 	}, nil
 }
 
+// getRefFromSecretProviderClass transforms a secrets-store CSI SecretProviderClass into a ConfigRef
+// and computes the checksum of the SecretProviderClass spec
+func (ct *ConfigTracker) getRefFromSecretProviderClass(name string, namespace string) (*ConfigRef, error) {
+	if ct.DynamicClient == nil {
+		return nil, fmt.Errorf("secretproviderclass %s.%s get query error: dynamic client not configured", name, namespace)
+	}
+	spc, err := ct.DynamicClient.Resource(SecretProviderClassGVR).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("secretproviderclass %s.%s get query error: %w", name, namespace, err)
+	}
+
+	if configIsDisabled(spc.GetAnnotations()) {
+		return nil, nil
+	}
+
+	spec, _, _ := unstructured.NestedMap(spc.Object, "spec")
+	return &ConfigRef{
+		Name:     spc.GetName(),
+		Type:     ConfigRefSecretProviderClass,
+		Checksum: checksum(spec),
+	}, nil
+}
+
 // GetTargetConfigs scans the target deployment for Kubernetes ConfigMaps and Secrets
 // and returns a list of config references
 func (ct *ConfigTracker) GetTargetConfigs(cd *flaggerv1.Canary) (map[string]ConfigRef, error) {
@@ -144,12 +196,38 @@ func (ct *ConfigTracker) GetTargetConfigs(cd *flaggerv1.Canary) (map[string]Conf
 		vs = targetDae.Spec.Template.Spec.Volumes
 		cs = targetDae.Spec.Template.Spec.Containers
 		cs = append(cs, targetDae.Spec.Template.Spec.InitContainers...)
+	case "StatefulSet":
+		targetSts, err := ct.KubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("statefulset %s.%s get query error: %w", targetName, cd.Namespace, err)
+		}
+		vs = targetSts.Spec.Template.Spec.Volumes
+		cs = targetSts.Spec.Template.Spec.Containers
+		cs = append(cs, targetSts.Spec.Template.Spec.InitContainers...)
+	case "Rollout":
+		rollout, err := getRollout(ct.KubeClient, ct.DynamicClient, targetName, cd.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		vs = rollout.Spec.Template.Spec.Volumes
+		cs = rollout.Spec.Template.Spec.Containers
+		cs = append(cs, rollout.Spec.Template.Spec.InitContainers...)
 	default:
-		return nil, fmt.Errorf("TargetRef.Kind invalid: %s", cd.Spec.TargetRef.Kind)
+		if cd.Spec.TargetWorkload == nil {
+			return nil, fmt.Errorf("TargetRef.Kind invalid: %s", cd.Spec.TargetRef.Kind)
+		}
+		w, err := getCustomWorkload(ct.DynamicClient, cd)
+		if err != nil {
+			return nil, err
+		}
+		vs = w.template.Spec.Volumes
+		cs = w.template.Spec.Containers
+		cs = append(cs, w.template.Spec.InitContainers...)
 	}
 
 	secretNames := make(map[string]bool)
 	configMapNames := make(map[string]bool)
+	providerClassNames := make(map[string]bool)
 
 	// scan volumes
 	for _, volume := range vs {
@@ -174,6 +252,12 @@ func (ct *ConfigTracker) GetTargetConfigs(cd *flaggerv1.Canary) (map[string]Conf
 				}
 			}
 		}
+
+		if csi := volume.CSI; csi != nil && csi.Driver == secretsStoreCSIDriver {
+			if name := csi.VolumeAttributes[secretProviderClassVolumeAttr]; name != "" {
+				providerClassNames[name] = true
+			}
+		}
 	}
 	// scan containers
 	for _, container := range cs {
@@ -233,9 +317,63 @@ func (ct *ConfigTracker) GetTargetConfigs(cd *flaggerv1.Canary) (map[string]Conf
 		}
 	}
 
+	for providerClassName := range providerClassNames {
+		spc, err := ct.getRefFromSecretProviderClass(providerClassName, cd.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if spc != nil {
+			res[spc.GetName()] = *spc
+		}
+	}
+
+	for _, obj := range cd.Spec.TrackedObjects {
+		ref, err := ct.getRefFromTrackedObject(obj, cd.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if ref != nil {
+			res[ref.GetName()] = *ref
+		}
+	}
+
 	return res, nil
 }
 
+// getRefFromTrackedObject transforms an object declared in the canary tracked objects into a ConfigRef
+// and computes the checksum of the object content, the metadata and the status are left out
+func (ct *ConfigTracker) getRefFromTrackedObject(obj flaggerv1.TrackedObjectReference, namespace string) (*ConfigRef, error) {
+	gv, err := schema.ParseGroupVersion(obj.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("trackedObjects apiVersion %s is invalid: %w", obj.APIVersion, err)
+	}
+	gvr := gv.WithResource(obj.GetResource())
+	if ct.DynamicClient == nil {
+		return nil, fmt.Errorf("%s %s.%s get query error: dynamic client not configured", gvr.Resource, obj.Name, namespace)
+	}
+
+	u, err := ct.DynamicClient.Resource(gvr).Namespace(namespace).Get(context.TODO(), obj.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s %s.%s get query error: %w", gvr.Resource, obj.Name, namespace, err)
+	}
+
+	if configIsDisabled(u.GetAnnotations()) {
+		return nil, nil
+	}
+
+	content := make(map[string]interface{}, len(u.Object))
+	for k, v := range u.Object {
+		if k != "metadata" && k != "status" {
+			content[k] = v
+		}
+	}
+	return &ConfigRef{
+		Name:     u.GetName(),
+		Type:     ConfigRefType(gvr.GroupResource().String()),
+		Checksum: checksum(content),
+	}, nil
+}
+
 func fieldIsMandatory(p *bool) bool {
 	if p == nil {
 		return true
@@ -301,7 +439,10 @@ func (ct *ConfigTracker) CreatePrimaryConfigs(cd *flaggerv1.Canary, refs map[str
 			if err != nil {
 				return fmt.Errorf("configmap %s.%s get query failed : %w", ref.Name, cd.Namespace, err)
 			}
-			primaryName := fmt.Sprintf("%s-primary", config.GetName())
+			primaryName, err := cd.GetPrimaryName(config.GetName())
+			if err != nil {
+				return err
+			}
 			ownerReferences := []metav1.OwnerReference{
 				*metav1.NewControllerRef(cd, schema.GroupVersionKind{
 					Group:   flaggerv1.SchemeGroupVersion.Group,
@@ -329,17 +470,17 @@ func (ct *ConfigTracker) CreatePrimaryConfigs(cd *flaggerv1.Canary, refs map[str
 				ObjectMeta: metav1.ObjectMeta{
 					Name:            primaryName,
 					Namespace:       cd.Namespace,
-					Labels:          labels,
+					Labels:          makeAuditLabels(cd, labels),
 					OwnerReferences: ownerReferences,
 				},
 				Data: config.Data,
 			}
 
 			// update or insert primary ConfigMap
-			_, err = ct.KubeClient.CoreV1().ConfigMaps(cd.Namespace).Update(context.TODO(), primaryConfigMap, metav1.UpdateOptions{})
+			_, err = ct.KubeClient.CoreV1().ConfigMaps(cd.Namespace).Update(context.TODO(), primaryConfigMap, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 			if err != nil {
 				if errors.IsNotFound(err) {
-					_, err = ct.KubeClient.CoreV1().ConfigMaps(cd.Namespace).Create(context.TODO(), primaryConfigMap, metav1.CreateOptions{})
+					_, err = ct.KubeClient.CoreV1().ConfigMaps(cd.Namespace).Create(context.TODO(), primaryConfigMap, metav1.CreateOptions{FieldManager: cd.FieldManager()})
 					if err != nil {
 						return fmt.Errorf("creating configmap %s.%s failed: %w", primaryConfigMap.Name, cd.Namespace, err)
 					}
@@ -355,7 +496,20 @@ func (ct *ConfigTracker) CreatePrimaryConfigs(cd *flaggerv1.Canary, refs map[str
 			if err != nil {
 				return fmt.Errorf("secret %s.%s get query failed : %w", ref.Name, cd.Namespace, err)
 			}
-			primaryName := fmt.Sprintf("%s-primary", secret.GetName())
+			generator, gvr, err := ct.getSecretGenerator(secret)
+			if err != nil {
+				return err
+			}
+			if generator != nil {
+				if err := ct.createPrimarySecretGenerator(cd, secret, generator, gvr, includeLabelPrefix); err != nil {
+					return err
+				}
+				continue
+			}
+			primaryName, err := cd.GetPrimaryName(secret.GetName())
+			if err != nil {
+				return err
+			}
 			ownerReferences := []metav1.OwnerReference{
 				*metav1.NewControllerRef(cd, schema.GroupVersionKind{
 					Group:   flaggerv1.SchemeGroupVersion.Group,
@@ -382,7 +536,7 @@ func (ct *ConfigTracker) CreatePrimaryConfigs(cd *flaggerv1.Canary, refs map[str
 				ObjectMeta: metav1.ObjectMeta{
 					Name:            primaryName,
 					Namespace:       cd.Namespace,
-					Labels:          labels,
+					Labels:          makeAuditLabels(cd, labels),
 					OwnerReferences: ownerReferences,
 				},
 				Type: secret.Type,
@@ -390,10 +544,10 @@ func (ct *ConfigTracker) CreatePrimaryConfigs(cd *flaggerv1.Canary, refs map[str
 			}
 
 			// update or insert primary Secret
-			_, err = ct.KubeClient.CoreV1().Secrets(cd.Namespace).Update(context.TODO(), primarySecret, metav1.UpdateOptions{})
+			_, err = ct.KubeClient.CoreV1().Secrets(cd.Namespace).Update(context.TODO(), primarySecret, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 			if err != nil {
 				if errors.IsNotFound(err) {
-					_, err = ct.KubeClient.CoreV1().Secrets(cd.Namespace).Create(context.TODO(), primarySecret, metav1.CreateOptions{})
+					_, err = ct.KubeClient.CoreV1().Secrets(cd.Namespace).Create(context.TODO(), primarySecret, metav1.CreateOptions{FieldManager: cd.FieldManager()})
 					if err != nil {
 						return fmt.Errorf("creating secret %s.%s failed: %w", primarySecret.Name, cd.Namespace, err)
 					}
@@ -404,27 +558,89 @@ func (ct *ConfigTracker) CreatePrimaryConfigs(cd *flaggerv1.Canary, refs map[str
 
 			ct.Logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
 				Infof("Secret %s synced", primarySecret.GetName())
+		case ConfigRefSecretProviderClass:
+			if err := ct.createPrimarySecretProviderClass(cd, ref.Name, includeLabelPrefix); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// createPrimarySecretProviderClass syncs the primary copy of a SecretProviderClass,
+// the synced Kubernetes secrets are left out as the primary pods use the primary copies of those secrets
+func (ct *ConfigTracker) createPrimarySecretProviderClass(cd *flaggerv1.Canary, name string, includeLabelPrefix []string) error {
+	client := ct.DynamicClient.Resource(SecretProviderClassGVR).Namespace(cd.Namespace)
+	spc, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("secretproviderclass %s.%s get query failed : %w", name, cd.Namespace, err)
+	}
+	spec, _, _ := unstructured.NestedMap(spc.Object, "spec")
+	delete(spec, "secretObjects")
+
+	primaryName, err := cd.GetPrimaryName(name)
+	if err != nil {
+		return err
+	}
+	primary := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": spc.GetAPIVersion(),
+		"kind":       spc.GetKind(),
+		"spec":       spec,
+	}}
+	primary.SetName(primaryName)
+	primary.SetNamespace(cd.Namespace)
+	primary.SetLabels(makeAuditLabels(cd, includeLabelsByPrefix(spc.GetLabels(), includeLabelPrefix)))
+	primary.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+			Group:   flaggerv1.SchemeGroupVersion.Group,
+			Version: flaggerv1.SchemeGroupVersion.Version,
+			Kind:    flaggerv1.CanaryKind,
+		}),
+	})
+
+	// update or insert primary SecretProviderClass
+	oldPrimary, err := client.Get(context.TODO(), primaryName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		if _, err := client.Create(context.TODO(), primary, metav1.CreateOptions{FieldManager: cd.FieldManager()}); err != nil {
+			return fmt.Errorf("creating secretproviderclass %s.%s failed: %w", primaryName, cd.Namespace, err)
+		}
+	case err != nil:
+		return fmt.Errorf("secretproviderclass %s.%s get query failed : %w", primaryName, cd.Namespace, err)
+	default:
+		primary.SetResourceVersion(oldPrimary.GetResourceVersion())
+		if _, err := client.Update(context.TODO(), primary, metav1.UpdateOptions{FieldManager: cd.FieldManager()}); err != nil {
+			return fmt.Errorf("updating secretproviderclass %s.%s failed: %w", primaryName, cd.Namespace, err)
 		}
 	}
 
+	ct.Logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Infof("SecretProviderClass %s synced", primaryName)
 	return nil
 }
 
-// ApplyPrimaryConfigs appends the primary suffix to all ConfigMaps and Secrets found in the PodSpec
-func (ct *ConfigTracker) ApplyPrimaryConfigs(spec corev1.PodSpec, refs map[string]ConfigRef) corev1.PodSpec {
+// ApplyPrimaryConfigs replaces all ConfigMaps and Secrets found in the PodSpec with their primary copies
+func (ct *ConfigTracker) ApplyPrimaryConfigs(cd *flaggerv1.Canary, spec corev1.PodSpec,
+	refs map[string]ConfigRef) (corev1.PodSpec, error) {
+	names, err := primaryConfigNames(cd, refs)
+	if err != nil {
+		return spec, err
+	}
+
 	// update volumes
 	for i, volume := range spec.Volumes {
 		if cmv := volume.ConfigMap; cmv != nil {
 			name := fmt.Sprintf("%s/%s", ConfigRefMap, cmv.Name)
 			if _, exists := refs[name]; exists {
-				spec.Volumes[i].ConfigMap.Name += "-primary"
+				spec.Volumes[i].ConfigMap.Name = names[spec.Volumes[i].ConfigMap.Name]
 			}
 		}
 
 		if sv := volume.Secret; sv != nil {
 			name := fmt.Sprintf("%s/%s", ConfigRefSecret, sv.SecretName)
 			if _, exists := refs[name]; exists {
-				spec.Volumes[i].Secret.SecretName += "-primary"
+				spec.Volumes[i].Secret.SecretName = names[spec.Volumes[i].Secret.SecretName]
 			}
 		}
 
@@ -433,22 +649,41 @@ func (ct *ConfigTracker) ApplyPrimaryConfigs(spec corev1.PodSpec, refs map[strin
 				if cmv := source.ConfigMap; cmv != nil {
 					name := fmt.Sprintf("%s/%s", ConfigRefMap, cmv.Name)
 					if _, exists := refs[name]; exists {
-						spec.Volumes[i].Projected.Sources[s].ConfigMap.Name += "-primary"
+						spec.Volumes[i].Projected.Sources[s].ConfigMap.Name = names[spec.Volumes[i].Projected.Sources[s].ConfigMap.Name]
 					}
 				}
 
 				if sv := source.Secret; sv != nil {
 					name := fmt.Sprintf("%s/%s", ConfigRefSecret, sv.Name)
 					if _, exists := refs[name]; exists {
-						spec.Volumes[i].Projected.Sources[s].Secret.Name += "-primary"
+						spec.Volumes[i].Projected.Sources[s].Secret.Name = names[spec.Volumes[i].Projected.Sources[s].Secret.Name]
 					}
 				}
 			}
 		}
+
+		if csi := volume.CSI; csi != nil && csi.Driver == secretsStoreCSIDriver {
+			name := fmt.Sprintf("%s/%s", ConfigRefSecretProviderClass, csi.VolumeAttributes[secretProviderClassVolumeAttr])
+			if _, exists := refs[name]; exists {
+				spec.Volumes[i].CSI.VolumeAttributes[secretProviderClassVolumeAttr] = names[csi.VolumeAttributes[secretProviderClassVolumeAttr]]
+			}
+		}
 	}
 
-	// update containers
-	for _, container := range spec.Containers {
+	// update containers and init containers
+	applyPrimaryContainerConfigs(spec.Containers, refs, names)
+	applyPrimaryContainerConfigs(spec.InitContainers, refs, names)
+
+	// update the config names found in args and env values
+	applyConfigRewrites(cd, spec.Containers, names)
+	applyConfigRewrites(cd, spec.InitContainers, names)
+
+	return spec, nil
+}
+
+// applyPrimaryContainerConfigs replaces the ConfigMaps and Secrets found in the containers env with their primary copies
+func applyPrimaryContainerConfigs(containers []corev1.Container, refs map[string]ConfigRef, names map[string]string) {
+	for _, container := range containers {
 		// update env
 		for i, env := range container.Env {
 			if env.ValueFrom != nil {
@@ -456,12 +691,12 @@ func (ct *ConfigTracker) ApplyPrimaryConfigs(spec corev1.PodSpec, refs map[strin
 				case env.ValueFrom.ConfigMapKeyRef != nil:
 					name := fmt.Sprintf("%s/%s", ConfigRefMap, env.ValueFrom.ConfigMapKeyRef.Name)
 					if _, exists := refs[name]; exists {
-						container.Env[i].ValueFrom.ConfigMapKeyRef.Name += "-primary"
+						container.Env[i].ValueFrom.ConfigMapKeyRef.Name = names[container.Env[i].ValueFrom.ConfigMapKeyRef.Name]
 					}
 				case env.ValueFrom.SecretKeyRef != nil:
 					name := fmt.Sprintf("%s/%s", ConfigRefSecret, env.ValueFrom.SecretKeyRef.Name)
 					if _, exists := refs[name]; exists {
-						container.Env[i].ValueFrom.SecretKeyRef.Name += "-primary"
+						container.Env[i].ValueFrom.SecretKeyRef.Name = names[container.Env[i].ValueFrom.SecretKeyRef.Name]
 					}
 				}
 			}
@@ -472,16 +707,14 @@ func (ct *ConfigTracker) ApplyPrimaryConfigs(spec corev1.PodSpec, refs map[strin
 			case envFrom.ConfigMapRef != nil:
 				name := fmt.Sprintf("%s/%s", ConfigRefMap, envFrom.ConfigMapRef.Name)
 				if _, exists := refs[name]; exists {
-					container.EnvFrom[i].ConfigMapRef.Name += "-primary"
+					container.EnvFrom[i].ConfigMapRef.Name = names[container.EnvFrom[i].ConfigMapRef.Name]
 				}
 			case envFrom.SecretRef != nil:
 				name := fmt.Sprintf("%s/%s", ConfigRefSecret, envFrom.SecretRef.Name)
 				if _, exists := refs[name]; exists {
-					container.EnvFrom[i].SecretRef.Name += "-primary"
+					container.EnvFrom[i].SecretRef.Name = names[container.EnvFrom[i].SecretRef.Name]
 				}
 			}
 		}
 	}
-
-	return spec
 }
@@ -24,9 +24,15 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
 	k8sTesting "k8s.io/client-go/testing"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
 func TestConfigIsDisabled(t *testing.T) {
@@ -347,6 +353,293 @@ func TestConfigTracker_Secrets(t *testing.T) {
 	})
 }
 
+func TestConfigTracker_InitContainers(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.initializeCanary(t)
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	initContainer := depPrimary.Spec.Template.Spec.InitContainers[0]
+	assert.Equal(t, "podinfo-config-init-env-primary", initContainer.Env[0].ValueFrom.ConfigMapKeyRef.Name)
+	assert.Equal(t, "podinfo-secret-init-env-primary", initContainer.Env[1].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, "podinfo-config-init-all-env-primary", initContainer.EnvFrom[0].ConfigMapRef.Name)
+	assert.Equal(t, "podinfo-secret-init-all-env-primary", initContainer.EnvFrom[1].SecretRef.Name)
+}
+
+func TestConfigTracker_ConfigRewrites(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.canary.Spec.ConfigRewrites = []flaggerv1.ConfigRewriteRule{
+		{Field: flaggerv1.ArgsConfigRewriteField},
+		{Field: flaggerv1.EnvConfigRewriteField, Keys: []string{"CONFIG_NAME"}},
+		{Field: flaggerv1.AnnotationsConfigRewriteField, Keys: []string{"app/config"}},
+	}
+
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	container := &dep.Spec.Template.Spec.Containers[0]
+	container.Args = []string{
+		"--config=podinfo-config-env",
+		"--file=/etc/podinfo-config-env.yaml",
+		"--config-dir=/etc/podinfo-config-env",
+		"--untracked=podinfo-config-tracker-disabled",
+		"--secret", "podinfo-secret-env",
+	}
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "CONFIG_NAME", Value: "podinfo-secret-env"},
+		corev1.EnvVar{Name: "OTHER_NAME", Value: "podinfo-secret-env"})
+	dep.Spec.Template.Annotations = map[string]string{
+		"app/config": "podinfo-config-env,podinfo-secret-env",
+		"app/other":  "podinfo-config-env",
+	}
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	mocks.initializeCanary(t)
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	primaryContainer := depPrimary.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, []string{
+		"--config=podinfo-config-env-primary",
+		"--file=/etc/podinfo-config-env.yaml",
+		"--config-dir=/etc/podinfo-config-env",
+		"--untracked=podinfo-config-tracker-disabled",
+		"--secret", "podinfo-secret-env-primary",
+	}, primaryContainer.Args)
+
+	env := make(map[string]string)
+	for _, e := range primaryContainer.Env {
+		env[e.Name] = e.Value
+	}
+	assert.Equal(t, "podinfo-secret-env-primary", env["CONFIG_NAME"])
+	assert.Equal(t, "podinfo-secret-env", env["OTHER_NAME"])
+
+	annotations := depPrimary.Spec.Template.Annotations
+	assert.Equal(t, "podinfo-config-env-primary,podinfo-secret-env-primary", annotations["app/config"])
+	assert.Equal(t, "podinfo-config-env", annotations["app/other"])
+
+	// the canary pod template is left as is
+	dep, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "--config=podinfo-config-env", dep.Spec.Template.Spec.Containers[0].Args[0])
+	assert.Equal(t, "podinfo-config-env,podinfo-secret-env", dep.Spec.Template.Annotations["app/config"])
+}
+
+func TestConfigTracker_SecretProviderClass(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+
+	spc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "secrets-store.csi.x-k8s.io/v1",
+		"kind":       "SecretProviderClass",
+		"metadata":   map[string]interface{}{"name": "podinfo-vault", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"provider":   "vault",
+			"parameters": map[string]interface{}{"roleName": "podinfo"},
+			"secretObjects": []interface{}{
+				map[string]interface{}{"secretName": "podinfo-synced", "type": "Opaque"},
+			},
+		},
+	}}
+	dynamicClient := fakeDynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{SecretProviderClassGVR: "SecretProviderClassList"}, spc)
+	mocks.controller.configTracker.(*ConfigTracker).DynamicClient = dynamicClient
+
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	dep.Spec.Template.Spec.Volumes = append(dep.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name: "secrets-store",
+		VolumeSource: corev1.VolumeSource{
+			CSI: &corev1.CSIVolumeSource{
+				Driver:           "secrets-store.csi.k8s.io",
+				VolumeAttributes: map[string]string{"secretProviderClass": "podinfo-vault"},
+			},
+		},
+	})
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	mocks.initializeCanary(t)
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	volumes := depPrimary.Spec.Template.Spec.Volumes
+	assert.Equal(t, "podinfo-vault-primary", volumes[len(volumes)-1].CSI.VolumeAttributes["secretProviderClass"])
+
+	primarySpc, err := dynamicClient.Resource(SecretProviderClassGVR).Namespace("default").Get(context.TODO(), "podinfo-vault-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	provider, _, _ := unstructured.NestedString(primarySpc.Object, "spec", "provider")
+	assert.Equal(t, "vault", provider)
+	_, found, _ := unstructured.NestedSlice(primarySpc.Object, "spec", "secretObjects")
+	assert.False(t, found)
+
+	// the canary is not modified
+	dep, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	volumes = dep.Spec.Template.Spec.Volumes
+	assert.Equal(t, "podinfo-vault", volumes[len(volumes)-1].CSI.VolumeAttributes["secretProviderClass"])
+
+	// rotate the provider parameters
+	cd := mocks.canary.DeepCopy()
+	refs, err := mocks.controller.configTracker.GetConfigRefs(cd)
+	require.NoError(t, err)
+	assert.Contains(t, *refs, "secretproviderclass/podinfo-vault")
+	cd.Status.TrackedConfigs = refs
+
+	require.NoError(t, unstructured.SetNestedField(spc.Object, "podinfo-v2", "spec", "parameters", "roleName"))
+	_, err = dynamicClient.Resource(SecretProviderClassGVR).Namespace("default").Update(context.TODO(), spc, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err := mocks.controller.configTracker.HasConfigChanged(cd)
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestConfigTracker_TrackedObjects(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+
+	flag := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "flags.example.com/v1",
+		"kind":       "FeatureFlag",
+		"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "default"},
+		"spec":       map[string]interface{}{"enabled": false},
+	}}
+	gvr := schema.GroupVersionResource{Group: "flags.example.com", Version: "v1", Resource: "featureflags"}
+	dynamicClient := fakeDynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "FeatureFlagList"}, flag)
+	mocks.controller.configTracker.(*ConfigTracker).DynamicClient = dynamicClient
+
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.TrackedObjects = []flaggerv1.TrackedObjectReference{
+		{APIVersion: "flags.example.com/v1", Kind: "FeatureFlag", Name: "podinfo"},
+	}
+	refs, err := mocks.controller.configTracker.GetConfigRefs(cd)
+	require.NoError(t, err)
+	assert.Contains(t, *refs, "featureflags.flags.example.com/podinfo")
+	cd.Status.TrackedConfigs = refs
+
+	// the status changes are ignored
+	require.NoError(t, unstructured.SetNestedField(flag.Object, "Ready", "status", "phase"))
+	flag, err = dynamicClient.Resource(gvr).Namespace("default").Update(context.TODO(), flag, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err := mocks.controller.configTracker.HasConfigChanged(cd)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, unstructured.SetNestedField(flag.Object, true, "spec", "enabled"))
+	_, err = dynamicClient.Resource(gvr).Namespace("default").Update(context.TODO(), flag, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err = mocks.controller.configTracker.HasConfigChanged(cd)
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestConfigTracker_SecretGenerators(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+
+	esGVR := schema.GroupVersionResource{Group: "external-secrets.io", Version: "v1beta1", Resource: "externalsecrets"}
+	ssGVR := schema.GroupVersionResource{Group: "bitnami.com", Version: "v1alpha1", Resource: "sealedsecrets"}
+	es := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "external-secrets.io/v1beta1",
+		"kind":       "ExternalSecret",
+		"metadata":   map[string]interface{}{"name": "podinfo-es", "namespace": "default", "uid": "es-uid"},
+		"spec": map[string]interface{}{
+			"refreshInterval": "1h",
+			"target":          map[string]interface{}{"name": "podinfo-secret-env"},
+		},
+	}}
+	namespaceWide := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bitnami.com/v1alpha1",
+		"kind":       "SealedSecret",
+		"metadata": map[string]interface{}{
+			"name":        "podinfo-secret-all-env",
+			"namespace":   "default",
+			"uid":         "ss-uid",
+			"annotations": map[string]interface{}{"sealedsecrets.bitnami.com/namespace-wide": "true"},
+		},
+		"spec": map[string]interface{}{"encryptedData": map[string]interface{}{"apiKey": "AgBy3i4OJSWK"}},
+	}}
+	strict := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bitnami.com/v1alpha1",
+		"kind":       "SealedSecret",
+		"metadata":   map[string]interface{}{"name": "podinfo-secret-vol", "namespace": "default", "uid": "strict-uid"},
+		"spec":       map[string]interface{}{"encryptedData": map[string]interface{}{"apiKey": "AgBy3i4OJSWK"}},
+	}}
+	dynamicClient := fakeDynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{esGVR: "ExternalSecretList", ssGVR: "SealedSecretList"},
+		es, namespaceWide, strict)
+	mocks.controller.configTracker.(*ConfigTracker).DynamicClient = dynamicClient
+
+	for name, generator := range map[string]*unstructured.Unstructured{
+		"podinfo-secret-env":     es,
+		"podinfo-secret-all-env": namespaceWide,
+		"podinfo-secret-vol":     strict,
+	} {
+		secret, err := mocks.kubeClient.CoreV1().Secrets("default").Get(context.TODO(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		secret.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(generator, generator.GroupVersionKind())}
+		_, err = mocks.kubeClient.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+
+	mocks.initializeCanary(t)
+
+	// the ExternalSecret is copied with a primary target
+	primaryES, err := dynamicClient.Resource(esGVR).Namespace("default").Get(context.TODO(), "podinfo-es-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	target, _, _ := unstructured.NestedString(primaryES.Object, "spec", "target", "name")
+	assert.Equal(t, "podinfo-secret-env-primary", target)
+	refresh, _, _ := unstructured.NestedString(primaryES.Object, "spec", "refreshInterval")
+	assert.Equal(t, "1h", refresh)
+	_, err = mocks.kubeClient.CoreV1().Secrets("default").Get(context.TODO(), "podinfo-secret-env-primary", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// the namespace wide SealedSecret is copied under the primary secret name
+	primarySS, err := dynamicClient.Resource(ssGVR).Namespace("default").Get(context.TODO(), "podinfo-secret-all-env-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", primarySS.GetAnnotations()["sealedsecrets.bitnami.com/namespace-wide"])
+	_, err = mocks.kubeClient.CoreV1().Secrets("default").Get(context.TODO(), "podinfo-secret-all-env-primary", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// the strict SealedSecret can't be renamed and its secret data is copied
+	_, err = dynamicClient.Resource(ssGVR).Namespace("default").Get(context.TODO(), "podinfo-secret-vol-primary", metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = mocks.kubeClient.CoreV1().Secrets("default").Get(context.TODO(), "podinfo-secret-vol-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// the rotation of a generated secret doesn't trigger a new revision
+	cd := mocks.canary.DeepCopy()
+	refs, err := mocks.controller.configTracker.GetConfigRefs(cd)
+	require.NoError(t, err)
+	cd.Status.TrackedConfigs = refs
+
+	secret, err := mocks.kubeClient.CoreV1().Secrets("default").Get(context.TODO(), "podinfo-secret-env", metav1.GetOptions{})
+	require.NoError(t, err)
+	secret.Data = map[string][]byte{"apiKey": []byte("rotated")}
+	_, err = mocks.kubeClient.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err := mocks.controller.configTracker.HasConfigChanged(cd)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, unstructured.SetNestedField(es.Object, "30m", "spec", "refreshInterval"))
+	_, err = dynamicClient.Resource(esGVR).Namespace("default").Update(context.TODO(), es, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err = mocks.controller.configTracker.HasConfigChanged(cd)
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
+
 func TestConfigTracker_HasConfigChanged_ShouldReturnErrorWhenAPIServerIsDown(t *testing.T) {
 	t.Run("secret", func(t *testing.T) {
 		dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
@@ -373,6 +666,46 @@ func TestConfigTracker_HasConfigChanged_ShouldReturnErrorWhenAPIServerIsDown(t *
 	})
 }
 
+func TestConfigTracker_HasConfigChanged_IgnoresDisabledConfigs(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	cd := mocks.canary.DeepCopy()
+
+	refs, err := mocks.controller.configTracker.GetConfigRefs(cd)
+	require.NoError(t, err)
+	assert.NotContains(t, *refs, "configmap/podinfo-config-tracker-disabled")
+	assert.NotContains(t, *refs, "secret/podinfo-secret-tracker-disabled")
+	cd.Status.TrackedConfigs = refs
+
+	// rotate the configmap and the secret with the tracking disabled
+	configMap, err := mocks.kubeClient.CoreV1().ConfigMaps("default").Get(context.TODO(), "podinfo-config-tracker-disabled", metav1.GetOptions{})
+	require.NoError(t, err)
+	configMap.Data["color"] = "blue"
+	_, err = mocks.kubeClient.CoreV1().ConfigMaps("default").Update(context.TODO(), configMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	secret, err := mocks.kubeClient.CoreV1().Secrets("default").Get(context.TODO(), "podinfo-secret-tracker-disabled", metav1.GetOptions{})
+	require.NoError(t, err)
+	secret.Data["apiKey"] = []byte("rotated")
+	_, err = mocks.kubeClient.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err := mocks.controller.configTracker.HasConfigChanged(cd)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// rotate the tracked secret
+	secret, err = mocks.kubeClient.CoreV1().Secrets("default").Get(context.TODO(), "podinfo-secret-tracker-enabled", metav1.GetOptions{})
+	require.NoError(t, err)
+	secret.Data["apiKey"] = []byte("rotated")
+	_, err = mocks.kubeClient.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err = mocks.controller.configTracker.HasConfigChanged(cd)
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
+
 func Test_fieldIsMandatory(t *testing.T) {
 	falsy := false
 	truthy := true
@@ -17,6 +17,9 @@ limitations under the License.
 package canary
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
@@ -24,16 +27,24 @@ type Controller interface {
 	IsPrimaryReady(canary *flaggerv1.Canary) error
 	IsCanaryReady(canary *flaggerv1.Canary) (bool, error)
 	GetMetadata(canary *flaggerv1.Canary) (string, string, map[string]int32, error)
+	GetPodTemplate(canary *flaggerv1.Canary) (*corev1.PodTemplateSpec, error)
 	SyncStatus(canary *flaggerv1.Canary, status flaggerv1.CanaryStatus) error
 	SetStatusFailedChecks(canary *flaggerv1.Canary, val int) error
 	SetStatusWeight(canary *flaggerv1.Canary, val int) error
 	SetStatusIterations(canary *flaggerv1.Canary, val int) error
 	SetStatusPhase(canary *flaggerv1.Canary, phase flaggerv1.CanaryPhase) error
+	SetStatusFinalization(canary *flaggerv1.Canary, finalization []flaggerv1.CanaryFinalizationStatus) error
+	SetStatusMetrics(canary *flaggerv1.Canary, metrics []flaggerv1.CanaryMetricStatus) error
+	SetStatusFailures(canary *flaggerv1.Canary, failures []flaggerv1.CanaryCheckFailure) error
+	SetStatusWebhooks(canary *flaggerv1.Canary, webhooks []flaggerv1.CanaryWebhookStatus) error
+	SetStatusNextAnalysisTime(canary *flaggerv1.Canary, next metav1.Time) error
 	Initialize(canary *flaggerv1.Canary) error
 	Promote(canary *flaggerv1.Canary) error
 	HasTargetChanged(canary *flaggerv1.Canary) (bool, error)
 	HaveDependenciesChanged(canary *flaggerv1.Canary) (bool, error)
 	ScaleToZero(canary *flaggerv1.Canary) error
 	ScaleFromZero(canary *flaggerv1.Canary) error
+	RestorePromoted(canary *flaggerv1.Canary) (bool, error)
+	SyncPrimaryDrift(canary *flaggerv1.Canary) (bool, error)
 	Finalize(canary *flaggerv1.Canary) error
 }
@@ -0,0 +1,295 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// customWorkload holds the pod template of a custom workload
+// and the replicas and selector read from its scale subresource
+type customWorkload struct {
+	metadata           metav1.ObjectMeta
+	observedGeneration *int64
+	template           corev1.PodTemplateSpec
+	replicas           int32
+	statusReplicas     int32
+	selector           map[string]string
+}
+
+// deployment returns a view of the custom workload as a deployment, Flagger never writes it back
+func (w *customWorkload) deployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        w.metadata.Name,
+			Namespace:   w.metadata.Namespace,
+			Labels:      w.metadata.Labels,
+			Annotations: w.metadata.Annotations,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: int32p(w.replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: w.selector},
+			Template: w.template,
+		},
+	}
+}
+
+// customWorkloadResource returns the resource of the canary target from the targetWorkload hint
+func customWorkloadResource(cd *flaggerv1.Canary) (schema.GroupVersionResource, error) {
+	tw := cd.Spec.TargetWorkload
+	if tw == nil || tw.Resource == "" {
+		return schema.GroupVersionResource{}, fmt.Errorf("targetWorkload.resource is required for %s targets", cd.Spec.TargetRef.Kind)
+	}
+	gv, err := schema.ParseGroupVersion(cd.Spec.TargetRef.APIVersion)
+	if err != nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("targetRef.apiVersion %s is invalid: %w", cd.Spec.TargetRef.APIVersion, err)
+	}
+	return gv.WithResource(tw.Resource), nil
+}
+
+// getCustomWorkload reads the pod template of the custom workload at the targetWorkload path
+// and the replicas and the pod selector from its scale subresource
+func getCustomWorkload(dynamicClient dynamic.Interface, cd *flaggerv1.Canary) (*customWorkload, error) {
+	targetName := cd.Spec.TargetRef.Name
+	gvr, err := customWorkloadResource(cd)
+	if err != nil {
+		return nil, err
+	}
+	if dynamicClient == nil {
+		return nil, fmt.Errorf("%s %s.%s get query error: dynamic client not configured", gvr.Resource, targetName, cd.Namespace)
+	}
+
+	client := dynamicClient.Resource(gvr).Namespace(cd.Namespace)
+	obj, err := client.Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s %s.%s get query error: %w", gvr.Resource, targetName, cd.Namespace, err)
+	}
+
+	w := &customWorkload{
+		metadata: metav1.ObjectMeta{
+			Name:        obj.GetName(),
+			Namespace:   obj.GetNamespace(),
+			Labels:      obj.GetLabels(),
+			Annotations: obj.GetAnnotations(),
+			Generation:  obj.GetGeneration(),
+		},
+	}
+	if observed, ok, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); ok {
+		w.observedGeneration = &observed
+	}
+
+	path := cd.Spec.TargetWorkload.GetPodTemplatePath()
+	template, ok, err := unstructured.NestedMap(obj.Object, path...)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("%s %s.%s has no pod template at %v", gvr.Resource, targetName, cd.Namespace, path)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(template, &w.template); err != nil {
+		return nil, fmt.Errorf("%s %s.%s pod template decoding failed: %w", gvr.Resource, targetName, cd.Namespace, err)
+	}
+
+	scale, err := client.Get(context.TODO(), targetName, metav1.GetOptions{}, "scale")
+	if err != nil {
+		return nil, fmt.Errorf("%s %s.%s scale query error: %w", gvr.Resource, targetName, cd.Namespace, err)
+	}
+	replicas, _, _ := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	statusReplicas, _, _ := unstructured.NestedInt64(scale.Object, "status", "replicas")
+	w.replicas, w.statusReplicas = int32(replicas), int32(statusReplicas)
+
+	selector, _, _ := unstructured.NestedString(scale.Object, "status", "selector")
+	if w.selector, err = labels.ConvertSelectorToLabelsMap(selector); err != nil || len(w.selector) == 0 {
+		return nil, fmt.Errorf("%s %s.%s scale selector %q must be a set of label matches", gvr.Resource, targetName, cd.Namespace, selector)
+	}
+	return w, nil
+}
+
+// CustomWorkloadController is managing the operations for the custom workloads that implement
+// the scale subresource, the pod template is read at the path of the targetWorkload hint
+// and Flagger creates a primary deployment from it, the custom workload is only scaled
+type CustomWorkloadController struct {
+	*DeploymentController
+}
+
+// Initialize creates the primary deployment from the custom workload pod template
+// and scales the custom workload to zero
+func (c *CustomWorkloadController) Initialize(cd *flaggerv1.Canary) error {
+	if cd.Spec.AutoscalerRef != nil {
+		return fmt.Errorf("autoscalerRef is not supported for %s targets", cd.Spec.TargetRef.Kind)
+	}
+
+	w, err := getCustomWorkload(c.dynamicClient, cd)
+	if err != nil {
+		return err
+	}
+
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	_, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if err := c.createPrimaryDeploymentFrom(cd, w.deployment(), c.includeLabelPrefix); err != nil {
+			return fmt.Errorf("createPrimaryDeployment failed: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	if cd.Status.Phase == "" || cd.Status.Phase == flaggerv1.CanaryPhaseInitializing {
+		if !cd.SkipAnalysis() {
+			if err := c.IsPrimaryReady(cd); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("Scaling down %s %s.%s", cd.Spec.TargetRef.Kind, cd.Spec.TargetRef.Name, cd.Namespace)
+		if err := c.ScaleToZero(cd); err != nil {
+			return fmt.Errorf("scaling down canary %s %s.%s failed: %w", cd.Spec.TargetRef.Kind, cd.Spec.TargetRef.Name, cd.Namespace, err)
+		}
+	}
+	return nil
+}
+
+// Promote copies the custom workload pod template, secrets and config maps to the primary deployment
+func (c *CustomWorkloadController) Promote(cd *flaggerv1.Canary) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		w, err := getCustomWorkload(c.dynamicClient, cd)
+		if err != nil {
+			return err
+		}
+		return c.promoteDeployment(cd, w.deployment())
+	})
+	if err != nil {
+		return fmt.Errorf("updating deployment %s.%s template spec failed: %w",
+			primaryName, cd.Namespace, err)
+	}
+	return nil
+}
+
+// GetPodTemplate returns the pod template of the custom workload
+func (c *CustomWorkloadController) GetPodTemplate(cd *flaggerv1.Canary) (*corev1.PodTemplateSpec, error) {
+	w, err := getCustomWorkload(c.dynamicClient, cd)
+	if err != nil {
+		return nil, err
+	}
+	return &w.template, nil
+}
+
+// HasTargetChanged returns true if the custom workload pod template has changed
+func (c *CustomWorkloadController) HasTargetChanged(cd *flaggerv1.Canary) (bool, error) {
+	w, err := getCustomWorkload(c.dynamicClient, cd)
+	if err != nil {
+		return false, err
+	}
+	return hasSpecChanged(cd, hashedPodTemplate(cd, w.template))
+}
+
+// ScaleToZero sets the custom workload replicas to zero
+func (c *CustomWorkloadController) ScaleToZero(cd *flaggerv1.Canary) error {
+	return c.scale(cd, 0)
+}
+
+// ScaleFromZero sets the custom workload replicas to the primary replicas
+func (c *CustomWorkloadController) ScaleFromZero(cd *flaggerv1.Canary) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	replicas := int32(1)
+	if primary.Spec.Replicas != nil && *primary.Spec.Replicas > 0 {
+		replicas = *primary.Spec.Replicas
+	}
+	return c.scale(cd, replicas)
+}
+
+// scale patches the replicas of the custom workload scale subresource
+func (c *CustomWorkloadController) scale(cd *flaggerv1.Canary, replicas int32) error {
+	gvr, err := customWorkloadResource(cd)
+	if err != nil {
+		return err
+	}
+	if c.dynamicClient == nil {
+		return fmt.Errorf("%s %s.%s scaling failed: dynamic client not configured", gvr.Resource, cd.Spec.TargetRef.Name, cd.Namespace)
+	}
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+	_, err = c.dynamicClient.Resource(gvr).Namespace(cd.Namespace).Patch(context.TODO(),
+		cd.Spec.TargetRef.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: cd.FieldManager()}, "scale")
+	if err != nil {
+		return fmt.Errorf("scaling %s %s.%s to %v failed: %w", gvr.Resource, cd.Spec.TargetRef.Name, cd.Namespace, replicas, err)
+	}
+	return nil
+}
+
+// GetMetadata returns the pod label selector and svc ports
+func (c *CustomWorkloadController) GetMetadata(cd *flaggerv1.Canary) (string, string, map[string]int32, error) {
+	w, err := getCustomWorkload(c.dynamicClient, cd)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	label, labelValue, err := c.getSelectorLabel(w.deployment())
+	if err != nil {
+		return "", "", nil, fmt.Errorf("getSelectorLabel failed: %w", err)
+	}
+
+	var ports map[string]int32
+	if cd.Spec.Service.PortDiscovery {
+		ports = getPorts(cd, w.template.Spec.Containers)
+	}
+	return label, labelValue, ports, nil
+}
+
+// Finalize scales the custom workload to the primary replicas
+func (c *CustomWorkloadController) Finalize(cd *flaggerv1.Canary) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return c.scale(cd, 1)
+		}
+		return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+	return c.scale(cd, int32Default(primary.Spec.Replicas))
+}
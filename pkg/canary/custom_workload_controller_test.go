@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestCustomWorkloadController_Initialize(t *testing.T) {
+	mocks := newCustomWorkloadFixture()
+	mocks.initializeCanary(t)
+
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "podinfo-primary", primary.Spec.Selector.MatchLabels["app"])
+	assert.Equal(t, "podinfo-primary", primary.Spec.Template.Labels["app"])
+	assert.Equal(t, int32(2), *primary.Spec.Replicas)
+	assert.Equal(t, "podinfo-config-env-primary", primary.Spec.Template.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Name)
+
+	// only the replicas of the custom workload are changed
+	cs := mocks.getCloneSet(t)
+	replicas, _, _ := unstructured.NestedInt64(cs.Object, "spec", "replicas")
+	assert.Equal(t, int64(0), replicas)
+	strategy, _, _ := unstructured.NestedString(cs.Object, "spec", "updateStrategy", "type")
+	assert.Equal(t, "InPlaceIfPossible", strategy)
+
+	label, labelValue, _, err := mocks.controller.GetMetadata(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, "app", label)
+	assert.Equal(t, "podinfo", labelValue)
+}
+
+func TestCustomWorkloadController_PodTemplatePath(t *testing.T) {
+	mocks := newCustomWorkloadFixture()
+	mocks.canary.Spec.TargetWorkload.PodTemplatePath = "spec.workload.template"
+	_, err := mocks.controller.GetPodTemplate(mocks.canary)
+	require.Error(t, err)
+
+	cs := mocks.getCloneSet(t)
+	template, _, _ := unstructured.NestedMap(cs.Object, "spec", "template")
+	require.NoError(t, unstructured.SetNestedMap(cs.Object, template, "spec", "workload", "template"))
+	_, err = mocks.dynamicClient.Resource(cloneSetGVR).Namespace("default").Update(context.TODO(), cs, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	pt, err := mocks.controller.GetPodTemplate(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:1.2.0", pt.Spec.Containers[0].Image)
+}
+
+func TestCustomWorkloadController_Promote(t *testing.T) {
+	mocks := newCustomWorkloadFixture()
+	mocks.initializeCanary(t)
+	require.NoError(t, mocks.controller.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitialized}))
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	cs := mocks.getCloneSet(t)
+	containers, _, _ := unstructured.NestedSlice(cs.Object, "spec", "template", "spec", "containers")
+	containers[0].(map[string]interface{})["image"] = "quay.io/stefanprodan/podinfo:1.2.1"
+	require.NoError(t, unstructured.SetNestedSlice(cs.Object, containers, "spec", "template", "spec", "containers"))
+	_, err = mocks.dynamicClient.Resource(cloneSetGVR).Namespace("default").Update(context.TODO(), cs, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err := mocks.controller.HasTargetChanged(cd)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	require.NoError(t, mocks.controller.ScaleFromZero(cd))
+	replicas, _, _ := unstructured.NestedInt64(mocks.getCloneSet(t).Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas)
+
+	require.NoError(t, mocks.controller.Promote(cd))
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:1.2.1", primary.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestCustomWorkloadController_IsCanaryReady(t *testing.T) {
+	pod := func(name string, ready corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "podinfo"}},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+			},
+		}
+	}
+
+	mocks := newCustomWorkloadFixture(pod("podinfo-a", corev1.ConditionTrue), pod("podinfo-b", corev1.ConditionFalse))
+	retryable, err := mocks.controller.IsCanaryReady(mocks.canary)
+	require.Error(t, err)
+	assert.True(t, retryable)
+
+	threshold := 50
+	mocks.canary.Spec.Analysis = &flaggerv1.CanaryAnalysis{CanaryReadyThreshold: &threshold}
+	_, err = mocks.controller.IsCanaryReady(mocks.canary)
+	require.NoError(t, err)
+
+	mocks.canary.Status.LastTransitionTime = metav1.NewTime(metav1.Now().Add(-time.Hour))
+	mocks.canary.Spec.Analysis.CanaryReadyThreshold = nil
+	retryable, err = mocks.controller.IsCanaryReady(mocks.canary)
+	require.Error(t, err)
+	assert.False(t, retryable)
+}
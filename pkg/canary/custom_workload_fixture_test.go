@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
+	fakeFlagger "github.com/fluxcd/flagger/pkg/client/clientset/versioned/fake"
+	"github.com/fluxcd/flagger/pkg/logger"
+)
+
+var cloneSetGVR = schema.GroupVersionResource{Group: "apps.kruise.io", Version: "v1alpha1", Resource: "clonesets"}
+
+type customWorkloadControllerFixture struct {
+	canary        *flaggerv1.Canary
+	kubeClient    kubernetes.Interface
+	dynamicClient *fakeDynamic.FakeDynamicClient
+	flaggerClient clientset.Interface
+	controller    CustomWorkloadController
+}
+
+func (f customWorkloadControllerFixture) initializeCanary(t *testing.T) {
+	err := f.controller.Initialize(f.canary)
+	require.Error(t, err) // not ready yet
+
+	p, err := f.kubeClient.AppsV1().Deployments(f.canary.Namespace).Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	p.Status = appsv1.DeploymentStatus{
+		Replicas:          2,
+		UpdatedReplicas:   2,
+		ReadyReplicas:     2,
+		AvailableReplicas: 2,
+	}
+	_, err = f.kubeClient.AppsV1().Deployments(f.canary.Namespace).Update(context.TODO(), p, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, f.controller.Initialize(f.canary))
+}
+
+// getCloneSet returns the custom workload stored by the dynamic client
+func (f customWorkloadControllerFixture) getCloneSet(t *testing.T) *unstructured.Unstructured {
+	obj, err := f.dynamicClient.Resource(cloneSetGVR).Namespace(f.canary.Namespace).Get(context.TODO(), f.canary.Spec.TargetRef.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	return obj
+}
+
+func newCustomWorkloadFixture(objects ...runtime.Object) customWorkloadControllerFixture {
+	canary := newCustomWorkloadControllerTestCanary()
+	flaggerClient := fakeFlagger.NewSimpleClientset(canary)
+	kubeClient := fake.NewSimpleClientset(append([]runtime.Object{
+		newDaemonSetControllerTestConfigMap(),
+		newDaemonSetControllerTestSecret(),
+	}, objects...)...)
+	// the fake client returns the whole object for subresources, the scale is built
+	// from the object like the API server does
+	scheme := runtime.NewScheme()
+	tracker := k8stesting.NewObjectTracker(scheme, serializer.NewCodecFactory(scheme).UniversalDecoder())
+	_ = tracker.Add(newCustomWorkloadControllerTestPodInfo())
+	dynamicClient := fakeDynamic.NewSimpleDynamicClient(scheme)
+	dynamicClient.PrependReactor("*", "*", k8stesting.ObjectReaction(tracker))
+	dynamicClient.PrependReactor("get", "clonesets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		obj, err := tracker.Get(cloneSetGVR, action.GetNamespace(), action.(k8stesting.GetAction).GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		u := obj.(*unstructured.Unstructured)
+		replicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+		statusReplicas, _, _ := unstructured.NestedInt64(u.Object, "status", "replicas")
+		return true, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "autoscaling/v1",
+			"kind":       "Scale",
+			"metadata":   map[string]interface{}{"name": u.GetName(), "namespace": u.GetNamespace()},
+			"spec":       map[string]interface{}{"replicas": replicas},
+			"status":     map[string]interface{}{"replicas": statusReplicas, "selector": "app=podinfo"},
+		}}, nil
+	})
+
+	logger, _ := logger.NewLogger("debug")
+	ctrl := CustomWorkloadController{
+		DeploymentController: &DeploymentController{
+			flaggerClient: flaggerClient,
+			kubeClient:    kubeClient,
+			dynamicClient: dynamicClient,
+			logger:        logger,
+			labels:        []string{"app", "name"},
+			configTracker: &ConfigTracker{
+				Logger:        logger,
+				KubeClient:    kubeClient,
+				DynamicClient: dynamicClient,
+				FlaggerClient: flaggerClient,
+			},
+		},
+	}
+
+	return customWorkloadControllerFixture{
+		canary:        canary,
+		controller:    ctrl,
+		flaggerClient: flaggerClient,
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+	}
+}
+
+func newCustomWorkloadControllerTestCanary() *flaggerv1.Canary {
+	return &flaggerv1.Canary{
+		TypeMeta: metav1.TypeMeta{APIVersion: flaggerv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "podinfo",
+		},
+		Spec: flaggerv1.CanarySpec{
+			TargetRef: flaggerv1.LocalObjectReference{
+				Name:       "podinfo",
+				APIVersion: "apps.kruise.io/v1alpha1",
+				Kind:       "CloneSet",
+			},
+			TargetWorkload: &flaggerv1.TargetWorkload{
+				Resource: "clonesets",
+			},
+			Analysis: &flaggerv1.CanaryAnalysis{},
+		},
+	}
+}
+
+func newCustomWorkloadControllerTestPodInfo() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps.kruise.io/v1alpha1",
+		"kind":       "CloneSet",
+		"metadata": map[string]interface{}{
+			"namespace":  "default",
+			"name":       "podinfo",
+			"generation": int64(1),
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "podinfo"},
+			},
+			"template": newRolloutControllerTestTemplate(),
+			"updateStrategy": map[string]interface{}{
+				"type": "InPlaceIfPossible",
+			},
+		},
+		"status": map[string]interface{}{
+			"observedGeneration": int64(1),
+			"replicas":           int64(2),
+		},
+	}}
+}
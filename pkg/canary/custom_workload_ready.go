@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// IsCanaryReady checks the custom workload pods and returns an error if
+// the workload is in the middle of an update or if the pods are unhealthy
+func (c *CustomWorkloadController) IsCanaryReady(cd *flaggerv1.Canary) (bool, error) {
+	w, err := getCustomWorkload(c.dynamicClient, cd)
+	if err != nil {
+		return true, err
+	}
+
+	retryable, err := c.isCustomWorkloadReady(cd, w, cd.GetAnalysisCanaryReadyThreshold())
+	if err != nil {
+		return retryable, fmt.Errorf("canary %s %s.%s not ready: %w",
+			cd.Spec.TargetRef.Kind, cd.Spec.TargetRef.Name, cd.Namespace, err)
+	}
+	return true, nil
+}
+
+// isCustomWorkloadReady determines if a custom workload is ready by counting the ready pods
+// matching the scale selector, the observed generation is checked when the workload reports it
+// and the progress deadline is counted from the last transition of the canary
+func (c *CustomWorkloadController) isCustomWorkloadReady(cd *flaggerv1.Canary, w *customWorkload, readyThreshold int) (bool, error) {
+	if w.observedGeneration != nil && w.metadata.Generation > *w.observedGeneration {
+		return true, fmt.Errorf("waiting for rollout to finish: observed generation less than desired generation")
+	}
+
+	pods, err := c.kubeClient.CoreV1().Pods(cd.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(w.selector).String(),
+	})
+	if err != nil {
+		return true, fmt.Errorf("pods list query error: %w", err)
+	}
+	readyPods := int32(0)
+	for i := range pods.Items {
+		if pod := &pods.Items[i]; pod.DeletionTimestamp == nil && isPodReady(pod) {
+			readyPods++
+		}
+	}
+
+	readyThresholdRatio := float32(readyThreshold) / float32(100)
+	readyThresholdReplicas := int32(float32(w.replicas) * readyThresholdRatio)
+
+	switch {
+	case w.statusReplicas > w.replicas:
+		err = fmt.Errorf("waiting for rollout to finish: %d old pods are pending termination",
+			w.statusReplicas-w.replicas)
+	case readyPods < readyThresholdReplicas:
+		err = fmt.Errorf("waiting for rollout to finish: %d of %d (readyThreshold %d%%) pods are ready",
+			readyPods, readyThresholdReplicas, readyThreshold)
+	}
+	if err == nil {
+		return true, nil
+	}
+
+	// check if deadline exceeded
+	from := cd.Status.LastTransitionTime
+	delta := time.Duration(cd.GetProgressDeadlineSeconds()) * time.Second
+	if !from.IsZero() && from.Add(delta).Before(time.Now()) {
+		return false, fmt.Errorf("exceeded its progressDeadlineSeconds: %d", cd.GetProgressDeadlineSeconds())
+	}
+	return true, err
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -65,14 +65,25 @@ func (c *DaemonSetController) ScaleToZero(cd *flaggerv1.Canary) error {
 		daeCopy.Spec.Template.Spec.NodeSelector[k] = v
 	}
 
-	_, err = c.kubeClient.AppsV1().DaemonSets(dae.Namespace).Update(context.TODO(), daeCopy, metav1.UpdateOptions{})
+	_, err = c.kubeClient.AppsV1().DaemonSets(dae.Namespace).Update(context.TODO(), daeCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("updating daemonset %s.%s failed: %w", daeCopy.GetName(), daeCopy.Namespace, err)
 	}
+
+	// release the nodes assigned to the canary
+	if err := c.partitionNodes(cd, 0); err != nil {
+		return fmt.Errorf("partitionNodes failed: %w", err)
+	}
 	return nil
 }
 
+// ScaleFromZero removes the scale down node selector from the canary DaemonSet,
+// when the node partitioning is enabled the canary is restricted to the nodes assigned to it
 func (c *DaemonSetController) ScaleFromZero(cd *flaggerv1.Canary) error {
+	return c.scaleFromZero(cd, nodePartitionSelector(cd))
+}
+
+func (c *DaemonSetController) scaleFromZero(cd *flaggerv1.Canary, nodeSelector map[string]string) error {
 	targetName := cd.Spec.TargetRef.Name
 	dep, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
 	if err != nil {
@@ -83,8 +94,17 @@ func (c *DaemonSetController) ScaleFromZero(cd *flaggerv1.Canary) error {
 	for k := range daemonSetScaleDownNodeSelector {
 		delete(depCopy.Spec.Template.Spec.NodeSelector, k)
 	}
+	if cd.Spec.NodePartition != nil {
+		delete(depCopy.Spec.Template.Spec.NodeSelector, cd.GetNodePartitionLabel())
+	}
+	if len(nodeSelector) > 0 && depCopy.Spec.Template.Spec.NodeSelector == nil {
+		depCopy.Spec.Template.Spec.NodeSelector = make(map[string]string, len(nodeSelector))
+	}
+	for k, v := range nodeSelector {
+		depCopy.Spec.Template.Spec.NodeSelector[k] = v
+	}
 
-	_, err = c.kubeClient.AppsV1().DaemonSets(dep.Namespace).Update(context.TODO(), depCopy, metav1.UpdateOptions{})
+	_, err = c.kubeClient.AppsV1().DaemonSets(dep.Namespace).Update(context.TODO(), depCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("scaling up daemonset %s.%s failed: %w", depCopy.GetName(), depCopy.Namespace, err)
 	}
@@ -118,19 +138,25 @@ func (c *DaemonSetController) Initialize(cd *flaggerv1.Canary) (err error) {
 // Promote copies the pod spec, secrets and config maps from canary to primary
 func (c *DaemonSetController) Promote(cd *flaggerv1.Canary) error {
 	targetName := cd.Spec.TargetRef.Name
-	primaryName := fmt.Sprintf("%s-primary", targetName)
+	primaryName, err := cd.GetPrimaryName(targetName)
+	if err != nil {
+		return err
+	}
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		canary, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
 		if err != nil {
 			return fmt.Errorf("damonset %s.%s get query error: %v", targetName, cd.Namespace, err)
 		}
 
 		label, labelValue, err := c.getSelectorLabel(canary)
-		primaryLabelValue := fmt.Sprintf("%s-primary", labelValue)
 		if err != nil {
 			return fmt.Errorf("getSelectorLabel failed: %w", err)
 		}
+		primaryLabelValue, err := cd.GetPrimaryLabelValue(labelValue)
+		if err != nil {
+			return err
+		}
 
 		primary, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 		if err != nil {
@@ -152,15 +178,27 @@ func (c *DaemonSetController) Promote(cd *flaggerv1.Canary) error {
 		primaryCopy.Spec.UpdateStrategy = canary.Spec.UpdateStrategy
 
 		// update spec with primary secrets and config maps
-		primaryCopy.Spec.Template.Spec = c.configTracker.ApplyPrimaryConfigs(canary.Spec.Template.Spec, configRefs)
+		primarySpec, err := c.configTracker.ApplyPrimaryConfigs(cd, primaryPodSpec(cd, canary.Spec.Template.Spec), configRefs)
+		if err != nil {
+			return fmt.Errorf("ApplyPrimaryConfigs failed: %w", err)
+		}
+		primaryCopy.Spec.Template.Spec = primarySpec
 
-		// ignore `daemonSetScaleDownNodeSelector` node selector
+		// ignore `daemonSetScaleDownNodeSelector` and node partition selectors
 		for key := range daemonSetScaleDownNodeSelector {
 			delete(primaryCopy.Spec.Template.Spec.NodeSelector, key)
 		}
+		if cd.Spec.NodePartition != nil {
+			delete(primaryCopy.Spec.Template.Spec.NodeSelector, cd.GetNodePartitionLabel())
+			primaryCopy.Spec.Template.Spec = withNodePartitionAffinity(cd, primaryCopy.Spec.Template.Spec)
+		}
 
 		// update pod annotations to ensure a rolling update
-		annotations, err := makeAnnotations(canary.Spec.Template.Annotations)
+		configAnnotations, err := primaryConfigAnnotations(cd, canary.Spec.Template.Annotations, configRefs)
+		if err != nil {
+			return fmt.Errorf("primaryConfigAnnotations failed: %w", err)
+		}
+		annotations, err := makeAnnotations(configAnnotations)
 		if err != nil {
 			return fmt.Errorf("makeAnnotations failed: %w", err)
 		}
@@ -170,19 +208,20 @@ func (c *DaemonSetController) Promote(cd *flaggerv1.Canary) error {
 
 		// update ds annotations
 		primaryCopy.ObjectMeta.Annotations = make(map[string]string)
-		filteredAnnotations := includeLabelsByPrefix(canary.ObjectMeta.Annotations, c.includeLabelPrefix)
+		filteredAnnotations := propagateMetadata(cd.GetAnnotationPropagation(), canary.ObjectMeta.Annotations, includeLabelsByPrefix(canary.ObjectMeta.Annotations, c.includeLabelPrefix))
 		for k, v := range filteredAnnotations {
 			primaryCopy.ObjectMeta.Annotations[k] = v
 		}
 		// update ds labels
 		primaryCopy.ObjectMeta.Labels = make(map[string]string)
-		filteredLabels := includeLabelsByPrefix(canary.ObjectMeta.Labels, c.includeLabelPrefix)
+		filteredLabels := propagateMetadata(cd.GetLabelPropagation(), canary.ObjectMeta.Labels, includeLabelsByPrefix(canary.ObjectMeta.Labels, c.includeLabelPrefix))
 		for k, v := range filteredLabels {
 			primaryCopy.ObjectMeta.Labels[k] = v
 		}
+		primaryCopy.ObjectMeta.Labels = makeAuditLabels(cd, primaryCopy.ObjectMeta.Labels)
 
 		// apply update
-		_, err = c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{})
+		_, err = c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 		return err
 	})
 	if err != nil {
@@ -193,6 +232,54 @@ func (c *DaemonSetController) Promote(cd *flaggerv1.Canary) error {
 	return nil
 }
 
+// RestorePromoted sets the pod template of the last promoted revision on the primary daemonset,
+// it returns true if the primary had drifted from the promoted revision
+func (c *DaemonSetController) RestorePromoted(cd *flaggerv1.Canary) (bool, error) {
+	if cd.Status.LastPromotedSpec == "" {
+		return false, nil
+	}
+
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return false, err
+	}
+	restored := false
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		primary, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("daemonset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+		}
+
+		primaryCopy := primary.DeepCopy()
+		restored, err = restorePromotedTemplate(c.kubeClient, cd, &primaryCopy.Spec.Template)
+		if err != nil || !restored {
+			return err
+		}
+
+		_, err = c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("restoring daemonset %s.%s template failed: %w", primaryName, cd.Namespace, err)
+	}
+	return restored, nil
+}
+
+// SyncPrimaryDrift is a no-op as the drift detection is supported only for the Deployment targets
+func (c *DaemonSetController) SyncPrimaryDrift(_ *flaggerv1.Canary) (bool, error) {
+	return false, nil
+}
+
+// GetPodTemplate returns the pod template of the canary DaemonSet
+func (c *DaemonSetController) GetPodTemplate(cd *flaggerv1.Canary) (*corev1.PodTemplateSpec, error) {
+	targetName := cd.Spec.TargetRef.Name
+	canary, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("daemonset %s.%s get query error: %w", targetName, cd.Namespace, err)
+	}
+	return &canary.Spec.Template, nil
+}
+
 // HasTargetChanged returns true if the canary DaemonSet pod spec has changed
 func (c *DaemonSetController) HasTargetChanged(cd *flaggerv1.Canary) (bool, error) {
 	targetName := cd.Spec.TargetRef.Name
@@ -201,17 +288,20 @@ func (c *DaemonSetController) HasTargetChanged(cd *flaggerv1.Canary) (bool, erro
 		return false, fmt.Errorf("daemonset %s.%s get query error: %w", targetName, cd.Namespace, err)
 	}
 
-	// ignore `daemonSetScaleDownNodeSelector` node selector
+	// ignore `daemonSetScaleDownNodeSelector` and node partition selectors
 	for key := range daemonSetScaleDownNodeSelector {
 		delete(canary.Spec.Template.Spec.NodeSelector, key)
 	}
+	if cd.Spec.NodePartition != nil {
+		delete(canary.Spec.Template.Spec.NodeSelector, cd.GetNodePartitionLabel())
+	}
 
 	// since nil and capacity zero map would have different hash, we have to initialize here
 	if canary.Spec.Template.Spec.NodeSelector == nil {
 		canary.Spec.Template.Spec.NodeSelector = map[string]string{}
 	}
 
-	return hasSpecChanged(cd, canary.Spec.Template)
+	return hasSpecChanged(cd, hashedPodTemplate(cd, canary.Spec.Template))
 }
 
 // GetMetadata returns the pod label selector and svc ports
@@ -237,7 +327,10 @@ func (c *DaemonSetController) GetMetadata(cd *flaggerv1.Canary) (string, string,
 
 func (c *DaemonSetController) createPrimaryDaemonSet(cd *flaggerv1.Canary, includeLabelPrefix []string) error {
 	targetName := cd.Spec.TargetRef.Name
-	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
 
 	canaryDae, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
 	if err != nil {
@@ -251,13 +344,16 @@ func (c *DaemonSetController) createPrimaryDaemonSet(cd *flaggerv1.Canary, inclu
 	}
 
 	// Create the labels map but filter unwanted labels
-	labels := includeLabelsByPrefix(canaryDae.Labels, includeLabelPrefix)
+	labels := propagateMetadata(cd.GetLabelPropagation(), canaryDae.Labels, includeLabelsByPrefix(canaryDae.Labels, includeLabelPrefix))
 
 	label, labelValue, err := c.getSelectorLabel(canaryDae)
-	primaryLabelValue := fmt.Sprintf("%s-primary", labelValue)
 	if err != nil {
 		return fmt.Errorf("getSelectorLabel failed: %w", err)
 	}
+	primaryLabelValue, err := cd.GetPrimaryLabelValue(labelValue)
+	if err != nil {
+		return err
+	}
 
 	primaryDae, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
@@ -269,18 +365,26 @@ func (c *DaemonSetController) createPrimaryDaemonSet(cd *flaggerv1.Canary, inclu
 		if err := c.configTracker.CreatePrimaryConfigs(cd, configRefs, c.includeLabelPrefix); err != nil {
 			return fmt.Errorf("CreatePrimaryConfigs failed: %w", err)
 		}
-		annotations, err := makeAnnotations(canaryDae.Spec.Template.Annotations)
+		configAnnotations, err := primaryConfigAnnotations(cd, canaryDae.Spec.Template.Annotations, configRefs)
+		if err != nil {
+			return fmt.Errorf("primaryConfigAnnotations failed: %w", err)
+		}
+		annotations, err := makeAnnotations(configAnnotations)
 		if err != nil {
 			return fmt.Errorf("makeAnnotations failed: %w", err)
 		}
+		primarySpec, err := c.configTracker.ApplyPrimaryConfigs(cd, primaryPodSpec(cd, canaryDae.Spec.Template.Spec), configRefs)
+		if err != nil {
+			return fmt.Errorf("ApplyPrimaryConfigs failed: %w", err)
+		}
 
 		// create primary daemonset
 		primaryDae = &appsv1.DaemonSet{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:        primaryName,
 				Namespace:   cd.Namespace,
-				Labels:      makePrimaryLabels(labels, primaryLabelValue, label),
-				Annotations: filterMetadata(canaryDae.Annotations),
+				Labels:      makeAuditLabels(cd, makePrimaryLabels(labels, primaryLabelValue, label)),
+				Annotations: propagateMetadata(cd.GetAnnotationPropagation(), canaryDae.Annotations, filterMetadata(canaryDae.Annotations)),
 				OwnerReferences: []metav1.OwnerReference{
 					*metav1.NewControllerRef(cd, schema.GroupVersionKind{
 						Group:   flaggerv1.SchemeGroupVersion.Group,
@@ -303,13 +407,14 @@ func (c *DaemonSetController) createPrimaryDaemonSet(cd *flaggerv1.Canary, inclu
 						Labels:      makePrimaryLabels(canaryDae.Spec.Template.Labels, primaryLabelValue, label),
 						Annotations: annotations,
 					},
-					// update spec with the primary secrets and config maps
-					Spec: c.configTracker.ApplyPrimaryConfigs(canaryDae.Spec.Template.Spec, configRefs),
+					// update spec with the primary secrets and config maps,
+					// and keep the primary away from the nodes assigned to the canary
+					Spec: withNodePartitionAffinity(cd, primarySpec),
 				},
 			},
 		}
 
-		_, err = c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Create(context.TODO(), primaryDae, metav1.CreateOptions{})
+		_, err = c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Create(context.TODO(), primaryDae, metav1.CreateOptions{FieldManager: cd.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("creating daemonset %s.%s failed: %w", primaryDae.Name, cd.Namespace, err)
 		}
@@ -339,8 +444,12 @@ func (c *DaemonSetController) HaveDependenciesChanged(cd *flaggerv1.Canary) (boo
 
 //Finalize scale the reference instance from zero
 func (c *DaemonSetController) Finalize(cd *flaggerv1.Canary) error {
-	if err := c.ScaleFromZero(cd); err != nil {
+	// the reference instance runs on all the nodes again
+	if err := c.scaleFromZero(cd, nil); err != nil {
 		return fmt.Errorf("ScaleFromZero failed: %w", err)
 	}
+	if err := c.partitionNodes(cd, 0); err != nil {
+		return fmt.Errorf("partitionNodes failed: %w", err)
+	}
 	return nil
 }
@@ -19,6 +19,7 @@ package canary
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -268,3 +269,88 @@ func TestDaemonSetController_Finalize(t *testing.T) {
 	_, ok := dep.Spec.Template.Spec.NodeSelector["flagger.app/scale-to-zero"]
 	assert.False(t, ok)
 }
+
+func TestDaemonSetController_NodePartition(t *testing.T) {
+	dc := daemonsetConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDaemonSetFixture(dc)
+	mocks.canary.Spec.NodePartition = &flaggerv1.NodePartition{NodeSelector: map[string]string{"pool": "agents"}}
+	for _, name := range []string{"node-d", "node-a", "node-c", "node-b", "node-e"} {
+		pool := "agents"
+		if name == "node-e" {
+			pool = "system"
+		}
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"pool": pool}}}
+		_, err := mocks.kubeClient.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	// the default label key is unique across namespaces
+	label := mocks.canary.GetNodePartitionLabel()
+	other := mocks.canary.DeepCopy()
+	other.Namespace = "test"
+	assert.NotEqual(t, label, other.GetNodePartitionLabel())
+	assert.LessOrEqual(t, len(strings.TrimPrefix(label, "canary.flagger.app/")), 63)
+
+	err := mocks.controller.Initialize(mocks.canary)
+	require.NoError(t, err)
+
+	// the primary is kept away from the canary nodes
+	daePrimary, err := mocks.kubeClient.AppsV1().DaemonSets("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	terms := daePrimary.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	require.Len(t, terms, 1)
+	assert.Equal(t, []corev1.NodeSelectorRequirement{{
+		Key:      label,
+		Operator: corev1.NodeSelectorOpNotIn,
+		Values:   []string{flaggerv1.NodePartitionLabelValue},
+	}}, terms[0].MatchExpressions)
+
+	// save last applied hash
+	err = mocks.controller.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitialized})
+	require.NoError(t, err)
+	canary, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	mocks.canary.Status = canary.Status
+
+	// the canary is restricted to the assigned nodes
+	err = mocks.controller.ScaleFromZero(mocks.canary)
+	require.NoError(t, err)
+	dae, err := mocks.kubeClient.AppsV1().DaemonSets("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{label: flaggerv1.NodePartitionLabelValue}, dae.Spec.Template.Spec.NodeSelector)
+
+	isChanged, err := mocks.controller.HasTargetChanged(mocks.canary)
+	require.NoError(t, err)
+	assert.False(t, isChanged)
+
+	assigned := func() []string {
+		nodes, err := mocks.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+			LabelSelector: label + "=" + flaggerv1.NodePartitionLabelValue,
+		})
+		require.NoError(t, err)
+		names := make([]string, 0, len(nodes.Items))
+		for _, node := range nodes.Items {
+			names = append(names, node.Name)
+		}
+		return names
+	}
+
+	require.NoError(t, mocks.controller.SetStatusWeight(mocks.canary, 30))
+	assert.ElementsMatch(t, []string{"node-a", "node-b"}, assigned())
+
+	require.NoError(t, mocks.controller.SetStatusWeight(mocks.canary, 60))
+	assert.ElementsMatch(t, []string{"node-a", "node-b", "node-c"}, assigned())
+
+	require.NoError(t, mocks.controller.SetStatusWeight(mocks.canary, 100))
+	assert.ElementsMatch(t, []string{"node-a", "node-b", "node-c", "node-d"}, assigned())
+
+	// promotion doesn't copy the partition node selector to the primary
+	require.NoError(t, mocks.controller.Promote(mocks.canary))
+	daePrimary, err = mocks.kubeClient.AppsV1().DaemonSets("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, daePrimary.Spec.Template.Spec.NodeSelector, label)
+	assert.NotNil(t, daePrimary.Spec.Template.Spec.Affinity.NodeAffinity)
+
+	require.NoError(t, mocks.controller.ScaleToZero(mocks.canary))
+	assert.Empty(t, assigned())
+}
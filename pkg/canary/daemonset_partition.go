@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// nodePartitionSelector returns the node selector that restricts the canary DaemonSet
+// to the nodes assigned to it
+func nodePartitionSelector(cd *flaggerv1.Canary) map[string]string {
+	if cd.Spec.NodePartition == nil {
+		return nil
+	}
+	return map[string]string{cd.GetNodePartitionLabel(): flaggerv1.NodePartitionLabelValue}
+}
+
+// withNodePartitionAffinity adds to the pod spec a required node affinity
+// that excludes the nodes assigned to the canary DaemonSet
+func withNodePartitionAffinity(cd *flaggerv1.Canary, spec corev1.PodSpec) corev1.PodSpec {
+	if cd.Spec.NodePartition == nil {
+		return spec
+	}
+
+	requirement := corev1.NodeSelectorRequirement{
+		Key:      cd.GetNodePartitionLabel(),
+		Operator: corev1.NodeSelectorOpNotIn,
+		Values:   []string{flaggerv1.NodePartitionLabelValue},
+	}
+
+	out := spec.DeepCopy()
+	if out.Affinity == nil {
+		out.Affinity = &corev1.Affinity{}
+	}
+	if out.Affinity.NodeAffinity == nil {
+		out.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	if out.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		out.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+
+	// the node selector terms are ORed, the requirement must be part of each term
+	required := out.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+	for i := range required.NodeSelectorTerms {
+		expressions := make([]corev1.NodeSelectorRequirement, 0, len(required.NodeSelectorTerms[i].MatchExpressions)+1)
+		for _, expr := range required.NodeSelectorTerms[i].MatchExpressions {
+			if expr.Key != requirement.Key {
+				expressions = append(expressions, expr)
+			}
+		}
+		required.NodeSelectorTerms[i].MatchExpressions = append(expressions, requirement)
+	}
+	return *out
+}
+
+// partitionNodes assigns to the canary DaemonSet the share of the eligible nodes given by the weight,
+// the nodes are picked in name order so that a node assigned at a lower weight stays assigned
+func (c *DaemonSetController) partitionNodes(cd *flaggerv1.Canary, weight int) error {
+	if cd.Spec.NodePartition == nil {
+		return nil
+	}
+	label := cd.GetNodePartitionLabel()
+
+	eligible, err := c.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(cd.Spec.NodePartition.NodeSelector).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("nodes list query error: %w", err)
+	}
+	nodes := eligible.Items
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	count := int(math.Ceil(float64(len(nodes)*weight) / 100))
+	if count > len(nodes) {
+		count = len(nodes)
+	}
+	desired := make(map[string]bool, count)
+	for _, node := range nodes[:count] {
+		desired[node.Name] = true
+	}
+
+	assigned, err := c.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(nodePartitionSelector(cd)).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("nodes list query error: %w", err)
+	}
+	for _, node := range assigned.Items {
+		if desired[node.Name] {
+			delete(desired, node.Name)
+			continue
+		}
+		if err := c.labelNode(cd, node.Name, label, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range nodes[:count] {
+		if !desired[node.Name] {
+			continue
+		}
+		value := flaggerv1.NodePartitionLabelValue
+		if err := c.labelNode(cd, node.Name, label, &value); err != nil {
+			return err
+		}
+	}
+
+	c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Debugf("%d of %d nodes assigned to DaemonSet %s.%s", count, len(nodes), cd.Spec.TargetRef.Name, cd.Namespace)
+	return nil
+}
+
+// labelNode sets the node label to the given value or removes it when the value is nil
+func (c *DaemonSetController) labelNode(cd *flaggerv1.Canary, name string, label string, value *string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]*string{label: value},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("node %s patch marshal error: %w", name, err)
+	}
+
+	_, err = c.kubeClient.CoreV1().Nodes().Patch(context.TODO(), name, types.MergePatchType, patch,
+		metav1.PatchOptions{FieldManager: cd.FieldManager()})
+	if err != nil {
+		return fmt.Errorf("patching node %s label %s failed: %w", name, label, err)
+	}
+	return nil
+}
@@ -30,7 +30,10 @@ import (
 // IsPrimaryReady checks the primary daemonset status and returns an error if
 // the daemonset is in the middle of a rolling update
 func (c *DaemonSetController) IsPrimaryReady(cd *flaggerv1.Canary) error {
-	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
 	primary, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("daemonset %s.%s get query error: %w", primaryName, cd.Namespace, err)
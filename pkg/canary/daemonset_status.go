@@ -47,8 +47,27 @@ func (c *DaemonSetController) SyncStatus(cd *flaggerv1.Canary, status flaggerv1.
 		return fmt.Errorf("GetConfigRefs failed: %w", err)
 	}
 
-	return syncCanaryStatus(c.flaggerClient, cd, status, dae.Spec.Template, func(cdCopy *flaggerv1.Canary) {
+	// record the primary template as the last promoted revision
+	if status.Phase == flaggerv1.CanaryPhaseInitialized {
+		primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+		if err != nil {
+			return err
+		}
+		primary, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("daemonset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+		}
+		spec := computeHash(hashedPodTemplate(cd, dae.Spec.Template))
+		if err := recordPromotedTemplate(c.kubeClient, cd, spec, primary.Spec.Template); err != nil {
+			return err
+		}
+	}
+
+	return syncCanaryStatus(c.flaggerClient, cd, status, hashedPodTemplate(cd, dae.Spec.Template), func(cdCopy *flaggerv1.Canary) {
 		cdCopy.Status.TrackedConfigs = configs
+		if status.Phase == flaggerv1.CanaryPhaseInitialized {
+			cdCopy.Status.LastPromotedImages = podImages(dae.Spec.Template.Spec)
+		}
 	})
 }
 
@@ -57,8 +76,12 @@ func (c *DaemonSetController) SetStatusFailedChecks(cd *flaggerv1.Canary, val in
 	return setStatusFailedChecks(c.flaggerClient, cd, val)
 }
 
-// SetStatusWeight updates the canary status weight value
+// SetStatusWeight updates the canary status weight value,
+// when the node partitioning is enabled the canary nodes follow the weight
 func (c *DaemonSetController) SetStatusWeight(cd *flaggerv1.Canary, val int) error {
+	if err := c.partitionNodes(cd, val); err != nil {
+		return fmt.Errorf("partitionNodes failed: %w", err)
+	}
 	return setStatusWeight(c.flaggerClient, cd, val)
 }
 
@@ -67,7 +90,48 @@ func (c *DaemonSetController) SetStatusIterations(cd *flaggerv1.Canary, val int)
 	return setStatusIterations(c.flaggerClient, cd, val)
 }
 
-// SetStatusPhase updates the canary status phase
+// SetStatusPhase updates the canary status phase, on promotion
+// the primary images and pod template are recorded as the last promoted revision
 func (c *DaemonSetController) SetStatusPhase(cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase) error {
-	return setStatusPhase(c.flaggerClient, cd, phase)
+	var images map[string]string
+	if phase == flaggerv1.CanaryPhaseInitialized || phase == flaggerv1.CanaryPhaseSucceeded {
+		primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+		if err != nil {
+			return err
+		}
+		primary, err := c.kubeClient.AppsV1().DaemonSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("daemonset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+		}
+		images = podImages(primary.Spec.Template.Spec)
+		if err := recordPromotedTemplate(c.kubeClient, cd, cd.Status.LastAppliedSpec, primary.Spec.Template); err != nil {
+			return err
+		}
+	}
+	return setStatusPhase(c.flaggerClient, cd, phase, images)
+}
+
+// SetStatusFinalization updates the canary status finalization
+func (c *DaemonSetController) SetStatusFinalization(cd *flaggerv1.Canary, finalization []flaggerv1.CanaryFinalizationStatus) error {
+	return setStatusFinalization(c.flaggerClient, cd, finalization)
+}
+
+// SetStatusMetrics updates the canary status metrics
+func (c *DaemonSetController) SetStatusMetrics(cd *flaggerv1.Canary, metrics []flaggerv1.CanaryMetricStatus) error {
+	return setStatusMetrics(c.flaggerClient, cd, metrics)
+}
+
+// SetStatusFailures updates the canary status failures
+func (c *DaemonSetController) SetStatusFailures(cd *flaggerv1.Canary, failures []flaggerv1.CanaryCheckFailure) error {
+	return setStatusFailures(c.flaggerClient, cd, failures)
+}
+
+// SetStatusWebhooks updates the last result of the webhooks
+func (c *DaemonSetController) SetStatusWebhooks(cd *flaggerv1.Canary, webhooks []flaggerv1.CanaryWebhookStatus) error {
+	return setStatusWebhooks(c.flaggerClient, cd, webhooks)
+}
+
+// SetStatusNextAnalysisTime records when the next analysis run is due
+func (c *DaemonSetController) SetStatusNextAnalysisTime(cd *flaggerv1.Canary, next metav1.Time) error {
+	return setStatusNextAnalysisTime(c.flaggerClient, cd, next)
 }
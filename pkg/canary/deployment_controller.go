@@ -28,6 +28,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/util/retry"
 
@@ -38,6 +39,7 @@ import (
 // DeploymentController is managing the operations for Kubernetes Deployment kind
 type DeploymentController struct {
 	kubeClient         kubernetes.Interface
+	dynamicClient      dynamic.Interface
 	flaggerClient      clientset.Interface
 	logger             *zap.SugaredLogger
 	configTracker      Tracker
@@ -45,10 +47,13 @@ type DeploymentController struct {
 	includeLabelPrefix []string
 }
 
-// Initialize creates the primary deployment, hpa,
+// Initialize creates the primary deployment, hpa, vpa, pod disruption budgets,
 // scales to zero the canary deployment and returns the pod selector label and container ports
 func (c *DeploymentController) Initialize(cd *flaggerv1.Canary) (err error) {
-	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
 	if err := c.createPrimaryDeployment(cd, c.includeLabelPrefix); err != nil {
 		return fmt.Errorf("createPrimaryDeployment failed: %w", err)
 	}
@@ -68,105 +73,210 @@ func (c *DeploymentController) Initialize(cd *flaggerv1.Canary) (err error) {
 	}
 
 	if cd.Spec.AutoscalerRef != nil {
-		if cd.Spec.AutoscalerRef.Kind == "HorizontalPodAutoscaler" {
+		switch cd.Spec.AutoscalerRef.Kind {
+		case "HorizontalPodAutoscaler":
 			if err := c.reconcilePrimaryHpa(cd, true); err != nil {
 				return fmt.Errorf(
 					"initial reconcilePrimaryHpa for %s.%s failed: %w", primaryName, cd.Namespace, err)
 			}
-		} else {
+		case "ScaledObject":
+			if err := c.reconcilePrimaryScaledObject(cd, true); err != nil {
+				return fmt.Errorf(
+					"initial reconcilePrimaryScaledObject for %s.%s failed: %w", primaryName, cd.Namespace, err)
+			}
+		case "VerticalPodAutoscaler":
+			// reconciled along with the VPAs that target the canary deployment
+		default:
 			return fmt.Errorf("cd.Spec.AutoscalerRef.Kind is invalid: %s", cd.Spec.AutoscalerRef.Kind)
 		}
 	}
+
+	if err := c.reconcilePrimaryVpas(cd); err != nil {
+		return fmt.Errorf("reconcilePrimaryVpas for %s.%s failed: %w", primaryName, cd.Namespace, err)
+	}
+
+	if err := c.reconcilePrimaryPDBs(cd); err != nil {
+		return fmt.Errorf("reconcilePrimaryPDBs for %s.%s failed: %w", primaryName, cd.Namespace, err)
+	}
 	return nil
 }
 
 // Promote copies the pod spec, secrets and config maps from canary to primary
 func (c *DeploymentController) Promote(cd *flaggerv1.Canary) error {
 	targetName := cd.Spec.TargetRef.Name
-	primaryName := fmt.Sprintf("%s-primary", targetName)
+	primaryName, err := cd.GetPrimaryName(targetName)
+	if err != nil {
+		return err
+	}
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		canary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
 		if err != nil {
 			return fmt.Errorf("deployment %s.%s get query error: %w", targetName, cd.Namespace, err)
 		}
+		return c.promoteDeployment(cd, canary)
+	})
+	if err != nil {
+		return fmt.Errorf("updating deployment %s.%s template spec failed: %w",
+			primaryName, cd.Namespace, err)
+	}
 
-		label, labelValue, err := c.getSelectorLabel(canary)
-		primaryLabelValue := fmt.Sprintf("%s-primary", labelValue)
-		if err != nil {
-			return fmt.Errorf("getSelectorLabel failed: %w", err)
+	// update HPA or ScaledObject
+	if cd.Spec.AutoscalerRef != nil {
+		switch cd.Spec.AutoscalerRef.Kind {
+		case "HorizontalPodAutoscaler":
+			if err := c.reconcilePrimaryHpa(cd, false); err != nil {
+				return fmt.Errorf(
+					"reconcilePrimaryHpa for %s.%s failed: %w", primaryName, cd.Namespace, err)
+			}
+		case "ScaledObject":
+			if err := c.reconcilePrimaryScaledObject(cd, false); err != nil {
+				return fmt.Errorf(
+					"reconcilePrimaryScaledObject for %s.%s failed: %w", primaryName, cd.Namespace, err)
+			}
+		case "VerticalPodAutoscaler":
+			// reconciled along with the VPAs that target the canary deployment
+		default:
+			return fmt.Errorf("cd.Spec.AutoscalerRef.Kind is invalid: %s", cd.Spec.AutoscalerRef.Kind)
 		}
+	}
+
+	if err := c.reconcilePrimaryVpas(cd); err != nil {
+		return fmt.Errorf("reconcilePrimaryVpas for %s.%s failed: %w", primaryName, cd.Namespace, err)
+	}
+
+	if err := c.reconcilePrimaryPDBs(cd); err != nil {
+		return fmt.Errorf("reconcilePrimaryPDBs for %s.%s failed: %w", primaryName, cd.Namespace, err)
+	}
+	return nil
+}
+
+// promoteDeployment copies the pod spec, secrets and config maps from the canary deployment to the primary
+func (c *DeploymentController) promoteDeployment(cd *flaggerv1.Canary, canary *appsv1.Deployment) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+
+	label, labelValue, err := c.getSelectorLabel(canary)
+	if err != nil {
+		return fmt.Errorf("getSelectorLabel failed: %w", err)
+	}
+	primaryLabelValue, err := cd.GetPrimaryLabelValue(labelValue)
+	if err != nil {
+		return err
+	}
+
+	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	// promote secrets and config maps
+	configRefs, err := c.configTracker.GetTargetConfigs(cd)
+	if err != nil {
+		return fmt.Errorf("GetTargetConfigs failed: %w", err)
+	}
+	if err := c.configTracker.CreatePrimaryConfigs(cd, configRefs, c.includeLabelPrefix); err != nil {
+		return fmt.Errorf("CreatePrimaryConfigs failed: %w", err)
+	}
+
+	primaryCopy := primary.DeepCopy()
+	primaryCopy.Spec.ProgressDeadlineSeconds = canary.Spec.ProgressDeadlineSeconds
+	primaryCopy.Spec.MinReadySeconds = canary.Spec.MinReadySeconds
+	primaryCopy.Spec.RevisionHistoryLimit = canary.Spec.RevisionHistoryLimit
+	primaryCopy.Spec.Strategy = canary.Spec.Strategy
+	// update replica if hpa isn't set
+	if !hasHorizontalAutoscaler(cd) {
+		primaryCopy.Spec.Replicas = canary.Spec.Replicas
+	}
+
+	// update spec with primary secrets and config maps
+	primarySpec, err := c.getPrimaryDeploymentTemplateSpec(cd, canary, configRefs)
+	if err != nil {
+		return err
+	}
+	primaryCopy.Spec.Template.Spec = primarySpec
+
+	// update pod annotations to ensure a rolling update
+	configAnnotations, err := primaryConfigAnnotations(cd, canary.Spec.Template.Annotations, configRefs)
+	if err != nil {
+		return fmt.Errorf("primaryConfigAnnotations failed: %w", err)
+	}
+	podAnnotations, err := makeAnnotations(configAnnotations)
+	if err != nil {
+		return fmt.Errorf("makeAnnotations for podAnnotations failed: %w", err)
+	}
+
+	primaryCopy.Spec.Template.Annotations = podAnnotations
+	primaryCopy.Spec.Template.Labels = makePrimaryLabels(canary.Spec.Template.Labels, primaryLabelValue, label)
 
+	// update deploy annotations
+	primaryCopy.ObjectMeta.Annotations = make(map[string]string)
+	filteredAnnotations := propagateMetadata(cd.GetAnnotationPropagation(), canary.ObjectMeta.Annotations, includeLabelsByPrefix(canary.ObjectMeta.Annotations, c.includeLabelPrefix))
+	for k, v := range filteredAnnotations {
+		primaryCopy.ObjectMeta.Annotations[k] = v
+	}
+	// update deploy labels
+	primaryCopy.ObjectMeta.Labels = make(map[string]string)
+	filteredLabels := propagateMetadata(cd.GetLabelPropagation(), canary.ObjectMeta.Labels, includeLabelsByPrefix(canary.ObjectMeta.Labels, c.includeLabelPrefix))
+	for k, v := range filteredLabels {
+		primaryCopy.ObjectMeta.Labels[k] = v
+	}
+	primaryCopy.ObjectMeta.Labels = makeAuditLabels(cd, primaryCopy.ObjectMeta.Labels)
+
+	// apply update
+	primary, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+	if err != nil {
+		return err
+	}
+	return c.recordPrimaryTemplate(cd, primary)
+}
+
+// RestorePromoted sets the pod template of the last promoted revision on the primary deployment,
+// it returns true if the primary had drifted from the promoted revision
+func (c *DeploymentController) RestorePromoted(cd *flaggerv1.Canary) (bool, error) {
+	if cd.Status.LastPromotedSpec == "" {
+		return false, nil
+	}
+
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return false, err
+	}
+	restored := false
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 		if err != nil {
 			return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
 		}
 
-		// promote secrets and config maps
-		configRefs, err := c.configTracker.GetTargetConfigs(cd)
-		if err != nil {
-			return fmt.Errorf("GetTargetConfigs failed: %w", err)
-		}
-		if err := c.configTracker.CreatePrimaryConfigs(cd, configRefs, c.includeLabelPrefix); err != nil {
-			return fmt.Errorf("CreatePrimaryConfigs failed: %w", err)
-		}
-
 		primaryCopy := primary.DeepCopy()
-		primaryCopy.Spec.ProgressDeadlineSeconds = canary.Spec.ProgressDeadlineSeconds
-		primaryCopy.Spec.MinReadySeconds = canary.Spec.MinReadySeconds
-		primaryCopy.Spec.RevisionHistoryLimit = canary.Spec.RevisionHistoryLimit
-		primaryCopy.Spec.Strategy = canary.Spec.Strategy
-		// update replica if hpa isn't set
-		if cd.Spec.AutoscalerRef == nil {
-			primaryCopy.Spec.Replicas = canary.Spec.Replicas
+		restored, err = restorePromotedTemplate(c.kubeClient, cd, &primaryCopy.Spec.Template)
+		if err != nil || !restored {
+			return err
 		}
 
-		// update spec with primary secrets and config maps
-		primaryCopy.Spec.Template.Spec = c.getPrimaryDeploymentTemplateSpec(canary, configRefs)
-
-		// update pod annotations to ensure a rolling update
-		podAnnotations, err := makeAnnotations(canary.Spec.Template.Annotations)
+		primary, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 		if err != nil {
-			return fmt.Errorf("makeAnnotations for podAnnotations failed: %w", err)
+			return err
 		}
-
-		primaryCopy.Spec.Template.Annotations = podAnnotations
-		primaryCopy.Spec.Template.Labels = makePrimaryLabels(canary.Spec.Template.Labels, primaryLabelValue, label)
-
-		// update deploy annotations
-		primaryCopy.ObjectMeta.Annotations = make(map[string]string)
-		filteredAnnotations := includeLabelsByPrefix(canary.ObjectMeta.Annotations, c.includeLabelPrefix)
-		for k, v := range filteredAnnotations {
-			primaryCopy.ObjectMeta.Annotations[k] = v
-		}
-		// update deploy labels
-		primaryCopy.ObjectMeta.Labels = make(map[string]string)
-		filteredLabels := includeLabelsByPrefix(canary.ObjectMeta.Labels, c.includeLabelPrefix)
-		for k, v := range filteredLabels {
-			primaryCopy.ObjectMeta.Labels[k] = v
-		}
-
-		// apply update
-		_, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{})
-		return err
+		return c.recordPrimaryTemplate(cd, primary)
 	})
 	if err != nil {
-		return fmt.Errorf("updating deployment %s.%s template spec failed: %w",
-			primaryName, cd.Namespace, err)
+		return false, fmt.Errorf("restoring deployment %s.%s template failed: %w", primaryName, cd.Namespace, err)
 	}
+	return restored, nil
+}
 
-	// update HPA
-	if cd.Spec.AutoscalerRef != nil {
-		if cd.Spec.AutoscalerRef.Kind == "HorizontalPodAutoscaler" {
-			if err := c.reconcilePrimaryHpa(cd, false); err != nil {
-				return fmt.Errorf(
-					"reconcilePrimaryHpa for %s.%s failed: %w", primaryName, cd.Namespace, err)
-			}
-		} else {
-			return fmt.Errorf("cd.Spec.AutoscalerRef.Kind is invalid: %s", cd.Spec.AutoscalerRef.Kind)
-		}
+// GetPodTemplate returns the pod template of the canary deployment
+func (c *DeploymentController) GetPodTemplate(cd *flaggerv1.Canary) (*corev1.PodTemplateSpec, error) {
+	targetName := cd.Spec.TargetRef.Name
+	canary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("deployment %s.%s get query error: %w", targetName, cd.Namespace, err)
 	}
-	return nil
+	return &canary.Spec.Template, nil
 }
 
 // HasTargetChanged returns true if the canary deployment pod spec has changed
@@ -177,7 +287,7 @@ func (c *DeploymentController) HasTargetChanged(cd *flaggerv1.Canary) (bool, err
 		return false, fmt.Errorf("deployment %s.%s get query error: %w", targetName, cd.Namespace, err)
 	}
 
-	return hasSpecChanged(cd, canary.Spec.Template)
+	return hasSpecChanged(cd, hashedPodTemplate(cd, canary.Spec.Template))
 }
 
 // ScaleToZero Scale sets the canary deployment replicas
@@ -188,10 +298,15 @@ func (c *DeploymentController) ScaleToZero(cd *flaggerv1.Canary) error {
 		return fmt.Errorf("deployment %s.%s get query error: %w", targetName, cd.Namespace, err)
 	}
 
+	// prevent KEDA from scaling up the canary deployment
+	if err := c.pauseScaledObject(cd, true); err != nil {
+		return err
+	}
+
 	depCopy := dep.DeepCopy()
 	depCopy.Spec.Replicas = int32p(0)
 
-	_, err = c.kubeClient.AppsV1().Deployments(dep.Namespace).Update(context.TODO(), depCopy, metav1.UpdateOptions{})
+	_, err = c.kubeClient.AppsV1().Deployments(dep.Namespace).Update(context.TODO(), depCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("deployment %s.%s update query error: %w", targetName, cd.Namespace, err)
 	}
@@ -208,9 +323,12 @@ func (c *DeploymentController) ScaleFromZero(cd *flaggerv1.Canary) error {
 	replicas := int32p(1)
 	if dep.Spec.Replicas != nil && *dep.Spec.Replicas > 0 {
 		replicas = dep.Spec.Replicas
-	} else if cd.Spec.AutoscalerRef == nil {
+	} else if !hasHorizontalAutoscaler(cd) {
 		// If HPA isn't set and replicas are not specified, it uses the primary replicas when scaling up the canary
-		primaryName := fmt.Sprintf("%s-primary", targetName)
+		primaryName, err := cd.GetPrimaryName(targetName)
+		if err != nil {
+			return err
+		}
 		primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 		if err != nil {
 			return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
@@ -223,11 +341,11 @@ func (c *DeploymentController) ScaleFromZero(cd *flaggerv1.Canary) error {
 	depCopy := dep.DeepCopy()
 	depCopy.Spec.Replicas = replicas
 
-	_, err = c.kubeClient.AppsV1().Deployments(dep.Namespace).Update(context.TODO(), depCopy, metav1.UpdateOptions{})
+	_, err = c.kubeClient.AppsV1().Deployments(dep.Namespace).Update(context.TODO(), depCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("scaling up %s.%s to %v failed: %v", depCopy.GetName(), depCopy.Namespace, replicas, err)
 	}
-	return nil
+	return c.pauseScaledObject(cd, false)
 }
 
 // GetMetadata returns the pod label selector and svc ports
@@ -253,21 +371,31 @@ func (c *DeploymentController) GetMetadata(cd *flaggerv1.Canary) (string, string
 }
 func (c *DeploymentController) createPrimaryDeployment(cd *flaggerv1.Canary, includeLabelPrefix []string) error {
 	targetName := cd.Spec.TargetRef.Name
-	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
-
 	canaryDep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("deployment %s.%s get query error: %w", targetName, cd.Namespace, err)
 	}
+	return c.createPrimaryDeploymentFrom(cd, canaryDep, includeLabelPrefix)
+}
+
+// createPrimaryDeploymentFrom creates the primary deployment from the canary deployment spec
+func (c *DeploymentController) createPrimaryDeploymentFrom(cd *flaggerv1.Canary, canaryDep *appsv1.Deployment, includeLabelPrefix []string) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
 
 	// Create the labels map but filter unwanted labels
-	labels := includeLabelsByPrefix(canaryDep.Labels, includeLabelPrefix)
+	labels := propagateMetadata(cd.GetLabelPropagation(), canaryDep.Labels, includeLabelsByPrefix(canaryDep.Labels, includeLabelPrefix))
 
 	label, labelValue, err := c.getSelectorLabel(canaryDep)
-	primaryLabelValue := fmt.Sprintf("%s-primary", labelValue)
 	if err != nil {
 		return fmt.Errorf("getSelectorLabel failed: %w", err)
 	}
+	primaryLabelValue, err := cd.GetPrimaryLabelValue(labelValue)
+	if err != nil {
+		return err
+	}
 
 	primaryDep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
@@ -279,10 +407,18 @@ func (c *DeploymentController) createPrimaryDeployment(cd *flaggerv1.Canary, inc
 		if err := c.configTracker.CreatePrimaryConfigs(cd, configRefs, c.includeLabelPrefix); err != nil {
 			return fmt.Errorf("CreatePrimaryConfigs failed: %w", err)
 		}
-		annotations, err := makeAnnotations(canaryDep.Spec.Template.Annotations)
+		configAnnotations, err := primaryConfigAnnotations(cd, canaryDep.Spec.Template.Annotations, configRefs)
+		if err != nil {
+			return fmt.Errorf("primaryConfigAnnotations failed: %w", err)
+		}
+		annotations, err := makeAnnotations(configAnnotations)
 		if err != nil {
 			return fmt.Errorf("makeAnnotations failed: %w", err)
 		}
+		primarySpec, err := c.getPrimaryDeploymentTemplateSpec(cd, canaryDep, configRefs)
+		if err != nil {
+			return err
+		}
 
 		replicas := int32(1)
 		if canaryDep.Spec.Replicas != nil && *canaryDep.Spec.Replicas > 0 {
@@ -294,8 +430,8 @@ func (c *DeploymentController) createPrimaryDeployment(cd *flaggerv1.Canary, inc
 			ObjectMeta: metav1.ObjectMeta{
 				Name:        primaryName,
 				Namespace:   cd.Namespace,
-				Labels:      makePrimaryLabels(labels, primaryLabelValue, label),
-				Annotations: filterMetadata(canaryDep.Annotations),
+				Labels:      makeAuditLabels(cd, makePrimaryLabels(labels, primaryLabelValue, label)),
+				Annotations: propagateMetadata(cd.GetAnnotationPropagation(), canaryDep.Annotations, filterMetadata(canaryDep.Annotations)),
 				OwnerReferences: []metav1.OwnerReference{
 					*metav1.NewControllerRef(cd, schema.GroupVersionKind{
 						Group:   flaggerv1.SchemeGroupVersion.Group,
@@ -321,14 +457,17 @@ func (c *DeploymentController) createPrimaryDeployment(cd *flaggerv1.Canary, inc
 						Annotations: annotations,
 					},
 					// update spec with the primary secrets and config maps
-					Spec: c.getPrimaryDeploymentTemplateSpec(canaryDep, configRefs),
+					Spec: primarySpec,
 				},
 			},
 		}
 
-		_, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Create(context.TODO(), primaryDep, metav1.CreateOptions{})
+		primaryDep, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Create(context.TODO(), primaryDep, metav1.CreateOptions{FieldManager: cd.FieldManager()})
 		if err != nil {
-			return fmt.Errorf("creating deployment %s.%s failed: %w", primaryDep.Name, cd.Namespace, err)
+			return fmt.Errorf("creating deployment %s.%s failed: %w", primaryName, cd.Namespace, err)
+		}
+		if err := c.recordPrimaryTemplate(cd, primaryDep); err != nil {
+			return err
 		}
 
 		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
@@ -339,7 +478,10 @@ func (c *DeploymentController) createPrimaryDeployment(cd *flaggerv1.Canary, inc
 }
 
 func (c *DeploymentController) reconcilePrimaryHpa(cd *flaggerv1.Canary, init bool) error {
-	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
 	hpa, err := c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace).Get(context.TODO(), cd.Spec.AutoscalerRef.Name, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("HorizontalPodAutoscaler %s.%s get query error: %w",
@@ -358,16 +500,29 @@ func (c *DeploymentController) reconcilePrimaryHpa(cd *flaggerv1.Canary, init bo
 		Behavior:    hpa.Spec.Behavior,
 	}
 
-	primaryHpaName := fmt.Sprintf("%s-primary", cd.Spec.AutoscalerRef.Name)
+	if replicas := cd.Spec.AutoscalerRef.PrimaryScalerReplicas; replicas != nil {
+		if replicas.MinReplicas != nil {
+			hpaSpec.MinReplicas = replicas.MinReplicas
+		}
+		if replicas.MaxReplicas != nil {
+			hpaSpec.MaxReplicas = *replicas.MaxReplicas
+		}
+	}
+
+	primaryHpaName, err := cd.GetPrimaryName(cd.Spec.AutoscalerRef.Name)
+	if err != nil {
+		return err
+	}
 	primaryHpa, err := c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace).Get(context.TODO(), primaryHpaName, metav1.GetOptions{})
 
 	// create HPA
 	if errors.IsNotFound(err) {
 		primaryHpa = &hpav2.HorizontalPodAutoscaler{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      primaryHpaName,
-				Namespace: cd.Namespace,
-				Labels:    filterMetadata(hpa.Labels),
+				Name:        primaryHpaName,
+				Namespace:   cd.Namespace,
+				Labels:      propagateMetadata(cd.GetLabelPropagation(), hpa.Labels, filterMetadata(hpa.Labels)),
+				Annotations: propagateMetadata(cd.GetAnnotationPropagation(), hpa.Annotations, nil),
 				OwnerReferences: []metav1.OwnerReference{
 					*metav1.NewControllerRef(cd, schema.GroupVersionKind{
 						Group:   flaggerv1.SchemeGroupVersion.Group,
@@ -379,7 +534,7 @@ func (c *DeploymentController) reconcilePrimaryHpa(cd *flaggerv1.Canary, init bo
 			Spec: hpaSpec,
 		}
 
-		_, err = c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace).Create(context.TODO(), primaryHpa, metav1.CreateOptions{})
+		_, err = c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace).Create(context.TODO(), primaryHpa, metav1.CreateOptions{FieldManager: cd.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("creating HorizontalPodAutoscaler %s.%s failed: %w",
 				primaryHpa.Name, primaryHpa.Namespace, err)
@@ -392,6 +547,29 @@ func (c *DeploymentController) reconcilePrimaryHpa(cd *flaggerv1.Canary, init bo
 			primaryHpa.Name, primaryHpa.Namespace, err)
 	}
 
+	// apply the primary replicas override while the canary HPA is left untouched
+	if init && cd.Spec.AutoscalerRef.PrimaryScalerReplicas != nil &&
+		(int32Default(hpaSpec.MinReplicas) != int32Default(primaryHpa.Spec.MinReplicas) || hpaSpec.MaxReplicas != primaryHpa.Spec.MaxReplicas) {
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			primaryHpa, err := c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace).Get(context.TODO(), primaryHpaName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			hpaClone := primaryHpa.DeepCopy()
+			hpaClone.Spec.MinReplicas = hpaSpec.MinReplicas
+			hpaClone.Spec.MaxReplicas = hpaSpec.MaxReplicas
+			_, err = c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace).Update(context.TODO(), hpaClone, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("updating HorizontalPodAutoscaler %s.%s replicas failed: %w",
+				primaryHpaName, cd.Namespace, err)
+		}
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("HorizontalPodAutoscaler %s.%s replicas set to %d-%d", primaryHpaName, cd.Namespace,
+				int32Default(hpaSpec.MinReplicas), hpaSpec.MaxReplicas)
+	}
+
 	// update HPA
 	if !init && primaryHpa != nil {
 		diffMetrics := cmp.Diff(hpaSpec.Metrics, primaryHpa.Spec.Metrics)
@@ -412,18 +590,18 @@ func (c *DeploymentController) reconcilePrimaryHpa(cd *flaggerv1.Canary, init bo
 
 				// update hpa annotations
 				hpaClone.ObjectMeta.Annotations = make(map[string]string)
-				filteredAnnotations := includeLabelsByPrefix(hpa.ObjectMeta.Annotations, c.includeLabelPrefix)
+				filteredAnnotations := propagateMetadata(cd.GetAnnotationPropagation(), hpa.ObjectMeta.Annotations, includeLabelsByPrefix(hpa.ObjectMeta.Annotations, c.includeLabelPrefix))
 				for k, v := range filteredAnnotations {
 					hpaClone.ObjectMeta.Annotations[k] = v
 				}
 				// update hpa labels
 				hpaClone.ObjectMeta.Labels = make(map[string]string)
-				filteredLabels := includeLabelsByPrefix(hpa.ObjectMeta.Labels, c.includeLabelPrefix)
+				filteredLabels := propagateMetadata(cd.GetLabelPropagation(), hpa.ObjectMeta.Labels, includeLabelsByPrefix(hpa.ObjectMeta.Labels, c.includeLabelPrefix))
 				for k, v := range filteredLabels {
 					hpaClone.ObjectMeta.Labels[k] = v
 				}
 
-				_, err = c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace).Update(context.TODO(), hpaClone, metav1.UpdateOptions{})
+				_, err = c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace).Update(context.TODO(), hpaClone, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 				return err
 			})
 			if err != nil {
@@ -467,7 +645,10 @@ func (c *DeploymentController) Finalize(cd *flaggerv1.Canary) error {
 	}
 
 	// get primary if possible, if not scale from zero
-	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
 	primaryDep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -486,6 +667,40 @@ func (c *DeploymentController) Finalize(cd *flaggerv1.Canary) error {
 			return fmt.Errorf("scale failed: %w", err)
 		}
 	}
+
+	// the target autoscaler takes over once the target is scaled up
+	if cd.Spec.RevertPolicy == flaggerv1.FullRevertPolicy && cd.Spec.AutoscalerRef != nil &&
+		cd.Spec.AutoscalerRef.Kind == "HorizontalPodAutoscaler" {
+		if err := c.deletePrimaryHpa(cd); err != nil {
+			return err
+		}
+	}
+	return c.pauseScaledObject(cd, false)
+}
+
+// deletePrimaryHpa removes the primary HPA generated by Flagger
+func (c *DeploymentController) deletePrimaryHpa(cd *flaggerv1.Canary) error {
+	primaryHpaName, err := cd.GetPrimaryName(cd.Spec.AutoscalerRef.Name)
+	if err != nil {
+		return err
+	}
+	client := c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(cd.Namespace)
+	hpa, err := client.Get(context.TODO(), primaryHpaName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("HorizontalPodAutoscaler %s.%s get query error: %w", primaryHpaName, cd.Namespace, err)
+	}
+	if ref := metav1.GetControllerOf(hpa); ref == nil || ref.Kind != flaggerv1.CanaryKind || ref.Name != cd.Name {
+		return nil
+	}
+
+	if err := client.Delete(context.TODO(), primaryHpaName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("HorizontalPodAutoscaler %s.%s delete error: %w", primaryHpaName, cd.Namespace, err)
+	}
+	c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Infof("HorizontalPodAutoscaler %s.%s deleted", primaryHpaName, cd.Namespace)
 	return nil
 }
 
@@ -499,54 +714,64 @@ func (c *DeploymentController) scale(cd *flaggerv1.Canary, replicas int32) error
 
 	depCopy := dep.DeepCopy()
 	depCopy.Spec.Replicas = int32p(replicas)
-	_, err = c.kubeClient.AppsV1().Deployments(dep.Namespace).Update(context.TODO(), depCopy, metav1.UpdateOptions{})
+	_, err = c.kubeClient.AppsV1().Deployments(dep.Namespace).Update(context.TODO(), depCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("scaling %s.%s to %v failed: %w", depCopy.GetName(), depCopy.Namespace, replicas, err)
 	}
 	return nil
 }
 
-func (c *DeploymentController) getPrimaryDeploymentTemplateSpec(canaryDep *appsv1.Deployment, refs map[string]ConfigRef) corev1.PodSpec {
-	spec := c.configTracker.ApplyPrimaryConfigs(canaryDep.Spec.Template.Spec, refs)
+func (c *DeploymentController) getPrimaryDeploymentTemplateSpec(cd *flaggerv1.Canary, canaryDep *appsv1.Deployment,
+	refs map[string]ConfigRef) (corev1.PodSpec, error) {
+	spec, err := c.configTracker.ApplyPrimaryConfigs(cd, primaryPodSpec(cd, canaryDep.Spec.Template.Spec), refs)
+	if err != nil {
+		return spec, fmt.Errorf("ApplyPrimaryConfigs failed: %w", err)
+	}
+	primaryValue, err := cd.GetPrimaryLabelValue(canaryDep.Name)
+	if err != nil {
+		return spec, err
+	}
 
 	// update TopologySpreadConstraints
 	for _, topologySpreadConstraint := range spec.TopologySpreadConstraints {
-		c.appendPrimarySuffixToValuesIfNeeded(topologySpreadConstraint.LabelSelector, canaryDep)
+		c.appendPrimarySuffixToValuesIfNeeded(topologySpreadConstraint.LabelSelector, canaryDep.Name, primaryValue)
 	}
 
 	// update affinity
 	if affinity := spec.Affinity; affinity != nil {
 		if podAntiAffinity := affinity.PodAntiAffinity; podAntiAffinity != nil {
 			for _, preferredAntiAffinity := range podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
-				c.appendPrimarySuffixToValuesIfNeeded(preferredAntiAffinity.PodAffinityTerm.LabelSelector, canaryDep)
+				c.appendPrimarySuffixToValuesIfNeeded(preferredAntiAffinity.PodAffinityTerm.LabelSelector, canaryDep.Name, primaryValue)
 			}
 
 			for _, requiredAntiAffinity := range podAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
-				c.appendPrimarySuffixToValuesIfNeeded(requiredAntiAffinity.LabelSelector, canaryDep)
+				c.appendPrimarySuffixToValuesIfNeeded(requiredAntiAffinity.LabelSelector, canaryDep.Name, primaryValue)
 			}
 		}
 	}
 
-	return spec
+	// the injected anti-affinity selects the canary pods and is added after the target selectors are rewritten
+	return withPrimaryAntiAffinity(cd, canaryDep.Spec.Selector, spec), nil
 }
 
-func (c *DeploymentController) appendPrimarySuffixToValuesIfNeeded(labelSelector *metav1.LabelSelector, canaryDep *appsv1.Deployment) {
+// appendPrimarySuffixToValuesIfNeeded replaces the canary label value with the primary value in the label selector
+func (c *DeploymentController) appendPrimarySuffixToValuesIfNeeded(labelSelector *metav1.LabelSelector, value string, primaryValue string) {
 	if labelSelector != nil {
 		for _, matchExpression := range labelSelector.MatchExpressions {
 			if contains(c.labels, matchExpression.Key) {
 				for i := range matchExpression.Values {
-					if matchExpression.Values[i] == canaryDep.Name {
-						matchExpression.Values[i] += "-primary"
+					if matchExpression.Values[i] == value {
+						matchExpression.Values[i] = primaryValue
 						break
 					}
 				}
 			}
 		}
 
-		for key, value := range labelSelector.MatchLabels {
+		for key, val := range labelSelector.MatchLabels {
 			if contains(c.labels, key) {
-				if value == canaryDep.Name {
-					labelSelector.MatchLabels[key] = value + "-primary"
+				if val == value {
+					labelSelector.MatchLabels[key] = primaryValue
 				}
 			}
 		}
@@ -24,10 +24,18 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	hpav2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
@@ -56,6 +64,43 @@ func TestDeploymentController_Sync_ConsistentNaming(t *testing.T) {
 	assert.Equal(t, depPrimary.Name, hpaPrimary.Spec.ScaleTargetRef.Name)
 }
 
+func TestDeploymentController_Sync_PrimaryNaming(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.canary.Spec.PrimaryNaming = &flaggerv1.PrimaryNaming{
+		NameTemplate:  "{{ .Name }}-stable",
+		LabelTemplate: "{{ .Name }}-{{ .Namespace }}-stable",
+	}
+	mocks.initializeCanary(t)
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-stable", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "podinfo-default-stable", depPrimary.Spec.Selector.MatchLabels[dc.label])
+	assert.Equal(t, "podinfo-default-stable", depPrimary.Spec.Template.Labels[dc.label])
+
+	_, err = mocks.kubeClient.CoreV1().ConfigMaps("default").Get(context.TODO(), "podinfo-config-env-stable", metav1.GetOptions{})
+	require.NoError(t, err)
+	env := depPrimary.Spec.Template.Spec.Containers[0].Env[0]
+	assert.Equal(t, "podinfo-config-env-stable", env.ValueFrom.ConfigMapKeyRef.Name)
+
+	hpaPrimary, err := mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Get(context.TODO(), "podinfo-stable", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, depPrimary.Name, hpaPrimary.Spec.ScaleTargetRef.Name)
+}
+
+func TestDeploymentController_Sync_InvalidPrimaryNaming(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.canary.Spec.PrimaryNaming = &flaggerv1.PrimaryNaming{NameTemplate: "{{ .Version }}"}
+
+	err := mocks.controller.Initialize(mocks.canary)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "primary name of podinfo")
+
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
 func TestDeploymentController_Sync_InconsistentNaming(t *testing.T) {
 	dc := deploymentConfigs{name: "podinfo-service", label: "name", labelValue: "podinfo"}
 	mocks := newDeploymentFixture(dc)
@@ -112,6 +157,7 @@ func TestDeploymentController_Promote(t *testing.T) {
 	depPrimaryLabels := depPrimary.ObjectMeta.Labels
 	depSourceLabels := dep2.ObjectMeta.Labels
 	assert.Equal(t, depSourceLabels["app.kubernetes.io/test-label-1"], depPrimaryLabels["app.kubernetes.io/test-label-1"])
+	assert.Equal(t, mocks.canary.Name, depPrimaryLabels["flagger.app/canary"])
 
 	depPrimaryAnnotations := depPrimary.ObjectMeta.Annotations
 	depSourceAnnotations := dep2.ObjectMeta.Annotations
@@ -140,6 +186,443 @@ func TestDeploymentController_Promote(t *testing.T) {
 	assert.Equal(t, "podinfo-primary", value)
 }
 
+func TestDeploymentController_MetadataPropagation(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.canary.Spec.MetadataPropagation = &flaggerv1.MetadataPropagation{
+		Labels: &flaggerv1.MetadataSelector{
+			Keys: []string{"test-label-1"},
+		},
+		Annotations: &flaggerv1.MetadataSelector{
+			IncludePrefixes: []string{"*"},
+			ExcludePrefixes: []string{"test-"},
+		},
+	}
+	mocks.initializeCanary(t)
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "test-label-value-1", depPrimary.Labels["test-label-1"])
+	assert.Equal(t, "podinfo-primary", depPrimary.Labels["name"])
+	assert.NotContains(t, depPrimary.Annotations, "test-annotation-1")
+	assert.NotContains(t, depPrimary.Annotations, "kustomize.toolkit.fluxcd.io/checksum")
+
+	dep2 := newDeploymentControllerTestV2()
+	dep2.Annotations["kubectl.kubernetes.io/default-container"] = "podinfo"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	err = mocks.controller.Promote(mocks.canary)
+	require.NoError(t, err)
+
+	depPrimary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, depPrimary.Labels, "app.kubernetes.io/test-label-1")
+	assert.Equal(t, "podinfo", depPrimary.Annotations["kubectl.kubernetes.io/default-container"])
+	assert.Equal(t, dep2.Annotations["app.kubernetes.io/test-annotation-1"], depPrimary.Annotations["app.kubernetes.io/test-annotation-1"])
+	assert.Equal(t, "podinfo", depPrimary.Labels["flagger.app/canary"])
+}
+
+func TestDeploymentController_SyncPrimaryDrift(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.canary.Spec.ConflictPolicy = flaggerv1.RevertConflictPolicy
+	mocks.initializeCanary(t)
+
+	// record the promoted revision
+	err := mocks.controller.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitialized})
+	require.NoError(t, err)
+	mocks.canary, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, depPrimary.Annotations, primaryTemplateHashAnnotation)
+	image := depPrimary.Spec.Template.Spec.Containers[0].Image
+
+	drifted, err := mocks.controller.SyncPrimaryDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.False(t, drifted)
+
+	// out-of-band change of the primary image
+	depPrimary.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:drifted"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), depPrimary, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	drifted, err = mocks.controller.SyncPrimaryDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.True(t, drifted)
+
+	depPrimary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, image, depPrimary.Spec.Template.Spec.Containers[0].Image)
+
+	// adopt the out-of-band change
+	mocks.canary.Spec.ConflictPolicy = flaggerv1.AdoptConflictPolicy
+	depPrimary.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:drifted"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), depPrimary, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	drifted, err = mocks.controller.SyncPrimaryDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.True(t, drifted)
+
+	drifted, err = mocks.controller.SyncPrimaryDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.False(t, drifted)
+
+	depPrimary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:drifted", depPrimary.Spec.Template.Spec.Containers[0].Image)
+
+	// the adopted template is restored on revert
+	mocks.canary.Spec.ConflictPolicy = flaggerv1.RevertConflictPolicy
+	depPrimary.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:drifted-again"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), depPrimary, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	drifted, err = mocks.controller.SyncPrimaryDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.True(t, drifted)
+
+	depPrimary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:drifted", depPrimary.Spec.Template.Spec.Containers[0].Image)
+
+	// the promotion resets the applied template
+	err = mocks.controller.Promote(mocks.canary)
+	require.NoError(t, err)
+
+	drifted, err = mocks.controller.SyncPrimaryDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.False(t, drifted)
+}
+
+func TestDeploymentController_RestorePromoted(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.initializeCanary(t)
+
+	err := mocks.controller.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitialized})
+	require.NoError(t, err)
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotEmpty(t, cd.Status.LastPromotedSpec)
+
+	revision, err := mocks.kubeClient.AppsV1().ControllerRevisions("default").Get(context.TODO(), "podinfo-promoted", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, cd.Status.LastPromotedSpec, revision.Annotations[promotedSpecAnnotation])
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	promoted := depPrimary.Spec.Template.DeepCopy()
+
+	restored, err := mocks.controller.RestorePromoted(cd)
+	require.NoError(t, err)
+	assert.False(t, restored)
+
+	// out-of-band changes of the primary pod template
+	depPrimary.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:drifted"
+	depPrimary.Spec.Template.Spec.ServiceAccountName = "drifted"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), depPrimary, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	restored, err = mocks.controller.RestorePromoted(cd)
+	require.NoError(t, err)
+	assert.True(t, restored)
+
+	depPrimary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	// the quantities decoded from the revision don't keep their cached string
+	assert.True(t, equality.Semantic.DeepEqual(*promoted, depPrimary.Spec.Template))
+
+	// the recorded template is not restored if it doesn't match the last promoted revision
+	cd.Status.LastPromotedSpec = "unknown"
+	_, err = mocks.controller.RestorePromoted(cd)
+	require.Error(t, err)
+}
+
+func TestDeploymentController_PrimaryHpa(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.initializeCanary(t)
+
+	hpa, err := mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	hpaClone := hpa.DeepCopy()
+	hpaClone.Spec.MinReplicas = int32p(2)
+	hpaClone.Spec.MaxReplicas = 4
+	hpaClone.Spec.Metrics = append(hpaClone.Spec.Metrics, hpav2.MetricSpec{
+		Type: hpav2.ContainerResourceMetricSourceType,
+		ContainerResource: &hpav2.ContainerResourceMetricSource{
+			Name:      "memory",
+			Container: "podinfo",
+			Target: hpav2.MetricTarget{
+				Type:               hpav2.UtilizationMetricType,
+				AverageUtilization: int32p(80),
+			},
+		},
+	})
+	hpaClone.Spec.Behavior = &hpav2.HorizontalPodAutoscalerBehavior{
+		ScaleDown: &hpav2.HPAScalingRules{
+			StabilizationWindowSeconds: int32p(600),
+			Policies: []hpav2.HPAScalingPolicy{
+				{Type: hpav2.PodsScalingPolicy, Value: 1, PeriodSeconds: 60},
+			},
+		},
+	}
+	_, err = mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Update(context.TODO(), hpaClone, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, mocks.controller.Promote(mocks.canary))
+
+	hpaPrimary, err := mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, hpaClone.Spec.Metrics, hpaPrimary.Spec.Metrics)
+	assert.Equal(t, hpaClone.Spec.Behavior, hpaPrimary.Spec.Behavior)
+	assert.Equal(t, int32(2), *hpaPrimary.Spec.MinReplicas)
+	assert.Equal(t, int32(4), hpaPrimary.Spec.MaxReplicas)
+
+	// override the primary replicas during the analysis
+	mocks.canary.Spec.AutoscalerRef.PrimaryScalerReplicas = &flaggerv1.ScalerReplicas{
+		MinReplicas: int32p(5),
+		MaxReplicas: int32p(10),
+	}
+	require.NoError(t, mocks.controller.Initialize(mocks.canary))
+
+	hpaPrimary, err = mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), *hpaPrimary.Spec.MinReplicas)
+	assert.Equal(t, int32(10), hpaPrimary.Spec.MaxReplicas)
+
+	hpa, err = mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), *hpa.Spec.MinReplicas)
+	assert.Equal(t, int32(4), hpa.Spec.MaxReplicas)
+}
+
+func TestDeploymentController_PodDisruptionBudgets(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+
+	minAvailable := intstr.FromInt(1)
+	for _, pdb := range []*policyv1.PodDisruptionBudget{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", Labels: map[string]string{"team": "a"}},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				MinAvailable: &minAvailable,
+				Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"name": "podinfo"}},
+			},
+		},
+		{
+			// selects both the canary and the primary pods
+			ObjectMeta: metav1.ObjectMeta{Name: "test-label", Namespace: "default"},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				MinAvailable: &minAvailable,
+				Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"test-label-1": "test-label-value-1"}},
+			},
+		},
+	} {
+		_, err := mocks.kubeClient.PolicyV1().PodDisruptionBudgets("default").Create(context.TODO(), pdb, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	mocks.initializeCanary(t)
+
+	pdbPrimary, err := mocks.kubeClient.PolicyV1().PodDisruptionBudgets("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "podinfo-primary"}, pdbPrimary.Spec.Selector.MatchLabels)
+	assert.Equal(t, 1, pdbPrimary.Spec.MinAvailable.IntValue())
+	assert.Equal(t, "a", pdbPrimary.Labels["team"])
+
+	_, err = mocks.kubeClient.PolicyV1().PodDisruptionBudgets("default").Get(context.TODO(), "test-label-primary", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+
+	// update the budget
+	pdb, err := mocks.kubeClient.PolicyV1().PodDisruptionBudgets("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	maxUnavailable := intstr.FromString("25%")
+	pdb.Spec.MinAvailable = nil
+	pdb.Spec.MaxUnavailable = &maxUnavailable
+	_, err = mocks.kubeClient.PolicyV1().PodDisruptionBudgets("default").Update(context.TODO(), pdb, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, mocks.controller.Promote(mocks.canary))
+	pdbPrimary, err = mocks.kubeClient.PolicyV1().PodDisruptionBudgets("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, pdbPrimary.Spec.MinAvailable)
+	assert.Equal(t, "25%", pdbPrimary.Spec.MaxUnavailable.String())
+
+	// delete the budget
+	err = mocks.kubeClient.PolicyV1().PodDisruptionBudgets("default").Delete(context.TODO(), "podinfo", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	require.NoError(t, mocks.controller.Promote(mocks.canary))
+	_, err = mocks.kubeClient.PolicyV1().PodDisruptionBudgets("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestDeploymentController_VerticalPodAutoscalers(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.canary.Spec.AutoscalerRef = &flaggerv1.AutoscalerReference{
+		APIVersion: "autoscaling.k8s.io/v1",
+		Kind:       "VerticalPodAutoscaler",
+		Name:       "podinfo",
+	}
+
+	newVpa := func(name, target string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "autoscaling.k8s.io/v1",
+			"kind":       "VerticalPodAutoscaler",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"spec": map[string]interface{}{
+				"targetRef": map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "Deployment",
+					"name":       target,
+				},
+				"updatePolicy": map[string]interface{}{"updateMode": "Auto"},
+			},
+		}}
+	}
+	dynamicClient := fakeDynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{VerticalPodAutoscalerGVR: "VerticalPodAutoscalerList"},
+		newVpa("podinfo", "podinfo"), newVpa("podinfo-recommender", "podinfo"), newVpa("frontend", "frontend"))
+	mocks.controller.dynamicClient = dynamicClient
+	mocks.initializeCanary(t)
+
+	vpas := dynamicClient.Resource(VerticalPodAutoscalerGVR).Namespace("default")
+	for _, name := range []string{"podinfo-primary", "podinfo-recommender-primary"} {
+		vpa, err := vpas.Get(context.TODO(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		target, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "name")
+		assert.Equal(t, "podinfo-primary", target)
+		mode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+		assert.Equal(t, "Auto", mode)
+	}
+	_, err := vpas.Get(context.TODO(), "frontend-primary", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+
+	// update and delete the autoscalers
+	vpa, err := vpas.Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NoError(t, unstructured.SetNestedField(vpa.Object, "Off", "spec", "updatePolicy", "updateMode"))
+	_, err = vpas.Update(context.TODO(), vpa, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, vpas.Delete(context.TODO(), "podinfo-recommender", metav1.DeleteOptions{}))
+
+	require.NoError(t, mocks.controller.Promote(mocks.canary))
+	vpa, err = vpas.Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	mode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+	assert.Equal(t, "Off", mode)
+	_, err = vpas.Get(context.TODO(), "podinfo-recommender-primary", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestDeploymentController_ScaledObject(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.canary.Spec.AutoscalerRef = &flaggerv1.AutoscalerReference{
+		APIVersion: "keda.sh/v1alpha1",
+		Kind:       "ScaledObject",
+		Name:       "podinfo",
+	}
+
+	so := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "keda.sh/v1alpha1",
+		"kind":       "ScaledObject",
+		"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"scaleTargetRef": map[string]interface{}{"name": "podinfo"},
+			"advanced": map[string]interface{}{
+				"horizontalPodAutoscalerConfig": map[string]interface{}{"name": "keda-podinfo"},
+				"scalingModifiers":              map[string]interface{}{"formula": "rps + cron", "target": "10"},
+			},
+			"triggers": []interface{}{
+				map[string]interface{}{
+					"type":              "prometheus",
+					"name":              "rps",
+					"metadata":          map[string]interface{}{"metricName": "http_rps", "query": "sum(rate(http_requests_total[1m]))"},
+					"authenticationRef": map[string]interface{}{"name": "prometheus"},
+				},
+				map[string]interface{}{
+					"type":              "cron",
+					"name":              "cron",
+					"metadata":          map[string]interface{}{"timezone": "UTC", "desiredReplicas": "2"},
+					"authenticationRef": map[string]interface{}{"name": "shared", "kind": "ClusterTriggerAuthentication"},
+				},
+			},
+		},
+	}}
+	auth := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "keda.sh/v1alpha1",
+		"kind":       "TriggerAuthentication",
+		"metadata":   map[string]interface{}{"name": "prometheus", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"secretTargetRef": []interface{}{
+				map[string]interface{}{"parameter": "bearerToken", "name": "prometheus", "key": "token"},
+			},
+		},
+	}}
+	dynamicClient := fakeDynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{VerticalPodAutoscalerGVR: "VerticalPodAutoscalerList"}, so, auth)
+	mocks.controller.dynamicClient = dynamicClient
+	mocks.initializeCanary(t)
+
+	scaledObjects := dynamicClient.Resource(ScaledObjectGVR).Namespace("default")
+	primary, err := scaledObjects.Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	target, _, _ := unstructured.NestedString(primary.Object, "spec", "scaleTargetRef", "name")
+	assert.Equal(t, "podinfo-primary", target)
+	hpaName, _, _ := unstructured.NestedString(primary.Object, "spec", "advanced", "horizontalPodAutoscalerConfig", "name")
+	assert.Equal(t, "keda-podinfo-primary", hpaName)
+	formula, _, _ := unstructured.NestedString(primary.Object, "spec", "advanced", "scalingModifiers", "formula")
+	assert.Equal(t, "rps + cron", formula)
+	assert.NotContains(t, primary.GetAnnotations(), "autoscaling.keda.sh/paused-replicas")
+
+	triggers, _, _ := unstructured.NestedSlice(primary.Object, "spec", "triggers")
+	require.Len(t, triggers, 2)
+	metricName, _, _ := unstructured.NestedString(triggers[0].(map[string]interface{}), "metadata", "metricName")
+	assert.Equal(t, "http_rps-primary", metricName)
+	authName, _, _ := unstructured.NestedString(triggers[0].(map[string]interface{}), "authenticationRef", "name")
+	assert.Equal(t, "prometheus-primary", authName)
+	authName, _, _ = unstructured.NestedString(triggers[1].(map[string]interface{}), "authenticationRef", "name")
+	assert.Equal(t, "shared", authName)
+
+	_, err = dynamicClient.Resource(TriggerAuthenticationGVR).Namespace("default").Get(context.TODO(), "prometheus-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// the target ScaledObject is paused while the canary is scaled to zero
+	canarySo, err := scaledObjects.Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "0", canarySo.GetAnnotations()["autoscaling.keda.sh/paused-replicas"])
+
+	require.NoError(t, mocks.controller.ScaleFromZero(mocks.canary))
+	canarySo, err = scaledObjects.Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, canarySo.GetAnnotations(), "autoscaling.keda.sh/paused-replicas")
+
+	// the pause set by the owner applies to the primary
+	canarySo.SetAnnotations(map[string]string{"autoscaling.keda.sh/paused": "true"})
+	_, err = scaledObjects.Update(context.TODO(), canarySo, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, mocks.controller.Initialize(mocks.canary))
+	primary, err = scaledObjects.Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", primary.GetAnnotations()["autoscaling.keda.sh/paused"])
+
+	// override the primary replicas
+	mocks.canary.Spec.AutoscalerRef.PrimaryScalerReplicas = &flaggerv1.ScalerReplicas{MaxReplicas: int32p(20)}
+	require.NoError(t, mocks.controller.Initialize(mocks.canary))
+	primary, err = scaledObjects.Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	maxReplicas, _, _ := unstructured.NestedInt64(primary.Object, "spec", "maxReplicaCount")
+	assert.Equal(t, int64(20), maxReplicas)
+	assert.NotContains(t, primary.Object["spec"], "minReplicaCount")
+}
+
 func TestDeploymentController_ScaleToZero(t *testing.T) {
 	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
 	mocks := newDeploymentFixture(dc)
@@ -256,6 +739,107 @@ func TestDeploymentController_HasTargetChanged(t *testing.T) {
 	assert.True(t, isNew)
 }
 
+func TestDeploymentController_HasTargetChanged_EphemeralContainersAndHooks(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.initializeCanary(t)
+
+	// save last applied hash
+	canary, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	canary.Spec.LifecycleHooksPolicy = flaggerv1.IgnoreLifecycleHooksPolicy
+	err = mocks.controller.SyncStatus(canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitializing})
+	require.NoError(t, err)
+	canary, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	canary.Spec.LifecycleHooksPolicy = flaggerv1.IgnoreLifecycleHooksPolicy
+
+	// add a debug container and a lifecycle hook
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	depClone := dep.DeepCopy()
+	depClone.Spec.Template.Spec.EphemeralContainers = []corev1.EphemeralContainer{{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger", Image: "busybox"},
+	}}
+	depClone.Spec.Template.Spec.Containers[0].Lifecycle = &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{Exec: &corev1.ExecAction{Command: []string{"sleep", "5"}}},
+	}
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), depClone, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	isNew, err := mocks.controller.HasTargetChanged(canary)
+	require.NoError(t, err)
+	assert.False(t, isNew)
+
+	err = mocks.controller.Promote(canary)
+	require.NoError(t, err)
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, depPrimary.Spec.Template.Spec.EphemeralContainers)
+	assert.Nil(t, depPrimary.Spec.Template.Spec.Containers[0].Lifecycle)
+
+	// the hooks are copied and tracked by default
+	canary.Spec.LifecycleHooksPolicy = ""
+	isNew, err = mocks.controller.HasTargetChanged(canary)
+	require.NoError(t, err)
+	assert.True(t, isNew)
+
+	err = mocks.controller.Promote(canary)
+	require.NoError(t, err)
+	depPrimary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, depPrimary.Spec.Template.Spec.EphemeralContainers)
+	assert.NotNil(t, depPrimary.Spec.Template.Spec.Containers[0].Lifecycle)
+}
+
+func TestDeploymentController_HasTargetChanged_ImageTracking(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.initializeCanary(t)
+
+	// add a log shipper sidecar excluded from the image tracking
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	dep.Spec.Template.Spec.Containers = append(dep.Spec.Template.Spec.Containers,
+		corev1.Container{Name: "log-shipper", Image: "fluent/fluent-bit:1.8.0"})
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	mocks.canary.Spec.ImageTracking = &flaggerv1.ImageTracking{Exclude: []string{"log-shipper"}}
+	err = mocks.controller.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitialized})
+	require.NoError(t, err)
+	canary, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	canary.Spec.ImageTracking = mocks.canary.Spec.ImageTracking
+
+	// a sidecar image change doesn't trigger an analysis
+	dep, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	dep.Spec.Template.Spec.Containers[1].Image = "fluent/fluent-bit:1.9.0"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	isNew, err := mocks.controller.HasTargetChanged(canary)
+	require.NoError(t, err)
+	assert.False(t, isNew)
+
+	// an app image change triggers an analysis and is recorded in status
+	dep.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:1.5.0"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	isNew, err = mocks.controller.HasTargetChanged(canary)
+	require.NoError(t, err)
+	assert.True(t, isNew)
+
+	err = mocks.controller.SyncStatus(canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing})
+	require.NoError(t, err)
+	canary, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{dep.Spec.Template.Spec.Containers[0].Name: "quay.io/stefanprodan/podinfo:1.5.0"},
+		canary.Status.ChangedImages)
+}
+
 func TestDeploymentController_Finalize(t *testing.T) {
 	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
 	mocks := newDeploymentFixture(dc)
@@ -283,6 +867,25 @@ func TestDeploymentController_Finalize(t *testing.T) {
 	}
 }
 
+func TestDeploymentController_FinalizeFull(t *testing.T) {
+	dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+	mocks := newDeploymentFixture(dc)
+	mocks.initializeCanary(t)
+
+	_, err := mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.RevertPolicy = flaggerv1.FullRevertPolicy
+	err = mocks.controller.Finalize(cd)
+	require.NoError(t, err)
+
+	_, err = mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	_, err = mocks.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
 func TestDeploymentController_AntiAffinityAndTopologySpreadConstraints(t *testing.T) {
 	t.Run("deployment", func(t *testing.T) {
 		dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
@@ -312,4 +915,34 @@ func TestDeploymentController_AntiAffinityAndTopologySpreadConstraints(t *testin
 		value = topologySpreadConstraints[1].LabelSelector.MatchExpressions[0].Values[0]
 		assert.False(t, strings.HasSuffix(value, "-primary"))
 	})
+
+	t.Run("primary anti-affinity", func(t *testing.T) {
+		dc := deploymentConfigs{name: "podinfo", label: "name", labelValue: "podinfo"}
+		mocks := newDeploymentFixture(dc)
+		mocks.canary.Spec.PrimaryAntiAffinity = &flaggerv1.PrimaryAntiAffinity{
+			TopologyKey: "topology.kubernetes.io/zone",
+			Required:    true,
+		}
+		mocks.initializeCanary(t)
+
+		depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+		require.NoError(t, err)
+
+		requiredConstraints := depPrimary.Spec.Template.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		require.Len(t, requiredConstraints, 3)
+		injected := requiredConstraints[2]
+		assert.Equal(t, "topology.kubernetes.io/zone", injected.TopologyKey)
+		assert.Equal(t, map[string]string{"name": "podinfo"}, injected.LabelSelector.MatchLabels)
+
+		// the injected term is kept on promotion
+		mocks.canary.Spec.PrimaryAntiAffinity.Required = false
+		require.NoError(t, mocks.controller.Promote(mocks.canary))
+		depPrimary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+		require.NoError(t, err)
+		antiAffinity := depPrimary.Spec.Template.Spec.Affinity.PodAntiAffinity
+		assert.Len(t, antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, 2)
+		preferred := antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[len(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution)-1]
+		assert.Equal(t, int32(100), preferred.Weight)
+		assert.Equal(t, map[string]string{"name": "podinfo"}, preferred.PodAffinityTerm.LabelSelector.MatchLabels)
+	})
 }
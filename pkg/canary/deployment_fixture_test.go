@@ -18,7 +18,6 @@ package canary
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -59,7 +58,8 @@ func (d deploymentControllerFixture) initializeCanary(t *testing.T) {
 	err := d.controller.Initialize(d.canary)
 	require.Error(t, err) // not ready yet
 
-	primaryName := fmt.Sprintf("%s-primary", d.canary.Spec.TargetRef.Name)
+	primaryName, err := d.canary.GetPrimaryName(d.canary.Spec.TargetRef.Name)
+	require.NoError(t, err)
 	p, err := d.controller.kubeClient.AppsV1().
 		Deployments(d.canary.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 	require.NoError(t, err)
@@ -397,7 +397,7 @@ func newDeploymentControllerTestCanary(cc canaryConfigs) *flaggerv1.Canary {
 				APIVersion: "apps/v1",
 				Kind:       "Deployment",
 			},
-			AutoscalerRef: &flaggerv1.LocalObjectReference{
+			AutoscalerRef: &flaggerv1.AutoscalerReference{
 				Name:       "podinfo",
 				APIVersion: "autoscaling/v2beta2",
 				Kind:       "HorizontalPodAutoscaler",
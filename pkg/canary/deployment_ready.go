@@ -31,7 +31,10 @@ import (
 // the deployment is in the middle of a rolling update or if the pods are unhealthy
 // it will return a non retryable error if the rolling update is stuck
 func (c *DeploymentController) IsPrimaryReady(cd *flaggerv1.Canary) error {
-	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
 	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
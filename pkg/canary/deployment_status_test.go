@@ -43,6 +43,15 @@ func TestDeploymentController_SyncStatus(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, status.Phase, res.Status.Phase)
 	assert.Equal(t, status.FailedChecks, res.Status.FailedChecks)
+	assert.NotEmpty(t, res.Status.RunID)
+	assert.False(t, res.Status.RunStartTime.IsZero())
+
+	// a restarted analysis gets a new run ID
+	err = mocks.controller.SyncStatus(res, status)
+	require.NoError(t, err)
+	restarted, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, res.Status.RunID, restarted.Status.RunID)
 
 	require.NotNil(t, res.Status.TrackedConfigs)
 	configs := *res.Status.TrackedConfigs
@@ -18,6 +18,7 @@ package canary
 
 import (
 	"go.uber.org/zap"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
@@ -25,6 +26,7 @@ import (
 
 type Factory struct {
 	kubeClient         kubernetes.Interface
+	dynamicClient      dynamic.Interface
 	flaggerClient      clientset.Interface
 	logger             *zap.SugaredLogger
 	configTracker      Tracker
@@ -48,10 +50,46 @@ func NewFactory(kubeClient kubernetes.Interface,
 	}
 }
 
+// WithDynamicClient returns a copy of the factory that reads the
+// workloads managed by other controllers, such as Argo Rollouts, with the given client
+func (factory *Factory) WithDynamicClient(dynamicClient dynamic.Interface) *Factory {
+	f := *factory
+	f.dynamicClient = dynamicClient
+	if tracker, ok := factory.configTracker.(*ConfigTracker); ok {
+		t := *tracker
+		t.DynamicClient = dynamicClient
+		f.configTracker = &t
+	}
+	return &f
+}
+
+// ForCluster returns a copy of the factory that manages the workloads
+// and tracks the configs in the cluster of the given clients
+func (factory *Factory) ForCluster(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface) *Factory {
+	f := *factory.WithDynamicClient(dynamicClient)
+	f.kubeClient = kubeClient
+	if tracker, ok := f.configTracker.(*ConfigTracker); ok {
+		tracker.KubeClient = kubeClient
+	}
+	return &f
+}
+
+// IsSupportedKind returns true if the kind of the canary target is supported natively,
+// the other kinds are managed as custom workloads with the scale subresource
+func IsSupportedKind(kind string) bool {
+	switch kind {
+	case "Deployment", "DaemonSet", "StatefulSet", "Rollout", "Service":
+		return true
+	default:
+		return false
+	}
+}
+
 func (factory *Factory) Controller(kind string) Controller {
 	deploymentCtrl := &DeploymentController{
 		logger:             factory.logger,
 		kubeClient:         factory.kubeClient,
+		dynamicClient:      factory.dynamicClient,
 		flaggerClient:      factory.flaggerClient,
 		labels:             factory.labels,
 		configTracker:      factory.configTracker,
@@ -65,6 +103,20 @@ func (factory *Factory) Controller(kind string) Controller {
 		configTracker:      factory.configTracker,
 		includeLabelPrefix: factory.includeLabelPrefix,
 	}
+	statefulSetCtrl := &StatefulSetController{
+		logger:             factory.logger,
+		kubeClient:         factory.kubeClient,
+		flaggerClient:      factory.flaggerClient,
+		labels:             factory.labels,
+		configTracker:      factory.configTracker,
+		includeLabelPrefix: factory.includeLabelPrefix,
+	}
+	rolloutCtrl := &RolloutController{
+		DeploymentController: deploymentCtrl,
+	}
+	customWorkloadCtrl := &CustomWorkloadController{
+		DeploymentController: deploymentCtrl,
+	}
 	serviceCtrl := &ServiceController{
 		logger:             factory.logger,
 		kubeClient:         factory.kubeClient,
@@ -75,11 +127,17 @@ func (factory *Factory) Controller(kind string) Controller {
 	switch kind {
 	case "DaemonSet":
 		return daemonSetCtrl
+	case "StatefulSet":
+		return statefulSetCtrl
 	case "Deployment":
 		return deploymentCtrl
+	case "Rollout":
+		return rolloutCtrl
 	case "Service":
 		return serviceCtrl
-	default:
+	case "":
 		return deploymentCtrl
+	default:
+		return customWorkloadCtrl
 	}
 }
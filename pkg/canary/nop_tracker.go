@@ -41,6 +41,6 @@ func (nt *NopTracker) CreatePrimaryConfigs(*flaggerv1.Canary, map[string]ConfigR
 	return nil
 }
 
-func (nt *NopTracker) ApplyPrimaryConfigs(spec corev1.PodSpec, _ map[string]ConfigRef) corev1.PodSpec {
-	return spec
+func (nt *NopTracker) ApplyPrimaryConfigs(_ *flaggerv1.Canary, spec corev1.PodSpec, _ map[string]ConfigRef) (corev1.PodSpec, error) {
+	return spec, nil
 }
@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// reconcilePrimaryPDBs creates or updates a PodDisruptionBudget for the primary pods
+// for each PodDisruptionBudget that selects the canary pods but not the primary ones,
+// and removes the primary copies of the budgets that no longer select the canary pods
+func (c *DeploymentController) reconcilePrimaryPDBs(cd *flaggerv1.Canary) error {
+	targetName := cd.Spec.TargetRef.Name
+	canaryDep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("deployment %s.%s get query error: %w", targetName, cd.Namespace, err)
+	}
+
+	label, labelValue, err := c.getSelectorLabel(canaryDep)
+	if err != nil {
+		return fmt.Errorf("getSelectorLabel failed: %w", err)
+	}
+	primaryLabelValue, err := cd.GetPrimaryLabelValue(labelValue)
+	if err != nil {
+		return err
+	}
+	podLabels := labels.Set(canaryDep.Spec.Template.Labels)
+	primaryPodLabels := labels.Set(makePrimaryLabels(canaryDep.Spec.Template.Labels, primaryLabelValue, label))
+
+	pdbs, err := c.kubeClient.PolicyV1().PodDisruptionBudgets(cd.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("PodDisruptionBudgets %s list query error: %w", cd.Namespace, err)
+	}
+
+	primaryNames := make(map[string]bool)
+	for i := range pdbs.Items {
+		pdb := &pdbs.Items[i]
+		if owner := metav1.GetControllerOf(pdb); owner != nil && owner.Kind == flaggerv1.CanaryKind {
+			continue
+		}
+		if pdb.Spec.Selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			continue
+		}
+		// the budgets that select the primary pods already protect them
+		if !selector.Matches(podLabels) || selector.Matches(primaryPodLabels) {
+			continue
+		}
+
+		primaryName, err := cd.GetPrimaryName(pdb.Name)
+		if err != nil {
+			return err
+		}
+		primaryNames[primaryName] = true
+		spec := policyv1.PodDisruptionBudgetSpec{
+			MinAvailable:   pdb.Spec.MinAvailable,
+			MaxUnavailable: pdb.Spec.MaxUnavailable,
+			Selector:       makePrimarySelector(pdb.Spec.Selector, label, labelValue, primaryLabelValue),
+		}
+		if err := c.reconcilePrimaryPDB(cd, pdb, primaryName, spec); err != nil {
+			return err
+		}
+	}
+
+	// remove the copies of the deleted budgets
+	for i := range pdbs.Items {
+		pdb := &pdbs.Items[i]
+		if !metav1.IsControlledBy(pdb, cd) || primaryNames[pdb.Name] {
+			continue
+		}
+		err := c.kubeClient.PolicyV1().PodDisruptionBudgets(cd.Namespace).Delete(context.TODO(), pdb.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("deleting PodDisruptionBudget %s.%s failed: %w", pdb.Name, cd.Namespace, err)
+		}
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("PodDisruptionBudget %s.%s deleted", pdb.Name, cd.Namespace)
+	}
+	return nil
+}
+
+func (c *DeploymentController) reconcilePrimaryPDB(cd *flaggerv1.Canary, pdb *policyv1.PodDisruptionBudget,
+	primaryName string, spec policyv1.PodDisruptionBudgetSpec) error {
+	primaryPdb, err := c.kubeClient.PolicyV1().PodDisruptionBudgets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		primaryPdb = &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        primaryName,
+				Namespace:   cd.Namespace,
+				Labels:      propagateMetadata(cd.GetLabelPropagation(), pdb.Labels, filterMetadata(pdb.Labels)),
+				Annotations: propagateMetadata(cd.GetAnnotationPropagation(), pdb.Annotations, filterMetadata(pdb.Annotations)),
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: spec,
+		}
+
+		_, err = c.kubeClient.PolicyV1().PodDisruptionBudgets(cd.Namespace).Create(context.TODO(), primaryPdb, metav1.CreateOptions{FieldManager: cd.FieldManager()})
+		if err != nil {
+			return fmt.Errorf("creating PodDisruptionBudget %s.%s failed: %w", primaryName, cd.Namespace, err)
+		}
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("PodDisruptionBudget %s.%s created", primaryName, cd.Namespace)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("PodDisruptionBudget %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	if equality.Semantic.DeepEqual(spec, primaryPdb.Spec) {
+		return nil
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		primaryPdb, err := c.kubeClient.PolicyV1().PodDisruptionBudgets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		pdbClone := primaryPdb.DeepCopy()
+		pdbClone.Spec = spec
+		pdbClone.ObjectMeta.Labels = propagateMetadata(cd.GetLabelPropagation(), pdb.Labels, filterMetadata(pdb.Labels))
+		pdbClone.ObjectMeta.Annotations = propagateMetadata(cd.GetAnnotationPropagation(), pdb.Annotations, filterMetadata(pdb.Annotations))
+
+		_, err = c.kubeClient.PolicyV1().PodDisruptionBudgets(cd.Namespace).Update(context.TODO(), pdbClone, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("updating PodDisruptionBudget %s.%s failed: %w", primaryName, cd.Namespace, err)
+	}
+	c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Infof("PodDisruptionBudget %s.%s updated", primaryName, cd.Namespace)
+	return nil
+}
+
+// makePrimarySelector returns a copy of the label selector where the
+// selector label value of the canary pods is replaced with the primary one
+func makePrimarySelector(selector *metav1.LabelSelector, label, labelValue, primaryLabelValue string) *metav1.LabelSelector {
+	out := selector.DeepCopy()
+	for key, value := range out.MatchLabels {
+		if key == label && value == labelValue {
+			out.MatchLabels[key] = primaryLabelValue
+		}
+	}
+	for _, expr := range out.MatchExpressions {
+		if expr.Key != label {
+			continue
+		}
+		for i := range expr.Values {
+			if expr.Values[i] == labelValue {
+				expr.Values[i] = primaryLabelValue
+			}
+		}
+	}
+	return out
+}
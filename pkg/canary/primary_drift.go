@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// primaryTemplateHashAnnotation holds the hash of the primary pod template as applied by Flagger
+const primaryTemplateHashAnnotation = "flagger.app/primary-template-hash"
+
+// legacyPrimaryTemplateAnnotation held the whole primary pod template, it is removed when the hash is recorded
+const legacyPrimaryTemplateAnnotation = "flagger.app/primary-template"
+
+// SyncPrimaryDrift compares the hash of the primary pod template with the one applied by Flagger and
+// reverts or adopts the out-of-band changes according to the canary conflict policy,
+// it returns true if the primary had drifted.
+// The pod template of the last promoted revision is restored on revert, an adopted template
+// replaces the recorded revision so that the later reverts and rollbacks restore it
+func (c *DeploymentController) SyncPrimaryDrift(cd *flaggerv1.Canary) (bool, error) {
+	if cd.Spec.ConflictPolicy == "" {
+		return false, nil
+	}
+
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return false, err
+	}
+	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	applied, ok := primary.Annotations[primaryTemplateHashAnnotation]
+	if !ok {
+		// the conflict policy was set after the last promotion
+		return false, c.recordPrimaryTemplate(cd, primary)
+	}
+	if applied == computeHash(primary.Spec.Template) {
+		return false, nil
+	}
+
+	if cd.Spec.ConflictPolicy == flaggerv1.AdoptConflictPolicy {
+		if cd.Status.LastPromotedSpec != "" {
+			if err := recordPromotedTemplate(c.kubeClient, cd, cd.Status.LastPromotedSpec, primary.Spec.Template); err != nil {
+				return false, err
+			}
+		}
+		return true, c.recordPrimaryTemplate(cd, primary)
+	}
+
+	primaryCopy := primary.DeepCopy()
+	if _, err := restorePromotedTemplate(c.kubeClient, cd, &primaryCopy.Spec.Template); err != nil {
+		return false, err
+	}
+	primary, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+	if err != nil {
+		return false, fmt.Errorf("reverting deployment %s.%s template failed: %w", primaryName, cd.Namespace, err)
+	}
+	return true, c.recordPrimaryTemplate(cd, primary)
+}
+
+// recordPrimaryTemplate stores the hash of the primary pod template, as returned by the API server,
+// in the primary annotations when a conflict policy is set
+func (c *DeploymentController) recordPrimaryTemplate(cd *flaggerv1.Canary, primary *appsv1.Deployment) error {
+	if cd.Spec.ConflictPolicy == "" {
+		return nil
+	}
+
+	primaryCopy := primary.DeepCopy()
+	if primaryCopy.Annotations == nil {
+		primaryCopy.Annotations = make(map[string]string)
+	}
+	delete(primaryCopy.Annotations, legacyPrimaryTemplateAnnotation)
+	primaryCopy.Annotations[primaryTemplateHashAnnotation] = computeHash(primary.Spec.Template)
+	_, err := c.kubeClient.AppsV1().Deployments(primary.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+	if err != nil {
+		return fmt.Errorf("deployment %s.%s update error: %w", primary.Name, primary.Namespace, err)
+	}
+	return nil
+}
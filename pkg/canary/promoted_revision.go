@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// promotedSpecAnnotation holds the checksum of the canary pod template the recorded revision was promoted from
+const promotedSpecAnnotation = "flagger.app/promoted-spec"
+
+// getPromotedRevisionName returns the name of the controller revision
+// that holds the primary pod template of the last promoted revision
+func getPromotedRevisionName(cd *flaggerv1.Canary) string {
+	return fmt.Sprintf("%s-promoted", cd.Name)
+}
+
+// recordPromotedTemplate stores the primary pod template of the promoted revision in a controller revision
+// owned by the canary, the revision is annotated with the checksum of the canary pod template
+func recordPromotedTemplate(kubeClient kubernetes.Interface, cd *flaggerv1.Canary, spec string, template corev1.PodTemplateSpec) error {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("pod template marshal failed: %w", err)
+	}
+
+	name := getPromotedRevisionName(cd)
+	revision := &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   cd.Namespace,
+			Labels:      makeAuditLabels(cd, nil),
+			Annotations: map[string]string{promotedSpecAnnotation: spec},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+					Group:   flaggerv1.SchemeGroupVersion.Group,
+					Version: flaggerv1.SchemeGroupVersion.Version,
+					Kind:    flaggerv1.CanaryKind,
+				}),
+			},
+		},
+		Data:     runtime.RawExtension{Raw: data},
+		Revision: 1,
+	}
+
+	existing, err := kubeClient.AppsV1().ControllerRevisions(cd.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		if _, err := kubeClient.AppsV1().ControllerRevisions(cd.Namespace).Create(context.TODO(), revision, metav1.CreateOptions{FieldManager: cd.FieldManager()}); err != nil {
+			return fmt.Errorf("creating controllerrevision %s.%s failed: %w", name, cd.Namespace, err)
+		}
+	case err != nil:
+		return fmt.Errorf("controllerrevision %s.%s get query error: %w", name, cd.Namespace, err)
+	default:
+		if !metav1.IsControlledBy(existing, cd) {
+			return fmt.Errorf("controllerrevision %s.%s is not owned by the canary", name, cd.Namespace)
+		}
+		revision.ResourceVersion = existing.ResourceVersion
+		revision.Revision = existing.Revision + 1
+		if _, err := kubeClient.AppsV1().ControllerRevisions(cd.Namespace).Update(context.TODO(), revision, metav1.UpdateOptions{FieldManager: cd.FieldManager()}); err != nil {
+			return fmt.Errorf("updating controllerrevision %s.%s failed: %w", name, cd.Namespace, err)
+		}
+	}
+	return nil
+}
+
+// getPromotedTemplate returns the primary pod template recorded for the last promoted revision,
+// it returns nil if no template has been recorded and an error if the recorded template
+// doesn't match the last promoted revision
+func getPromotedTemplate(kubeClient kubernetes.Interface, cd *flaggerv1.Canary) (*corev1.PodTemplateSpec, error) {
+	name := getPromotedRevisionName(cd)
+	revision, err := kubeClient.AppsV1().ControllerRevisions(cd.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("controllerrevision %s.%s get query error: %w", name, cd.Namespace, err)
+	}
+	if !metav1.IsControlledBy(revision, cd) {
+		return nil, nil
+	}
+
+	if spec := revision.Annotations[promotedSpecAnnotation]; spec != cd.Status.LastPromotedSpec {
+		return nil, fmt.Errorf("controllerrevision %s.%s holds the revision %s instead of the last promoted revision %s",
+			name, cd.Namespace, spec, cd.Status.LastPromotedSpec)
+	}
+
+	var template corev1.PodTemplateSpec
+	if err := json.Unmarshal(revision.Data.Raw, &template); err != nil {
+		return nil, fmt.Errorf("controllerrevision %s.%s pod template unmarshal failed: %w", name, cd.Namespace, err)
+	}
+	return &template, nil
+}
+
+// restorePromotedTemplate sets the pod template of the last promoted revision on the primary template,
+// the container images are restored when no template was recorded.
+// It returns true if the primary template has been changed
+func restorePromotedTemplate(kubeClient kubernetes.Interface, cd *flaggerv1.Canary, primary *corev1.PodTemplateSpec) (bool, error) {
+	template, err := getPromotedTemplate(kubeClient, cd)
+	if err != nil {
+		return false, err
+	}
+	if template == nil {
+		return restoreImages(&primary.Spec, cd.Status.LastPromotedImages), nil
+	}
+	if equality.Semantic.DeepEqual(*template, *primary) {
+		return false, nil
+	}
+	*primary = *template
+	return true, nil
+}
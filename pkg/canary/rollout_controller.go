@@ -0,0 +1,288 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// RolloutGVR is the resource of the Argo Rollouts targets
+var RolloutGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+
+// argoRollout is the subset of an Argo Rollout read by Flagger
+type argoRollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   argoRolloutSpec   `json:"spec"`
+	Status argoRolloutStatus `json:"status,omitempty"`
+}
+
+type argoRolloutSpec struct {
+	Replicas                *int32                 `json:"replicas,omitempty"`
+	Selector                *metav1.LabelSelector  `json:"selector,omitempty"`
+	Template                corev1.PodTemplateSpec `json:"template,omitempty"`
+	WorkloadRef             *argoWorkloadRef       `json:"workloadRef,omitempty"`
+	MinReadySeconds         int32                  `json:"minReadySeconds,omitempty"`
+	RevisionHistoryLimit    *int32                 `json:"revisionHistoryLimit,omitempty"`
+	ProgressDeadlineSeconds *int32                 `json:"progressDeadlineSeconds,omitempty"`
+	Paused                  bool                   `json:"paused,omitempty"`
+}
+
+type argoWorkloadRef struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+type argoRolloutStatus struct {
+	Replicas          int32  `json:"replicas,omitempty"`
+	UpdatedReplicas   int32  `json:"updatedReplicas,omitempty"`
+	ReadyReplicas     int32  `json:"readyReplicas,omitempty"`
+	AvailableReplicas int32  `json:"availableReplicas,omitempty"`
+	Phase             string `json:"phase,omitempty"`
+	Message           string `json:"message,omitempty"`
+}
+
+// deployment returns a view of the rollout as a deployment, Flagger never writes it back
+func (r *argoRollout) deployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        r.Name,
+			Namespace:   r.Namespace,
+			Labels:      r.Labels,
+			Annotations: r.Annotations,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas:                r.Spec.Replicas,
+			Selector:                r.Spec.Selector,
+			Template:                r.Spec.Template,
+			MinReadySeconds:         r.Spec.MinReadySeconds,
+			RevisionHistoryLimit:    r.Spec.RevisionHistoryLimit,
+			ProgressDeadlineSeconds: r.Spec.ProgressDeadlineSeconds,
+		},
+	}
+}
+
+// getRollout fetches the rollout, the pod template of a rollout that references
+// a deployment with spec.workloadRef is read from that deployment
+func getRollout(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface, name string, namespace string) (*argoRollout, error) {
+	if dynamicClient == nil {
+		return nil, fmt.Errorf("rollout %s.%s get query error: dynamic client not configured", name, namespace)
+	}
+	obj, err := dynamicClient.Resource(RolloutGVR).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("rollout %s.%s get query error: %w", name, namespace, err)
+	}
+
+	rollout := &argoRollout{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), rollout); err != nil {
+		return nil, fmt.Errorf("rollout %s.%s decoding failed: %w", name, namespace, err)
+	}
+
+	if ref := rollout.Spec.WorkloadRef; ref != nil {
+		if ref.Kind != "Deployment" {
+			return nil, fmt.Errorf("rollout %s.%s workloadRef kind %s is not supported", name, namespace, ref.Kind)
+		}
+		dep, err := kubeClient.AppsV1().Deployments(namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("deployment %s.%s get query error: %w", ref.Name, namespace, err)
+		}
+		rollout.Spec.Template = dep.Spec.Template
+		if rollout.Spec.Selector == nil {
+			rollout.Spec.Selector = dep.Spec.Selector
+		}
+	}
+
+	if rollout.Spec.Selector == nil {
+		return nil, fmt.Errorf("rollout %s.%s spec.selector is not set", name, namespace)
+	}
+	return rollout, nil
+}
+
+// RolloutController is managing the operations for Argo Rollouts, the pod template
+// of the rollout is managed by Argo Rollouts and is never written by Flagger,
+// Flagger creates a primary deployment from it and only scales the rollout
+type RolloutController struct {
+	*DeploymentController
+}
+
+// Initialize creates the primary deployment from the rollout pod template
+// and scales the rollout to zero
+func (c *RolloutController) Initialize(cd *flaggerv1.Canary) error {
+	if cd.Spec.AutoscalerRef != nil {
+		return fmt.Errorf("autoscalerRef is not supported for Rollout targets")
+	}
+
+	rollout, err := getRollout(c.kubeClient, c.dynamicClient, cd.Spec.TargetRef.Name, cd.Namespace)
+	if err != nil {
+		return err
+	}
+
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	_, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if err := c.createPrimaryDeploymentFrom(cd, rollout.deployment(), c.includeLabelPrefix); err != nil {
+			return fmt.Errorf("createPrimaryDeployment failed: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	if cd.Status.Phase == "" || cd.Status.Phase == flaggerv1.CanaryPhaseInitializing {
+		if !cd.SkipAnalysis() {
+			if err := c.IsPrimaryReady(cd); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("Scaling down Rollout %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
+		if err := c.ScaleToZero(cd); err != nil {
+			return fmt.Errorf("scaling down canary rollout %s.%s failed: %w", cd.Spec.TargetRef.Name, cd.Namespace, err)
+		}
+	}
+	return nil
+}
+
+// Promote copies the rollout pod template, secrets and config maps to the primary deployment
+func (c *RolloutController) Promote(cd *flaggerv1.Canary) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		rollout, err := getRollout(c.kubeClient, c.dynamicClient, cd.Spec.TargetRef.Name, cd.Namespace)
+		if err != nil {
+			return err
+		}
+		return c.promoteDeployment(cd, rollout.deployment())
+	})
+	if err != nil {
+		return fmt.Errorf("updating deployment %s.%s template spec failed: %w",
+			primaryName, cd.Namespace, err)
+	}
+	return nil
+}
+
+// GetPodTemplate returns the pod template of the rollout
+func (c *RolloutController) GetPodTemplate(cd *flaggerv1.Canary) (*corev1.PodTemplateSpec, error) {
+	rollout, err := getRollout(c.kubeClient, c.dynamicClient, cd.Spec.TargetRef.Name, cd.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &rollout.Spec.Template, nil
+}
+
+// HasTargetChanged returns true if the pod template managed by Argo Rollouts has changed
+func (c *RolloutController) HasTargetChanged(cd *flaggerv1.Canary) (bool, error) {
+	rollout, err := getRollout(c.kubeClient, c.dynamicClient, cd.Spec.TargetRef.Name, cd.Namespace)
+	if err != nil {
+		return false, err
+	}
+	return hasSpecChanged(cd, hashedPodTemplate(cd, rollout.Spec.Template))
+}
+
+// ScaleToZero sets the rollout replicas to zero
+func (c *RolloutController) ScaleToZero(cd *flaggerv1.Canary) error {
+	return c.scale(cd, 0)
+}
+
+// ScaleFromZero sets the rollout replicas to the primary replicas
+func (c *RolloutController) ScaleFromZero(cd *flaggerv1.Canary) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	replicas := int32(1)
+	if primary.Spec.Replicas != nil && *primary.Spec.Replicas > 0 {
+		replicas = *primary.Spec.Replicas
+	}
+	return c.scale(cd, replicas)
+}
+
+// scale patches the rollout replicas, the rest of the rollout spec is left to Argo Rollouts
+func (c *RolloutController) scale(cd *flaggerv1.Canary, replicas int32) error {
+	if c.dynamicClient == nil {
+		return fmt.Errorf("rollout %s.%s scaling failed: dynamic client not configured", cd.Spec.TargetRef.Name, cd.Namespace)
+	}
+	patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+	_, err := c.dynamicClient.Resource(RolloutGVR).Namespace(cd.Namespace).Patch(context.TODO(),
+		cd.Spec.TargetRef.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: cd.FieldManager()})
+	if err != nil {
+		return fmt.Errorf("scaling rollout %s.%s to %v failed: %w", cd.Spec.TargetRef.Name, cd.Namespace, replicas, err)
+	}
+	return nil
+}
+
+// GetMetadata returns the pod label selector and svc ports
+func (c *RolloutController) GetMetadata(cd *flaggerv1.Canary) (string, string, map[string]int32, error) {
+	rollout, err := getRollout(c.kubeClient, c.dynamicClient, cd.Spec.TargetRef.Name, cd.Namespace)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	label, labelValue, err := c.getSelectorLabel(rollout.deployment())
+	if err != nil {
+		return "", "", nil, fmt.Errorf("getSelectorLabel failed: %w", err)
+	}
+
+	var ports map[string]int32
+	if cd.Spec.Service.PortDiscovery {
+		ports = getPorts(cd, rollout.Spec.Template.Spec.Containers)
+	}
+	return label, labelValue, ports, nil
+}
+
+// Finalize scales the rollout to the primary replicas so that Argo Rollouts
+// takes over the workload once the canary is deleted
+func (c *RolloutController) Finalize(cd *flaggerv1.Canary) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return c.scale(cd, 1)
+		}
+		return fmt.Errorf("deployment %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+	return c.scale(cd, int32Default(primary.Spec.Replicas))
+}
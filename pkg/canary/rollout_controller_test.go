@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestRolloutController_Initialize(t *testing.T) {
+	mocks := newRolloutFixture(newRolloutControllerTestPodInfo())
+	mocks.initializeCanary(t)
+
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "podinfo-primary", primary.Spec.Selector.MatchLabels["app"])
+	assert.Equal(t, "podinfo-primary", primary.Spec.Template.Labels["app"])
+	assert.Equal(t, int32(2), *primary.Spec.Replicas)
+	assert.Equal(t, "podinfo-config-env-primary", primary.Spec.Template.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Name)
+	assert.Equal(t, "podinfo-secret-env-primary", primary.Spec.Template.Spec.Containers[0].EnvFrom[1].SecretRef.Name)
+
+	// only the replicas of the rollout are changed
+	rollout := mocks.getRollout(t)
+	replicas, _, _ := unstructured.NestedInt64(rollout.Object, "spec", "replicas")
+	assert.Equal(t, int64(0), replicas)
+	assert.Equal(t, newRolloutControllerTestTemplate(), rollout.Object["spec"].(map[string]interface{})["template"])
+	_, ok, _ := unstructured.NestedMap(rollout.Object, "spec", "strategy", "canary")
+	assert.True(t, ok)
+
+	label, labelValue, ports, err := mocks.controller.GetMetadata(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, "app", label)
+	assert.Equal(t, "podinfo", labelValue)
+	assert.Nil(t, ports)
+}
+
+func TestRolloutController_AutoscalerRef(t *testing.T) {
+	mocks := newRolloutFixture(newRolloutControllerTestPodInfo())
+	mocks.canary.Spec.AutoscalerRef = &flaggerv1.AutoscalerReference{Kind: "HorizontalPodAutoscaler", Name: "podinfo"}
+	assert.Error(t, mocks.controller.Initialize(mocks.canary))
+}
+
+func TestRolloutController_Promote(t *testing.T) {
+	mocks := newRolloutFixture(newRolloutControllerTestPodInfo())
+	mocks.initializeCanary(t)
+	require.NoError(t, mocks.controller.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitialized}))
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// Argo Rollouts or a GitOps tool changes the pod template
+	rollout := mocks.getRollout(t)
+	containers, _, _ := unstructured.NestedSlice(rollout.Object, "spec", "template", "spec", "containers")
+	containers[0].(map[string]interface{})["image"] = "quay.io/stefanprodan/podinfo:1.2.1"
+	require.NoError(t, unstructured.SetNestedSlice(rollout.Object, containers, "spec", "template", "spec", "containers"))
+	_, err = mocks.dynamicClient.Resource(RolloutGVR).Namespace("default").Update(context.TODO(), rollout, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err := mocks.controller.HasTargetChanged(cd)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	require.NoError(t, mocks.controller.ScaleFromZero(cd))
+	replicas, _, _ := unstructured.NestedInt64(mocks.getRollout(t).Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas)
+
+	require.NoError(t, mocks.controller.Promote(cd))
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:1.2.1", primary.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "podinfo-primary", primary.Spec.Template.Labels["app"])
+
+	require.NoError(t, mocks.controller.Finalize(cd))
+	replicas, _, _ = unstructured.NestedInt64(mocks.getRollout(t).Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas)
+}
+
+func TestRolloutController_WorkloadRef(t *testing.T) {
+	rollout, dep := newRolloutControllerTestWorkloadRef()
+	mocks := newRolloutFixture(rollout, dep)
+	mocks.initializeCanary(t)
+
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:1.3.0", primary.Spec.Template.Spec.Containers[0].Image)
+
+	// the referenced deployment is left to Argo Rollouts
+	dep, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-template", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *dep.Spec.Replicas)
+}
+
+func TestRolloutController_IsCanaryReady(t *testing.T) {
+	mocks := newRolloutFixture(newRolloutControllerTestPodInfo())
+
+	r := &argoRollout{Spec: argoRolloutSpec{Replicas: int32p(2)}}
+	r.Status = argoRolloutStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 1}
+	retryable, err := mocks.controller.isRolloutReady(r, 100)
+	require.Error(t, err)
+	assert.True(t, retryable)
+
+	r.Status = argoRolloutStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2, Phase: "Healthy"}
+	_, err = mocks.controller.isRolloutReady(r, 100)
+	require.NoError(t, err)
+
+	r.Status.Phase = "Paused"
+	retryable, err = mocks.controller.isRolloutReady(r, 100)
+	require.Error(t, err)
+	assert.True(t, retryable)
+
+	r.Status.Phase = "Degraded"
+	retryable, err = mocks.controller.isRolloutReady(r, 100)
+	require.Error(t, err)
+	assert.False(t, retryable)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
+	fakeFlagger "github.com/fluxcd/flagger/pkg/client/clientset/versioned/fake"
+	"github.com/fluxcd/flagger/pkg/logger"
+)
+
+type rolloutControllerFixture struct {
+	canary        *flaggerv1.Canary
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	flaggerClient clientset.Interface
+	controller    RolloutController
+}
+
+func (f rolloutControllerFixture) initializeCanary(t *testing.T) {
+	err := f.controller.Initialize(f.canary)
+	require.Error(t, err) // not ready yet
+
+	p, err := f.kubeClient.AppsV1().Deployments(f.canary.Namespace).Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	p.Status = appsv1.DeploymentStatus{
+		Replicas:          2,
+		UpdatedReplicas:   2,
+		ReadyReplicas:     2,
+		AvailableReplicas: 2,
+	}
+	_, err = f.kubeClient.AppsV1().Deployments(f.canary.Namespace).Update(context.TODO(), p, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, f.controller.Initialize(f.canary))
+}
+
+// getRollout returns the rollout object stored by the dynamic client
+func (f rolloutControllerFixture) getRollout(t *testing.T) *unstructured.Unstructured {
+	obj, err := f.dynamicClient.Resource(RolloutGVR).Namespace(f.canary.Namespace).Get(context.TODO(), f.canary.Spec.TargetRef.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	return obj
+}
+
+func newRolloutFixture(rollout *unstructured.Unstructured, objects ...runtime.Object) rolloutControllerFixture {
+	canary := newRolloutControllerTestCanary()
+	flaggerClient := fakeFlagger.NewSimpleClientset(canary)
+	kubeClient := fake.NewSimpleClientset(append([]runtime.Object{
+		newDaemonSetControllerTestConfigMap(),
+		newDaemonSetControllerTestSecret(),
+	}, objects...)...)
+	dynamicClient := fakeDynamic.NewSimpleDynamicClient(runtime.NewScheme(), rollout)
+
+	logger, _ := logger.NewLogger("debug")
+	ctrl := RolloutController{
+		DeploymentController: &DeploymentController{
+			flaggerClient: flaggerClient,
+			kubeClient:    kubeClient,
+			dynamicClient: dynamicClient,
+			logger:        logger,
+			labels:        []string{"app", "name"},
+			configTracker: &ConfigTracker{
+				Logger:        logger,
+				KubeClient:    kubeClient,
+				DynamicClient: dynamicClient,
+				FlaggerClient: flaggerClient,
+			},
+		},
+	}
+
+	return rolloutControllerFixture{
+		canary:        canary,
+		controller:    ctrl,
+		flaggerClient: flaggerClient,
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+	}
+}
+
+func newRolloutControllerTestCanary() *flaggerv1.Canary {
+	return &flaggerv1.Canary{
+		TypeMeta: metav1.TypeMeta{APIVersion: flaggerv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "podinfo",
+		},
+		Spec: flaggerv1.CanarySpec{
+			TargetRef: flaggerv1.LocalObjectReference{
+				Name:       "podinfo",
+				APIVersion: "argoproj.io/v1alpha1",
+				Kind:       "Rollout",
+			},
+			Analysis: &flaggerv1.CanaryAnalysis{},
+		},
+	}
+}
+
+func newRolloutControllerTestTemplate() map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"app": "podinfo"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name":  "podinfo",
+					"image": "quay.io/stefanprodan/podinfo:1.2.0",
+					"ports": []interface{}{
+						map[string]interface{}{"name": "http", "containerPort": int64(9898)},
+					},
+					"envFrom": []interface{}{
+						map[string]interface{}{"configMapRef": map[string]interface{}{"name": "podinfo-config-env"}},
+						map[string]interface{}{"secretRef": map[string]interface{}{"name": "podinfo-secret-env"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newRolloutControllerTestPodInfo() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Rollout",
+		"metadata": map[string]interface{}{
+			"namespace": "default",
+			"name":      "podinfo",
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "podinfo"},
+			},
+			"template": newRolloutControllerTestTemplate(),
+			"strategy": map[string]interface{}{
+				"canary": map[string]interface{}{},
+			},
+		},
+	}}
+}
+
+// newRolloutControllerTestWorkloadRef returns a rollout that references the pod template of a deployment
+func newRolloutControllerTestWorkloadRef() (*unstructured.Unstructured, *appsv1.Deployment) {
+	rollout := newRolloutControllerTestPodInfo()
+	unstructured.RemoveNestedField(rollout.Object, "spec", "template")
+	_ = unstructured.SetNestedMap(rollout.Object, map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"name":       "podinfo-template",
+	}, "spec", "workloadRef")
+
+	dep := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "podinfo-template",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: int32p(0),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "podinfo"},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "podinfo"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "podinfo",
+							Image: "quay.io/stefanprodan/podinfo:1.3.0",
+							Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 9898}},
+						},
+					},
+				},
+			},
+		},
+	}
+	return rollout, dep
+}
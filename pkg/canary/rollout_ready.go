@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// IsCanaryReady checks the rollout status and returns an error if Argo Rollouts
+// is in the middle of an update or if the pods are unhealthy,
+// it will return a non retriable error if the rollout is degraded
+func (c *RolloutController) IsCanaryReady(cd *flaggerv1.Canary) (bool, error) {
+	rollout, err := getRollout(c.kubeClient, c.dynamicClient, cd.Spec.TargetRef.Name, cd.Namespace)
+	if err != nil {
+		return true, err
+	}
+
+	retryable, err := c.isRolloutReady(rollout, cd.GetAnalysisCanaryReadyThreshold())
+	if err != nil {
+		return retryable, fmt.Errorf("canary rollout %s.%s not ready: %w",
+			cd.Spec.TargetRef.Name, cd.Namespace, err)
+	}
+	return true, nil
+}
+
+// isRolloutReady determines if a rollout is ready by checking the replicas reported by Argo Rollouts,
+// a degraded rollout has exceeded its progress deadline and returns a non retriable error
+func (c *RolloutController) isRolloutReady(rollout *argoRollout, readyThreshold int) (bool, error) {
+	replicas := int32Default(rollout.Spec.Replicas)
+	readyThresholdRatio := float32(readyThreshold) / float32(100)
+	readyThresholdReplicas := int32(float32(rollout.Status.UpdatedReplicas) * readyThresholdRatio)
+
+	switch {
+	case rollout.Status.Phase == "Degraded":
+		return false, fmt.Errorf("rollout %q is degraded: %s", rollout.GetName(), rollout.Status.Message)
+	case rollout.Spec.Paused || rollout.Status.Phase == "Paused":
+		return true, fmt.Errorf("waiting for rollout to finish: rollout %q is paused", rollout.GetName())
+	case rollout.Status.UpdatedReplicas < replicas:
+		return true, fmt.Errorf("waiting for rollout to finish: %d out of %d new replicas have been updated",
+			rollout.Status.UpdatedReplicas, replicas)
+	case rollout.Status.Replicas > rollout.Status.UpdatedReplicas:
+		return true, fmt.Errorf("waiting for rollout to finish: %d old replicas are pending termination",
+			rollout.Status.Replicas-rollout.Status.UpdatedReplicas)
+	case rollout.Status.AvailableReplicas < readyThresholdReplicas:
+		return true, fmt.Errorf("waiting for rollout to finish: %d of %d (readyThreshold %d%%) updated replicas are available",
+			rollout.Status.AvailableReplicas, readyThresholdReplicas, readyThreshold)
+	}
+	return true, nil
+}
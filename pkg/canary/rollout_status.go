@@ -0,0 +1,43 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// SyncStatus encodes the rollout pod template and updates the canary status
+func (c *RolloutController) SyncStatus(cd *flaggerv1.Canary, status flaggerv1.CanaryStatus) error {
+	rollout, err := getRollout(c.kubeClient, c.dynamicClient, cd.Spec.TargetRef.Name, cd.Namespace)
+	if err != nil {
+		return err
+	}
+
+	configs, err := c.configTracker.GetConfigRefs(cd)
+	if err != nil {
+		return fmt.Errorf("GetConfigRefs failed: %w", err)
+	}
+
+	return syncCanaryStatus(c.flaggerClient, cd, status, hashedPodTemplate(cd, rollout.Spec.Template), func(cdCopy *flaggerv1.Canary) {
+		cdCopy.Status.TrackedConfigs = configs
+		if status.Phase == flaggerv1.CanaryPhaseInitialized {
+			cdCopy.Status.LastPromotedImages = podImages(rollout.Spec.Template.Spec)
+		}
+	})
+}
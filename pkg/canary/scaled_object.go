@@ -0,0 +1,380 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+var (
+	// ScaledObjectGVR is the resource of the KEDA ScaledObject
+	ScaledObjectGVR = schema.GroupVersionResource{
+		Group:    "keda.sh",
+		Version:  "v1alpha1",
+		Resource: "scaledobjects",
+	}
+
+	// TriggerAuthenticationGVR is the resource of the KEDA TriggerAuthentication
+	TriggerAuthenticationGVR = schema.GroupVersionResource{
+		Group:    "keda.sh",
+		Version:  "v1alpha1",
+		Resource: "triggerauthentications",
+	}
+)
+
+const (
+	kedaPausedAnnotation         = "autoscaling.keda.sh/paused"
+	kedaPausedReplicasAnnotation = "autoscaling.keda.sh/paused-replicas"
+	// scaledObjectPausedAnnotation marks the target ScaledObjects paused by Flagger
+	// while the canary deployment is scaled to zero
+	scaledObjectPausedAnnotation = "flagger.app/scaled-object-paused"
+)
+
+func hasScaledObject(cd *flaggerv1.Canary) bool {
+	return cd.Spec.AutoscalerRef != nil && cd.Spec.AutoscalerRef.Kind == "ScaledObject"
+}
+
+// reconcilePrimaryScaledObject creates or updates the KEDA ScaledObject of the primary deployment,
+// the triggers use primary copies of the referenced TriggerAuthentications and primary-suffixed metric names,
+// on init only the pause annotations of an existing primary ScaledObject are synced
+func (c *DeploymentController) reconcilePrimaryScaledObject(cd *flaggerv1.Canary, init bool) error {
+	if c.dynamicClient == nil {
+		return fmt.Errorf("ScaledObject %s.%s can't be queried without a dynamic client", cd.Spec.AutoscalerRef.Name, cd.Namespace)
+	}
+	client := c.dynamicClient.Resource(ScaledObjectGVR).Namespace(cd.Namespace)
+	so, err := client.Get(context.TODO(), cd.Spec.AutoscalerRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("ScaledObject %s.%s get query error: %w", cd.Spec.AutoscalerRef.Name, cd.Namespace, err)
+	}
+
+	primaryName, err := cd.GetPrimaryName(so.GetName())
+	if err != nil {
+		return err
+	}
+	annotations := primaryScaledObjectAnnotations(propagateMetadata(cd.GetAnnotationPropagation(), so.GetAnnotations(), so.GetAnnotations()))
+	primarySo, err := client.Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("ScaledObject %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+	exists := err == nil
+
+	// sync the pause annotations and the replicas override of the existing primary ScaledObject on each run
+	if exists && init {
+		current := primarySo.GetAnnotations()
+		replicaCounts := primaryScaledObjectReplicaCounts(cd)
+		synced := current[kedaPausedAnnotation] == annotations[kedaPausedAnnotation] &&
+			current[kedaPausedReplicasAnnotation] == annotations[kedaPausedReplicasAnnotation]
+		for key, value := range replicaCounts {
+			if count, _, _ := unstructured.NestedInt64(primarySo.Object, "spec", key); count != value {
+				synced = false
+			}
+		}
+		if synced {
+			return nil
+		}
+		return c.updatePrimaryScaledObject(cd, primaryName, func(primary *unstructured.Unstructured) error {
+			for key, value := range replicaCounts {
+				if err := unstructured.SetNestedField(primary.Object, value, "spec", key); err != nil {
+					return err
+				}
+			}
+			primaryAnnotations := primary.GetAnnotations()
+			if primaryAnnotations == nil {
+				primaryAnnotations = make(map[string]string)
+			}
+			for _, key := range []string{kedaPausedAnnotation, kedaPausedReplicasAnnotation} {
+				delete(primaryAnnotations, key)
+				if value, ok := annotations[key]; ok {
+					primaryAnnotations[key] = value
+				}
+			}
+			primary.SetAnnotations(primaryAnnotations)
+			return nil
+		})
+	}
+
+	spec, err := c.makePrimaryScaledObjectSpec(cd, so)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		primarySo = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": so.GetAPIVersion(),
+			"kind":       so.GetKind(),
+			"spec":       spec,
+		}}
+		primarySo.SetName(primaryName)
+		primarySo.SetNamespace(cd.Namespace)
+		primarySo.SetLabels(propagateMetadata(cd.GetLabelPropagation(), so.GetLabels(), filterMetadata(so.GetLabels())))
+		primarySo.SetAnnotations(annotations)
+		primarySo.SetOwnerReferences([]metav1.OwnerReference{
+			*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+				Group:   flaggerv1.SchemeGroupVersion.Group,
+				Version: flaggerv1.SchemeGroupVersion.Version,
+				Kind:    flaggerv1.CanaryKind,
+			}),
+		})
+
+		_, err = client.Create(context.TODO(), primarySo, metav1.CreateOptions{FieldManager: cd.FieldManager()})
+		if err != nil {
+			return fmt.Errorf("creating ScaledObject %s.%s failed: %w", primaryName, cd.Namespace, err)
+		}
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("ScaledObject %s.%s created", primaryName, cd.Namespace)
+		return nil
+	}
+
+	primarySpec, _, _ := unstructured.NestedMap(primarySo.Object, "spec")
+	if equality.Semantic.DeepEqual(spec, primarySpec) &&
+		equality.Semantic.DeepEqual(annotations, primarySo.GetAnnotations()) {
+		return nil
+	}
+	return c.updatePrimaryScaledObject(cd, primaryName, func(primary *unstructured.Unstructured) error {
+		primary.SetLabels(propagateMetadata(cd.GetLabelPropagation(), so.GetLabels(), filterMetadata(so.GetLabels())))
+		primary.SetAnnotations(annotations)
+		return unstructured.SetNestedMap(primary.Object, spec, "spec")
+	})
+}
+
+func (c *DeploymentController) updatePrimaryScaledObject(cd *flaggerv1.Canary, primaryName string,
+	mutate func(primary *unstructured.Unstructured) error) error {
+	client := c.dynamicClient.Resource(ScaledObjectGVR).Namespace(cd.Namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		primary, err := client.Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		primaryClone := primary.DeepCopy()
+		if err := mutate(primaryClone); err != nil {
+			return err
+		}
+		_, err = client.Update(context.TODO(), primaryClone, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("updating ScaledObject %s.%s failed: %w", primaryName, cd.Namespace, err)
+	}
+	c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Infof("ScaledObject %s.%s updated", primaryName, cd.Namespace)
+	return nil
+}
+
+// primaryScaledObjectReplicaCounts returns the replica counts of the primary ScaledObject
+// that are overridden in the autoscaler reference
+func primaryScaledObjectReplicaCounts(cd *flaggerv1.Canary) map[string]int64 {
+	counts := make(map[string]int64)
+	if replicas := cd.Spec.AutoscalerRef.PrimaryScalerReplicas; replicas != nil {
+		if replicas.MinReplicas != nil {
+			counts["minReplicaCount"] = int64(*replicas.MinReplicas)
+		}
+		if replicas.MaxReplicas != nil {
+			counts["maxReplicaCount"] = int64(*replicas.MaxReplicas)
+		}
+	}
+	return counts
+}
+
+// makePrimaryScaledObjectSpec returns a copy of the ScaledObject spec targeting the primary deployment,
+// the advanced settings such as the scaling modifiers formulas are copied as they are,
+// except for the name of the generated HPA that must be unique
+func (c *DeploymentController) makePrimaryScaledObjectSpec(cd *flaggerv1.Canary, so *unstructured.Unstructured) (map[string]interface{}, error) {
+	spec, ok, err := unstructured.NestedMap(so.Object, "spec")
+	if err != nil || !ok {
+		return nil, fmt.Errorf("ScaledObject %s.%s spec is invalid: %v", so.GetName(), cd.Namespace, err)
+	}
+
+	primaryTargetName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(spec, primaryTargetName, "scaleTargetRef", "name"); err != nil {
+		return nil, fmt.Errorf("ScaledObject %s.%s scaleTargetRef is invalid: %w", so.GetName(), cd.Namespace, err)
+	}
+	if hpaName, ok, _ := unstructured.NestedString(spec, "advanced", "horizontalPodAutoscalerConfig", "name"); ok && hpaName != "" {
+		primaryHpaName, err := cd.GetPrimaryName(hpaName)
+		if err != nil {
+			return nil, err
+		}
+		_ = unstructured.SetNestedField(spec, primaryHpaName, "advanced", "horizontalPodAutoscalerConfig", "name")
+	}
+	for key, value := range primaryScaledObjectReplicaCounts(cd) {
+		spec[key] = value
+	}
+
+	triggers, _, err := unstructured.NestedSlice(spec, "triggers")
+	if err != nil {
+		return nil, fmt.Errorf("ScaledObject %s.%s triggers are invalid: %w", so.GetName(), cd.Namespace, err)
+	}
+	for i := range triggers {
+		trigger, ok := triggers[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if metricName, ok, _ := unstructured.NestedString(trigger, "metadata", "metricName"); ok && metricName != "" {
+			primaryMetricName, err := cd.GetPrimaryName(metricName)
+			if err != nil {
+				return nil, err
+			}
+			_ = unstructured.SetNestedField(trigger, primaryMetricName, "metadata", "metricName")
+		}
+
+		authName, _, _ := unstructured.NestedString(trigger, "authenticationRef", "name")
+		authKind, _, _ := unstructured.NestedString(trigger, "authenticationRef", "kind")
+		// the cluster scoped authentications are shared with the primary
+		if authName == "" || (authKind != "" && authKind != "TriggerAuthentication") {
+			continue
+		}
+		primaryAuthName, err := cd.GetPrimaryName(authName)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.reconcilePrimaryTriggerAuthentication(cd, authName, primaryAuthName); err != nil {
+			return nil, err
+		}
+		_ = unstructured.SetNestedField(trigger, primaryAuthName, "authenticationRef", "name")
+	}
+	if len(triggers) > 0 {
+		if err := unstructured.SetNestedSlice(spec, triggers, "triggers"); err != nil {
+			return nil, fmt.Errorf("ScaledObject %s.%s triggers update failed: %w", so.GetName(), cd.Namespace, err)
+		}
+	}
+	return spec, nil
+}
+
+// reconcilePrimaryTriggerAuthentication creates or updates the primary copy of a TriggerAuthentication
+func (c *DeploymentController) reconcilePrimaryTriggerAuthentication(cd *flaggerv1.Canary, name string, primaryName string) error {
+	client := c.dynamicClient.Resource(TriggerAuthenticationGVR).Namespace(cd.Namespace)
+	auth, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("TriggerAuthentication %s.%s get query error: %w", name, cd.Namespace, err)
+	}
+	spec, _, _ := unstructured.NestedMap(auth.Object, "spec")
+
+	primaryAuth, err := client.Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		primaryAuth = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": auth.GetAPIVersion(),
+			"kind":       auth.GetKind(),
+			"spec":       spec,
+		}}
+		primaryAuth.SetName(primaryName)
+		primaryAuth.SetNamespace(cd.Namespace)
+		primaryAuth.SetLabels(filterMetadata(auth.GetLabels()))
+		primaryAuth.SetAnnotations(filterMetadata(auth.GetAnnotations()))
+		primaryAuth.SetOwnerReferences([]metav1.OwnerReference{
+			*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+				Group:   flaggerv1.SchemeGroupVersion.Group,
+				Version: flaggerv1.SchemeGroupVersion.Version,
+				Kind:    flaggerv1.CanaryKind,
+			}),
+		})
+
+		_, err = client.Create(context.TODO(), primaryAuth, metav1.CreateOptions{FieldManager: cd.FieldManager()})
+		if err != nil {
+			return fmt.Errorf("creating TriggerAuthentication %s.%s failed: %w", primaryName, cd.Namespace, err)
+		}
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("TriggerAuthentication %s.%s created", primaryName, cd.Namespace)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("TriggerAuthentication %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	if primarySpec, _, _ := unstructured.NestedMap(primaryAuth.Object, "spec"); equality.Semantic.DeepEqual(spec, primarySpec) {
+		return nil
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		primaryAuth, err := client.Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		authClone := primaryAuth.DeepCopy()
+		if err := unstructured.SetNestedMap(authClone.Object, spec, "spec"); err != nil {
+			return err
+		}
+		_, err = client.Update(context.TODO(), authClone, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("updating TriggerAuthentication %s.%s failed: %w", primaryName, cd.Namespace, err)
+	}
+	return nil
+}
+
+// pauseScaledObject pauses the target ScaledObject at zero replicas so that KEDA doesn't scale up
+// the canary deployment, the ScaledObjects paused by their owners are left untouched
+func (c *DeploymentController) pauseScaledObject(cd *flaggerv1.Canary, paused bool) error {
+	if !hasScaledObject(cd) || c.dynamicClient == nil {
+		return nil
+	}
+	client := c.dynamicClient.Resource(ScaledObjectGVR).Namespace(cd.Namespace)
+	name := cd.Spec.AutoscalerRef.Name
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		so, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		annotations := so.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		_, pausedByFlagger := annotations[scaledObjectPausedAnnotation]
+		switch {
+		case paused && !pausedByFlagger:
+			if _, ok := annotations[kedaPausedReplicasAnnotation]; ok {
+				return nil
+			}
+			annotations[kedaPausedReplicasAnnotation] = "0"
+			annotations[scaledObjectPausedAnnotation] = "true"
+		case !paused && pausedByFlagger:
+			delete(annotations, kedaPausedReplicasAnnotation)
+			delete(annotations, scaledObjectPausedAnnotation)
+		default:
+			return nil
+		}
+		soClone := so.DeepCopy()
+		soClone.SetAnnotations(annotations)
+		_, err = client.Update(context.TODO(), soClone, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("updating ScaledObject %s.%s pause annotations failed: %w", name, cd.Namespace, err)
+	}
+	return nil
+}
+
+// primaryScaledObjectAnnotations returns the annotations of the primary ScaledObject,
+// the pause set by Flagger on the target ScaledObject is not copied
+func primaryScaledObjectAnnotations(annotations map[string]string) map[string]string {
+	out := filterMetadata(annotations)
+	if _, ok := out[scaledObjectPausedAnnotation]; ok {
+		delete(out, scaledObjectPausedAnnotation)
+		delete(out, kedaPausedReplicasAnnotation)
+	}
+	return out
+}
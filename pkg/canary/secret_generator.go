@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+const (
+	externalSecretGroup = "external-secrets.io"
+	sealedSecretGroup   = "bitnami.com"
+
+	sealedSecretNamespaceWideAnnotation = "sealedsecrets.bitnami.com/namespace-wide"
+	sealedSecretClusterWideAnnotation   = "sealedsecrets.bitnami.com/cluster-wide"
+)
+
+// secretGeneratorResources maps the kinds of the supported secret generators to their resources
+var secretGeneratorResources = map[schema.GroupKind]string{
+	{Group: externalSecretGroup, Kind: "ExternalSecret"}: "externalsecrets",
+	{Group: sealedSecretGroup, Kind: "SealedSecret"}:     "sealedsecrets",
+}
+
+// getSecretGenerator returns the ExternalSecret or SealedSecret that controls the secret,
+// it returns nil if the secret isn't generated or if the generator can't be copied
+// e.g. a SealedSecret with the default strict scope can only be decrypted under its own name
+func (ct *ConfigTracker) getSecretGenerator(secret *corev1.Secret) (*unstructured.Unstructured, schema.GroupVersionResource, error) {
+	owner := metav1.GetControllerOf(secret)
+	if owner == nil || ct.DynamicClient == nil {
+		return nil, schema.GroupVersionResource{}, nil
+	}
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return nil, schema.GroupVersionResource{}, nil
+	}
+	resource, ok := secretGeneratorResources[gv.WithKind(owner.Kind).GroupKind()]
+	if !ok {
+		return nil, schema.GroupVersionResource{}, nil
+	}
+
+	gvr := gv.WithResource(resource)
+	generator, err := ct.DynamicClient.Resource(gvr).Namespace(secret.Namespace).Get(context.TODO(), owner.Name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, gvr, nil
+		}
+		return nil, gvr, fmt.Errorf("%s %s.%s get query error: %w", resource, owner.Name, secret.Namespace, err)
+	}
+
+	if gv.Group == sealedSecretGroup && !sealedSecretIsRenamable(generator) {
+		ct.Logger.Debugf("secret %s.%s is generated by a strict scoped SealedSecret, copying the secret data",
+			secret.Name, secret.Namespace)
+		return nil, gvr, nil
+	}
+	return generator, gvr, nil
+}
+
+// sealedSecretIsRenamable returns true if the SealedSecret is namespace or cluster wide
+func sealedSecretIsRenamable(ss *unstructured.Unstructured) bool {
+	templateAnnotations, _, _ := unstructured.NestedStringMap(ss.Object, "spec", "template", "metadata", "annotations")
+	for _, annotations := range []map[string]string{ss.GetAnnotations(), templateAnnotations} {
+		if annotations[sealedSecretNamespaceWideAnnotation] == "true" ||
+			annotations[sealedSecretClusterWideAnnotation] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// createPrimarySecretGenerator syncs the primary copy of the ExternalSecret or SealedSecret
+// that generates the secret, the generator controller creates the primary secret and keeps it rotated
+func (ct *ConfigTracker) createPrimarySecretGenerator(cd *flaggerv1.Canary, secret *corev1.Secret,
+	generator *unstructured.Unstructured, gvr schema.GroupVersionResource, includeLabelPrefix []string) error {
+	primarySecretName, err := cd.GetPrimaryName(secret.GetName())
+	if err != nil {
+		return err
+	}
+	spec, _, _ := unstructured.NestedMap(generator.Object, "spec")
+	if spec == nil {
+		spec = make(map[string]interface{})
+	}
+
+	primaryName, err := cd.GetPrimaryName(generator.GetName())
+	if err != nil {
+		return err
+	}
+	switch gvr.Group {
+	case externalSecretGroup:
+		if err := unstructured.SetNestedField(spec, primarySecretName, "target", "name"); err != nil {
+			return fmt.Errorf("%s %s.%s target update failed: %w", gvr.Resource, generator.GetName(), cd.Namespace, err)
+		}
+	case sealedSecretGroup:
+		// the unsealed secret is named after the SealedSecret
+		primaryName = primarySecretName
+		unstructured.RemoveNestedField(spec, "template", "metadata", "name")
+		unstructured.RemoveNestedField(spec, "template", "metadata", "namespace")
+	}
+
+	primary := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": generator.GetAPIVersion(),
+		"kind":       generator.GetKind(),
+		"spec":       spec,
+	}}
+	primary.SetName(primaryName)
+	primary.SetNamespace(cd.Namespace)
+	primary.SetLabels(makeAuditLabels(cd, includeLabelsByPrefix(generator.GetLabels(), includeLabelPrefix)))
+	annotations := filterMetadata(generator.GetAnnotations())
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	primary.SetAnnotations(annotations)
+	primary.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+			Group:   flaggerv1.SchemeGroupVersion.Group,
+			Version: flaggerv1.SchemeGroupVersion.Version,
+			Kind:    flaggerv1.CanaryKind,
+		}),
+	})
+
+	// remove the primary secret copied by Flagger so that the generator controller can own it
+	oldSecret, err := ct.KubeClient.CoreV1().Secrets(cd.Namespace).Get(context.TODO(), primarySecretName, metav1.GetOptions{})
+	if err == nil {
+		if owner := metav1.GetControllerOf(oldSecret); owner != nil && owner.Kind == flaggerv1.CanaryKind && owner.Name == cd.Name {
+			err = ct.KubeClient.CoreV1().Secrets(cd.Namespace).Delete(context.TODO(), primarySecretName, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("deleting secret %s.%s failed: %w", primarySecretName, cd.Namespace, err)
+			}
+		}
+	} else if !errors.IsNotFound(err) {
+		return fmt.Errorf("secret %s.%s get query failed : %w", primarySecretName, cd.Namespace, err)
+	}
+
+	// update or insert the primary generator
+	client := ct.DynamicClient.Resource(gvr).Namespace(cd.Namespace)
+	oldPrimary, err := client.Get(context.TODO(), primaryName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		if _, err := client.Create(context.TODO(), primary, metav1.CreateOptions{FieldManager: cd.FieldManager()}); err != nil {
+			return fmt.Errorf("creating %s %s.%s failed: %w", gvr.Resource, primaryName, cd.Namespace, err)
+		}
+	case err != nil:
+		return fmt.Errorf("%s %s.%s get query failed : %w", gvr.Resource, primaryName, cd.Namespace, err)
+	default:
+		primary.SetResourceVersion(oldPrimary.GetResourceVersion())
+		if _, err := client.Update(context.TODO(), primary, metav1.UpdateOptions{FieldManager: cd.FieldManager()}); err != nil {
+			return fmt.Errorf("updating %s %s.%s failed: %w", gvr.Resource, primaryName, cd.Namespace, err)
+		}
+	}
+
+	ct.Logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Infof("%s %s synced", generator.GetKind(), primaryName)
+	return nil
+}
@@ -57,7 +57,30 @@ func (c *ServiceController) SetStatusIterations(cd *flaggerv1.Canary, val int) e
 
 // SetStatusPhase updates the canary status phase
 func (c *ServiceController) SetStatusPhase(cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase) error {
-	return setStatusPhase(c.flaggerClient, cd, phase)
+	return setStatusPhase(c.flaggerClient, cd, phase, nil)
+}
+
+// SetStatusFinalization updates the canary status finalization
+func (c *ServiceController) SetStatusFinalization(cd *flaggerv1.Canary, finalization []flaggerv1.CanaryFinalizationStatus) error {
+	return setStatusFinalization(c.flaggerClient, cd, finalization)
+}
+
+// SetStatusMetrics updates the canary status metrics
+func (c *ServiceController) SetStatusMetrics(cd *flaggerv1.Canary, metrics []flaggerv1.CanaryMetricStatus) error {
+	return setStatusMetrics(c.flaggerClient, cd, metrics)
+}
+
+// SetStatusFailures updates the canary status failures
+func (c *ServiceController) SetStatusFailures(cd *flaggerv1.Canary, failures []flaggerv1.CanaryCheckFailure) error {
+	return setStatusFailures(c.flaggerClient, cd, failures)
+}
+
+func (c *ServiceController) SetStatusWebhooks(cd *flaggerv1.Canary, webhooks []flaggerv1.CanaryWebhookStatus) error {
+	return setStatusWebhooks(c.flaggerClient, cd, webhooks)
+}
+
+func (c *ServiceController) SetStatusNextAnalysisTime(cd *flaggerv1.Canary, next metav1.Time) error {
+	return setStatusNextAnalysisTime(c.flaggerClient, cd, next)
 }
 
 // GetMetadata returns the pod label selector, label value and svc ports
@@ -68,9 +91,16 @@ func (c *ServiceController) GetMetadata(_ *flaggerv1.Canary) (string, string, ma
 // Initialize creates or updates the primary and canary services to prepare for the canary release process targeted on the K8s service
 func (c *ServiceController) Initialize(cd *flaggerv1.Canary) (err error) {
 	targetName := cd.Spec.TargetRef.Name
-	primaryName := fmt.Sprintf("%s-primary", targetName)
+	primaryName, err := cd.GetPrimaryName(targetName)
+	if err != nil {
+		return err
+	}
 	canaryName := fmt.Sprintf("%s-canary", targetName)
 
+	if err := cd.Spec.Service.ValidateIPFamilies(); err != nil {
+		return fmt.Errorf("service %s.%s invalid: %w", targetName, cd.Namespace, err)
+	}
+
 	svc, err := c.kubeClient.CoreV1().Services(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("service %s.%s get query error: %w", primaryName, cd.Namespace, err)
@@ -100,6 +130,7 @@ func (c *ServiceController) reconcileCanaryService(canary *flaggerv1.Canary, nam
 	if ns.Spec.Type == "ClusterIP" {
 		// We can't change this immutable field
 		ns.Spec.ClusterIP = current.Spec.ClusterIP
+		ns.Spec.ClusterIPs = current.Spec.ClusterIPs
 	}
 
 	// We can't change this immutable field
@@ -107,7 +138,7 @@ func (c *ServiceController) reconcileCanaryService(canary *flaggerv1.Canary, nam
 
 	ns.ObjectMeta.ResourceVersion = current.ObjectMeta.ResourceVersion
 
-	_, err = c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), ns, metav1.UpdateOptions{})
+	_, err = c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), ns, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("updating service %s.%s failed: %w", name, canary.Namespace, err)
 	}
@@ -133,12 +164,13 @@ func (c *ServiceController) createService(canary *flaggerv1.Canary, name string,
 	if svc.Spec.Type == "ClusterIP" {
 		// Reset and let K8s assign the IP. Otherwise we get an error due to the IP is already assigned
 		svc.Spec.ClusterIP = ""
+		svc.Spec.ClusterIPs = nil
 	}
 
 	// Let K8s set this. Otherwise K8s API complains with "resourceVersion should not be set on objects to be created"
 	svc.ObjectMeta.ResourceVersion = ""
 
-	_, err := c.kubeClient.CoreV1().Services(canary.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+	_, err := c.kubeClient.CoreV1().Services(canary.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("creating service %s.%s query error: %w", canary.Name, canary.Namespace, err)
 	}
@@ -165,15 +197,29 @@ func buildService(canary *flaggerv1.Canary, name string, src *corev1.Service) *c
 		//   Operation cannot be fulfilled on services "mysvc-canary": the object has been modified; please apply your changes to the latest version and try again
 		delete(svc.ObjectMeta.Annotations, "kubectl.kubernetes.io/last-applied-configuration")
 	}
+	setIPFamilies(canary, svc)
 	return svc
 }
 
+// setIPFamilies overrides the target IP families with the ones set in the canary spec
+func setIPFamilies(canary *flaggerv1.Canary, svc *corev1.Service) {
+	if len(canary.Spec.Service.IPFamilies) > 0 {
+		svc.Spec.IPFamilies = canary.Spec.Service.IPFamilies
+	}
+	if canary.Spec.Service.IPFamilyPolicy != nil {
+		svc.Spec.IPFamilyPolicy = canary.Spec.Service.IPFamilyPolicy
+	}
+}
+
 // Promote copies target's spec from canary to primary
 func (c *ServiceController) Promote(cd *flaggerv1.Canary) error {
 	targetName := cd.Spec.TargetRef.Name
-	primaryName := fmt.Sprintf("%s-primary", targetName)
+	primaryName, err := cd.GetPrimaryName(targetName)
+	if err != nil {
+		return err
+	}
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		canary, err := c.kubeClient.CoreV1().Services(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
 		if err != nil {
 			return fmt.Errorf("service %s.%s get query error: %w", targetName, cd.Namespace, err)
@@ -188,25 +234,27 @@ func (c *ServiceController) Promote(cd *flaggerv1.Canary) error {
 		primaryCopy.ObjectMeta.Name = primary.ObjectMeta.Name
 		if primaryCopy.Spec.Type == "ClusterIP" {
 			primaryCopy.Spec.ClusterIP = primary.Spec.ClusterIP
+			primaryCopy.Spec.ClusterIPs = primary.Spec.ClusterIPs
 		}
+		setIPFamilies(cd, primaryCopy)
 		primaryCopy.ObjectMeta.ResourceVersion = primary.ObjectMeta.ResourceVersion
 		primaryCopy.ObjectMeta.UID = primary.ObjectMeta.UID
 
 		// update service annotations
 		primaryCopy.ObjectMeta.Annotations = make(map[string]string)
-		filteredAnnotations := includeLabelsByPrefix(canary.ObjectMeta.Annotations, c.includeLabelPrefix)
+		filteredAnnotations := propagateMetadata(cd.GetAnnotationPropagation(), canary.ObjectMeta.Annotations, includeLabelsByPrefix(canary.ObjectMeta.Annotations, c.includeLabelPrefix))
 		for k, v := range filteredAnnotations {
 			primaryCopy.ObjectMeta.Annotations[k] = v
 		}
 		// update service labels
 		primaryCopy.ObjectMeta.Labels = make(map[string]string)
-		filteredLabels := includeLabelsByPrefix(canary.ObjectMeta.Labels, c.includeLabelPrefix)
+		filteredLabels := propagateMetadata(cd.GetLabelPropagation(), canary.ObjectMeta.Labels, includeLabelsByPrefix(canary.ObjectMeta.Labels, c.includeLabelPrefix))
 		for k, v := range filteredLabels {
 			primaryCopy.ObjectMeta.Labels[k] = v
 		}
 
 		// apply update
-		_, err = c.kubeClient.CoreV1().Services(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{})
+		_, err = c.kubeClient.CoreV1().Services(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 		return err
 	})
 	if err != nil {
@@ -236,6 +284,21 @@ func (c *ServiceController) ScaleFromZero(_ *flaggerv1.Canary) error {
 	return nil
 }
 
+// GetPodTemplate returns nil as the services have no pod template
+func (c *ServiceController) GetPodTemplate(_ *flaggerv1.Canary) (*corev1.PodTemplateSpec, error) {
+	return nil, nil
+}
+
+// RestorePromoted is a no-op as the services have no container images
+func (c *ServiceController) RestorePromoted(_ *flaggerv1.Canary) (bool, error) {
+	return false, nil
+}
+
+// SyncPrimaryDrift is a no-op as the primary service is reconciled on every run
+func (c *ServiceController) SyncPrimaryDrift(_ *flaggerv1.Canary) (bool, error) {
+	return false, nil
+}
+
 func (c *ServiceController) SyncStatus(cd *flaggerv1.Canary, status flaggerv1.CanaryStatus) error {
 	dep, err := c.kubeClient.CoreV1().Services(cd.Namespace).Get(context.TODO(), cd.Spec.TargetRef.Name, metav1.GetOptions{})
 	if err != nil {
@@ -21,6 +21,8 @@ import (
 	"hash/fnv"
 
 	"github.com/davecgh/go-spew/spew"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -63,3 +65,110 @@ func computeHash(spec interface{}) string {
 
 	return rand.SafeEncodeString(fmt.Sprint(hasher.Sum32()))
 }
+
+// primaryPodSpec returns a copy of the target pod spec without the ephemeral containers
+// added by debug sessions and, if the canary ignores the lifecycle hooks, without the
+// containers lifecycle hooks
+func primaryPodSpec(cd *flaggerv1.Canary, spec corev1.PodSpec) corev1.PodSpec {
+	out := spec.DeepCopy()
+	out.EphemeralContainers = nil
+
+	if cd.Spec.LifecycleHooksPolicy == flaggerv1.IgnoreLifecycleHooksPolicy {
+		for i := range out.InitContainers {
+			out.InitContainers[i].Lifecycle = nil
+		}
+		for i := range out.Containers {
+			out.Containers[i].Lifecycle = nil
+		}
+	}
+	return *out
+}
+
+// fastPathTemplate returns a copy of the pod template without the fields
+// whose changes are promoted through the fast path
+func fastPathTemplate(cd *flaggerv1.Canary, template corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	out := template.DeepCopy()
+	for _, field := range cd.GetFastPathFields() {
+		if field == flaggerv1.AnnotationsFastPathField {
+			out.Annotations = nil
+			continue
+		}
+		for _, containers := range [][]corev1.Container{out.Spec.InitContainers, out.Spec.Containers} {
+			for i := range containers {
+				switch field {
+				case flaggerv1.ResourcesFastPathField:
+					containers[i].Resources = corev1.ResourceRequirements{}
+				case flaggerv1.EnvFastPathField:
+					containers[i].Env = nil
+					containers[i].EnvFrom = nil
+				}
+			}
+		}
+	}
+	return *out
+}
+
+// withPrimaryAntiAffinity adds to the primary pod spec an anti-affinity term
+// that matches the canary pods selected by the given selector
+func withPrimaryAntiAffinity(cd *flaggerv1.Canary, canarySelector *metav1.LabelSelector, spec corev1.PodSpec) corev1.PodSpec {
+	if cd.Spec.PrimaryAntiAffinity == nil || canarySelector == nil {
+		return spec
+	}
+
+	topologyKey := cd.Spec.PrimaryAntiAffinity.TopologyKey
+	if topologyKey == "" {
+		topologyKey = corev1.LabelHostname
+	}
+	term := corev1.PodAffinityTerm{
+		LabelSelector: canarySelector.DeepCopy(),
+		TopologyKey:   topologyKey,
+	}
+
+	out := spec.DeepCopy()
+	if out.Affinity == nil {
+		out.Affinity = &corev1.Affinity{}
+	}
+	if out.Affinity.PodAntiAffinity == nil {
+		out.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+	}
+	antiAffinity := out.Affinity.PodAntiAffinity
+	if cd.Spec.PrimaryAntiAffinity.Required {
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+			antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, term)
+	} else {
+		antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			corev1.WeightedPodAffinityTerm{Weight: 100, PodAffinityTerm: term})
+	}
+	return *out
+}
+
+// hashedPodTemplate returns the pod template used to detect the target changes,
+// the fields removed from the primary pod spec and the images of the containers
+// excluded from the image tracking don't trigger a canary analysis
+func hashedPodTemplate(cd *flaggerv1.Canary, template corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	out := template.DeepCopy()
+	out.Spec = primaryPodSpec(cd, out.Spec)
+	for _, containers := range [][]corev1.Container{out.Spec.InitContainers, out.Spec.Containers} {
+		for i := range containers {
+			if !cd.Spec.ImageTracking.IsTracked(containers[i].Name) {
+				containers[i].Image = ""
+			}
+		}
+	}
+	return *out
+}
+
+// changedImages returns the tracked containers whose image differs from the last promoted revision
+func changedImages(cd *flaggerv1.Canary, spec corev1.PodSpec) map[string]string {
+	changed := make(map[string]string)
+	for name, image := range podImages(spec) {
+		if cd.Spec.ImageTracking.IsTracked(name) && cd.Status.LastPromotedImages[name] != image {
+			changed[name] = image
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	return changed
+}
@@ -0,0 +1,512 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
+)
+
+// StatefulSetController is managing the operations for Kubernetes StatefulSet kind
+type StatefulSetController struct {
+	kubeClient         kubernetes.Interface
+	flaggerClient      clientset.Interface
+	logger             *zap.SugaredLogger
+	configTracker      Tracker
+	labels             []string
+	includeLabelPrefix []string
+}
+
+// Initialize creates the primary StatefulSet and its headless service,
+// and scales down the canary StatefulSet
+func (c *StatefulSetController) Initialize(cd *flaggerv1.Canary) (err error) {
+	if cd.Spec.AutoscalerRef != nil {
+		return fmt.Errorf("autoscalerRef is not supported for StatefulSet %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
+	}
+
+	if err := c.createPrimaryStatefulSet(cd, c.includeLabelPrefix); err != nil {
+		return fmt.Errorf("createPrimaryStatefulSet failed: %w", err)
+	}
+
+	if cd.Status.Phase == "" || cd.Status.Phase == flaggerv1.CanaryPhaseInitializing {
+		if !cd.SkipAnalysis() {
+			if err := c.IsPrimaryReady(cd); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("Scaling down StatefulSet %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
+		if err := c.ScaleToZero(cd); err != nil {
+			return fmt.Errorf("scaling down canary statefulset %s.%s failed: %w", cd.Spec.TargetRef.Name, cd.Namespace, err)
+		}
+	}
+	return nil
+}
+
+// Promote copies the pod spec, secrets and config maps from canary to primary,
+// the volume claim templates are immutable and are not promoted
+func (c *StatefulSetController) Promote(cd *flaggerv1.Canary) error {
+	targetName := cd.Spec.TargetRef.Name
+	primaryName, err := cd.GetPrimaryName(targetName)
+	if err != nil {
+		return err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		canary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("statefulset %s.%s get query error: %w", targetName, cd.Namespace, err)
+		}
+
+		label, labelValue, err := c.getSelectorLabel(canary)
+		if err != nil {
+			return fmt.Errorf("getSelectorLabel failed: %w", err)
+		}
+		primaryLabelValue, err := cd.GetPrimaryLabelValue(labelValue)
+		if err != nil {
+			return err
+		}
+
+		primary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("statefulset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+		}
+
+		// promote secrets and config maps
+		configRefs, err := c.configTracker.GetTargetConfigs(cd)
+		if err != nil {
+			return fmt.Errorf("GetTargetConfigs failed: %w", err)
+		}
+		if err := c.configTracker.CreatePrimaryConfigs(cd, configRefs, c.includeLabelPrefix); err != nil {
+			return fmt.Errorf("CreatePrimaryConfigs failed: %w", err)
+		}
+
+		primaryCopy := primary.DeepCopy()
+		primaryCopy.Spec.MinReadySeconds = canary.Spec.MinReadySeconds
+		primaryCopy.Spec.RevisionHistoryLimit = canary.Spec.RevisionHistoryLimit
+		primaryCopy.Spec.UpdateStrategy = primaryUpdateStrategy(canary.Spec.UpdateStrategy)
+		primaryCopy.Spec.Replicas = canary.Spec.Replicas
+		if int32Default(primaryCopy.Spec.Replicas) == 0 {
+			// the canary is scaled down after the analysis, the primary keeps its replicas
+			primaryCopy.Spec.Replicas = primary.Spec.Replicas
+		}
+
+		// update spec with primary secrets and config maps
+		primarySpec, err := c.configTracker.ApplyPrimaryConfigs(cd, primaryPodSpec(cd, canary.Spec.Template.Spec), configRefs)
+		if err != nil {
+			return fmt.Errorf("ApplyPrimaryConfigs failed: %w", err)
+		}
+		primaryCopy.Spec.Template.Spec = withPrimaryAntiAffinity(cd, canary.Spec.Selector, primarySpec)
+
+		// update pod annotations to ensure a rolling update
+		configAnnotations, err := primaryConfigAnnotations(cd, canary.Spec.Template.Annotations, configRefs)
+		if err != nil {
+			return fmt.Errorf("primaryConfigAnnotations failed: %w", err)
+		}
+		annotations, err := makeAnnotations(configAnnotations)
+		if err != nil {
+			return fmt.Errorf("makeAnnotations failed: %w", err)
+		}
+
+		primaryCopy.Spec.Template.Annotations = annotations
+		primaryCopy.Spec.Template.Labels = makePrimaryLabels(canary.Spec.Template.Labels, primaryLabelValue, label)
+
+		// update sts annotations
+		primaryCopy.ObjectMeta.Annotations = make(map[string]string)
+		filteredAnnotations := propagateMetadata(cd.GetAnnotationPropagation(), canary.ObjectMeta.Annotations, includeLabelsByPrefix(canary.ObjectMeta.Annotations, c.includeLabelPrefix))
+		for k, v := range filteredAnnotations {
+			primaryCopy.ObjectMeta.Annotations[k] = v
+		}
+		// update sts labels
+		primaryCopy.ObjectMeta.Labels = make(map[string]string)
+		filteredLabels := propagateMetadata(cd.GetLabelPropagation(), canary.ObjectMeta.Labels, includeLabelsByPrefix(canary.ObjectMeta.Labels, c.includeLabelPrefix))
+		for k, v := range filteredLabels {
+			primaryCopy.ObjectMeta.Labels[k] = v
+		}
+		primaryCopy.ObjectMeta.Labels = makeAuditLabels(cd, primaryCopy.ObjectMeta.Labels)
+
+		// apply update
+		_, err = c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("updating statefulset %s.%s template spec failed: %w",
+			primaryName, cd.Namespace, err)
+	}
+
+	return nil
+}
+
+// RestorePromoted sets the pod template of the last promoted revision on the primary statefulset,
+// it returns true if the primary had drifted from the promoted revision
+func (c *StatefulSetController) RestorePromoted(cd *flaggerv1.Canary) (bool, error) {
+	if cd.Status.LastPromotedSpec == "" {
+		return false, nil
+	}
+
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return false, err
+	}
+	restored := false
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		primary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("statefulset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+		}
+
+		primaryCopy := primary.DeepCopy()
+		restored, err = restorePromotedTemplate(c.kubeClient, cd, &primaryCopy.Spec.Template)
+		if err != nil || !restored {
+			return err
+		}
+
+		_, err = c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Update(context.TODO(), primaryCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("restoring statefulset %s.%s template failed: %w", primaryName, cd.Namespace, err)
+	}
+	return restored, nil
+}
+
+// SyncPrimaryDrift is a no-op as the drift detection is supported only for the Deployment targets
+func (c *StatefulSetController) SyncPrimaryDrift(_ *flaggerv1.Canary) (bool, error) {
+	return false, nil
+}
+
+// GetPodTemplate returns the pod template of the canary statefulset
+func (c *StatefulSetController) GetPodTemplate(cd *flaggerv1.Canary) (*corev1.PodTemplateSpec, error) {
+	targetName := cd.Spec.TargetRef.Name
+	canary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("statefulset %s.%s get query error: %w", targetName, cd.Namespace, err)
+	}
+	return &canary.Spec.Template, nil
+}
+
+// HasTargetChanged returns true if the canary statefulset pod spec has changed
+func (c *StatefulSetController) HasTargetChanged(cd *flaggerv1.Canary) (bool, error) {
+	targetName := cd.Spec.TargetRef.Name
+	canary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("statefulset %s.%s get query error: %w", targetName, cd.Namespace, err)
+	}
+
+	return hasSpecChanged(cd, hashedPodTemplate(cd, canary.Spec.Template))
+}
+
+// ScaleToZero sets the canary statefulset replicas to zero
+func (c *StatefulSetController) ScaleToZero(cd *flaggerv1.Canary) error {
+	return c.scale(cd, 0)
+}
+
+// ScaleFromZero scales up the canary statefulset to the primary replicas,
+// the pods are started in order unless the pod management policy is Parallel
+func (c *StatefulSetController) ScaleFromZero(cd *flaggerv1.Canary) error {
+	targetName := cd.Spec.TargetRef.Name
+	sts, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("statefulset %s.%s get query error: %w", targetName, cd.Namespace, err)
+	}
+	if int32Default(sts.Spec.Replicas) > 0 {
+		return nil
+	}
+
+	replicas := int32(1)
+	primaryName, err := cd.GetPrimaryName(targetName)
+	if err != nil {
+		return err
+	}
+	primary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("statefulset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+	if primary.Spec.Replicas != nil && *primary.Spec.Replicas > 0 {
+		replicas = *primary.Spec.Replicas
+	}
+	return c.scale(cd, replicas)
+}
+
+// scale sets the canary statefulset replicas
+func (c *StatefulSetController) scale(cd *flaggerv1.Canary, replicas int32) error {
+	targetName := cd.Spec.TargetRef.Name
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		sts, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		stsCopy := sts.DeepCopy()
+		stsCopy.Spec.Replicas = int32p(replicas)
+		_, err = c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Update(context.TODO(), stsCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("scaling statefulset %s.%s to %v failed: %w", targetName, cd.Namespace, replicas, err)
+	}
+	return nil
+}
+
+// GetMetadata returns the pod label selector and svc ports
+func (c *StatefulSetController) GetMetadata(cd *flaggerv1.Canary) (string, string, map[string]int32, error) {
+	targetName := cd.Spec.TargetRef.Name
+
+	canarySts, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", nil, fmt.Errorf("statefulset %s.%s get query error: %w", targetName, cd.Namespace, err)
+	}
+
+	label, labelValue, err := c.getSelectorLabel(canarySts)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("getSelectorLabel failed: %w", err)
+	}
+
+	var ports map[string]int32
+	if cd.Spec.Service.PortDiscovery {
+		ports = getPorts(cd, canarySts.Spec.Template.Spec.Containers)
+	}
+	return label, labelValue, ports, nil
+}
+
+func (c *StatefulSetController) createPrimaryStatefulSet(cd *flaggerv1.Canary, includeLabelPrefix []string) error {
+	targetName := cd.Spec.TargetRef.Name
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+
+	canarySts, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("statefulset %s.%s get query error: %w", targetName, cd.Namespace, err)
+	}
+
+	if canarySts.Spec.UpdateStrategy.Type != "" &&
+		canarySts.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return fmt.Errorf("statefulset %s.%s must have RollingUpdate strategy but have %s",
+			targetName, cd.Namespace, canarySts.Spec.UpdateStrategy.Type)
+	}
+
+	// Create the labels map but filter unwanted labels
+	labels := propagateMetadata(cd.GetLabelPropagation(), canarySts.Labels, includeLabelsByPrefix(canarySts.Labels, includeLabelPrefix))
+
+	label, labelValue, err := c.getSelectorLabel(canarySts)
+	if err != nil {
+		return fmt.Errorf("getSelectorLabel failed: %w", err)
+	}
+	primaryLabelValue, err := cd.GetPrimaryLabelValue(labelValue)
+	if err != nil {
+		return err
+	}
+
+	// the primary pods get their DNS records from a dedicated headless service
+	headlessName := fmt.Sprintf("%s-headless", primaryName)
+	if err := c.createPrimaryHeadlessService(cd, canarySts, headlessName, label, primaryLabelValue); err != nil {
+		return err
+	}
+
+	primarySts, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// create primary secrets and config maps
+		configRefs, err := c.configTracker.GetTargetConfigs(cd)
+		if err != nil {
+			return fmt.Errorf("GetTargetConfigs failed: %w", err)
+		}
+		if err := c.configTracker.CreatePrimaryConfigs(cd, configRefs, c.includeLabelPrefix); err != nil {
+			return fmt.Errorf("CreatePrimaryConfigs failed: %w", err)
+		}
+		configAnnotations, err := primaryConfigAnnotations(cd, canarySts.Spec.Template.Annotations, configRefs)
+		if err != nil {
+			return fmt.Errorf("primaryConfigAnnotations failed: %w", err)
+		}
+		annotations, err := makeAnnotations(configAnnotations)
+		if err != nil {
+			return fmt.Errorf("makeAnnotations failed: %w", err)
+		}
+		primarySpec, err := c.configTracker.ApplyPrimaryConfigs(cd, primaryPodSpec(cd, canarySts.Spec.Template.Spec), configRefs)
+		if err != nil {
+			return fmt.Errorf("ApplyPrimaryConfigs failed: %w", err)
+		}
+
+		replicas := int32(1)
+		if canarySts.Spec.Replicas != nil && *canarySts.Spec.Replicas > 0 {
+			replicas = *canarySts.Spec.Replicas
+		}
+
+		// create primary statefulset, the volume claims are created for the primary pods
+		primarySts = &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        primaryName,
+				Namespace:   cd.Namespace,
+				Labels:      makeAuditLabels(cd, makePrimaryLabels(labels, primaryLabelValue, label)),
+				Annotations: propagateMetadata(cd.GetAnnotationPropagation(), canarySts.Annotations, filterMetadata(canarySts.Annotations)),
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:             int32p(replicas),
+				ServiceName:          headlessName,
+				PodManagementPolicy:  canarySts.Spec.PodManagementPolicy,
+				UpdateStrategy:       primaryUpdateStrategy(canarySts.Spec.UpdateStrategy),
+				MinReadySeconds:      canarySts.Spec.MinReadySeconds,
+				RevisionHistoryLimit: canarySts.Spec.RevisionHistoryLimit,
+				VolumeClaimTemplates: canarySts.Spec.VolumeClaimTemplates,
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						label: primaryLabelValue,
+					},
+				},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels:      makePrimaryLabels(canarySts.Spec.Template.Labels, primaryLabelValue, label),
+						Annotations: annotations,
+					},
+					// update spec with the primary secrets and config maps
+					Spec: withPrimaryAntiAffinity(cd, canarySts.Spec.Selector, primarySpec),
+				},
+			},
+		}
+
+		_, err = c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Create(context.TODO(), primarySts, metav1.CreateOptions{FieldManager: cd.FieldManager()})
+		if err != nil {
+			return fmt.Errorf("creating statefulset %s.%s failed: %w", primarySts.Name, cd.Namespace, err)
+		}
+
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("StatefulSet %s.%s created", primarySts.GetName(), cd.Namespace)
+	}
+	return nil
+}
+
+// createPrimaryHeadlessService creates the governing service of the primary statefulset
+// with the ports of the canary governing service
+func (c *StatefulSetController) createPrimaryHeadlessService(cd *flaggerv1.Canary, canarySts *appsv1.StatefulSet,
+	name string, label string, primaryLabelValue string) error {
+	_, err := c.kubeClient.CoreV1().Services(cd.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return fmt.Errorf("service %s.%s get query error: %w", name, cd.Namespace, err)
+	}
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cd.Namespace,
+			Labels:    makeAuditLabels(cd, map[string]string{label: primaryLabelValue}),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+					Group:   flaggerv1.SchemeGroupVersion.Group,
+					Version: flaggerv1.SchemeGroupVersion.Version,
+					Kind:    flaggerv1.CanaryKind,
+				}),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  map[string]string{label: primaryLabelValue},
+		},
+	}
+
+	if canarySts.Spec.ServiceName != "" {
+		governing, err := c.kubeClient.CoreV1().Services(cd.Namespace).Get(context.TODO(), canarySts.Spec.ServiceName, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("service %s.%s get query error: %w", canarySts.Spec.ServiceName, cd.Namespace, err)
+		}
+		if err == nil {
+			svc.Spec.Ports = governing.Spec.Ports
+			svc.Spec.PublishNotReadyAddresses = governing.Spec.PublishNotReadyAddresses
+		}
+	}
+
+	_, err = c.kubeClient.CoreV1().Services(cd.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{FieldManager: cd.FieldManager()})
+	if err != nil {
+		return fmt.Errorf("creating service %s.%s failed: %w", name, cd.Namespace, err)
+	}
+	c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Infof("Service %s.%s created", name, cd.Namespace)
+	return nil
+}
+
+// primaryUpdateStrategy copies the canary update strategy without the partition,
+// so that a promotion rolls out to all the primary pods
+func primaryUpdateStrategy(strategy appsv1.StatefulSetUpdateStrategy) appsv1.StatefulSetUpdateStrategy {
+	out := *strategy.DeepCopy()
+	if out.RollingUpdate != nil {
+		out.RollingUpdate.Partition = nil
+	}
+	return out
+}
+
+// getSelectorLabel returns the selector match label
+func (c *StatefulSetController) getSelectorLabel(sts *appsv1.StatefulSet) (string, string, error) {
+	for _, l := range c.labels {
+		if _, ok := sts.Spec.Selector.MatchLabels[l]; ok {
+			return l, sts.Spec.Selector.MatchLabels[l], nil
+		}
+	}
+
+	return "", "", fmt.Errorf(
+		"statefulset %s.%s spec.selector.matchLabels must contain one of %v",
+		sts.Name, sts.Namespace, c.labels,
+	)
+}
+
+func (c *StatefulSetController) HaveDependenciesChanged(cd *flaggerv1.Canary) (bool, error) {
+	return c.configTracker.HasConfigChanged(cd)
+}
+
+// Finalize sets the replicas of the primary on the target statefulset
+func (c *StatefulSetController) Finalize(cd *flaggerv1.Canary) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	primary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if err := c.ScaleFromZero(cd); err != nil {
+			return fmt.Errorf("ScaleFromZero failed: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("statefulset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	if err := c.scale(cd, int32Default(primary.Spec.Replicas)); err != nil {
+		return fmt.Errorf("scale failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestStatefulSetController_Initialize(t *testing.T) {
+	mocks := newStatefulSetFixture()
+	mocks.initializeCanary(t)
+
+	primary, err := mocks.kubeClient.AppsV1().StatefulSets("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "podinfo-primary", primary.Spec.Selector.MatchLabels["app"])
+	assert.Equal(t, "podinfo-primary", primary.Spec.Template.Labels["app"])
+	assert.Equal(t, "podinfo-primary-headless", primary.Spec.ServiceName)
+	assert.Equal(t, appsv1.OrderedReadyPodManagement, primary.Spec.PodManagementPolicy)
+	assert.Nil(t, primary.Spec.UpdateStrategy.RollingUpdate.Partition)
+	assert.Equal(t, int32(2), *primary.Spec.Replicas)
+	require.Len(t, primary.Spec.VolumeClaimTemplates, 1)
+	assert.Equal(t, "podinfo-config-env-primary", primary.Spec.Template.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Name)
+	assert.Equal(t, "podinfo-secret-env-primary", primary.Spec.Template.Spec.Containers[0].EnvFrom[1].SecretRef.Name)
+
+	headless, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo-primary-headless", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, corev1.ClusterIPNone, headless.Spec.ClusterIP)
+	assert.Equal(t, "podinfo-primary", headless.Spec.Selector["app"])
+	require.Len(t, headless.Spec.Ports, 1)
+	assert.Equal(t, int32(9898), headless.Spec.Ports[0].Port)
+
+	canary, err := mocks.kubeClient.AppsV1().StatefulSets("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *canary.Spec.Replicas)
+}
+
+func TestStatefulSetController_AutoscalerRef(t *testing.T) {
+	mocks := newStatefulSetFixture()
+	mocks.canary.Spec.AutoscalerRef = &flaggerv1.AutoscalerReference{Kind: "HorizontalPodAutoscaler", Name: "podinfo"}
+	assert.Error(t, mocks.controller.Initialize(mocks.canary))
+}
+
+func TestStatefulSetController_Promote(t *testing.T) {
+	mocks := newStatefulSetFixture()
+	mocks.initializeCanary(t)
+
+	// a new revision scales up the canary to the primary replicas
+	require.NoError(t, mocks.controller.ScaleFromZero(mocks.canary))
+	sts, err := mocks.kubeClient.AppsV1().StatefulSets("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), *sts.Spec.Replicas)
+
+	sts.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:1.2.1"
+	_, err = mocks.kubeClient.AppsV1().StatefulSets("default").Update(context.TODO(), sts, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, mocks.controller.Promote(mocks.canary))
+
+	primary, err := mocks.kubeClient.AppsV1().StatefulSets("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:1.2.1", primary.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "podinfo-primary", primary.Spec.Template.Labels["app"])
+	assert.Equal(t, int32(2), *primary.Spec.Replicas)
+	assert.Nil(t, primary.Spec.UpdateStrategy.RollingUpdate.Partition)
+}
+
+func TestStatefulSetController_IsReady(t *testing.T) {
+	mocks := newStatefulSetFixture()
+	mocks.initializeCanary(t)
+	require.NoError(t, mocks.controller.IsPrimaryReady(mocks.canary))
+
+	require.NoError(t, mocks.controller.ScaleFromZero(mocks.canary))
+	retryable, err := mocks.controller.IsCanaryReady(mocks.canary)
+	require.Error(t, err)
+	assert.True(t, retryable)
+
+	sts, err := mocks.kubeClient.AppsV1().StatefulSets("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	sts.Status = appsv1.StatefulSetStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 1}
+	_, err = mocks.kubeClient.AppsV1().StatefulSets("default").Update(context.TODO(), sts, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// the pods of an ordered statefulset are ready one at a time
+	_, err = mocks.controller.IsCanaryReady(mocks.canary)
+	require.Error(t, err)
+
+	sts.Status.ReadyReplicas = 2
+	_, err = mocks.kubeClient.AppsV1().StatefulSets("default").Update(context.TODO(), sts, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	_, err = mocks.controller.IsCanaryReady(mocks.canary)
+	require.NoError(t, err)
+}
+
+func TestStatefulSetController_HasTargetChanged(t *testing.T) {
+	mocks := newStatefulSetFixture()
+	mocks.initializeCanary(t)
+	require.NoError(t, mocks.controller.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitialized}))
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	changed, err := mocks.controller.HasTargetChanged(cd)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	sts, err := mocks.kubeClient.AppsV1().StatefulSets("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	sts.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:1.2.1"
+	_, err = mocks.kubeClient.AppsV1().StatefulSets("default").Update(context.TODO(), sts, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	changed, err = mocks.controller.HasTargetChanged(cd)
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestStatefulSetController_Finalize(t *testing.T) {
+	mocks := newStatefulSetFixture()
+	mocks.initializeCanary(t)
+
+	require.NoError(t, mocks.controller.Finalize(mocks.canary))
+	sts, err := mocks.kubeClient.AppsV1().StatefulSets("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), *sts.Spec.Replicas)
+}
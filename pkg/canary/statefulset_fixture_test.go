@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
+	fakeFlagger "github.com/fluxcd/flagger/pkg/client/clientset/versioned/fake"
+	"github.com/fluxcd/flagger/pkg/logger"
+)
+
+type statefulSetControllerFixture struct {
+	canary        *flaggerv1.Canary
+	kubeClient    kubernetes.Interface
+	flaggerClient clientset.Interface
+	controller    StatefulSetController
+}
+
+func (f statefulSetControllerFixture) initializeCanary(t *testing.T) {
+	err := f.controller.Initialize(f.canary)
+	require.Error(t, err) // not ready yet
+
+	primaryName := fmt.Sprintf("%s-primary", f.canary.Spec.TargetRef.Name)
+	p, err := f.kubeClient.AppsV1().StatefulSets(f.canary.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	p.Status = appsv1.StatefulSetStatus{
+		Replicas:        2,
+		UpdatedReplicas: 2,
+		ReadyReplicas:   2,
+	}
+	_, err = f.kubeClient.AppsV1().StatefulSets(f.canary.Namespace).Update(context.TODO(), p, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, f.controller.Initialize(f.canary))
+}
+
+func newStatefulSetFixture() statefulSetControllerFixture {
+	canary := newStatefulSetControllerTestCanary()
+	flaggerClient := fakeFlagger.NewSimpleClientset(canary)
+	kubeClient := fake.NewSimpleClientset(
+		newStatefulSetControllerTestPodInfo(),
+		newStatefulSetControllerTestHeadlessService(),
+		newDaemonSetControllerTestConfigMap(),
+		newDaemonSetControllerTestSecret(),
+	)
+
+	logger, _ := logger.NewLogger("debug")
+	ctrl := StatefulSetController{
+		flaggerClient: flaggerClient,
+		kubeClient:    kubeClient,
+		logger:        logger,
+		labels:        []string{"app", "name"},
+		configTracker: &ConfigTracker{
+			Logger:        logger,
+			KubeClient:    kubeClient,
+			FlaggerClient: flaggerClient,
+		},
+	}
+
+	return statefulSetControllerFixture{
+		canary:        canary,
+		controller:    ctrl,
+		flaggerClient: flaggerClient,
+		kubeClient:    kubeClient,
+	}
+}
+
+func newStatefulSetControllerTestCanary() *flaggerv1.Canary {
+	return &flaggerv1.Canary{
+		TypeMeta: metav1.TypeMeta{APIVersion: flaggerv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "podinfo",
+		},
+		Spec: flaggerv1.CanarySpec{
+			TargetRef: flaggerv1.LocalObjectReference{
+				Name:       "podinfo",
+				APIVersion: "apps/v1",
+				Kind:       "StatefulSet",
+			},
+			Analysis: &flaggerv1.CanaryAnalysis{},
+		},
+	}
+}
+
+func newStatefulSetControllerTestHeadlessService() *corev1.Service {
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "podinfo-headless",
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  map[string]string{"app": "podinfo"},
+			Ports:     []corev1.ServicePort{{Name: "http", Port: 9898}},
+		},
+	}
+}
+
+func newStatefulSetControllerTestPodInfo() *appsv1.StatefulSet {
+	partition := int32(1)
+	return &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "podinfo",
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            int32p(2),
+			ServiceName:         "podinfo-headless",
+			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+			},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "podinfo"},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "podinfo"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "podinfo",
+							Image: "quay.io/stefanprodan/podinfo:1.2.0",
+							Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 9898}},
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									ConfigMapRef: &corev1.ConfigMapEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{Name: "podinfo-config-env"},
+									},
+								},
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{Name: "podinfo-secret-env"},
+									},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}},
+						},
+					},
+				},
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "data"},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					},
+				},
+			},
+		},
+	}
+}
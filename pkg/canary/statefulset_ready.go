@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// IsPrimaryReady checks the primary statefulset status and returns an error if
+// the statefulset is in the middle of a rolling update or if the pods are unhealthy
+func (c *StatefulSetController) IsPrimaryReady(cd *flaggerv1.Canary) error {
+	primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	primary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("statefulset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	_, err = c.isStatefulSetReady(cd, primary, cd.GetAnalysisPrimaryReadyThreshold())
+	if err != nil {
+		return fmt.Errorf("primary statefulset %s.%s not ready: %w", primaryName, cd.Namespace, err)
+	}
+
+	if int32Default(primary.Spec.Replicas) == 0 {
+		return fmt.Errorf("halt %s.%s advancement: primary statefulset is scaled to zero",
+			cd.Name, cd.Namespace)
+	}
+	return nil
+}
+
+// IsCanaryReady checks the canary statefulset status and returns an error if
+// the statefulset is in the middle of a rolling update or if the pods are unhealthy
+func (c *StatefulSetController) IsCanaryReady(cd *flaggerv1.Canary) (bool, error) {
+	targetName := cd.Spec.TargetRef.Name
+	canary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+	if err != nil {
+		return true, fmt.Errorf("statefulset %s.%s get query error: %w", targetName, cd.Namespace, err)
+	}
+
+	retryable, err := c.isStatefulSetReady(cd, canary, cd.GetAnalysisCanaryReadyThreshold())
+	if err != nil {
+		return retryable, fmt.Errorf("canary statefulset %s.%s not ready with retryable %v: %w",
+			targetName, cd.Namespace, retryable, err)
+	}
+	return true, nil
+}
+
+// isStatefulSetReady determines if a statefulset is ready by checking the updated and ready replicas,
+// the pods of an ordered statefulset are started one at a time so the progress deadline
+// is counted from the last transition of the canary
+// reference: https://github.com/kubernetes/kubectl/blob/v0.23.3/pkg/polymorphichelpers/rollout_status.go#L120
+func (c *StatefulSetController) isStatefulSetReady(cd *flaggerv1.Canary, sts *appsv1.StatefulSet, readyThreshold int) (bool, error) {
+	if sts.Generation > sts.Status.ObservedGeneration {
+		return true, fmt.Errorf("waiting for rollout to finish: observed statefulset generation less than desired generation")
+	}
+
+	replicas := int32Default(sts.Spec.Replicas)
+	readyThresholdRatio := float32(readyThreshold) / float32(100)
+	readyThresholdReplicas := int32(float32(replicas) * readyThresholdRatio)
+
+	var err error
+	switch {
+	case sts.Status.UpdatedReplicas < replicas:
+		err = fmt.Errorf("waiting for rollout to finish: %d out of %d new pods have been updated",
+			sts.Status.UpdatedReplicas, replicas)
+	case sts.Status.ReadyReplicas < readyThresholdReplicas:
+		err = fmt.Errorf("waiting for rollout to finish: %d of %d (readyThreshold %d%%) pods are ready",
+			sts.Status.ReadyReplicas, readyThresholdReplicas, readyThreshold)
+	case sts.Status.UpdateRevision != sts.Status.CurrentRevision && sts.Status.Replicas > sts.Status.UpdatedReplicas:
+		err = fmt.Errorf("waiting for rollout to finish: %d old pods are pending termination",
+			sts.Status.Replicas-sts.Status.UpdatedReplicas)
+	}
+	if err == nil {
+		return true, nil
+	}
+
+	// check if deadline exceeded
+	from := cd.Status.LastTransitionTime
+	delta := time.Duration(cd.GetProgressDeadlineSeconds()) * time.Second
+	if !from.IsZero() && from.Add(delta).Before(time.Now()) {
+		return false, fmt.Errorf("exceeded its progressDeadlineSeconds: %d", cd.GetProgressDeadlineSeconds())
+	}
+	return true, err
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// SyncStatus encodes the canary pod spec and updates the canary status
+func (c *StatefulSetController) SyncStatus(cd *flaggerv1.Canary, status flaggerv1.CanaryStatus) error {
+	sts, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), cd.Spec.TargetRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("statefulset %s.%s get query error: %w", cd.Spec.TargetRef.Name, cd.Namespace, err)
+	}
+
+	configs, err := c.configTracker.GetConfigRefs(cd)
+	if err != nil {
+		return fmt.Errorf("GetConfigRefs failed: %w", err)
+	}
+
+	// record the primary template as the last promoted revision
+	if status.Phase == flaggerv1.CanaryPhaseInitialized {
+		primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+		if err != nil {
+			return err
+		}
+		primary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("statefulset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+		}
+		spec := computeHash(hashedPodTemplate(cd, sts.Spec.Template))
+		if err := recordPromotedTemplate(c.kubeClient, cd, spec, primary.Spec.Template); err != nil {
+			return err
+		}
+	}
+
+	return syncCanaryStatus(c.flaggerClient, cd, status, hashedPodTemplate(cd, sts.Spec.Template), func(cdCopy *flaggerv1.Canary) {
+		cdCopy.Status.TrackedConfigs = configs
+		if status.Phase == flaggerv1.CanaryPhaseInitialized {
+			cdCopy.Status.LastPromotedImages = podImages(sts.Spec.Template.Spec)
+		}
+	})
+}
+
+// SetStatusFailedChecks updates the canary failed checks counter
+func (c *StatefulSetController) SetStatusFailedChecks(cd *flaggerv1.Canary, val int) error {
+	return setStatusFailedChecks(c.flaggerClient, cd, val)
+}
+
+// SetStatusWeight updates the canary status weight value
+func (c *StatefulSetController) SetStatusWeight(cd *flaggerv1.Canary, val int) error {
+	return setStatusWeight(c.flaggerClient, cd, val)
+}
+
+// SetStatusIterations updates the canary status iterations value
+func (c *StatefulSetController) SetStatusIterations(cd *flaggerv1.Canary, val int) error {
+	return setStatusIterations(c.flaggerClient, cd, val)
+}
+
+// SetStatusPhase updates the canary status phase, on promotion
+// the primary images and pod template are recorded as the last promoted revision
+func (c *StatefulSetController) SetStatusPhase(cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase) error {
+	var images map[string]string
+	if phase == flaggerv1.CanaryPhaseInitialized || phase == flaggerv1.CanaryPhaseSucceeded {
+		primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+		if err != nil {
+			return err
+		}
+		primary, err := c.kubeClient.AppsV1().StatefulSets(cd.Namespace).Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("statefulset %s.%s get query error: %w", primaryName, cd.Namespace, err)
+		}
+		images = podImages(primary.Spec.Template.Spec)
+		if err := recordPromotedTemplate(c.kubeClient, cd, cd.Status.LastAppliedSpec, primary.Spec.Template); err != nil {
+			return err
+		}
+	}
+	return setStatusPhase(c.flaggerClient, cd, phase, images)
+}
+
+// SetStatusFinalization updates the canary status finalization
+func (c *StatefulSetController) SetStatusFinalization(cd *flaggerv1.Canary, finalization []flaggerv1.CanaryFinalizationStatus) error {
+	return setStatusFinalization(c.flaggerClient, cd, finalization)
+}
+
+// SetStatusMetrics updates the canary status metrics
+func (c *StatefulSetController) SetStatusMetrics(cd *flaggerv1.Canary, metrics []flaggerv1.CanaryMetricStatus) error {
+	return setStatusMetrics(c.flaggerClient, cd, metrics)
+}
+
+// SetStatusFailures updates the canary status failures
+func (c *StatefulSetController) SetStatusFailures(cd *flaggerv1.Canary, failures []flaggerv1.CanaryCheckFailure) error {
+	return setStatusFailures(c.flaggerClient, cd, failures)
+}
+
+// SetStatusWebhooks updates the last result of the webhooks
+func (c *StatefulSetController) SetStatusWebhooks(cd *flaggerv1.Canary, webhooks []flaggerv1.CanaryWebhookStatus) error {
+	return setStatusWebhooks(c.flaggerClient, cd, webhooks)
+}
+
+// SetStatusNextAnalysisTime records when the next analysis run is due
+func (c *StatefulSetController) SetStatusNextAnalysisTime(cd *flaggerv1.Canary, next metav1.Time) error {
+	return setStatusNextAnalysisTime(c.flaggerClient, cd, next)
+}
@@ -23,6 +23,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/util/retry"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -47,14 +48,36 @@ func syncCanaryStatus(flaggerClient clientset.Interface, cd *flaggerv1.Canary, s
 		cdCopy.Status.CanaryWeight = status.CanaryWeight
 		cdCopy.Status.FailedChecks = status.FailedChecks
 		cdCopy.Status.Iterations = status.Iterations
+		cdCopy.Status.AdaptiveStepWeight = status.AdaptiveStepWeight
+		cdCopy.Status.RetryAttempts = status.RetryAttempts
+		cdCopy.Status.NextRetryTime = status.NextRetryTime
+		cdCopy.Status.RollbackDrain = status.RollbackDrain
 		cdCopy.Status.LastAppliedSpec = hash
+		cdCopy.Status.LastAppliedFastPathSpec = ""
+		if template, ok := canaryResource.(corev1.PodTemplateSpec); ok && cd.Spec.FastPath != nil {
+			cdCopy.Status.LastAppliedFastPathSpec = computeHash(fastPathTemplate(cd, template))
+		}
 		if status.Phase == flaggerv1.CanaryPhaseInitialized {
 			cdCopy.Status.LastPromotedSpec = hash
+			cdCopy.Status.LastPromotedFastPathSpec = cdCopy.Status.LastAppliedFastPathSpec
 		}
 		cdCopy.Status.LastTransitionTime = metav1.Now()
+		// each analysis starts or restarts in the progressing phase
+		if status.Phase == flaggerv1.CanaryPhaseProgressing {
+			cdCopy.Status.RunID = string(uuid.NewUUID())
+			cdCopy.Status.RunStartTime = cdCopy.Status.LastTransitionTime
+			cdCopy.Status.Failures = nil
+			cdCopy.Status.ChangedImages = nil
+			cdCopy.Status.FastPath = cdCopy.Status.LastAppliedFastPathSpec != "" &&
+				cdCopy.Status.LastAppliedFastPathSpec == cd.Status.LastPromotedFastPathSpec
+			if template, ok := canaryResource.(corev1.PodTemplateSpec); ok {
+				cdCopy.Status.ChangedImages = changedImages(cd, template.Spec)
+			}
+		}
 		setAll(cdCopy)
+		cdCopy.Status.ObservedGeneration = cd.Generation
 
-		if ok, conditions := MakeStatusConditions(cd, status.Phase); ok {
+		if ok, conditions := MakeStatusConditions(cdCopy, status.Phase); ok {
 			cdCopy.Status.Conditions = conditions
 		}
 
@@ -106,6 +129,7 @@ func setStatusWeight(flaggerClient clientset.Interface, cd *flaggerv1.Canary, va
 		cdCopy := cd.DeepCopy()
 		cdCopy.Status.CanaryWeight = val
 		cdCopy.Status.LastTransitionTime = metav1.Now()
+		cdCopy.Status.StepStartTime = cdCopy.Status.LastTransitionTime
 
 		err = updateStatusWithUpgrade(flaggerClient, cdCopy)
 		firstTry = false
@@ -143,7 +167,7 @@ func setStatusIterations(flaggerClient clientset.Interface, cd *flaggerv1.Canary
 	return nil
 }
 
-func setStatusPhase(flaggerClient clientset.Interface, cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase) error {
+func setStatusPhase(flaggerClient clientset.Interface, cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase, promotedImages map[string]string) error {
 	firstTry := true
 	name, ns := cd.GetName(), cd.GetNamespace()
 	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
@@ -158,6 +182,12 @@ func setStatusPhase(flaggerClient clientset.Interface, cd *flaggerv1.Canary, pha
 		cdCopy.Status.Phase = phase
 		cdCopy.Status.LastTransitionTime = metav1.Now()
 
+		// the metric retries are counted from the start of the analysis
+		if phase == flaggerv1.CanaryPhaseProgressing && cd.Status.Phase != flaggerv1.CanaryPhaseProgressing {
+			cdCopy.Status.Metrics = nil
+			cdCopy.Status.Failures = nil
+		}
+
 		if phase != flaggerv1.CanaryPhaseProgressing && phase != flaggerv1.CanaryPhaseWaiting {
 			cdCopy.Status.CanaryWeight = 0
 			cdCopy.Status.Iterations = 0
@@ -166,11 +196,16 @@ func setStatusPhase(flaggerClient clientset.Interface, cd *flaggerv1.Canary, pha
 			}
 		}
 
-		// on promotion set primary spec hash
+		// on promotion set primary spec hash and images
 		if phase == flaggerv1.CanaryPhaseInitialized || phase == flaggerv1.CanaryPhaseSucceeded {
 			cdCopy.Status.LastPromotedSpec = cd.Status.LastAppliedSpec
+			cdCopy.Status.LastPromotedFastPathSpec = cd.Status.LastAppliedFastPathSpec
+			if promotedImages != nil {
+				cdCopy.Status.LastPromotedImages = promotedImages
+			}
 		}
 
+		cdCopy.Status.ObservedGeneration = cd.Generation
 		if ok, conditions := MakeStatusConditions(cdCopy, phase); ok {
 			cdCopy.Status.Conditions = conditions
 		}
@@ -185,6 +220,123 @@ func setStatusPhase(flaggerClient clientset.Interface, cd *flaggerv1.Canary, pha
 	return nil
 }
 
+func setStatusFinalization(flaggerClient clientset.Interface, cd *flaggerv1.Canary, finalization []flaggerv1.CanaryFinalizationStatus) error {
+	firstTry := true
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			cd, err = flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+
+		cdCopy := cd.DeepCopy()
+		cdCopy.Status.Finalization = finalization
+
+		err = updateStatusWithUpgrade(flaggerClient, cdCopy)
+		firstTry = false
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
+
+func setStatusMetrics(flaggerClient clientset.Interface, cd *flaggerv1.Canary, metrics []flaggerv1.CanaryMetricStatus) error {
+	firstTry := true
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			cd, err = flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+
+		cdCopy := cd.DeepCopy()
+		cdCopy.Status.Metrics = metrics
+
+		err = updateStatusWithUpgrade(flaggerClient, cdCopy)
+		firstTry = false
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
+
+func setStatusFailures(flaggerClient clientset.Interface, cd *flaggerv1.Canary, failures []flaggerv1.CanaryCheckFailure) error {
+	firstTry := true
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			cd, err = flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+
+		cdCopy := cd.DeepCopy()
+		cdCopy.Status.Failures = failures
+
+		err = updateStatusWithUpgrade(flaggerClient, cdCopy)
+		firstTry = false
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
+
+// setStatusWebhooks reads the canary before the update, the post-rollout and post-rollback webhooks
+// run after the phase is changed and the in-memory canary holds the previous phase
+func setStatusWebhooks(flaggerClient clientset.Interface, cd *flaggerv1.Canary, webhooks []flaggerv1.CanaryWebhookStatus) error {
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		cd, err = flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+		}
+
+		cdCopy := cd.DeepCopy()
+		cdCopy.Status.Webhooks = webhooks
+
+		return updateStatusWithUpgrade(flaggerClient, cdCopy)
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
+
+func setStatusNextAnalysisTime(flaggerClient clientset.Interface, cd *flaggerv1.Canary, next metav1.Time) error {
+	firstTry := true
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			cd, err = flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+
+		cdCopy := cd.DeepCopy()
+		cdCopy.Status.NextAnalysisTime = next
+
+		err = updateStatusWithUpgrade(flaggerClient, cdCopy)
+		firstTry = false
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
+
 // getStatusCondition returns a condition based on type
 func getStatusCondition(status flaggerv1.CanaryStatus, conditionType flaggerv1.CanaryConditionType) *flaggerv1.CanaryCondition {
 	for i := range status.Conditions {
@@ -199,8 +351,6 @@ func getStatusCondition(status flaggerv1.CanaryStatus, conditionType flaggerv1.C
 // MakeStatusCondition updates the canary status conditions based on canary phase
 func MakeStatusConditions(cd *flaggerv1.Canary,
 	phase flaggerv1.CanaryPhase) (bool, []flaggerv1.CanaryCondition) {
-	currentCondition := getStatusCondition(cd.Status, flaggerv1.PromotedType)
-
 	message := fmt.Sprintf("New %s detected, starting initialization.", cd.Spec.TargetRef.Kind)
 	status := corev1.ConditionUnknown
 	switch phase {
@@ -231,28 +381,86 @@ func MakeStatusConditions(cd *flaggerv1.Canary,
 	case flaggerv1.CanaryPhaseFailed:
 		status = corev1.ConditionFalse
 		message = fmt.Sprintf("Canary analysis failed, %s scaled to zero.", cd.Spec.TargetRef.Kind)
+	case flaggerv1.CanaryPhaseDryRunSucceeded:
+		status = corev1.ConditionFalse
+		message = fmt.Sprintf("Dry-run analysis completed successfully, %s scaled to zero without promotion.", cd.Spec.TargetRef.Kind)
 	}
 
-	newCondition := &flaggerv1.CanaryCondition{
-		Type:               flaggerv1.PromotedType,
-		Status:             status,
-		LastUpdateTime:     metav1.Now(),
-		LastTransitionTime: metav1.Now(),
-		Message:            message,
-		Reason:             string(phase),
+	newConditions := append([]flaggerv1.CanaryCondition{{
+		Type:    flaggerv1.PromotedType,
+		Status:  status,
+		Message: message,
+		Reason:  string(phase),
+	}}, makePhaseConditions(cd, phase, message)...)
+
+	// keep the conditions that don't depend on the canary phase
+	conditions := make([]flaggerv1.CanaryCondition, 0, len(cd.Status.Conditions)+len(newConditions))
+	for _, c := range cd.Status.Conditions {
+		if !isPhaseCondition(c.Type) {
+			conditions = append(conditions, c)
+		}
 	}
 
-	if currentCondition != nil &&
-		currentCondition.Status == newCondition.Status &&
-		currentCondition.Reason == newCondition.Reason {
+	changed := false
+	now := metav1.Now()
+	for _, newCondition := range newConditions {
+		newCondition.ObservedGeneration = cd.Generation
+		newCondition.LastUpdateTime = now
+		newCondition.LastTransitionTime = now
+
+		currentCondition := getStatusCondition(cd.Status, newCondition.Type)
+		if currentCondition != nil && currentCondition.Status == newCondition.Status {
+			newCondition.LastTransitionTime = currentCondition.LastTransitionTime
+			if currentCondition.Reason == newCondition.Reason &&
+				currentCondition.ObservedGeneration == newCondition.ObservedGeneration {
+				newCondition = *currentCondition
+			}
+		}
+		changed = changed || currentCondition == nil || *currentCondition != newCondition
+		conditions = append(conditions, newCondition)
+	}
+	if !changed {
 		return false, nil
 	}
 
-	if currentCondition != nil && currentCondition.Status == newCondition.Status {
-		newCondition.LastTransitionTime = currentCondition.LastTransitionTime
+	return true, conditions
+}
+
+// makePhaseConditions returns the standard Progressing, Healthy, RolledBack and Stalled conditions
+// of the canary phase, so that generic tools like kstatus can compute the readiness of a canary
+func makePhaseConditions(cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase, message string) []flaggerv1.CanaryCondition {
+	progressing, healthy, rolledBack, stalled := corev1.ConditionFalse, corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionFalse
+	switch phase {
+	case flaggerv1.CanaryPhaseInitializing:
+		progressing, healthy = corev1.ConditionTrue, corev1.ConditionUnknown
+	case flaggerv1.CanaryPhaseWaiting, flaggerv1.CanaryPhaseProgressing, flaggerv1.CanaryPhaseWaitingPromotion,
+		flaggerv1.CanaryPhasePromoting, flaggerv1.CanaryPhaseFinalising:
+		progressing = corev1.ConditionTrue
+	case flaggerv1.CanaryPhaseFailed:
+		healthy, rolledBack = corev1.ConditionFalse, corev1.ConditionTrue
+		// a failed canary is analysed again when a new revision is applied or a retry is scheduled
+		if cd.Status.NextRetryTime.IsZero() {
+			stalled = corev1.ConditionTrue
+		}
 	}
 
-	return true, []flaggerv1.CanaryCondition{*newCondition}
+	reason := string(phase)
+	return []flaggerv1.CanaryCondition{
+		{Type: flaggerv1.ProgressingType, Status: progressing, Reason: reason, Message: message},
+		{Type: flaggerv1.HealthyType, Status: healthy, Reason: reason, Message: message},
+		{Type: flaggerv1.RolledBackType, Status: rolledBack, Reason: reason, Message: message},
+		{Type: flaggerv1.StalledType, Status: stalled, Reason: reason, Message: message},
+	}
+}
+
+// isPhaseCondition returns true if the condition is computed from the canary phase
+func isPhaseCondition(conditionType flaggerv1.CanaryConditionType) bool {
+	switch conditionType {
+	case flaggerv1.PromotedType, flaggerv1.ProgressingType, flaggerv1.HealthyType,
+		flaggerv1.RolledBackType, flaggerv1.StalledType:
+		return true
+	}
+	return false
 }
 
 // updateStatusWithUpgrade tries to update the status sub-resource
@@ -260,14 +468,14 @@ func MakeStatusConditions(cd *flaggerv1.Canary,
 // Canary.flagger.app is invalid: apiVersion: Invalid value: flagger.app/v1alpha3: must be flagger.app/v1beta1
 // then the canary object will be updated to the latest API version
 func updateStatusWithUpgrade(flaggerClient clientset.Interface, cd *flaggerv1.Canary) error {
-	_, err := flaggerClient.FlaggerV1beta1().Canaries(cd.Namespace).UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+	_, err := flaggerClient.FlaggerV1beta1().Canaries(cd.Namespace).UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 	if err != nil && strings.Contains(err.Error(), "flagger.app/v1alpha") {
 		// upgrade alpha resource
-		if _, updateErr := flaggerClient.FlaggerV1beta1().Canaries(cd.Namespace).Update(context.TODO(), cd, metav1.UpdateOptions{}); updateErr != nil {
+		if _, updateErr := flaggerClient.FlaggerV1beta1().Canaries(cd.Namespace).Update(context.TODO(), cd, metav1.UpdateOptions{FieldManager: cd.FieldManager()}); updateErr != nil {
 			return fmt.Errorf("updating canary %s.%s from v1alpha to v1beta failed: %w", cd.Name, cd.Namespace, updateErr)
 		}
 		// retry status update
-		_, err = flaggerClient.FlaggerV1beta1().Canaries(cd.Namespace).UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+		_, err = flaggerClient.FlaggerV1beta1().Canaries(cd.Namespace).UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 	}
 
 	if err != nil {
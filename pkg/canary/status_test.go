@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestMakeStatusConditions(t *testing.T) {
+	cd := &flaggerv1.Canary{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Generation: 2}}
+	cd.Status.Conditions = []flaggerv1.CanaryCondition{
+		{Type: flaggerv1.RouterReadyType, Status: corev1.ConditionTrue, Reason: "Ready"},
+	}
+
+	ok, conditions := MakeStatusConditions(cd, flaggerv1.CanaryPhaseProgressing)
+	require.True(t, ok)
+	cd.Status.Conditions = conditions
+	assert.Equal(t, corev1.ConditionTrue, getStatusCondition(cd.Status, flaggerv1.RouterReadyType).Status)
+	assert.Equal(t, corev1.ConditionUnknown, getStatusCondition(cd.Status, flaggerv1.PromotedType).Status)
+	assert.Equal(t, corev1.ConditionTrue, getStatusCondition(cd.Status, flaggerv1.ProgressingType).Status)
+	assert.Equal(t, corev1.ConditionTrue, getStatusCondition(cd.Status, flaggerv1.HealthyType).Status)
+	assert.Equal(t, corev1.ConditionFalse, getStatusCondition(cd.Status, flaggerv1.StalledType).Status)
+	assert.Equal(t, int64(2), getStatusCondition(cd.Status, flaggerv1.ProgressingType).ObservedGeneration)
+
+	// the conditions are not updated when the phase doesn't change
+	ok, _ = MakeStatusConditions(cd, flaggerv1.CanaryPhaseProgressing)
+	assert.False(t, ok)
+
+	// a failed canary without a scheduled retry is rolled back and stalled
+	ok, conditions = MakeStatusConditions(cd, flaggerv1.CanaryPhaseFailed)
+	require.True(t, ok)
+	cd.Status.Conditions = conditions
+	assert.Equal(t, corev1.ConditionFalse, getStatusCondition(cd.Status, flaggerv1.ProgressingType).Status)
+	assert.Equal(t, corev1.ConditionFalse, getStatusCondition(cd.Status, flaggerv1.HealthyType).Status)
+	assert.Equal(t, corev1.ConditionTrue, getStatusCondition(cd.Status, flaggerv1.RolledBackType).Status)
+	assert.Equal(t, corev1.ConditionTrue, getStatusCondition(cd.Status, flaggerv1.StalledType).Status)
+	assert.Equal(t, string(flaggerv1.CanaryPhaseFailed), getStatusCondition(cd.Status, flaggerv1.StalledType).Reason)
+
+	cd.Status.NextRetryTime = metav1.Now()
+	ok, conditions = MakeStatusConditions(cd, flaggerv1.CanaryPhaseFailed)
+	require.True(t, ok)
+	cd.Status.Conditions = conditions
+	assert.Equal(t, corev1.ConditionFalse, getStatusCondition(cd.Status, flaggerv1.StalledType).Status)
+	assert.Len(t, cd.Status.Conditions, 6)
+}
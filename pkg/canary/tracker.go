@@ -26,5 +26,5 @@ type Tracker interface {
 	GetConfigRefs(cd *flaggerv1.Canary) (*map[string]string, error)
 	HasConfigChanged(cd *flaggerv1.Canary) (bool, error)
 	CreatePrimaryConfigs(cd *flaggerv1.Canary, refs map[string]ConfigRef, includeLabelPrefix []string) error
-	ApplyPrimaryConfigs(spec corev1.PodSpec, refs map[string]ConfigRef) corev1.PodSpec
+	ApplyPrimaryConfigs(cd *flaggerv1.Canary, spec corev1.PodSpec, refs map[string]ConfigRef) (corev1.PodSpec, error)
 }
@@ -69,6 +69,33 @@ func getPorts(cd *flaggerv1.Canary, cs []corev1.Container) map[string]int32 {
 	return ports
 }
 
+// podImages returns the images of the pod containers and init containers by container name
+func podImages(spec corev1.PodSpec) map[string]string {
+	images := make(map[string]string, len(spec.InitContainers)+len(spec.Containers))
+	for _, container := range spec.InitContainers {
+		images[container.Name] = container.Image
+	}
+	for _, container := range spec.Containers {
+		images[container.Name] = container.Image
+	}
+	return images
+}
+
+// restoreImages sets the given images on the matching pod containers,
+// it returns true if any of the images has been changed
+func restoreImages(spec *corev1.PodSpec, images map[string]string) bool {
+	changed := false
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			if image, ok := images[containers[i].Name]; ok && containers[i].Image != image {
+				containers[i].Image = image
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
 const toolkitMarker = "toolkit.fluxcd.io"
 
 // makeAnnotations appends an unique ID to annotations map
@@ -125,6 +152,15 @@ func includeLabelsByPrefix(labels map[string]string, includeLabelPrefixes []stri
 	return filteredLabels
 }
 
+// propagateMetadata returns the labels or annotations selected by the canary metadata propagation policy,
+// the defaults are returned when no policy is set
+func propagateMetadata(selector *flaggerv1.MetadataSelector, meta map[string]string, defaults map[string]string) map[string]string {
+	if selector == nil {
+		return defaults
+	}
+	return filterMetadata(selector.Select(meta))
+}
+
 func makePrimaryLabels(labels map[string]string, labelValue string, label string) map[string]string {
 	res := make(map[string]string)
 	for k, v := range labels {
@@ -140,6 +176,19 @@ func makePrimaryLabels(labels map[string]string, labelValue string, label string
 	return res
 }
 
+// makeAuditLabels returns a copy of the labels with the canary audit labels added
+func makeAuditLabels(cd *flaggerv1.Canary, labels map[string]string) map[string]string {
+	res := make(map[string]string)
+	for k, v := range labels {
+		res[k] = v
+	}
+	for k, v := range cd.AuditLabels() {
+		res[k] = v
+	}
+
+	return res
+}
+
 func int32p(i int32) *int32 {
 	return &i
 }
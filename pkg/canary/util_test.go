@@ -17,9 +17,14 @@ limitations under the License.
 package canary
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
 func TestIncludeLabelsByPrefix(t *testing.T) {
@@ -69,3 +74,51 @@ func TestMakePrimaryLabels(t *testing.T) {
 		"foo":   "new-bar", // overriden value for a specific label
 	})
 }
+
+func TestMakeAuditLabels(t *testing.T) {
+	cd := &flaggerv1.Canary{ObjectMeta: metav1.ObjectMeta{Name: "podinfo"}}
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "Helm",
+		"app.kubernetes.io/part-of":    "shop",
+	}
+
+	// the labels set by Helm or the user are kept
+	assert.Equal(t, map[string]string{
+		"app.kubernetes.io/managed-by": "Helm",
+		"app.kubernetes.io/part-of":    "shop",
+		"flagger.app/canary":           "podinfo",
+	}, makeAuditLabels(cd, labels))
+
+	cd.Status.RunID = "c5d6e7f8-0000-4000-8000-000000000000"
+	assert.Equal(t, cd.Status.RunID, makeAuditLabels(cd, nil)["flagger.app/run-id"])
+
+	// the long names are truncated to a valid label value
+	cd.Name = strings.Repeat("podinfo-", 10)
+	value := makeAuditLabels(cd, nil)["flagger.app/canary"]
+	assert.Len(t, value, 63)
+	assert.True(t, strings.HasPrefix(value, cd.Name[:54]))
+	cd.Name += "x"
+	assert.NotEqual(t, value, makeAuditLabels(cd, nil)["flagger.app/canary"])
+}
+
+func TestRestoreImages(t *testing.T) {
+	spec := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Image: "init:1.0"}},
+		Containers: []corev1.Container{
+			{Name: "app", Image: "app:1.0"},
+			{Name: "sidecar", Image: "sidecar:1.0"},
+		},
+	}
+	images := podImages(spec)
+	assert.Equal(t, map[string]string{"init": "init:1.0", "app": "app:1.0", "sidecar": "sidecar:1.0"}, images)
+
+	// nothing to restore
+	assert.False(t, restoreImages(&spec, images))
+
+	spec.InitContainers[0].Image = "init:2.0"
+	spec.Containers[0].Image = "app:2.0"
+	assert.True(t, restoreImages(&spec, images))
+	assert.Equal(t, "init:1.0", spec.InitContainers[0].Image)
+	assert.Equal(t, "app:1.0", spec.Containers[0].Image)
+	assert.Equal(t, "sidecar:1.0", spec.Containers[1].Image)
+}
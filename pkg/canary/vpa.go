@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// VerticalPodAutoscalerGVR is the resource of the Kubernetes VerticalPodAutoscaler
+var VerticalPodAutoscalerGVR = schema.GroupVersionResource{
+	Group:    "autoscaling.k8s.io",
+	Version:  "v1",
+	Resource: "verticalpodautoscalers",
+}
+
+// hasHorizontalAutoscaler returns true if the replicas of the primary are managed by an HPA
+func hasHorizontalAutoscaler(cd *flaggerv1.Canary) bool {
+	return cd.Spec.AutoscalerRef != nil && cd.Spec.AutoscalerRef.Kind != "VerticalPodAutoscaler"
+}
+
+// reconcilePrimaryVpas creates or updates a VerticalPodAutoscaler targeting the primary deployment
+// for the autoscaler reference and for each VerticalPodAutoscaler that targets the canary deployment,
+// and removes the primary copies of the autoscalers that no longer target it
+func (c *DeploymentController) reconcilePrimaryVpas(cd *flaggerv1.Canary) error {
+	vpaRef := ""
+	if cd.Spec.AutoscalerRef != nil && cd.Spec.AutoscalerRef.Kind == "VerticalPodAutoscaler" {
+		vpaRef = cd.Spec.AutoscalerRef.Name
+	}
+	if c.dynamicClient == nil {
+		if vpaRef != "" {
+			return fmt.Errorf("VerticalPodAutoscaler %s.%s can't be queried without a dynamic client", vpaRef, cd.Namespace)
+		}
+		return nil
+	}
+
+	client := c.dynamicClient.Resource(VerticalPodAutoscalerGVR).Namespace(cd.Namespace)
+	vpas, err := client.List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		// the VerticalPodAutoscaler CRD is not installed
+		if errors.IsNotFound(err) && vpaRef == "" {
+			return nil
+		}
+		return fmt.Errorf("VerticalPodAutoscalers %s list query error: %w", cd.Namespace, err)
+	}
+
+	targetName := cd.Spec.TargetRef.Name
+	primaryNames := make(map[string]bool)
+	refFound := false
+	for i := range vpas.Items {
+		vpa := &vpas.Items[i]
+		if owner := metav1.GetControllerOf(vpa); owner != nil && owner.Kind == flaggerv1.CanaryKind {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "kind")
+		name, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "name")
+		isRef := vpa.GetName() == vpaRef
+		if !isRef && (kind != "Deployment" || name != targetName) {
+			continue
+		}
+		refFound = refFound || isRef
+
+		primaryName, err := cd.GetPrimaryName(vpa.GetName())
+		if err != nil {
+			return err
+		}
+		primaryNames[primaryName] = true
+		if err := c.reconcilePrimaryVpa(cd, vpa, primaryName); err != nil {
+			return err
+		}
+	}
+	if vpaRef != "" && !refFound {
+		return fmt.Errorf("VerticalPodAutoscaler %s.%s not found", vpaRef, cd.Namespace)
+	}
+
+	// remove the copies of the deleted autoscalers
+	for i := range vpas.Items {
+		vpa := &vpas.Items[i]
+		if !metav1.IsControlledBy(vpa, cd) || primaryNames[vpa.GetName()] {
+			continue
+		}
+		if err := client.Delete(context.TODO(), vpa.GetName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("deleting VerticalPodAutoscaler %s.%s failed: %w", vpa.GetName(), cd.Namespace, err)
+		}
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("VerticalPodAutoscaler %s.%s deleted", vpa.GetName(), cd.Namespace)
+	}
+	return nil
+}
+
+func (c *DeploymentController) reconcilePrimaryVpa(cd *flaggerv1.Canary, vpa *unstructured.Unstructured, primaryName string) error {
+	spec, ok, err := unstructured.NestedMap(vpa.Object, "spec")
+	if err != nil || !ok {
+		return fmt.Errorf("VerticalPodAutoscaler %s.%s spec is invalid: %v", vpa.GetName(), cd.Namespace, err)
+	}
+	// retarget the autoscaler to the primary deployment
+	primaryTargetName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	targetRef := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"name":       primaryTargetName,
+	}
+	if err := unstructured.SetNestedMap(spec, targetRef, "targetRef"); err != nil {
+		return fmt.Errorf("VerticalPodAutoscaler %s.%s targetRef update failed: %w", vpa.GetName(), cd.Namespace, err)
+	}
+
+	client := c.dynamicClient.Resource(VerticalPodAutoscalerGVR).Namespace(cd.Namespace)
+	primaryVpa, err := client.Get(context.TODO(), primaryName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		primaryVpa = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": vpa.GetAPIVersion(),
+			"kind":       vpa.GetKind(),
+			"spec":       spec,
+		}}
+		primaryVpa.SetName(primaryName)
+		primaryVpa.SetNamespace(cd.Namespace)
+		primaryVpa.SetLabels(propagateMetadata(cd.GetLabelPropagation(), vpa.GetLabels(), filterMetadata(vpa.GetLabels())))
+		primaryVpa.SetAnnotations(propagateMetadata(cd.GetAnnotationPropagation(), vpa.GetAnnotations(), filterMetadata(vpa.GetAnnotations())))
+		primaryVpa.SetOwnerReferences([]metav1.OwnerReference{
+			*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+				Group:   flaggerv1.SchemeGroupVersion.Group,
+				Version: flaggerv1.SchemeGroupVersion.Version,
+				Kind:    flaggerv1.CanaryKind,
+			}),
+		})
+
+		_, err = client.Create(context.TODO(), primaryVpa, metav1.CreateOptions{FieldManager: cd.FieldManager()})
+		if err != nil {
+			return fmt.Errorf("creating VerticalPodAutoscaler %s.%s failed: %w", primaryName, cd.Namespace, err)
+		}
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Infof("VerticalPodAutoscaler %s.%s created", primaryName, cd.Namespace)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("VerticalPodAutoscaler %s.%s get query error: %w", primaryName, cd.Namespace, err)
+	}
+
+	if primarySpec, _, _ := unstructured.NestedMap(primaryVpa.Object, "spec"); equality.Semantic.DeepEqual(spec, primarySpec) {
+		return nil
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		primaryVpa, err := client.Get(context.TODO(), primaryName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		vpaClone := primaryVpa.DeepCopy()
+		if err := unstructured.SetNestedMap(vpaClone.Object, spec, "spec"); err != nil {
+			return err
+		}
+		vpaClone.SetLabels(propagateMetadata(cd.GetLabelPropagation(), vpa.GetLabels(), filterMetadata(vpa.GetLabels())))
+		vpaClone.SetAnnotations(propagateMetadata(cd.GetAnnotationPropagation(), vpa.GetAnnotations(), filterMetadata(vpa.GetAnnotations())))
+
+		_, err = client.Update(context.TODO(), vpaClone, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("updating VerticalPodAutoscaler %s.%s failed: %w", primaryName, cd.Namespace, err)
+	}
+	c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Infof("VerticalPodAutoscaler %s.%s updated", primaryName, cd.Namespace)
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// ErrInjected is wrapped by all the synthetic failures
+var ErrInjected = errors.New("injected failure")
+
+// Injector returns synthetic failures at the configured rates so that operators
+// can verify that their alerting and rollback configurations are working.
+// A nil Injector never injects failures.
+type Injector struct {
+	providerTimeoutRate float64
+	routerErrorRate     float64
+	webhookErrorRate    float64
+	random              func() float64
+}
+
+// NewInjector validates the failure rates and returns an Injector,
+// the returned Injector is nil if all the rates are zero
+func NewInjector(providerTimeoutRate, routerErrorRate, webhookErrorRate float64) (*Injector, error) {
+	for name, rate := range map[string]float64{
+		"provider timeout": providerTimeoutRate,
+		"router error":     routerErrorRate,
+		"webhook error":    webhookErrorRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s rate %v must be between 0 and 1", name, rate)
+		}
+	}
+
+	if providerTimeoutRate == 0 && routerErrorRate == 0 && webhookErrorRate == 0 {
+		return nil, nil
+	}
+
+	return &Injector{
+		providerTimeoutRate: providerTimeoutRate,
+		routerErrorRate:     routerErrorRate,
+		webhookErrorRate:    webhookErrorRate,
+		random:              rand.Float64,
+	}, nil
+}
+
+// ProviderTimeout returns a synthetic metrics provider timeout
+func (i *Injector) ProviderTimeout() error {
+	if i == nil || !i.inject(i.providerTimeoutRate) {
+		return nil
+	}
+	return fmt.Errorf("request failed: %w: context deadline exceeded", ErrInjected)
+}
+
+// RouterError returns a synthetic router write error
+func (i *Injector) RouterError() error {
+	if i == nil || !i.inject(i.routerErrorRate) {
+		return nil
+	}
+	return fmt.Errorf("router update error: %w: the server is currently unable to handle the request", ErrInjected)
+}
+
+// WebhookError returns a synthetic webhook error
+func (i *Injector) WebhookError() error {
+	if i == nil || !i.inject(i.webhookErrorRate) {
+		return nil
+	}
+	return fmt.Errorf("%w: 500 Internal Server Error", ErrInjected)
+}
+
+// String returns the configured rates
+func (i *Injector) String() string {
+	if i == nil {
+		return "disabled"
+	}
+	return fmt.Sprintf("provider timeout rate %v, router error rate %v, webhook error rate %v",
+		i.providerTimeoutRate, i.routerErrorRate, i.webhookErrorRate)
+}
+
+func (i *Injector) inject(rate float64) bool {
+	return rate > 0 && i.random() < rate
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+)
+
+type fakeProvider struct{}
+
+func (p *fakeProvider) RunQuery(query string) (float64, error) { return 1, nil }
+func (p *fakeProvider) IsOnline() (bool, error)                { return true, nil }
+
+type fakeRangeProvider struct{ fakeProvider }
+
+func (p *fakeRangeProvider) RunRangeQuery(query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	return []float64{1}, nil
+}
+
+type fakeRouter struct{ calls int }
+
+func (r *fakeRouter) Reconcile(canary *flaggerv1.Canary) error { r.calls++; return nil }
+func (r *fakeRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int, mirrored bool) error {
+	r.calls++
+	return nil
+}
+func (r *fakeRouter) GetRoutes(canary *flaggerv1.Canary) (int, int, bool, error) {
+	return 100, 0, false, nil
+}
+func (r *fakeRouter) Finalize(canary *flaggerv1.Canary) error { r.calls++; return nil }
+
+func TestNewInjector(t *testing.T) {
+	i, err := NewInjector(0, 0, 0)
+	require.NoError(t, err)
+	assert.Nil(t, i)
+
+	_, err = NewInjector(1.5, 0, 0)
+	assert.Error(t, err)
+
+	_, err = NewInjector(0, -0.1, 0)
+	assert.Error(t, err)
+
+	i, err = NewInjector(0.1, 0.2, 0.3)
+	require.NoError(t, err)
+	require.NotNil(t, i)
+}
+
+func TestInjector_Nil(t *testing.T) {
+	var i *Injector
+	assert.NoError(t, i.ProviderTimeout())
+	assert.NoError(t, i.RouterError())
+	assert.NoError(t, i.WebhookError())
+
+	p := &fakeProvider{}
+	assert.Same(t, p, i.Provider(p))
+
+	r := &fakeRouter{}
+	assert.Same(t, r, i.MeshRouter(r))
+}
+
+func TestInjector_Rates(t *testing.T) {
+	i, err := NewInjector(0.5, 0, 1)
+	require.NoError(t, err)
+
+	i.random = func() float64 { return 0.4 }
+	assert.True(t, errors.Is(i.ProviderTimeout(), ErrInjected))
+	assert.NoError(t, i.RouterError())
+	assert.True(t, errors.Is(i.WebhookError(), ErrInjected))
+
+	i.random = func() float64 { return 0.6 }
+	assert.NoError(t, i.ProviderTimeout())
+	assert.True(t, errors.Is(i.WebhookError(), ErrInjected))
+}
+
+func TestInjector_Provider(t *testing.T) {
+	i, err := NewInjector(0.5, 0, 0)
+	require.NoError(t, err)
+
+	p := i.Provider(&fakeProvider{})
+	_, ok := p.(providers.RangeInterface)
+	assert.False(t, ok)
+
+	rp := i.Provider(&fakeRangeProvider{})
+	_, ok = rp.(providers.RangeInterface)
+	require.True(t, ok)
+
+	i.random = func() float64 { return 0.4 }
+	_, err = p.RunQuery("up")
+	assert.True(t, errors.Is(err, ErrInjected))
+	_, err = p.IsOnline()
+	assert.True(t, errors.Is(err, ErrInjected))
+	_, err = rp.(providers.RangeInterface).RunRangeQuery("up", time.Now(), time.Now(), time.Second)
+	assert.True(t, errors.Is(err, ErrInjected))
+
+	i.random = func() float64 { return 0.6 }
+	val, err := p.RunQuery("up")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), val)
+}
+
+func TestInjector_MeshRouter(t *testing.T) {
+	i, err := NewInjector(0, 0.5, 0)
+	require.NoError(t, err)
+
+	r := &fakeRouter{}
+	mr := i.MeshRouter(r)
+	canary := &flaggerv1.Canary{}
+
+	i.random = func() float64 { return 0.4 }
+	assert.True(t, errors.Is(mr.Reconcile(canary), ErrInjected))
+	assert.True(t, errors.Is(mr.SetRoutes(canary, 90, 10, false), ErrInjected))
+	assert.True(t, errors.Is(mr.Finalize(canary), ErrInjected))
+	_, _, _, err = mr.GetRoutes(canary)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, r.calls)
+
+	i.random = func() float64 { return 0.6 }
+	assert.NoError(t, mr.Reconcile(canary))
+	assert.NoError(t, mr.SetRoutes(canary, 90, 10, false))
+	assert.NoError(t, mr.Finalize(canary))
+	assert.Equal(t, 3, r.calls)
+}
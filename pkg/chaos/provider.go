@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"time"
+
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+)
+
+// Provider wraps the metrics provider to inject timeouts
+func (i *Injector) Provider(p providers.Interface) providers.Interface {
+	if i == nil || i.providerTimeoutRate == 0 {
+		return p
+	}
+	if rp, ok := p.(providers.RangeInterface); ok {
+		return &rangeProvider{provider: provider{Interface: p, injector: i}, rangeInterface: rp}
+	}
+	return &provider{Interface: p, injector: i}
+}
+
+type provider struct {
+	providers.Interface
+	injector *Injector
+}
+
+func (p *provider) RunQuery(query string) (float64, error) {
+	if err := p.injector.ProviderTimeout(); err != nil {
+		return 0, err
+	}
+	return p.Interface.RunQuery(query)
+}
+
+func (p *provider) IsOnline() (bool, error) {
+	if err := p.injector.ProviderTimeout(); err != nil {
+		return false, err
+	}
+	return p.Interface.IsOnline()
+}
+
+type rangeProvider struct {
+	provider
+	rangeInterface providers.RangeInterface
+}
+
+func (p *rangeProvider) RunRangeQuery(query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	if err := p.injector.ProviderTimeout(); err != nil {
+		return nil, err
+	}
+	return p.rangeInterface.RunRangeQuery(query, start, end, step)
+}
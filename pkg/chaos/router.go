@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// MeshRouter wraps the mesh router to inject write errors
+func (i *Injector) MeshRouter(r router.Interface) router.Interface {
+	if i == nil || i.routerErrorRate == 0 {
+		return r
+	}
+	return &meshRouter{Interface: r, injector: i}
+}
+
+// KubernetesRouter wraps the Kubernetes router to inject write errors
+func (i *Injector) KubernetesRouter(r router.KubernetesRouter) router.KubernetesRouter {
+	if i == nil || i.routerErrorRate == 0 {
+		return r
+	}
+	return &kubernetesRouter{KubernetesRouter: r, injector: i}
+}
+
+type meshRouter struct {
+	router.Interface
+	injector *Injector
+}
+
+func (r *meshRouter) Reconcile(canary *flaggerv1.Canary) error {
+	if err := r.injector.RouterError(); err != nil {
+		return err
+	}
+	return r.Interface.Reconcile(canary)
+}
+
+func (r *meshRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int, mirrored bool) error {
+	if err := r.injector.RouterError(); err != nil {
+		return err
+	}
+	return r.Interface.SetRoutes(canary, primaryWeight, canaryWeight, mirrored)
+}
+
+func (r *meshRouter) Finalize(canary *flaggerv1.Canary) error {
+	if err := r.injector.RouterError(); err != nil {
+		return err
+	}
+	return r.Interface.Finalize(canary)
+}
+
+type kubernetesRouter struct {
+	router.KubernetesRouter
+	injector *Injector
+}
+
+func (r *kubernetesRouter) Initialize(canary *flaggerv1.Canary) error {
+	if err := r.injector.RouterError(); err != nil {
+		return err
+	}
+	return r.KubernetesRouter.Initialize(canary)
+}
+
+func (r *kubernetesRouter) Reconcile(canary *flaggerv1.Canary) error {
+	if err := r.injector.RouterError(); err != nil {
+		return err
+	}
+	return r.KubernetesRouter.Reconcile(canary)
+}
+
+func (r *kubernetesRouter) Finalize(canary *flaggerv1.Canary) error {
+	if err := r.injector.RouterError(); err != nil {
+		return err
+	}
+	return r.KubernetesRouter.Finalize(canary)
+}
@@ -34,6 +34,7 @@ import (
 	splitv1alpha1 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha1"
 	splitv1alpha2 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha2"
 	splitv1alpha3 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha3"
+	telemetryv1alpha1 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/telemetry/v1alpha1"
 	traefikv1alpha1 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/traefik/v1alpha1"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
@@ -54,6 +55,7 @@ type Interface interface {
 	SplitV1alpha1() splitv1alpha1.SplitV1alpha1Interface
 	SplitV1alpha2() splitv1alpha2.SplitV1alpha2Interface
 	SplitV1alpha3() splitv1alpha3.SplitV1alpha3Interface
+	TelemetryV1alpha1() telemetryv1alpha1.TelemetryV1alpha1Interface
 	TraefikV1alpha1() traefikv1alpha1.TraefikV1alpha1Interface
 }
 
@@ -73,6 +75,7 @@ type Clientset struct {
 	splitV1alpha1      *splitv1alpha1.SplitV1alpha1Client
 	splitV1alpha2      *splitv1alpha2.SplitV1alpha2Client
 	splitV1alpha3      *splitv1alpha3.SplitV1alpha3Client
+	telemetryV1alpha1  *telemetryv1alpha1.TelemetryV1alpha1Client
 	traefikV1alpha1    *traefikv1alpha1.TraefikV1alpha1Client
 }
 
@@ -136,6 +139,11 @@ func (c *Clientset) SplitV1alpha3() splitv1alpha3.SplitV1alpha3Interface {
 	return c.splitV1alpha3
 }
 
+// TelemetryV1alpha1 retrieves the TelemetryV1alpha1Client
+func (c *Clientset) TelemetryV1alpha1() telemetryv1alpha1.TelemetryV1alpha1Interface {
+	return c.telemetryV1alpha1
+}
+
 // TraefikV1alpha1 retrieves the TraefikV1alpha1Client
 func (c *Clientset) TraefikV1alpha1() traefikv1alpha1.TraefikV1alpha1Interface {
 	return c.traefikV1alpha1
@@ -229,6 +237,10 @@ func NewForConfigAndClient(c *rest.Config, httpClient *http.Client) (*Clientset,
 	if err != nil {
 		return nil, err
 	}
+	cs.telemetryV1alpha1, err = telemetryv1alpha1.NewForConfigAndClient(&configShallowCopy, httpClient)
+	if err != nil {
+		return nil, err
+	}
 	cs.traefikV1alpha1, err = traefikv1alpha1.NewForConfigAndClient(&configShallowCopy, httpClient)
 	if err != nil {
 		return nil, err
@@ -266,6 +278,7 @@ func New(c rest.Interface) *Clientset {
 	cs.splitV1alpha1 = splitv1alpha1.New(c)
 	cs.splitV1alpha2 = splitv1alpha2.New(c)
 	cs.splitV1alpha3 = splitv1alpha3.New(c)
+	cs.telemetryV1alpha1 = telemetryv1alpha1.New(c)
 	cs.traefikV1alpha1 = traefikv1alpha1.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
@@ -44,6 +44,8 @@ import (
 	fakesplitv1alpha2 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha2/fake"
 	splitv1alpha3 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha3"
 	fakesplitv1alpha3 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha3/fake"
+	telemetryv1alpha1 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/telemetry/v1alpha1"
+	faketelemetryv1alpha1 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/telemetry/v1alpha1/fake"
 	traefikv1alpha1 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/traefik/v1alpha1"
 	faketraefikv1alpha1 "github.com/fluxcd/flagger/pkg/client/clientset/versioned/typed/traefik/v1alpha1/fake"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -163,6 +165,11 @@ func (c *Clientset) SplitV1alpha3() splitv1alpha3.SplitV1alpha3Interface {
 	return &fakesplitv1alpha3.FakeSplitV1alpha3{Fake: &c.Fake}
 }
 
+// TelemetryV1alpha1 retrieves the TelemetryV1alpha1Client
+func (c *Clientset) TelemetryV1alpha1() telemetryv1alpha1.TelemetryV1alpha1Interface {
+	return &faketelemetryv1alpha1.FakeTelemetryV1alpha1{Fake: &c.Fake}
+}
+
 // TraefikV1alpha1 retrieves the TraefikV1alpha1Client
 func (c *Clientset) TraefikV1alpha1() traefikv1alpha1.TraefikV1alpha1Interface {
 	return &faketraefikv1alpha1.FakeTraefikV1alpha1{Fake: &c.Fake}
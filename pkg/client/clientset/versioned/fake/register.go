@@ -31,6 +31,7 @@ import (
 	splitv1alpha1 "github.com/fluxcd/flagger/pkg/apis/smi/v1alpha1"
 	splitv1alpha2 "github.com/fluxcd/flagger/pkg/apis/smi/v1alpha2"
 	splitv1alpha3 "github.com/fluxcd/flagger/pkg/apis/smi/v1alpha3"
+	telemetryv1alpha1 "github.com/fluxcd/flagger/pkg/apis/telemetry/v1alpha1"
 	traefikv1alpha1 "github.com/fluxcd/flagger/pkg/apis/traefik/v1alpha1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
@@ -55,6 +56,7 @@ var localSchemeBuilder = runtime.SchemeBuilder{
 	splitv1alpha1.AddToScheme,
 	splitv1alpha2.AddToScheme,
 	splitv1alpha3.AddToScheme,
+	telemetryv1alpha1.AddToScheme,
 	traefikv1alpha1.AddToScheme,
 }
 
@@ -32,14 +32,26 @@ func (c *FakeFlaggerV1beta1) AlertProviders(namespace string) v1beta1.AlertProvi
 	return &FakeAlertProviders{c, namespace}
 }
 
+func (c *FakeFlaggerV1beta1) AnalysisTemplates(namespace string) v1beta1.AnalysisTemplateInterface {
+	return &FakeAnalysisTemplates{c, namespace}
+}
+
 func (c *FakeFlaggerV1beta1) Canaries(namespace string) v1beta1.CanaryInterface {
 	return &FakeCanaries{c, namespace}
 }
 
+func (c *FakeFlaggerV1beta1) CanaryRuns(namespace string) v1beta1.CanaryRunInterface {
+	return &FakeCanaryRuns{c, namespace}
+}
+
 func (c *FakeFlaggerV1beta1) MetricTemplates(namespace string) v1beta1.MetricTemplateInterface {
 	return &FakeMetricTemplates{c, namespace}
 }
 
+func (c *FakeFlaggerV1beta1) ReleaseGroups(namespace string) v1beta1.ReleaseGroupInterface {
+	return &FakeReleaseGroups{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeFlaggerV1beta1) RESTClient() rest.Interface {
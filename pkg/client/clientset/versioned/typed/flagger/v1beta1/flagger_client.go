@@ -29,8 +29,11 @@ import (
 type FlaggerV1beta1Interface interface {
 	RESTClient() rest.Interface
 	AlertProvidersGetter
+	AnalysisTemplatesGetter
 	CanariesGetter
+	CanaryRunsGetter
 	MetricTemplatesGetter
+	ReleaseGroupsGetter
 }
 
 // FlaggerV1beta1Client is used to interact with features provided by the flagger.app group.
@@ -42,14 +45,26 @@ func (c *FlaggerV1beta1Client) AlertProviders(namespace string) AlertProviderInt
 	return newAlertProviders(c, namespace)
 }
 
+func (c *FlaggerV1beta1Client) AnalysisTemplates(namespace string) AnalysisTemplateInterface {
+	return newAnalysisTemplates(c, namespace)
+}
+
 func (c *FlaggerV1beta1Client) Canaries(namespace string) CanaryInterface {
 	return newCanaries(c, namespace)
 }
 
+func (c *FlaggerV1beta1Client) CanaryRuns(namespace string) CanaryRunInterface {
+	return newCanaryRuns(c, namespace)
+}
+
 func (c *FlaggerV1beta1Client) MetricTemplates(namespace string) MetricTemplateInterface {
 	return newMetricTemplates(c, namespace)
 }
 
+func (c *FlaggerV1beta1Client) ReleaseGroups(namespace string) ReleaseGroupInterface {
+	return newReleaseGroups(c, namespace)
+}
+
 // NewForConfig creates a new FlaggerV1beta1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
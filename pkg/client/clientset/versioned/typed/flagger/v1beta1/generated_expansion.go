@@ -20,6 +20,12 @@ package v1beta1
 
 type AlertProviderExpansion interface{}
 
+type AnalysisTemplateExpansion interface{}
+
 type CanaryExpansion interface{}
 
+type CanaryRunExpansion interface{}
+
 type MetricTemplateExpansion interface{}
+
+type ReleaseGroupExpansion interface{}
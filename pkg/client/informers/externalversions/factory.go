@@ -34,6 +34,7 @@ import (
 	kuma "github.com/fluxcd/flagger/pkg/client/informers/externalversions/kuma"
 	projectcontour "github.com/fluxcd/flagger/pkg/client/informers/externalversions/projectcontour"
 	smi "github.com/fluxcd/flagger/pkg/client/informers/externalversions/smi"
+	telemetry "github.com/fluxcd/flagger/pkg/client/informers/externalversions/telemetry"
 	traefik "github.com/fluxcd/flagger/pkg/client/informers/externalversions/traefik"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
@@ -190,6 +191,7 @@ type SharedInformerFactory interface {
 	Kuma() kuma.Interface
 	Projectcontour() projectcontour.Interface
 	Split() smi.Interface
+	Telemetry() telemetry.Interface
 	Traefik() traefik.Interface
 }
 
@@ -229,6 +231,10 @@ func (f *sharedInformerFactory) Split() smi.Interface {
 	return smi.New(f, f.namespace, f.tweakListOptions)
 }
 
+func (f *sharedInformerFactory) Telemetry() telemetry.Interface {
+	return telemetry.New(f, f.namespace, f.tweakListOptions)
+}
+
 func (f *sharedInformerFactory) Traefik() traefik.Interface {
 	return traefik.New(f, f.namespace, f.tweakListOptions)
 }
@@ -26,10 +26,16 @@ import (
 type Interface interface {
 	// AlertProviders returns a AlertProviderInformer.
 	AlertProviders() AlertProviderInformer
+	// AnalysisTemplates returns a AnalysisTemplateInformer.
+	AnalysisTemplates() AnalysisTemplateInformer
 	// Canaries returns a CanaryInformer.
 	Canaries() CanaryInformer
+	// CanaryRuns returns a CanaryRunInformer.
+	CanaryRuns() CanaryRunInformer
 	// MetricTemplates returns a MetricTemplateInformer.
 	MetricTemplates() MetricTemplateInformer
+	// ReleaseGroups returns a ReleaseGroupInformer.
+	ReleaseGroups() ReleaseGroupInformer
 }
 
 type version struct {
@@ -48,12 +54,27 @@ func (v *version) AlertProviders() AlertProviderInformer {
 	return &alertProviderInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// AnalysisTemplates returns a AnalysisTemplateInformer.
+func (v *version) AnalysisTemplates() AnalysisTemplateInformer {
+	return &analysisTemplateInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Canaries returns a CanaryInformer.
 func (v *version) Canaries() CanaryInformer {
 	return &canaryInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// CanaryRuns returns a CanaryRunInformer.
+func (v *version) CanaryRuns() CanaryRunInformer {
+	return &canaryRunInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // MetricTemplates returns a MetricTemplateInformer.
 func (v *version) MetricTemplates() MetricTemplateInformer {
 	return &metricTemplateInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// ReleaseGroups returns a ReleaseGroupInformer.
+func (v *version) ReleaseGroups() ReleaseGroupInformer {
+	return &releaseGroupInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
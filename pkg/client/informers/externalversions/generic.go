@@ -33,6 +33,7 @@ import (
 	smiv1alpha1 "github.com/fluxcd/flagger/pkg/apis/smi/v1alpha1"
 	smiv1alpha2 "github.com/fluxcd/flagger/pkg/apis/smi/v1alpha2"
 	smiv1alpha3 "github.com/fluxcd/flagger/pkg/apis/smi/v1alpha3"
+	telemetryv1alpha1 "github.com/fluxcd/flagger/pkg/apis/telemetry/v1alpha1"
 	traefikv1alpha1 "github.com/fluxcd/flagger/pkg/apis/traefik/v1alpha1"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
 	cache "k8s.io/client-go/tools/cache"
@@ -83,10 +84,16 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		// Group=flagger.app, Version=v1beta1
 	case flaggerv1beta1.SchemeGroupVersion.WithResource("alertproviders"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Flagger().V1beta1().AlertProviders().Informer()}, nil
+	case flaggerv1beta1.SchemeGroupVersion.WithResource("analysistemplates"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Flagger().V1beta1().AnalysisTemplates().Informer()}, nil
 	case flaggerv1beta1.SchemeGroupVersion.WithResource("canaries"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Flagger().V1beta1().Canaries().Informer()}, nil
+	case flaggerv1beta1.SchemeGroupVersion.WithResource("canaryruns"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Flagger().V1beta1().CanaryRuns().Informer()}, nil
 	case flaggerv1beta1.SchemeGroupVersion.WithResource("metrictemplates"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Flagger().V1beta1().MetricTemplates().Informer()}, nil
+	case flaggerv1beta1.SchemeGroupVersion.WithResource("releasegroups"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Flagger().V1beta1().ReleaseGroups().Informer()}, nil
 
 		// Group=gateway.solo.io, Version=v1
 	case v1.SchemeGroupVersion.WithResource("routetables"):
@@ -126,6 +133,10 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	case smiv1alpha3.SchemeGroupVersion.WithResource("trafficsplits"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Split().V1alpha3().TrafficSplits().Informer()}, nil
 
+		// Group=telemetry.istio.io, Version=v1alpha1
+	case telemetryv1alpha1.SchemeGroupVersion.WithResource("telemetries"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Telemetry().V1alpha1().Telemetries().Informer()}, nil
+
 		// Group=traefik.containo.us, Version=v1alpha1
 	case traefikv1alpha1.SchemeGroupVersion.WithResource("traefikservices"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Traefik().V1alpha1().TraefikServices().Informer()}, nil
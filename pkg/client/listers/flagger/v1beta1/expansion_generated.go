@@ -26,6 +26,14 @@ type AlertProviderListerExpansion interface{}
 // AlertProviderNamespaceLister.
 type AlertProviderNamespaceListerExpansion interface{}
 
+// AnalysisTemplateListerExpansion allows custom methods to be added to
+// AnalysisTemplateLister.
+type AnalysisTemplateListerExpansion interface{}
+
+// AnalysisTemplateNamespaceListerExpansion allows custom methods to be added to
+// AnalysisTemplateNamespaceLister.
+type AnalysisTemplateNamespaceListerExpansion interface{}
+
 // CanaryListerExpansion allows custom methods to be added to
 // CanaryLister.
 type CanaryListerExpansion interface{}
@@ -34,6 +42,14 @@ type CanaryListerExpansion interface{}
 // CanaryNamespaceLister.
 type CanaryNamespaceListerExpansion interface{}
 
+// CanaryRunListerExpansion allows custom methods to be added to
+// CanaryRunLister.
+type CanaryRunListerExpansion interface{}
+
+// CanaryRunNamespaceListerExpansion allows custom methods to be added to
+// CanaryRunNamespaceLister.
+type CanaryRunNamespaceListerExpansion interface{}
+
 // MetricTemplateListerExpansion allows custom methods to be added to
 // MetricTemplateLister.
 type MetricTemplateListerExpansion interface{}
@@ -41,3 +57,11 @@ type MetricTemplateListerExpansion interface{}
 // MetricTemplateNamespaceListerExpansion allows custom methods to be added to
 // MetricTemplateNamespaceLister.
 type MetricTemplateNamespaceListerExpansion interface{}
+
+// ReleaseGroupListerExpansion allows custom methods to be added to
+// ReleaseGroupLister.
+type ReleaseGroupListerExpansion interface{}
+
+// ReleaseGroupNamespaceListerExpansion allows custom methods to be added to
+// ReleaseGroupNamespaceLister.
+type ReleaseGroupNamespaceListerExpansion interface{}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+const (
+	// SpecVersion is the version of the CloudEvents specification of the events
+	SpecVersion = "1.0"
+	// ContentType is the media type of the events sent in structured mode
+	ContentType = "application/cloudevents+json"
+	// DataContentType is the media type of the event data
+	DataContentType = "application/json"
+)
+
+// EventType is the type of a canary lifecycle event
+type EventType string
+
+const (
+	// CanaryInitialized is sent when the primary workload and the routes have been created
+	CanaryInitialized EventType = "app.flagger.canary.initialized"
+	// CanaryWeightChanged is sent when the traffic weight routed to the canary or the primary changes
+	CanaryWeightChanged EventType = "app.flagger.canary.weight.changed"
+	// CanaryPromoted is sent when the canary spec has been promoted to the primary
+	CanaryPromoted EventType = "app.flagger.canary.promoted"
+	// CanaryRolledBack is sent when the traffic has been routed back to the primary after a failed analysis
+	CanaryRolledBack EventType = "app.flagger.canary.rolledback"
+	// CanaryWebhookFailed is sent when a webhook of the analysis returns an error
+	CanaryWebhookFailed EventType = "app.flagger.canary.webhook.failed"
+)
+
+// Event is a CloudEvent in the JSON structured format
+type Event struct {
+	SpecVersion     string     `json:"specversion"`
+	ID              string     `json:"id"`
+	Source          string     `json:"source"`
+	Type            EventType  `json:"type"`
+	Subject         string     `json:"subject"`
+	Time            time.Time  `json:"time"`
+	DataContentType string     `json:"datacontenttype"`
+	Data            CanaryData `json:"data"`
+}
+
+// CanaryData is the payload of the canary lifecycle events
+type CanaryData struct {
+	// Name of the canary
+	Name string `json:"name"`
+
+	// Namespace of the canary
+	Namespace string `json:"namespace"`
+
+	// Cluster is the name of the cluster set with the -cluster-name flag
+	Cluster string `json:"cluster,omitempty"`
+
+	// TargetKind is the kind of the canary target
+	TargetKind string `json:"targetKind"`
+
+	// TargetName is the name of the canary target
+	TargetName string `json:"targetName"`
+
+	// Phase of the canary when the event was sent
+	Phase string `json:"phase"`
+
+	// CanaryWeight is the traffic weight routed to the canary
+	CanaryWeight int `json:"canaryWeight"`
+
+	// PrimaryWeight is the traffic weight routed to the primary
+	PrimaryWeight int `json:"primaryWeight"`
+
+	// Iterations of the current analysis
+	Iterations int `json:"iterations"`
+
+	// Revision is the hash of the canary target spec
+	Revision string `json:"revision,omitempty"`
+
+	// RunID identifies the analysis run
+	RunID string `json:"runID,omitempty"`
+
+	// Reason of the rollback
+	Reason string `json:"reason,omitempty"`
+
+	// Webhook is the name of the failed webhook
+	Webhook string `json:"webhook,omitempty"`
+
+	// Message describes the event
+	Message string `json:"message"`
+}
+
+// NewEvent returns an event of the given type for the canary
+func NewEvent(eventType EventType, data CanaryData) Event {
+	source := "flagger"
+	if data.Cluster != "" {
+		source = fmt.Sprintf("flagger/%s", data.Cluster)
+	}
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              string(uuid.NewUUID()),
+		Source:          source,
+		Type:            eventType,
+		Subject:         fmt.Sprintf("namespaces/%s/canaries/%s", data.Namespace, data.Name),
+		Time:            time.Now().UTC(),
+		DataContentType: DataContentType,
+		Data:            data,
+	}
+}
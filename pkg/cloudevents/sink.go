@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fluxcd/flagger/pkg/logger"
+)
+
+// defaultTimeout is the timeout of the HTTP requests when the sink doesn't set one
+const defaultTimeout = 5 * time.Second
+
+// Send posts the event in structured mode to the HTTP sink,
+// an error is returned unless the sink responds with a 2xx status code
+func Send(sink string, event Event, timeout time.Duration) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshalling event failed: %w", err)
+	}
+
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink, bytes.NewReader(payload))
+	if err != nil {
+		return logger.RedactError(err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// the sink URL can contain tokens in the query
+		return logger.RedactError(err)
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(r.Body, 1024))
+		return fmt.Errorf("sink responded with status code %d: %s", r.StatusCode, string(b))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	var received map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ContentType, r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	event := NewEvent(CanaryPromoted, CanaryData{
+		Name:          "podinfo",
+		Namespace:     "test",
+		Cluster:       "prod",
+		Phase:         "Succeeded",
+		PrimaryWeight: 100,
+	})
+	require.NoError(t, Send(ts.URL, event, 0))
+
+	assert.Equal(t, "1.0", received["specversion"])
+	assert.Equal(t, "app.flagger.canary.promoted", received["type"])
+	assert.Equal(t, "flagger/prod", received["source"])
+	assert.Equal(t, "namespaces/test/canaries/podinfo", received["subject"])
+	assert.NotEmpty(t, received["id"])
+	data := received["data"].(map[string]interface{})
+	assert.Equal(t, "podinfo", data["name"])
+	assert.Equal(t, float64(100), data["primaryWeight"])
+}
+
+func TestSend_StatusCode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	err := Send(ts.URL, NewEvent(CanaryInitialized, CanaryData{Name: "podinfo"}), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "400")
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// resolveAnalysisTemplate returns a copy of the canary with its analysis merged
+// into the referenced analysis template, the canary is returned as is if it
+// doesn't reference a template
+func (c *Controller) resolveAnalysisTemplate(cd *flaggerv1.Canary) (*flaggerv1.Canary, error) {
+	analysis := cd.GetAnalysis()
+	if analysis == nil || analysis.TemplateRef == nil {
+		return cd, nil
+	}
+
+	namespace := cd.Namespace
+	if analysis.TemplateRef.Namespace != "" {
+		namespace = analysis.TemplateRef.Namespace
+	}
+	if c.noCrossNamespaceRefs && namespace != cd.Namespace {
+		return nil, fmt.Errorf("can't access analysis template %s.%s, cross-namespace references are blocked",
+			analysis.TemplateRef.Name, namespace)
+	}
+
+	template, err := c.flaggerClient.FlaggerV1beta1().AnalysisTemplates(namespace).Get(context.TODO(), analysis.TemplateRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("analysis template %s.%s error: %w", analysis.TemplateRef.Name, namespace, err)
+	}
+	if template.Spec.Analysis.TemplateRef != nil {
+		return nil, fmt.Errorf("analysis template %s.%s can't reference another template", template.Name, template.Namespace)
+	}
+
+	out := cd.DeepCopy()
+	out.Spec.Analysis = mergeAnalysis(&template.Spec.Analysis, analysis)
+	out.Spec.CanaryAnalysis = nil
+	return out, nil
+}
+
+// mergeAnalysis overrides the template settings with the ones set in the canary analysis,
+// the alerts, metrics and webhooks are merged by name
+func mergeAnalysis(template *flaggerv1.CanaryAnalysis, local *flaggerv1.CanaryAnalysis) *flaggerv1.CanaryAnalysis {
+	out := template.DeepCopy()
+	local = local.DeepCopy()
+
+	if local.Interval != "" {
+		out.Interval = local.Interval
+	}
+	if local.Iterations > 0 {
+		out.Iterations = local.Iterations
+	}
+	if local.Mirror {
+		out.Mirror = true
+	}
+	if local.MirrorWeight > 0 {
+		out.MirrorWeight = local.MirrorWeight
+	}
+	if local.MirrorWarmupIterations > 0 {
+		out.MirrorWarmupIterations = local.MirrorWarmupIterations
+	}
+	if local.MaxWeight > 0 {
+		out.MaxWeight = local.MaxWeight
+	}
+	if local.StepWeight > 0 {
+		out.StepWeight = local.StepWeight
+	}
+	if len(local.StepWeights) > 0 {
+		out.StepWeights = local.StepWeights
+	}
+	if len(local.StepWeightDurations) > 0 {
+		out.StepWeightDurations = local.StepWeightDurations
+	}
+	if local.Adaptive != nil {
+		out.Adaptive = local.Adaptive
+	}
+	if local.StepWeightPromotion > 0 {
+		out.StepWeightPromotion = local.StepWeightPromotion
+	}
+	if local.Threshold > 0 {
+		out.Threshold = local.Threshold
+	}
+	if local.PrimaryReadyThreshold != nil {
+		out.PrimaryReadyThreshold = local.PrimaryReadyThreshold
+	}
+	if local.CanaryReadyThreshold != nil {
+		out.CanaryReadyThreshold = local.CanaryReadyThreshold
+	}
+	if local.Retries > 0 {
+		out.Retries = local.Retries
+	}
+	if local.RetryInterval != "" {
+		out.RetryInterval = local.RetryInterval
+	}
+	if local.RollbackDrainPeriod != "" {
+		out.RollbackDrainPeriod = local.RollbackDrainPeriod
+	}
+	if local.CanaryScaleDownDelay != "" {
+		out.CanaryScaleDownDelay = local.CanaryScaleDownDelay
+	}
+	if local.RollbackPolicy != "" {
+		out.RollbackPolicy = local.RollbackPolicy
+	}
+	if len(local.TrafficWindows) > 0 {
+		out.TrafficWindows = local.TrafficWindows
+	}
+	if len(local.ProgressionWindows) > 0 {
+		out.ProgressionWindows = local.ProgressionWindows
+	}
+	if local.ProgressionWindowPolicy != "" {
+		out.ProgressionWindowPolicy = local.ProgressionWindowPolicy
+	}
+	if local.MaxDuration != "" {
+		out.MaxDuration = local.MaxDuration
+	}
+	if local.MaxDurationPolicy != "" {
+		out.MaxDurationPolicy = local.MaxDurationPolicy
+	}
+	if local.ManualPromotion {
+		out.ManualPromotion = true
+	}
+	if len(local.DependsOn) > 0 {
+		out.DependsOn = local.DependsOn
+	}
+	if local.Kayenta != nil {
+		out.Kayenta = local.Kayenta
+	}
+	if len(local.Match) > 0 {
+		out.Match = local.Match
+	}
+	if local.ProgressiveAfterMatch {
+		out.ProgressiveAfterMatch = true
+	}
+	if local.SessionAffinity != nil {
+		out.SessionAffinity = local.SessionAffinity
+	}
+	if local.DryRun {
+		out.DryRun = true
+	}
+
+	for _, alert := range local.Alerts {
+		found := false
+		for i := range out.Alerts {
+			if out.Alerts[i].Name == alert.Name {
+				out.Alerts[i] = alert
+				found = true
+			}
+		}
+		if !found {
+			out.Alerts = append(out.Alerts, alert)
+		}
+	}
+
+	for _, metric := range local.Metrics {
+		found := false
+		for i := range out.Metrics {
+			if out.Metrics[i].Name == metric.Name {
+				out.Metrics[i] = metric
+				found = true
+			}
+		}
+		if !found {
+			out.Metrics = append(out.Metrics, metric)
+		}
+	}
+
+	for _, webhook := range local.Webhooks {
+		found := false
+		for i := range out.Webhooks {
+			if out.Webhooks[i].Name == webhook.Name {
+				out.Webhooks[i] = webhook
+				found = true
+			}
+		}
+		if !found {
+			out.Webhooks = append(out.Webhooks, webhook)
+		}
+	}
+
+	out.TemplateRef = local.TemplateRef
+	return out
+}
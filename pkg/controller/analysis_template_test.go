@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	fakeFlagger "github.com/fluxcd/flagger/pkg/client/clientset/versioned/fake"
+)
+
+func newTestAnalysisTemplate() *flaggerv1.AnalysisTemplate {
+	return &flaggerv1.AnalysisTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard", Namespace: "flagger-system"},
+		Spec: flaggerv1.AnalysisTemplateSpec{
+			Analysis: flaggerv1.CanaryAnalysis{
+				Interval:   "1m",
+				Threshold:  5,
+				MaxWeight:  50,
+				StepWeight: 10,
+				Metrics: []flaggerv1.CanaryMetric{
+					{Name: "request-success-rate", Threshold: 99},
+					{Name: "request-duration", Threshold: 500},
+				},
+				Webhooks: []flaggerv1.CanaryWebhook{
+					{Name: "load-test", URL: "http://flagger-loadtester/"},
+				},
+			},
+		},
+	}
+}
+
+func TestController_resolveAnalysisTemplate(t *testing.T) {
+	template := newTestAnalysisTemplate()
+	ctrl := &Controller{flaggerClient: fakeFlagger.NewSimpleClientset(template)}
+
+	cd := newDeploymentTestCanary()
+	cd.Spec.Analysis = &flaggerv1.CanaryAnalysis{
+		TemplateRef: &flaggerv1.CrossNamespaceObjectReference{Name: "standard", Namespace: "flagger-system"},
+		Threshold:   2,
+		Metrics: []flaggerv1.CanaryMetric{
+			{Name: "request-duration", Threshold: 100},
+			{Name: "error-rate", Threshold: 1},
+		},
+	}
+
+	resolved, err := ctrl.resolveAnalysisTemplate(cd)
+	require.NoError(t, err)
+
+	analysis := resolved.GetAnalysis()
+	assert.Equal(t, "1m", analysis.Interval)
+	assert.Equal(t, 2, analysis.Threshold)
+	assert.Equal(t, 50, analysis.MaxWeight)
+	assert.Equal(t, 10, analysis.StepWeight)
+	require.Len(t, analysis.Metrics, 3)
+	assert.Equal(t, float64(99), analysis.Metrics[0].Threshold)
+	assert.Equal(t, float64(100), analysis.Metrics[1].Threshold)
+	assert.Equal(t, "error-rate", analysis.Metrics[2].Name)
+	require.Len(t, analysis.Webhooks, 1)
+
+	// the canary and the template are not modified
+	assert.Equal(t, "", cd.Spec.Analysis.Interval)
+	assert.Len(t, cd.Spec.Analysis.Metrics, 2)
+	assert.Len(t, template.Spec.Analysis.Metrics, 2)
+
+	// the canary is returned as is without a template
+	cd = newDeploymentTestCanary()
+	resolved, err = ctrl.resolveAnalysisTemplate(cd)
+	require.NoError(t, err)
+	assert.Same(t, cd, resolved)
+}
+
+func TestController_resolveAnalysisTemplateErrors(t *testing.T) {
+	ctrl := &Controller{flaggerClient: fakeFlagger.NewSimpleClientset(newTestAnalysisTemplate())}
+
+	cd := newDeploymentTestCanary()
+	cd.Spec.Analysis = &flaggerv1.CanaryAnalysis{
+		TemplateRef: &flaggerv1.CrossNamespaceObjectReference{Name: "missing"},
+	}
+	_, err := ctrl.resolveAnalysisTemplate(cd)
+	assert.Error(t, err)
+
+	cd.Spec.Analysis.TemplateRef = &flaggerv1.CrossNamespaceObjectReference{Name: "standard", Namespace: "flagger-system"}
+	ctrl.noCrossNamespaceRefs = true
+	_, err = ctrl.resolveAnalysisTemplate(cd)
+	assert.Error(t, err)
+}
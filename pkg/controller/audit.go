@@ -0,0 +1,263 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// AuditTrail buffers the decisions made during the analysis runs,
+// they are written to a CanaryRun per run at the end of each analysis iteration
+type AuditTrail struct {
+	maxRuns    int
+	maxRecords int
+
+	mu   sync.Mutex
+	runs map[string]*auditRun
+}
+
+// auditRun holds the records of an analysis run that are not written yet
+type auditRun struct {
+	canary    string
+	namespace string
+	targetRef flaggerv1.LocalObjectReference
+	runID     string
+	revision  string
+	startTime metav1.Time
+	records   []flaggerv1.CanaryRunRecord
+}
+
+// NewAuditTrail creates an audit trail that keeps the last runs of each canary
+// and the last records of each run
+func NewAuditTrail(maxRuns int, maxRecords int) (*AuditTrail, error) {
+	if maxRuns < 1 {
+		return nil, fmt.Errorf("the max number of runs must be greater than zero, got %d", maxRuns)
+	}
+	if maxRecords < 1 {
+		return nil, fmt.Errorf("the max number of records must be greater than zero, got %d", maxRecords)
+	}
+	return &AuditTrail{
+		maxRuns:    maxRuns,
+		maxRecords: maxRecords,
+		runs:       make(map[string]*auditRun),
+	}, nil
+}
+
+func (a *AuditTrail) add(cd *flaggerv1.Canary, record flaggerv1.CanaryRunRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%s/%s", cd.Namespace, cd.Name, cd.Status.RunID)
+	run, ok := a.runs[key]
+	if !ok {
+		run = &auditRun{
+			canary:    cd.Name,
+			namespace: cd.Namespace,
+			targetRef: cd.Spec.TargetRef,
+			runID:     cd.Status.RunID,
+			revision:  cd.Status.LastAppliedSpec,
+			startTime: cd.Status.RunStartTime,
+		}
+		a.runs[key] = run
+	}
+	run.records = append(run.records, record)
+}
+
+// take removes the pending runs of the canary
+func (a *AuditTrail) take(name string, namespace string) []*auditRun {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var runs []*auditRun
+	for key, run := range a.runs {
+		if run.canary == name && run.namespace == namespace {
+			runs = append(runs, run)
+			delete(a.runs, key)
+		}
+	}
+	return runs
+}
+
+// recordAudit adds the decision to the audit trail of the analysis run
+func (c *Controller) recordAudit(cd *flaggerv1.Canary, record flaggerv1.CanaryRunRecord) {
+	if c.auditTrail == nil || cd.Status.RunID == "" {
+		return
+	}
+	if record.Time.IsZero() {
+		record.Time = metav1.Now()
+	}
+	if record.CanaryWeight == 0 && record.Type != flaggerv1.WeightRecord {
+		record.CanaryWeight = cd.Status.CanaryWeight
+	}
+	c.auditTrail.add(cd, record)
+}
+
+func (c *Controller) auditPhase(cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase, message string) {
+	c.recordAudit(cd, flaggerv1.CanaryRunRecord{
+		Type:    flaggerv1.PhaseRecord,
+		Phase:   phase,
+		Message: message,
+	})
+}
+
+func (c *Controller) auditWeight(cd *flaggerv1.Canary, canaryWeight int, message string) {
+	c.recordAudit(cd, flaggerv1.CanaryRunRecord{
+		Type:         flaggerv1.WeightRecord,
+		CanaryWeight: canaryWeight,
+		Message:      message,
+	})
+}
+
+// auditGate records a decision made with an annotation along with the field manager that set it
+func (c *Controller) auditGate(cd *flaggerv1.Canary, annotation string, result flaggerv1.CanaryCheckResult,
+	phase flaggerv1.CanaryPhase, message string) {
+	c.recordAudit(cd, flaggerv1.CanaryRunRecord{
+		Type:    flaggerv1.GateRecord,
+		Name:    annotation,
+		Result:  result,
+		Phase:   phase,
+		Actor:   annotationManager(cd, annotation),
+		Message: message,
+	})
+}
+
+// flushAuditTrail writes the pending records of the canary to the CanaryRun objects
+func (c *Controller) flushAuditTrail(name string, namespace string) {
+	if c.auditTrail == nil {
+		return
+	}
+	for _, run := range c.auditTrail.take(name, namespace) {
+		if err := c.writeCanaryRun(run); err != nil {
+			c.logger.With("canary", fmt.Sprintf("%s.%s", name, namespace)).
+				Errorf("Writing the audit trail of run %s failed: %v", run.runID, err)
+		}
+	}
+}
+
+// writeCanaryRun appends the records to the CanaryRun of the analysis run,
+// the oldest runs of the canary are deleted when a run is created
+func (c *Controller) writeCanaryRun(run *auditRun) error {
+	runs := c.flaggerClient.FlaggerV1beta1().CanaryRuns(run.namespace)
+	runName := fmt.Sprintf("%s-%s", run.canary, run.runID)
+
+	created := false
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		cr, err := runs.Get(context.TODO(), runName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			cr, err = runs.Create(context.TODO(), &flaggerv1.CanaryRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      runName,
+					Namespace: run.namespace,
+					Labels:    map[string]string{flaggerv1.CanaryRunLabel: run.canary},
+				},
+				Spec: flaggerv1.CanaryRunSpec{
+					CanaryName: run.canary,
+					TargetRef:  run.targetRef,
+					RunID:      run.runID,
+					Revision:   run.revision,
+				},
+			}, metav1.CreateOptions{FieldManager: "flagger"})
+			created = created || err == nil
+		}
+		if err != nil {
+			return err
+		}
+
+		crCopy := cr.DeepCopy()
+		crCopy.Status.StartTime = run.startTime
+		crCopy.Status.Records = append(crCopy.Status.Records, run.records...)
+		for _, r := range run.records {
+			if r.Type != flaggerv1.PhaseRecord {
+				continue
+			}
+			crCopy.Status.Phase = r.Phase
+			if r.Phase == flaggerv1.CanaryPhaseSucceeded || r.Phase == flaggerv1.CanaryPhaseFailed {
+				crCopy.Status.CompletionTime = r.Time
+			}
+		}
+		if dropped := len(crCopy.Status.Records) - c.auditTrail.maxRecords; dropped > 0 {
+			crCopy.Status.Records = crCopy.Status.Records[dropped:]
+			crCopy.Status.DroppedRecords += dropped
+		}
+		_, err = runs.UpdateStatus(context.TODO(), crCopy, metav1.UpdateOptions{FieldManager: "flagger"})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("canary run %s.%s update failed: %w", runName, run.namespace, err)
+	}
+
+	if created {
+		return c.pruneCanaryRuns(run.canary, run.namespace)
+	}
+	return nil
+}
+
+// pruneCanaryRuns deletes the oldest runs of the canary above the max number of runs
+func (c *Controller) pruneCanaryRuns(name string, namespace string) error {
+	selector := labels.SelectorFromSet(labels.Set{flaggerv1.CanaryRunLabel: name}).String()
+	list, err := c.flaggerClient.FlaggerV1beta1().CanaryRuns(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("canary runs list query failed: %w", err)
+	}
+	if len(list.Items) <= c.auditTrail.maxRuns {
+		return nil
+	}
+
+	items := list.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Status.StartTime.Before(&items[j].Status.StartTime)
+	})
+	for _, cr := range items[:len(items)-c.auditTrail.maxRuns] {
+		err := c.flaggerClient.FlaggerV1beta1().CanaryRuns(namespace).Delete(context.TODO(), cr.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("canary run %s.%s delete failed: %w", cr.Name, namespace, err)
+		}
+	}
+	return nil
+}
+
+// annotationManager returns the field manager that set the annotation of the canary
+func annotationManager(cd *flaggerv1.Canary, annotation string) string {
+	for _, mf := range cd.ManagedFields {
+		if mf.FieldsV1 == nil {
+			continue
+		}
+		var fields struct {
+			Metadata struct {
+				Annotations map[string]json.RawMessage `json:"f:annotations"`
+			} `json:"f:metadata"`
+		}
+		if err := json.Unmarshal(mf.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields.Metadata.Annotations["f:"+annotation]; ok {
+			return mf.Manager
+		}
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestController_AuditTrail(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	auditTrail, err := NewAuditTrail(2, 100)
+	require.NoError(t, err)
+	mocks.ctrl.auditTrail = auditTrail
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	// advance
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// the rollback is requested with the CLI
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Annotations = map[string]string{flaggerv1.RollbackAnnotation: "true"}
+	cd.ManagedFields = []metav1.ManagedFieldsEntry{{
+		Manager:  "kubectl-flagger",
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:flagger.app/rollback":{}}}}`)},
+	}}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseFailed))
+
+	runs, err := mocks.flaggerClient.FlaggerV1beta1().CanaryRuns("default").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, runs.Items, 1)
+
+	run := runs.Items[0]
+	assert.Equal(t, fmt.Sprintf("podinfo-%s", cd.Status.RunID), run.Name)
+	assert.Equal(t, "podinfo", run.Labels[flaggerv1.CanaryRunLabel])
+	assert.Equal(t, cd.Status.LastAppliedSpec, run.Spec.Revision)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, run.Status.Phase)
+	assert.False(t, run.Status.CompletionTime.IsZero())
+
+	types := make([]flaggerv1.CanaryRunRecordType, 0, len(run.Status.Records))
+	for _, r := range run.Status.Records {
+		types = append(types, r.Type)
+		if r.Type == flaggerv1.GateRecord {
+			assert.Equal(t, flaggerv1.RollbackAnnotation, r.Name)
+			assert.Equal(t, "kubectl-flagger", r.Actor)
+		}
+	}
+	assert.Equal(t, []flaggerv1.CanaryRunRecordType{
+		flaggerv1.WeightRecord,
+		flaggerv1.GateRecord,
+		flaggerv1.PhaseRecord,
+	}, types)
+}
+
+func TestController_writeCanaryRun(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	auditTrail, err := NewAuditTrail(2, 3)
+	require.NoError(t, err)
+	mocks.ctrl.auditTrail = auditTrail
+
+	cd := newDeploymentTestCanary()
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		cd.Status.RunID = fmt.Sprintf("run-%d", i)
+		cd.Status.RunStartTime = metav1.NewTime(start.Add(time.Duration(i) * time.Minute))
+		for w := 10; w <= 50; w += 10 {
+			mocks.ctrl.auditWeight(cd, w, fmt.Sprintf("Advance canary weight %v", w))
+		}
+		mocks.ctrl.flushAuditTrail(cd.Name, cd.Namespace)
+	}
+
+	// the oldest run is deleted
+	runs, err := mocks.flaggerClient.FlaggerV1beta1().CanaryRuns("default").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, runs.Items, 2)
+	for _, run := range runs.Items {
+		assert.NotEqual(t, "run-0", run.Spec.RunID)
+
+		// the oldest records are dropped
+		require.Len(t, run.Status.Records, 3)
+		assert.Equal(t, 2, run.Status.DroppedRecords)
+		assert.Equal(t, 30, run.Status.Records[0].CanaryWeight)
+	}
+}
+
+func TestNewAuditTrail(t *testing.T) {
+	_, err := NewAuditTrail(0, 10)
+	assert.Error(t, err)
+	_, err = NewAuditTrail(10, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/cloudevents"
+)
+
+// publishCloudEvent sends the lifecycle event to the cloudevents webhooks of the canary,
+// the sink set with the -cloudevents-sink flag is used when the canary doesn't have one
+func (c *Controller) publishCloudEvent(cd *flaggerv1.Canary, eventType cloudevents.EventType, data cloudevents.CanaryData) {
+	var sinks []flaggerv1.CanaryWebhook
+	for _, w := range cd.GetAnalysis().Webhooks {
+		if w.Type == flaggerv1.CloudEventsHook {
+			sinks = append(sinks, w)
+		}
+	}
+	if len(sinks) == 0 && c.cloudEventsSink != "" {
+		sinks = append(sinks, flaggerv1.CanaryWebhook{Name: "cloudevents", URL: c.cloudEventsSink})
+	}
+	if len(sinks) == 0 {
+		return
+	}
+
+	data.Name, data.Namespace, data.Cluster = cd.Name, cd.Namespace, c.clusterName
+	data.TargetKind, data.TargetName = cd.Spec.TargetRef.Kind, cd.Spec.TargetRef.Name
+	if data.Phase == "" {
+		data.Phase = string(cd.Status.Phase)
+	}
+	data.Iterations, data.Revision, data.RunID = cd.Status.Iterations, cd.Status.LastAppliedSpec, cd.Status.RunID
+	event := cloudevents.NewEvent(eventType, data)
+
+	for _, sink := range sinks {
+		timeout, _ := time.ParseDuration(sink.Timeout)
+		if err := cloudevents.Send(sink.URL, event, timeout); err != nil {
+			c.canaryLogger(cd).Errorf("error sending %s event to %s: %v", eventType, sink.Name, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// remoteCluster holds the factories built from the kubeconfig referenced by a canary
+type remoteCluster struct {
+	checksum      string
+	canaryFactory *canary.Factory
+	routerFactory *router.Factory
+}
+
+// getClusterFactories returns the factories managing the target workload and the routing objects of the canary,
+// the factories of a remote cluster are cached per canary and rebuilt when the kubeconfig changes.
+// The secret is read from the informer cache and the kubeconfig users can only authenticate
+// with an inline token or client certificate
+func (c *Controller) getClusterFactories(cd *flaggerv1.Canary) (*canary.Factory, *router.Factory, error) {
+	ref := cd.Spec.KubeConfig
+	if ref == nil {
+		return c.canaryFactory, c.routerFactory, nil
+	}
+
+	secret, err := c.flaggerInformers.SecretInformer.Lister().Secrets(cd.Namespace).Get(ref.SecretRef.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kubeconfig secret %s.%s query error: %w", ref.SecretRef.Name, cd.Namespace, err)
+	}
+	data, ok := secret.Data[ref.GetKey()]
+	if !ok {
+		return nil, nil, fmt.Errorf("kubeconfig secret %s.%s has no %s key", ref.SecretRef.Name, cd.Namespace, ref.GetKey())
+	}
+
+	key := fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)
+	checksum := fmt.Sprintf("%x", sha256.Sum256(data))
+	if value, ok := c.clusters.Load(key); ok {
+		if cluster := value.(*remoteCluster); cluster.checksum == checksum {
+			return cluster.canaryFactory, cluster.routerFactory, nil
+		}
+	}
+
+	kubeConfig, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kubeconfig secret %s.%s is invalid: %w", ref.SecretRef.Name, cd.Namespace, err)
+	}
+	if err := validateKubeConfigAuth(kubeConfig); err != nil {
+		return nil, nil, fmt.Errorf("kubeconfig secret %s.%s is invalid: %w", ref.SecretRef.Name, cd.Namespace, err)
+	}
+	cfg, err := clientcmd.NewDefaultClientConfig(*kubeConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("kubeconfig secret %s.%s is invalid: %w", ref.SecretRef.Name, cd.Namespace, err)
+	}
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error building the kubernetes client for %s: %w", cfg.Host, err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error building the dynamic client for %s: %w", cfg.Host, err)
+	}
+	meshClient, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error building the mesh client for %s: %w", cfg.Host, err)
+	}
+
+	cluster := &remoteCluster{
+		checksum:      checksum,
+		canaryFactory: c.canaryFactory.ForCluster(kubeClient, dynamicClient),
+		routerFactory: c.routerFactory.ForCluster(cfg, kubeClient, meshClient),
+	}
+	c.clusters.Store(key, cluster)
+	c.canaryLogger(cd).Infof("Managing %s.%s in the cluster %s", cd.Spec.TargetRef.Name, cd.Namespace, cfg.Host)
+	return cluster.canaryFactory, cluster.routerFactory, nil
+}
+
+// validateKubeConfigAuth rejects the users that run a command, use an auth provider plugin
+// or read the credentials from the files of the Flagger container,
+// only the inline tokens and client certificates are allowed
+func validateKubeConfigAuth(kubeConfig *clientcmdapi.Config) error {
+	for name, user := range kubeConfig.AuthInfos {
+		switch {
+		case user.Exec != nil:
+			return fmt.Errorf("user %s uses exec, only token or client certificate auth is allowed", name)
+		case user.AuthProvider != nil:
+			return fmt.Errorf("user %s uses an auth provider, only token or client certificate auth is allowed", name)
+		case user.TokenFile != "" || user.ClientCertificate != "" || user.ClientKey != "":
+			return fmt.Errorf("user %s reads the credentials from files, only inline credentials are allowed", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func newTestKubeConfig(server string) []byte {
+	return newTestKubeConfigWithUser(server, `
+    token: test
+`)
+}
+
+func newTestKubeConfigWithUser(server string, user string) []byte {
+	return []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: workload
+  cluster:
+    server: ` + server + `
+contexts:
+- name: workload
+  context:
+    cluster: workload
+    user: flagger
+current-context: workload
+users:
+- name: flagger
+  user:` + user)
+}
+
+func TestController_getClusterFactories(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	cd := mocks.canary.DeepCopy()
+
+	// the local cluster is used without a kubeconfig
+	canaryFactory, routerFactory, err := mocks.ctrl.getClusterFactories(cd)
+	require.NoError(t, err)
+	assert.Same(t, mocks.ctrl.canaryFactory, canaryFactory)
+	assert.Same(t, mocks.ctrl.routerFactory, routerFactory)
+
+	cd.Spec.KubeConfig = &flaggerv1.KubeConfigReference{
+		SecretRef: corev1.LocalObjectReference{Name: "workload-kubeconfig"},
+	}
+	_, _, err = mocks.ctrl.getClusterFactories(cd)
+	require.Error(t, err)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload-kubeconfig", Namespace: "default"},
+		Data:       map[string][]byte{"value": newTestKubeConfig("https://workload:6443")},
+	}
+	secret, err = mocks.kubeClient.CoreV1().Secrets("default").Create(context.TODO(), secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+
+	// the kubeconfig key defaults to kubeconfig
+	_, _, err = mocks.ctrl.getClusterFactories(cd)
+	require.Error(t, err)
+
+	cd.Spec.KubeConfig.Key = "value"
+	canaryFactory, routerFactory, err = mocks.ctrl.getClusterFactories(cd)
+	require.NoError(t, err)
+	assert.NotSame(t, mocks.ctrl.canaryFactory, canaryFactory)
+	assert.NotSame(t, mocks.ctrl.routerFactory, routerFactory)
+
+	// the factories are cached until the kubeconfig changes
+	cachedCanaryFactory, cachedRouterFactory, err := mocks.ctrl.getClusterFactories(cd)
+	require.NoError(t, err)
+	assert.Same(t, canaryFactory, cachedCanaryFactory)
+	assert.Same(t, routerFactory, cachedRouterFactory)
+
+	secret.Data["value"] = newTestKubeConfig("https://workload-2:6443")
+	_, err = mocks.kubeClient.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	canaryFactory, _, err = mocks.ctrl.getClusterFactories(cd)
+	require.NoError(t, err)
+	assert.NotSame(t, cachedCanaryFactory, canaryFactory)
+
+	secret.Data["value"] = []byte("invalid")
+	_, err = mocks.kubeClient.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	_, _, err = mocks.ctrl.getClusterFactories(cd)
+	require.Error(t, err)
+
+	// the users can only authenticate with inline credentials
+	for _, user := range []string{`
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+`, `
+    auth-provider:
+      name: gcp
+`, `
+    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token
+`} {
+		secret.Data["value"] = newTestKubeConfigWithUser("https://workload:6443", user)
+		_, err = mocks.kubeClient.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+		require.NoError(t, err)
+		mocks.syncInformers(t)
+		_, _, err = mocks.ctrl.getClusterFactories(cd)
+		require.Error(t, err)
+	}
+}
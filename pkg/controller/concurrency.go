@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Concurrency limits the number of canaries analysed in parallel
+// and the rate at which the canaries are reconciled by the workqueue
+type Concurrency struct {
+	slots     chan struct{}
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	qps       float64
+	burst     int
+}
+
+// NewConcurrency returns the limits for the given number of parallel analysis runs,
+// zero means unlimited, the workqueue retries a canary with an exponential delay
+// between the base and max delay and processes at most qps canaries per second
+func NewConcurrency(maxAnalysis int, baseDelay time.Duration, maxDelay time.Duration, qps float64, burst int) (*Concurrency, error) {
+	if maxAnalysis < 0 {
+		return nil, fmt.Errorf("max concurrent analysis %d must be positive", maxAnalysis)
+	}
+	if baseDelay <= 0 || maxDelay < baseDelay {
+		return nil, fmt.Errorf("workqueue max delay %v must be greater than the base delay %v", maxDelay, baseDelay)
+	}
+	if qps <= 0 || burst < 1 {
+		return nil, fmt.Errorf("workqueue qps %v and burst %d must be positive", qps, burst)
+	}
+
+	c := &Concurrency{baseDelay: baseDelay, maxDelay: maxDelay, qps: qps, burst: burst}
+	if maxAnalysis > 0 {
+		c.slots = make(chan struct{}, maxAnalysis)
+	}
+	return c, nil
+}
+
+// RateLimiter returns the workqueue rate limiter, the client-go defaults are used when the limits are not set
+func (c *Concurrency) RateLimiter() workqueue.RateLimiter {
+	if c == nil {
+		return workqueue.DefaultControllerRateLimiter()
+	}
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(c.baseDelay, c.maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(c.qps), c.burst)},
+	)
+}
+
+// acquire blocks until an analysis slot is available
+func (c *Concurrency) acquire() {
+	if c == nil || c.slots == nil {
+		return
+	}
+	c.slots <- struct{}{}
+}
+
+// release frees the analysis slot
+func (c *Concurrency) release() {
+	if c == nil || c.slots == nil {
+		return
+	}
+	<-c.slots
+}
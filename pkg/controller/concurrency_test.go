@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConcurrency(t *testing.T) {
+	_, err := NewConcurrency(-1, time.Millisecond, time.Second, 10, 100)
+	assert.Error(t, err)
+	_, err = NewConcurrency(0, time.Second, time.Millisecond, 10, 100)
+	assert.Error(t, err)
+	_, err = NewConcurrency(0, time.Millisecond, time.Second, 0, 100)
+	assert.Error(t, err)
+
+	c, err := NewConcurrency(0, time.Millisecond, time.Second, 10, 100)
+	require.NoError(t, err)
+	rl := c.RateLimiter()
+	assert.Equal(t, time.Millisecond, rl.When("podinfo.default"))
+	assert.Equal(t, 2*time.Millisecond, rl.When("podinfo.default"))
+	assert.Equal(t, time.Millisecond, rl.When("podinfo.test"))
+}
+
+func TestConcurrency_acquire(t *testing.T) {
+	c, err := NewConcurrency(2, time.Millisecond, time.Second, 10, 100)
+	require.NoError(t, err)
+
+	var running, max int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.acquire()
+			defer c.release()
+
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), max)
+
+	// unlimited
+	var unlimited *Concurrency
+	unlimited.acquire()
+	unlimited.release()
+}
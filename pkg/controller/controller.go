@@ -27,6 +27,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -36,13 +37,16 @@ import (
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 	"github.com/fluxcd/flagger/pkg/canary"
+	"github.com/fluxcd/flagger/pkg/chaos"
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 	flaggerscheme "github.com/fluxcd/flagger/pkg/client/clientset/versioned/scheme"
 	flaggerinformers "github.com/fluxcd/flagger/pkg/client/informers/externalversions/flagger/v1beta1"
 	"github.com/fluxcd/flagger/pkg/metrics"
 	"github.com/fluxcd/flagger/pkg/metrics/observers"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
 	"github.com/fluxcd/flagger/pkg/notifier"
 	"github.com/fluxcd/flagger/pkg/router"
+	"github.com/fluxcd/flagger/pkg/tracing"
 )
 
 const controllerAgentName = "flagger"
@@ -66,14 +70,61 @@ type Controller struct {
 	observerFactory      *observers.Factory
 	meshProvider         string
 	eventWebhook         string
+	cloudEventsSink      string
 	clusterName          string
 	noCrossNamespaceRefs bool
+	faultInjector        *chaos.Injector
+	queryCache           *providers.QueryCache
+	queryRetries         int
+	builtinMetrics       observers.BuiltinMetrics
+	scrapeInterval       time.Duration
+	queryJitter          time.Duration
+	freezeWindows        *FreezeWindows
+	shard                *Shard
+	namespaceScope       *NamespaceScope
+	concurrency          *Concurrency
+	orphansNamespace     string
+	orphansPolicy        OrphansPolicy
+	lastOrphansCheck     time.Time
+	auditTrail           *AuditTrail
+	tracer               *tracing.Tracer
+	spans                sync.Map
+	clusters             sync.Map
+	excluded             sync.Map
+	lastAnalysis         sync.Map
+	inflight             sync.WaitGroup
+	handoverMu           sync.Mutex
+	handingOver          bool
+	checkingTemplates    int32
+	checkingOrphans      int32
 }
 
 type Informers struct {
-	CanaryInformer flaggerinformers.CanaryInformer
-	MetricInformer flaggerinformers.MetricTemplateInformer
-	AlertInformer  flaggerinformers.AlertProviderInformer
+	CanaryInformer       flaggerinformers.CanaryInformer
+	MetricInformer       flaggerinformers.MetricTemplateInformer
+	AlertInformer        flaggerinformers.AlertProviderInformer
+	ReleaseGroupInformer flaggerinformers.ReleaseGroupInformer
+	// SecretInformer caches the secrets that hold the kubeconfigs of the remote clusters
+	SecretInformer coreinformers.SecretInformer
+}
+
+// Options holds the optional settings of the controller, the zero value disables all of them
+type Options struct {
+	FaultInjector    *chaos.Injector
+	QueryCache       *providers.QueryCache
+	QueryRetries     int
+	BuiltinMetrics   observers.BuiltinMetrics
+	ScrapeInterval   time.Duration
+	QueryJitter      time.Duration
+	FreezeWindows    *FreezeWindows
+	Shard            *Shard
+	NamespaceScope   *NamespaceScope
+	CloudEventsSink  string
+	Tracer           *tracing.Tracer
+	Concurrency      *Concurrency
+	OrphansNamespace string
+	OrphansPolicy    OrphansPolicy
+	AuditTrail       *AuditTrail
 }
 
 func NewController(
@@ -91,6 +142,7 @@ func NewController(
 	eventWebhook string,
 	clusterName string,
 	noCrossNamespaceRefs bool,
+	opts Options,
 ) *Controller {
 	logger.Debug("Creating event broadcaster")
 	flaggerscheme.AddToScheme(scheme.Scheme)
@@ -109,7 +161,7 @@ func NewController(
 		flaggerClient:        flaggerClient,
 		flaggerInformers:     flaggerInformers,
 		flaggerSynced:        flaggerInformers.CanaryInformer.Informer().HasSynced,
-		workqueue:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerAgentName),
+		workqueue:            workqueue.NewNamedRateLimitingQueue(opts.Concurrency.RateLimiter(), controllerAgentName),
 		eventRecorder:        eventRecorder,
 		logger:               logger,
 		canaries:             new(sync.Map),
@@ -122,8 +174,23 @@ func NewController(
 		routerFactory:        routerFactory,
 		meshProvider:         meshProvider,
 		eventWebhook:         eventWebhook,
+		cloudEventsSink:      opts.CloudEventsSink,
+		tracer:               opts.Tracer,
 		clusterName:          clusterName,
 		noCrossNamespaceRefs: noCrossNamespaceRefs,
+		faultInjector:        opts.FaultInjector,
+		queryCache:           opts.QueryCache,
+		queryRetries:         opts.QueryRetries,
+		builtinMetrics:       opts.BuiltinMetrics,
+		scrapeInterval:       opts.ScrapeInterval,
+		queryJitter:          opts.QueryJitter,
+		freezeWindows:        opts.FreezeWindows,
+		shard:                opts.Shard,
+		namespaceScope:       opts.NamespaceScope,
+		concurrency:          opts.Concurrency,
+		orphansNamespace:     opts.OrphansNamespace,
+		orphansPolicy:        opts.OrphansPolicy,
+		auditTrail:           opts.AuditTrail,
 	}
 
 	flaggerInformers.CanaryInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -154,6 +221,9 @@ func NewController(
 				// If this was marked for deletion and has finalizers enqueue for finalizing or
 				// if this canary doesn't have finalizers and RevertOnDeletion is true updated speck enqueue
 				ctrl.enqueue(new)
+			} else if ctrl.shard.IsSharded() && !cmp.Equal(newCanary.Labels, oldCanary.Labels) {
+				// the canary may have moved to or from this shard
+				ctrl.enqueue(new)
 			}
 
 			// If canary no longer desires reverting, finalizers should be removed
@@ -171,6 +241,9 @@ func NewController(
 			if ok {
 				ctrl.logger.Infof("Deleting %s.%s from cache", r.Name, r.Namespace)
 				ctrl.canaries.Delete(fmt.Sprintf("%s.%s", r.Name, r.Namespace))
+				ctrl.clusters.Delete(fmt.Sprintf("%s.%s", r.Name, r.Namespace))
+				ctrl.excluded.Delete(fmt.Sprintf("%s.%s", r.Name, r.Namespace))
+				ctrl.lastAnalysis.Delete(fmt.Sprintf("%s.%s", r.Name, r.Namespace))
 			}
 		},
 	})
@@ -198,9 +271,16 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) error {
 	for {
 		select {
 		case <-tickChan:
+			c.refreshNamespaceScope()
 			c.scheduleCanaries()
+			// the metric templates are shared by the canaries of all the shards
+			if c.shard.IsFirst() {
+				c.startMetricTemplatesCheck()
+				c.startOrphansCheck(time.Now())
+			}
 		case <-stopCh:
 			c.logger.Info("Shutting down operator workers")
+			c.handover()
 			return nil
 		}
 	}
@@ -253,6 +333,20 @@ func (c *Controller) syncHandler(key string) error {
 		return nil
 	}
 
+	// the canaries of the other shards are processed by the instances owning them
+	if !c.shard.Owns(cd) {
+		if _, ok := c.canaries.LoadAndDelete(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)); ok {
+			c.logger.Infof("Canary %s.%s moved to another shard, deleting from cache", cd.Name, cd.Namespace)
+		}
+		c.clusters.Delete(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace))
+		return nil
+	}
+
+	// the canaries outside of the namespace scope are left untouched
+	if !c.inNamespaceScope(cd) {
+		return nil
+	}
+
 	if err := c.verifyCanary(cd); err != nil {
 		return fmt.Errorf("invalid canary spec: %s", err)
 	}
@@ -267,16 +361,14 @@ func (c *Controller) syncHandler(key string) error {
 
 		if cd.Status.Phase != flaggerv1.CanaryPhaseTerminated {
 			if err := c.finalize(cd); err != nil {
-				c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
-					Errorf("Unable to finalize canary: %v", err)
+				c.canaryLogger(cd).Errorf("Unable to finalize canary: %v", err)
 				return fmt.Errorf("unable to finalize to canary %s.%s error: %w", cd.Name, cd.Namespace, err)
 			}
 		}
 
 		// Remove finalizer from Canary
 		if err := c.removeFinalizer(cd); err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
-				Errorf("Unable to remove finalizer for canary %s.%s error: %v", cd.Name, cd.Namespace, err)
+			c.canaryLogger(cd).Errorf("Unable to remove finalizer for canary %s.%s error: %v", cd.Name, cd.Namespace, err)
 			return fmt.Errorf("unable to remove finalizer for canary %s.%s: %w", cd.Name, cd.Namespace, err)
 		}
 
@@ -295,7 +387,16 @@ func (c *Controller) syncHandler(key string) error {
 		}
 	}
 
-	c.canaries.Store(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace), cd)
+	// merge the analysis template to schedule the canary with the resulting interval
+	resolved, err := c.resolveAnalysisTemplate(cd)
+	if err != nil {
+		return fmt.Errorf("invalid canary spec: %w", err)
+	}
+	if err := c.verifyCanary(resolved); err != nil {
+		return fmt.Errorf("invalid canary spec: %s", err)
+	}
+
+	c.canaries.Store(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace), resolved)
 
 	// If opt in for revertOnDeletion add finalizer if not present
 	if cd.Spec.RevertOnDeletion && !hasFinalizer(cd) {
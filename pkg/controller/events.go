@@ -23,26 +23,37 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/logger"
 	"github.com/fluxcd/flagger/pkg/notifier"
 )
 
+// logLevelAnnotation overrides the log level of Flagger for a canary e.g. flagger.app/log-level: debug
+const logLevelAnnotation = "flagger.app/log-level"
+
+// canaryLogger returns the logger of the canary with the level set by the log level annotation
+func (c *Controller) canaryLogger(r *flaggerv1.Canary) *zap.SugaredLogger {
+	return logger.WithLevel(c.logger, r.GetAnnotations()[logLevelAnnotation]).
+		With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace))
+}
+
 func (c *Controller) recordEventInfof(r *flaggerv1.Canary, template string, args ...interface{}) {
-	c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Infof(template, args...)
+	c.canaryLogger(r).Infof(template, args...)
 	c.eventRecorder.Event(r, corev1.EventTypeNormal, "Synced", fmt.Sprintf(template, args...))
 	c.sendEventToWebhook(r, corev1.EventTypeNormal, template, args)
 }
 
 func (c *Controller) recordEventErrorf(r *flaggerv1.Canary, template string, args ...interface{}) {
-	c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Errorf(template, args...)
+	c.canaryLogger(r).Errorf(template, args...)
 	c.eventRecorder.Event(r, corev1.EventTypeWarning, "Synced", fmt.Sprintf(template, args...))
 	c.sendEventToWebhook(r, corev1.EventTypeWarning, template, args)
 }
 
 func (c *Controller) recordEventWarningf(r *flaggerv1.Canary, template string, args ...interface{}) {
-	c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Infof(template, args...)
+	c.canaryLogger(r).Infof(template, args...)
 	c.eventRecorder.Event(r, corev1.EventTypeWarning, "Synced", fmt.Sprintf(template, args...))
 	c.sendEventToWebhook(r, corev1.EventTypeWarning, template, args)
 }
@@ -54,7 +65,7 @@ func (c *Controller) sendEventToWebhook(r *flaggerv1.Canary, eventType, template
 			webhookOverride = true
 			err := CallEventWebhook(r, canaryWebhook, fmt.Sprintf(template, args...), eventType)
 			if err != nil {
-				c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Errorf("error sending event to webhook: %s", err)
+				c.canaryLogger(r).Errorf("error sending event to webhook: %s", err)
 			}
 		}
 	}
@@ -66,7 +77,7 @@ func (c *Controller) sendEventToWebhook(r *flaggerv1.Canary, eventType, template
 		}
 		err := CallEventWebhook(r, hook, fmt.Sprintf(template, args...), eventType)
 		if err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Errorf("error sending event to webhook: %s", err)
+			c.canaryLogger(r).Errorf("error sending event to webhook: %s", err)
 		}
 	}
 }
@@ -81,8 +92,7 @@ func (c *Controller) alert(canary *flaggerv1.Canary, message string, metadata bo
 	if len(canary.GetAnalysis().Alerts) == 0 {
 		err := c.notifier.Post(canary.Name, canary.Namespace, message, fields, string(severity))
 		if err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
-				Errorf("alert can't be sent: %v", err)
+			c.canaryLogger(canary).Errorf("alert can't be sent: %v", err)
 			return
 		}
 		return
@@ -115,8 +125,7 @@ func (c *Controller) alert(canary *flaggerv1.Canary, message string, metadata bo
 		// find alert provider
 		provider, err := c.flaggerInformers.AlertInformer.Lister().AlertProviders(providerNamespace).Get(alert.ProviderRef.Name)
 		if err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
-				Errorf("alert provider %s.%s error: %v", alert.ProviderRef.Name, providerNamespace, err)
+			c.canaryLogger(canary).Errorf("alert provider %s.%s error: %v", alert.ProviderRef.Name, providerNamespace, err)
 			continue
 		}
 
@@ -127,15 +136,13 @@ func (c *Controller) alert(canary *flaggerv1.Canary, message string, metadata bo
 		if provider.Spec.SecretRef != nil {
 			secret, err := c.kubeClient.CoreV1().Secrets(providerNamespace).Get(context.TODO(), provider.Spec.SecretRef.Name, metav1.GetOptions{})
 			if err != nil {
-				c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
-					Errorf("alert provider %s.%s secretRef error: %v", alert.ProviderRef.Name, providerNamespace, err)
+				c.canaryLogger(canary).Errorf("alert provider %s.%s secretRef error: %v", alert.ProviderRef.Name, providerNamespace, err)
 				continue
 			}
 			if address, ok := secret.Data["address"]; ok {
 				url = string(address)
 			} else {
-				c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
-					Errorf("alert provider %s.%s secret does not contain an address", alert.ProviderRef.Name, providerNamespace)
+				c.canaryLogger(canary).Errorf("alert provider %s.%s secret does not contain an address", alert.ProviderRef.Name, providerNamespace)
 				continue
 			}
 		}
@@ -158,16 +165,14 @@ func (c *Controller) alert(canary *flaggerv1.Canary, message string, metadata bo
 		f := notifier.NewFactory(url, proxy, username, channel)
 		n, err := f.Notifier(provider.Spec.Type)
 		if err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
-				Errorf("alert provider %s.%s error: %v", alert.ProviderRef.Name, providerNamespace, err)
+			c.canaryLogger(canary).Errorf("alert provider %s.%s error: %v", alert.ProviderRef.Name, providerNamespace, err)
 			continue
 		}
 
 		// send alert
 		err = n.Post(canary.Name, canary.Namespace, message, fields, string(severity))
 		if err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
-				Errorf("alert provider $s.%s send error: %v", alert.ProviderRef.Name, providerNamespace, err)
+			c.canaryLogger(canary).Errorf("alert provider $s.%s send error: %v", alert.ProviderRef.Name, providerNamespace, err)
 		}
 
 	}
@@ -19,11 +19,14 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/retry"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+	"github.com/fluxcd/flagger/pkg/router"
 )
 
 const finalizer = "finalizer.flagger.app"
@@ -39,8 +42,14 @@ func (c *Controller) finalize(old interface{}) error {
 		return fmt.Errorf("get query error: %w", err)
 	}
 
+	// Retrieve the factories of the cluster running the target workload
+	canaryFactory, routerFactory, err := c.getClusterFactories(canary)
+	if err != nil {
+		return fmt.Errorf("failed to init the cluster factories: %w", err)
+	}
+
 	// Retrieve a controller
-	canaryController := c.canaryFactory.Controller(canary.Spec.TargetRef.Kind)
+	canaryController := canaryFactory.Controller(canary.Spec.TargetRef.Kind)
 
 	// Set the status to terminating if not already in that state
 	if canary.Status.Phase != flaggerv1.CanaryPhaseTerminating {
@@ -52,8 +61,15 @@ func (c *Controller) finalize(old interface{}) error {
 		c.recordEventInfof(canary, "Terminating canary %s.%s", canary.Name, canary.Namespace)
 	}
 
+	finalization := newFinalizationStatus(canary)
+	targetResource := fmt.Sprintf("%s/%s", canary.Spec.TargetRef.Kind, canary.Spec.TargetRef.Name)
+	apexName, _, _ := canary.GetServiceNames()
+	serviceResource := fmt.Sprintf("Service/%s", apexName)
+	meshResource := fmt.Sprintf("%s/%s", c.getMeshProvider(canary), apexName)
+
 	// Revert the Kubernetes deployment or daemonset
 	err = canaryController.Finalize(canary)
+	c.setFinalizationStatus(canary, canaryController, finalization, targetResource, err)
 	if err != nil {
 		return fmt.Errorf("failed to revert target: %w", err)
 	}
@@ -72,14 +88,18 @@ func (c *Controller) finalize(old interface{}) error {
 	}
 
 	// Revert the Kubernetes service
-	router := c.routerFactory.KubernetesRouter(canary.Spec.TargetRef.Kind, labelSelector, labelValue, ports)
-	if err := router.Finalize(canary); err != nil {
+	router := c.faultInjector.KubernetesRouter(routerFactory.KubernetesRouter(canary.Spec.TargetRef.Kind, labelSelector, labelValue, ports))
+	err = router.Finalize(canary)
+	c.setFinalizationStatus(canary, canaryController, finalization, serviceResource, err)
+	if err != nil {
 		return fmt.Errorf("failed revert router: %w", err)
 	}
 	c.logger.Infof("%s.%s router reverted", canary.Name, canary.Namespace)
 
 	// Revert the mesh objects
-	if err := c.revertMesh(canary); err != nil {
+	err = c.revertMesh(canary, routerFactory)
+	c.setFinalizationStatus(canary, canaryController, finalization, meshResource, err)
+	if err != nil {
 		return fmt.Errorf("failed to revert mesh: %w", err)
 	}
 
@@ -89,13 +109,10 @@ func (c *Controller) finalize(old interface{}) error {
 
 // revertMesh reverts defined mesh provider based upon the implementation's respective Finalize method.
 // If the Finalize method encounters and error that is returned, else revert is considered successful.
-func (c *Controller) revertMesh(r *flaggerv1.Canary) error {
-	provider := c.meshProvider
-	if r.Spec.Provider != "" {
-		provider = r.Spec.Provider
-	}
+func (c *Controller) revertMesh(r *flaggerv1.Canary, routerFactory *router.Factory) error {
+	provider := c.getMeshProvider(r)
 
-	meshRouter := c.routerFactory.MeshRouter(provider, "")
+	meshRouter := c.faultInjector.MeshRouter(routerFactory.MeshRouter(provider, ""))
 	if err := meshRouter.Finalize(r); err != nil {
 		return fmt.Errorf("meshRouter.Finlize failed: %w", err)
 	}
@@ -104,6 +121,55 @@ func (c *Controller) revertMesh(r *flaggerv1.Canary) error {
 	return nil
 }
 
+// getMeshProvider returns the canary provider, defaults to the controller mesh provider
+func (c *Controller) getMeshProvider(r *flaggerv1.Canary) string {
+	if r.Spec.Provider != "" {
+		return r.Spec.Provider
+	}
+	return c.meshProvider
+}
+
+// newFinalizationStatus returns a copy of the canary finalization status
+func newFinalizationStatus(canary *flaggerv1.Canary) map[string]flaggerv1.CanaryFinalizationStatus {
+	finalization := make(map[string]flaggerv1.CanaryFinalizationStatus, len(canary.Status.Finalization))
+	for _, f := range canary.Status.Finalization {
+		finalization[f.Resource] = *f.DeepCopy()
+	}
+	return finalization
+}
+
+// setFinalizationStatus records the revert result of a resource in the canary status,
+// status update errors are logged since the finalization is retried until it succeeds
+func (c *Controller) setFinalizationStatus(canary *flaggerv1.Canary, canaryController canary.Controller,
+	finalization map[string]flaggerv1.CanaryFinalizationStatus, resource string, err error) {
+	status := flaggerv1.CanaryFinalizationStatus{
+		Resource:  resource,
+		Finalized: err == nil,
+	}
+	if err != nil {
+		status.Message = err.Error()
+	}
+
+	if current, ok := finalization[resource]; ok && current.Finalized == status.Finalized && current.Message == status.Message {
+		return
+	}
+	status.LastTransitionTime = metav1.Now()
+	finalization[resource] = status
+
+	list := make([]flaggerv1.CanaryFinalizationStatus, 0, len(finalization))
+	for _, f := range finalization {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastTransitionTime.Before(&list[j].LastTransitionTime) ||
+			list[i].LastTransitionTime.Equal(&list[j].LastTransitionTime) && list[i].Resource < list[j].Resource
+	})
+
+	if err := canaryController.SetStatusFinalization(canary, list); err != nil {
+		c.canaryLogger(canary).Errorf("Failed to update finalization status of %s: %v", resource, err)
+	}
+}
+
 // hasFinalizer evaluates the finalizers of a given canary for for existence of a provide finalizer string.
 // It returns a boolean, true if the finalizer is found false otherwise.
 func hasFinalizer(canary *flaggerv1.Canary) bool {
@@ -133,7 +199,7 @@ func (c *Controller) addFinalizer(canary *flaggerv1.Canary) error {
 		cCopy := canary.DeepCopy()
 		if !hasFinalizer(cCopy) {
 			cCopy.ObjectMeta.Finalizers = append(cCopy.ObjectMeta.Finalizers, finalizer)
-			_, err = c.flaggerClient.FlaggerV1beta1().Canaries(canary.Namespace).Update(context.TODO(), cCopy, metav1.UpdateOptions{})
+			_, err = c.flaggerClient.FlaggerV1beta1().Canaries(canary.Namespace).Update(context.TODO(), cCopy, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 		}
 
 		return
@@ -173,7 +239,7 @@ func (c *Controller) removeFinalizer(canary *flaggerv1.Canary) error {
 		}
 
 		cCopy.ObjectMeta.Finalizers = nfs
-		_, err = c.flaggerClient.FlaggerV1beta1().Canaries(canary.Namespace).Update(context.TODO(), cCopy, metav1.UpdateOptions{})
+		_, err = c.flaggerClient.FlaggerV1beta1().Canaries(canary.Namespace).Update(context.TODO(), cCopy, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 		firstTry = false
 		return
 	})
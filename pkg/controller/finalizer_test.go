@@ -17,10 +17,12 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8sTesting "k8s.io/client-go/testing"
 
@@ -104,3 +106,33 @@ func TestFinalizer_removeFinalizer(t *testing.T) {
 		}
 	}
 }
+
+func TestFinalizer_setFinalizationStatus(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	canaryController := mocks.ctrl.canaryFactory.Controller("Deployment")
+	finalization := newFinalizationStatus(mocks.canary)
+
+	mocks.ctrl.setFinalizationStatus(mocks.canary, canaryController, finalization, "Deployment/podinfo", nil)
+	mocks.ctrl.setFinalizationStatus(mocks.canary, canaryController, finalization, "Service/podinfo", fmt.Errorf("service error"))
+
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Finalization, 2)
+	require.Equal(t, "Deployment/podinfo", c.Status.Finalization[0].Resource)
+	require.True(t, c.Status.Finalization[0].Finalized)
+	require.Equal(t, "Service/podinfo", c.Status.Finalization[1].Resource)
+	require.False(t, c.Status.Finalization[1].Finalized)
+	require.Equal(t, "service error", c.Status.Finalization[1].Message)
+
+	// retrying the finalization updates the resource status
+	finalization = newFinalizationStatus(c)
+	mocks.ctrl.setFinalizationStatus(c, canaryController, finalization, "Service/podinfo", nil)
+
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Finalization, 2)
+	for _, f := range c.Status.Finalization {
+		require.True(t, f.Finalized)
+		require.Empty(t, f.Message)
+	}
+}
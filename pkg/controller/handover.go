@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// runCanaryJob advances the canary and records the start time of the run,
+// no new runs are started once the controller is handing over the canaries
+func (c *Controller) runCanaryJob(name string, namespace string) {
+	c.concurrency.acquire()
+	defer c.concurrency.release()
+
+	c.handoverMu.Lock()
+	if c.handingOver {
+		c.handoverMu.Unlock()
+		return
+	}
+	c.inflight.Add(1)
+	c.handoverMu.Unlock()
+	defer c.inflight.Done()
+
+	c.lastAnalysis.Store(fmt.Sprintf("%s.%s", name, namespace), time.Now())
+	c.advanceCanary(name, namespace)
+}
+
+// handover stops the canary jobs, waits for the in-flight analysis runs to finish
+// and persists the time of the next run of the canaries under analysis,
+// the next leader resumes the analysis on schedule instead of restarting the interval
+func (c *Controller) handover() {
+	for name, job := range c.jobs {
+		job.Stop()
+		delete(c.jobs, name)
+	}
+
+	c.handoverMu.Lock()
+	c.handingOver = true
+	c.handoverMu.Unlock()
+	c.inflight.Wait()
+
+	c.lastAnalysis.Range(func(key interface{}, value interface{}) bool {
+		cn, ok := c.canaries.Load(key)
+		if !ok {
+			return true
+		}
+		name, namespace := cn.(*flaggerv1.Canary).Name, cn.(*flaggerv1.Canary).Namespace
+		cd, err := c.flaggerClient.FlaggerV1beta1().Canaries(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			c.logger.With("canary", key).Errorf("Canary %s handover failed: %v", key, err)
+			return true
+		}
+		if !isAnalysisInProgress(cd.Status.Phase) {
+			return true
+		}
+
+		next := metav1.NewTime(value.(time.Time).Add(cd.GetAnalysisInterval()))
+		canaryController := c.canaryFactory.Controller(cd.Spec.TargetRef.Kind)
+		if err := canaryController.SetStatusNextAnalysisTime(cd, next); err != nil {
+			c.canaryLogger(cd).Errorf("Canary %s handover failed: %v", key, err)
+			return true
+		}
+		c.canaryLogger(cd).Infof("Canary %s handed over, next analysis at %s", key, next.Format(time.RFC3339))
+		return true
+	})
+}
+
+// canaryJobResumeOffset returns the delay until the next analysis run
+// persisted by the previous leader, the delay is never longer than the analysis interval
+func canaryJobResumeOffset(cd *flaggerv1.Canary, now time.Time) (time.Duration, bool) {
+	next := cd.Status.NextAnalysisTime
+	if next.IsZero() || !isAnalysisInProgress(cd.Status.Phase) {
+		return 0, false
+	}
+
+	offset := next.Sub(now)
+	if offset <= 0 || offset > cd.GetAnalysisInterval() {
+		return 0, false
+	}
+	return offset, true
+}
+
+// isAnalysisInProgress returns true for the phases that are advanced on each analysis interval
+func isAnalysisInProgress(phase flaggerv1.CanaryPhase) bool {
+	switch phase {
+	case flaggerv1.CanaryPhaseInitializing,
+		flaggerv1.CanaryPhaseWaiting,
+		flaggerv1.CanaryPhaseProgressing,
+		flaggerv1.CanaryPhaseWaitingPromotion,
+		flaggerv1.CanaryPhasePromoting,
+		flaggerv1.CanaryPhaseFinalising:
+		return true
+	}
+	return false
+}
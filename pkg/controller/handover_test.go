@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestController_handover(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.canaries.Store("podinfo.default", mocks.canary)
+
+	// initializing
+	mocks.ctrl.runCanaryJob("podinfo", "default")
+	mocks.makePrimaryReady(t)
+
+	// initialized
+	mocks.ctrl.runCanaryJob("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes
+	mocks.ctrl.runCanaryJob("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	// progressing
+	mocks.ctrl.runCanaryJob("podinfo", "default")
+	last, ok := mocks.ctrl.lastAnalysis.Load("podinfo.default")
+	require.True(t, ok)
+
+	mocks.ctrl.handover()
+
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, c.Status.Phase)
+	assert.Equal(t, 10, c.Status.CanaryWeight)
+	assert.Equal(t, last.(time.Time).Add(c.GetAnalysisInterval()).Unix(), c.Status.NextAnalysisTime.Unix())
+
+	// no analysis runs after the handover
+	mocks.ctrl.runCanaryJob("podinfo", "default")
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 10, c.Status.CanaryWeight)
+}
+
+func TestCanaryJobResumeOffset(t *testing.T) {
+	now := time.Now()
+	cd := newDeploymentTestCanary()
+	cd.Spec.Analysis.Interval = "1m"
+	cd.Status.Phase = flaggerv1.CanaryPhaseProgressing
+
+	_, ok := canaryJobResumeOffset(cd, now)
+	assert.False(t, ok, "no handover")
+
+	cd.Status.NextAnalysisTime = metav1.NewTime(now.Add(20 * time.Second))
+	offset, ok := canaryJobResumeOffset(cd, now)
+	require.True(t, ok)
+	assert.Equal(t, 20*time.Second, offset)
+
+	cd.Status.NextAnalysisTime = metav1.NewTime(now.Add(-20 * time.Second))
+	_, ok = canaryJobResumeOffset(cd, now)
+	assert.False(t, ok, "next run is overdue")
+
+	cd.Status.NextAnalysisTime = metav1.NewTime(now.Add(2 * time.Minute))
+	_, ok = canaryJobResumeOffset(cd, now)
+	assert.False(t, ok, "offset longer than the interval")
+
+	cd.Status.NextAnalysisTime = metav1.NewTime(now.Add(20 * time.Second))
+	cd.Status.Phase = flaggerv1.CanaryPhaseSucceeded
+	_, ok = canaryJobResumeOffset(cd, now)
+	assert.False(t, ok, "analysis finished")
+}
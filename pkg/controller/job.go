@@ -16,7 +16,10 @@ limitations under the License.
 
 package controller
 
-import "time"
+import (
+	"hash/fnv"
+	"time"
+)
 
 // CanaryJob holds the reference to a canary deployment schedule
 type CanaryJob struct {
@@ -26,11 +29,22 @@ type CanaryJob struct {
 	done             chan bool
 	ticker           *time.Ticker
 	analysisInterval time.Duration
+	offset           time.Duration
 }
 
 // Start runs the canary analysis on a schedule
 func (j CanaryJob) Start() {
 	go func() {
+		// spread the schedules of the canaries to avoid querying the metrics at the same time
+		if j.offset > 0 {
+			select {
+			case <-time.After(j.offset):
+				j.ticker.Reset(j.analysisInterval)
+			case <-j.done:
+				return
+			}
+		}
+
 		// run the infra bootstrap on job creation
 		j.function(j.Name, j.Namespace)
 		for {
@@ -53,3 +67,18 @@ func (j CanaryJob) Stop() {
 func (j CanaryJob) GetCanaryAnalysisInterval() time.Duration {
 	return j.analysisInterval
 }
+
+// canaryJobOffset returns a delay lower than the jitter and the analysis interval,
+// the delay is derived from the canary name to keep it stable across restarts
+func canaryJobOffset(name string, interval time.Duration, jitter time.Duration) time.Duration {
+	if jitter > interval {
+		jitter = interval
+	}
+	if jitter <= 0 {
+		return 0
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return time.Duration(uint64(h.Sum32()) % uint64(jitter))
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/metrics/observers"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+)
+
+const (
+	// metricTemplateCheckInterval is the max age of the metric template validation
+	metricTemplateCheckInterval = 5 * time.Minute
+
+	// metricTemplateDryRunTarget is the workload name used to render the queries
+	metricTemplateDryRunTarget = "flagger-dry-run"
+
+	metricTemplateAvailableReason     = "Available"
+	metricTemplateSecretErrorReason   = "SecretError"
+	metricTemplateProviderErrorReason = "ProviderError"
+	metricTemplateUnavailableReason   = "Unavailable"
+	metricTemplateInvalidQueryReason  = "InvalidQuery"
+)
+
+// metricTemplateError holds the reason of a failed metric template validation
+type metricTemplateError struct {
+	reason string
+	err    error
+}
+
+func (e *metricTemplateError) Error() string {
+	return e.err.Error()
+}
+
+// startMetricTemplatesCheck validates the metric templates in the background,
+// a new check is not started until the previous one is finished
+func (c *Controller) startMetricTemplatesCheck() {
+	if !atomic.CompareAndSwapInt32(&c.checkingTemplates, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&c.checkingTemplates, 0)
+		c.checkMetricTemplates(time.Now())
+	}()
+}
+
+// checkMetricTemplates validates the metric templates changed since the last validation
+// or validated before the check interval
+func (c *Controller) checkMetricTemplates(now time.Time) {
+	templates, err := c.flaggerInformers.MetricInformer.Lister().List(labels.Everything())
+	if err != nil {
+		c.logger.Errorf("Listing the metric templates failed: %v", err)
+		return
+	}
+
+	for _, template := range templates {
+		if !metricTemplateCheckDue(template, now) {
+			continue
+		}
+		c.checkMetricTemplate(template)
+	}
+}
+
+// checkMetricTemplate validates the provider and the queries of the metric template
+// and sets the Ready condition, a warning event is emitted when the template becomes not ready
+func (c *Controller) checkMetricTemplate(template *flaggerv1.MetricTemplate) {
+	status, reason, message := corev1.ConditionTrue, metricTemplateAvailableReason, "Provider is available and queries are valid."
+	if err := c.validateMetricTemplate(template); err != nil {
+		status, reason, message = corev1.ConditionFalse, metricTemplateProviderErrorReason, err.Error()
+		var mte *metricTemplateError
+		if errors.As(err, &mte) {
+			reason = mte.reason
+		}
+	}
+
+	previous := getMetricTemplateCondition(template.Status, flaggerv1.MetricTemplateReadyCondition)
+	if err := c.setMetricTemplateCondition(template, status, reason, message); err != nil {
+		c.logger.With("template", fmt.Sprintf("%s.%s", template.Name, template.Namespace)).
+			Errorf("Updating the metric template status failed: %v", err)
+		return
+	}
+
+	if status == corev1.ConditionFalse && (previous == nil || previous.Status != status || previous.Message != message) {
+		c.logger.With("template", fmt.Sprintf("%s.%s", template.Name, template.Namespace)).
+			Errorf("Metric template is not ready: %s", message)
+		c.eventRecorder.Event(template, corev1.EventTypeWarning, reason, message)
+	}
+}
+
+// validateMetricTemplate reads the provider credentials, checks that the providers are online and
+// runs the queries rendered for a dry-run target, the queries returning no values are considered valid
+func (c *Controller) validateMetricTemplate(template *flaggerv1.MetricTemplate) error {
+	queries := template.Spec.Queries
+	if len(queries) == 0 {
+		queries = []flaggerv1.MetricTemplateQuery{{Query: template.Spec.Query}}
+	}
+
+	model := flaggerv1.MetricTemplateModel{
+		Name:        metricTemplateDryRunTarget,
+		Namespace:   template.Namespace,
+		Target:      metricTemplateDryRunTarget,
+		Service:     metricTemplateDryRunTarget,
+		Ingress:     metricTemplateDryRunTarget,
+		Interval:    flaggerv1.MetricInterval,
+		RunDuration: flaggerv1.MetricInterval,
+	}
+
+	values := make(map[string]float64, len(queries))
+	for _, q := range queries {
+		spec := template.Spec.Provider
+		if q.Provider != nil {
+			spec = *q.Provider
+		}
+
+		var credentials map[string][]byte
+		if spec.SecretRef != nil {
+			secret, err := c.kubeClient.CoreV1().Secrets(template.Namespace).Get(context.TODO(), spec.SecretRef.Name, metav1.GetOptions{})
+			if err != nil {
+				return &metricTemplateError{reason: metricTemplateSecretErrorReason,
+					err: fmt.Errorf("secret %s error: %w", spec.SecretRef.Name, err)}
+			}
+			credentials = secret.Data
+		}
+
+		factory := providers.Factory{}
+		provider, err := factory.Provider(flaggerv1.MetricInterval, spec, credentials)
+		if err != nil {
+			return &metricTemplateError{reason: metricTemplateProviderErrorReason,
+				err: fmt.Errorf("provider %s error: %w", spec.Type, err)}
+		}
+
+		if ok, err := provider.IsOnline(); !ok || err != nil {
+			return &metricTemplateError{reason: metricTemplateUnavailableReason,
+				err: fmt.Errorf("provider %s not available: %v", spec.Type, err)}
+		}
+
+		query, err := observers.RenderQuery(q.Query, model)
+		if err != nil {
+			return &metricTemplateError{reason: metricTemplateInvalidQueryReason,
+				err: fmt.Errorf("query %s render error: %w", q.Name, err)}
+		}
+
+		if _, err := provider.RunQuery(query); err != nil && !errors.Is(err, providers.ErrNoValuesFound) {
+			reason := metricTemplateInvalidQueryReason
+			if providers.IsTransient(err) {
+				reason = metricTemplateUnavailableReason
+			}
+			return &metricTemplateError{reason: reason, err: fmt.Errorf("query %s failed: %w", q.Name, err)}
+		}
+		values[q.Name] = 1
+	}
+
+	if template.Spec.Expression != "" {
+		if _, err := observers.EvaluateExpression(template.Spec.Expression, values); err != nil {
+			return &metricTemplateError{reason: metricTemplateInvalidQueryReason,
+				err: fmt.Errorf("expression error: %w", err)}
+		}
+	}
+	return nil
+}
+
+// setMetricTemplateCondition updates the Ready condition and the observed generation of the metric template
+func (c *Controller) setMetricTemplateCondition(template *flaggerv1.MetricTemplate, status corev1.ConditionStatus,
+	reason string, message string) error {
+	firstTry := true
+	name, ns := template.GetName(), template.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			template, err = c.flaggerClient.FlaggerV1beta1().MetricTemplates(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("metric template %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+
+		newCondition := flaggerv1.MetricTemplateCondition{
+			Type:               flaggerv1.MetricTemplateReadyCondition,
+			Status:             status,
+			LastUpdateTime:     metav1.Now(),
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		}
+
+		templateCopy := template.DeepCopy()
+		conditions := make([]flaggerv1.MetricTemplateCondition, 0, len(template.Status.Conditions)+1)
+		for _, condition := range template.Status.Conditions {
+			if condition.Type != flaggerv1.MetricTemplateReadyCondition {
+				conditions = append(conditions, condition)
+			} else if condition.Status == status {
+				newCondition.LastTransitionTime = condition.LastTransitionTime
+			}
+		}
+		templateCopy.Status.Conditions = append(conditions, newCondition)
+		templateCopy.Status.ObservedGeneration = template.Generation
+
+		_, err = c.flaggerClient.FlaggerV1beta1().MetricTemplates(ns).UpdateStatus(context.TODO(), templateCopy, metav1.UpdateOptions{})
+		firstTry = false
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
+
+// metricTemplateCheckDue returns true if the metric template has changed since
+// the last validation or if the validation is older than the check interval
+func metricTemplateCheckDue(template *flaggerv1.MetricTemplate, now time.Time) bool {
+	condition := getMetricTemplateCondition(template.Status, flaggerv1.MetricTemplateReadyCondition)
+	if condition == nil || template.Status.ObservedGeneration != template.Generation {
+		return true
+	}
+	return now.Sub(condition.LastUpdateTime.Time) >= metricTemplateCheckInterval
+}
+
+// getMetricTemplateCondition returns the condition of the given type or nil if not found
+func getMetricTemplateCondition(status flaggerv1.MetricTemplateStatus, conditionType string) *flaggerv1.MetricTemplateCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestController_checkMetricTemplates(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		switch {
+		case strings.Contains(query, "invalid"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+		case strings.Contains(query, "empty"):
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		default:
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"1"]}]}}`))
+		}
+	}))
+	defer ts.Close()
+
+	getReady := func(t *testing.T, mocks fixture) *flaggerv1.MetricTemplateCondition {
+		template, err := mocks.flaggerClient.FlaggerV1beta1().MetricTemplates("default").Get(context.TODO(), "envoy", metav1.GetOptions{})
+		require.NoError(t, err)
+		return getMetricTemplateCondition(template.Status, flaggerv1.MetricTemplateReadyCondition)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		update func(template *flaggerv1.MetricTemplate)
+		status corev1.ConditionStatus
+		reason string
+	}{
+		{
+			name:   "available",
+			update: func(template *flaggerv1.MetricTemplate) {},
+			status: corev1.ConditionTrue,
+			reason: metricTemplateAvailableReason,
+		},
+		{
+			name: "no values",
+			update: func(template *flaggerv1.MetricTemplate) {
+				template.Spec.Query = `sum(empty{pod=~"{{ target }}-.*"})`
+			},
+			status: corev1.ConditionTrue,
+			reason: metricTemplateAvailableReason,
+		},
+		{
+			name: "invalid query",
+			update: func(template *flaggerv1.MetricTemplate) {
+				template.Spec.Query = `sum(invalid{`
+			},
+			status: corev1.ConditionFalse,
+			reason: metricTemplateInvalidQueryReason,
+		},
+		{
+			name: "invalid template",
+			update: func(template *flaggerv1.MetricTemplate) {
+				template.Spec.Query = `sum(errors{pod=~"{{ unknown }}"})`
+			},
+			status: corev1.ConditionFalse,
+			reason: metricTemplateInvalidQueryReason,
+		},
+		{
+			name: "missing secret",
+			update: func(template *flaggerv1.MetricTemplate) {
+				template.Spec.Provider.SecretRef.Name = "missing"
+			},
+			status: corev1.ConditionFalse,
+			reason: metricTemplateSecretErrorReason,
+		},
+		{
+			name: "missing credentials",
+			update: func(template *flaggerv1.MetricTemplate) {
+				template.Spec.Provider.Type = "datadog"
+			},
+			status: corev1.ConditionFalse,
+			reason: metricTemplateProviderErrorReason,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mocks := newDeploymentFixture(nil)
+			template := newDeploymentTestMetricTemplate()
+			template.Spec.Provider.Address = ts.URL
+			tc.update(template)
+			_, err := mocks.flaggerClient.FlaggerV1beta1().MetricTemplates("default").Update(context.TODO(), template, metav1.UpdateOptions{})
+			require.NoError(t, err)
+			require.NoError(t, mocks.ctrl.flaggerInformers.MetricInformer.Informer().GetIndexer().Update(template))
+
+			mocks.ctrl.checkMetricTemplates(time.Now())
+
+			condition := getReady(t, mocks)
+			require.NotNil(t, condition)
+			assert.Equal(t, tc.status, condition.Status)
+			assert.Equal(t, tc.reason, condition.Reason)
+		})
+	}
+}
+
+func TestMetricTemplateCheckDue(t *testing.T) {
+	now := time.Now()
+	template := newDeploymentTestMetricTemplate()
+	assert.True(t, metricTemplateCheckDue(template, now))
+
+	template.Status.Conditions = []flaggerv1.MetricTemplateCondition{{
+		Type:           flaggerv1.MetricTemplateReadyCondition,
+		Status:         corev1.ConditionTrue,
+		LastUpdateTime: metav1.NewTime(now.Add(-time.Minute)),
+	}}
+	assert.False(t, metricTemplateCheckDue(template, now))
+	assert.True(t, metricTemplateCheckDue(template, now.Add(metricTemplateCheckInterval)))
+
+	// the template has changed since the last check
+	template.Generation = 2
+	assert.True(t, metricTemplateCheckDue(template, now))
+}
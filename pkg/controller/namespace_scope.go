@@ -0,0 +1,197 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// NamespaceScopeSpec lists the namespaces whose canaries are managed by Flagger
+type NamespaceScopeSpec struct {
+	// Include lists the glob patterns of the managed namespaces, all the namespaces are managed when empty
+	Include []string `json:"include,omitempty"`
+
+	// Exclude lists the glob patterns of the namespaces that are not managed, it takes precedence over Include
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// NamespaceScope holds the namespace scope read from a file usually mounted from a ConfigMap,
+// the file is read again when it changes so that the namespaces can be onboarded without a restart
+type NamespaceScope struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	spec    NamespaceScopeSpec
+}
+
+// LoadNamespaceScope reads and validates the YAML namespace scope from a file
+func LoadNamespaceScope(path string) (*NamespaceScope, error) {
+	s := &NamespaceScope{path: path}
+	if _, err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Allows returns true if the canaries of the namespace are managed
+func (s *NamespaceScope) Allows(namespace string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spec.Allows(namespace)
+}
+
+// Refresh reads the file again if it has been modified and returns true if the scope changed,
+// the previous scope is kept if the changed file is not valid
+func (s *NamespaceScope) Refresh() (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reload()
+}
+
+func (s *NamespaceScope) String() string {
+	if s == nil {
+		return "all namespaces"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scope := "all namespaces"
+	if len(s.spec.Include) > 0 {
+		scope = strings.Join(s.spec.Include, ", ")
+	}
+	if len(s.spec.Exclude) > 0 {
+		scope = fmt.Sprintf("%s except %s", scope, strings.Join(s.spec.Exclude, ", "))
+	}
+	return scope
+}
+
+// reload reads the file if it has been modified since it was last read
+func (s *NamespaceScope) reload() (bool, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false, fmt.Errorf("reading namespace scope failed: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return false, nil
+	}
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return false, fmt.Errorf("reading namespace scope failed: %w", err)
+	}
+	defer file.Close()
+
+	spec, err := ParseNamespaceScope(file)
+	if err != nil {
+		return false, err
+	}
+	s.spec, s.modTime = spec, info.ModTime()
+	return true, nil
+}
+
+// ParseNamespaceScope decodes and validates a YAML or JSON namespace scope
+func ParseNamespaceScope(r io.Reader) (NamespaceScopeSpec, error) {
+	var spec NamespaceScopeSpec
+	if err := yaml.NewYAMLOrJSONDecoder(r, 4096).Decode(&spec); err != nil && err != io.EOF {
+		return NamespaceScopeSpec{}, fmt.Errorf("decoding namespace scope failed: %w", err)
+	}
+
+	for _, pattern := range append(spec.Include, spec.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return NamespaceScopeSpec{}, fmt.Errorf("namespace pattern %s is invalid: %w", pattern, err)
+		}
+	}
+	return spec, nil
+}
+
+// Allows returns true if the namespace is included and not excluded
+func (spec NamespaceScopeSpec) Allows(namespace string) bool {
+	for _, pattern := range spec.Exclude {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return false
+		}
+	}
+	if len(spec.Include) == 0 {
+		return true
+	}
+	for _, pattern := range spec.Include {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshNamespaceScope reloads the namespace scope and syncs all the canaries again when it changed
+func (c *Controller) refreshNamespaceScope() {
+	changed, err := c.namespaceScope.Refresh()
+	if err != nil {
+		c.logger.Errorf("%v", err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	c.logger.Infof("Namespace scope changed to %s", c.namespaceScope)
+	canaries, err := c.flaggerInformers.CanaryInformer.Lister().List(labels.Everything())
+	if err != nil {
+		c.logger.Errorf("Listing the canaries failed: %v", err)
+		return
+	}
+	for _, cd := range canaries {
+		c.enqueue(cd)
+	}
+}
+
+// inNamespaceScope returns true if the canary namespace is managed, the canaries moving
+// out of the scope are removed from the schedule and an event confirms the scope change
+func (c *Controller) inNamespaceScope(cd *flaggerv1.Canary) bool {
+	key := fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)
+	if !c.namespaceScope.Allows(cd.Namespace) {
+		if _, excluded := c.excluded.LoadOrStore(key, true); !excluded {
+			c.canaries.Delete(key)
+			c.clusters.Delete(key)
+			c.recordEventWarningf(cd, "Canary %s.%s is not managed, the namespace is outside of the scope %s",
+				cd.Name, cd.Namespace, c.namespaceScope)
+		}
+		return false
+	}
+
+	if _, excluded := c.excluded.LoadAndDelete(key); excluded {
+		c.recordEventInfof(cd, "Canary %s.%s is managed, the namespace is inside of the scope %s",
+			cd.Name, cd.Namespace, c.namespaceScope)
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeNamespaceScope(t *testing.T, path string, data string, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestParseNamespaceScope(t *testing.T) {
+	spec, err := ParseNamespaceScope(strings.NewReader(`
+include:
+- "team-*"
+- default
+exclude:
+- team-legacy
+`))
+	require.NoError(t, err)
+	assert.True(t, spec.Allows("team-a"))
+	assert.True(t, spec.Allows("default"))
+	assert.False(t, spec.Allows("team-legacy"))
+	assert.False(t, spec.Allows("kube-system"))
+
+	// all the namespaces are included by default
+	spec, err = ParseNamespaceScope(strings.NewReader("exclude: [kube-system]\n"))
+	require.NoError(t, err)
+	assert.True(t, spec.Allows("default"))
+	assert.False(t, spec.Allows("kube-system"))
+
+	_, err = ParseNamespaceScope(strings.NewReader("include: [\"team-[\"]\n"))
+	require.Error(t, err)
+}
+
+func TestNamespaceScope_Refresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scope.yaml")
+	now := time.Now()
+	writeNamespaceScope(t, path, "include: [default]\n", now.Add(-time.Minute))
+
+	scope, err := LoadNamespaceScope(path)
+	require.NoError(t, err)
+	assert.True(t, scope.Allows("default"))
+	assert.False(t, scope.Allows("test"))
+
+	changed, err := scope.Refresh()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// the file is read again when it changes
+	writeNamespaceScope(t, path, "include: [default, test]\n", now)
+	changed, err = scope.Refresh()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, scope.Allows("test"))
+	assert.Equal(t, "default, test", scope.String())
+
+	// the previous scope is kept when the file is not valid
+	writeNamespaceScope(t, path, "include: [\"[\"]\n", now.Add(time.Minute))
+	changed, err = scope.Refresh()
+	require.Error(t, err)
+	assert.False(t, changed)
+	assert.True(t, scope.Allows("test"))
+
+	// all the namespaces are managed when the scope is not set
+	var unset *NamespaceScope
+	assert.True(t, unset.Allows("test"))
+}
+
+func TestController_syncHandlerNamespaceScope(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scope.yaml")
+	now := time.Now()
+	writeNamespaceScope(t, path, "exclude: [default]\n", now.Add(-time.Minute))
+	scope, err := LoadNamespaceScope(path)
+	require.NoError(t, err)
+
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.namespaceScope = scope
+	require.NoError(t, mocks.ctrl.syncHandler("default/podinfo"))
+	_, ok := mocks.ctrl.canaries.Load("podinfo.default")
+	assert.False(t, ok)
+
+	// the canaries are synced again when the namespace is onboarded
+	writeNamespaceScope(t, path, "exclude: [kube-system]\n", now)
+	mocks.ctrl.refreshNamespaceScope()
+	require.NoError(t, mocks.ctrl.syncHandler("default/podinfo"))
+	_, ok = mocks.ctrl.canaries.Load("podinfo.default")
+	assert.True(t, ok)
+	_, ok = mocks.ctrl.excluded.Load("podinfo.default")
+	assert.False(t, ok)
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// orphansCheckInterval is the interval between two scans for orphaned objects
+const orphansCheckInterval = 10 * time.Minute
+
+// OrphansPolicy defines how the objects generated for canaries that no longer exist are handled
+type OrphansPolicy string
+
+const (
+	// IgnoreOrphansPolicy disables the scan for orphaned objects
+	IgnoreOrphansPolicy OrphansPolicy = "ignore"
+	// ReportOrphansPolicy emits a warning event for each orphaned object
+	ReportOrphansPolicy OrphansPolicy = "report"
+	// DeleteOrphansPolicy deletes the orphaned objects
+	DeleteOrphansPolicy OrphansPolicy = "delete"
+)
+
+// NewOrphansPolicy validates the orphans policy, an empty value disables the scan
+func NewOrphansPolicy(policy string) (OrphansPolicy, error) {
+	switch OrphansPolicy(policy) {
+	case "", IgnoreOrphansPolicy:
+		return IgnoreOrphansPolicy, nil
+	case ReportOrphansPolicy, DeleteOrphansPolicy:
+		return OrphansPolicy(policy), nil
+	}
+	return "", fmt.Errorf("orphans policy %s is invalid, must be one of ignore, report or delete", policy)
+}
+
+// orphanedObject is a workload, service or autoscaler generated for a canary that no longer exists
+type orphanedObject struct {
+	kind   string
+	object runtime.Object
+	meta   metav1.Object
+	canary string
+	remove func() error
+}
+
+// startOrphansCheck scans for orphaned objects in the background once per check interval,
+// a new scan is not started until the previous one is finished
+func (c *Controller) startOrphansCheck(now time.Time) {
+	if c.orphansPolicy == "" || c.orphansPolicy == IgnoreOrphansPolicy || now.Sub(c.lastOrphansCheck) < orphansCheckInterval {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&c.checkingOrphans, 0, 1) {
+		return
+	}
+	c.lastOrphansCheck = now
+	go func() {
+		defer atomic.StoreInt32(&c.checkingOrphans, 0)
+		c.collectOrphans()
+	}()
+}
+
+// collectOrphans reports or deletes the objects generated for canaries that no longer exist
+func (c *Controller) collectOrphans() {
+	orphans, err := c.findOrphans()
+	if err != nil {
+		c.logger.Errorf("Listing the orphaned objects failed: %v", err)
+	}
+
+	for _, o := range orphans {
+		resource := fmt.Sprintf("%s %s.%s", o.kind, o.meta.GetName(), o.meta.GetNamespace())
+		if c.orphansPolicy != DeleteOrphansPolicy {
+			c.logger.Warnf("%s is orphaned, canary %s.%s not found", resource, o.canary, o.meta.GetNamespace())
+			c.eventRecorder.Eventf(o.object, corev1.EventTypeWarning, "Orphaned",
+				"Canary %s not found, the object can be deleted", o.canary)
+			continue
+		}
+
+		if err := o.remove(); err != nil && !errors.IsNotFound(err) {
+			c.logger.Errorf("Deleting orphaned %s failed: %v", resource, err)
+			continue
+		}
+		c.logger.Infof("Orphaned %s deleted, canary %s.%s not found", resource, o.canary, o.meta.GetNamespace())
+	}
+}
+
+// findOrphans lists the deployments, daemonsets, statefulsets, services and autoscalers
+// generated for canaries that no longer exist
+func (c *Controller) findOrphans() ([]orphanedObject, error) {
+	var orphans []orphanedObject
+	add := func(kind string, obj runtime.Object, meta metav1.Object, remove func(string, string) error) {
+		if name, ok := c.isOrphan(meta); ok {
+			orphans = append(orphans, orphanedObject{kind: kind, object: obj, meta: meta, canary: name,
+				remove: func() error { return remove(meta.GetNamespace(), meta.GetName()) }})
+		}
+	}
+	opts := metav1.DeleteOptions{}
+
+	deployments, err := c.kubeClient.AppsV1().Deployments(c.orphansNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return orphans, fmt.Errorf("deployments list query error: %w", err)
+	}
+	for i := range deployments.Items {
+		add("Deployment", &deployments.Items[i], &deployments.Items[i], func(ns, name string) error {
+			return c.kubeClient.AppsV1().Deployments(ns).Delete(context.TODO(), name, opts)
+		})
+	}
+
+	daemonSets, err := c.kubeClient.AppsV1().DaemonSets(c.orphansNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return orphans, fmt.Errorf("daemonsets list query error: %w", err)
+	}
+	for i := range daemonSets.Items {
+		add("DaemonSet", &daemonSets.Items[i], &daemonSets.Items[i], func(ns, name string) error {
+			return c.kubeClient.AppsV1().DaemonSets(ns).Delete(context.TODO(), name, opts)
+		})
+	}
+
+	statefulSets, err := c.kubeClient.AppsV1().StatefulSets(c.orphansNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return orphans, fmt.Errorf("statefulsets list query error: %w", err)
+	}
+	for i := range statefulSets.Items {
+		add("StatefulSet", &statefulSets.Items[i], &statefulSets.Items[i], func(ns, name string) error {
+			return c.kubeClient.AppsV1().StatefulSets(ns).Delete(context.TODO(), name, opts)
+		})
+	}
+
+	services, err := c.kubeClient.CoreV1().Services(c.orphansNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return orphans, fmt.Errorf("services list query error: %w", err)
+	}
+	for i := range services.Items {
+		add("Service", &services.Items[i], &services.Items[i], func(ns, name string) error {
+			return c.kubeClient.CoreV1().Services(ns).Delete(context.TODO(), name, opts)
+		})
+	}
+
+	hpas, err := c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(c.orphansNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return orphans, fmt.Errorf("autoscalers list query error: %w", err)
+	}
+	for i := range hpas.Items {
+		add("HorizontalPodAutoscaler", &hpas.Items[i], &hpas.Items[i], func(ns, name string) error {
+			return c.kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(ns).Delete(context.TODO(), name, opts)
+		})
+	}
+
+	return orphans, nil
+}
+
+// isOrphan returns the name of the canary that generated the object when the canary no longer exists,
+// the object is generated by a canary if it is controlled by it or if it has the Flagger canary label
+func (c *Controller) isOrphan(obj metav1.Object) (string, bool) {
+	if !c.namespaceScope.Allows(obj.GetNamespace()) {
+		return "", false
+	}
+
+	name := ""
+	if ref := metav1.GetControllerOf(obj); ref != nil {
+		if ref.Kind != flaggerv1.CanaryKind {
+			return "", false
+		}
+		name = ref.Name
+	} else {
+		name = obj.GetLabels()[flaggerv1.CanaryLabel]
+	}
+	if name == "" {
+		return "", false
+	}
+
+	cached, err := c.flaggerInformers.CanaryInformer.Lister().Canaries(obj.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return "", false
+	}
+	for _, cd := range cached {
+		if generatedBy(cd, name) {
+			return "", false
+		}
+	}
+
+	// the informer cache can be stale, the canary is confirmed as deleted with the API
+	list, err := c.flaggerClient.FlaggerV1beta1().Canaries(obj.GetNamespace()).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return "", false
+	}
+	for i := range list.Items {
+		if generatedBy(&list.Items[i], name) {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// generatedBy returns true if the canary name or the canary label value matches the canary
+func generatedBy(cd *flaggerv1.Canary, name string) bool {
+	return cd.Name == name || cd.AuditLabelValue() == name
+}
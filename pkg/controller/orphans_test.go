@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestController_collectOrphans(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	isController := true
+	orphanDep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "orphan-primary",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: flaggerv1.SchemeGroupVersion.String(),
+				Kind:       flaggerv1.CanaryKind,
+				Name:       "orphan",
+				Controller: &isController,
+			}},
+		},
+	}
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Create(context.TODO(), orphanDep, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	orphanSvc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "legacy-primary",
+			Namespace: "default",
+			Labels: map[string]string{
+				flaggerv1.CanaryLabel: "legacy",
+			},
+		},
+	}
+	_, err = mocks.kubeClient.CoreV1().Services("default").Create(context.TODO(), orphanSvc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	orphans, err := mocks.ctrl.findOrphans()
+	require.NoError(t, err)
+	require.Len(t, orphans, 2)
+
+	// report only
+	mocks.ctrl.orphansPolicy = ReportOrphansPolicy
+	mocks.ctrl.collectOrphans()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "orphan-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	mocks.ctrl.orphansPolicy = DeleteOrphansPolicy
+	mocks.ctrl.collectOrphans()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "orphan-primary", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	_, err = mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "legacy-primary", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+
+	// the objects of existing canaries are kept
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	_, err = mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
+func TestNewOrphansPolicy(t *testing.T) {
+	p, err := NewOrphansPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, IgnoreOrphansPolicy, p)
+
+	p, err = NewOrphansPolicy("delete")
+	require.NoError(t, err)
+	assert.Equal(t, DeleteOrphansPolicy, p)
+
+	_, err = NewOrphansPolicy("purge")
+	assert.Error(t, err)
+}
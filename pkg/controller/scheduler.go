@@ -27,7 +27,9 @@ import (
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 	"github.com/fluxcd/flagger/pkg/canary"
+	"github.com/fluxcd/flagger/pkg/cloudevents"
 	"github.com/fluxcd/flagger/pkg/router"
+	"github.com/fluxcd/flagger/pkg/tracing"
 )
 
 func (c *Controller) min(a int, b int) int {
@@ -56,6 +58,14 @@ func (c *Controller) totalWeight(canary *flaggerv1.Canary) int {
 
 func (c *Controller) nextStepWeight(canary *flaggerv1.Canary, canaryWeight int) int {
 	var stepWeightsLen = len(canary.GetAnalysis().StepWeights)
+	if canary.GetAnalysis().StepWeight > 0 && canary.GetAnalysis().Adaptive != nil && canary.Status.AdaptiveStepWeight > 0 {
+		// don't go above the max weight with the adaptive step
+		step := canary.Status.AdaptiveStepWeight
+		if maxStep := c.maxWeight(canary) - canaryWeight; maxStep > 0 && step > maxStep {
+			step = maxStep
+		}
+		return step
+	}
 	if canary.GetAnalysis().StepWeight > 0 || stepWeightsLen == 0 {
 		return canary.GetAnalysis().StepWeight
 	}
@@ -86,6 +96,23 @@ func (c *Controller) nextStepWeight(canary *flaggerv1.Canary, canaryWeight int)
 	return maxStep
 }
 
+// holdStepWeight returns true if the canary weight is one of the step weights
+// and the step duration has not elapsed since the weight was set
+func (c *Controller) holdStepWeight(canary *flaggerv1.Canary, canaryWeight int) bool {
+	duration := canary.GetStepWeightDuration(canaryWeight)
+	if duration == 0 || canary.Status.StepStartTime.IsZero() {
+		return false
+	}
+
+	remaining := duration - time.Since(canary.Status.StepStartTime.Time)
+	if remaining <= 0 {
+		return false
+	}
+	c.recordEventInfof(canary, "Holding %s.%s canary weight %v for %v",
+		canary.Name, canary.Namespace, canaryWeight, remaining.Round(time.Second))
+	return true
+}
+
 // scheduleCanaries synchronises the canary map with the jobs map,
 // for new canaries new jobs are created and started
 // for the removed canaries the jobs are stopped and deleted
@@ -110,10 +137,15 @@ func (c *Controller) scheduleCanaries() {
 			newJob := CanaryJob{
 				Name:             cn.Name,
 				Namespace:        cn.Namespace,
-				function:         c.advanceCanary,
+				function:         c.runCanaryJob,
 				done:             make(chan bool),
 				ticker:           time.NewTicker(cn.GetAnalysisInterval()),
 				analysisInterval: cn.GetAnalysisInterval(),
+				offset:           canaryJobOffset(name, cn.GetAnalysisInterval(), c.queryJitter),
+			}
+			// resume the analysis on the schedule of the previous leader
+			if offset, ok := canaryJobResumeOffset(cn, time.Now()); ok && !exists {
+				newJob.offset = offset
 			}
 
 			c.jobs[name] = newJob
@@ -163,6 +195,40 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 			Errorf("Canary %s.%s not found", name, namespace)
 		return
 	}
+	defer c.flushAuditTrail(name, namespace)
+
+	// trace the analysis run, the span is the parent of the router, metric and webhook spans
+	span := c.tracer.Start("canary.advance",
+		tracing.String("canary.name", name),
+		tracing.String("canary.namespace", namespace),
+		tracing.String("canary.phase", string(cd.Status.Phase)),
+		tracing.String("canary.target", fmt.Sprintf("%s/%s", cd.Spec.TargetRef.Kind, cd.Spec.TargetRef.Name)))
+	if span != nil {
+		c.spans.Store(fmt.Sprintf("%s.%s", name, namespace), span)
+		defer func() {
+			c.spans.Delete(fmt.Sprintf("%s.%s", name, namespace))
+			span.End()
+		}()
+	}
+
+	// merge the analysis template into the canary analysis
+	resolved, err := c.resolveAnalysisTemplate(cd)
+	if err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	cd = resolved
+
+	// the primary objects can't be named when the primary naming templates are invalid
+	if err := cd.ValidatePrimaryNaming(); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+
+	// the A/B testing routes are replaced with weighted routes after the A/B testing iterations
+	if cd.IsProgressiveAfterMatch() {
+		cd.GetAnalysis().Match = nil
+	}
 
 	// override the global provider if one is specified in the canary spec
 	provider := c.meshProvider
@@ -170,8 +236,15 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 		provider = cd.Spec.Provider
 	}
 
+	// init the factories of the cluster running the target workload
+	canaryFactory, routerFactory, err := c.getClusterFactories(cd)
+	if err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+
 	// init controller based on target kind
-	canaryController := c.canaryFactory.Controller(cd.Spec.TargetRef.Kind)
+	canaryController := canaryFactory.Controller(cd.Spec.TargetRef.Kind)
 	labelSelector, labelValue, ports, err := canaryController.GetMetadata(cd)
 	if err != nil {
 		c.recordEventWarningf(cd, "%v", err)
@@ -179,7 +252,13 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 	}
 
 	// init Kubernetes router
-	kubeRouter := c.routerFactory.KubernetesRouter(cd.Spec.TargetRef.Kind, labelSelector, labelValue, ports)
+	kubeRouter := c.faultInjector.KubernetesRouter(routerFactory.KubernetesRouter(cd.Spec.TargetRef.Kind, labelSelector, labelValue, ports))
+	kubeRouter = span.KubernetesRouter(c.recorder.KubernetesRouter(kubeRouter))
+
+	// report the out-of-band changes of the primary and apex services before they are reconciled
+	if ok := c.runServiceDriftCheck(cd, kubeRouter); !ok {
+		return
+	}
 
 	// reconcile the canary/primary services
 	if err := kubeRouter.Initialize(cd); err != nil {
@@ -195,13 +274,13 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 	}
 
 	// init mesh router
-	meshRouter := c.routerFactory.MeshRouter(provider, labelSelector)
+	meshRouter := c.faultInjector.MeshRouter(routerFactory.MeshRouter(provider, labelSelector))
+	meshRouter = span.MeshRouter(c.recorder.MeshRouter(meshRouter))
 
 	// register the AppMesh VirtualNodes before creating the primary deployment
 	// otherwise the pods will not be injected with the Envoy proxy
 	if strings.HasPrefix(provider, flaggerv1.AppMeshProvider) {
-		if err := meshRouter.Reconcile(cd); err != nil {
-			c.recordEventWarningf(cd, "%v", err)
+		if ok := c.reconcileMeshRouter(cd, provider, routerFactory, meshRouter); !ok {
 			return
 		}
 	}
@@ -213,6 +292,11 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 		return
 	}
 
+	// revert or adopt the out-of-band changes of the primary workload
+	if ok := c.runPrimaryDriftCheck(cd, canaryController); !ok {
+		return
+	}
+
 	// change the apex service pod selector to primary
 	if err := kubeRouter.Reconcile(cd); err != nil {
 		c.recordEventWarningf(cd, "%v", err)
@@ -222,8 +306,7 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 	// take over an existing virtual service or ingress
 	// runs after the primary is ready to ensure zero downtime
 	if !strings.HasPrefix(provider, flaggerv1.AppMeshProvider) {
-		if err := meshRouter.Reconcile(cd); err != nil {
-			c.recordEventWarningf(cd, "%v", err)
+		if ok := c.reconcileMeshRouter(cd, provider, routerFactory, meshRouter); !ok {
 			return
 		}
 	}
@@ -236,6 +319,13 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 	}
 
 	if !shouldAdvance {
+		c.runRetry(cd, canaryController)
+		c.recorder.SetStatus(cd, cd.Status.Phase)
+		return
+	}
+
+	// pause the analysis while the canary is suspended
+	if ok := c.runSuspendCheck(cd); !ok {
 		c.recorder.SetStatus(cd, cd.Status.Phase)
 		return
 	}
@@ -264,6 +354,12 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 
 	c.recorder.SetWeight(cd, primaryWeight, canaryWeight)
 
+	// finish the rollback once the canary traffic is drained
+	if cd.Status.RollbackDrain != nil {
+		c.runRollbackDrain(cd, canaryController, meshRouter, canaryWeight)
+		return
+	}
+
 	// check if canary analysis should start (canary revision has changes) or continue
 	if ok := c.checkCanaryStatus(cd, canaryController, shouldAdvance); !ok {
 		return
@@ -312,10 +408,24 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 	if cd.Status.Phase == flaggerv1.CanaryPhaseProgressing ||
 		cd.Status.Phase == flaggerv1.CanaryPhaseWaiting ||
 		cd.Status.Phase == flaggerv1.CanaryPhaseWaitingPromotion {
+		if c.runManualRollbackGate(cd) {
+			c.recordEventWarningf(cd, "Rolling back %s.%s requested with the %s annotation", cd.Name, cd.Namespace, flaggerv1.RollbackAnnotation)
+			c.alert(cd, "Rolling back manual rollback requested", false, flaggerv1.SeverityWarn)
+			c.rollback(cd, canaryController, meshRouter, flaggerv1.ManualRollbackReason)
+			return
+		}
+
 		if ok := c.runRollbackHooks(cd, cd.Status.Phase); ok {
 			c.recordEventWarningf(cd, "Rolling back %s.%s manual webhook invoked", cd.Name, cd.Namespace)
 			c.alert(cd, "Rolling back manual webhook invoked", false, flaggerv1.SeverityWarn)
-			c.rollback(cd, canaryController, meshRouter)
+			c.rollback(cd, canaryController, meshRouter, flaggerv1.WebhookRollbackReason)
+			return
+		}
+
+		if member, failed := c.hasReleaseGroupFailed(cd); failed {
+			c.recordEventWarningf(cd, "Rolling back %s.%s release group canary %s failed", cd.Name, cd.Namespace, member)
+			c.alert(cd, fmt.Sprintf("Rolling back release group canary %s failed", member), false, flaggerv1.SeverityWarn)
+			c.rollback(cd, canaryController, meshRouter, flaggerv1.ReleaseGroupRollbackReason)
 			return
 		}
 	}
@@ -328,6 +438,15 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 
 	// scale canary to zero if promotion has finished
 	if cd.Status.Phase == flaggerv1.CanaryPhaseFinalising {
+		// keep the canary running with no traffic until the scale down delay has passed
+		if delay := cd.GetAnalysisCanaryScaleDownDelay(); delay > 0 {
+			if remaining := delay - time.Since(cd.Status.LastTransitionTime.Time); remaining > 0 {
+				c.canaryLogger(cd).Infof("Delaying the scale down of %s.%s for %v",
+					cd.Spec.TargetRef.Name, cd.Namespace, remaining.Round(time.Second))
+				return
+			}
+		}
+
 		if err := canaryController.ScaleToZero(cd); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
@@ -339,8 +458,15 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 			return
 		}
 		c.recorder.SetStatus(cd, flaggerv1.CanaryPhaseSucceeded)
+		c.recorder.SetPromotionDuration(cd)
 		c.runPostRolloutHooks(cd, flaggerv1.CanaryPhaseSucceeded)
 		c.recordEventInfof(cd, "Promotion completed! Scaling down %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
+		c.auditPhase(cd, flaggerv1.CanaryPhaseSucceeded, "Promotion completed")
+		c.publishCloudEvent(cd, cloudevents.CanaryPromoted, cloudevents.CanaryData{
+			Phase:         string(flaggerv1.CanaryPhaseSucceeded),
+			PrimaryWeight: c.totalWeight(cd),
+			Message:       "Canary analysis completed successfully, promotion finished.",
+		})
 		c.alert(cd, "Canary analysis completed successfully, promotion finished.",
 			false, flaggerv1.SeverityInfo)
 		return
@@ -349,16 +475,62 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 	// check if the number of failed checks reached the threshold
 	if (cd.Status.Phase == flaggerv1.CanaryPhaseProgressing || cd.Status.Phase == flaggerv1.CanaryPhaseWaitingPromotion) &&
 		(!retriable || cd.Status.FailedChecks >= cd.GetAnalysisThreshold()) {
+		reason := flaggerv1.FailedChecksRollbackReason
 		if !retriable {
+			reason = flaggerv1.ProgressDeadlineRollbackReason
 			c.recordEventWarningf(cd, "Rolling back %s.%s progress deadline exceeded %v",
 				cd.Name, cd.Namespace, err)
 			c.alert(cd, fmt.Sprintf("Progress deadline exceeded %v", err),
 				false, flaggerv1.SeverityError)
 		}
-		c.rollback(cd, canaryController, meshRouter)
+		c.rollback(cd, canaryController, meshRouter, reason)
 		return
 	}
 
+	// check if a metric with its own failure threshold reached it
+	if cd.Status.Phase == flaggerv1.CanaryPhaseProgressing || cd.Status.Phase == flaggerv1.CanaryPhaseWaitingPromotion {
+		if metric, threshold, failed := getFailedMetricThreshold(cd); failed {
+			c.recordEventWarningf(cd, "Rolling back %s.%s metric %s failed checks threshold reached %v",
+				cd.Name, cd.Namespace, metric, threshold)
+			c.rollback(cd, canaryController, meshRouter, flaggerv1.FailedChecksRollbackReason)
+			return
+		}
+	}
+
+	// roll back or promote the analysis that is still running after the max duration
+	if cd.Status.Phase == flaggerv1.CanaryPhaseProgressing || cd.Status.Phase == flaggerv1.CanaryPhaseWaitingPromotion {
+		if ok := c.runMaxDurationCheck(cd, canaryController, meshRouter); !ok {
+			return
+		}
+	}
+
+	// pause the analysis outside the traffic windows
+	if cd.Status.Phase == flaggerv1.CanaryPhaseProgressing {
+		if ok := c.runTrafficWindowCheck(cd, meshRouter, canaryWeight, mirrored); !ok {
+			return
+		}
+	}
+
+	// hold or roll back the analysis outside the progression windows
+	holdProgression := false
+	if cd.Status.Phase == flaggerv1.CanaryPhaseProgressing || cd.Status.Phase == flaggerv1.CanaryPhaseWaitingPromotion {
+		hold, ok := c.runProgressionWindowCheck(cd, canaryController, meshRouter, canaryWeight)
+		if !ok {
+			return
+		}
+		holdProgression = hold
+	}
+
+	// hold the analysis during the change freeze windows
+	freezeWindow := ""
+	if cd.Status.Phase == flaggerv1.CanaryPhaseProgressing || cd.Status.Phase == flaggerv1.CanaryPhaseWaitingPromotion {
+		freezeWindow = c.activeFreezeWindow(cd)
+		if freezeWindow != "" && canaryWeight == 0 && cd.Status.Iterations == 0 {
+			c.recordEventInfof(cd, "Halt %s.%s analysis start during the freeze window %s", cd.Name, cd.Namespace, freezeWindow)
+			return
+		}
+	}
+
 	// record analysis duration
 	defer func() {
 		c.recorder.SetDuration(cd, time.Since(begin))
@@ -367,7 +539,7 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 	// check if the canary success rate is above the threshold
 	// skip check if no traffic is routed or mirrored to canary
 	if canaryWeight == 0 && cd.Status.Iterations == 0 &&
-		!(cd.GetAnalysis().Mirror && mirrored) {
+		!(cd.IsMirrorIteration(cd.Status.Iterations) && mirrored) {
 		c.recordEventInfof(cd, "Starting canary analysis for %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
 
 		// run pre-rollout web hooks
@@ -378,14 +550,46 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 			return
 		}
 	} else {
-		if ok := c.runAnalysis(cd); !ok {
-			if err := canaryController.SetStatusFailedChecks(cd, cd.Status.FailedChecks+1); err != nil {
-				c.recordEventWarningf(cd, "%v", err)
+		// a metric in the warning range or a metric failure counted against its own threshold
+		// halts the advancement without counting as a failed check
+		if ok, held := c.runAnalysis(cd, canaryController); !ok {
+			if !held {
+				if err := canaryController.SetStatusFailedChecks(cd, cd.Status.FailedChecks+1); err != nil {
+					c.recordEventWarningf(cd, "%v", err)
+				}
+			}
+			return
+		}
+
+		// a marginal Kayenta score halts the advancement without counting as a failed check
+		if ok, marginal := c.runKayentaCheck(cd); !ok {
+			if !marginal {
+				if err := canaryController.SetStatusFailedChecks(cd, cd.Status.FailedChecks+1); err != nil {
+					c.recordEventWarningf(cd, "%v", err)
+				}
 			}
 			return
 		}
 	}
 
+	// the checks have passed but the canary can't advance outside the progression windows
+	if holdProgression {
+		c.recordEventInfof(cd, "Halt %s.%s advancement outside the progression windows", cd.Name, cd.Namespace)
+		return
+	}
+
+	// the checks have passed but the canary can't advance during a freeze window
+	if freezeWindow != "" {
+		c.recordEventInfof(cd, "Halt %s.%s advancement during the freeze window %s", cd.Name, cd.Namespace, freezeWindow)
+		return
+	}
+
+	// run the checks without shifting the traffic or promoting the canary
+	if cd.GetAnalysis().DryRun {
+		c.runDryRun(cd, canaryController, meshRouter, provider, mirrored)
+		return
+	}
+
 	// use blue/green strategy for kubernetes provider
 	if provider == flaggerv1.KubernetesProvider {
 		if len(cd.GetAnalysis().Match) > 0 {
@@ -399,20 +603,38 @@ func (c *Controller) advanceCanary(name string, namespace string) {
 		}
 	}
 
+	// run a single analysis step for the revisions taking the fast path
+	if cd.IsFastPathRevision() && cd.GetFastPathPolicy() == flaggerv1.ShortAnalysisFastPathPolicy {
+		shortenAnalysis(cd, maxWeight)
+	}
+
 	// strategy: A/B testing
 	if len(cd.GetAnalysis().Match) > 0 && cd.GetAnalysis().Iterations > 0 {
+		// switch to the progressive traffic increase after the A/B testing iterations
+		if cd.GetAnalysis().ProgressiveAfterMatch && cd.Status.Iterations >= cd.GetAnalysis().Iterations {
+			c.startProgressiveAfterMatch(cd, canaryController, meshRouter)
+			return
+		}
 		c.runAB(cd, canaryController, meshRouter)
 		return
 	}
 
 	// strategy: Blue/Green
-	if cd.GetAnalysis().Iterations > 0 {
+	if cd.GetAnalysis().Iterations > 0 && !cd.IsProgressiveAfterMatch() {
 		c.runBlueGreen(cd, canaryController, meshRouter, provider, mirrored)
 		return
 	}
 
 	// strategy: Canary progressive traffic increase
 	if c.nextStepWeight(cd, canaryWeight) > 0 {
+		// hold the current step weight for its duration
+		if hold := c.holdStepWeight(cd, canaryWeight); hold {
+			return
+		}
+		// hold the current step weight until the lockstep release group canaries catch up
+		if hold := c.holdReleaseGroupWeight(cd, canaryWeight); hold {
+			return
+		}
 		// run hook only if traffic is not mirrored
 		if !mirrored {
 			if promote := c.runConfirmTrafficIncreaseHooks(cd); !promote {
@@ -464,6 +686,12 @@ func (c *Controller) runPromotionTrafficShift(canary *flaggerv1.Canary, canaryCo
 		}
 		c.recorder.SetWeight(canary, primaryWeight, canaryWeight)
 		c.recordEventInfof(canary, "Advance %s.%s primary weight %v", canary.Name, canary.Namespace, primaryWeight)
+		c.auditWeight(canary, canaryWeight, fmt.Sprintf("Advance primary weight %v", primaryWeight))
+		c.publishCloudEvent(canary, cloudevents.CanaryWeightChanged, cloudevents.CanaryData{
+			CanaryWeight:  canaryWeight,
+			PrimaryWeight: primaryWeight,
+			Message:       fmt.Sprintf("Advance primary weight %v", primaryWeight),
+		})
 
 		// finalize promotion
 		if primaryWeight == c.totalWeight(canary) {
@@ -483,7 +711,11 @@ func (c *Controller) runPromotionTrafficShift(canary *flaggerv1.Canary, canaryCo
 
 func (c *Controller) runCanary(canary *flaggerv1.Canary, canaryController canary.Controller,
 	meshRouter router.Interface, mirrored bool, canaryWeight int, primaryWeight int, maxWeight int) {
-	primaryName := fmt.Sprintf("%s-primary", canary.Spec.TargetRef.Name)
+	primaryName, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
 
 	// increase traffic weight
 	if canaryWeight < maxWeight {
@@ -503,8 +735,7 @@ func (c *Controller) runCanary(canary *flaggerv1.Canary, canaryController canary
 				primaryWeight = c.totalWeight(canary) - nextStepWeight
 				canaryWeight = nextStepWeight
 			}
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
-				Infof("Running mirror step %d/%d/%t", primaryWeight, canaryWeight, mirrored)
+			c.canaryLogger(canary).Infof("Running mirror step %d/%d/%t", primaryWeight, canaryWeight, mirrored)
 		} else {
 
 			primaryWeight -= nextStepWeight
@@ -529,6 +760,12 @@ func (c *Controller) runCanary(canary *flaggerv1.Canary, canaryController canary
 
 		c.recorder.SetWeight(canary, primaryWeight, canaryWeight)
 		c.recordEventInfof(canary, "Advance %s.%s canary weight %v", canary.Name, canary.Namespace, canaryWeight)
+		c.auditWeight(canary, canaryWeight, fmt.Sprintf("Advance canary weight %v", canaryWeight))
+		c.publishCloudEvent(canary, cloudevents.CanaryWeightChanged, cloudevents.CanaryData{
+			CanaryWeight:  canaryWeight,
+			PrimaryWeight: primaryWeight,
+			Message:       fmt.Sprintf("Advance canary weight %v", canaryWeight),
+		})
 		return
 	}
 
@@ -552,12 +789,17 @@ func (c *Controller) runCanary(canary *flaggerv1.Canary, canaryController canary
 			c.recordEventWarningf(canary, "%v", err)
 			return
 		}
+		c.auditPhase(canary, flaggerv1.CanaryPhasePromoting, "Analysis succeeded, promotion started")
 	}
 }
 
 func (c *Controller) runAB(canary *flaggerv1.Canary, canaryController canary.Controller,
 	meshRouter router.Interface) {
-	primaryName := fmt.Sprintf("%s-primary", canary.Spec.TargetRef.Name)
+	primaryName, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
 
 	// route traffic to canary and increment iterations
 	if canary.GetAnalysis().Iterations > canary.Status.Iterations {
@@ -595,23 +837,51 @@ func (c *Controller) runAB(canary *flaggerv1.Canary, canaryController canary.Con
 			c.recordEventWarningf(canary, "%v", err)
 			return
 		}
+		c.auditPhase(canary, flaggerv1.CanaryPhasePromoting, "Analysis succeeded, promotion started")
 	}
 }
 
+// startProgressiveAfterMatch routes all users to the primary and starts the progressive
+// traffic increase, the iterations are incremented past the A/B testing ones to mark the switch
+func (c *Controller) startProgressiveAfterMatch(canary *flaggerv1.Canary, canaryController canary.Controller,
+	meshRouter router.Interface) {
+	canary.GetAnalysis().Match = nil
+	if err := meshRouter.SetRoutes(canary, c.totalWeight(canary), 0, false); err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
+	c.recorder.SetWeight(canary, c.totalWeight(canary), 0)
+
+	if err := canaryController.SetStatusWeight(canary, 0); err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
+	if err := canaryController.SetStatusIterations(canary, canary.Status.Iterations+1); err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
+	c.recordEventInfof(canary, "A/B testing passed, starting the progressive traffic increase for %s.%s",
+		canary.Name, canary.Namespace)
+}
+
 func (c *Controller) runBlueGreen(canary *flaggerv1.Canary, canaryController canary.Controller,
 	meshRouter router.Interface, provider string, mirrored bool) {
-	primaryName := fmt.Sprintf("%s-primary", canary.Spec.TargetRef.Name)
+	primaryName, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
 
 	// increment iterations
 	if canary.GetAnalysis().Iterations > canary.Status.Iterations {
 		// If in "mirror" mode, mirror requests during the entire B/G canary test
+		// or during the warm-up iterations before the cutover
 		if provider != "kubernetes" &&
-			canary.GetAnalysis().Mirror && !mirrored {
+			canary.IsMirrorIteration(canary.Status.Iterations) && !mirrored {
 			if err := meshRouter.SetRoutes(canary, c.totalWeight(canary), 0, true); err != nil {
 				c.recordEventWarningf(canary, "%v", err)
 			}
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
-				Infof("Start traffic mirroring")
+			c.canaryLogger(canary).Infof("Start traffic mirroring")
 		}
 		if err := canaryController.SetStatusIterations(canary, canary.Status.Iterations+1); err != nil {
 			c.recordEventWarningf(canary, "%v", err)
@@ -630,7 +900,7 @@ func (c *Controller) runBlueGreen(canary *flaggerv1.Canary, canaryController can
 	// route all traffic to canary - max iterations reached
 	if canary.GetAnalysis().Iterations == canary.Status.Iterations {
 		if provider != "kubernetes" {
-			if canary.GetAnalysis().Mirror {
+			if mirrored {
 				c.recordEventInfof(canary, "Stop traffic mirroring and route all traffic to canary")
 			} else {
 				c.recordEventInfof(canary, "Routing all traffic to canary")
@@ -664,47 +934,145 @@ func (c *Controller) runBlueGreen(canary *flaggerv1.Canary, canaryController can
 			c.recordEventWarningf(canary, "%v", err)
 			return
 		}
+		c.auditPhase(canary, flaggerv1.CanaryPhasePromoting, "Analysis succeeded, promotion started")
 	}
 
 }
 
-func (c *Controller) runAnalysis(canary *flaggerv1.Canary) bool {
+// runAnalysis runs the webhooks and metric checks, the advancement is halted without
+// counting a failed check when a metric is outside its warning range
+func (c *Controller) runAnalysis(canary *flaggerv1.Canary, canaryController canary.Controller) (ok bool, held bool) {
+	run := newAnalysisRun()
+	defer c.setFailureStatus(canary, canaryController, run)
+
 	// run external checks
 	for _, webhook := range canary.GetAnalysis().Webhooks {
 		if webhook.Type == "" || webhook.Type == flaggerv1.RolloutHook {
-			err := CallWebhook(canary.Name, canary.Namespace, flaggerv1.CanaryPhaseProgressing, webhook)
+			err := c.runWebhook(canary, flaggerv1.CanaryPhaseProgressing, webhook)
 			if err != nil {
+				run.failWebhook(webhook.Name, err)
 				c.recordEventWarningf(canary, "Halt %s.%s advancement external check %s failed %v",
 					canary.Name, canary.Namespace, webhook.Name, err)
-				return false
+				return false, false
 			}
 		}
 	}
 
-	ok := c.runBuiltinMetricChecks(canary)
+	defer c.setMetricStatus(canary, canaryController, run)
+
+	// all the metrics are checked so that each failure is counted against its own threshold
+	ok = c.runBuiltinMetricChecks(canary, run)
+	ok = c.runMetricChecks(canary, run) && ok
+	counted := countMetricFailedChecks(canary, ok, run)
 	if !ok {
-		return ok
+		return false, counted
+	}
+
+	if len(run.warnings) > 0 {
+		c.holdOnMetricWarnings(canary, run.warnings)
+		return false, true
+	}
+
+	c.runAdaptiveStepWeight(canary, run.headroom)
+	return true, false
+}
+
+// runTrafficWindowCheck routes all traffic to primary when the time is outside the canary traffic windows
+// and returns false to pause the analysis until the next window starts. The canary weight and the iterations
+// are kept in status, when the next window starts the traffic is routed back to canary at the held weight
+// and the analysis resumes on the next run.
+func (c *Controller) runTrafficWindowCheck(canary *flaggerv1.Canary, meshRouter router.Interface,
+	canaryWeight int, mirrored bool) bool {
+	ok, err := canary.InTrafficWindow(time.Now())
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return false
 	}
 
-	ok = c.runMetricChecks(canary)
 	if !ok {
-		return ok
+		if canaryWeight > 0 || mirrored {
+			c.recordEventInfof(canary, "Outside traffic windows, routing all traffic to primary %s.%s, holding canary weight %v",
+				canary.Name, canary.Namespace, canary.Status.CanaryWeight)
+			if err := meshRouter.SetRoutes(canary, c.totalWeight(canary), 0, false); err != nil {
+				c.recordEventWarningf(canary, "%v", err)
+				return false
+			}
+			c.recorder.SetWeight(canary, c.totalWeight(canary), 0)
+		}
+		return false
 	}
 
+	// route the traffic back to canary if the analysis was paused at a non-zero weight
+	if heldWeight := canary.Status.CanaryWeight; heldWeight > 0 && canaryWeight == 0 && !mirrored {
+		c.recordEventInfof(canary, "Inside traffic windows, resuming %s.%s analysis at canary weight %v",
+			canary.Name, canary.Namespace, heldWeight)
+		primaryWeight := c.totalWeight(canary) - heldWeight
+		if err := meshRouter.SetRoutes(canary, primaryWeight, heldWeight, false); err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+			return false
+		}
+		c.recorder.SetWeight(canary, primaryWeight, heldWeight)
+		return false
+	}
 	return true
 }
 
+// runProgressionWindowCheck returns hold set to true when the time is outside the progression windows
+// and the analysis has started, so that the checks are run without advancing the canary.
+// It returns ok set to false if the analysis hasn't started yet or if the canary was rolled back
+// according to the Rollback policy
+func (c *Controller) runProgressionWindowCheck(canary *flaggerv1.Canary, canaryController canary.Controller,
+	meshRouter router.Interface, canaryWeight int) (hold bool, ok bool) {
+	inWindow, err := canary.InProgressionWindow(time.Now())
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return false, false
+	}
+	if inWindow {
+		return false, true
+	}
+
+	if canaryWeight == 0 && canary.Status.Iterations == 0 {
+		c.recordEventInfof(canary, "Halt %s.%s analysis start outside the progression windows", canary.Name, canary.Namespace)
+		return false, false
+	}
+
+	if canary.GetAnalysis().ProgressionWindowPolicy == flaggerv1.RollbackProgressionWindowPolicy {
+		c.recordEventWarningf(canary, "Rolling back %s.%s outside the progression windows", canary.Name, canary.Namespace)
+		c.alert(canary, "Rolling back outside the progression windows", false, flaggerv1.SeverityWarn)
+		c.rollback(canary, canaryController, meshRouter, flaggerv1.ProgressionWindowRollbackReason)
+		return false, false
+	}
+	return true, true
+}
+
 func (c *Controller) shouldSkipAnalysis(canary *flaggerv1.Canary, canaryController canary.Controller, meshRouter router.Interface, err error, retriable bool) bool {
 	if !canary.SkipAnalysis() {
-		return false
+		if canary.IsFastPathRevision() && canary.GetFastPathPolicy() == flaggerv1.PromoteFastPathPolicy {
+			c.recordEventInfof(canary, "Skipping analysis for %s.%s revision only changes the fast path fields %v",
+				canary.Spec.TargetRef.Name, canary.Namespace, canary.GetFastPathFields())
+		} else {
+			rule := c.matchSkipAnalysisRules(canary, canaryController)
+			if rule == nil {
+				return false
+			}
+			c.recordEventInfof(canary, "Skipping analysis for %s.%s revision matches the rule %s",
+				canary.Spec.TargetRef.Name, canary.Namespace, rule)
+		}
 	}
 
 	// regardless if analysis is being skipped, rollback if canary failed to progress
 	if !retriable {
 		c.recordEventWarningf(canary, "Rolling back %s.%s progress deadline exceeded %v", canary.Name, canary.Namespace, err)
 		c.alert(canary, fmt.Sprintf("Progress deadline exceeded %v", err), false, flaggerv1.SeverityError)
-		c.rollback(canary, canaryController, meshRouter)
+		c.rollback(canary, canaryController, meshRouter, flaggerv1.ProgressDeadlineRollbackReason)
+
+		return true
+	}
 
+	// defer the promotion until the freeze window ends
+	if window := c.activeFreezeWindow(canary); window != "" {
+		c.recordEventInfof(canary, "Halt %s.%s promotion during the freeze window %s", canary.Name, canary.Namespace, window)
 		return true
 	}
 
@@ -718,8 +1086,13 @@ func (c *Controller) shouldSkipAnalysis(canary *flaggerv1.Canary, canaryControll
 	c.recorder.SetWeight(canary, primaryWeight, canaryWeight)
 
 	// copy spec and configs from canary to primary
-	c.recordEventInfof(canary, "Copying %s.%s template spec to %s-primary.%s",
-		canary.Spec.TargetRef.Name, canary.Namespace, canary.Spec.TargetRef.Name, canary.Namespace)
+	primaryName, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return true
+	}
+	c.recordEventInfof(canary, "Copying %s.%s template spec to %s.%s",
+		canary.Spec.TargetRef.Name, canary.Namespace, primaryName, canary.Namespace)
 	if err := canaryController.Promote(canary); err != nil {
 		c.recordEventWarningf(canary, "%v", err)
 		return true
@@ -747,6 +1120,33 @@ func (c *Controller) shouldSkipAnalysis(canary *flaggerv1.Canary, canaryControll
 	return true
 }
 
+// matchSkipAnalysisRules returns the skip analysis rule matched by the pod template of the canary,
+// the rules are evaluated only while the canary is being analysed
+func (c *Controller) matchSkipAnalysisRules(canary *flaggerv1.Canary, canaryController canary.Controller) *flaggerv1.SkipAnalysisRule {
+	if len(canary.Spec.SkipAnalysisRules) == 0 {
+		return nil
+	}
+	if canary.Status.Phase != flaggerv1.CanaryPhaseProgressing && canary.Status.Phase != flaggerv1.CanaryPhaseWaitingPromotion {
+		return nil
+	}
+
+	template, err := canaryController.GetPodTemplate(canary)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return nil
+	}
+	if template == nil {
+		return nil
+	}
+
+	rule, err := canary.MatchSkipAnalysisRules(*template)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return nil
+	}
+	return rule
+}
+
 func (c *Controller) shouldAdvance(canary *flaggerv1.Canary, canaryController canary.Controller) (bool, error) {
 	if canary.Status.LastAppliedSpec == "" ||
 		canary.Status.Phase == flaggerv1.CanaryPhaseInitializing ||
@@ -758,10 +1158,15 @@ func (c *Controller) shouldAdvance(canary *flaggerv1.Canary, canaryController ca
 		return true, nil
 	}
 
+	// start the canary release of a revision that passed the dry run once the dry run is turned off
+	if canary.Status.Phase == flaggerv1.CanaryPhaseDryRunSucceeded && !canary.GetAnalysis().DryRun {
+		return true, nil
+	}
+
 	// Make sure to sync lastAppliedSpec even if the canary is in a failed state.
 	if canary.Status.Phase == flaggerv1.CanaryPhaseFailed {
 		if err := canaryController.SyncStatus(canary, canary.Status); err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).Errorf("%v", err)
+			c.canaryLogger(canary).Errorf("%v", err)
 			return false, err
 		}
 	}
@@ -795,17 +1200,22 @@ func (c *Controller) checkCanaryStatus(canary *flaggerv1.Canary, canaryControlle
 	var err error
 	canary, err = c.flaggerClient.FlaggerV1beta1().Canaries(canary.Namespace).Get(context.TODO(), canary.Name, metav1.GetOptions{})
 	if err != nil {
-		c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).Errorf("%v", err)
+		c.canaryLogger(canary).Errorf("%v", err)
 		return false
 	}
 
 	if canary.Status.Phase == "" || canary.Status.Phase == flaggerv1.CanaryPhaseInitializing {
 		if err := canaryController.SyncStatus(canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseInitialized}); err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).Errorf("%v", err)
+			c.canaryLogger(canary).Errorf("%v", err)
 			return false
 		}
 		c.recorder.SetStatus(canary, flaggerv1.CanaryPhaseInitialized)
 		c.recordEventInfof(canary, "Initialization done! %s.%s", canary.Name, canary.Namespace)
+		c.publishCloudEvent(canary, cloudevents.CanaryInitialized, cloudevents.CanaryData{
+			Phase:         string(flaggerv1.CanaryPhaseInitialized),
+			PrimaryWeight: c.totalWeight(canary),
+			Message:       fmt.Sprintf("New %s detected, initialization completed.", canary.Spec.TargetRef.Kind),
+		})
 		c.alert(canary, fmt.Sprintf("New %s detected, initialization completed.", canary.Spec.TargetRef.Kind),
 			true, flaggerv1.SeverityInfo)
 		return false
@@ -823,7 +1233,7 @@ func (c *Controller) checkCanaryStatus(canary *flaggerv1.Canary, canaryControlle
 			return false
 		}
 		if err := canaryController.SyncStatus(canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing}); err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).Errorf("%v", err)
+			c.canaryLogger(canary).Errorf("%v", err)
 			return false
 		}
 		c.recorder.SetStatus(canary, flaggerv1.CanaryPhaseProgressing)
@@ -845,7 +1255,65 @@ func (c *Controller) hasCanaryRevisionChanged(canary *flaggerv1.Canary, canaryCo
 	return false
 }
 
-func (c *Controller) rollback(canary *flaggerv1.Canary, canaryController canary.Controller, meshRouter router.Interface) {
+// runPrimaryDriftCheck applies the conflict policy to the out-of-band changes of the primary workload
+func (c *Controller) runPrimaryDriftCheck(canary *flaggerv1.Canary, canaryController canary.Controller) bool {
+	if canary.Spec.ConflictPolicy == "" {
+		return true
+	}
+
+	drifted, err := canaryController.SyncPrimaryDrift(canary)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return false
+	}
+	if !drifted {
+		return true
+	}
+
+	primaryName, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return false
+	}
+	switch canary.Spec.ConflictPolicy {
+	case flaggerv1.AdoptConflictPolicy:
+		c.recordEventInfof(canary, "Adopted the out-of-band changes of %s.%s", primaryName, canary.Namespace)
+	case flaggerv1.RevertWithWarningConflictPolicy:
+		c.recordEventWarningf(canary, "Reverted the out-of-band changes of %s.%s", primaryName, canary.Namespace)
+	default:
+		c.canaryLogger(canary).Infof("Reverted the out-of-band changes of %s.%s", primaryName, canary.Namespace)
+	}
+	return true
+}
+
+// runServiceDriftCheck reports the out-of-band changes of the services selector and ports,
+// the services are reverted by the Kubernetes router as the traffic routing relies on them
+func (c *Controller) runServiceDriftCheck(canary *flaggerv1.Canary, kubeRouter router.KubernetesRouter) bool {
+	if canary.Spec.ConflictPolicy == "" {
+		return true
+	}
+
+	drifted, err := kubeRouter.GetServiceDrift(canary)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return false
+	}
+	for _, name := range drifted {
+		switch canary.Spec.ConflictPolicy {
+		case flaggerv1.AdoptConflictPolicy:
+			c.recordEventWarningf(canary, "Reverted the out-of-band changes of service %s.%s, the service selector and ports can't be adopted",
+				name, canary.Namespace)
+		case flaggerv1.RevertWithWarningConflictPolicy:
+			c.recordEventWarningf(canary, "Reverted the out-of-band changes of service %s.%s", name, canary.Namespace)
+		default:
+			c.canaryLogger(canary).Infof("Reverted the out-of-band changes of service %s.%s", name, canary.Namespace)
+		}
+	}
+	return true
+}
+
+func (c *Controller) rollback(canary *flaggerv1.Canary, canaryController canary.Controller, meshRouter router.Interface,
+	reason flaggerv1.RollbackReason) {
 	if canary.Status.FailedChecks >= canary.GetAnalysisThreshold() {
 		c.recordEventWarningf(canary, "Rolling back %s.%s failed checks threshold reached %v",
 			canary.Name, canary.Namespace, canary.Status.FailedChecks)
@@ -853,6 +1321,23 @@ func (c *Controller) rollback(canary *flaggerv1.Canary, canaryController canary.
 			false, flaggerv1.SeverityError)
 	}
 
+	// restore the primary to the last promoted revision before routing the traffic back
+	if canary.GetAnalysis().RollbackPolicy == flaggerv1.PinnedRollbackPolicy {
+		restored, err := canaryController.RestorePromoted(canary)
+		if err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+			return
+		}
+		if restored {
+			if primaryName, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name); err != nil {
+				c.recordEventWarningf(canary, "%v", err)
+			} else {
+				c.recordEventWarningf(canary, "Restoring %s.%s to the last promoted revision %s",
+					primaryName, canary.Namespace, canary.Status.LastPromotedSpec)
+			}
+		}
+	}
+
 	// route all traffic back to primary
 	primaryWeight := c.totalWeight(canary)
 	canaryWeight := 0
@@ -861,27 +1346,100 @@ func (c *Controller) rollback(canary *flaggerv1.Canary, canaryController canary.
 		return
 	}
 
+	c.recorder.SetWeight(canary, primaryWeight, canaryWeight)
+
+	// wait for the in-flight requests to complete before removing the canary pods,
+	// the rollback is finished by a later run once the drain period has passed
+	if drainPeriod := canary.GetAnalysisRollbackDrainPeriod(); drainPeriod > 0 {
+		if err := c.setRollbackDrain(canary, &flaggerv1.CanaryRollbackDrain{Reason: reason, StartTime: metav1.Now()}); err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+			return
+		}
+		c.recordEventInfof(canary, "Draining %s.%s for %v", canary.Name, canary.Namespace, drainPeriod)
+		return
+	}
+
+	c.finishRollback(canary, canaryController, reason)
+}
+
+// finishRollback scales the canary to zero and marks it as failed
+func (c *Controller) finishRollback(canary *flaggerv1.Canary, canaryController canary.Controller, reason flaggerv1.RollbackReason) {
+	rollback := newRollbackPayload(canary, reason)
+	primaryWeight := c.totalWeight(canary)
+
 	canaryPhaseFailed := canary.DeepCopy()
 	canaryPhaseFailed.Status.Phase = flaggerv1.CanaryPhaseFailed
 	c.recordEventWarningf(canaryPhaseFailed, "Canary failed! Scaling down %s.%s",
 		canaryPhaseFailed.Name, canaryPhaseFailed.Namespace)
 
-	c.recorder.SetWeight(canary, primaryWeight, canaryWeight)
-
 	// shutdown canary
 	if err := canaryController.ScaleToZero(canary); err != nil {
 		c.recordEventWarningf(canary, "%v", err)
 		return
 	}
 
-	// mark canary as failed
-	if err := canaryController.SyncStatus(canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseFailed, CanaryWeight: 0}); err != nil {
-		c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).Errorf("%v", err)
+	// mark canary as failed and schedule a retry if the analysis or a metric reached its failed checks threshold,
+	// the reason is kept across the rollback drain while the failed checks may have been reset
+	status := flaggerv1.CanaryStatus{
+		Phase:         flaggerv1.CanaryPhaseFailed,
+		CanaryWeight:  0,
+		RetryAttempts: canary.Status.RetryAttempts,
+	}
+	retryAnalysis := reason == flaggerv1.FailedChecksRollbackReason &&
+		canary.Status.RetryAttempts < canary.GetAnalysis().Retries
+	if retryAnalysis {
+		status.NextRetryTime = metav1.NewTime(time.Now().Add(canary.GetAnalysisRetryInterval()))
+	}
+	if err := canaryController.SyncStatus(canary, status); err != nil {
+		c.canaryLogger(canary).Errorf("%v", err)
 		return
 	}
 
 	c.recorder.SetStatus(canary, flaggerv1.CanaryPhaseFailed)
 	c.runPostRolloutHooks(canary, flaggerv1.CanaryPhaseFailed)
+	c.runPostRollbackHooks(canary, rollback)
+	c.auditPhase(canary, flaggerv1.CanaryPhaseFailed, fmt.Sprintf("Rolled back, reason %s", rollback.Reason))
+	c.publishCloudEvent(canary, cloudevents.CanaryRolledBack, cloudevents.CanaryData{
+		Phase:         string(flaggerv1.CanaryPhaseFailed),
+		PrimaryWeight: primaryWeight,
+		Reason:        string(rollback.Reason),
+		Message:       fmt.Sprintf("Canary analysis failed, %s scaled to zero.", canary.Spec.TargetRef.Kind),
+	})
+
+	if retryAnalysis {
+		c.recordEventInfof(canary, "Retrying %s.%s analysis in %v, attempt %v/%v",
+			canary.Name, canary.Namespace, canary.GetAnalysisRetryInterval(),
+			canary.Status.RetryAttempts+1, canary.GetAnalysis().Retries)
+	}
+}
+
+// runRetry restarts the analysis of a failed canary when the retry time has passed,
+// the canary is scaled up and the analysis starts from the first step
+func (c *Controller) runRetry(canary *flaggerv1.Canary, canaryController canary.Controller) {
+	if canary.Status.Phase != flaggerv1.CanaryPhaseFailed || canary.Status.NextRetryTime.IsZero() ||
+		time.Now().Before(canary.Status.NextRetryTime.Time) {
+		return
+	}
+
+	attempt := canary.Status.RetryAttempts + 1
+	c.recordEventInfof(canary, "Retrying %s.%s analysis, attempt %v/%v! Scaling up %s.%s",
+		canary.Name, canary.Namespace, attempt, canary.GetAnalysis().Retries, canary.Spec.TargetRef.Name, canary.Namespace)
+	c.alert(canary, fmt.Sprintf("Retrying canary analysis, attempt %v/%v.", attempt, canary.GetAnalysis().Retries),
+		true, flaggerv1.SeverityInfo)
+
+	if err := canaryController.ScaleFromZero(canary); err != nil {
+		c.recordEventErrorf(canary, "%v", err)
+		return
+	}
+	status := flaggerv1.CanaryStatus{
+		Phase:         flaggerv1.CanaryPhaseProgressing,
+		RetryAttempts: attempt,
+	}
+	if err := canaryController.SyncStatus(canary, status); err != nil {
+		c.canaryLogger(canary).Errorf("%v", err)
+		return
+	}
+	c.recorder.SetStatus(canary, flaggerv1.CanaryPhaseProgressing)
 }
 
 func (c *Controller) setPhaseInitializing(cd *flaggerv1.Canary) error {
@@ -901,7 +1459,8 @@ func (c *Controller) setPhaseInitializing(cd *flaggerv1.Canary) error {
 			cdCopy.Status.Conditions = conditions
 			cdCopy.Status.LastTransitionTime = metav1.Now()
 			cdCopy.Status.Phase = phase
-			_, err = c.flaggerClient.FlaggerV1beta1().Canaries(cd.Namespace).UpdateStatus(context.TODO(), cdCopy, metav1.UpdateOptions{})
+			cdCopy.Status.ObservedGeneration = cd.Generation
+			_, err = c.flaggerClient.FlaggerV1beta1().Canaries(cd.Namespace).UpdateStatus(context.TODO(), cdCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
 		}
 		firstTry = false
 		return
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// defaultAdaptiveHeadroom is the default target headroom in percent of the adaptive progression
+const defaultAdaptiveHeadroom = 50
+
+// metricHeadroom holds the relative distance between the value of each metric
+// and the closest bound of its threshold range during an analysis run
+type metricHeadroom map[string]float64
+
+// record computes the headroom of the metric value, the bounds equal to zero have no headroom
+func (h metricHeadroom) record(name string, tr flaggerv1.CanaryThresholdRange, val float64) {
+	headroom := math.Inf(1)
+	for _, bound := range []*float64{tr.Min, tr.Max} {
+		if bound == nil {
+			continue
+		}
+		if *bound == 0 {
+			headroom = 0
+			continue
+		}
+		headroom = math.Min(headroom, math.Abs(val-*bound)/math.Abs(*bound))
+	}
+	if !math.IsInf(headroom, 1) {
+		h[name] = headroom
+	}
+}
+
+// min returns the lowest headroom and false if no metric has a threshold range
+func (h metricHeadroom) min() (float64, bool) {
+	if len(h) == 0 {
+		return 0, false
+	}
+	lowest := math.Inf(1)
+	for _, v := range h {
+		lowest = math.Min(lowest, v)
+	}
+	return lowest, true
+}
+
+// metricThresholdRange returns the threshold range of the metric,
+// the legacy threshold is a lower bound for the success rate metrics and an upper bound otherwise
+func metricThresholdRange(metric flaggerv1.CanaryMetric) flaggerv1.CanaryThresholdRange {
+	if metric.ThresholdRange != nil {
+		return *metric.ThresholdRange
+	}
+	threshold := metric.Threshold
+	if metric.Name == "request-success-rate" || metric.Name == "grpc-success-rate" {
+		return flaggerv1.CanaryThresholdRange{Min: &threshold}
+	}
+	return flaggerv1.CanaryThresholdRange{Max: &threshold}
+}
+
+// adaptiveStepWeightBounds returns the smallest and the largest step weight of the adaptive progression
+func adaptiveStepWeightBounds(canary *flaggerv1.Canary) (int, int) {
+	analysis := canary.GetAnalysis()
+	minStep, maxStep := analysis.Adaptive.MinStepWeight, analysis.Adaptive.MaxStepWeight
+	if minStep <= 0 {
+		minStep = analysis.StepWeight / 2
+		if minStep < 1 {
+			minStep = 1
+		}
+	}
+	if maxStep <= 0 {
+		maxStep = analysis.StepWeight * 4
+	}
+	if maxStep < minStep {
+		maxStep = minStep
+	}
+	return minStep, maxStep
+}
+
+// nextAdaptiveStepWeight doubles the step weight when the headroom is above the target
+// and halves it when the headroom is below half of the target
+func nextAdaptiveStepWeight(canary *flaggerv1.Canary, headroom float64) int {
+	analysis := canary.GetAnalysis()
+	step := canary.Status.AdaptiveStepWeight
+	if step == 0 {
+		step = analysis.StepWeight
+	}
+
+	target := float64(analysis.Adaptive.Headroom) / 100
+	if analysis.Adaptive.Headroom <= 0 {
+		target = float64(defaultAdaptiveHeadroom) / 100
+	}
+
+	minStep, maxStep := adaptiveStepWeightBounds(canary)
+	switch {
+	case headroom >= target:
+		step *= 2
+	case headroom < target/2:
+		step /= 2
+	}
+	if step > maxStep {
+		step = maxStep
+	}
+	if step < minStep {
+		step = minStep
+	}
+	return step
+}
+
+// runAdaptiveStepWeight sets the step weight of the next advancement according to the metrics headroom,
+// the adaptive progression applies only to the canaries using stepWeight
+func (c *Controller) runAdaptiveStepWeight(canary *flaggerv1.Canary, headroom metricHeadroom) {
+	analysis := canary.GetAnalysis()
+	if analysis.Adaptive == nil || analysis.StepWeight <= 0 {
+		return
+	}
+	lowest, ok := headroom.min()
+	if !ok {
+		return
+	}
+
+	step := nextAdaptiveStepWeight(canary, lowest)
+	if step == canary.Status.AdaptiveStepWeight {
+		return
+	}
+	if err := c.setAdaptiveStepWeight(canary, step); err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
+	c.recordEventInfof(canary, "Step weight of %s.%s set to %v, metrics headroom %.0f%%",
+		canary.Name, canary.Namespace, step, lowest*100)
+
+	// the canary is fetched at each run, the step is used by this run advancement
+	canary.Status.AdaptiveStepWeight = step
+}
+
+// setAdaptiveStepWeight updates the adaptive step weight in the canary status
+func (c *Controller) setAdaptiveStepWeight(cd *flaggerv1.Canary, step int) error {
+	firstTry := true
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			cd, err = c.flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+
+		cdCopy := cd.DeepCopy()
+		cdCopy.Status.AdaptiveStepWeight = step
+		_, err = c.flaggerClient.FlaggerV1beta1().Canaries(ns).UpdateStatus(context.TODO(), cdCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		firstTry = false
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
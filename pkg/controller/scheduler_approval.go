@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+)
+
+// runManualPromotionGate halts the promotion until the canary is annotated with flagger.app/approve,
+// an approval set to "true" is removed when the promotion starts so that the next revision
+// must be approved again
+func (c *Controller) runManualPromotionGate(cd *flaggerv1.Canary, canaryController canary.Controller) bool {
+	if !cd.GetAnalysis().ManualPromotion {
+		return true
+	}
+
+	if !isPromotionApproved(cd) {
+		if cd.Status.Phase != flaggerv1.CanaryPhaseWaitingPromotion {
+			if err := canaryController.SetStatusPhase(cd, flaggerv1.CanaryPhaseWaitingPromotion); err != nil {
+				c.canaryLogger(cd).Errorf("%v", err)
+			}
+			c.recordEventWarningf(cd, "Halt %s.%s advancement waiting for promotion approval, annotate the canary with %s=true",
+				cd.Name, cd.Namespace, flaggerv1.ApproveAnnotation)
+			c.alert(cd, "Canary promotion is waiting for approval.", false, flaggerv1.SeverityWarn)
+		} else {
+			c.repeatLastIteration(cd, canaryController)
+		}
+		return false
+	}
+
+	// the field manager of the annotation is lost once the annotation is removed
+	actor := annotationManager(cd, flaggerv1.ApproveAnnotation)
+	if cd.GetAnnotations()[flaggerv1.ApproveAnnotation] == "true" {
+		if err := c.removeAnnotation(cd, flaggerv1.ApproveAnnotation); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return false
+		}
+	}
+	c.recordEventInfof(cd, "Promotion of %s.%s approved with the %s annotation",
+		cd.Name, cd.Namespace, flaggerv1.ApproveAnnotation)
+	c.recordAudit(cd, flaggerv1.CanaryRunRecord{
+		Type:    flaggerv1.GateRecord,
+		Name:    flaggerv1.ApproveAnnotation,
+		Result:  flaggerv1.CheckPassed,
+		Actor:   actor,
+		Message: "Promotion approved",
+	})
+	return true
+}
+
+// isPromotionApproved returns true if the approve annotation is set to "true"
+// or to the checksum of the revision under analysis
+func isPromotionApproved(cd *flaggerv1.Canary) bool {
+	value := cd.GetAnnotations()[flaggerv1.ApproveAnnotation]
+	return value == "true" || value != "" && value == cd.Status.LastAppliedSpec
+}
+
+// isRollbackRequested returns true if the rollback annotation is set to "true"
+// or to the checksum of the revision under analysis
+func isRollbackRequested(cd *flaggerv1.Canary) bool {
+	value := cd.GetAnnotations()[flaggerv1.RollbackAnnotation]
+	return value == "true" || value != "" && value == cd.Status.LastAppliedSpec
+}
+
+// isGateClosed returns true if the canary is annotated with flagger.app/gate=closed
+func isGateClosed(cd *flaggerv1.Canary) bool {
+	return cd.GetAnnotations()[flaggerv1.GateAnnotation] == flaggerv1.GateClosed
+}
+
+// runManualRollbackGate returns true if a rollback was requested with the flagger.app/rollback annotation,
+// a request set to "true" is removed so that the next revision is not rolled back
+func (c *Controller) runManualRollbackGate(cd *flaggerv1.Canary) bool {
+	if !isRollbackRequested(cd) {
+		return false
+	}
+
+	actor := annotationManager(cd, flaggerv1.RollbackAnnotation)
+	if cd.GetAnnotations()[flaggerv1.RollbackAnnotation] == "true" {
+		if err := c.removeAnnotation(cd, flaggerv1.RollbackAnnotation); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return false
+		}
+	}
+	c.recordAudit(cd, flaggerv1.CanaryRunRecord{
+		Type:    flaggerv1.GateRecord,
+		Name:    flaggerv1.RollbackAnnotation,
+		Result:  flaggerv1.CheckFailed,
+		Actor:   actor,
+		Message: "Rollback requested",
+	})
+	return true
+}
+
+// removeAnnotation removes the annotation from the canary
+func (c *Controller) removeAnnotation(cd *flaggerv1.Canary, annotation string) error {
+	firstTry := true
+	canary := cd
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			cd, err = c.flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+		firstTry = false
+
+		if _, ok := cd.GetAnnotations()[annotation]; !ok {
+			return nil
+		}
+		cdCopy := cd.DeepCopy()
+		delete(cdCopy.Annotations, annotation)
+		_, err = c.flaggerClient.FlaggerV1beta1().Canaries(ns).Update(context.TODO(), cdCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		if err == nil {
+			// the status updates that follow in this run start from the in-memory canary
+			canary.Annotations = cdCopy.Annotations
+		}
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("removing the %s annotation failed after retries: %w", annotation, err)
+	}
+	return nil
+}
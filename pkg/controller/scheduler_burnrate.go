@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/metrics/observers"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+)
+
+// burnRateMax is the burn rate that exhausts 2% of a 30 days error budget in one hour
+const burnRateMax = 14.4
+
+// burnRateWindows are the short and long windows of the multi-window burn rate
+var burnRateWindows = []string{"5m", "1h"}
+
+// metricBurnRate holds the burn rate spec with the defaults applied
+type metricBurnRate struct {
+	budget      float64
+	windows     []string
+	maxBurnRate float64
+}
+
+// runMetricBurnRate computes the error budget burn rate of the SLI over each window
+// and halts the advancement if the burn rate exceeds the max in all the windows
+func (c *Controller) runMetricBurnRate(canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric,
+	template *flaggerv1.MetricTemplate, run *analysisRun) bool {
+	burnRate, err := newMetricBurnRate(metric)
+	if err != nil {
+		c.recordEventErrorf(canary, "Metric %s burn rate is invalid: %v", metric.Name, err)
+		return false
+	}
+
+	if template.Spec.Query == "" {
+		c.recordEventErrorf(canary, "Metric template %s.%s query is required for the burn rate of %s",
+			template.Name, template.Namespace, metric.Name)
+		return false
+	}
+
+	rate := math.Inf(1)
+	rates := make([]string, 0, len(burnRate.windows))
+	for _, window := range burnRate.windows {
+		metric.Interval = window
+		provider, err := c.newMetricProvider(template.Namespace, template.Spec.Provider, window)
+		if err != nil {
+			c.recordEventErrorf(canary, "Metric template %s.%s %v", template.Name, template.Namespace, err)
+			return false
+		}
+		provider = c.withQueryRetries(canary, metric.Name, provider, run.retries)
+
+		query, err := observers.RenderQuery(template.Spec.Query, toMetricModel(canary, metric))
+		if err != nil {
+			c.recordEventErrorf(canary, "Metric template %s.%s query render error: %v",
+				template.Name, template.Namespace, err)
+			return false
+		}
+
+		ratio, err := provider.RunQuery(query)
+		if err != nil {
+			if errors.Is(err, providers.ErrNoValuesFound) {
+				c.recordEventWarningf(canary, "Halt advancement no values found for custom metric: %s over %s: %v",
+					metric.Name, window, err)
+			} else {
+				c.recordEventErrorf(canary, "Metric query failed for %s over %s: %v", metric.Name, window, err)
+			}
+			return false
+		}
+
+		windowRate := ratio / burnRate.budget
+		c.canaryLogger(canary).Debugf("Metric %s burn rate %v over %s", metric.Name, windowRate, window)
+		rates = append(rates, fmt.Sprintf("%.2f over %s", windowRate, window))
+		rate = math.Min(rate, windowRate)
+	}
+
+	// the lowest burn rate is the one that gates the advancement
+	c.recorder.SetAnalysis(canary, metric.Name, rate)
+	run.observe(metric, rate)
+
+	if rate > burnRate.maxBurnRate {
+		c.recordEventWarningf(canary, "Halt %s.%s advancement %s error budget burn rate %s > %v",
+			canary.Name, canary.Namespace, metric.Name, strings.Join(rates, ", "), burnRate.maxBurnRate)
+		return false
+	}
+
+	return true
+}
+
+// newMetricBurnRate validates the metric burn rate spec and applies the defaults
+func newMetricBurnRate(metric flaggerv1.CanaryMetric) (metricBurnRate, error) {
+	spec := metric.BurnRate
+	if spec.Objective <= 0 || spec.Objective >= 100 {
+		return metricBurnRate{}, fmt.Errorf("objective %v must be between 0 and 100", spec.Objective)
+	}
+
+	burnRate := metricBurnRate{
+		budget:      1 - spec.Objective/100,
+		windows:     burnRateWindows,
+		maxBurnRate: burnRateMax,
+	}
+
+	if len(spec.Windows) > 0 {
+		for _, window := range spec.Windows {
+			if d, err := time.ParseDuration(window); err != nil || d <= 0 {
+				return metricBurnRate{}, fmt.Errorf("window %s is invalid", window)
+			}
+		}
+		burnRate.windows = spec.Windows
+	}
+
+	if spec.MaxBurnRate < 0 {
+		return metricBurnRate{}, fmt.Errorf("max burn rate %v must be positive", spec.MaxBurnRate)
+	}
+	if spec.MaxBurnRate > 0 {
+		burnRate.maxBurnRate = spec.MaxBurnRate
+	}
+
+	return burnRate, nil
+}
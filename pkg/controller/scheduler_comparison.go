@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/metrics/observers"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+	"github.com/fluxcd/flagger/pkg/metrics/stats"
+)
+
+const (
+	comparisonSignificance = 0.05
+	comparisonStep         = 15 * time.Second
+	comparisonMinSamples   = 10
+)
+
+// metricComparison holds the comparison spec with the defaults applied
+type metricComparison struct {
+	alternative  stats.Alternative
+	significance float64
+	interval     time.Duration
+	step         time.Duration
+	minSamples   int
+}
+
+// runMetricComparison collects the canary and primary samples over the metric interval
+// and halts the advancement if the statistical test accepts the alternative hypothesis
+func (c *Controller) runMetricComparison(canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric,
+	template *flaggerv1.MetricTemplate, run *analysisRun) bool {
+	comparison, err := newMetricComparison(canary, metric)
+	if err != nil {
+		c.recordEventErrorf(canary, "Metric %s comparison is invalid: %v", metric.Name, err)
+		return false
+	}
+
+	if template.Spec.Query == "" {
+		c.recordEventErrorf(canary, "Metric template %s.%s query is required for the comparison of %s",
+			template.Name, template.Namespace, metric.Name)
+		return false
+	}
+
+	provider, err := c.newMetricProvider(template.Namespace, template.Spec.Provider, metric.Interval)
+	if err != nil {
+		c.recordEventErrorf(canary, "Metric template %s.%s %v", template.Name, template.Namespace, err)
+		return false
+	}
+	provider = c.withQueryRetries(canary, metric.Name, provider, run.retries)
+
+	rangeProvider, ok := provider.(providers.RangeInterface)
+	if !ok {
+		c.recordEventErrorf(canary, "Metric template %s.%s provider %s does not support the comparison of %s",
+			template.Name, template.Namespace, template.Spec.Provider.Type, metric.Name)
+		return false
+	}
+
+	canaryModel := toMetricModel(canary, metric)
+	primaryModel := canaryModel
+	primaryTarget, err := canary.GetPrimaryName(canaryModel.Target)
+	if err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return false
+	}
+	primaryModel.Target = primaryTarget
+
+	end := time.Now()
+	start := end.Add(-comparison.interval)
+	samples := make(map[string][]float64, 2)
+	for name, model := range map[string]flaggerv1.MetricTemplateModel{"canary": canaryModel, "primary": primaryModel} {
+		query, err := observers.RenderQuery(template.Spec.Query, model)
+		if err != nil {
+			c.recordEventErrorf(canary, "Metric template %s.%s query render error: %v",
+				template.Name, template.Namespace, err)
+			return false
+		}
+
+		values, err := rangeProvider.RunRangeQuery(query, start, end, comparison.step)
+		if err != nil {
+			if errors.Is(err, providers.ErrNoValuesFound) {
+				c.recordEventWarningf(canary, "Halt advancement no %s values found for custom metric: %s: %v",
+					name, metric.Name, err)
+			} else {
+				c.recordEventErrorf(canary, "Metric query failed for %s %s: %v", name, metric.Name, err)
+			}
+			return false
+		}
+		samples[name] = values
+	}
+
+	if len(samples["canary"]) < comparison.minSamples || len(samples["primary"]) < comparison.minSamples {
+		c.recordEventWarningf(canary, "Halt %s.%s advancement %s not enough samples canary %d primary %d < %d",
+			canary.Name, canary.Namespace, metric.Name, len(samples["canary"]), len(samples["primary"]), comparison.minSamples)
+		return false
+	}
+
+	_, p, err := stats.MannWhitneyUTest(samples["canary"], samples["primary"], comparison.alternative)
+	if err != nil {
+		c.recordEventErrorf(canary, "Metric %s comparison failed: %v", metric.Name, err)
+		return false
+	}
+	c.recorder.SetAnalysis(canary, metric.Name, p)
+
+	if p < comparison.significance {
+		c.recordEventWarningf(canary, "Halt %s.%s advancement %s canary differs from primary (%s) p-value %.4f < %v",
+			canary.Name, canary.Namespace, metric.Name, comparison.alternative, p, comparison.significance)
+		return false
+	}
+
+	return true
+}
+
+// newMetricComparison validates the metric comparison spec and applies the defaults
+func newMetricComparison(canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric) (metricComparison, error) {
+	spec := metric.Comparison
+	if spec.Test != "" && spec.Test != flaggerv1.MannWhitneyComparisonTest {
+		return metricComparison{}, fmt.Errorf("test %s not supported", spec.Test)
+	}
+
+	comparison := metricComparison{
+		alternative:  stats.GreaterAlternative,
+		significance: comparisonSignificance,
+		step:         comparisonStep,
+		minSamples:   comparisonMinSamples,
+	}
+
+	switch alternative := stats.Alternative(spec.Alternative); alternative {
+	case "":
+	case stats.GreaterAlternative, stats.LessAlternative, stats.TwoSidedAlternative:
+		comparison.alternative = alternative
+	default:
+		return metricComparison{}, fmt.Errorf("alternative %s not supported", spec.Alternative)
+	}
+
+	if spec.Significance != 0 {
+		if spec.Significance < 0 || spec.Significance >= 1 {
+			return metricComparison{}, fmt.Errorf("significance %v must be between 0 and 1", spec.Significance)
+		}
+		comparison.significance = spec.Significance
+	}
+
+	if spec.MinSamples > 0 {
+		comparison.minSamples = spec.MinSamples
+	}
+
+	interval := metric.Interval
+	if interval == "" {
+		interval = canary.GetMetricInterval()
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return metricComparison{}, fmt.Errorf("interval %s is invalid: %w", interval, err)
+	}
+	comparison.interval = d
+
+	if spec.Step != "" {
+		step, err := time.ParseDuration(spec.Step)
+		if err != nil || step <= 0 {
+			return metricComparison{}, fmt.Errorf("step %s is invalid", spec.Step)
+		}
+		comparison.step = step
+	}
+
+	return comparison, nil
+}
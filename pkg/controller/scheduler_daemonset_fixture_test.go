@@ -25,6 +25,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/record"
@@ -86,13 +87,15 @@ func newDaemonSetFixture(c *flaggerv1.Canary) daemonSetFixture {
 	flaggerInformerFactory := informers.NewSharedInformerFactory(flaggerClient, 0)
 
 	fi := Informers{
-		CanaryInformer: flaggerInformerFactory.Flagger().V1beta1().Canaries(),
-		MetricInformer: flaggerInformerFactory.Flagger().V1beta1().MetricTemplates(),
-		AlertInformer:  flaggerInformerFactory.Flagger().V1beta1().AlertProviders(),
+		CanaryInformer:       flaggerInformerFactory.Flagger().V1beta1().Canaries(),
+		MetricInformer:       flaggerInformerFactory.Flagger().V1beta1().MetricTemplates(),
+		AlertInformer:        flaggerInformerFactory.Flagger().V1beta1().AlertProviders(),
+		ReleaseGroupInformer: flaggerInformerFactory.Flagger().V1beta1().ReleaseGroups(),
+		SecretInformer:       kubeinformers.NewSharedInformerFactory(kubeClient, 0).Core().V1().Secrets(),
 	}
 
 	// init router
-	rf := router.NewFactory(nil, kubeClient, flaggerClient, "annotationsPrefix", "", logger, flaggerClient)
+	rf := router.NewFactory(nil, kubeClient, flaggerClient, "annotationsPrefix", "", "", logger, flaggerClient)
 
 	// init observer
 	observerFactory, _ := observers.NewFactory(testMetricsServerURL)
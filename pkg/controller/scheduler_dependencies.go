@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+)
+
+// runStartDependencyGate halts the start of the analysis until the canaries
+// this canary depends on have been promoted
+func (c *Controller) runStartDependencyGate(cd *flaggerv1.Canary, canaryController canary.Controller) bool {
+	// the initialization and the started analysis are not held
+	switch cd.Status.Phase {
+	case "", flaggerv1.CanaryPhaseInitializing, flaggerv1.CanaryPhaseProgressing,
+		flaggerv1.CanaryPhaseWaitingPromotion, flaggerv1.CanaryPhasePromoting, flaggerv1.CanaryPhaseFinalising:
+		return true
+	}
+
+	pending, err := c.getPendingDependencies(cd, flaggerv1.StartDependencyGate)
+	if err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return false
+	}
+
+	if len(pending) > 0 {
+		if cd.Status.Phase != flaggerv1.CanaryPhaseWaiting {
+			if err := canaryController.SetStatusPhase(cd, flaggerv1.CanaryPhaseWaiting); err != nil {
+				c.canaryLogger(cd).Errorf("%v", err)
+			}
+			c.recordEventWarningf(cd, "Halt %s.%s advancement waiting for canaries %s to be promoted",
+				cd.Name, cd.Namespace, strings.Join(pending, ", "))
+		}
+		return false
+	}
+
+	// the waiting canary is moved to progressing by the canary status check
+	return true
+}
+
+// runPromotionDependencyGate halts the promotion until the canaries
+// this canary depends on have been promoted
+func (c *Controller) runPromotionDependencyGate(cd *flaggerv1.Canary, canaryController canary.Controller) bool {
+	pending, err := c.getPendingDependencies(cd, flaggerv1.PromotionDependencyGate)
+	if err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return false
+	}
+	if len(pending) == 0 {
+		return true
+	}
+
+	if cd.Status.Phase != flaggerv1.CanaryPhaseWaitingPromotion {
+		if err := canaryController.SetStatusPhase(cd, flaggerv1.CanaryPhaseWaitingPromotion); err != nil {
+			c.canaryLogger(cd).Errorf("%v", err)
+		}
+		c.recordEventWarningf(cd, "Halt %s.%s advancement waiting for canaries %s to be promoted",
+			cd.Name, cd.Namespace, strings.Join(pending, ", "))
+	} else {
+		c.repeatLastIteration(cd, canaryController)
+	}
+	return false
+}
+
+// getPendingDependencies returns the canaries holding the given gate that have not been promoted,
+// a canary is considered promoted when it has been initialized or has finished the analysis successfully
+func (c *Controller) getPendingDependencies(cd *flaggerv1.Canary, gate flaggerv1.DependencyGate) ([]string, error) {
+	var pending []string
+	for _, dep := range cd.GetAnalysis().DependsOn {
+		if dep.GetGate() != gate {
+			continue
+		}
+
+		namespace := dep.Namespace
+		if namespace == "" {
+			namespace = cd.Namespace
+		}
+		if dep.Name == cd.Name && namespace == cd.Namespace {
+			return nil, fmt.Errorf("canary %s.%s cannot depend on itself", cd.Name, cd.Namespace)
+		}
+
+		target, err := c.flaggerClient.FlaggerV1beta1().Canaries(namespace).Get(context.TODO(), dep.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("dependency %s.%s query failed: %w", dep.Name, namespace, err)
+		}
+
+		// circular dependencies would hold both canaries forever
+		if analysis := target.GetAnalysis(); analysis != nil {
+			for _, d := range analysis.DependsOn {
+				ns := d.Namespace
+				if ns == "" {
+					ns = target.Namespace
+				}
+				if d.Name == cd.Name && ns == cd.Namespace {
+					return nil, fmt.Errorf("canaries %s.%s and %s.%s depend on each other",
+						cd.Name, cd.Namespace, target.Name, target.Namespace)
+				}
+			}
+		}
+
+		switch target.Status.Phase {
+		case flaggerv1.CanaryPhaseInitialized, flaggerv1.CanaryPhaseSucceeded:
+		default:
+			pending = append(pending, fmt.Sprintf("%s.%s", target.Name, target.Namespace))
+		}
+	}
+	return pending, nil
+}
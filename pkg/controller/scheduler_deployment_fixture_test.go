@@ -30,6 +30,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/record"
@@ -82,6 +83,27 @@ func (f fixture) makeReady(t *testing.T, name string) {
 	require.NoError(t, err)
 }
 
+// syncInformers copies the canaries, release groups and secrets from the fake clients to the informer caches
+func (f fixture) syncInformers(t *testing.T) {
+	canaries, err := f.flaggerClient.FlaggerV1beta1().Canaries("default").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	for i := range canaries.Items {
+		require.NoError(t, f.ctrl.flaggerInformers.CanaryInformer.Informer().GetIndexer().Update(&canaries.Items[i]))
+	}
+
+	groups, err := f.flaggerClient.FlaggerV1beta1().ReleaseGroups("default").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	for i := range groups.Items {
+		require.NoError(t, f.ctrl.flaggerInformers.ReleaseGroupInformer.Informer().GetIndexer().Update(&groups.Items[i]))
+	}
+
+	secrets, err := f.kubeClient.CoreV1().Secrets("default").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	for i := range secrets.Items {
+		require.NoError(t, f.ctrl.flaggerInformers.SecretInformer.Informer().GetIndexer().Update(&secrets.Items[i]))
+	}
+}
+
 func newDeploymentFixture(c *flaggerv1.Canary) fixture {
 	if c == nil {
 		c = newDeploymentTestCanary()
@@ -114,13 +136,15 @@ func newDeploymentFixture(c *flaggerv1.Canary) fixture {
 	flaggerInformerFactory := informers.NewSharedInformerFactory(flaggerClient, 0)
 
 	fi := Informers{
-		CanaryInformer: flaggerInformerFactory.Flagger().V1beta1().Canaries(),
-		MetricInformer: flaggerInformerFactory.Flagger().V1beta1().MetricTemplates(),
-		AlertInformer:  flaggerInformerFactory.Flagger().V1beta1().AlertProviders(),
+		CanaryInformer:       flaggerInformerFactory.Flagger().V1beta1().Canaries(),
+		MetricInformer:       flaggerInformerFactory.Flagger().V1beta1().MetricTemplates(),
+		AlertInformer:        flaggerInformerFactory.Flagger().V1beta1().AlertProviders(),
+		ReleaseGroupInformer: flaggerInformerFactory.Flagger().V1beta1().ReleaseGroups(),
+		SecretInformer:       kubeinformers.NewSharedInformerFactory(kubeClient, 0).Core().V1().Secrets(),
 	}
 
 	// init router
-	rf := router.NewFactory(nil, kubeClient, flaggerClient, "annotationsPrefix", "", logger, flaggerClient)
+	rf := router.NewFactory(nil, kubeClient, flaggerClient, "annotationsPrefix", "", "", logger, flaggerClient)
 
 	// init observer
 	observerFactory, _ := observers.NewFactory(testMetricsServerURL)
@@ -294,7 +318,7 @@ func newDeploymentTestCanary() *flaggerv1.Canary {
 				APIVersion: "apps/v1",
 				Kind:       "Deployment",
 			},
-			AutoscalerRef: &flaggerv1.LocalObjectReference{
+			AutoscalerRef: &flaggerv1.AutoscalerReference{
 				Name:       "podinfo",
 				APIVersion: "autoscaling/v2beta2",
 				Kind:       "HorizontalPodAutoscaler",
@@ -356,7 +380,7 @@ func newDeploymentTestCanaryAB() *flaggerv1.Canary {
 				APIVersion: "apps/v1",
 				Kind:       "Deployment",
 			},
-			AutoscalerRef: &flaggerv1.LocalObjectReference{
+			AutoscalerRef: &flaggerv1.AutoscalerReference{
 				Name:       "podinfo",
 				APIVersion: "autoscaling/v2beta2",
 				Kind:       "HorizontalPodAutoscaler",
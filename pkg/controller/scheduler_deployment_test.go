@@ -24,11 +24,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 	"github.com/fluxcd/flagger/pkg/notifier"
@@ -42,6 +46,55 @@ func TestScheduler_DeploymentInit(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestScheduler_DeploymentMissingCRDs(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Status.Conditions = []flaggerv1.CanaryCondition{{
+		Type:   flaggerv1.RouterReadyType,
+		Status: corev1.ConditionFalse,
+		Reason: routerMissingCRDReason,
+	}}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// initializing
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// make primary ready
+	mocks.makePrimaryReady(t)
+
+	// the Istio CRDs are not installed
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, err = mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.Error(t, err)
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	condition := getCanaryCondition(cd.Status, flaggerv1.RouterReadyType)
+	require.NotNil(t, condition)
+	assert.Equal(t, corev1.ConditionFalse, condition.Status)
+	assert.Contains(t, condition.Message, "virtualservices.networking.istio.io/v1alpha3")
+
+	// install the Istio CRDs
+	mocks.meshClient.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{
+		GroupVersion: "networking.istio.io/v1alpha3",
+		APIResources: []metav1.APIResource{{Name: "virtualservices"}, {Name: "destinationrules"}},
+	}}
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, err = mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	condition = getCanaryCondition(cd.Status, flaggerv1.RouterReadyType)
+	require.NotNil(t, condition)
+	assert.Equal(t, corev1.ConditionTrue, condition.Status)
+}
+
 func TestScheduler_DeploymentNewRevision(t *testing.T) {
 	mocks := newDeploymentFixture(nil)
 
@@ -62,89 +115,1081 @@ func TestScheduler_DeploymentNewRevision(t *testing.T) {
 	// detect changes
 	mocks.ctrl.advanceCanary("podinfo", "default")
 
-	c, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	c, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), *c.Spec.Replicas)
+}
+
+func TestScheduler_DeploymentRollback(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	// initializing
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// make primary ready
+	mocks.makePrimaryReady(t)
+
+	// initialized
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update failed checks to max
+	err := mocks.deployer.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing, FailedChecks: 10})
+	require.NoError(t, err)
+
+	// set a metric check to fail
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd := c.DeepCopy()
+	cd.Spec.Analysis.Metrics = append(c.Spec.Analysis.Metrics, flaggerv1.CanaryMetric{
+		Name:     "fail",
+		Interval: "1m",
+		ThresholdRange: &flaggerv1.CanaryThresholdRange{
+			Min: toFloatPtr(0),
+			Max: toFloatPtr(50),
+		},
+		Query: "fail",
+	})
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// run metric checks
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// finalise analysis
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// check status
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, c.Status.Phase)
+}
+
+func TestScheduler_DeploymentRollbackPinned(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	// initializing
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// make primary ready
+	mocks.makePrimaryReady(t)
+
+	// initialized
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:1.2.0", c.Status.LastPromotedImages["podinfo"])
+
+	// change the primary image outside of Flagger
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	primary.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:drifted"
+	primary.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "DRIFTED", Value: "true"}}
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), primary, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// update failed checks to max
+	err = mocks.deployer.SyncStatus(c, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing, FailedChecks: 10})
+	require.NoError(t, err)
+
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd := c.DeepCopy()
+	cd.Spec.Analysis.RollbackPolicy = flaggerv1.PinnedRollbackPolicy
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// rollback
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, c.Status.Phase)
+
+	// the primary runs the pod template of the last promoted revision
+	primary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:1.2.0", primary.Spec.Template.Spec.Containers[0].Image)
+	assert.NotContains(t, primary.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "DRIFTED", Value: "true"})
+}
+
+func TestScheduler_DeploymentRollbackWebhook(t *testing.T) {
+	var payload flaggerv1.CanaryWebhookPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer ts.Close()
+
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.Webhooks = []flaggerv1.CanaryWebhook{{
+		Name: "incident",
+		Type: flaggerv1.PostRollbackHook,
+		URL:  ts.URL,
+	}}
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// update failed checks to max
+	err = mocks.deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing, FailedChecks: 10, CanaryWeight: 20})
+	require.NoError(t, err)
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SetStatusFailures(cd, []flaggerv1.CanaryCheckFailure{{
+		Kind:         flaggerv1.MetricCheckKind,
+		Name:         "request-success-rate",
+		Value:        toFloatPtr(95),
+		Message:      "request-success-rate 95.00 < 99",
+		CanaryWeight: 20,
+	}})
+	require.NoError(t, err)
+
+	// rollback
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, c.Status.Phase)
+
+	require.NotNil(t, payload.Rollback)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, payload.Phase)
+	assert.Equal(t, flaggerv1.FailedChecksRollbackReason, payload.Rollback.Reason)
+	assert.Equal(t, 20, payload.Rollback.CanaryWeight)
+	assert.Equal(t, 10, payload.Rollback.FailedChecks)
+	require.Len(t, payload.Rollback.Failures, 1)
+	assert.Equal(t, "request-success-rate", payload.Rollback.Failures[0].Name)
+	assert.Equal(t, 95.0, *payload.Rollback.Failures[0].Value)
+}
+
+func TestScheduler_DeploymentRollbackRetry(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.Retries = 1
+	cd.Spec.Analysis.RetryInterval = "0s"
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// update failed checks to max
+	err = mocks.deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing, FailedChecks: 10})
+	require.NoError(t, err)
+
+	// rollback and schedule a retry
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, cd.Status.Phase)
+	assert.False(t, cd.Status.NextRetryTime.IsZero())
+
+	// retry the analysis
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+	assert.Equal(t, 1, cd.Status.RetryAttempts)
+	assert.Equal(t, 0, cd.Status.FailedChecks)
+	assert.True(t, cd.Status.NextRetryTime.IsZero())
+
+	// no retry is left
+	mocks.makeCanaryReady(t)
+	err = mocks.deployer.SetStatusFailedChecks(cd, 10)
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, cd.Status.Phase)
+	assert.Equal(t, 1, cd.Status.RetryAttempts)
+	assert.True(t, cd.Status.NextRetryTime.IsZero())
+}
+
+func TestScheduler_DeploymentMetricThresholdRollbackRetry(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.Retries = 1
+	cd.Spec.Analysis.RetryInterval = "0s"
+	cd.Spec.Analysis.Metrics[0].FailureThreshold = 2
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// the metric reached its own threshold while the analysis is below the global one
+	err = mocks.deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing, FailedChecks: 2})
+	require.NoError(t, err)
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Less(t, cd.Status.FailedChecks, cd.GetAnalysisThreshold())
+	cd.Status.Metrics = []flaggerv1.CanaryMetricStatus{{Name: cd.Spec.Analysis.Metrics[0].Name, FailedChecks: 2}}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// rollback and schedule a retry
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, cd.Status.Phase)
+	assert.False(t, cd.Status.NextRetryTime.IsZero())
+
+	// retry the analysis
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+	assert.Equal(t, 1, cd.Status.RetryAttempts)
+	assert.True(t, cd.Status.NextRetryTime.IsZero())
+}
+
+func TestScheduler_DeploymentRollbackDrain(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.RollbackDrainPeriod = "1m"
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// update failed checks to max
+	err = mocks.deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing, FailedChecks: 10})
+	require.NoError(t, err)
+	require.NoError(t, mocks.router.SetRoutes(mocks.canary, 90, 10, false))
+
+	// the traffic is routed back to the primary and the canary keeps running
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	_, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 0, canaryWeight)
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+	require.NotNil(t, cd.Status.RollbackDrain)
+	assert.Equal(t, flaggerv1.FailedChecksRollbackReason, cd.Status.RollbackDrain.Reason)
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, int32(0), *dep.Spec.Replicas)
+
+	// the drain restarts when the router reports canary traffic
+	start := cd.Status.RollbackDrain.StartTime
+	require.NoError(t, mocks.router.SetRoutes(mocks.canary, 90, 10, false))
+	cd.Status.RollbackDrain.StartTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err = mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 0, canaryWeight)
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, cd.Status.RollbackDrain)
+	assert.False(t, cd.Status.RollbackDrain.StartTime.Before(&start))
+	dep, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, int32(0), *dep.Spec.Replicas)
+
+	// the canary is scaled down once the drain period has passed with no canary traffic
+	cd.Status.RollbackDrain.StartTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, cd.Status.Phase)
+	assert.Nil(t, cd.Status.RollbackDrain)
+	dep, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *dep.Spec.Replicas)
+}
+
+func TestScheduler_DeploymentReleaseGroup(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	member := newDeploymentTestCanary()
+	member.Name = "podinfo-db"
+	member, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Create(context.TODO(), member, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	group := &flaggerv1.ReleaseGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec: flaggerv1.ReleaseGroupSpec{
+			Canaries: []corev1.LocalObjectReference{{Name: "podinfo"}, {Name: "podinfo-db"}},
+		},
+	}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().ReleaseGroups("default").Create(context.TODO(), group, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	err = mocks.deployer.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing})
+	require.NoError(t, err)
+	err = mocks.deployer.SyncStatus(member, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing})
+	require.NoError(t, err)
+
+	// promotion is blocked while the other canary is running the analysis
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	assert.False(t, mocks.ctrl.runReleaseGroupGate(cd, mocks.deployer))
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseWaitingPromotion, cd.Status.Phase)
+
+	// promotion is unblocked when the other canary has passed the analysis
+	member, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo-db", metav1.GetOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SetStatusPhase(member, flaggerv1.CanaryPhaseWaitingPromotion)
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	assert.True(t, mocks.ctrl.runReleaseGroupGate(cd, mocks.deployer))
+	_, failed := mocks.ctrl.hasReleaseGroupFailed(cd)
+	assert.False(t, failed)
+
+	// rollback is triggered when the other canary fails
+	member, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo-db", metav1.GetOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SetStatusPhase(member, flaggerv1.CanaryPhaseFailed)
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	name, failed := mocks.ctrl.hasReleaseGroupFailed(cd)
+	assert.True(t, failed)
+	assert.Equal(t, "podinfo-db", name)
+
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, cd.Status.Phase)
+}
+
+func TestScheduler_DeploymentReleaseGroupLockstep(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	member := newDeploymentTestCanary()
+	member.Name = "podinfo-db"
+	member, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Create(context.TODO(), member, metav1.CreateOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SyncStatus(member, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing})
+	require.NoError(t, err)
+
+	group := &flaggerv1.ReleaseGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec: flaggerv1.ReleaseGroupSpec{
+			Canaries: []corev1.LocalObjectReference{{Name: "podinfo"}, {Name: "podinfo-db"}},
+		},
+	}
+	group, err = mocks.flaggerClient.FlaggerV1beta1().ReleaseGroups("default").Create(context.TODO(), group, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// the weights are not synchronised without lockstep
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	assert.False(t, mocks.ctrl.holdReleaseGroupWeight(cd, 10))
+
+	// the weight is held while the other canary has a lower weight
+	group.Spec.Lockstep = true
+	_, err = mocks.flaggerClient.FlaggerV1beta1().ReleaseGroups("default").Update(context.TODO(), group, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	assert.False(t, mocks.ctrl.holdReleaseGroupWeight(cd, 0))
+	assert.True(t, mocks.ctrl.holdReleaseGroupWeight(cd, 10))
+
+	member, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo-db", metav1.GetOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SetStatusWeight(member, 10)
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	assert.False(t, mocks.ctrl.holdReleaseGroupWeight(cd, 10))
+
+	// the weight is held while the other canary is waiting to start
+	member, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo-db", metav1.GetOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SetStatusPhase(member, flaggerv1.CanaryPhaseWaiting)
+	require.NoError(t, err)
+	mocks.syncInformers(t)
+	assert.True(t, mocks.ctrl.holdReleaseGroupWeight(cd, 0))
+}
+
+func TestScheduler_DeploymentManualPromotion(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.ManualPromotion = true
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing})
+	require.NoError(t, err)
+
+	// promotion is blocked until the canary is annotated
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.False(t, mocks.ctrl.runManualPromotionGate(cd, mocks.deployer))
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseWaitingPromotion, cd.Status.Phase)
+	revision := cd.Status.LastAppliedSpec
+
+	// approval of another revision is ignored
+	cd.Annotations = map[string]string{flaggerv1.ApproveAnnotation: "xyz"}
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.False(t, mocks.ctrl.runManualPromotionGate(cd, mocks.deployer))
+
+	// approval of the current revision is kept
+	cd.Annotations[flaggerv1.ApproveAnnotation] = revision
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.True(t, mocks.ctrl.runManualPromotionGate(cd, mocks.deployer))
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, revision, cd.Annotations[flaggerv1.ApproveAnnotation])
+
+	// approval set to true is removed once used
+	cd.Annotations[flaggerv1.ApproveAnnotation] = "true"
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.True(t, mocks.ctrl.runManualPromotionGate(cd, mocks.deployer))
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, cd.Annotations, flaggerv1.ApproveAnnotation)
+}
+
+func TestScheduler_DeploymentManualRollback(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	// the gate halts the analysis
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Annotations = map[string]string{flaggerv1.GateAnnotation: flaggerv1.GateClosed}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseWaiting))
+
+	// the rollback is requested
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Annotations = map[string]string{flaggerv1.RollbackAnnotation: "true"}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// resume the analysis
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+
+	// rollback
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseFailed))
+
+	// the request set to true is removed once used
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, cd.Annotations, flaggerv1.RollbackAnnotation)
+}
+
+func TestScheduler_DeploymentDependencies(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	backend := newDeploymentTestCanary()
+	backend.Name = "podinfo-db"
+	backend, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Create(context.TODO(), backend, metav1.CreateOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SyncStatus(backend, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing})
+	require.NoError(t, err)
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.DependsOn = []flaggerv1.CanaryDependency{{Name: "podinfo-db"}}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// the analysis doesn't start while the backend canary is running
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseWaiting, cd.Status.Phase)
+
+	// the analysis starts once the backend canary is promoted
+	backend, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo-db", metav1.GetOptions{})
+	require.NoError(t, err)
+	err = mocks.deployer.SetStatusPhase(backend, flaggerv1.CanaryPhaseSucceeded)
+	require.NoError(t, err)
+
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+
+	// the promotion is held while the backend canary is running
+	cd.Spec.Analysis.DependsOn[0].Gate = flaggerv1.PromotionDependencyGate
+	err = mocks.deployer.SetStatusPhase(backend, flaggerv1.CanaryPhaseProgressing)
+	require.NoError(t, err)
+	assert.False(t, mocks.ctrl.runPromotionDependencyGate(cd, mocks.deployer))
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseWaitingPromotion, cd.Status.Phase)
+
+	// circular dependencies are rejected
+	backend, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo-db", metav1.GetOptions{})
+	require.NoError(t, err)
+	backend.Spec.Analysis.DependsOn = []flaggerv1.CanaryDependency{{Name: "podinfo"}}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), backend, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	_, err = mocks.ctrl.getPendingDependencies(backend, flaggerv1.StartDependencyGate)
+	require.Error(t, err)
+}
+
+func TestScheduler_DeploymentTrafficWindows(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes and start the analysis
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, heldWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	require.Greater(t, heldWeight, 0)
+
+	// window is closed for the whole day
+	now := time.Now().UTC()
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.TrafficWindows = []flaggerv1.TimeWindow{{
+		Start: "00:00",
+		End:   "00:00",
+		Days:  []string{now.AddDate(0, 0, 1).Weekday().String()[:3]},
+	}}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// analysis is paused outside the window with the canary weight held in status
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	primaryWeight, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 100, primaryWeight)
+	assert.Equal(t, 0, canaryWeight)
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+	assert.Equal(t, heldWeight, cd.Status.CanaryWeight)
+
+	// traffic is routed back at the held weight when the window opens
+	cd.Spec.Analysis.TrafficWindows[0].Days = nil
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err = mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, heldWeight, canaryWeight)
+
+	// the analysis advances from the held weight
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err = mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Greater(t, canaryWeight, heldWeight)
+}
+
+func TestScheduler_DeploymentProgressionWindows(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes and start the analysis
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	require.Greater(t, canaryWeight, 0)
+
+	// window is closed for the whole day
+	now := time.Now().UTC()
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.ProgressionWindows = []flaggerv1.TimeWindow{{
+		Start: "00:00",
+		End:   "00:00",
+		Days:  []string{now.AddDate(0, 0, 1).Weekday().String()[:3]},
+	}}
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// the canary weight is held outside the window
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, heldWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, canaryWeight, heldWeight)
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+
+	// the canary is rolled back with the rollback policy
+	cd.Spec.Analysis.ProgressionWindowPolicy = flaggerv1.RollbackProgressionWindowPolicy
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFailed, cd.Status.Phase)
+
+	primaryWeight, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 100, primaryWeight)
+	assert.Equal(t, 0, canaryWeight)
+}
+
+func TestScheduler_DeploymentMaxDuration(t *testing.T) {
+	startAnalysis := func(t *testing.T, policy flaggerv1.MaxDurationPolicy) fixture {
+		mocks := newDeploymentFixture(nil)
+		mocks.ctrl.advanceCanary("podinfo", "default")
+		mocks.makePrimaryReady(t)
+		mocks.ctrl.advanceCanary("podinfo", "default")
+
+		// update
+		dep2 := newDeploymentTestDeploymentV2()
+		_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		// detect changes and start the analysis
+		mocks.ctrl.advanceCanary("podinfo", "default")
+		mocks.makeCanaryReady(t)
+		mocks.ctrl.advanceCanary("podinfo", "default")
+		require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+
+		cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+		require.NoError(t, err)
+		cd.Spec.Analysis.MaxDuration = "1h"
+		cd.Spec.Analysis.MaxDurationPolicy = policy
+		cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		// the analysis is not ended before the max duration
+		mocks.ctrl.advanceCanary("podinfo", "default")
+		require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+
+		// the analysis started two hours ago
+		cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+		require.NoError(t, err)
+		cd.Status.RunStartTime = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		mocks.ctrl.advanceCanary("podinfo", "default")
+		return mocks
+	}
+
+	t.Run("rollback", func(t *testing.T) {
+		mocks := startAnalysis(t, "")
+		require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseFailed))
+
+		primaryWeight, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+		require.NoError(t, err)
+		assert.Equal(t, 100, primaryWeight)
+		assert.Equal(t, 0, canaryWeight)
+	})
+
+	t.Run("promote", func(t *testing.T) {
+		mocks := startAnalysis(t, flaggerv1.PromoteMaxDurationPolicy)
+		require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhasePromoting))
+
+		primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+		require.NoError(t, err)
+		dep2 := newDeploymentTestDeploymentV2()
+		assert.Equal(t, dep2.Spec.Template.Spec.Containers[0].Image, primary.Spec.Template.Spec.Containers[0].Image)
+	})
+}
+
+func TestScheduler_DeploymentStepWeightDurations(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.Analysis.StepWeight = 0
+	cd.Spec.Analysis.StepWeights = []int{10, 50}
+	cd.Spec.Analysis.StepWeightDurations = []string{"1h"}
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes and advance to the first step
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	require.Equal(t, 10, canaryWeight)
+
+	// the first step is held for its duration
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err = mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 10, canaryWeight)
+
+	// advance when the duration has elapsed
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Status.StepStartTime = metav1.NewTime(time.Now().Add(-time.Hour))
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err = mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 50, canaryWeight)
+}
+
+func TestScheduler_DeploymentSuspend(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes and start the analysis
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	require.Greater(t, canaryWeight, 0)
+
+	// suspend
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	iterations := cd.Status.Iterations
+	cd.Spec.Suspend = true
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// weights and status are frozen
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, suspendedWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, canaryWeight, suspendedWeight)
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+	assert.Equal(t, canaryWeight, cd.Status.CanaryWeight)
+	assert.Equal(t, iterations, cd.Status.Iterations)
+	condition := getCanaryCondition(cd.Status, flaggerv1.SuspendedType)
+	require.NotNil(t, condition)
+	assert.Equal(t, corev1.ConditionTrue, condition.Status)
+
+	// resume from the same weight
+	cd.Spec.Suspend = false
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, resumedWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, canaryWeight+mocks.canary.GetAnalysis().StepWeight, resumedWeight)
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	condition = getCanaryCondition(cd.Status, flaggerv1.SuspendedType)
+	require.NotNil(t, condition)
+	assert.Equal(t, corev1.ConditionFalse, condition.Status)
+}
+
+func TestTimeWindow_Contains(t *testing.T) {
+	// Friday
+	now := time.Date(2022, 3, 4, 3, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		window flaggerv1.TimeWindow
+		want   bool
+	}{
+		{name: "inside", window: flaggerv1.TimeWindow{Start: "02:00", End: "05:00"}, want: true},
+		{name: "outside", window: flaggerv1.TimeWindow{Start: "04:00", End: "05:00"}, want: false},
+		{name: "day", window: flaggerv1.TimeWindow{Start: "02:00", End: "05:00", Days: []string{"Sat", "Sun"}}, want: false},
+		{name: "midnight", window: flaggerv1.TimeWindow{Start: "22:00", End: "04:00", Days: []string{"Thu"}}, want: true},
+		{name: "time zone", window: flaggerv1.TimeWindow{Start: "02:00", End: "05:00", TimeZone: "America/New_York"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := tt.window.Contains(now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+
+	_, err := flaggerv1.TimeWindow{Start: "2am", End: "05:00"}.Contains(now)
+	require.Error(t, err)
+}
+
+func TestScheduler_DeploymentSkipAnalysis(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	// initializing
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// make primary ready
+	mocks.makePrimaryReady(t)
+
+	// initialized
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// enable skip
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	cd.Spec.SkipAnalysis = true
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	// advance
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, c.Spec.SkipAnalysis)
+	assert.Equal(t, flaggerv1.CanaryPhaseSucceeded, c.Status.Phase)
+}
+
+func TestScheduler_DeploymentSkipAnalysisRules(t *testing.T) {
+	cd := newDeploymentTestCanary()
+	cd.Spec.SkipAnalysisRules = []flaggerv1.SkipAnalysisRule{
+		{ImageTag: "^hotfix-"},
+		{Annotation: "app.kubernetes.io/change", AnnotationValue: "^revert"},
+	}
+	mocks := newDeploymentFixture(cd)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// the revision doesn't match the rules
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+
+	// the revert revision is promoted without analysis
+	dep2.Spec.Template.Annotations = map[string]string{"app.kubernetes.io/change": "revert-1234"}
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseSucceeded))
+
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "revert-1234", primary.Spec.Template.Annotations["app.kubernetes.io/change"])
+
+	// the hotfix revision is promoted without analysis
+	dep2.Spec.Template.Annotations = nil
+	dep2.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:hotfix-1.2.2"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+	mocks.makeCanaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseSucceeded))
+
+	primary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
 	require.NoError(t, err)
-	assert.Equal(t, int32(1), *c.Spec.Replicas)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:hotfix-1.2.2", primary.Spec.Template.Spec.Containers[0].Image)
 }
 
-func TestScheduler_DeploymentRollback(t *testing.T) {
-	mocks := newDeploymentFixture(nil)
-	// initializing
+func TestScheduler_DeploymentFastPath(t *testing.T) {
+	cd := newDeploymentTestCanary()
+	cd.Spec.FastPath = &flaggerv1.FastPath{}
+	mocks := newDeploymentFixture(cd)
 	mocks.ctrl.advanceCanary("podinfo", "default")
-
-	// make primary ready
 	mocks.makePrimaryReady(t)
-
-	// initialized
 	mocks.ctrl.advanceCanary("podinfo", "default")
 
-	// update failed checks to max
-	err := mocks.deployer.SyncStatus(mocks.canary, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryPhaseProgressing, FailedChecks: 10})
-	require.NoError(t, err)
-
-	// set a metric check to fail
-	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	// the resources change is promoted without analysis
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
 	require.NoError(t, err)
-	cd := c.DeepCopy()
-	cd.Spec.Analysis.Metrics = append(c.Spec.Analysis.Metrics, flaggerv1.CanaryMetric{
-		Name:     "fail",
-		Interval: "1m",
-		ThresholdRange: &flaggerv1.CanaryThresholdRange{
-			Min: toFloatPtr(0),
-			Max: toFloatPtr(50),
-		},
-		Query: "fail",
-	})
-	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	dep.Spec.Template.Spec.Containers[0].Resources.Requests = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("200m"),
+	}
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep, metav1.UpdateOptions{})
 	require.NoError(t, err)
-
-	// run metric checks
 	mocks.ctrl.advanceCanary("podinfo", "default")
-
-	// finalise analysis
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+	mocks.makeCanaryReady(t)
 	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseSucceeded))
 
-	// check status
-	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
 	require.NoError(t, err)
+	assert.Equal(t, "200m", primary.Spec.Template.Spec.Containers[0].Resources.Requests.Cpu().String())
 
-	assert.Equal(t, flaggerv1.CanaryPhaseFailed, c.Status.Phase)
+	// the image change is analysed
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.False(t, c.Status.FastPath)
 }
 
-func TestScheduler_DeploymentSkipAnalysis(t *testing.T) {
-	mocks := newDeploymentFixture(nil)
-	// initializing
+func TestScheduler_DeploymentFastPathShortAnalysis(t *testing.T) {
+	cd := newDeploymentTestCanary()
+	cd.Spec.FastPath = &flaggerv1.FastPath{
+		Fields: []flaggerv1.FastPathField{flaggerv1.ResourcesFastPathField, flaggerv1.AnnotationsFastPathField},
+		Policy: flaggerv1.ShortAnalysisFastPathPolicy,
+	}
+	cd.Spec.Analysis = &flaggerv1.CanaryAnalysis{
+		Interval:   "1m",
+		StepWeight: 10,
+		MaxWeight:  50,
+	}
+	mocks := newDeploymentFixture(cd)
 	mocks.ctrl.advanceCanary("podinfo", "default")
-
-	// make primary ready
 	mocks.makePrimaryReady(t)
-
-	// initialized
 	mocks.ctrl.advanceCanary("podinfo", "default")
 
-	// enable skip
-	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
-	require.NoError(t, err)
-	cd.Spec.SkipAnalysis = true
-	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), cd, metav1.UpdateOptions{})
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
 	require.NoError(t, err)
-
-	// update
-	dep2 := newDeploymentTestDeploymentV2()
-	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	dep.Spec.Template.Annotations = map[string]string{"app.kubernetes.io/change": "cpu-bump"}
+	dep.Spec.Template.Spec.Containers[0].Resources.Limits = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("2"),
+	}
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep, metav1.UpdateOptions{})
 	require.NoError(t, err)
-
-	// detect changes
 	mocks.ctrl.advanceCanary("podinfo", "default")
 	mocks.makeCanaryReady(t)
 
-	// advance
+	// a single step to the max weight
 	mocks.ctrl.advanceCanary("podinfo", "default")
-
 	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
 	require.NoError(t, err)
-	assert.True(t, c.Spec.SkipAnalysis)
-	assert.Equal(t, flaggerv1.CanaryPhaseSucceeded, c.Status.Phase)
+	assert.True(t, c.Status.FastPath)
+	assert.Equal(t, 50, c.Status.CanaryWeight)
+
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhasePromoting))
 }
 
 func TestScheduler_DeploymentAnalysisPhases(t *testing.T) {
@@ -197,6 +1242,151 @@ func TestScheduler_DeploymentAnalysisPhases(t *testing.T) {
 	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseSucceeded))
 }
 
+func TestScheduler_DeploymentCanaryScaleDownDelay(t *testing.T) {
+	cd := newDeploymentTestCanary()
+	cd.Spec.Analysis = &flaggerv1.CanaryAnalysis{
+		Interval:             "1m",
+		StepWeight:           100,
+		CanaryScaleDownDelay: "1h",
+	}
+	mocks := newDeploymentFixture(cd)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	// advance until the promotion has finished
+	for i := 0; i < 4; i++ {
+		mocks.ctrl.advanceCanary("podinfo", "default")
+	}
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseFinalising))
+
+	// the canary keeps running during the delay
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseFinalising))
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, int32(0), *dep.Spec.Replicas)
+
+	// the canary is scaled down once the delay has passed
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	c.Status.LastTransitionTime = metav1.NewTime(time.Now().Add(-time.Hour))
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").UpdateStatus(context.TODO(), c, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseSucceeded))
+	dep, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *dep.Spec.Replicas)
+}
+
+func TestScheduler_DeploymentConflictPolicy(t *testing.T) {
+	cd := newDeploymentTestCanary()
+	cd.Spec.ConflictPolicy = flaggerv1.RevertWithWarningConflictPolicy
+	mocks := newDeploymentFixture(cd)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	image := primary.Spec.Template.Spec.Containers[0].Image
+
+	// out-of-band change of the primary
+	primary.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:drifted"
+	primary.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "DRIFTED", Value: "true"}}
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), primary, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	primary, err = mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, image, primary.Spec.Template.Spec.Containers[0].Image)
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseInitialized))
+}
+
+func TestScheduler_DeploymentDryRun(t *testing.T) {
+	cd := newDeploymentTestCanary()
+	cd.Spec.Analysis = &flaggerv1.CanaryAnalysis{
+		Interval:   "1m",
+		StepWeight: 50,
+		MaxWeight:  100,
+		Mirror:     true,
+		DryRun:     true,
+	}
+	mocks := newDeploymentFixture(cd)
+
+	// initializing
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// make primary ready
+	mocks.makePrimaryReady(t)
+
+	// initialized
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	// the traffic is mirrored during the dry run
+	for i := 1; i <= 2; i++ {
+		mocks.ctrl.advanceCanary("podinfo", "default")
+		require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+
+		primaryWeight, canaryWeight, mirrored, err := mocks.router.GetRoutes(mocks.canary)
+		require.NoError(t, err)
+		assert.Equal(t, 100, primaryWeight)
+		assert.Equal(t, 0, canaryWeight)
+		assert.True(t, mirrored)
+
+		c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, i, c.Status.Iterations)
+	}
+
+	// dry run passed
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseDryRunSucceeded))
+
+	primaryWeight, canaryWeight, mirrored, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 100, primaryWeight)
+	assert.Equal(t, 0, canaryWeight)
+	assert.False(t, mirrored)
+
+	// the primary is not promoted
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/stefanprodan/podinfo:1.2.0", primary.Spec.Template.Spec.Containers[0].Image)
+	canary, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *canary.Spec.Replicas)
+
+	// the canary release starts once the dry run is turned off
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	c.Spec.Analysis.DryRun = false
+	_, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Update(context.TODO(), c, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	require.NoError(t, assertPhase(mocks.flaggerClient, "podinfo", flaggerv1.CanaryPhaseProgressing))
+}
+
 func TestScheduler_DeploymentBlueGreenAnalysisPhases(t *testing.T) {
 	cd := newDeploymentTestCanary()
 	cd.Spec.Analysis = &flaggerv1.CanaryAnalysis{
@@ -431,6 +1621,52 @@ func TestScheduler_DeploymentMirroring(t *testing.T) {
 	assert.False(t, mirrored)
 }
 
+func TestScheduler_DeploymentBlueGreenMirrorWarmup(t *testing.T) {
+	cd := newDeploymentTestCanary()
+	cd.Spec.Analysis = &flaggerv1.CanaryAnalysis{
+		Interval:               "1m",
+		Iterations:             3,
+		MirrorWarmupIterations: 1,
+	}
+	mocks := newDeploymentFixture(cd)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect pod spec changes
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	// no traffic is mirrored before the warm-up
+	for i := 0; i < 2; i++ {
+		mocks.ctrl.advanceCanary("podinfo", "default")
+		_, _, mirrored, err := mocks.router.GetRoutes(mocks.canary)
+		require.NoError(t, err)
+		assert.False(t, mirrored)
+	}
+
+	// traffic is mirrored during the last iteration
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	primaryWeight, canaryWeight, mirrored, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 100, primaryWeight)
+	assert.Equal(t, 0, canaryWeight)
+	assert.True(t, mirrored)
+
+	// traffic is routed to canary after the warm-up
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	primaryWeight, canaryWeight, mirrored, err = mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 0, primaryWeight)
+	assert.Equal(t, 100, canaryWeight)
+	assert.False(t, mirrored)
+}
+
 func TestScheduler_DeploymentABTesting(t *testing.T) {
 	mocks := newDeploymentFixture(newDeploymentTestCanaryAB())
 	// initializing
@@ -496,6 +1732,73 @@ func TestScheduler_DeploymentABTesting(t *testing.T) {
 	assert.Equal(t, flaggerv1.CanaryPhaseSucceeded, c.Status.Phase)
 }
 
+func TestScheduler_DeploymentABTestingProgressive(t *testing.T) {
+	cd := newDeploymentTestCanaryAB()
+	cd.Spec.Analysis.Iterations = 2
+	cd.Spec.Analysis.StepWeight = 50
+	cd.Spec.Analysis.MaxWeight = 50
+	cd.Spec.Analysis.ProgressiveAfterMatch = true
+	mocks := newDeploymentFixture(cd)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// detect pod spec changes
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+
+	// the cohort is routed to canary during the A/B testing iterations
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, vs.Spec.Http, 2)
+
+	// all users are routed to primary after the A/B testing iterations
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, cd.IsProgressiveAfterMatch())
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+
+	vs, err = mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, vs.Spec.Http, 1)
+	assert.Empty(t, vs.Spec.Http[0].Match)
+
+	primaryWeight, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 100, primaryWeight)
+	assert.Equal(t, 0, canaryWeight)
+
+	// the traffic of all users is shifted progressively
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	vs, err = mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, vs.Spec.Http, 1)
+
+	primaryWeight, canaryWeight, _, err = mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 50, primaryWeight)
+	assert.Equal(t, 50, canaryWeight)
+
+	// promote
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	cd, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseFinalising, cd.Status.Phase)
+}
+
 func TestScheduler_DeploymentPortDiscovery(t *testing.T) {
 	mocks := newDeploymentFixture(nil)
 
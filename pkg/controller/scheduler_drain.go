@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// runRollbackDrain finishes the rollback once no traffic has been routed to the canary
+// for the rollback drain period, the drain restarts if the router reports canary traffic
+func (c *Controller) runRollbackDrain(cd *flaggerv1.Canary, canaryController canary.Controller,
+	meshRouter router.Interface, canaryWeight int) {
+	drain := cd.Status.RollbackDrain
+	if canaryWeight != 0 {
+		if err := meshRouter.SetRoutes(cd, c.totalWeight(cd), 0, false); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return
+		}
+		if err := c.setRollbackDrain(cd, &flaggerv1.CanaryRollbackDrain{Reason: drain.Reason, StartTime: metav1.Now()}); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return
+		}
+		c.canaryLogger(cd).Infof("Waiting for the traffic to be removed from %s.%s", cd.Name, cd.Namespace)
+		return
+	}
+
+	if remaining := cd.GetAnalysisRollbackDrainPeriod() - time.Since(drain.StartTime.Time); remaining > 0 {
+		c.canaryLogger(cd).Infof("Draining %s.%s for %v", cd.Name, cd.Namespace, remaining.Round(time.Second))
+		return
+	}
+
+	c.finishRollback(cd, canaryController, drain.Reason)
+}
+
+// setRollbackDrain records in the canary status the rollback waiting for the canary traffic to drain
+func (c *Controller) setRollbackDrain(cd *flaggerv1.Canary, drain *flaggerv1.CanaryRollbackDrain) error {
+	firstTry := true
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			cd, err = c.flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+
+		cdCopy := cd.DeepCopy()
+		cdCopy.Status.RollbackDrain = drain
+		_, err = c.flaggerClient.FlaggerV1beta1().Canaries(ns).UpdateStatus(context.TODO(), cdCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		firstTry = false
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
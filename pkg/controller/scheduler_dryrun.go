@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// runDryRun counts the passed analysis iterations without shifting the user traffic,
+// the requests are mirrored to the canary if mirroring is enabled. When all the iterations
+// have passed the canary is scaled to zero and the primary is left untouched.
+func (c *Controller) runDryRun(canary *flaggerv1.Canary, canaryController canary.Controller,
+	meshRouter router.Interface, provider string, mirrored bool) {
+	iterations := c.dryRunIterations(canary)
+
+	if canary.Status.Iterations < iterations {
+		if provider != flaggerv1.KubernetesProvider && canary.GetAnalysis().Mirror && !mirrored {
+			if err := meshRouter.SetRoutes(canary, c.totalWeight(canary), 0, true); err != nil {
+				c.recordEventWarningf(canary, "%v", err)
+				return
+			}
+			c.canaryLogger(canary).Infof("Start traffic mirroring")
+		}
+		if err := canaryController.SetStatusIterations(canary, canary.Status.Iterations+1); err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+			return
+		}
+		c.recordEventInfof(canary, "Advance %s.%s dry-run iteration %v/%v",
+			canary.Name, canary.Namespace, canary.Status.Iterations+1, iterations)
+		return
+	}
+
+	if mirrored {
+		if err := meshRouter.SetRoutes(canary, c.totalWeight(canary), 0, false); err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+			return
+		}
+		c.canaryLogger(canary).Infof("Stop traffic mirroring")
+	}
+
+	if err := canaryController.ScaleToZero(canary); err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
+
+	if err := canaryController.SetStatusPhase(canary, flaggerv1.CanaryPhaseDryRunSucceeded); err != nil {
+		c.recordEventWarningf(canary, "%v", err)
+		return
+	}
+	c.recorder.SetStatus(canary, flaggerv1.CanaryPhaseDryRunSucceeded)
+	c.runPostRolloutHooks(canary, flaggerv1.CanaryPhaseDryRunSucceeded)
+	c.recordEventInfof(canary, "Dry-run analysis passed! Scaling down %s.%s without promotion",
+		canary.Spec.TargetRef.Name, canary.Namespace)
+	c.alert(canary, "Dry-run analysis completed successfully, the canary was not promoted.",
+		false, flaggerv1.SeverityInfo)
+}
+
+// dryRunIterations returns the number of iterations of the dry-run analysis,
+// for the progressive traffic increase it matches the number of step weights
+func (c *Controller) dryRunIterations(canary *flaggerv1.Canary) int {
+	analysis := canary.GetAnalysis()
+	switch {
+	case analysis.Iterations > 0:
+		return analysis.Iterations
+	case len(analysis.StepWeights) > 0:
+		return len(analysis.StepWeights)
+	case analysis.StepWeight > 0:
+		return (c.maxWeight(canary) + analysis.StepWeight - 1) / analysis.StepWeight
+	}
+	return 1
+}
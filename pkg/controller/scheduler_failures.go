@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+)
+
+// analysisFailure holds a check that failed an analysis run
+type analysisFailure struct {
+	kind    flaggerv1.CanaryCheckKind
+	name    string
+	value   *float64
+	message string
+}
+
+// observe records the last value of the metric and the threshold range it is checked against,
+// the values are recorded before the threshold checks so that the last value is known when a check fails
+func (r *analysisRun) observe(metric flaggerv1.CanaryMetric, val float64) {
+	r.values[metric.Name] = val
+	r.thresholds[metric.Name] = formatThresholdRange(checkThresholdRange(metric))
+}
+
+// failMetric marks the metric as failed, the crossed threshold is
+// described when the metric value is known
+func (r *analysisRun) failMetric(metric flaggerv1.CanaryMetric) {
+	failure := analysisFailure{kind: flaggerv1.MetricCheckKind, name: metric.Name}
+	if val, ok := r.values[metric.Name]; ok {
+		failure.value = &val
+
+		tr := checkThresholdRange(metric)
+		if tr.Min != nil && val < *tr.Min {
+			failure.message = fmt.Sprintf("%s %.2f < %v", metric.Name, val, *tr.Min)
+		}
+		if tr.Max != nil && val > *tr.Max {
+			failure.message = fmt.Sprintf("%s %.2f > %v", metric.Name, val, *tr.Max)
+		}
+	}
+	r.failures = append(r.failures, failure)
+}
+
+// passMetric marks the metric as passed
+func (r *analysisRun) passMetric(name string) {
+	r.passed[name] = true
+}
+
+// failWebhook marks the webhook as failed
+func (r *analysisRun) failWebhook(name string, err error) {
+	r.failures = append(r.failures, analysisFailure{kind: flaggerv1.WebhookCheckKind, name: name, message: err.Error()})
+}
+
+// checkThresholdRange returns the threshold range the metric value is checked against,
+// the burn rate metrics are checked against the max burn rate
+func checkThresholdRange(metric flaggerv1.CanaryMetric) flaggerv1.CanaryThresholdRange {
+	if metric.BurnRate != nil {
+		if burnRate, err := newMetricBurnRate(metric); err == nil {
+			return flaggerv1.CanaryThresholdRange{Max: &burnRate.maxBurnRate}
+		}
+	}
+	return metricThresholdRange(metric)
+}
+
+// formatThresholdRange describes the threshold range e.g. >= 99, <= 500
+func formatThresholdRange(tr flaggerv1.CanaryThresholdRange) string {
+	var bounds []string
+	if tr.Min != nil {
+		bounds = append(bounds, fmt.Sprintf(">= %v", *tr.Min))
+	}
+	if tr.Max != nil {
+		bounds = append(bounds, fmt.Sprintf("<= %v", *tr.Max))
+	}
+	return strings.Join(bounds, ", ")
+}
+
+// metricFailedChecks holds the failed checks of the metrics that have their own failure threshold
+type metricFailedChecks map[string]int
+
+// countMetricFailedChecks increments the failed checks of each failed metric that has its own failure threshold
+// and resets the failed checks of the passed metrics that count consecutive failures.
+// All the metrics are checked on each run, it returns true if every failure of the run
+// was counted against the threshold of its metric
+func countMetricFailedChecks(cd *flaggerv1.Canary, ok bool, run *analysisRun) bool {
+	status := make(map[string]int, len(cd.Status.Metrics))
+	for _, m := range cd.Status.Metrics {
+		status[m.Name] = m.FailedChecks
+	}
+	thresholds := make(map[string]int, len(cd.GetAnalysis().Metrics))
+	for _, metric := range cd.GetAnalysis().Metrics {
+		thresholds[metric.Name] = metric.FailureThreshold
+		if run.passed[metric.Name] && metric.ConsecutiveFailures && status[metric.Name] > 0 {
+			run.failedChecks[metric.Name] = 0
+		}
+	}
+
+	counted := !ok && !run.aborted && len(run.failures) > 0
+	for _, failure := range run.failures {
+		if failure.kind != flaggerv1.MetricCheckKind || thresholds[failure.name] == 0 {
+			counted = false
+			continue
+		}
+		run.failedChecks[failure.name] = status[failure.name] + 1
+	}
+	return counted
+}
+
+// getFailedMetricThreshold returns the first metric whose failed checks reached its own failure threshold
+func getFailedMetricThreshold(cd *flaggerv1.Canary) (string, int, bool) {
+	status := make(map[string]int, len(cd.Status.Metrics))
+	for _, m := range cd.Status.Metrics {
+		status[m.Name] = m.FailedChecks
+	}
+
+	for _, metric := range cd.GetAnalysis().Metrics {
+		if metric.FailureThreshold > 0 && status[metric.Name] >= metric.FailureThreshold {
+			return metric.Name, metric.FailureThreshold, true
+		}
+	}
+	return "", 0, false
+}
+
+// setFailureStatus replaces the previous failures of the checks in the canary status
+func (c *Controller) setFailureStatus(cd *flaggerv1.Canary, canaryController canary.Controller, run *analysisRun) {
+	if len(run.failures) == 0 {
+		return
+	}
+
+	now := metav1.Now()
+	failed := make(map[string]bool, len(run.failures))
+	for _, failure := range run.failures {
+		failed[string(failure.kind)+"/"+failure.name] = true
+	}
+	failures := make([]flaggerv1.CanaryCheckFailure, 0, len(cd.Status.Failures)+len(run.failures))
+	for _, f := range cd.Status.Failures {
+		if !failed[string(f.Kind)+"/"+f.Name] {
+			failures = append(failures, f)
+		}
+	}
+	for _, failure := range run.failures {
+		failures = append(failures, flaggerv1.CanaryCheckFailure{
+			Kind:               failure.kind,
+			Name:               failure.name,
+			Value:              failure.value,
+			Message:            failure.message,
+			CanaryWeight:       cd.Status.CanaryWeight,
+			LastTransitionTime: now,
+		})
+	}
+
+	if err := canaryController.SetStatusFailures(cd, failures); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+	}
+}
+
+// newRollbackPayload returns the cause of the rollback with the weight and
+// the failed checks recorded before the traffic is routed back to primary
+func newRollbackPayload(cd *flaggerv1.Canary, reason flaggerv1.RollbackReason) *flaggerv1.CanaryRollbackPayload {
+	return &flaggerv1.CanaryRollbackPayload{
+		Reason:       reason,
+		CanaryWeight: cd.Status.CanaryWeight,
+		Iterations:   cd.Status.Iterations,
+		FailedChecks: cd.Status.FailedChecks,
+		Failures:     cd.Status.Failures,
+	}
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// shortenAnalysis reduces the analysis of a fast path revision to a single iteration
+// or to a single step to the max weight, only the in-memory canary is changed
+func shortenAnalysis(cd *flaggerv1.Canary, maxWeight int) {
+	analysis := cd.GetAnalysis()
+	if analysis.Iterations > 1 {
+		analysis.Iterations = 1
+	}
+	analysis.StepWeights = nil
+	analysis.StepWeightDurations = nil
+	analysis.Adaptive = nil
+	analysis.StepWeight = maxWeight
+	analysis.MaxWeight = maxWeight
+}
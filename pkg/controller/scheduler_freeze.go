@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// FreezeWindowSet is the list of change freeze windows set by the operators
+type FreezeWindowSet struct {
+	Windows []FreezeWindow `json:"windows"`
+}
+
+// FreezeWindow is a period during which the canaries are held and the promotions deferred,
+// it is either a date range or a weekly recurring time window
+type FreezeWindow struct {
+	// Name of the window e.g. black-friday
+	Name string `json:"name"`
+
+	// From is the start of the date range in the RFC3339 format
+	From string `json:"from,omitempty"`
+
+	// To is the end of the date range in the RFC3339 format
+	To string `json:"to,omitempty"`
+
+	// Weekly is a recurring time window
+	Weekly *flaggerv1.TimeWindow `json:"weekly,omitempty"`
+}
+
+// FreezeWindows holds the freeze windows read from a file usually mounted from a ConfigMap,
+// the file is read again when it changes so that the windows can be updated without a restart
+type FreezeWindows struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	windows []FreezeWindow
+}
+
+// LoadFreezeWindows reads and validates the YAML freeze windows from a file
+func LoadFreezeWindows(path string) (*FreezeWindows, error) {
+	f := &FreezeWindows{path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Len returns the number of freeze windows
+func (f *FreezeWindows) Len() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.windows)
+}
+
+// Active returns the name of the freeze window that contains the time,
+// the previous windows are kept if the changed file is not valid
+func (f *FreezeWindows) Active(t time.Time) (string, error) {
+	if f == nil {
+		return "", nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reloadErr := f.reload()
+	for _, w := range f.windows {
+		ok, err := w.Contains(t)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return w.Name, reloadErr
+		}
+	}
+	return "", reloadErr
+}
+
+// reload reads the file if it has been modified since it was last read
+func (f *FreezeWindows) reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("reading freeze windows failed: %w", err)
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("reading freeze windows failed: %w", err)
+	}
+	defer file.Close()
+
+	windows, err := ParseFreezeWindows(file)
+	if err != nil {
+		return err
+	}
+	f.windows, f.modTime = windows, info.ModTime()
+	return nil
+}
+
+// ParseFreezeWindows decodes and validates a YAML or JSON freeze window set
+func ParseFreezeWindows(r io.Reader) ([]FreezeWindow, error) {
+	var set FreezeWindowSet
+	if err := yaml.NewYAMLOrJSONDecoder(r, 4096).Decode(&set); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decoding freeze windows failed: %w", err)
+	}
+
+	for _, w := range set.Windows {
+		if w.Name == "" {
+			return nil, fmt.Errorf("freeze window name is required")
+		}
+		if (w.From != "" || w.To != "") == (w.Weekly != nil) {
+			return nil, fmt.Errorf("freeze window %s must set either a date range or a weekly window", w.Name)
+		}
+		// the windows are checked against the current time to report the format errors on load
+		if _, err := w.Contains(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return set.Windows, nil
+}
+
+// Contains returns true if the time is inside the window
+func (w FreezeWindow) Contains(t time.Time) (bool, error) {
+	if w.Weekly != nil {
+		ok, err := w.Weekly.Contains(t)
+		if err != nil {
+			return false, fmt.Errorf("freeze window %s: %w", w.Name, err)
+		}
+		return ok, nil
+	}
+
+	from, err := time.Parse(time.RFC3339, w.From)
+	if err != nil {
+		return false, fmt.Errorf("freeze window %s from %s is not valid, expected RFC3339", w.Name, w.From)
+	}
+	to, err := time.Parse(time.RFC3339, w.To)
+	if err != nil {
+		return false, fmt.Errorf("freeze window %s to %s is not valid, expected RFC3339", w.Name, w.To)
+	}
+	if !to.After(from) {
+		return false, fmt.Errorf("freeze window %s ends before it starts", w.Name)
+	}
+	return !t.Before(from) && t.Before(to), nil
+}
+
+// activeFreezeWindow returns the name of the change freeze window the canary is in
+func (c *Controller) activeFreezeWindow(canary *flaggerv1.Canary) string {
+	name, err := c.freezeWindows.Active(time.Now())
+	if err != nil {
+		c.canaryLogger(canary).Errorf("%v", err)
+	}
+	return name
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func writeFreezeWindows(t *testing.T, path string, from, to time.Time, modTime time.Time) {
+	data := fmt.Sprintf("windows:\n- name: black-friday\n  from: %s\n  to: %s\n",
+		from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestParseFreezeWindows(t *testing.T) {
+	windows, err := ParseFreezeWindows(strings.NewReader(`
+windows:
+- name: black-friday
+  from: "2022-11-25T00:00:00Z"
+  to: "2022-11-29T00:00:00Z"
+- name: weekend
+  weekly:
+    start: "18:00"
+    end: "08:00"
+    days: [Fri, Sat, Sun]
+`))
+	require.NoError(t, err)
+	require.Len(t, windows, 2)
+
+	ok, err := windows[0].Contains(time.Date(2022, 11, 28, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = windows[0].Contains(time.Date(2022, 11, 29, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Saturday evening
+	ok, err = windows[1].Contains(time.Date(2022, 11, 19, 20, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// Saturday morning, the window started on Friday
+	ok, err = windows[1].Contains(time.Date(2022, 11, 19, 2, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// Saturday noon, between the nightly windows
+	ok, err = windows[1].Contains(time.Date(2022, 11, 19, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	// Monday
+	ok, err = windows[1].Contains(time.Date(2022, 11, 21, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, data := range []string{
+		"windows:\n- from: \"2022-11-25T00:00:00Z\"\n  to: \"2022-11-29T00:00:00Z\"\n",
+		"windows:\n- name: black-friday\n",
+		"windows:\n- name: black-friday\n  from: \"2022-11-25\"\n  to: \"2022-11-29\"\n",
+		"windows:\n- name: black-friday\n  from: \"2022-11-29T00:00:00Z\"\n  to: \"2022-11-25T00:00:00Z\"\n",
+	} {
+		_, err := ParseFreezeWindows(strings.NewReader(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestFreezeWindows_Active(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "windows.yaml")
+	now := time.Now()
+	writeFreezeWindows(t, path, now.Add(-time.Hour), now.Add(time.Hour), now.Add(-time.Minute))
+
+	windows, err := LoadFreezeWindows(path)
+	require.NoError(t, err)
+	name, err := windows.Active(now)
+	require.NoError(t, err)
+	assert.Equal(t, "black-friday", name)
+
+	// the file is read again when it changes
+	writeFreezeWindows(t, path, now.Add(-2*time.Hour), now.Add(-time.Hour), now)
+	name, err = windows.Active(now)
+	require.NoError(t, err)
+	assert.Empty(t, name)
+
+	// the previous windows are kept when the file is not valid
+	require.NoError(t, os.WriteFile(path, []byte("windows:\n- name: invalid\n"), 0644))
+	require.NoError(t, os.Chtimes(path, now.Add(time.Minute), now.Add(time.Minute)))
+	name, err = windows.Active(now)
+	require.Error(t, err)
+	assert.Empty(t, name)
+	assert.Equal(t, 1, windows.Len())
+
+	// no windows are active when the freeze windows are not set
+	var unset *FreezeWindows
+	name, err = unset.Active(now)
+	require.NoError(t, err)
+	assert.Empty(t, name)
+}
+
+func TestScheduler_DeploymentFreezeWindows(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makePrimaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	path := filepath.Join(t.TempDir(), "windows.yaml")
+	now := time.Now()
+	writeFreezeWindows(t, path, now.Add(-time.Hour), now.Add(time.Hour), now.Add(-time.Minute))
+	windows, err := LoadFreezeWindows(path)
+	require.NoError(t, err)
+	mocks.ctrl.freezeWindows = windows
+
+	// update
+	dep2 := newDeploymentTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// the analysis doesn't start during the freeze
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.makeCanaryReady(t)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err := mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 0, canaryWeight)
+
+	cd, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, flaggerv1.CanaryPhaseProgressing, cd.Status.Phase)
+	assert.Equal(t, 0, cd.Status.Iterations)
+
+	// the analysis starts when the freeze ends
+	writeFreezeWindows(t, path, now.Add(-2*time.Hour), now.Add(-time.Hour), now)
+	mocks.ctrl.advanceCanary("podinfo", "default")
+	mocks.ctrl.advanceCanary("podinfo", "default")
+
+	_, canaryWeight, _, err = mocks.router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Greater(t, canaryWeight, 0)
+}
@@ -17,8 +17,6 @@ limitations under the License.
 package controller
 
 import (
-	"fmt"
-
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 	"github.com/fluxcd/flagger/pkg/canary"
 )
@@ -26,7 +24,7 @@ import (
 func (c *Controller) runConfirmTrafficIncreaseHooks(canary *flaggerv1.Canary) bool {
 	for _, webhook := range canary.GetAnalysis().Webhooks {
 		if webhook.Type == flaggerv1.ConfirmTrafficIncreaseHook {
-			err := CallWebhook(canary.Name, canary.Namespace, flaggerv1.CanaryPhaseProgressing, webhook)
+			err := c.runWebhook(canary, flaggerv1.CanaryPhaseProgressing, webhook)
 			if err != nil {
 				c.recordEventWarningf(canary, "Halt %s.%s advancement waiting for traffic increase approval %s",
 					canary.Name, canary.Namespace, webhook.Name)
@@ -42,13 +40,27 @@ func (c *Controller) runConfirmTrafficIncreaseHooks(canary *flaggerv1.Canary) bo
 }
 
 func (c *Controller) runConfirmRolloutHooks(canary *flaggerv1.Canary, canaryController canary.Controller) bool {
+	if !c.runStartDependencyGate(canary, canaryController) {
+		return false
+	}
+	if isGateClosed(canary) {
+		if canary.Status.Phase != flaggerv1.CanaryPhaseWaiting {
+			if err := canaryController.SetStatusPhase(canary, flaggerv1.CanaryPhaseWaiting); err != nil {
+				c.canaryLogger(canary).Errorf("%v", err)
+			}
+			c.recordEventWarningf(canary, "Halt %s.%s advancement, the %s annotation is set to %s",
+				canary.Name, canary.Namespace, flaggerv1.GateAnnotation, flaggerv1.GateClosed)
+			c.auditGate(canary, flaggerv1.GateAnnotation, flaggerv1.CheckFailed, flaggerv1.CanaryPhaseWaiting, "Rollout halted by the closed gate")
+		}
+		return false
+	}
 	for _, webhook := range canary.GetAnalysis().Webhooks {
 		if webhook.Type == flaggerv1.ConfirmRolloutHook {
-			err := CallWebhook(canary.Name, canary.Namespace, flaggerv1.CanaryPhaseProgressing, webhook)
+			err := c.runWebhook(canary, flaggerv1.CanaryPhaseProgressing, webhook)
 			if err != nil {
 				if canary.Status.Phase != flaggerv1.CanaryPhaseWaiting {
 					if err := canaryController.SetStatusPhase(canary, flaggerv1.CanaryPhaseWaiting); err != nil {
-						c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).Errorf("%v", err)
+						c.canaryLogger(canary).Errorf("%v", err)
 					}
 					c.recordEventWarningf(canary, "Halt %s.%s advancement waiting for approval %s",
 						canary.Name, canary.Namespace, webhook.Name)
@@ -60,7 +72,7 @@ func (c *Controller) runConfirmRolloutHooks(canary *flaggerv1.Canary, canaryCont
 			} else {
 				if canary.Status.Phase == flaggerv1.CanaryPhaseWaiting {
 					if err := canaryController.SetStatusPhase(canary, flaggerv1.CanaryPhaseProgressing); err != nil {
-						c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).Errorf("%v", err)
+						c.canaryLogger(canary).Errorf("%v", err)
 						return false
 					}
 					if err := canaryController.ScaleFromZero(canary); err != nil {
@@ -77,13 +89,26 @@ func (c *Controller) runConfirmRolloutHooks(canary *flaggerv1.Canary, canaryCont
 }
 
 func (c *Controller) runConfirmPromotionHooks(canary *flaggerv1.Canary, canaryController canary.Controller) bool {
+	if isGateClosed(canary) {
+		if canary.Status.Phase != flaggerv1.CanaryPhaseWaitingPromotion {
+			if err := canaryController.SetStatusPhase(canary, flaggerv1.CanaryPhaseWaitingPromotion); err != nil {
+				c.canaryLogger(canary).Errorf("%v", err)
+			}
+			c.recordEventWarningf(canary, "Halt %s.%s advancement waiting for promotion, the %s annotation is set to %s",
+				canary.Name, canary.Namespace, flaggerv1.GateAnnotation, flaggerv1.GateClosed)
+			c.auditGate(canary, flaggerv1.GateAnnotation, flaggerv1.CheckFailed, flaggerv1.CanaryPhaseWaitingPromotion, "Promotion halted by the closed gate")
+		} else {
+			c.repeatLastIteration(canary, canaryController)
+		}
+		return false
+	}
 	for _, webhook := range canary.GetAnalysis().Webhooks {
 		if webhook.Type == flaggerv1.ConfirmPromotionHook {
-			err := CallWebhook(canary.Name, canary.Namespace, flaggerv1.CanaryPhaseProgressing, webhook)
+			err := c.runWebhook(canary, flaggerv1.CanaryPhaseProgressing, webhook)
 			if err != nil {
 				if canary.Status.Phase != flaggerv1.CanaryPhaseWaitingPromotion {
 					if err := canaryController.SetStatusPhase(canary, flaggerv1.CanaryPhaseWaitingPromotion); err != nil {
-						c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).Errorf("%v", err)
+						c.canaryLogger(canary).Errorf("%v", err)
 					}
 					c.recordEventWarningf(canary, "Halt %s.%s advancement waiting for promotion approval %s",
 						canary.Name, canary.Namespace, webhook.Name)
@@ -91,9 +116,7 @@ func (c *Controller) runConfirmPromotionHooks(canary *flaggerv1.Canary, canaryCo
 						c.alert(canary, "Canary promotion is waiting for approval.", false, flaggerv1.SeverityWarn)
 					}
 				} else {
-					if err := canaryController.SetStatusIterations(canary, canary.GetAnalysis().Iterations-1); err != nil {
-						c.recordEventWarningf(canary, "%v", err)
-					}
+					c.repeatLastIteration(canary, canaryController)
 				}
 				return false
 			} else {
@@ -101,13 +124,31 @@ func (c *Controller) runConfirmPromotionHooks(canary *flaggerv1.Canary, canaryCo
 			}
 		}
 	}
-	return true
+	if !c.runReleaseGroupGate(canary, canaryController) {
+		return false
+	}
+	if !c.runPromotionDependencyGate(canary, canaryController) {
+		return false
+	}
+	return c.runManualPromotionGate(canary, canaryController)
+}
+
+// repeatLastIteration sets the iterations back by one while the promotion is halted so that
+// the A/B testing and Blue/Green checks keep running, the iterations are kept during the
+// progressive traffic increase that follows the A/B testing
+func (c *Controller) repeatLastIteration(cd *flaggerv1.Canary, canaryController canary.Controller) {
+	if cd.IsProgressiveAfterMatch() {
+		return
+	}
+	if err := canaryController.SetStatusIterations(cd, cd.GetAnalysis().Iterations-1); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+	}
 }
 
 func (c *Controller) runPreRolloutHooks(canary *flaggerv1.Canary) bool {
 	for _, webhook := range canary.GetAnalysis().Webhooks {
 		if webhook.Type == flaggerv1.PreRolloutHook {
-			err := CallWebhook(canary.Name, canary.Namespace, flaggerv1.CanaryPhaseProgressing, webhook)
+			err := c.runWebhook(canary, flaggerv1.CanaryPhaseProgressing, webhook)
 			if err != nil {
 				c.recordEventWarningf(canary, "Halt %s.%s advancement pre-rollout check %s failed %v",
 					canary.Name, canary.Namespace, webhook.Name, err)
@@ -123,7 +164,7 @@ func (c *Controller) runPreRolloutHooks(canary *flaggerv1.Canary) bool {
 func (c *Controller) runPostRolloutHooks(canary *flaggerv1.Canary, phase flaggerv1.CanaryPhase) bool {
 	for _, webhook := range canary.GetAnalysis().Webhooks {
 		if webhook.Type == flaggerv1.PostRolloutHook {
-			err := CallWebhook(canary.Name, canary.Namespace, phase, webhook)
+			err := c.runWebhook(canary, phase, webhook)
 			if err != nil {
 				c.recordEventWarningf(canary, "Post-rollout hook %s failed %v", webhook.Name, err)
 				return false
@@ -135,10 +176,26 @@ func (c *Controller) runPostRolloutHooks(canary *flaggerv1.Canary, phase flagger
 	return true
 }
 
+// runPostRollbackHooks sends the cause of the rollback and the failed checks to the post-rollback webhooks
+func (c *Controller) runPostRollbackHooks(canary *flaggerv1.Canary, rollback *flaggerv1.CanaryRollbackPayload) bool {
+	for _, webhook := range canary.GetAnalysis().Webhooks {
+		if webhook.Type == flaggerv1.PostRollbackHook {
+			err := c.runRollbackWebhook(canary, webhook, rollback)
+			if err != nil {
+				c.recordEventWarningf(canary, "Post-rollback hook %s failed %v", webhook.Name, err)
+				return false
+			} else {
+				c.recordEventInfof(canary, "Post-rollback check %s passed", webhook.Name)
+			}
+		}
+	}
+	return true
+}
+
 func (c *Controller) runRollbackHooks(canary *flaggerv1.Canary, phase flaggerv1.CanaryPhase) bool {
 	for _, webhook := range canary.GetAnalysis().Webhooks {
 		if webhook.Type == flaggerv1.RollbackHook {
-			err := CallWebhook(canary.Name, canary.Namespace, phase, webhook)
+			err := c.runWebhook(canary, phase, webhook)
 			if err != nil {
 				c.recordEventInfof(canary, "Rollback hook %s not signaling a rollback", webhook.Name)
 			} else {
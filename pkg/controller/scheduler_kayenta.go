@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/metrics/kayenta"
+)
+
+const (
+	kayentaMetricName    = "kayenta-score"
+	kayentaApplication   = "flagger"
+	kayentaPassScore     = 95
+	kayentaMarginalScore = 75
+	kayentaStep          = time.Minute
+	kayentaTimeout       = time.Minute
+)
+
+// runKayentaCheck delegates the metrics judgement to Kayenta, a marginal score
+// halts the advancement without counting as a failed check
+func (c *Controller) runKayentaCheck(canary *flaggerv1.Canary) (ok bool, marginal bool) {
+	spec := canary.GetAnalysis().Kayenta
+	if spec == nil {
+		return true, false
+	}
+
+	request, err := newKayentaRequest(canary, time.Now().UTC())
+	if err != nil {
+		c.recordEventErrorf(canary, "Kayenta analysis of %s.%s is invalid: %v", canary.Name, canary.Namespace, err)
+		return false, false
+	}
+
+	timeout, err := parseKayentaDuration(spec.Timeout, kayentaTimeout)
+	if err != nil {
+		c.recordEventErrorf(canary, "Kayenta timeout of %s.%s is invalid: %v", canary.Name, canary.Namespace, err)
+		return false, false
+	}
+
+	client, err := kayenta.NewClient(spec.Address)
+	if err != nil {
+		c.recordEventErrorf(canary, "Kayenta client error: %v", err)
+		return false, false
+	}
+
+	params := url.Values{}
+	params.Set("application", kayentaApplication)
+	if spec.Application != "" {
+		params.Set("application", spec.Application)
+	}
+	if spec.MetricsAccountName != "" {
+		params.Set("metricsAccountName", spec.MetricsAccountName)
+	}
+	if spec.StorageAccountName != "" {
+		params.Set("storageAccountName", spec.StorageAccountName)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	score, err := client.Judge(ctx, spec.CanaryConfigID, params, request)
+	if err != nil {
+		c.recordEventErrorf(canary, "Kayenta judgement failed for %s.%s: %v", canary.Name, canary.Namespace, err)
+		return false, false
+	}
+	c.recorder.SetAnalysis(canary, kayentaMetricName, score.Score)
+
+	switch score.Classification {
+	case kayenta.PassClassification:
+		return true, false
+	case kayenta.MarginalClassification:
+		c.recordEventWarningf(canary, "Halt %s.%s advancement Kayenta score %.2f is marginal < %v",
+			canary.Name, canary.Namespace, score.Score, request.Thresholds.Pass)
+		return false, true
+	default:
+		c.recordEventWarningf(canary, "Halt %s.%s advancement Kayenta score %.2f classified as %s",
+			canary.Name, canary.Namespace, score.Score, score.Classification)
+		return false, false
+	}
+}
+
+// newKayentaRequest returns a Kayenta execution request comparing the primary (control)
+// and canary (experiment) metrics over the time window ending now
+func newKayentaRequest(canary *flaggerv1.Canary, now time.Time) (kayenta.ExecutionRequest, error) {
+	spec := canary.GetAnalysis().Kayenta
+
+	interval, err := parseKayentaDuration(spec.Interval, canary.GetAnalysisInterval())
+	if err != nil {
+		return kayenta.ExecutionRequest{}, fmt.Errorf("interval: %w", err)
+	}
+
+	step, err := parseKayentaDuration(spec.Step, kayentaStep)
+	if err != nil {
+		return kayenta.ExecutionRequest{}, fmt.Errorf("step: %w", err)
+	}
+
+	controlScope, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name)
+	if err != nil {
+		return kayenta.ExecutionRequest{}, err
+	}
+	if spec.ControlScope != "" {
+		controlScope = spec.ControlScope
+	}
+
+	experimentScope := canary.Spec.TargetRef.Name
+	if spec.ExperimentScope != "" {
+		experimentScope = spec.ExperimentScope
+	}
+
+	location := canary.Namespace
+	if spec.Location != "" {
+		location = spec.Location
+	}
+
+	thresholds := kayenta.Thresholds{
+		Pass:     kayentaPassScore,
+		Marginal: kayentaMarginalScore,
+	}
+	if spec.PassScore > 0 {
+		thresholds.Pass = spec.PassScore
+	}
+	if spec.MarginalScore > 0 {
+		thresholds.Marginal = spec.MarginalScore
+	}
+	if thresholds.Marginal > thresholds.Pass {
+		return kayenta.ExecutionRequest{}, fmt.Errorf("marginal score %v is greater than the pass score %v",
+			thresholds.Marginal, thresholds.Pass)
+	}
+
+	scope := func(name string) kayenta.Scope {
+		return kayenta.Scope{
+			Scope:    name,
+			Location: location,
+			Start:    now.Add(-interval),
+			End:      now,
+			Step:     int64(step.Seconds()),
+		}
+	}
+
+	return kayenta.ExecutionRequest{
+		Scopes: map[string]kayenta.ScopePair{
+			"default": {
+				ControlScope:    scope(controlScope),
+				ExperimentScope: scope(experimentScope),
+			},
+		},
+		Thresholds: thresholds,
+	}, nil
+}
+
+func parseKayentaDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration %s must be greater than zero", value)
+	}
+	return d, nil
+}
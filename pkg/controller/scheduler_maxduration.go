@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// runMaxDurationCheck ends the analysis that has been running for longer than the max duration,
+// the canary is rolled back or promoted according to the max duration policy.
+// It returns false if the analysis was ended
+func (c *Controller) runMaxDurationCheck(canary *flaggerv1.Canary, canaryController canary.Controller,
+	meshRouter router.Interface) bool {
+	maxDuration := canary.GetAnalysisMaxDuration()
+	if maxDuration == 0 || canary.Status.RunStartTime.IsZero() {
+		return true
+	}
+	if time.Since(canary.Status.RunStartTime.Time) < maxDuration {
+		return true
+	}
+
+	// the dry-run analysis never promotes the canary
+	if canary.GetAnalysis().MaxDurationPolicy == flaggerv1.PromoteMaxDurationPolicy && !canary.GetAnalysis().DryRun {
+		// the promotion is deferred until the freeze window ends
+		if window := c.activeFreezeWindow(canary); window != "" {
+			return true
+		}
+
+		c.recordEventWarningf(canary, "Promoting %s.%s max duration %v exceeded", canary.Name, canary.Namespace, maxDuration)
+		c.alert(canary, fmt.Sprintf("Promoting max duration %v exceeded", maxDuration), false, flaggerv1.SeverityWarn)
+
+		primaryName, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name)
+		if err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+			return false
+		}
+		c.recordEventInfof(canary, "Copying %s.%s template spec to %s.%s",
+			canary.Spec.TargetRef.Name, canary.Namespace, primaryName, canary.Namespace)
+		if err := canaryController.Promote(canary); err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+			return false
+		}
+		if err := canaryController.SetStatusPhase(canary, flaggerv1.CanaryPhasePromoting); err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+		}
+		return false
+	}
+
+	c.recordEventWarningf(canary, "Rolling back %s.%s max duration %v exceeded", canary.Name, canary.Namespace, maxDuration)
+	c.alert(canary, fmt.Sprintf("Rolling back max duration %v exceeded", maxDuration), false, flaggerv1.SeverityWarn)
+	c.rollback(canary, canaryController, meshRouter, flaggerv1.MaxDurationRollbackReason)
+	return false
+}
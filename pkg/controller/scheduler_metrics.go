@@ -20,24 +20,65 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
 	"github.com/fluxcd/flagger/pkg/metrics/observers"
 	"github.com/fluxcd/flagger/pkg/metrics/providers"
 )
 
 const (
 	MetricsProviderServiceSuffix = ":service"
+
+	// metricQueryBackoff is the delay before the first retry of a failed metric query
+	metricQueryBackoff = time.Second
 )
 
+// metricRetries counts the query retries of each metric during an analysis run
+type metricRetries map[string]int
+
+// metricWarnings holds the warning message of each metric whose value
+// is outside the warning range during an analysis run
+type metricWarnings map[string]string
+
+// analysisRun holds the results of the metric checks of an analysis run,
+// they are added to the canary status when the run ends
+type analysisRun struct {
+	retries      metricRetries
+	warnings     metricWarnings
+	headroom     metricHeadroom
+	failedChecks metricFailedChecks
+	failures     []analysisFailure
+	values       map[string]float64
+	thresholds   map[string]string
+	passed       map[string]bool
+	// aborted is set when the checks stop on an error that isn't attributed to a metric
+	aborted bool
+}
+
+func newAnalysisRun() *analysisRun {
+	return &analysisRun{
+		retries:      metricRetries{},
+		warnings:     metricWarnings{},
+		headroom:     metricHeadroom{},
+		failedChecks: metricFailedChecks{},
+		values:       map[string]float64{},
+		thresholds:   map[string]string{},
+		passed:       map[string]bool{},
+	}
+}
+
 // to be called during canary initialization
 func (c *Controller) checkMetricProviderAvailability(canary *flaggerv1.Canary) error {
 	for _, metric := range canary.GetAnalysis().Metrics {
-		if metric.Name == "request-success-rate" || metric.Name == "request-duration" {
+		isRegistered := metric.Query == "" && metric.TemplateRef == nil && c.builtinMetrics.Has(metric.Name)
+		if observers.IsBuiltinMetric(metric.Name) || isRegistered {
 			observerFactory := c.observerFactory
 			if canary.Spec.MetricsServer != "" {
 				var err error
@@ -45,10 +86,23 @@ func (c *Controller) checkMetricProviderAvailability(canary *flaggerv1.Canary) e
 				if err != nil {
 					return fmt.Errorf("error building Prometheus client for %s %v", canary.Spec.MetricsServer, err)
 				}
+				observerFactory.Client = c.wrapMetricProvider(canary.Namespace, metricsServerProvider(canary.Spec.MetricsServer), observerFactory.Client)
 			}
 			if ok, err := observerFactory.Client.IsOnline(); !ok || err != nil {
 				return fmt.Errorf("prometheus not avaiable: %v", err)
 			}
+			if strings.HasPrefix(metric.Name, "grpc-") {
+				metricsProvider := c.getBuiltinMetricsProvider(canary)
+				if _, ok := newBuiltinObserver(*observerFactory, canary, metricsProvider).(observers.GrpcInterface); !ok {
+					return fmt.Errorf("metric %s is not supported by the %s provider", metric.Name, metricsProvider)
+				}
+			}
+			if isRegistered {
+				metricsProvider := c.getBuiltinMetricsProvider(canary)
+				if _, ok := c.builtinMetrics.Query(metric.Name, metricsProvider); !ok {
+					return fmt.Errorf("metric %s has no query for the %s provider", metric.Name, metricsProvider)
+				}
+			}
 			continue
 		}
 
@@ -79,18 +133,34 @@ func (c *Controller) checkMetricProviderAvailability(canary *flaggerv1.Canary) e
 				return fmt.Errorf("metric template %s.%s provider %s error: %v",
 					metric.TemplateRef.Name, namespace, template.Spec.Provider.Type, err)
 			}
+			provider = c.wrapMetricProvider(namespace, template.Spec.Provider, provider)
 
 			if ok, err := provider.IsOnline(); !ok || err != nil {
 				return fmt.Errorf("%v in metric template %s.%s not avaiable: %v", template.Spec.Provider.Type,
 					template.Name, template.Namespace, err)
 			}
+
+			for _, q := range template.Spec.Queries {
+				if q.Provider == nil {
+					continue
+				}
+				provider, err := c.newMetricTemplateQueryProvider(template, q, metric.Interval)
+				if err != nil {
+					return fmt.Errorf("metric template %s.%s %v", template.Name, template.Namespace, err)
+				}
+				if ok, err := provider.IsOnline(); !ok || err != nil {
+					return fmt.Errorf("%v of query %s in metric template %s.%s not avaiable: %v", q.Provider.Type,
+						q.Name, template.Name, template.Namespace, err)
+				}
+			}
 		}
 	}
 	c.recordEventInfof(canary, "all the metrics providers are available!")
 	return nil
 }
 
-func (c *Controller) runBuiltinMetricChecks(canary *flaggerv1.Canary) bool {
+// getBuiltinMetricsProvider returns the provider that selects the queries of the builtin metrics
+func (c *Controller) getBuiltinMetricsProvider(canary *flaggerv1.Canary) string {
 	// override the global provider if one is specified in the canary spec
 	var metricsProvider string
 	// set the metrics provider to Crossover Prometheus when Crossover is the mesh provider
@@ -113,6 +183,44 @@ func (c *Controller) runBuiltinMetricChecks(canary *flaggerv1.Canary) bool {
 	if canary.Spec.TargetRef.Kind == "Service" {
 		metricsProvider = metricsProvider + MetricsProviderServiceSuffix
 	}
+	return metricsProvider
+}
+
+func newBuiltinObserver(factory observers.Factory, canary *flaggerv1.Canary, metricsProvider string) observers.Interface {
+	if metricsProvider == flaggerv1.IstioProvider && canary.Spec.Service.Telemetry != nil {
+		return factory.IstioTelemetryObserver(canary.Spec.Service.Telemetry.GetTag())
+	}
+	return factory.Observer(metricsProvider)
+}
+
+// getBuiltinSuccessRate returns the success rate of the HTTP or gRPC requests
+func getBuiltinSuccessRate(observer observers.Interface, metricName string, model flaggerv1.MetricTemplateModel) (float64, error) {
+	if metricName != "grpc-success-rate" {
+		return observer.GetRequestSuccessRate(model)
+	}
+	grpcObserver, ok := observer.(observers.GrpcInterface)
+	if !ok {
+		return 0, fmt.Errorf("metric %s is not supported by the observer", metricName)
+	}
+	return grpcObserver.GetGrpcSuccessRate(model)
+}
+
+// getBuiltinDuration returns the latency of the HTTP or gRPC requests
+func getBuiltinDuration(observer observers.Interface, metricName string, model flaggerv1.MetricTemplateModel) (time.Duration, error) {
+	if metricName != "grpc-duration" {
+		return observer.GetRequestDuration(model)
+	}
+	grpcObserver, ok := observer.(observers.GrpcInterface)
+	if !ok {
+		return 0, fmt.Errorf("metric %s is not supported by the observer", metricName)
+	}
+	return grpcObserver.GetGrpcDuration(model)
+}
+
+// runBuiltinMetricChecks runs the checks of all the metrics without a template,
+// the metrics are checked even if a previous metric failed
+func (c *Controller) runBuiltinMetricChecks(canary *flaggerv1.Canary, run *analysisRun) bool {
+	metricsProvider := c.getBuiltinMetricsProvider(canary)
 
 	// create observer based on the mesh provider
 	observerFactory := c.observerFactory
@@ -123,194 +231,582 @@ func (c *Controller) runBuiltinMetricChecks(canary *flaggerv1.Canary) bool {
 		observerFactory, err = observers.NewFactory(canary.Spec.MetricsServer)
 		if err != nil {
 			c.recordEventErrorf(canary, "Error building Prometheus client for %s %v", canary.Spec.MetricsServer, err)
+			run.aborted = true
 			return false
 		}
+		observerFactory.Client = c.wrapMetricProvider(canary.Namespace, metricsServerProvider(canary.Spec.MetricsServer), observerFactory.Client)
 	}
-	observer := observerFactory.Observer(metricsProvider)
 
 	// run metrics checks
+	ok := true
 	for _, metric := range canary.GetAnalysis().Metrics {
-		if metric.Interval == "" {
-			metric.Interval = canary.GetMetricInterval()
+		if !c.runBuiltinMetricCheck(canary, metric, metricsProvider, observerFactory, run) {
+			ok = false
+		} else if metric.TemplateRef == nil {
+			run.passMetric(metric.Name)
 		}
+	}
 
-		if metric.Name == "request-success-rate" {
-			val, err := observer.GetRequestSuccessRate(toMetricModel(canary, metric.Interval))
-			if err != nil {
-				if errors.Is(err, providers.ErrNoValuesFound) {
-					c.recordEventWarningf(canary,
-						"Halt advancement no values found for %s metric %s probably %s.%s is not receiving traffic: %v",
-						metricsProvider, metric.Name, canary.Spec.TargetRef.Name, canary.Namespace, err)
-				} else {
-					c.recordEventErrorf(canary, "Prometheus query failed: %v", err)
-				}
-				return false
+	return ok
+}
+
+// runBuiltinMetricCheck runs the check of a builtin or in-line PromQL metric and marks the metric as failed
+// if its value is outside the threshold range or its query fails
+func (c *Controller) runBuiltinMetricCheck(canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric,
+	metricsProvider string, observerFactory *observers.Factory, run *analysisRun) (ok bool) {
+	defer func() {
+		if !ok {
+			run.failMetric(metric)
+		}
+	}()
+
+	if metric.Interval == "" {
+		metric.Interval = canary.GetMetricInterval()
+	}
+	metric.Interval = alignMetricInterval(metric.Interval, c.scrapeInterval)
+	// the metrics registered by the operators are run as in-line PromQL
+	if metric.Query == "" && metric.TemplateRef == nil && c.builtinMetrics.Has(metric.Name) {
+		query, ok := c.builtinMetrics.Query(metric.Name, metricsProvider)
+		if !ok {
+			c.recordEventErrorf(canary, "Metric %s has no query for the %s provider", metric.Name, metricsProvider)
+			return false
+		}
+		metric.Query = query
+	}
+	client := c.withQueryRetries(canary, metric.Name, observerFactory.Client, run.retries)
+	observer := newBuiltinObserver(observers.Factory{Client: client}, canary, metricsProvider)
+
+	// the relative threshold is resolved against the primary value over the same interval
+	if metric.RelativeThreshold != nil {
+		primaryName, err := canary.GetPrimaryName(canary.Spec.TargetRef.Name)
+		if err != nil {
+			c.recordEventWarningf(canary, "%v", err)
+			return false
+		}
+		primaryVal, err := getBuiltinPrimaryValue(observer, client, canary, metric)
+		if err != nil {
+			if errors.Is(err, providers.ErrNoValuesFound) {
+				c.recordEventWarningf(canary,
+					"Halt advancement no values found for %s metric %s probably %s.%s is not receiving traffic",
+					metricsProvider, metric.Name, primaryName, canary.Namespace)
+			} else {
+				c.recordEventErrorf(canary, "Prometheus query failed for the primary %s: %v", metric.Name, err)
 			}
-			c.recorder.SetAnalysis(canary, metric.Name, val)
-			if metric.ThresholdRange != nil {
-				tr := *metric.ThresholdRange
-				if tr.Min != nil && val < *tr.Min {
-					c.recordEventWarningf(canary, "Halt %s.%s advancement success rate %.2f%% < %v%%",
-						canary.Name, canary.Namespace, val, *tr.Min)
-					return false
-				}
-				if tr.Max != nil && val > *tr.Max {
-					c.recordEventWarningf(canary, "Halt %s.%s advancement success rate %.2f%% > %v%%",
-						canary.Name, canary.Namespace, val, *tr.Max)
-					return false
-				}
-			} else if metric.Threshold > val {
+			return false
+		}
+		metric.ThresholdRange = relativeThresholdRange(metric, primaryVal)
+		c.canaryLogger(canary).Debugf("Metric %s primary value %v", metric.Name, primaryVal)
+	}
+
+	if metric.Name == "request-success-rate" || metric.Name == "grpc-success-rate" {
+		val, err := getBuiltinSuccessRate(observer, metric.Name, toMetricModel(canary, metric))
+		if err != nil {
+			if errors.Is(err, providers.ErrNoValuesFound) {
+				c.recordEventWarningf(canary,
+					"Halt advancement no values found for %s metric %s probably %s.%s is not receiving traffic: %v",
+					metricsProvider, metric.Name, canary.Spec.TargetRef.Name, canary.Namespace, err)
+			} else {
+				c.recordEventErrorf(canary, "Prometheus query failed: %v", err)
+			}
+			return false
+		}
+		c.recorder.SetAnalysis(canary, metric.Name, val)
+		run.observe(metric, val)
+		if metric.ThresholdRange != nil {
+			tr := *metric.ThresholdRange
+			if tr.Min != nil && val < *tr.Min {
 				c.recordEventWarningf(canary, "Halt %s.%s advancement success rate %.2f%% < %v%%",
-					canary.Name, canary.Namespace, val, metric.Threshold)
+					canary.Name, canary.Namespace, val, *tr.Min)
 				return false
 			}
+			if tr.Max != nil && val > *tr.Max {
+				c.recordEventWarningf(canary, "Halt %s.%s advancement success rate %.2f%% > %v%%",
+					canary.Name, canary.Namespace, val, *tr.Max)
+				return false
+			}
+		} else if metric.Threshold > val {
+			c.recordEventWarningf(canary, "Halt %s.%s advancement success rate %.2f%% < %v%%",
+				canary.Name, canary.Namespace, val, metric.Threshold)
+			return false
 		}
+		checkMetricWarning(metric, val, run.warnings)
+		run.headroom.record(metric.Name, metricThresholdRange(metric), val)
+	}
 
-		if metric.Name == "request-duration" {
-			val, err := observer.GetRequestDuration(toMetricModel(canary, metric.Interval))
-			if err != nil {
-				if errors.Is(err, providers.ErrNoValuesFound) {
-					c.recordEventWarningf(canary, "Halt advancement no values found for %s metric %s probably %s.%s is not receiving traffic",
-						metricsProvider, metric.Name, canary.Spec.TargetRef.Name, canary.Namespace)
-				} else {
-					c.recordEventErrorf(canary, "Prometheus query failed: %v", err)
-				}
+	if metric.Name == "request-duration" || metric.Name == "grpc-duration" {
+		val, err := getBuiltinDuration(observer, metric.Name, toMetricModel(canary, metric))
+		if err != nil {
+			if errors.Is(err, providers.ErrNoValuesFound) {
+				c.recordEventWarningf(canary, "Halt advancement no values found for %s metric %s probably %s.%s is not receiving traffic",
+					metricsProvider, metric.Name, canary.Spec.TargetRef.Name, canary.Namespace)
+			} else {
+				c.recordEventErrorf(canary, "Prometheus query failed: %v", err)
+			}
+			return false
+		}
+		c.recorder.SetAnalysis(canary, metric.Name, val.Seconds())
+		run.observe(metric, float64(val)/float64(time.Millisecond))
+		if metric.ThresholdRange != nil {
+			tr := *metric.ThresholdRange
+			if tr.Min != nil && val < time.Duration(*tr.Min)*time.Millisecond {
+				c.recordEventWarningf(canary, "Halt %s.%s advancement request duration %v < %v",
+					canary.Name, canary.Namespace, val, time.Duration(*tr.Min)*time.Millisecond)
 				return false
 			}
-			c.recorder.SetAnalysis(canary, metric.Name, val.Seconds())
-			if metric.ThresholdRange != nil {
-				tr := *metric.ThresholdRange
-				if tr.Min != nil && val < time.Duration(*tr.Min)*time.Millisecond {
-					c.recordEventWarningf(canary, "Halt %s.%s advancement request duration %v < %v",
-						canary.Name, canary.Namespace, val, time.Duration(*tr.Min)*time.Millisecond)
-					return false
-				}
-				if tr.Max != nil && val > time.Duration(*tr.Max)*time.Millisecond {
-					c.recordEventWarningf(canary, "Halt %s.%s advancement request duration %v > %v",
-						canary.Name, canary.Namespace, val, time.Duration(*tr.Max)*time.Millisecond)
-					return false
-				}
-			} else if val > time.Duration(metric.Threshold)*time.Millisecond {
+			if tr.Max != nil && val > time.Duration(*tr.Max)*time.Millisecond {
 				c.recordEventWarningf(canary, "Halt %s.%s advancement request duration %v > %v",
-					canary.Name, canary.Namespace, val, time.Duration(metric.Threshold)*time.Millisecond)
+					canary.Name, canary.Namespace, val, time.Duration(*tr.Max)*time.Millisecond)
 				return false
 			}
+		} else if val > time.Duration(metric.Threshold)*time.Millisecond {
+			c.recordEventWarningf(canary, "Halt %s.%s advancement request duration %v > %v",
+				canary.Name, canary.Namespace, val, time.Duration(metric.Threshold)*time.Millisecond)
+			return false
 		}
+		// the warning range of the request duration is expressed in milliseconds
+		checkMetricWarning(metric, float64(val)/float64(time.Millisecond), run.warnings)
+		run.headroom.record(metric.Name, metricThresholdRange(metric), float64(val)/float64(time.Millisecond))
+	}
 
-		// in-line PromQL
-		if metric.Query != "" {
-			query, err := observers.RenderQuery(metric.Query, toMetricModel(canary, metric.Interval))
-			val, err := observerFactory.Client.RunQuery(query)
-			if err != nil {
-				if errors.Is(err, providers.ErrNoValuesFound) {
-					c.recordEventWarningf(canary, "Halt advancement no values found for metric: %s",
-						metric.Name)
-				} else {
-					c.recordEventErrorf(canary, "Prometheus query failed for %s: %v", metric.Name, err)
-				}
+	// in-line PromQL
+	if metric.Query != "" {
+		query, err := observers.RenderQuery(metric.Query, toMetricModel(canary, metric))
+		val, err := client.RunQuery(query)
+		if err != nil {
+			if errors.Is(err, providers.ErrNoValuesFound) {
+				c.recordEventWarningf(canary, "Halt advancement no values found for metric: %s",
+					metric.Name)
+			} else {
+				c.recordEventErrorf(canary, "Prometheus query failed for %s: %v", metric.Name, err)
+			}
+			return false
+		}
+		c.recorder.SetAnalysis(canary, metric.Name, val)
+		run.observe(metric, val)
+		if metric.ThresholdRange != nil {
+			tr := *metric.ThresholdRange
+			if tr.Min != nil && val < *tr.Min {
+				c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f < %v",
+					canary.Name, canary.Namespace, metric.Name, val, *tr.Min)
 				return false
 			}
-			c.recorder.SetAnalysis(canary, metric.Name, val)
-			if metric.ThresholdRange != nil {
-				tr := *metric.ThresholdRange
-				if tr.Min != nil && val < *tr.Min {
-					c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f < %v",
-						canary.Name, canary.Namespace, metric.Name, val, *tr.Min)
-					return false
-				}
-				if tr.Max != nil && val > *tr.Max {
-					c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f > %v",
-						canary.Name, canary.Namespace, metric.Name, val, *tr.Max)
-					return false
-				}
-			} else if val > metric.Threshold {
+			if tr.Max != nil && val > *tr.Max {
 				c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f > %v",
-					canary.Name, canary.Namespace, metric.Name, val, metric.Threshold)
+					canary.Name, canary.Namespace, metric.Name, val, *tr.Max)
 				return false
 			}
+		} else if val > metric.Threshold {
+			c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f > %v",
+				canary.Name, canary.Namespace, metric.Name, val, metric.Threshold)
+			return false
 		}
+		checkMetricWarning(metric, val, run.warnings)
+		run.headroom.record(metric.Name, metricThresholdRange(metric), val)
 	}
 
 	return true
 }
 
-func (c *Controller) runMetricChecks(canary *flaggerv1.Canary) bool {
+// runMetricChecks runs the checks of all the metrics with a template,
+// the metrics are checked even if a previous metric failed
+func (c *Controller) runMetricChecks(canary *flaggerv1.Canary, run *analysisRun) bool {
+	ok := true
 	for _, metric := range canary.GetAnalysis().Metrics {
-		if metric.TemplateRef != nil {
-			namespace := canary.Namespace
-			if metric.TemplateRef.Namespace != canary.Namespace {
-				namespace = metric.TemplateRef.Namespace
-			}
+		if metric.TemplateRef == nil {
+			continue
+		}
+		if !c.runMetricTemplateCheck(canary, metric, run) {
+			ok = false
+		} else {
+			run.passMetric(metric.Name)
+		}
+	}
 
-			template, err := c.flaggerInformers.MetricInformer.Lister().MetricTemplates(namespace).Get(metric.TemplateRef.Name)
-			if err != nil {
-				c.recordEventErrorf(canary, "Metric template %s.%s error: %v", metric.TemplateRef.Name, namespace, err)
-				return false
-			}
+	return ok
+}
 
-			var credentials map[string][]byte
-			if template.Spec.Provider.SecretRef != nil {
-				secret, err := c.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), template.Spec.Provider.SecretRef.Name, metav1.GetOptions{})
-				if err != nil {
-					c.recordEventErrorf(canary, "Metric template %s.%s secret %s error: %v",
-						metric.TemplateRef.Name, namespace, template.Spec.Provider.SecretRef.Name, err)
-					return false
-				}
-				credentials = secret.Data
-			}
+// runMetricTemplateCheck runs the check of a metric with a template and marks the metric as failed
+// if its value is outside the threshold range or its query fails
+func (c *Controller) runMetricTemplateCheck(canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric,
+	run *analysisRun) (ok bool) {
+	defer func() {
+		if !ok {
+			run.failMetric(metric)
+		}
+	}()
 
-			factory := providers.Factory{}
-			provider, err := factory.Provider(metric.Interval, template.Spec.Provider, credentials)
-			if err != nil {
-				c.recordEventErrorf(canary, "Metric template %s.%s provider %s error: %v",
-					metric.TemplateRef.Name, namespace, template.Spec.Provider.Type, err)
-				return false
-			}
+	namespace := canary.Namespace
+	if metric.TemplateRef.Namespace != canary.Namespace {
+		namespace = metric.TemplateRef.Namespace
+	}
 
-			query, err := observers.RenderQuery(template.Spec.Query, toMetricModel(canary, metric.Interval))
-			if err != nil {
-				c.recordEventErrorf(canary, "Metric template %s.%s query render error: %v",
-					metric.TemplateRef.Name, namespace, err)
-				return false
-			}
+	template, err := c.flaggerInformers.MetricInformer.Lister().MetricTemplates(namespace).Get(metric.TemplateRef.Name)
+	if err != nil {
+		c.recordEventErrorf(canary, "Metric template %s.%s error: %v", metric.TemplateRef.Name, namespace, err)
+		return false
+	}
+	metric.Interval = alignMetricInterval(metric.Interval, c.getScrapeInterval(template.Spec.Provider))
 
-			val, err := provider.RunQuery(query)
-			if err != nil {
-				if errors.Is(err, providers.ErrNoValuesFound) {
-					c.recordEventWarningf(canary, "Halt advancement no values found for custom metric: %s: %v",
-						metric.Name, err)
-				} else {
-					c.recordEventErrorf(canary, "Metric query failed for %s: %v", metric.Name, err)
-				}
-				return false
-			}
+	// compare the canary samples with the primary samples
+	if metric.Comparison != nil {
+		return c.runMetricComparison(canary, metric, template, run)
+	}
 
-			c.recorder.SetAnalysis(canary, metric.Name, val)
+	// gate the advancement on the error budget burn rate
+	if metric.BurnRate != nil {
+		return c.runMetricBurnRate(canary, metric, template, run)
+	}
 
-			if metric.ThresholdRange != nil {
-				tr := *metric.ThresholdRange
-				if tr.Min != nil && val < *tr.Min {
-					c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f < %v",
-						canary.Name, canary.Namespace, metric.Name, val, *tr.Min)
-					return false
-				}
-				if tr.Max != nil && val > *tr.Max {
-					c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f > %v",
-						canary.Name, canary.Namespace, metric.Name, val, *tr.Max)
-					return false
-				}
-			} else if val > metric.Threshold {
-				c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f > %v",
-					canary.Name, canary.Namespace, metric.Name, val, metric.Threshold)
-				return false
+	// evaluate the named queries and combine their results
+	if len(template.Spec.Queries) > 0 {
+		val, err := c.runMetricTemplateQueries(canary, metric, template, run.retries)
+		if err != nil {
+			if errors.Is(err, providers.ErrNoValuesFound) {
+				c.recordEventWarningf(canary, "Halt advancement no values found for custom metric: %s: %v",
+					metric.Name, err)
+			} else {
+				c.recordEventErrorf(canary, "Metric template %s.%s queries failed for %s: %v",
+					metric.TemplateRef.Name, namespace, metric.Name, err)
 			}
+			return false
+		}
+
+		return c.checkMetricThreshold(canary, metric, val, run)
+	}
+
+	var credentials map[string][]byte
+	if template.Spec.Provider.SecretRef != nil {
+		secret, err := c.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), template.Spec.Provider.SecretRef.Name, metav1.GetOptions{})
+		if err != nil {
+			c.recordEventErrorf(canary, "Metric template %s.%s secret %s error: %v",
+				metric.TemplateRef.Name, namespace, template.Spec.Provider.SecretRef.Name, err)
+			return false
+		}
+		credentials = secret.Data
+	}
+
+	factory := providers.Factory{}
+	provider, err := factory.Provider(metric.Interval, template.Spec.Provider, credentials)
+	if err != nil {
+		c.recordEventErrorf(canary, "Metric template %s.%s provider %s error: %v",
+			metric.TemplateRef.Name, namespace, template.Spec.Provider.Type, err)
+		return false
+	}
+	provider = c.wrapMetricProvider(namespace, template.Spec.Provider, provider)
+	provider = c.withQueryRetries(canary, metric.Name, provider, run.retries)
+
+	query, err := observers.RenderQuery(template.Spec.Query, toMetricModel(canary, metric))
+	if err != nil {
+		c.recordEventErrorf(canary, "Metric template %s.%s query render error: %v",
+			metric.TemplateRef.Name, namespace, err)
+		return false
+	}
+
+	c.canaryLogger(canary).Debugf("Running metric %s query %s", metric.Name, query)
+	val, err := provider.RunQuery(query)
+	if err != nil {
+		if errors.Is(err, providers.ErrNoValuesFound) {
+			c.recordEventWarningf(canary, "Halt advancement no values found for custom metric: %s: %v",
+				metric.Name, err)
+		} else {
+			c.recordEventErrorf(canary, "Metric query failed for %s: %v", metric.Name, err)
 		}
+		return false
+	}
+
+	if ok := c.checkMetricThreshold(canary, metric, val, run); !ok {
+		return false
 	}
 
 	return true
 }
 
-func toMetricModel(r *flaggerv1.Canary, interval string) flaggerv1.MetricTemplateModel {
+// checkMetricThreshold records the metric value and returns false if the value is outside the threshold range
+func (c *Controller) checkMetricThreshold(canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric, val float64,
+	run *analysisRun) bool {
+	c.recorder.SetAnalysis(canary, metric.Name, val)
+	run.observe(metric, val)
+	c.canaryLogger(canary).Debugf("Metric %s value %v", metric.Name, val)
+
+	if metric.ThresholdRange != nil {
+		tr := *metric.ThresholdRange
+		if tr.Min != nil && val < *tr.Min {
+			c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f < %v",
+				canary.Name, canary.Namespace, metric.Name, val, *tr.Min)
+			return false
+		}
+		if tr.Max != nil && val > *tr.Max {
+			c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f > %v",
+				canary.Name, canary.Namespace, metric.Name, val, *tr.Max)
+			return false
+		}
+	} else if val > metric.Threshold {
+		c.recordEventWarningf(canary, "Halt %s.%s advancement %s %.2f > %v",
+			canary.Name, canary.Namespace, metric.Name, val, metric.Threshold)
+		return false
+	}
+	checkMetricWarning(metric, val, run.warnings)
+	run.headroom.record(metric.Name, metricThresholdRange(metric), val)
+	return true
+}
+
+// checkMetricWarning records a warning if the value is outside the warning range of the metric
+func checkMetricWarning(metric flaggerv1.CanaryMetric, val float64, warnings metricWarnings) {
+	if metric.WarningRange == nil {
+		return
+	}
+	wr := *metric.WarningRange
+	if wr.Min != nil && val < *wr.Min {
+		warnings[metric.Name] = fmt.Sprintf("%s %.2f < %v", metric.Name, val, *wr.Min)
+	}
+	if wr.Max != nil && val > *wr.Max {
+		warnings[metric.Name] = fmt.Sprintf("%s %.2f > %v", metric.Name, val, *wr.Max)
+	}
+}
+
+// holdOnMetricWarnings emits a warning event for the metrics outside the warning range,
+// the alert is sent only when a metric enters the warning range
+func (c *Controller) holdOnMetricWarnings(canary *flaggerv1.Canary, warnings metricWarnings) {
+	names := make([]string, 0, len(warnings))
+	for name := range warnings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, 0, len(names))
+	for _, name := range names {
+		messages = append(messages, warnings[name])
+	}
+	message := fmt.Sprintf("Hold %s.%s advancement metrics in warning range: %s",
+		canary.Name, canary.Namespace, strings.Join(messages, ", "))
+	c.recordEventWarningf(canary, "%s", message)
+
+	previous := make(map[string]bool, len(canary.Status.Metrics))
+	for _, m := range canary.Status.Metrics {
+		previous[m.Name] = m.Warning != ""
+	}
+	for _, name := range names {
+		if !previous[name] {
+			c.alert(canary, message, false, flaggerv1.SeverityWarn)
+			return
+		}
+	}
+}
+
+// runMetricTemplateQueries runs the named queries of the metric template
+// and returns the result of the expression computed over the query results
+func (c *Controller) runMetricTemplateQueries(canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric,
+	template *flaggerv1.MetricTemplate, retries metricRetries) (float64, error) {
+	values := make(map[string]float64, len(template.Spec.Queries))
+	for _, q := range template.Spec.Queries {
+		provider, err := c.newMetricTemplateQueryProvider(template, q, metric.Interval)
+		if err != nil {
+			return 0, err
+		}
+		provider = c.withQueryRetries(canary, metric.Name, provider, retries)
+
+		query, err := observers.RenderQuery(q.Query, toMetricModel(canary, metric))
+		if err != nil {
+			return 0, fmt.Errorf("query %s render error: %w", q.Name, err)
+		}
+
+		val, err := provider.RunQuery(query)
+		if err != nil {
+			return 0, fmt.Errorf("query %s failed: %w", q.Name, err)
+		}
+		values[q.Name] = val
+	}
+
+	if template.Spec.Expression == "" {
+		if len(template.Spec.Queries) > 1 {
+			return 0, fmt.Errorf("expression is required to combine multiple queries")
+		}
+		return values[template.Spec.Queries[0].Name], nil
+	}
+
+	return observers.EvaluateExpression(template.Spec.Expression, values)
+}
+
+// newMetricTemplateQueryProvider returns the query provider, defaults to the metric template provider
+func (c *Controller) newMetricTemplateQueryProvider(template *flaggerv1.MetricTemplate,
+	query flaggerv1.MetricTemplateQuery, interval string) (providers.Interface, error) {
+	spec := template.Spec.Provider
+	if query.Provider != nil {
+		spec = *query.Provider
+	}
+
+	provider, err := c.newMetricProvider(template.Namespace, spec, interval)
+	if err != nil {
+		return nil, fmt.Errorf("query %s %w", query.Name, err)
+	}
+	return provider, nil
+}
+
+// newMetricProvider reads the provider credentials from the secret and returns the metric provider
+func (c *Controller) newMetricProvider(namespace string, spec flaggerv1.MetricTemplateProvider,
+	interval string) (providers.Interface, error) {
+	var credentials map[string][]byte
+	if spec.SecretRef != nil {
+		secret, err := c.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), spec.SecretRef.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("secret %s error: %w", spec.SecretRef.Name, err)
+		}
+		credentials = secret.Data
+	}
+
+	factory := providers.Factory{}
+	provider, err := factory.Provider(interval, spec, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s error: %w", spec.Type, err)
+	}
+	return c.wrapMetricProvider(namespace, spec, provider), nil
+}
+
+// wrapMetricProvider adds the failure injection, caching and rate limiting to the provider,
+// the cached results are shared by the canaries using the same provider configuration
+func (c *Controller) wrapMetricProvider(namespace string, spec flaggerv1.MetricTemplateProvider,
+	provider providers.Interface) providers.Interface {
+	provider = c.faultInjector.Provider(provider)
+	return c.queryCache.Provider(namespace, spec, provider)
+}
+
+// withQueryRetries wraps the provider to retry the transient failures of the metric queries
+// within the analysis interval, the retries are counted per metric
+func (c *Controller) withQueryRetries(canary *flaggerv1.Canary, metricName string,
+	provider providers.Interface, retries metricRetries) providers.Interface {
+	policy := providers.RetryPolicy{
+		Retries: c.queryRetries,
+		Backoff: metricQueryBackoff,
+		Timeout: canary.GetAnalysisInterval(),
+	}
+	provider = c.runSpan(canary).Provider(c.recorder.Provider(canary, metricName, provider), metricName)
+	return policy.Provider(provider, func(err error, backoff time.Duration) {
+		retries[metricName]++
+		c.canaryLogger(canary).Infof("Metric %s query failed, retrying in %v: %v", metricName, backoff, err)
+	})
+}
+
+// setMetricStatus adds the retries of the analysis run to the canary status,
+// replaces the warnings of the previous run, sets the failed checks of the metrics
+// and the last value, threshold and result of the checked metrics
+func (c *Controller) setMetricStatus(cd *flaggerv1.Canary, canaryController canary.Controller, run *analysisRun) {
+	warned := false
+	for _, m := range cd.Status.Metrics {
+		warned = warned || m.Warning != ""
+	}
+	checked := len(run.values) > 0 || len(run.failures) > 0
+	if len(run.retries) == 0 && len(run.warnings) == 0 && len(run.failedChecks) == 0 && !warned && !checked {
+		return
+	}
+
+	total := make(map[string]*flaggerv1.CanaryMetricStatus, len(cd.Status.Metrics)+len(run.retries))
+	status := func(name string) *flaggerv1.CanaryMetricStatus {
+		if _, ok := total[name]; !ok {
+			total[name] = &flaggerv1.CanaryMetricStatus{Name: name}
+		}
+		return total[name]
+	}
+	for _, m := range cd.Status.Metrics {
+		m.Warning = ""
+		*status(m.Name) = *m.DeepCopy()
+	}
+	now := metav1.Now()
+	for name, val := range run.values {
+		val := val
+		s := status(name)
+		s.Value, s.Threshold, s.Result, s.LastCheckTime = &val, run.thresholds[name], flaggerv1.CheckPassed, now
+	}
+	for _, failure := range run.failures {
+		if failure.kind == flaggerv1.MetricCheckKind {
+			s := status(failure.name)
+			s.Value, s.Result, s.LastCheckTime = failure.value, flaggerv1.CheckFailed, now
+		}
+	}
+	for name, count := range run.retries {
+		status(name).Retries += count
+	}
+	for name, message := range run.warnings {
+		status(name).Warning = message
+	}
+	for name, count := range run.failedChecks {
+		status(name).FailedChecks = count
+	}
+
+	metrics := make([]flaggerv1.CanaryMetricStatus, 0, len(total))
+	for _, m := range total {
+		metrics = append(metrics, *m)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+
+	for _, m := range metrics {
+		if m.LastCheckTime.Equal(&now) {
+			c.recordAudit(cd, flaggerv1.CanaryRunRecord{
+				Time:      now,
+				Type:      flaggerv1.MetricRecord,
+				Name:      m.Name,
+				Result:    m.Result,
+				Value:     m.Value,
+				Threshold: m.Threshold,
+				Message:   m.Warning,
+			})
+		}
+	}
+
+	if err := canaryController.SetStatusMetrics(cd, metrics); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	// the status updates that follow in this run start from the in-memory canary
+	cd.Status.Metrics = metrics
+}
+
+// getScrapeInterval returns the scrape interval of the provider, defaults to the global scrape interval
+func (c *Controller) getScrapeInterval(provider flaggerv1.MetricTemplateProvider) time.Duration {
+	if provider.ScrapeInterval != "" {
+		if d, err := time.ParseDuration(provider.ScrapeInterval); err == nil {
+			return d
+		}
+	}
+	return c.scrapeInterval
+}
+
+// alignMetricInterval rounds up the metric interval to a multiple of the scrape interval,
+// the aligned interval spans two scrapes at least so that the rate queries find two samples
+func alignMetricInterval(interval string, scrapeInterval time.Duration) string {
+	if interval == "" || scrapeInterval <= 0 {
+		return interval
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return interval
+	}
+
+	aligned := (d + scrapeInterval - 1) / scrapeInterval * scrapeInterval
+	if aligned < 2*scrapeInterval {
+		aligned = 2 * scrapeInterval
+	}
+	if aligned == d {
+		return interval
+	}
+
+	// use the units supported by the query languages e.g. 2m instead of 2m0s
+	if aligned%time.Minute == 0 {
+		return fmt.Sprintf("%dm", aligned/time.Minute)
+	}
+	return fmt.Sprintf("%ds", (aligned+time.Second-1)/time.Second)
+}
+
+func metricsServerProvider(address string) flaggerv1.MetricTemplateProvider {
+	return flaggerv1.MetricTemplateProvider{
+		Type:    "prometheus",
+		Address: address,
+	}
+}
+
+func toMetricModel(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric) flaggerv1.MetricTemplateModel {
+	interval := metric.Interval
 	service := r.Spec.TargetRef.Name
 	if r.Spec.Service.Name != "" {
 		service = r.Spec.Service.Name
@@ -319,12 +815,37 @@ func toMetricModel(r *flaggerv1.Canary, interval string) flaggerv1.MetricTemplat
 	if r.Spec.IngressRef != nil {
 		ingress = r.Spec.IngressRef.Name
 	}
-	return flaggerv1.MetricTemplateModel{
+	model := flaggerv1.MetricTemplateModel{
 		Name:      r.Name,
 		Namespace: r.Namespace,
 		Target:    r.Spec.TargetRef.Name,
 		Service:   service,
 		Ingress:   ingress,
 		Interval:  interval,
+		RunID:     r.Status.RunID,
+		Ports:     servicePortsRegex(r),
+	}
+	if metric.Percentile > 0 {
+		model.Percentile = strconv.FormatFloat(metric.Percentile, 'f', -1, 64)
+	}
+
+	// scope the queries to the current run, the run duration defaults to the metric interval
+	model.RunDuration = interval
+	if start := r.Status.RunStartTime; !start.IsZero() {
+		model.RunStart = strconv.FormatInt(start.Unix(), 10)
+		if elapsed := time.Since(start.Time).Truncate(time.Second); elapsed >= time.Second {
+			model.RunDuration = fmt.Sprintf("%ds", int64(elapsed.Seconds()))
+		}
+	}
+	return model
+}
+
+// servicePortsRegex returns the ports of the generated services joined in a regex e.g. 8080|9090
+func servicePortsRegex(r *flaggerv1.Canary) string {
+	ports := r.GetServicePorts()
+	numbers := make([]string, 0, len(ports))
+	for _, p := range ports {
+		numbers = append(numbers, strconv.Itoa(int(p.Port)))
 	}
+	return strings.Join(numbers, "|")
 }
@@ -17,14 +17,23 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 	"github.com/fluxcd/flagger/pkg/metrics/observers"
+	"github.com/fluxcd/flagger/pkg/metrics/stats"
 )
 
 func TestController_checkMetricProviderAvailability(t *testing.T) {
@@ -47,6 +56,21 @@ func TestController_checkMetricProviderAvailability(t *testing.T) {
 		require.NoError(t, ctrl.checkMetricProviderAvailability(canary))
 	})
 
+	t.Run("grpc", func(t *testing.T) {
+		analysis := &flaggerv1.CanaryAnalysis{Metrics: []flaggerv1.CanaryMetric{{Name: "grpc-success-rate"}}}
+		canary := &flaggerv1.Canary{Spec: flaggerv1.CanarySpec{Analysis: analysis}}
+		obs, err := observers.NewFactory(testMetricsServerURL)
+		require.NoError(t, err)
+
+		// ok
+		ctrl := Controller{observerFactory: obs, logger: zap.S(), eventRecorder: &record.FakeRecorder{}, meshProvider: flaggerv1.LinkerdProvider}
+		require.NoError(t, ctrl.checkMetricProviderAvailability(canary))
+
+		// error (not supported)
+		ctrl.meshProvider = flaggerv1.NGINXProvider
+		require.Error(t, ctrl.checkMetricProviderAvailability(canary))
+	})
+
 	t.Run("templateRef", func(t *testing.T) {
 		ctrl := newDeploymentFixture(nil).ctrl
 
@@ -67,3 +91,650 @@ func TestController_checkMetricProviderAvailability(t *testing.T) {
 		require.NoError(t, ctrl.checkMetricProviderAvailability(canary))
 	})
 }
+
+func TestController_runBuiltinMetricChecksRegistered(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.builtinMetrics = observers.BuiltinMetrics{
+		"error-rate": {"kubernetes": `sum(rate(http_errors_total{pod=~"{{ target }}-.*"}[{{ interval }}]))`},
+	}
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Analysis.Metrics = []flaggerv1.CanaryMetric{{
+		Name:           "error-rate",
+		ThresholdRange: &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(200)},
+	}}
+
+	// no query for the default istio provider
+	require.Error(t, mocks.ctrl.checkMetricProviderAvailability(canary))
+	assert.False(t, mocks.ctrl.runBuiltinMetricChecks(canary, newAnalysisRun()))
+
+	canary.Spec.Provider = flaggerv1.KubernetesProvider
+	require.NoError(t, mocks.ctrl.checkMetricProviderAvailability(canary))
+	assert.True(t, mocks.ctrl.runBuiltinMetricChecks(canary, newAnalysisRun()))
+
+	canary.Spec.Analysis.Metrics[0].ThresholdRange.Max = toFloatPtr(50)
+	assert.False(t, mocks.ctrl.runBuiltinMetricChecks(canary, newAnalysisRun()))
+}
+
+func TestController_runBuiltinMetricChecksFailure(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.builtinMetrics = observers.BuiltinMetrics{
+		"error-rate": {"kubernetes": `sum(rate(http_errors_total{pod=~"{{ target }}-.*"}[{{ interval }}]))`},
+	}
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Provider = flaggerv1.KubernetesProvider
+	canary.Spec.Analysis.Metrics = []flaggerv1.CanaryMetric{{
+		Name:           "error-rate",
+		ThresholdRange: &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(50)},
+	}}
+
+	run := newAnalysisRun()
+	assert.False(t, mocks.ctrl.runBuiltinMetricChecks(canary, run))
+	require.Len(t, run.failures, 1)
+	failure := run.failures[0]
+	assert.Equal(t, flaggerv1.MetricCheckKind, failure.kind)
+	assert.Equal(t, "error-rate", failure.name)
+	require.NotNil(t, failure.value)
+	assert.Equal(t, "error-rate 100.00 > 50", failure.message)
+
+	mocks.ctrl.setFailureStatus(mocks.canary, mocks.deployer, run)
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Failures, 1)
+	assert.Equal(t, "error-rate", c.Status.Failures[0].Name)
+	assert.Equal(t, *failure.value, *c.Status.Failures[0].Value)
+}
+
+func TestController_runBuiltinMetricChecksRelativeThreshold(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.builtinMetrics = observers.BuiltinMetrics{
+		"error-rate": {"kubernetes": `sum(rate(http_errors_total{pod=~"{{ target }}-.*"}[{{ interval }}]))`},
+	}
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Provider = flaggerv1.KubernetesProvider
+	canary.Spec.Analysis.Metrics = []flaggerv1.CanaryMetric{{
+		Name:              "error-rate",
+		RelativeThreshold: &flaggerv1.CanaryRelativeThreshold{MaxRatio: toFloatPtr(2)},
+	}}
+
+	// the canary and the primary values are both 100
+	assert.True(t, mocks.ctrl.runBuiltinMetricChecks(canary, newAnalysisRun()))
+
+	// the absolute threshold is kept when it is tighter
+	canary.Spec.Analysis.Metrics[0].ThresholdRange = &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(50)}
+	assert.False(t, mocks.ctrl.runBuiltinMetricChecks(canary, newAnalysisRun()))
+
+	canary.Spec.Analysis.Metrics[0].ThresholdRange = nil
+	canary.Spec.Analysis.Metrics[0].RelativeThreshold.MaxRatio = nil
+	canary.Spec.Analysis.Metrics[0].RelativeThreshold.MinRatio = toFloatPtr(1)
+	assert.True(t, mocks.ctrl.runBuiltinMetricChecks(canary, newAnalysisRun()))
+
+	// the failure is described with the bound derived from the primary value
+	canary.Spec.Analysis.Metrics[0].RelativeThreshold.MinRatio = toFloatPtr(2)
+	run := newAnalysisRun()
+	assert.False(t, mocks.ctrl.runBuiltinMetricChecks(canary, run))
+	require.Len(t, run.failures, 1)
+	assert.Equal(t, "error-rate 100.00 < 200", run.failures[0].message)
+	assert.Nil(t, canary.Spec.Analysis.Metrics[0].ThresholdRange)
+}
+
+func TestController_runAnalysisWarningRange(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.builtinMetrics = observers.BuiltinMetrics{
+		"error-rate": {"kubernetes": `sum(rate(http_errors_total{pod=~"{{ target }}-.*"}[{{ interval }}]))`},
+	}
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Provider = flaggerv1.KubernetesProvider
+	canary.Spec.Analysis.Metrics = []flaggerv1.CanaryMetric{{
+		Name:           "error-rate",
+		ThresholdRange: &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(200)},
+		WarningRange:   &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(50)},
+	}}
+
+	run := newAnalysisRun()
+	assert.True(t, mocks.ctrl.runBuiltinMetricChecks(canary, run))
+	assert.Len(t, run.warnings, 1)
+	assert.Contains(t, run.warnings["error-rate"], "> 50")
+
+	// the advancement is held without failing the analysis
+	ok, warning := mocks.ctrl.runAnalysis(canary, mocks.deployer)
+	assert.False(t, ok)
+	assert.True(t, warning)
+
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Metrics, 1)
+	assert.Contains(t, c.Status.Metrics[0].Warning, "error-rate")
+
+	// the warning is cleared when the value is back in the warning range
+	canary.Spec.Analysis.Metrics[0].WarningRange.Max = toFloatPtr(150)
+	canary.Status = c.Status
+	ok, warning = mocks.ctrl.runAnalysis(canary, mocks.deployer)
+	assert.True(t, ok)
+	assert.False(t, warning)
+
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Metrics, 1)
+	assert.Empty(t, c.Status.Metrics[0].Warning)
+	assert.Equal(t, flaggerv1.CheckPassed, c.Status.Metrics[0].Result)
+}
+
+func TestController_runAnalysisFailureThreshold(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.builtinMetrics = observers.BuiltinMetrics{
+		"error-rate": {"kubernetes": `sum(rate(http_errors_total{pod=~"{{ target }}-.*"}[{{ interval }}]))`},
+	}
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Provider = flaggerv1.KubernetesProvider
+	canary.Spec.Analysis.Metrics = []flaggerv1.CanaryMetric{{
+		Name:                "error-rate",
+		ThresholdRange:      &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(50)},
+		FailureThreshold:    2,
+		ConsecutiveFailures: true,
+	}}
+
+	runAnalysis := func(max int) (bool, bool, flaggerv1.CanaryStatus) {
+		canary.Spec.Analysis.Metrics[0].ThresholdRange.Max = toFloatPtr(max)
+		ok, held := mocks.ctrl.runAnalysis(canary, mocks.deployer)
+		c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+		require.NoError(t, err)
+		canary.Status = c.Status
+		return ok, held, c.Status
+	}
+
+	// the failure is counted against the threshold of the metric
+	ok, held, status := runAnalysis(50)
+	assert.False(t, ok)
+	assert.True(t, held)
+	require.Len(t, status.Metrics, 1)
+	assert.Equal(t, 1, status.Metrics[0].FailedChecks)
+	assert.Equal(t, flaggerv1.CheckFailed, status.Metrics[0].Result)
+	_, _, failed := getFailedMetricThreshold(canary)
+	assert.False(t, failed)
+
+	// the consecutive failures are reset when the metric passes
+	ok, _, status = runAnalysis(200)
+	assert.True(t, ok)
+	require.Len(t, status.Metrics, 1)
+	assert.Equal(t, 0, status.Metrics[0].FailedChecks)
+	assert.Equal(t, flaggerv1.CheckPassed, status.Metrics[0].Result)
+
+	runAnalysis(50)
+	runAnalysis(50)
+	name, threshold, failed := getFailedMetricThreshold(canary)
+	assert.True(t, failed)
+	assert.Equal(t, "error-rate", name)
+	assert.Equal(t, 2, threshold)
+
+	// the failures of a metric without its own threshold count as failed checks of the analysis
+	canary.Spec.Analysis.Metrics[0].FailureThreshold = 0
+	ok, held, _ = runAnalysis(50)
+	assert.False(t, ok)
+	assert.False(t, held)
+}
+
+func TestController_runAnalysisFailureThresholdMetrics(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.builtinMetrics = observers.BuiltinMetrics{
+		"error-rate": {"kubernetes": `sum(rate(http_errors_total{pod=~"{{ target }}-.*"}[{{ interval }}]))`},
+		"5xx-rate":   {"kubernetes": `sum(rate(http_5xx_total{pod=~"{{ target }}-.*"}[{{ interval }}]))`},
+	}
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Provider = flaggerv1.KubernetesProvider
+	canary.Spec.Analysis.Metrics = []flaggerv1.CanaryMetric{
+		{
+			Name:             "error-rate",
+			ThresholdRange:   &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(50)},
+			FailureThreshold: 2,
+		},
+		{
+			Name:             "5xx-rate",
+			ThresholdRange:   &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(50)},
+			FailureThreshold: 3,
+		},
+	}
+
+	runAnalysis := func() (bool, bool, flaggerv1.CanaryStatus) {
+		ok, held := mocks.ctrl.runAnalysis(canary, mocks.deployer)
+		c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+		require.NoError(t, err)
+		canary.Status = c.Status
+		return ok, held, c.Status
+	}
+
+	// both metrics are checked and each failure is counted against the threshold of its metric
+	ok, held, status := runAnalysis()
+	assert.False(t, ok)
+	assert.True(t, held)
+	require.Len(t, status.Metrics, 2)
+	assert.Equal(t, "5xx-rate", status.Metrics[0].Name)
+	assert.Equal(t, 1, status.Metrics[0].FailedChecks)
+	assert.Equal(t, "error-rate", status.Metrics[1].Name)
+	assert.Equal(t, 1, status.Metrics[1].FailedChecks)
+	assert.Len(t, status.Failures, 2)
+
+	runAnalysis()
+	name, threshold, failed := getFailedMetricThreshold(canary)
+	assert.True(t, failed)
+	assert.Equal(t, "error-rate", name)
+	assert.Equal(t, 2, threshold)
+
+	// the failure of a metric without its own threshold counts as a failed check of the analysis
+	canary.Spec.Analysis.Metrics[0].FailureThreshold = 0
+	ok, held, status = runAnalysis()
+	assert.False(t, ok)
+	assert.False(t, held)
+	assert.Equal(t, 3, status.Metrics[0].FailedChecks)
+}
+
+func TestController_runAnalysisAdaptiveStepWeight(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.builtinMetrics = observers.BuiltinMetrics{
+		"error-rate": {"kubernetes": `sum(rate(http_errors_total{pod=~"{{ target }}-.*"}[{{ interval }}]))`},
+	}
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Provider = flaggerv1.KubernetesProvider
+	canary.Spec.Analysis.StepWeight = 10
+	canary.Spec.Analysis.MaxWeight = 50
+	canary.Spec.Analysis.Adaptive = &flaggerv1.AdaptiveProgression{MaxStepWeight: 40}
+	canary.Spec.Analysis.Metrics = []flaggerv1.CanaryMetric{{
+		Name:           "error-rate",
+		ThresholdRange: &flaggerv1.CanaryThresholdRange{Max: toFloatPtr(200)},
+	}}
+
+	// the step weight is doubled when the headroom reaches the target
+	run := newAnalysisRun()
+	assert.True(t, mocks.ctrl.runBuiltinMetricChecks(canary, run))
+	assert.InDelta(t, 0.5, run.headroom["error-rate"], 0.001)
+
+	ok, _ := mocks.ctrl.runAnalysis(canary, mocks.deployer)
+	require.True(t, ok)
+	assert.Equal(t, 20, canary.Status.AdaptiveStepWeight)
+	assert.Equal(t, 20, mocks.ctrl.nextStepWeight(canary, 10))
+	assert.Equal(t, 10, mocks.ctrl.nextStepWeight(canary, 40))
+
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 20, c.Status.AdaptiveStepWeight)
+
+	// the step weight is halved when the metric approaches the threshold
+	canary.Spec.Analysis.Metrics[0].ThresholdRange.Max = toFloatPtr(110)
+	canary.Status = c.Status
+	ok, _ = mocks.ctrl.runAnalysis(canary, mocks.deployer)
+	require.True(t, ok)
+	assert.Equal(t, 10, canary.Status.AdaptiveStepWeight)
+
+	// the step weight stays within the bounds
+	canary.Status.AdaptiveStepWeight = 5
+	assert.Equal(t, 5, nextAdaptiveStepWeight(canary, 0))
+	canary.Status.AdaptiveStepWeight = 40
+	assert.Equal(t, 40, nextAdaptiveStepWeight(canary, 1))
+}
+
+func TestController_runKayentaCheck(t *testing.T) {
+	classification := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Write([]byte(`{"canaryExecutionId":"01EXEC"}`))
+			return
+		}
+		w.Write([]byte(`{"complete":true,"status":"succeeded","result":{"judgeResult":{"score":{"score":80,"classification":"` + classification + `"}}}}`))
+	}))
+	defer ts.Close()
+
+	mocks := newDeploymentFixture(nil)
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Analysis.Kayenta = &flaggerv1.KayentaAnalysis{
+		Address:        ts.URL,
+		CanaryConfigID: "podinfo-config",
+	}
+
+	classification = "Pass"
+	ok, marginal := mocks.ctrl.runKayentaCheck(canary)
+	assert.True(t, ok)
+	assert.False(t, marginal)
+
+	classification = "Marginal"
+	ok, marginal = mocks.ctrl.runKayentaCheck(canary)
+	assert.False(t, ok)
+	assert.True(t, marginal)
+
+	classification = "Fail"
+	ok, marginal = mocks.ctrl.runKayentaCheck(canary)
+	assert.False(t, ok)
+	assert.False(t, marginal)
+}
+
+func TestController_newKayentaRequest(t *testing.T) {
+	canary := newDeploymentTestCanary()
+	canary.Spec.Analysis.Kayenta = &flaggerv1.KayentaAnalysis{
+		Interval:  "10m",
+		Step:      "30s",
+		PassScore: 90,
+	}
+
+	now := time.Now().UTC()
+	request, err := newKayentaRequest(canary, now)
+	require.NoError(t, err)
+
+	scopes := request.Scopes["default"]
+	assert.Equal(t, "podinfo-primary", scopes.ControlScope.Scope)
+	assert.Equal(t, "podinfo", scopes.ExperimentScope.Scope)
+	assert.Equal(t, "default", scopes.ExperimentScope.Location)
+	assert.Equal(t, now.Add(-10*time.Minute), scopes.ExperimentScope.Start)
+	assert.Equal(t, int64(30), scopes.ExperimentScope.Step)
+	assert.Equal(t, float64(90), request.Thresholds.Pass)
+	assert.Equal(t, float64(75), request.Thresholds.Marginal)
+
+	// marginal score must not exceed the pass score
+	canary.Spec.Analysis.Kayenta.PassScore = 50
+	_, err = newKayentaRequest(canary, now)
+	require.Error(t, err)
+}
+
+func TestController_runMetricTemplateQueries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		val := "10"
+		if strings.Contains(r.URL.Query().Get("query"), "primary") {
+			val = "5"
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"` + val + `"]}]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := newDeploymentFixture(nil)
+	template := newDeploymentTestMetricTemplate()
+	template.Spec.Provider.Address = ts.URL
+	template.Spec.Query = ""
+	template.Spec.Queries = []flaggerv1.MetricTemplateQuery{
+		{Name: "canary_errors", Query: `sum(errors{pod=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"})`},
+		{Name: "primary_errors", Query: `sum(errors{pod=~"{{ target }}-primary-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"})`},
+	}
+	template.Spec.Expression = "canary_errors / primary_errors"
+	metric := flaggerv1.CanaryMetric{Name: "errors-ratio", Interval: "1m"}
+
+	val, err := mocks.ctrl.runMetricTemplateQueries(mocks.canary, metric, template, metricRetries{})
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), val)
+
+	template.Spec.Expression = "canary_errors > primary_errors * 3"
+	val, err = mocks.ctrl.runMetricTemplateQueries(mocks.canary, metric, template, metricRetries{})
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), val)
+
+	template.Spec.Expression = "canary_errors / unknown_errors"
+	_, err = mocks.ctrl.runMetricTemplateQueries(mocks.canary, metric, template, metricRetries{})
+	require.Error(t, err)
+
+	template.Spec.Expression = ""
+	_, err = mocks.ctrl.runMetricTemplateQueries(mocks.canary, metric, template, metricRetries{})
+	require.Error(t, err)
+}
+
+func TestController_runMetricTemplateQueriesRetries(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"10"]}]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.queryRetries = 2
+	template := newDeploymentTestMetricTemplate()
+	template.Spec.Provider.Address = ts.URL
+	template.Spec.Query = ""
+	template.Spec.Queries = []flaggerv1.MetricTemplateQuery{
+		{Name: "errors", Query: `sum(errors{pod=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"})`},
+	}
+	metric := flaggerv1.CanaryMetric{Name: "errors", Interval: "1m"}
+
+	run := newAnalysisRun()
+	val, err := mocks.ctrl.runMetricTemplateQueries(mocks.canary, metric, template, run.retries)
+	require.NoError(t, err)
+	assert.Equal(t, float64(10), val)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, metricRetries{"errors": 1}, run.retries)
+
+	mocks.ctrl.setMetricStatus(mocks.canary, mocks.deployer, run)
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []flaggerv1.CanaryMetricStatus{{Name: "errors", Retries: 1}}, c.Status.Metrics)
+
+	// the retries are added to the status of the previous runs
+	run = newAnalysisRun()
+	run.retries = metricRetries{"errors": 2, "latency": 1}
+	mocks.ctrl.setMetricStatus(c, mocks.deployer, run)
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []flaggerv1.CanaryMetricStatus{{Name: "errors", Retries: 3}, {Name: "latency", Retries: 1}}, c.Status.Metrics)
+}
+
+func TestController_setMetricStatusValues(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	max := float64(500)
+	latency := flaggerv1.CanaryMetric{Name: "latency", ThresholdRange: &flaggerv1.CanaryThresholdRange{Max: &max}}
+	errors := flaggerv1.CanaryMetric{Name: "errors", Threshold: 1}
+
+	run := newAnalysisRun()
+	run.observe(latency, 300)
+	mocks.ctrl.setMetricStatus(mocks.canary, mocks.deployer, run)
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Metrics, 1)
+	assert.Equal(t, float64(300), *c.Status.Metrics[0].Value)
+	assert.Equal(t, "<= 500", c.Status.Metrics[0].Threshold)
+	assert.Equal(t, flaggerv1.CheckPassed, c.Status.Metrics[0].Result)
+	assert.False(t, c.Status.Metrics[0].LastCheckTime.IsZero())
+
+	// the failed metric is reported with its last value and the passed metrics are kept
+	run = newAnalysisRun()
+	run.observe(errors, 5)
+	run.failMetric(errors)
+	mocks.ctrl.setMetricStatus(c, mocks.deployer, run)
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Metrics, 2)
+	assert.Equal(t, "errors", c.Status.Metrics[0].Name)
+	assert.Equal(t, float64(5), *c.Status.Metrics[0].Value)
+	assert.Equal(t, flaggerv1.CheckFailed, c.Status.Metrics[0].Result)
+	assert.Equal(t, flaggerv1.CheckPassed, c.Status.Metrics[1].Result)
+}
+
+func TestController_runMetricComparison(t *testing.T) {
+	canaryValues := `[1,"1"],[2,"2"],[3,"3"],[4,"4"],[5,"5"]`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := `[1,"1"],[2,"2"],[3,"3"],[4,"4"],[5,"5"]`
+		if !strings.Contains(r.URL.Query().Get("query"), "primary") {
+			values = canaryValues
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[` + values + `]}]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := newDeploymentFixture(nil)
+	template := newDeploymentTestMetricTemplate()
+	template.Spec.Provider.Address = ts.URL
+	template.Spec.Query = `histogram_quantile(0.99, sum(rate(latency_bucket{pod=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"}[1m])) by (le))`
+	metric := flaggerv1.CanaryMetric{
+		Name:       "latency",
+		Interval:   "1m",
+		Comparison: &flaggerv1.CanaryMetricComparison{MinSamples: 5},
+	}
+
+	// same distribution
+	assert.True(t, mocks.ctrl.runMetricComparison(mocks.canary, metric, template, newAnalysisRun()))
+
+	// canary is greater
+	canaryValues = `[1,"11"],[2,"12"],[3,"13"],[4,"14"],[5,"15"]`
+	assert.False(t, mocks.ctrl.runMetricComparison(mocks.canary, metric, template, newAnalysisRun()))
+
+	// canary is greater but only less is rejected
+	metric.Comparison.Alternative = "less"
+	assert.True(t, mocks.ctrl.runMetricComparison(mocks.canary, metric, template, newAnalysisRun()))
+
+	// not enough samples
+	metric.Comparison.MinSamples = 10
+	assert.False(t, mocks.ctrl.runMetricComparison(mocks.canary, metric, template, newAnalysisRun()))
+}
+
+func TestController_newMetricComparison(t *testing.T) {
+	canary := newDeploymentTestCanary()
+	metric := flaggerv1.CanaryMetric{Name: "latency", Comparison: &flaggerv1.CanaryMetricComparison{}}
+
+	comparison, err := newMetricComparison(canary, metric)
+	require.NoError(t, err)
+	assert.Equal(t, stats.GreaterAlternative, comparison.alternative)
+	assert.Equal(t, 0.05, comparison.significance)
+	assert.Equal(t, time.Minute, comparison.interval)
+	assert.Equal(t, 15*time.Second, comparison.step)
+	assert.Equal(t, 10, comparison.minSamples)
+
+	metric.Comparison.Significance = 1.5
+	_, err = newMetricComparison(canary, metric)
+	require.Error(t, err)
+
+	metric.Comparison = &flaggerv1.CanaryMetricComparison{Test: "t-test"}
+	_, err = newMetricComparison(canary, metric)
+	require.Error(t, err)
+
+	metric.Comparison = &flaggerv1.CanaryMetricComparison{Alternative: "other"}
+	_, err = newMetricComparison(canary, metric)
+	require.Error(t, err)
+}
+
+func TestController_runMetricBurnRate(t *testing.T) {
+	ratios := map[string]string{"5m": "0.01", "1h": "0.01"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ratio := ratios["1h"]
+		if strings.Contains(r.URL.Query().Get("query"), "[5m]") {
+			ratio = ratios["5m"]
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"` + ratio + `"]}]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := newDeploymentFixture(nil)
+	template := newDeploymentTestMetricTemplate()
+	template.Spec.Provider.Address = ts.URL
+	template.Spec.Query = `sum(rate(errors{pod=~"{{ target }}-.*"}[{{ interval }}])) / sum(rate(requests{pod=~"{{ target }}-.*"}[{{ interval }}]))`
+	metric := flaggerv1.CanaryMetric{
+		Name:     "availability",
+		BurnRate: &flaggerv1.CanaryMetricBurnRate{Objective: 99.9},
+	}
+
+	// burn rate of 10 in both windows
+	assert.True(t, mocks.ctrl.runMetricBurnRate(mocks.canary, metric, template, newAnalysisRun()))
+
+	// short spike is not enough to halt the advancement
+	ratios["5m"] = "0.05"
+	assert.True(t, mocks.ctrl.runMetricBurnRate(mocks.canary, metric, template, newAnalysisRun()))
+
+	// burn rate of 50 in both windows
+	ratios["1h"] = "0.05"
+	run := newAnalysisRun()
+	assert.False(t, mocks.ctrl.runMetricBurnRate(mocks.canary, metric, template, run))
+	run.failMetric(metric)
+	assert.Equal(t, "availability 50.00 > 14.4", run.failures[0].message)
+
+	// burn rate below the custom max
+	metric.BurnRate.MaxBurnRate = 60
+	assert.True(t, mocks.ctrl.runMetricBurnRate(mocks.canary, metric, template, newAnalysisRun()))
+}
+
+func TestController_newMetricBurnRate(t *testing.T) {
+	metric := flaggerv1.CanaryMetric{Name: "availability", BurnRate: &flaggerv1.CanaryMetricBurnRate{Objective: 99}}
+
+	burnRate, err := newMetricBurnRate(metric)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.01, burnRate.budget, 1e-9)
+	assert.Equal(t, []string{"5m", "1h"}, burnRate.windows)
+	assert.Equal(t, 14.4, burnRate.maxBurnRate)
+
+	metric.BurnRate.Objective = 100
+	_, err = newMetricBurnRate(metric)
+	require.Error(t, err)
+
+	metric.BurnRate = &flaggerv1.CanaryMetricBurnRate{Objective: 99, Windows: []string{"30m", "6h"}, MaxBurnRate: 6}
+	burnRate, err = newMetricBurnRate(metric)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"30m", "6h"}, burnRate.windows)
+	assert.Equal(t, 6.0, burnRate.maxBurnRate)
+
+	metric.BurnRate.Windows = []string{"1d"}
+	_, err = newMetricBurnRate(metric)
+	require.Error(t, err)
+
+	metric.BurnRate = &flaggerv1.CanaryMetricBurnRate{Objective: 99, MaxBurnRate: -1}
+	_, err = newMetricBurnRate(metric)
+	require.Error(t, err)
+}
+
+func TestController_toMetricModel(t *testing.T) {
+	canary := newDeploymentTestCanary()
+
+	model := toMetricModel(canary, flaggerv1.CanaryMetric{Interval: "1m"})
+	assert.Empty(t, model.RunID)
+	assert.Empty(t, model.RunStart)
+	assert.Equal(t, "1m", model.RunDuration)
+
+	start := time.Now().Add(-5 * time.Minute)
+	canary.Status.RunID = "0b5c7d9e-run"
+	canary.Status.RunStartTime = metav1.NewTime(start)
+	model = toMetricModel(canary, flaggerv1.CanaryMetric{Interval: "1m"})
+	assert.Equal(t, "0b5c7d9e-run", model.RunID)
+	assert.Equal(t, strconv.FormatInt(start.Unix(), 10), model.RunStart)
+	assert.Regexp(t, `^30[0-9]s$`, model.RunDuration)
+
+	query, err := observers.RenderQuery(`sum(rate(requests{run="{{ run_id }}"}[{{ run_duration }}]))`, model)
+	require.NoError(t, err)
+	assert.Equal(t, `sum(rate(requests{run="0b5c7d9e-run"}[`+model.RunDuration+`]))`, query)
+
+	model = toMetricModel(canary, flaggerv1.CanaryMetric{Interval: "1m", Percentile: 0.95})
+	assert.Equal(t, "0.95", model.Percentile)
+
+	canary.Spec.Service.AdditionalPorts = []flaggerv1.CanaryServicePort{{Name: "grpc", Port: 9999}}
+	model = toMetricModel(canary, flaggerv1.CanaryMetric{Interval: "1m"})
+	query, err = observers.RenderQuery(`sum(rate(requests{port=~"{{ ports }}"}[1m]))`, model)
+	require.NoError(t, err)
+	assert.Equal(t, `sum(rate(requests{port=~"9898|9999"}[1m]))`, query)
+}
+
+func TestAlignMetricInterval(t *testing.T) {
+	for _, tc := range []struct {
+		interval string
+		scrape   time.Duration
+		expected string
+	}{
+		{interval: "1m", scrape: 0, expected: "1m"},
+		{interval: "1m", scrape: 30 * time.Second, expected: "1m"},
+		{interval: "30s", scrape: 30 * time.Second, expected: "1m"},
+		{interval: "1m", scrape: time.Minute, expected: "2m"},
+		{interval: "50s", scrape: 15 * time.Second, expected: "1m"},
+		{interval: "100s", scrape: 15 * time.Second, expected: "105s"},
+		{interval: "", scrape: time.Minute, expected: ""},
+		{interval: "1h30m", scrape: time.Minute, expected: "1h30m"},
+	} {
+		assert.Equal(t, tc.expected, alignMetricInterval(tc.interval, tc.scrape), tc.interval)
+	}
+
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.scrapeInterval = 30 * time.Second
+	assert.Equal(t, 30*time.Second, mocks.ctrl.getScrapeInterval(flaggerv1.MetricTemplateProvider{}))
+	assert.Equal(t, time.Minute, mocks.ctrl.getScrapeInterval(flaggerv1.MetricTemplateProvider{ScrapeInterval: "1m"}))
+}
+
+func TestCanaryJobOffset(t *testing.T) {
+	assert.Equal(t, time.Duration(0), canaryJobOffset("podinfo.default", time.Minute, 0))
+
+	offset := canaryJobOffset("podinfo.default", time.Minute, 10*time.Second)
+	assert.Less(t, offset, 10*time.Second)
+	assert.Equal(t, offset, canaryJobOffset("podinfo.default", time.Minute, 10*time.Second))
+
+	// the offset is lower than the analysis interval
+	assert.Less(t, canaryJobOffset("podinfo.default", 5*time.Second, time.Minute), 5*time.Second)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/metrics/observers"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+)
+
+// primaryMetricModel returns the model of the metric queries scoped to the primary workload
+func primaryMetricModel(canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric) (flaggerv1.MetricTemplateModel, error) {
+	model := toMetricModel(canary, metric)
+	target, err := canary.GetPrimaryName(model.Target)
+	if err != nil {
+		return model, err
+	}
+	model.Target = target
+	return model, nil
+}
+
+// getBuiltinPrimaryValue runs the builtin metric query against the primary workload,
+// the request duration is returned in milliseconds to match the threshold range
+func getBuiltinPrimaryValue(observer observers.Interface, client providers.Interface,
+	canary *flaggerv1.Canary, metric flaggerv1.CanaryMetric) (float64, error) {
+	model, err := primaryMetricModel(canary, metric)
+	if err != nil {
+		return 0, err
+	}
+	switch metric.Name {
+	case "request-success-rate", "grpc-success-rate":
+		return getBuiltinSuccessRate(observer, metric.Name, model)
+	case "request-duration", "grpc-duration":
+		val, err := getBuiltinDuration(observer, metric.Name, model)
+		if err != nil {
+			return 0, err
+		}
+		return float64(val) / float64(time.Millisecond), nil
+	}
+
+	if metric.Query == "" {
+		return 0, fmt.Errorf("metric %s has no query", metric.Name)
+	}
+	query, err := observers.RenderQuery(metric.Query, model)
+	if err != nil {
+		return 0, fmt.Errorf("query render error: %w", err)
+	}
+	return client.RunQuery(query)
+}
+
+// relativeThresholdRange tightens the threshold range of the metric with
+// the bounds derived from the primary value and the relative threshold ratios
+func relativeThresholdRange(metric flaggerv1.CanaryMetric, primaryVal float64) *flaggerv1.CanaryThresholdRange {
+	var tr flaggerv1.CanaryThresholdRange
+	if metric.ThresholdRange != nil || metric.Threshold != 0 {
+		tr = metricThresholdRange(metric)
+	}
+
+	rt := metric.RelativeThreshold
+	if rt.MaxRatio != nil {
+		max := roundThreshold(primaryVal * *rt.MaxRatio)
+		if tr.Max == nil || max < *tr.Max {
+			tr.Max = &max
+		}
+	}
+	if rt.MinRatio != nil {
+		min := roundThreshold(primaryVal * *rt.MinRatio)
+		if tr.Min == nil || min > *tr.Min {
+			tr.Min = &min
+		}
+	}
+	return &tr
+}
+
+// roundThreshold rounds the threshold to four decimals to keep the events readable
+func roundThreshold(val float64) float64 {
+	return math.Round(val*1e4) / 1e4
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+)
+
+// runReleaseGroupGate halts the promotion until the analysis of all the canaries
+// in the release groups of this canary has finished
+func (c *Controller) runReleaseGroupGate(cd *flaggerv1.Canary, canaryController canary.Controller) bool {
+	members, err := c.getReleaseGroupMembers(cd, false)
+	if err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return false
+	}
+
+	var pending []string
+	for _, member := range members {
+		switch member.Status.Phase {
+		case flaggerv1.CanaryPhaseInitializing, flaggerv1.CanaryPhaseWaiting, flaggerv1.CanaryPhaseProgressing:
+			pending = append(pending, member.Name)
+		}
+	}
+	if len(pending) == 0 {
+		return true
+	}
+
+	if cd.Status.Phase != flaggerv1.CanaryPhaseWaitingPromotion {
+		if err := canaryController.SetStatusPhase(cd, flaggerv1.CanaryPhaseWaitingPromotion); err != nil {
+			c.canaryLogger(cd).Errorf("%v", err)
+		}
+		c.recordEventWarningf(cd, "Halt %s.%s advancement waiting for release group canaries %s",
+			cd.Name, cd.Namespace, strings.Join(pending, ", "))
+	} else {
+		c.repeatLastIteration(cd, canaryController)
+	}
+	return false
+}
+
+// holdReleaseGroupWeight returns true if a canary of the lockstep release groups
+// is waiting to start or is under analysis with a lower traffic weight
+func (c *Controller) holdReleaseGroupWeight(cd *flaggerv1.Canary, canaryWeight int) bool {
+	members, err := c.getReleaseGroupMembers(cd, true)
+	if err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return true
+	}
+
+	var behind []string
+	for _, member := range members {
+		switch member.Status.Phase {
+		case flaggerv1.CanaryPhaseWaiting:
+			behind = append(behind, member.Name)
+		case flaggerv1.CanaryPhaseProgressing:
+			if member.Status.CanaryWeight < canaryWeight {
+				behind = append(behind, member.Name)
+			}
+		}
+	}
+	if len(behind) == 0 {
+		return false
+	}
+
+	c.recordEventInfof(cd, "Holding %s.%s canary weight %v for release group canaries %s",
+		cd.Name, cd.Namespace, canaryWeight, strings.Join(behind, ", "))
+	return true
+}
+
+// hasReleaseGroupFailed returns the name of the first canary in the release groups
+// of this canary that failed after the current analysis has started
+func (c *Controller) hasReleaseGroupFailed(cd *flaggerv1.Canary) (string, bool) {
+	started := getPromotedCondition(cd.Status)
+	if started == nil || started.Status != corev1.ConditionUnknown {
+		return "", false
+	}
+
+	members, err := c.getReleaseGroupMembers(cd, false)
+	if err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return "", false
+	}
+
+	for _, member := range members {
+		if member.Status.Phase != flaggerv1.CanaryPhaseFailed {
+			continue
+		}
+		failed := getPromotedCondition(member.Status)
+		if failed != nil && failed.Status == corev1.ConditionFalse &&
+			!failed.LastTransitionTime.Before(&started.LastTransitionTime) {
+			return member.Name, true
+		}
+	}
+	return "", false
+}
+
+// getReleaseGroupMembers returns the other canaries of the release groups this canary is part of,
+// only the lockstep groups are considered when lockstep is true
+func (c *Controller) getReleaseGroupMembers(cd *flaggerv1.Canary, lockstep bool) ([]*flaggerv1.Canary, error) {
+	groups, err := c.flaggerInformers.ReleaseGroupInformer.Lister().ReleaseGroups(cd.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("release groups %s query failed: %w", cd.Namespace, err)
+	}
+
+	seen := map[string]bool{cd.Name: true}
+	var members []*flaggerv1.Canary
+	for _, group := range groups {
+		if !group.HasCanary(cd.Name) || (lockstep && !group.Spec.Lockstep) {
+			continue
+		}
+		for _, ref := range group.Spec.Canaries {
+			if seen[ref.Name] {
+				continue
+			}
+			seen[ref.Name] = true
+			member, err := c.flaggerInformers.CanaryInformer.Lister().Canaries(cd.Namespace).Get(ref.Name)
+			if err != nil {
+				return nil, fmt.Errorf("release group %s.%s canary %s query failed: %w", group.Name, group.Namespace, ref.Name, err)
+			}
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+// getPromotedCondition returns the Promoted condition of the canary status
+func getPromotedCondition(status flaggerv1.CanaryStatus) *flaggerv1.CanaryCondition {
+	return getCanaryCondition(status, flaggerv1.PromotedType)
+}
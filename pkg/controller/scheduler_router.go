@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+const (
+	// routerMissingCRDReason is set on the RouterReady condition when the provider CRDs are not installed
+	routerMissingCRDReason = "MissingCRD"
+	// routerAvailableReason is set on the RouterReady condition when the provider CRDs have been installed
+	routerAvailableReason = "Available"
+)
+
+// reconcileMeshRouter runs the mesh router reconciliation unless the provider CRDs
+// are known to be missing, in which case the CRDs are looked up again at each interval
+// and the reconciliation resumes once they are installed
+func (c *Controller) reconcileMeshRouter(cd *flaggerv1.Canary, provider string,
+	routerFactory *router.Factory, meshRouter router.Interface) bool {
+	if !c.checkRouterResources(cd, provider, routerFactory) {
+		return false
+	}
+
+	if err := meshRouter.Reconcile(cd); err != nil {
+		// tell apart the missing CRDs from the transient routing errors
+		if missing, discoveryErr := routerFactory.MissingMeshResources(provider); discoveryErr == nil && len(missing) > 0 {
+			c.setRouterMissingResources(cd, provider, missing)
+			return false
+		}
+		c.recordEventWarningf(cd, "%v", err)
+		return false
+	}
+	return true
+}
+
+// checkRouterResources returns false if the canary is marked with missing CRDs
+// and they are still not installed
+func (c *Controller) checkRouterResources(cd *flaggerv1.Canary, provider string, routerFactory *router.Factory) bool {
+	condition := getCanaryCondition(cd.Status, flaggerv1.RouterReadyType)
+	if condition == nil || condition.Status != corev1.ConditionFalse {
+		return true
+	}
+
+	missing, err := routerFactory.MissingMeshResources(provider)
+	if err != nil {
+		c.canaryLogger(cd).Errorf("Checking the %s resources failed: %v", provider, err)
+		return false
+	}
+
+	if len(missing) > 0 {
+		c.setRouterMissingResources(cd, provider, missing)
+		return false
+	}
+
+	message := fmt.Sprintf("The %s resources are available.", provider)
+	if err := c.setRouterCondition(cd, corev1.ConditionTrue, routerAvailableReason, message); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return false
+	}
+	c.recordEventInfof(cd, "The %s resources have been installed, resuming %s.%s", provider, cd.Name, cd.Namespace)
+	return true
+}
+
+// setRouterMissingResources marks the canary with the RouterReady condition set to false,
+// the warning is emitted only when the condition changes to avoid flooding the events
+func (c *Controller) setRouterMissingResources(cd *flaggerv1.Canary, provider string, missing []schema.GroupVersionResource) {
+	names := make([]string, 0, len(missing))
+	for _, r := range missing {
+		names = append(names, r.GroupResource().String()+"/"+r.Version)
+	}
+	message := fmt.Sprintf("The %s CRDs are not installed: %s.", provider, strings.Join(names, ", "))
+
+	condition := getCanaryCondition(cd.Status, flaggerv1.RouterReadyType)
+	if condition != nil && condition.Status == corev1.ConditionFalse && condition.Message == message {
+		c.canaryLogger(cd).Debug(message)
+		return
+	}
+
+	if err := c.setRouterCondition(cd, corev1.ConditionFalse, routerMissingCRDReason, message); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	c.recordEventErrorf(cd, "%s Waiting for the CRDs to be installed.", message)
+}
+
+// setRouterCondition updates the RouterReady condition of the canary
+func (c *Controller) setRouterCondition(cd *flaggerv1.Canary, status corev1.ConditionStatus, reason string, message string) error {
+	return c.setCanaryCondition(cd, flaggerv1.RouterReadyType, status, reason, message)
+}
+
+// setCanaryCondition updates the condition of the given type, the transition time
+// is kept if the condition status is unchanged
+func (c *Controller) setCanaryCondition(cd *flaggerv1.Canary, conditionType flaggerv1.CanaryConditionType,
+	status corev1.ConditionStatus, reason string, message string) error {
+	firstTry := true
+	canary := cd
+	name, ns := cd.GetName(), cd.GetNamespace()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+		if !firstTry {
+			cd, err = c.flaggerClient.FlaggerV1beta1().Canaries(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("canary %s.%s get query failed: %w", name, ns, err)
+			}
+		}
+
+		newCondition := flaggerv1.CanaryCondition{
+			Type:               conditionType,
+			Status:             status,
+			LastUpdateTime:     metav1.Now(),
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: cd.Generation,
+		}
+
+		cdCopy := cd.DeepCopy()
+		conditions := make([]flaggerv1.CanaryCondition, 0, len(cd.Status.Conditions)+1)
+		for _, condition := range cd.Status.Conditions {
+			if condition.Type != conditionType {
+				conditions = append(conditions, condition)
+			} else if condition.Status == status {
+				newCondition.LastTransitionTime = condition.LastTransitionTime
+			}
+		}
+		cdCopy.Status.Conditions = append(conditions, newCondition)
+
+		_, err = c.flaggerClient.FlaggerV1beta1().Canaries(ns).UpdateStatus(context.TODO(), cdCopy, metav1.UpdateOptions{FieldManager: cd.FieldManager()})
+		if err == nil {
+			// the status updates that follow in this run start from the in-memory canary
+			canary.Status.Conditions = cdCopy.Status.Conditions
+		}
+		firstTry = false
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("failed after retries: %w", err)
+	}
+	return nil
+}
+
+// getCanaryCondition returns the condition of the given type or nil if not found
+func getCanaryCondition(status flaggerv1.CanaryStatus, conditionType flaggerv1.CanaryConditionType) *flaggerv1.CanaryCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
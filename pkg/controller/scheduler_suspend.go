@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+const (
+	// suspendedReason is set on the Suspended condition when spec.suspend is true
+	suspendedReason = "Suspended"
+	// resumedReason is set on the Suspended condition when spec.suspend is set back to false
+	resumedReason = "Resumed"
+)
+
+// runSuspendCheck returns false while the canary is suspended, the routing and the status
+// are left untouched so that the analysis resumes from the same weight, iteration and failed checks
+func (c *Controller) runSuspendCheck(cd *flaggerv1.Canary) bool {
+	condition := getCanaryCondition(cd.Status, flaggerv1.SuspendedType)
+	suspended := condition != nil && condition.Status == corev1.ConditionTrue
+
+	if cd.Spec.Suspend {
+		if suspended {
+			c.canaryLogger(cd).Debugf("Analysis of %s.%s is suspended", cd.Name, cd.Namespace)
+			return false
+		}
+		message := fmt.Sprintf("Analysis suspended at phase %s, canary weight %v, iteration %v, failed checks %v.",
+			cd.Status.Phase, cd.Status.CanaryWeight, cd.Status.Iterations, cd.Status.FailedChecks)
+		if err := c.setCanaryCondition(cd, flaggerv1.SuspendedType, corev1.ConditionTrue, suspendedReason, message); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return false
+		}
+		c.recordEventInfof(cd, "Suspended %s.%s %s", cd.Name, cd.Namespace, message)
+		return false
+	}
+
+	if suspended {
+		message := fmt.Sprintf("Analysis resumed at phase %s.", cd.Status.Phase)
+		if err := c.setCanaryCondition(cd, flaggerv1.SuspendedType, corev1.ConditionFalse, resumedReason, message); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return false
+		}
+		c.recordEventInfof(cd, "Resumed %s.%s analysis", cd.Name, cd.Namespace)
+	}
+	return true
+}
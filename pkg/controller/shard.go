@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// Shard selects the canaries processed by a Flagger instance, the canaries are partitioned
+// by consistent hashing of their UID and optionally filtered with a label selector
+type Shard struct {
+	id       int
+	count    int
+	selector labels.Selector
+}
+
+// NewShard returns the shard with the given ID out of count shards,
+// the canaries not matching the label selector are ignored
+func NewShard(id int, count int, selector string) (*Shard, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("shard count %d must be at least 1", count)
+	}
+	if id < 0 || id >= count {
+		return nil, fmt.Errorf("shard ID %d must be between 0 and %d", id, count-1)
+	}
+
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("shard selector %s is invalid: %w", selector, err)
+	}
+
+	return &Shard{id: id, count: count, selector: s}, nil
+}
+
+// IsSharded returns true if the canaries are partitioned between several instances
+func (s *Shard) IsSharded() bool {
+	return s != nil && (s.count > 1 || !s.selector.Empty())
+}
+
+// IsFirst returns true if the shard runs the checks that are not specific
+// to a canary, such as the metric templates validation
+func (s *Shard) IsFirst() bool {
+	return s == nil || s.id == 0
+}
+
+// Owns returns true if the canary is processed by this shard
+func (s *Shard) Owns(cd *flaggerv1.Canary) bool {
+	if s == nil {
+		return true
+	}
+	if !s.selector.Matches(labels.Set(cd.Labels)) {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(cd.UID))
+	return jumpHash(h.Sum64(), s.count) == s.id
+}
+
+// Name returns a name unique to the shard ID and the selector that can be used in object names
+func (s *Shard) Name() string {
+	if s.selector.Empty() {
+		return fmt.Sprintf("shard-%d", s.id)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(s.selector.String()))
+	return fmt.Sprintf("shard-%d-%08x", s.id, h.Sum32())
+}
+
+func (s *Shard) String() string {
+	if s.selector.Empty() {
+		return fmt.Sprintf("%d/%d", s.id, s.count)
+	}
+	return fmt.Sprintf("%d/%d selector %s", s.id, s.count, s.selector)
+}
+
+// jumpHash maps the key to one of the buckets, when the number of buckets changes
+// only the keys moving to or from the added or removed buckets are reassigned
+// https://arxiv.org/abs/1406.2294
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
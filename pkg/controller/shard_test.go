@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestNewShard(t *testing.T) {
+	_, err := NewShard(0, 0, "")
+	require.Error(t, err)
+	_, err = NewShard(3, 3, "")
+	require.Error(t, err)
+	_, err = NewShard(0, 1, "team in (")
+	require.Error(t, err)
+
+	shard, err := NewShard(0, 1, "")
+	require.NoError(t, err)
+	assert.False(t, shard.IsSharded())
+	assert.True(t, shard.IsFirst())
+
+	shard, err = NewShard(1, 3, "team=a")
+	require.NoError(t, err)
+	assert.True(t, shard.IsSharded())
+	assert.False(t, shard.IsFirst())
+	assert.Regexp(t, "^shard-1-[0-9a-f]{8}$", shard.Name())
+}
+
+func TestShard_Owns(t *testing.T) {
+	var shards []*Shard
+	for id := 0; id < 3; id++ {
+		shard, err := NewShard(id, 3, "")
+		require.NoError(t, err)
+		shards = append(shards, shard)
+	}
+
+	owned := make(map[int]int)
+	for i := 0; i < 300; i++ {
+		cd := newDeploymentTestCanary()
+		cd.UID = types.UID(fmt.Sprintf("uid-%d", i))
+
+		owners := 0
+		for id, shard := range shards {
+			if shard.Owns(cd) {
+				owners++
+				owned[id]++
+			}
+		}
+		require.Equal(t, 1, owners)
+	}
+	for id := range shards {
+		assert.Greater(t, owned[id], 50)
+	}
+
+	// all the canaries are owned without sharding
+	var shard *Shard
+	assert.True(t, shard.Owns(newDeploymentTestCanary()))
+
+	shard, err := NewShard(0, 1, "team=a")
+	require.NoError(t, err)
+	cd := newDeploymentTestCanary()
+	assert.False(t, shard.Owns(cd))
+	cd.Labels = map[string]string{"team": "a"}
+	assert.True(t, shard.Owns(cd))
+}
+
+func TestJumpHash(t *testing.T) {
+	// the keys only move to the added bucket
+	for key := uint64(0); key < 1000; key++ {
+		before, after := jumpHash(key, 3), jumpHash(key, 4)
+		if before != after {
+			assert.Equal(t, 3, after)
+		}
+	}
+}
+
+func TestController_syncHandlerShard(t *testing.T) {
+	mocks := newDeploymentFixture(nil)
+	require.NoError(t, mocks.ctrl.syncHandler("default/podinfo"))
+	_, ok := mocks.ctrl.canaries.Load("podinfo.default")
+	require.True(t, ok)
+
+	// the canary is dropped from the cache when it moves to another shard
+	shard, err := NewShard(0, 1, "team=a")
+	require.NoError(t, err)
+	mocks.ctrl.shard = shard
+	require.NoError(t, mocks.ctrl.syncHandler("default/podinfo"))
+	_, ok = mocks.ctrl.canaries.Load("podinfo.default")
+	assert.False(t, ok)
+}
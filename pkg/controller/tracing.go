@@ -0,0 +1,42 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/logger"
+	"github.com/fluxcd/flagger/pkg/tracing"
+)
+
+// runSpan returns the span of the current analysis run of the canary,
+// nil when tracing is disabled or outside of the analysis run
+func (c *Controller) runSpan(cd *flaggerv1.Canary) *tracing.Span {
+	if span, ok := c.spans.Load(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)); ok {
+		return span.(*tracing.Span)
+	}
+	return nil
+}
+
+// webhookSpan starts the span of a webhook call in the analysis run of the canary
+func (c *Controller) webhookSpan(cd *flaggerv1.Canary, w flaggerv1.CanaryWebhook) *tracing.Span {
+	return c.runSpan(cd).Child("webhook",
+		tracing.String("webhook.name", w.Name),
+		tracing.String("webhook.type", string(w.Type)),
+		tracing.String("webhook.url", logger.RedactURL(w.URL)))
+}
@@ -20,15 +20,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/cloudevents"
+	"github.com/fluxcd/flagger/pkg/logger"
 )
 
 func callWebhook(webhook string, payload interface{}, timeout string) error {
@@ -63,7 +69,8 @@ func callWebhook(webhook string, payload interface{}, timeout string) error {
 
 	r, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		// the webhook URL can contain tokens in the query
+		return logger.RedactError(err)
 	}
 	defer r.Body.Close()
 
@@ -73,7 +80,10 @@ func callWebhook(webhook string, payload interface{}, timeout string) error {
 	}
 
 	if r.StatusCode > 202 {
-		return errors.New(string(b))
+		if body := strings.TrimSpace(string(b)); body != "" {
+			return fmt.Errorf("webhook returned %s: %s", r.Status, body)
+		}
+		return fmt.Errorf("webhook returned %s", r.Status)
 	}
 
 	return nil
@@ -99,6 +109,127 @@ func CallWebhook(name string, namespace string, phase flaggerv1.CanaryPhase, w f
 	return callWebhook(w.URL, payload, w.Timeout)
 }
 
+// runWebhook calls the webhook unless the fault injector returns a synthetic error
+func (c *Controller) runWebhook(canary *flaggerv1.Canary, phase flaggerv1.CanaryPhase, w flaggerv1.CanaryWebhook) error {
+	span := c.webhookSpan(canary, w)
+	defer span.End()
+	err := c.faultInjector.WebhookError()
+	if err == nil {
+		c.canaryLogger(canary).Debugf("Calling %s webhook %s %s", w.Type, w.Name, logger.RedactURL(w.URL))
+		begin := time.Now()
+		err = CallWebhook(canary.Name, canary.Namespace, phase, w)
+		c.recorder.SetWebhookDuration(canary, w, time.Since(begin), err)
+	}
+	span.RecordError(err)
+	c.setWebhookStatus(canary, w, err)
+	return err
+}
+
+// webhookStatusMessageLength is the max length of the webhook error kept in the canary status
+const webhookStatusMessageLength = 256
+
+// truncateMessage cuts the message to at most max bytes without splitting a multi-byte rune
+func truncateMessage(message string, max int) string {
+	if len(message) <= max {
+		return message
+	}
+	for max > 0 && !utf8.RuneStart(message[max]) {
+		max--
+	}
+	return message[:max]
+}
+
+// setWebhookStatus replaces the last result of the webhook in the canary status
+func (c *Controller) setWebhookStatus(cd *flaggerv1.Canary, w flaggerv1.CanaryWebhook, err error) {
+	result := flaggerv1.CanaryWebhookStatus{
+		Name:          w.Name,
+		Type:          w.Type,
+		Result:        flaggerv1.CheckPassed,
+		LastCheckTime: metav1.Now(),
+	}
+	if result.Type == "" {
+		result.Type = flaggerv1.RolloutHook
+	}
+	if err != nil {
+		result.Result, result.Message = flaggerv1.CheckFailed, err.Error()
+		result.Message = truncateMessage(result.Message, webhookStatusMessageLength)
+		c.publishCloudEvent(cd, cloudevents.CanaryWebhookFailed, cloudevents.CanaryData{
+			CanaryWeight:  cd.Status.CanaryWeight,
+			PrimaryWeight: c.totalWeight(cd) - cd.Status.CanaryWeight,
+			Webhook:       w.Name,
+			Message:       result.Message,
+		})
+	}
+
+	// the polled gates are recorded in the audit trail when their result changes
+	changed := true
+	webhooks := make([]flaggerv1.CanaryWebhookStatus, 0, len(cd.Status.Webhooks)+1)
+	for _, s := range cd.Status.Webhooks {
+		if s.Name != result.Name || s.Type != result.Type {
+			webhooks = append(webhooks, s)
+		} else {
+			changed = s.Result != result.Result || s.Message != result.Message
+		}
+	}
+	if changed {
+		c.recordAudit(cd, flaggerv1.CanaryRunRecord{
+			Time:    result.LastCheckTime,
+			Type:    flaggerv1.WebhookRecord,
+			Name:    fmt.Sprintf("%s/%s", result.Type, result.Name),
+			Result:  result.Result,
+			Message: result.Message,
+		})
+	}
+	webhooks = append(webhooks, result)
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].Name < webhooks[j].Name
+	})
+
+	canaryController := c.canaryFactory.Controller(cd.Spec.TargetRef.Kind)
+	if err := canaryController.SetStatusWebhooks(cd, webhooks); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	// the status updates that follow in this run start from the in-memory canary
+	cd.Status.Webhooks = webhooks
+}
+
+// CallRollbackWebhook sends the cause of the rollback and the failed checks to the webhook
+func CallRollbackWebhook(name string, namespace string, w flaggerv1.CanaryWebhook, rollback *flaggerv1.CanaryRollbackPayload) error {
+	payload := flaggerv1.CanaryWebhookPayload{
+		Name:      name,
+		Namespace: namespace,
+		Phase:     flaggerv1.CanaryPhaseFailed,
+		Rollback:  rollback,
+	}
+
+	if w.Metadata != nil {
+		payload.Metadata = *w.Metadata
+	}
+
+	if len(w.Timeout) < 2 {
+		w.Timeout = "10s"
+	}
+
+	return callWebhook(w.URL, payload, w.Timeout)
+}
+
+// runRollbackWebhook calls the post-rollback webhook unless the fault injector returns a synthetic error
+func (c *Controller) runRollbackWebhook(canary *flaggerv1.Canary, w flaggerv1.CanaryWebhook, rollback *flaggerv1.CanaryRollbackPayload) error {
+	span := c.webhookSpan(canary, w)
+	defer span.End()
+	err := c.faultInjector.WebhookError()
+	if err == nil {
+		c.canaryLogger(canary).Debugf("Calling %s webhook %s %s", w.Type, w.Name, logger.RedactURL(w.URL))
+		begin := time.Now()
+		err = CallRollbackWebhook(canary.Name, canary.Namespace, w, rollback)
+		c.recorder.SetWebhookDuration(canary, w, time.Since(begin), err)
+	}
+	span.RecordError(err)
+	c.setWebhookStatus(canary, w, err)
+	return err
+}
+
 func CallEventWebhook(r *flaggerv1.Canary, w flaggerv1.CanaryWebhook, message, eventtype string) error {
 	t := time.Now()
 
@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -29,6 +30,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/cloudevents"
 )
 
 func TestCallWebhook(t *testing.T) {
@@ -58,7 +60,29 @@ func TestCallWebhook_StatusCode(t *testing.T) {
 	}
 
 	err := CallWebhook("podinfo", v1.NamespaceDefault, flaggerv1.CanaryPhaseProgressing, hook)
-	assert.Error(t, err)
+	assert.EqualError(t, err, "webhook returned 500 Internal Server Error")
+}
+
+func TestCallWebhook_StatusCodeBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("gate closed\n"))
+	}))
+	defer ts.Close()
+	hook := flaggerv1.CanaryWebhook{
+		Name: "gate",
+		URL:  ts.URL,
+	}
+
+	err := CallWebhook("podinfo", v1.NamespaceDefault, flaggerv1.CanaryPhaseProgressing, hook)
+	assert.EqualError(t, err, "webhook returned 403 Forbidden: gate closed")
+}
+
+func TestTruncateMessage(t *testing.T) {
+	assert.Equal(t, "short", truncateMessage("short", 10))
+	assert.Equal(t, "abc", truncateMessage("abcdef", 3))
+	// the two-byte rune is dropped instead of being split
+	assert.Equal(t, "ab", truncateMessage("abé", 3))
 }
 
 func TestCallEventWebhook(t *testing.T) {
@@ -146,3 +170,59 @@ func TestCallEventWebhookStatusCode(t *testing.T) {
 	err := CallEventWebhook(canary, hook, canaryMessage, canaryEventType)
 	assert.Error(t, err)
 }
+
+func TestController_runWebhookStatus(t *testing.T) {
+	code := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer ts.Close()
+
+	mocks := newDeploymentFixture(nil)
+	hook := flaggerv1.CanaryWebhook{Name: "load-test", URL: ts.URL}
+	require.NoError(t, mocks.ctrl.runWebhook(mocks.canary, flaggerv1.CanaryPhaseProgressing, hook))
+
+	c, err := mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", v1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Webhooks, 1)
+	assert.Equal(t, flaggerv1.RolloutHook, c.Status.Webhooks[0].Type)
+	assert.Equal(t, flaggerv1.CheckPassed, c.Status.Webhooks[0].Result)
+
+	// the last result replaces the previous one
+	code = http.StatusInternalServerError
+	require.Error(t, mocks.ctrl.runWebhook(c, flaggerv1.CanaryPhaseProgressing, hook))
+	c, err = mocks.flaggerClient.FlaggerV1beta1().Canaries("default").Get(context.TODO(), "podinfo", v1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, c.Status.Webhooks, 1)
+	assert.Equal(t, flaggerv1.CheckFailed, c.Status.Webhooks[0].Result)
+	assert.Equal(t, "webhook returned 500 Internal Server Error", c.Status.Webhooks[0].Message)
+}
+
+func TestController_publishCloudEvent(t *testing.T) {
+	var global, override []string
+	newSink := func(types *[]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var event cloudevents.Event
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+			*types = append(*types, string(event.Type))
+		}))
+	}
+	globalSink, overrideSink := newSink(&global), newSink(&override)
+	defer globalSink.Close()
+	defer overrideSink.Close()
+
+	mocks := newDeploymentFixture(nil)
+	mocks.ctrl.cloudEventsSink = globalSink.URL
+	mocks.ctrl.publishCloudEvent(mocks.canary, cloudevents.CanaryInitialized, cloudevents.CanaryData{})
+	assert.Equal(t, []string{"app.flagger.canary.initialized"}, global)
+
+	// the cloudevents webhooks of the canary override the global sink
+	mocks.canary.Spec.Analysis.Webhooks = append(mocks.canary.Spec.Analysis.Webhooks, flaggerv1.CanaryWebhook{
+		Name: "bus",
+		Type: flaggerv1.CloudEventsHook,
+		URL:  overrideSink.URL,
+	})
+	mocks.ctrl.setWebhookStatus(mocks.canary, flaggerv1.CanaryWebhook{Name: "load-test"}, fmt.Errorf("timeout"))
+	assert.Len(t, global, 1)
+	assert.Equal(t, []string{"app.flagger.canary.webhook.failed"}, override)
+}
@@ -28,21 +28,7 @@ func NewLogger(logLevel string) (*zap.SugaredLogger, error) {
 
 // NewLoggerWithEncoding returns a zap sugared logger configured with provided format, e.g. console or json, and caller id
 func NewLoggerWithEncoding(logLevel, zapEncoding string) (*zap.SugaredLogger, error) {
-	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
-	switch logLevel {
-	case "debug":
-		level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
-	case "info":
-		level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
-	case "warn":
-		level = zap.NewAtomicLevelAt(zapcore.WarnLevel)
-	case "error":
-		level = zap.NewAtomicLevelAt(zapcore.ErrorLevel)
-	case "fatal":
-		level = zap.NewAtomicLevelAt(zapcore.FatalLevel)
-	case "panic":
-		level = zap.NewAtomicLevelAt(zapcore.PanicLevel)
-	}
+	level, _ := parseLevel(logLevel)
 
 	zapEncoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "ts",
@@ -58,8 +44,9 @@ func NewLoggerWithEncoding(logLevel, zapEncoding string) (*zap.SugaredLogger, er
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
+	// the level is enforced by the levelCore to allow lowering it with WithLevel
 	zapConfig := zap.Config{
-		Level:       level,
+		Level:       zap.NewAtomicLevelAt(zapcore.DebugLevel),
 		Development: false,
 		Sampling: &zap.SamplingConfig{
 			Initial:    100,
@@ -71,9 +58,66 @@ func NewLoggerWithEncoding(logLevel, zapEncoding string) (*zap.SugaredLogger, er
 		ErrorOutputPaths: []string{"stderr"},
 	}
 
-	logger, err := zapConfig.Build()
+	logger, err := zapConfig.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: level}
+	}))
 	if err != nil {
 		return nil, err
 	}
 	return logger.Sugar(), nil
 }
+
+// WithLevel returns a logger with the given level e.g. debug, the logger
+// is returned unchanged if the level is not valid
+func WithLevel(logger *zap.SugaredLogger, logLevel string) *zap.SugaredLogger {
+	level, ok := parseLevel(logLevel)
+	if !ok {
+		return logger
+	}
+
+	return logger.Desugar().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if lc, ok := core.(*levelCore); ok {
+			return &levelCore{Core: lc.Core, level: level}
+		}
+		return core
+	})).Sugar()
+}
+
+func parseLevel(logLevel string) (zapcore.Level, bool) {
+	switch logLevel {
+	case "debug":
+		return zapcore.DebugLevel, true
+	case "info":
+		return zapcore.InfoLevel, true
+	case "warn":
+		return zapcore.WarnLevel, true
+	case "error":
+		return zapcore.ErrorLevel, true
+	case "fatal":
+		return zapcore.FatalLevel, true
+	case "panic":
+		return zapcore.PanicLevel, true
+	}
+	return zapcore.InfoLevel, false
+}
+
+// levelCore filters the log entries of a core that accepts all levels
+type levelCore struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// redacted is the placeholder used by url.URL.Redacted for passwords
+const redacted = "xxxxx"
+
+// minSecretLength prevents the redaction of short values that are unlikely to be secrets e.g. true or 1
+const minSecretLength = 4
+
+// sensitiveParams are the URL query parameters with values hidden from the logs
+var sensitiveParams = []string{"auth", "key", "password", "secret", "signature", "token"}
+
+// Redact replaces the secret values found in the message
+func Redact(message string, secrets ...string) string {
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			message = strings.ReplaceAll(message, secret, redacted)
+		}
+	}
+	return message
+}
+
+// RedactURL hides the password and the values of the query parameters
+// that look like credentials e.g. token or api_key
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := u.Query()
+	for param := range query {
+		for _, sensitive := range sensitiveParams {
+			if strings.Contains(strings.ToLower(param), sensitive) {
+				query.Set(param, redacted)
+				break
+			}
+		}
+	}
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// RedactError returns an error with the secret values and the URL credentials
+// removed from the message, the original error can be unwrapped
+func RedactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+
+	message := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		message = strings.ReplaceAll(message, urlErr.URL, RedactURL(urlErr.URL))
+	}
+	message = Redact(message, secrets...)
+
+	if message == err.Error() {
+		return err
+	}
+	return &redactedError{message: message, err: err}
+}
+
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
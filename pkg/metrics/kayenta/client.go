@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kayenta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+const (
+	PassClassification     = "Pass"
+	MarginalClassification = "Marginal"
+	FailClassification     = "Fail"
+	NoDataClassification   = "Nodata"
+
+	succeededStatus = "succeeded"
+)
+
+// Client runs canary judgements with the Kayenta standalone canary API
+type Client struct {
+	address      string
+	client       *http.Client
+	pollInterval time.Duration
+}
+
+// ExecutionRequest is the Kayenta canary execution request
+type ExecutionRequest struct {
+	Scopes     map[string]ScopePair `json:"scopes"`
+	Thresholds Thresholds           `json:"thresholds"`
+}
+
+// ScopePair holds the scopes of the baseline and canary metrics
+type ScopePair struct {
+	ControlScope    Scope `json:"controlScope"`
+	ExperimentScope Scope `json:"experimentScope"`
+}
+
+// Scope selects the metrics of a workload in the given time window
+type Scope struct {
+	Scope    string    `json:"scope"`
+	Location string    `json:"location"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Step     int64     `json:"step"`
+}
+
+// Thresholds are the pass and marginal scores of the judgement
+type Thresholds struct {
+	Pass     float64 `json:"pass"`
+	Marginal float64 `json:"marginal"`
+}
+
+// Score is the result of the canary judgement
+type Score struct {
+	Score          float64 `json:"score"`
+	Classification string  `json:"classification"`
+}
+
+type executionResponse struct {
+	CanaryExecutionID string `json:"canaryExecutionId"`
+}
+
+type executionStatusResponse struct {
+	Complete bool   `json:"complete"`
+	Status   string `json:"status"`
+	Result   *struct {
+		JudgeResult struct {
+			Score Score `json:"score"`
+		} `json:"judgeResult"`
+	} `json:"result,omitempty"`
+}
+
+// NewClient returns a Kayenta client for the given address
+func NewClient(address string) (*Client, error) {
+	if _, err := url.Parse(address); err != nil {
+		return nil, fmt.Errorf("kayenta address %s is not a valid URL", address)
+	}
+
+	return &Client{
+		address:      address,
+		client:       http.DefaultClient,
+		pollInterval: time.Second,
+	}, nil
+}
+
+// Judge starts a canary execution for the given canary config
+// and waits for the judgement score until the context is done
+func (c *Client) Judge(ctx context.Context, configID string, params url.Values, request ExecutionRequest) (*Score, error) {
+	executionID, err := c.startExecution(ctx, configID, params, request)
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := c.getExecution(ctx, executionID)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.Complete {
+			if status.Status != succeededStatus || status.Result == nil {
+				return nil, fmt.Errorf("kayenta execution %s ended with status %s", executionID, status.Status)
+			}
+			return &status.Result.JudgeResult.Score, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("kayenta execution %s not completed: %w", executionID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) startExecution(ctx context.Context, configID string, params url.Values, request ExecutionRequest) (string, error) {
+	u, err := url.Parse(c.address)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse failed: %w", err)
+	}
+	u.Path = path.Join(u.Path, "canary", configID)
+	u.RawQuery = params.Encode()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("marshalling execution request failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewBuffer(body))
+	if err != nil {
+		return "", fmt.Errorf("http.NewRequest failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var res executionResponse
+	if err := c.do(req, &res); err != nil {
+		return "", err
+	}
+
+	if res.CanaryExecutionID == "" {
+		return "", fmt.Errorf("kayenta execution ID not found in response")
+	}
+	return res.CanaryExecutionID, nil
+}
+
+func (c *Client) getExecution(ctx context.Context, executionID string) (*executionStatusResponse, error) {
+	u, err := url.Parse(c.address)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse failed: %w", err)
+	}
+	u.Path = path.Join(u.Path, "canary", executionID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest failed: %w", err)
+	}
+
+	var res executionStatusResponse
+	if err := c.do(req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) do(req *http.Request, result interface{}) error {
+	r, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer r.Body.Close()
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading body: %w", err)
+	}
+
+	if r.StatusCode >= 400 {
+		return fmt.Errorf("error response: %s", string(b))
+	}
+
+	if err := json.Unmarshal(b, result); err != nil {
+		return fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+	return nil
+}
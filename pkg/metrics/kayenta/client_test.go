@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kayenta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Judge(t *testing.T) {
+	polls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/canary/podinfo-config":
+			assert.Equal(t, "flagger", r.URL.Query().Get("application"))
+			assert.Equal(t, "prometheus", r.URL.Query().Get("metricsAccountName"))
+
+			var req ExecutionRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "podinfo-primary", req.Scopes["default"].ControlScope.Scope)
+			assert.Equal(t, "podinfo", req.Scopes["default"].ExperimentScope.Scope)
+			assert.Equal(t, float64(95), req.Thresholds.Pass)
+
+			w.Write([]byte(`{"canaryExecutionId":"01EXEC"}`))
+		case r.Method == "GET" && r.URL.Path == "/canary/01EXEC":
+			polls++
+			if polls < 2 {
+				w.Write([]byte(`{"complete":false,"status":"running"}`))
+				return
+			}
+			w.Write([]byte(`{"complete":true,"status":"succeeded","result":{"judgeResult":{"score":{"score":97.5,"classification":"Pass"}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL)
+	require.NoError(t, err)
+	client.pollInterval = 10 * time.Millisecond
+
+	params := url.Values{}
+	params.Set("application", "flagger")
+	params.Set("metricsAccountName", "prometheus")
+	request := ExecutionRequest{
+		Scopes: map[string]ScopePair{
+			"default": {
+				ControlScope:    Scope{Scope: "podinfo-primary", Location: "default"},
+				ExperimentScope: Scope{Scope: "podinfo", Location: "default"},
+			},
+		},
+		Thresholds: Thresholds{Pass: 95, Marginal: 75},
+	}
+
+	score, err := client.Judge(context.TODO(), "podinfo-config", params, request)
+	require.NoError(t, err)
+	assert.Equal(t, 97.5, score.Score)
+	assert.Equal(t, PassClassification, score.Classification)
+	assert.Equal(t, 2, polls)
+}
+
+func TestClient_JudgeFailedExecution(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Write([]byte(`{"canaryExecutionId":"01EXEC"}`))
+			return
+		}
+		w.Write([]byte(`{"complete":true,"status":"terminal"}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL)
+	require.NoError(t, err)
+
+	_, err = client.Judge(context.TODO(), "podinfo-config", url.Values{}, ExecutionRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "terminal")
+}
+
+func TestClient_JudgeTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Write([]byte(`{"canaryExecutionId":"01EXEC"}`))
+			return
+		}
+		w.Write([]byte(`{"complete":false,"status":"running"}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL)
+	require.NoError(t, err)
+	client.pollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = client.Judge(ctx, "podinfo-config", url.Values{}, ExecutionRequest{})
+	require.Error(t, err)
+}
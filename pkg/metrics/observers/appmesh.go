@@ -47,7 +47,7 @@ var appMeshQueries = map[string]string{
 	* 100`,
 	"request-duration": `
 	histogram_quantile(
-		0.99,
+		{{ percentile }},
 		sum(
 			rate(
 				envoy_cluster_upstream_rq_time_bucket{
@@ -57,6 +57,37 @@ var appMeshQueries = map[string]string{
 			)
 		) by (le)
 	)`,
+	"grpc-success-rate": `
+	sum(
+		rate(
+			envoy_cluster_grpc_success{
+				kubernetes_namespace="{{ namespace }}",
+				kubernetes_pod_name=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"
+			}[{{ interval }}]
+		)
+	) 
+	/ 
+	sum(
+		rate(
+			envoy_cluster_grpc_total{
+				kubernetes_namespace="{{ namespace }}",
+				kubernetes_pod_name=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"
+			}[{{ interval }}]
+		)
+	) 
+	* 100`,
+	"grpc-duration": `
+	histogram_quantile(
+		{{ percentile }},
+		sum(
+			rate(
+				envoy_cluster_grpc_upstream_rq_time_bucket{
+					kubernetes_namespace="{{ namespace }}",
+					kubernetes_pod_name=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"
+				}[{{ interval }}]
+			)
+		) by (le)
+	)`,
 }
 
 type AppMeshObserver struct {
@@ -91,3 +122,34 @@ func (ob *AppMeshObserver) GetRequestDuration(model flaggerv1.MetricTemplateMode
 	ms := time.Duration(int64(value)) * time.Millisecond
 	return ms, nil
 }
+
+// GetGrpcSuccessRate returns the rate of the gRPC requests reported with the OK status
+// by the Envoy gRPC statistics filter
+func (ob *AppMeshObserver) GetGrpcSuccessRate(model flaggerv1.MetricTemplateModel) (float64, error) {
+	query, err := RenderQuery(appMeshQueries["grpc-success-rate"], model)
+	if err != nil {
+		return 0, fmt.Errorf("rendering query failed: %w", err)
+	}
+
+	value, err := ob.client.RunQuery(query)
+	if err != nil {
+		return 0, fmt.Errorf("running query failed: %w", err)
+	}
+
+	return value, nil
+}
+
+func (ob *AppMeshObserver) GetGrpcDuration(model flaggerv1.MetricTemplateModel) (time.Duration, error) {
+	query, err := RenderQuery(appMeshQueries["grpc-duration"], model)
+	if err != nil {
+		return 0, fmt.Errorf("rendering query failed: %w", err)
+	}
+
+	value, err := ob.client.RunQuery(query)
+	if err != nil {
+		return 0, fmt.Errorf("running query failed: %w", err)
+	}
+
+	ms := time.Duration(int64(value)) * time.Millisecond
+	return ms, nil
+}
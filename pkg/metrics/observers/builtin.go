@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package observers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// DefaultBuiltinMetricProvider selects the query used when a metric
+// has no query for the provider of the canary
+const DefaultBuiltinMetricProvider = "default"
+
+// IsBuiltinMetric returns true if the metric is implemented by the observers
+func IsBuiltinMetric(name string) bool {
+	switch name {
+	case "request-success-rate", "request-duration", "grpc-success-rate", "grpc-duration":
+		return true
+	}
+	return false
+}
+
+// BuiltinMetricSet is the list of metrics registered by the operators
+// in addition to request-success-rate and request-duration
+type BuiltinMetricSet struct {
+	Metrics []BuiltinMetric `json:"metrics"`
+}
+
+// BuiltinMetric holds the Prometheus query templates of a metric indexed by provider
+// e.g. istio, linkerd or default
+type BuiltinMetric struct {
+	Name    string            `json:"name"`
+	Queries map[string]string `json:"queries"`
+}
+
+// BuiltinMetrics holds the query templates indexed by metric name and provider
+type BuiltinMetrics map[string]map[string]string
+
+// LoadBuiltinMetrics reads the YAML metric set from a file
+// usually mounted from a ConfigMap
+func LoadBuiltinMetrics(path string) (BuiltinMetrics, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading builtin metrics failed: %w", err)
+	}
+	defer f.Close()
+
+	return ParseBuiltinMetrics(f)
+}
+
+// ParseBuiltinMetrics decodes and validates a YAML or JSON metric set
+func ParseBuiltinMetrics(r io.Reader) (BuiltinMetrics, error) {
+	var set BuiltinMetricSet
+	if err := yaml.NewYAMLOrJSONDecoder(r, 4096).Decode(&set); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decoding builtin metrics failed: %w", err)
+	}
+
+	metrics := BuiltinMetrics{}
+	for _, metric := range set.Metrics {
+		switch {
+		case metric.Name == "":
+			return nil, fmt.Errorf("builtin metric name is required")
+		case IsBuiltinMetric(metric.Name):
+			return nil, fmt.Errorf("builtin metric %s can't be overridden", metric.Name)
+		case metrics[metric.Name] != nil:
+			return nil, fmt.Errorf("builtin metric %s is duplicated", metric.Name)
+		case len(metric.Queries) == 0:
+			return nil, fmt.Errorf("builtin metric %s has no queries", metric.Name)
+		}
+		for provider, query := range metric.Queries {
+			if _, err := RenderQuery(query, flaggerv1.MetricTemplateModel{}); err != nil {
+				return nil, fmt.Errorf("builtin metric %s query for %s is invalid: %w", metric.Name, provider, err)
+			}
+		}
+		metrics[metric.Name] = metric.Queries
+	}
+	return metrics, nil
+}
+
+// Query returns the query template of the metric for the provider, the provider version
+// e.g. gatewayapi:v1alpha2 is ignored if no query matches the full name
+func (b BuiltinMetrics) Query(name string, provider string) (string, bool) {
+	queries, ok := b[name]
+	if !ok {
+		return "", false
+	}
+
+	for _, p := range []string{provider, strings.Split(provider, ":")[0], DefaultBuiltinMetricProvider} {
+		if query, ok := queries[p]; ok {
+			return query, true
+		}
+	}
+	return "", false
+}
+
+// Has returns true if the metric is registered in the set
+func (b BuiltinMetrics) Has(name string) bool {
+	_, ok := b[name]
+	return ok
+}
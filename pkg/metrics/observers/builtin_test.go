@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package observers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuiltinMetrics(t *testing.T) {
+	set := `
+metrics:
+  - name: error-rate
+    queries:
+      istio: sum(rate(istio_requests_total{destination_workload="{{ target }}"}[{{ interval }}]))
+      gatewayapi:v1beta1: sum(rate(envoy_errors{pod=~"{{ target }}-.*"}[{{ interval }}]))
+      default: sum(rate(http_errors_total{pod=~"{{ target }}-.*"}[{{ interval }}]))
+`
+	metrics, err := ParseBuiltinMetrics(strings.NewReader(set))
+	require.NoError(t, err)
+	assert.True(t, metrics.Has("error-rate"))
+	assert.False(t, metrics.Has("latency"))
+
+	query, ok := metrics.Query("error-rate", "istio:service")
+	require.True(t, ok)
+	assert.Contains(t, query, "istio_requests_total")
+
+	query, ok = metrics.Query("error-rate", "gatewayapi:v1beta1")
+	require.True(t, ok)
+	assert.Contains(t, query, "envoy_errors")
+
+	query, ok = metrics.Query("error-rate", "linkerd")
+	require.True(t, ok)
+	assert.Contains(t, query, "http_errors_total")
+
+	_, ok = metrics.Query("latency", "linkerd")
+	assert.False(t, ok)
+}
+
+func TestParseBuiltinMetrics_Errors(t *testing.T) {
+	for name, set := range map[string]string{
+		"builtin":   "metrics: [{name: request-duration, queries: {default: up}}]",
+		"duplicate": "metrics: [{name: errors, queries: {default: up}}, {name: errors, queries: {istio: up}}]",
+		"no name":   "metrics: [{queries: {default: up}}]",
+		"no query":  "metrics: [{name: errors}]",
+		"template":  "metrics: [{name: errors, queries: {default: '{{ unknown }}'}}]",
+	} {
+		_, err := ParseBuiltinMetrics(strings.NewReader(set))
+		assert.Error(t, err, name)
+	}
+}
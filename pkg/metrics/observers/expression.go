@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package observers
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+)
+
+// EvaluateExpression computes an arithmetic or boolean expression over the named query results,
+// comparison and boolean operators return 1 for true and 0 for false
+func EvaluateExpression(expression string, values map[string]float64) (float64, error) {
+	expr, err := parser.ParseExpr(expression)
+	if err != nil {
+		return 0, fmt.Errorf("expression parsing failed: %w", err)
+	}
+
+	val, err := evaluate(expr, values)
+	if err != nil {
+		return 0, fmt.Errorf("expression evaluation failed: %w", err)
+	}
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return 0, fmt.Errorf("expression evaluation failed: result is %v", val)
+	}
+	return val, nil
+}
+
+func evaluate(expr ast.Expr, values map[string]float64) (float64, error) {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return evaluate(e.X, values)
+	case *ast.BasicLit:
+		if e.Kind != token.INT && e.Kind != token.FLOAT {
+			return 0, fmt.Errorf("unsupported literal %s", e.Value)
+		}
+		return strconv.ParseFloat(e.Value, 64)
+	case *ast.Ident:
+		switch e.Name {
+		case "true":
+			return 1, nil
+		case "false":
+			return 0, nil
+		}
+		val, ok := values[e.Name]
+		if !ok {
+			return 0, fmt.Errorf("query %s not found", e.Name)
+		}
+		return val, nil
+	case *ast.UnaryExpr:
+		x, err := evaluate(e.X, values)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.SUB:
+			return -x, nil
+		case token.ADD:
+			return x, nil
+		case token.NOT:
+			return toFloat(x == 0), nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", e.Op)
+	case *ast.BinaryExpr:
+		x, err := evaluate(e.X, values)
+		if err != nil {
+			return 0, err
+		}
+		y, err := evaluate(e.Y, values)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.ADD:
+			return x + y, nil
+		case token.SUB:
+			return x - y, nil
+		case token.MUL:
+			return x * y, nil
+		case token.QUO:
+			if y == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return x / y, nil
+		case token.LSS:
+			return toFloat(x < y), nil
+		case token.LEQ:
+			return toFloat(x <= y), nil
+		case token.GTR:
+			return toFloat(x > y), nil
+		case token.GEQ:
+			return toFloat(x >= y), nil
+		case token.EQL:
+			return toFloat(x == y), nil
+		case token.NEQ:
+			return toFloat(x != y), nil
+		case token.LAND:
+			return toFloat(x != 0 && y != 0), nil
+		case token.LOR:
+			return toFloat(x != 0 || y != 0), nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", e.Op)
+	}
+	return 0, fmt.Errorf("unsupported expression %T", expr)
+}
+
+func toFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package observers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	values := map[string]float64{
+		"canary_errors":  4,
+		"primary_errors": 2,
+		"canary_rps":     10,
+	}
+
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{expression: "canary_errors / primary_errors", expected: 2},
+		{expression: "(canary_errors - primary_errors) * 100 / canary_rps", expected: 20},
+		{expression: "-canary_errors + 1.5", expected: -2.5},
+		{expression: "canary_errors > primary_errors", expected: 1},
+		{expression: "canary_errors <= primary_errors", expected: 0},
+		{expression: "canary_rps >= 10 && canary_errors < 5", expected: 1},
+		{expression: "canary_rps == 0 || !(canary_errors != 4)", expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			val, err := EvaluateExpression(tt.expression, values)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, val)
+		})
+	}
+}
+
+func TestEvaluateExpression_Errors(t *testing.T) {
+	values := map[string]float64{"canary_errors": 1, "primary_errors": 0}
+
+	for _, expression := range []string{
+		"canary_errors / primary_errors",
+		"canary_errors / unknown",
+		"canary_errors +",
+		`canary_errors + "1"`,
+		"canary_errors % 2",
+		"max(canary_errors, 1)",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := EvaluateExpression(expression, values)
+			require.Error(t, err)
+		})
+	}
+}
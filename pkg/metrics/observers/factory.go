@@ -94,3 +94,12 @@ func (factory Factory) Observer(provider string) Interface {
 		}
 	}
 }
+
+// IstioTelemetryObserver returns an Istio observer that selects the canary metrics
+// by the dimension added with the Istio Telemetry API
+func (factory Factory) IstioTelemetryObserver(tag string) Interface {
+	return &IstioObserver{
+		client:       factory.Client,
+		telemetryTag: tag,
+	}
+}
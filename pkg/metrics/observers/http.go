@@ -47,7 +47,7 @@ var httpQueries = map[string]string{
 	* 100`,
 	"request-duration": `
 	histogram_quantile(
-		0.99,
+		{{ percentile }},
 		sum(
 			rate(
 				http_request_duration_seconds_bucket{
@@ -57,6 +57,38 @@ var httpQueries = map[string]string{
 			)
 		) by (le)
 	)`,
+	"grpc-success-rate": `
+	sum(
+		rate(
+			grpc_server_handled_total{
+				kubernetes_namespace="{{ namespace }}",
+				kubernetes_pod_name=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)",
+				grpc_code!~"Unknown|DeadlineExceeded|ResourceExhausted|Unimplemented|Internal|Unavailable|DataLoss"
+			}[{{ interval }}]
+		)
+	) 
+	/ 
+	sum(
+		rate(
+			grpc_server_handled_total{
+				kubernetes_namespace="{{ namespace }}",
+				kubernetes_pod_name=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"
+			}[{{ interval }}]
+		)
+	) 
+	* 100`,
+	"grpc-duration": `
+	histogram_quantile(
+		{{ percentile }},
+		sum(
+			rate(
+				grpc_server_handling_seconds_bucket{
+					kubernetes_namespace="{{ namespace }}",
+					kubernetes_pod_name=~"{{ target }}-[0-9a-zA-Z]+(-[0-9a-zA-Z]+)"
+				}[{{ interval }}]
+			)
+		) by (le)
+	)`,
 }
 
 type HttpObserver struct {
@@ -91,3 +123,32 @@ func (ob *HttpObserver) GetRequestDuration(model flaggerv1.MetricTemplateModel)
 	ms := time.Duration(int64(value*1000)) * time.Millisecond
 	return ms, nil
 }
+
+func (ob *HttpObserver) GetGrpcSuccessRate(model flaggerv1.MetricTemplateModel) (float64, error) {
+	query, err := RenderQuery(httpQueries["grpc-success-rate"], model)
+	if err != nil {
+		return 0, fmt.Errorf("rendering query failed: %w", err)
+	}
+
+	value, err := ob.client.RunQuery(query)
+	if err != nil {
+		return 0, fmt.Errorf("running query failed: %w", err)
+	}
+
+	return value, nil
+}
+
+func (ob *HttpObserver) GetGrpcDuration(model flaggerv1.MetricTemplateModel) (time.Duration, error) {
+	query, err := RenderQuery(httpQueries["grpc-duration"], model)
+	if err != nil {
+		return 0, fmt.Errorf("rendering query failed: %w", err)
+	}
+
+	value, err := ob.client.RunQuery(query)
+	if err != nil {
+		return 0, fmt.Errorf("running query failed: %w", err)
+	}
+
+	ms := time.Duration(int64(value*1000)) * time.Millisecond
+	return ms, nil
+}
@@ -49,7 +49,7 @@ var istioQueries = map[string]string{
 	* 100`,
 	"request-duration": `
 	histogram_quantile(
-		0.99,
+		{{ percentile }},
 		sum(
 			rate(
 				istio_request_duration_milliseconds_bucket{
@@ -60,14 +60,140 @@ var istioQueries = map[string]string{
 			)
 		) by (le)
 	)`,
+	"grpc-success-rate": `
+	sum(
+		rate(
+			istio_requests_total{
+				reporter="destination",
+				destination_workload_namespace="{{ namespace }}",
+				destination_workload=~"{{ target }}",
+				request_protocol="grpc",
+				grpc_response_status!~"2|4|8|12|13|14|15"
+			}[{{ interval }}]
+		)
+	) 
+	/ 
+	sum(
+		rate(
+			istio_requests_total{
+				reporter="destination",
+				destination_workload_namespace="{{ namespace }}",
+				destination_workload=~"{{ target }}",
+				request_protocol="grpc"
+			}[{{ interval }}]
+		)
+	) 
+	* 100`,
+	"grpc-duration": `
+	histogram_quantile(
+		{{ percentile }},
+		sum(
+			rate(
+				istio_request_duration_milliseconds_bucket{
+					reporter="destination",
+					destination_workload_namespace="{{ namespace }}",
+					destination_workload=~"{{ target }}",
+					request_protocol="grpc"
+				}[{{ interval }}]
+			)
+		) by (le)
+	)`,
+}
+
+// istioTelemetryQueries select the canary workload by the dimension
+// added to the Istio standard metrics with the Telemetry API,
+// the dimension value is the canary workload name set by the router
+var istioTelemetryQueries = map[string]string{
+	"request-success-rate": `
+	sum(
+		rate(
+			istio_requests_total{
+				reporter="destination",
+				destination_workload_namespace="{{ namespace }}",
+				%[1]s="{{ target }}-canary",
+				response_code!~"5.*"
+			}[{{ interval }}]
+		)
+	) 
+	/ 
+	sum(
+		rate(
+			istio_requests_total{
+				reporter="destination",
+				destination_workload_namespace="{{ namespace }}",
+				%[1]s="{{ target }}-canary"
+			}[{{ interval }}]
+		)
+	) 
+	* 100`,
+	"request-duration": `
+	histogram_quantile(
+		{{ percentile }},
+		sum(
+			rate(
+				istio_request_duration_milliseconds_bucket{
+					reporter="destination",
+					destination_workload_namespace="{{ namespace }}",
+					%[1]s="{{ target }}-canary"
+				}[{{ interval }}]
+			)
+		) by (le)
+	)`,
+	"grpc-success-rate": `
+	sum(
+		rate(
+			istio_requests_total{
+				reporter="destination",
+				destination_workload_namespace="{{ namespace }}",
+				%[1]s="{{ target }}-canary",
+				request_protocol="grpc",
+				grpc_response_status!~"2|4|8|12|13|14|15"
+			}[{{ interval }}]
+		)
+	) 
+	/ 
+	sum(
+		rate(
+			istio_requests_total{
+				reporter="destination",
+				destination_workload_namespace="{{ namespace }}",
+				%[1]s="{{ target }}-canary",
+				request_protocol="grpc"
+			}[{{ interval }}]
+		)
+	) 
+	* 100`,
+	"grpc-duration": `
+	histogram_quantile(
+		{{ percentile }},
+		sum(
+			rate(
+				istio_request_duration_milliseconds_bucket{
+					reporter="destination",
+					destination_workload_namespace="{{ namespace }}",
+					%[1]s="{{ target }}-canary",
+					request_protocol="grpc"
+				}[{{ interval }}]
+			)
+		) by (le)
+	)`,
 }
 
 type IstioObserver struct {
 	client providers.Interface
+	// telemetryTag is the metrics dimension set by the Istio Telemetry generated for the canary
+	telemetryTag string
+}
+
+func (ob *IstioObserver) getQuery(name string) string {
+	if ob.telemetryTag != "" {
+		return fmt.Sprintf(istioTelemetryQueries[name], ob.telemetryTag)
+	}
+	return istioQueries[name]
 }
 
 func (ob *IstioObserver) GetRequestSuccessRate(model flaggerv1.MetricTemplateModel) (float64, error) {
-	query, err := RenderQuery(istioQueries["request-success-rate"], model)
+	query, err := RenderQuery(ob.getQuery("request-success-rate"), model)
 	if err != nil {
 		return 0, fmt.Errorf("rendering query failed: %w", err)
 	}
@@ -81,7 +207,36 @@ func (ob *IstioObserver) GetRequestSuccessRate(model flaggerv1.MetricTemplateMod
 }
 
 func (ob *IstioObserver) GetRequestDuration(model flaggerv1.MetricTemplateModel) (time.Duration, error) {
-	query, err := RenderQuery(istioQueries["request-duration"], model)
+	query, err := RenderQuery(ob.getQuery("request-duration"), model)
+	if err != nil {
+		return 0, fmt.Errorf("rendering query failed: %w", err)
+	}
+
+	value, err := ob.client.RunQuery(query)
+	if err != nil {
+		return 0, fmt.Errorf("running query failed: %w", err)
+	}
+
+	ms := time.Duration(int64(value)) * time.Millisecond
+	return ms, nil
+}
+
+func (ob *IstioObserver) GetGrpcSuccessRate(model flaggerv1.MetricTemplateModel) (float64, error) {
+	query, err := RenderQuery(ob.getQuery("grpc-success-rate"), model)
+	if err != nil {
+		return 0, fmt.Errorf("rendering query failed: %w", err)
+	}
+
+	value, err := ob.client.RunQuery(query)
+	if err != nil {
+		return 0, fmt.Errorf("running query failed: %w", err)
+	}
+
+	return value, nil
+}
+
+func (ob *IstioObserver) GetGrpcDuration(model flaggerv1.MetricTemplateModel) (time.Duration, error) {
+	query, err := RenderQuery(ob.getQuery("grpc-duration"), model)
 	if err != nil {
 		return 0, fmt.Errorf("rendering query failed: %w", err)
 	}
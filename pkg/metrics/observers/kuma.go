@@ -48,7 +48,7 @@ var kumaQueries = map[string]string{
 	* 100`,
 	"request-duration": `
 	histogram_quantile(
-		0.99,
+		{{ percentile }},
 		sum(
 			rate(
 				envoy_cluster_upstream_rq_time_bucket{
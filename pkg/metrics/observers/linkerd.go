@@ -49,7 +49,7 @@ var linkerdQueries = map[string]string{
 	* 100`,
 	"request-duration": `
 	histogram_quantile(
-		0.99,
+		{{ percentile }},
 		sum(
 			rate(
 				response_latency_ms_bucket{
@@ -60,6 +60,30 @@ var linkerdQueries = map[string]string{
 			)
 		) by (le)
 	)`,
+	"grpc-success-rate": `
+	sum(
+		rate(
+			response_total{
+				namespace="{{ namespace }}",
+				deployment=~"{{ target }}",
+				grpc_status!="",
+				grpc_status!~"2|4|8|12|13|14|15",
+				direction="inbound"
+			}[{{ interval }}]
+		)
+	) 
+	/ 
+	sum(
+		rate(
+			response_total{
+				namespace="{{ namespace }}",
+				deployment=~"{{ target }}",
+				grpc_status!="",
+				direction="inbound"
+			}[{{ interval }}]
+		)
+	) 
+	* 100`,
 }
 
 type LinkerdObserver struct {
@@ -94,3 +118,34 @@ func (ob *LinkerdObserver) GetRequestDuration(model flaggerv1.MetricTemplateMode
 	ms := time.Duration(int64(value)) * time.Millisecond
 	return ms, nil
 }
+
+func (ob *LinkerdObserver) GetGrpcSuccessRate(model flaggerv1.MetricTemplateModel) (float64, error) {
+	query, err := RenderQuery(linkerdQueries["grpc-success-rate"], model)
+	if err != nil {
+		return 0, fmt.Errorf("rendering query failed: %w", err)
+	}
+
+	value, err := ob.client.RunQuery(query)
+	if err != nil {
+		return 0, fmt.Errorf("running query failed: %w", err)
+	}
+
+	return value, nil
+}
+
+// GetGrpcDuration returns the inbound requests latency, the Linkerd proxy
+// doesn't label the latency histogram with the gRPC status
+func (ob *LinkerdObserver) GetGrpcDuration(model flaggerv1.MetricTemplateModel) (time.Duration, error) {
+	query, err := RenderQuery(linkerdQueries["request-duration"], model)
+	if err != nil {
+		return 0, fmt.Errorf("rendering query failed: %w", err)
+	}
+
+	value, err := ob.client.RunQuery(query)
+	if err != nil {
+		return 0, fmt.Errorf("running query failed: %w", err)
+	}
+
+	ms := time.Duration(int64(value)) * time.Millisecond
+	return ms, nil
+}
@@ -26,3 +26,12 @@ type Interface interface {
 	GetRequestSuccessRate(model flaggerv1.MetricTemplateModel) (float64, error)
 	GetRequestDuration(model flaggerv1.MetricTemplateModel) (time.Duration, error)
 }
+
+// GrpcInterface is implemented by the observers that can tell
+// the gRPC requests apart, a gRPC request fails when its status
+// is Unknown, DeadlineExceeded, ResourceExhausted, Unimplemented,
+// Internal, Unavailable or DataLoss
+type GrpcInterface interface {
+	GetGrpcSuccessRate(model flaggerv1.MetricTemplateModel) (float64, error)
+	GetGrpcDuration(model flaggerv1.MetricTemplateModel) (time.Duration, error)
+}
@@ -49,7 +49,7 @@ var osmQueries = map[string]string{
 	* 100`,
 	"request-duration": `
 	histogram_quantile(
-		0.99,
+		{{ percentile }},
 		sum(
 		  rate(
 			osm_request_duration_ms_bucket{
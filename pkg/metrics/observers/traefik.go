@@ -46,7 +46,7 @@ var traefikQueries = map[string]string{
 	) * 100`,
 	"request-duration": `
 	histogram_quantile(
-		0.99,
+		{{ percentile }},
 		sum(
 			rate(
 				traefik_service_request_duration_seconds_bucket{
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+)
+
+// Provider wraps the metrics provider to record the latency of the queries of the canary metric
+func (cr *Recorder) Provider(cd *flaggerv1.Canary, metricName string, p providers.Interface) providers.Interface {
+	tp := provider{Interface: p, recorder: cr, canary: cd, metricName: metricName}
+	if rp, ok := p.(providers.RangeInterface); ok {
+		return &rangeProvider{provider: tp, rangeInterface: rp}
+	}
+	return &tp
+}
+
+type provider struct {
+	providers.Interface
+	recorder   *Recorder
+	canary     *flaggerv1.Canary
+	metricName string
+}
+
+func (p *provider) RunQuery(query string) (float64, error) {
+	begin := time.Now()
+	val, err := p.Interface.RunQuery(query)
+	p.recorder.SetQueryDuration(p.canary, p.metricName, time.Since(begin), err)
+	return val, err
+}
+
+type rangeProvider struct {
+	provider
+	rangeInterface providers.RangeInterface
+}
+
+func (p *rangeProvider) RunRangeQuery(query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	begin := time.Now()
+	samples, err := p.rangeInterface.RunRangeQuery(query, start, end, step)
+	p.recorder.SetQueryDuration(p.canary, p.metricName, time.Since(begin), err)
+	return samples, err
+}
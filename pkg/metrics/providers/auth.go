@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+const (
+	bearerTokenSecretKey  = "token"
+	clientIDSecretKey     = "clientID"
+	clientSecretSecretKey = "clientSecret"
+	tlsCertSecretKey      = "tls.crt"
+	tlsKeySecretKey       = "tls.key"
+	caCertSecretKey       = "ca.crt"
+)
+
+// oauth2TokenSources holds the token sources shared by the providers created
+// for each analysis run, so that the access tokens are reused until they expire
+var oauth2TokenSources sync.Map
+
+// newHTTPClient returns the HTTP client of the provider, the client presents the TLS client
+// certificate and trusts the CA bundle found in the credentials, skips the TLS verification
+// if requested and authenticates the requests with an OAuth2 access token obtained with the
+// client credentials flow or with the bearer token found in the credentials.
+// The returned bool is true if the requests are authenticated with a token.
+func newHTTPClient(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (*http.Client, bool, error) {
+	client := http.DefaultClient
+	tlsConfig, err := newTLSConfig(provider, credentials)
+	if err != nil {
+		return nil, false, err
+	}
+	if tlsConfig != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsConfig
+		client = &http.Client{Transport: t}
+	}
+
+	if provider.OAuth2 != nil {
+		ts, err := newOAuth2TokenSource(provider, credentials, client)
+		if err != nil {
+			return nil, false, err
+		}
+		return &http.Client{Transport: &oauth2.Transport{Source: ts, Base: client.Transport}}, true, nil
+	}
+
+	if token, ok := credentials[bearerTokenSecretKey]; ok && provider.SecretRef != nil {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: strings.TrimSpace(string(token))})
+		return &http.Client{Transport: &oauth2.Transport{Source: ts, Base: client.Transport}}, true, nil
+	}
+
+	return client, false, nil
+}
+
+// newTLSConfig returns the TLS config of the provider or nil if the default config can be used,
+// the client certificate and key are read from the tls.crt and tls.key credentials
+// and the CA bundle used to verify the server from ca.crt
+func newTLSConfig(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: provider.InsecureSkipVerify}
+	custom := provider.InsecureSkipVerify
+	if provider.SecretRef == nil {
+		credentials = nil
+	}
+
+	cert, hasCert := credentials[tlsCertSecretKey]
+	key, hasKey := credentials[tlsKeySecretKey]
+	if hasCert != hasKey {
+		return nil, fmt.Errorf("%s credentials must contain both %s and %s", provider.Type, tlsCertSecretKey, tlsKeySecretKey)
+	}
+	if hasCert {
+		certificate, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s client certificate is invalid: %w", provider.Type, err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+		custom = true
+	}
+
+	if ca, ok := credentials[caCertSecretKey]; ok {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%s %s does not contain any valid PEM certificate", provider.Type, caCertSecretKey)
+		}
+		config.RootCAs = pool
+		custom = true
+	}
+
+	if !custom {
+		return nil, nil
+	}
+	return config, nil
+}
+
+// hasClientCertificate returns true if the requests are authenticated with
+// the TLS client certificate found in the credentials
+func hasClientCertificate(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) bool {
+	_, ok := credentials[tlsCertSecretKey]
+	return ok && provider.SecretRef != nil
+}
+
+// newOAuth2TokenSource returns the cached token source of the client credentials,
+// the token source refreshes the access token when it expires
+func newOAuth2TokenSource(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte,
+	client *http.Client) (oauth2.TokenSource, error) {
+	if provider.OAuth2.TokenURL == "" {
+		return nil, fmt.Errorf("%s oauth2 token URL cannot be empty", provider.Type)
+	}
+	if provider.SecretRef == nil {
+		return nil, fmt.Errorf("%s oauth2 requires a secretRef", provider.Type)
+	}
+	clientID, ok := credentials[clientIDSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("%s credentials does not contain %s", provider.Type, clientIDSecretKey)
+	}
+	clientSecret, ok := credentials[clientSecretSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("%s credentials does not contain %s", provider.Type, clientSecretSecretKey)
+	}
+
+	config := clientcredentials.Config{
+		ClientID:     strings.TrimSpace(string(clientID)),
+		ClientSecret: strings.TrimSpace(string(clientSecret)),
+		TokenURL:     provider.OAuth2.TokenURL,
+		Scopes:       provider.OAuth2.Scopes,
+	}
+
+	// the token endpoint is queried with the provider TLS settings
+	tlsHash := sha256.New()
+	for _, k := range []string{tlsCertSecretKey, tlsKeySecretKey, caCertSecretKey} {
+		tlsHash.Write(credentials[k])
+	}
+	key := fmt.Sprintf("%s/%s/%x/%s/%v/%x", config.TokenURL, config.ClientID,
+		sha256.Sum256([]byte(config.ClientSecret)), strings.Join(config.Scopes, " "), provider.InsecureSkipVerify, tlsHash.Sum(nil))
+	if ts, ok := oauth2TokenSources.Load(key); ok {
+		return ts.(oauth2.TokenSource), nil
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	ts, _ := oauth2TokenSources.LoadOrStore(key, config.TokenSource(ctx))
+	return ts.(oauth2.TokenSource), nil
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestNewHTTPClient_BearerToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"1"]}]}}`))
+	}))
+	defer ts.Close()
+
+	prom, err := NewPrometheusProvider(flaggerv1.MetricTemplateProvider{
+		Type:      "prometheus",
+		Address:   ts.URL,
+		SecretRef: &corev1.LocalObjectReference{Name: "prometheus"},
+	}, map[string][]byte{
+		"token": []byte("secret\n"),
+	})
+	require.NoError(t, err)
+
+	val, err := prom.RunQuery("vector(1)")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), val)
+}
+
+func TestNewHTTPClient_OAuth2(t *testing.T) {
+	tokenRequests := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		assert.Equal(t, "metrics:read", r.Form.Get("scope"))
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "flagger", user)
+		assert.Equal(t, "client-secret", pass)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer access-token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"1.5"]}]}}`))
+	}))
+	defer ts.Close()
+
+	provider := flaggerv1.MetricTemplateProvider{
+		Type:      "prometheus",
+		Address:   ts.URL,
+		SecretRef: &corev1.LocalObjectReference{Name: "prometheus"},
+		OAuth2: &flaggerv1.OAuth2Options{
+			TokenURL: tokenServer.URL,
+			Scopes:   []string{"metrics:read"},
+		},
+	}
+	credentials := map[string][]byte{
+		"clientID":     []byte("flagger"),
+		"clientSecret": []byte("client-secret"),
+	}
+
+	// the access token is reused by the providers created for each analysis run
+	for i := 0; i < 2; i++ {
+		prom, err := NewPrometheusProvider(provider, credentials)
+		require.NoError(t, err)
+
+		val, err := prom.RunQuery("vector(1.5)")
+		require.NoError(t, err)
+		assert.Equal(t, 1.5, val)
+	}
+	assert.Equal(t, 1, tokenRequests)
+}
+
+func TestNewHTTPClient_OAuth2Errors(t *testing.T) {
+	provider := flaggerv1.MetricTemplateProvider{
+		Type:    "prometheus",
+		Address: "http://prometheus:9090",
+		OAuth2:  &flaggerv1.OAuth2Options{TokenURL: "http://auth/token"},
+	}
+
+	_, _, err := newHTTPClient(provider, nil)
+	assert.Error(t, err)
+
+	provider.SecretRef = &corev1.LocalObjectReference{Name: "prometheus"}
+	_, _, err = newHTTPClient(provider, map[string][]byte{"clientSecret": []byte("secret")})
+	assert.Error(t, err)
+
+	_, _, err = newHTTPClient(provider, map[string][]byte{"clientID": []byte("flagger")})
+	assert.Error(t, err)
+
+	provider.OAuth2.TokenURL = ""
+	_, _, err = newHTTPClient(provider, map[string][]byte{"clientID": []byte("flagger"), "clientSecret": []byte("secret")})
+	assert.Error(t, err)
+}
+
+func TestNewHTTPClient_MutualTLS(t *testing.T) {
+	certPEM, keyPEM := newTestClientCertificate(t)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Len(t, r.TLS.PeerCertificates, 1)
+		assert.Equal(t, "flagger", r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"1"]}]}}`))
+	}))
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(certPEM))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	provider := flaggerv1.MetricTemplateProvider{
+		Type:      "prometheus",
+		Address:   ts.URL,
+		SecretRef: &corev1.LocalObjectReference{Name: "prometheus"},
+	}
+
+	prom, err := NewPrometheusProvider(provider, map[string][]byte{
+		"tls.crt": certPEM,
+		"tls.key": keyPEM,
+		"ca.crt":  caPEM,
+	})
+	require.NoError(t, err)
+
+	val, err := prom.RunQuery("vector(1)")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), val)
+
+	// the server rejects the requests without a client certificate
+	provider.SecretRef = nil
+	provider.InsecureSkipVerify = true
+	prom, err = NewPrometheusProvider(provider, nil)
+	require.NoError(t, err)
+
+	_, err = prom.RunQuery("vector(1)")
+	assert.Error(t, err)
+}
+
+func TestNewHTTPClient_MutualTLSErrors(t *testing.T) {
+	certPEM, keyPEM := newTestClientCertificate(t)
+	provider := flaggerv1.MetricTemplateProvider{
+		Type:      "prometheus",
+		Address:   "https://prometheus:9090",
+		SecretRef: &corev1.LocalObjectReference{Name: "prometheus"},
+	}
+
+	_, _, err := newHTTPClient(provider, map[string][]byte{"tls.crt": certPEM})
+	assert.Error(t, err)
+
+	_, _, err = newHTTPClient(provider, map[string][]byte{"tls.crt": keyPEM, "tls.key": certPEM})
+	assert.Error(t, err)
+
+	_, _, err = newHTTPClient(provider, map[string][]byte{"ca.crt": []byte("not a certificate")})
+	assert.Error(t, err)
+
+	client, _, err := newHTTPClient(provider, map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM})
+	require.NoError(t, err)
+	assert.NotEqual(t, http.DefaultClient, client)
+}
+
+func newTestClientCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "flagger"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
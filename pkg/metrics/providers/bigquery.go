@@ -0,0 +1,190 @@
+//go:build !no_bigquery
+// +build !no_bigquery
+
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func init() {
+	registerProvider("bigquery", func(_ string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewBigQueryProvider(provider, credentials)
+	})
+}
+
+const (
+	bigQueryOnlineQuery = "SELECT 1"
+
+	bigQueryProjectSecretKey  = "project"
+	bigQueryLocationSecretKey = "location"
+)
+
+// BigQueryProvider executes standard SQL queries against the BigQuery API
+type BigQueryProvider struct {
+	service    *bigquery.Service
+	project    string
+	location   string
+	parameters []*bigquery.QueryParameter
+	timeout    time.Duration
+}
+
+// NewBigQueryProvider takes a provider spec and the credentials map and returns a BigQuery client.
+// The credentials must contain the project ID and may contain the service account key and the dataset location,
+// without a service account key the Google application default credentials are used (e.g. Workload Identity).
+// The provider query params are passed to the SQL queries as named string parameters.
+func NewBigQueryProvider(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (*BigQueryProvider, error) {
+	bq := BigQueryProvider{
+		timeout: 30 * time.Second,
+	}
+
+	var opts []option.ClientOption
+	if provider.Address != "" {
+		opts = append(opts, option.WithEndpoint(provider.Address))
+	}
+
+	if provider.SecretRef == nil {
+		return nil, fmt.Errorf("%s requires a secretRef with the project id", provider.Type)
+	}
+
+	if project, ok := credentials[bigQueryProjectSecretKey]; ok {
+		bq.project = string(project)
+	} else {
+		return nil, fmt.Errorf("%s credentials does not contain a project id", provider.Type)
+	}
+
+	if location, ok := credentials[bigQueryLocationSecretKey]; ok {
+		bq.location = string(location)
+	}
+
+	if saKey, ok := credentials["serviceAccountKey"]; ok {
+		opts = append(opts, option.WithCredentialsJSON(saKey))
+	}
+
+	params, err := providerQueryParams(provider, credentials)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		bq.parameters = append(bq.parameters, &bigquery.QueryParameter{
+			Name:           name,
+			ParameterType:  &bigquery.QueryParameterType{Type: "STRING"},
+			ParameterValue: &bigquery.QueryParameterValue{Value: params.Get(name)},
+		})
+	}
+
+	service, err := bigquery.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bigquery client: %w", err)
+	}
+	bq.service = service
+
+	return &bq, nil
+}
+
+// RunQuery executes the SQL query and returns the single column of the first row as float64
+func (b *BigQueryProvider) RunQuery(query string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+
+	useLegacySQL := false
+	req := &bigquery.QueryRequest{
+		Query:           query,
+		UseLegacySql:    &useLegacySQL,
+		Location:        b.location,
+		QueryParameters: b.parameters,
+		TimeoutMs:       b.timeout.Milliseconds(),
+	}
+	if len(b.parameters) > 0 {
+		req.ParameterMode = "NAMED"
+	}
+
+	resp, err := b.service.Jobs.Query(b.project, req).Context(ctx).Do()
+	if err != nil {
+		return 0, fmt.Errorf("error requesting bigquery: %w", err)
+	}
+
+	rows := resp.Rows
+	complete := resp.JobComplete
+	for !complete {
+		if resp.JobReference == nil {
+			return 0, fmt.Errorf("invalid response: query job reference is missing")
+		}
+		results, err := b.service.Jobs.GetQueryResults(b.project, resp.JobReference.JobId).
+			Location(resp.JobReference.Location).
+			TimeoutMs(b.timeout.Milliseconds()).
+			Context(ctx).Do()
+		if err != nil {
+			return 0, fmt.Errorf("error requesting bigquery query results: %w", err)
+		}
+		rows = results.Rows
+		complete = results.JobComplete
+	}
+
+	if len(rows) < 1 || len(rows[0].F) < 1 {
+		return 0, fmt.Errorf("invalid response: %w", ErrNoValuesFound)
+	}
+	if len(rows[0].F) > 1 {
+		return 0, fmt.Errorf("invalid response: the query must return a single column, got %d", len(rows[0].F))
+	}
+
+	switch v := rows[0].F[0].V.(type) {
+	case nil:
+		return 0, fmt.Errorf("invalid response: null value: %w", ErrNoValuesFound)
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid response: %s is not a number", v)
+		}
+		return f, nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("invalid response: unexpected value %v", v)
+	}
+}
+
+// IsOnline runs a simple query and returns an error if the API is unreachable
+// or the credentials don't allow running query jobs in the project
+func (b *BigQueryProvider) IsOnline() (bool, error) {
+	value, err := b.RunQuery(bigQueryOnlineQuery)
+	if err != nil {
+		return false, fmt.Errorf("running query failed: %w", err)
+	}
+
+	if value != float64(1) {
+		return false, fmt.Errorf("value is not 1 for query: %s", bigQueryOnlineQuery)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,111 @@
+//go:build !no_bigquery
+// +build !no_bigquery
+
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func newBigQueryTestProvider(t *testing.T, url string) *BigQueryProvider {
+	service, err := bigquery.NewService(context.Background(), option.WithEndpoint(url+"/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+
+	return &BigQueryProvider{
+		service: service,
+		project: "flagger",
+		parameters: []*bigquery.QueryParameter{{
+			Name:           "service",
+			ParameterType:  &bigquery.QueryParameterType{Type: "STRING"},
+			ParameterValue: &bigquery.QueryParameterValue{Value: "podinfo"},
+		}},
+		timeout: 5 * time.Second,
+	}
+}
+
+func TestNewBigQueryProvider(t *testing.T) {
+	_, err := NewBigQueryProvider(flaggerv1.MetricTemplateProvider{
+		Type:      "bigquery",
+		SecretRef: &corev1.LocalObjectReference{Name: "bigquery"},
+	}, map[string][]byte{})
+	require.Error(t, err, "error expected since project is not given")
+
+	_, err = NewBigQueryProvider(flaggerv1.MetricTemplateProvider{Type: "bigquery"}, nil)
+	require.Error(t, err, "error expected since secretRef is not given")
+}
+
+func TestBigQueryProvider_RunQuery(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/projects/flagger/queries", r.URL.Path)
+
+			var req bigquery.QueryRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "SELECT error_rate FROM sli WHERE service = @service", req.Query)
+			assert.False(t, *req.UseLegacySql)
+			assert.Equal(t, "NAMED", req.ParameterMode)
+			assert.Equal(t, "podinfo", req.QueryParameters[0].ParameterValue.Value)
+
+			w.Write([]byte(`{"jobComplete":true,"rows":[{"f":[{"v":"0.25"}]}]}`))
+		}))
+		defer ts.Close()
+
+		bq := newBigQueryTestProvider(t, ts.URL)
+		val, err := bq.RunQuery("SELECT error_rate FROM sli WHERE service = @service")
+		require.NoError(t, err)
+		assert.Equal(t, 0.25, val)
+	})
+
+	t.Run("no values", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"jobComplete":true,"rows":[{"f":[{"v":null}]}]}`))
+		}))
+		defer ts.Close()
+
+		bq := newBigQueryTestProvider(t, ts.URL)
+		_, err := bq.RunQuery("SELECT error_rate FROM sli")
+		require.True(t, errors.Is(err, ErrNoValuesFound))
+	})
+}
+
+func TestBigQueryProvider_IsOnline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jobComplete":true,"rows":[{"f":[{"v":"1"}]}]}`))
+	}))
+	defer ts.Close()
+
+	bq := newBigQueryTestProvider(t, ts.URL)
+	ok, err := bq.IsOnline()
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// QueryCache is shared by all the canaries to cache the query results for the TTL
+// duration and to limit the rate of the requests sent to each provider.
+// A nil QueryCache returns the providers as is.
+type QueryCache struct {
+	ttl   time.Duration
+	qps   float64
+	burst int
+	now   func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]queryCacheEntry
+	limiters map[string]*rate.Limiter
+}
+
+type queryCacheEntry struct {
+	value   float64
+	online  bool
+	expires time.Time
+}
+
+// NewQueryCache returns a QueryCache, the results are not cached if the TTL is zero
+// and the requests are not rate limited if the QPS is zero,
+// the returned QueryCache is nil if both are zero
+func NewQueryCache(ttl time.Duration, qps float64) (*QueryCache, error) {
+	if ttl < 0 {
+		return nil, fmt.Errorf("query cache TTL %v must be greater than or equal to zero", ttl)
+	}
+	if qps < 0 {
+		return nil, fmt.Errorf("query QPS %v must be greater than or equal to zero", qps)
+	}
+	if ttl == 0 && qps == 0 {
+		return nil, nil
+	}
+	burst := int(math.Ceil(qps))
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &QueryCache{
+		ttl:      ttl,
+		qps:      qps,
+		burst:    burst,
+		now:      time.Now,
+		entries:  make(map[string]queryCacheEntry),
+		limiters: make(map[string]*rate.Limiter),
+	}, nil
+}
+
+// Provider wraps the provider to cache its results and limit its request rate,
+// the requests are rate limited per provider type and address and the results
+// are cached per provider configuration
+func (c *QueryCache) Provider(namespace string, spec flaggerv1.MetricTemplateProvider, p Interface) Interface {
+	if c == nil {
+		return p
+	}
+
+	// the credentials are namespaced so are the results of the providers using them
+	if spec.SecretRef == nil {
+		namespace = ""
+	}
+	b, _ := json.Marshal(spec)
+
+	cp := cachedProvider{
+		Interface: p,
+		cache:     c,
+		endpoint:  fmt.Sprintf("%s/%s/%s", spec.Type, spec.Address, spec.Region),
+		key:       fmt.Sprintf("%s/%s", namespace, string(b)),
+	}
+	if rp, ok := p.(RangeInterface); ok {
+		return &cachedRangeProvider{cachedProvider: cp, rangeInterface: rp}
+	}
+	return &cp
+}
+
+func (c *QueryCache) get(key string) (queryCacheEntry, bool) {
+	if c.ttl == 0 {
+		return queryCacheEntry{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return queryCacheEntry{}, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return queryCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *QueryCache) set(key string, entry queryCacheEntry) {
+	if c.ttl == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	entry.expires = now.Add(c.ttl)
+	c.entries[key] = entry
+}
+
+// wait blocks until the provider rate limiter allows a request
+func (c *QueryCache) wait(key string) error {
+	if c.qps == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	limiter, ok := c.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(c.qps), c.burst)
+		c.limiters[key] = limiter
+	}
+	c.mu.Unlock()
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		return fmt.Errorf("rate limiter error: %w", err)
+	}
+	return nil
+}
+
+type cachedProvider struct {
+	Interface
+	cache    *QueryCache
+	endpoint string
+	key      string
+}
+
+func (p *cachedProvider) RunQuery(query string) (float64, error) {
+	key := fmt.Sprintf("%s/query/%s", p.key, query)
+	if entry, ok := p.cache.get(key); ok {
+		return entry.value, nil
+	}
+
+	if err := p.cache.wait(p.endpoint); err != nil {
+		return 0, err
+	}
+	value, err := p.Interface.RunQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	p.cache.set(key, queryCacheEntry{value: value})
+	return value, nil
+}
+
+func (p *cachedProvider) IsOnline() (bool, error) {
+	key := fmt.Sprintf("%s/online", p.key)
+	if entry, ok := p.cache.get(key); ok {
+		return entry.online, nil
+	}
+
+	if err := p.cache.wait(p.endpoint); err != nil {
+		return false, err
+	}
+	online, err := p.Interface.IsOnline()
+	if err != nil {
+		return false, err
+	}
+	if online {
+		p.cache.set(key, queryCacheEntry{online: online})
+	}
+	return online, nil
+}
+
+type cachedRangeProvider struct {
+	cachedProvider
+	rangeInterface RangeInterface
+}
+
+// RunRangeQuery is rate limited but not cached since the time range changes on every call
+func (p *cachedRangeProvider) RunRangeQuery(query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	if err := p.cache.wait(p.endpoint); err != nil {
+		return nil, err
+	}
+	return p.rangeInterface.RunRangeQuery(query, start, end, step)
+}
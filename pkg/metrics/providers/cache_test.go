@@ -0,0 +1,155 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+type countingProvider struct {
+	queries int
+	online  int
+	err     error
+}
+
+func (p *countingProvider) RunQuery(query string) (float64, error) {
+	p.queries++
+	return float64(p.queries), p.err
+}
+
+func (p *countingProvider) IsOnline() (bool, error) {
+	p.online++
+	return p.err == nil, p.err
+}
+
+func TestNewQueryCache(t *testing.T) {
+	c, err := NewQueryCache(0, 0)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	p := &countingProvider{}
+	assert.Same(t, p, c.Provider("default", flaggerv1.MetricTemplateProvider{}, p))
+
+	_, err = NewQueryCache(-time.Second, 0)
+	assert.Error(t, err)
+
+	_, err = NewQueryCache(0, -1)
+	assert.Error(t, err)
+}
+
+func TestQueryCache_RunQuery(t *testing.T) {
+	c, err := NewQueryCache(time.Minute, 0)
+	require.NoError(t, err)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	spec := flaggerv1.MetricTemplateProvider{Type: "prometheus", Address: "http://prometheus:9090"}
+	p := &countingProvider{}
+	p1 := c.Provider("test1", spec, p)
+	p2 := c.Provider("test2", spec, p)
+
+	val, err := p1.RunQuery("up")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), val)
+
+	// the results are shared by the canaries using the same provider
+	val, err = p2.RunQuery("up")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), val)
+
+	val, err = p2.RunQuery("down")
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), val)
+
+	ok, err := p1.IsOnline()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, _ = p2.IsOnline()
+	assert.Equal(t, 1, p.online)
+
+	// the results expire after the TTL
+	now = now.Add(time.Minute)
+	val, err = p1.RunQuery("up")
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), val)
+	assert.Equal(t, 3, p.queries)
+}
+
+func TestQueryCache_Credentials(t *testing.T) {
+	c, err := NewQueryCache(time.Minute, 0)
+	require.NoError(t, err)
+
+	spec := flaggerv1.MetricTemplateProvider{
+		Type:      "datadog",
+		Address:   "https://api.datadoghq.com",
+		SecretRef: &corev1.LocalObjectReference{Name: "datadog"},
+	}
+	p := &countingProvider{}
+
+	_, err = c.Provider("test1", spec, p).RunQuery("avg:requests")
+	require.NoError(t, err)
+	_, err = c.Provider("test2", spec, p).RunQuery("avg:requests")
+	require.NoError(t, err)
+
+	// the namespaced credentials are not shared
+	assert.Equal(t, 2, p.queries)
+}
+
+func TestQueryCache_Errors(t *testing.T) {
+	c, err := NewQueryCache(time.Minute, 0)
+	require.NoError(t, err)
+
+	p := &countingProvider{err: errors.New("timeout")}
+	cp := c.Provider("default", flaggerv1.MetricTemplateProvider{Type: "prometheus"}, p)
+
+	_, err = cp.RunQuery("up")
+	assert.Error(t, err)
+	_, err = cp.RunQuery("up")
+	assert.Error(t, err)
+	assert.Equal(t, 2, p.queries)
+
+	ok, err := cp.IsOnline()
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestQueryCache_RateLimit(t *testing.T) {
+	c, err := NewQueryCache(0, 20)
+	require.NoError(t, err)
+
+	p := &countingProvider{}
+	cp := c.Provider("default", flaggerv1.MetricTemplateProvider{Type: "prometheus"}, p)
+
+	start := time.Now()
+	for i := 0; i < 25; i++ {
+		_, err := cp.RunQuery("up")
+		require.NoError(t, err)
+	}
+
+	// the first 20 queries use the burst and the next 5 are limited to 20 QPS
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, 25, p.queries)
+}
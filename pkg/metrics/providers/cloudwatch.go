@@ -1,3 +1,6 @@
+//go:build !no_cloudwatch
+// +build !no_cloudwatch
+
 /*
 Copyright 2020 The Flux authors
 
@@ -19,7 +22,9 @@ package providers
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -30,11 +35,21 @@ import (
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerProvider("cloudwatch", func(metricInterval string, provider flaggerv1.MetricTemplateProvider, _ map[string][]byte) (Interface, error) {
+		return NewCloudWatchProvider(metricInterval, provider)
+	})
+}
+
 const (
 	cloudWatchMaxRetries                           = 3
 	cloudWatchStartDeltaMultiplierOnMetricInterval = 10
 )
 
+// cloudWatchAnomalyBandRegexp matches the metric ID of an anomaly detection band
+// expression e.g. ANOMALY_DETECTION_BAND(m1, 2)
+var cloudWatchAnomalyBandRegexp = regexp.MustCompile(`ANOMALY_DETECTION_BAND\(\s*([a-z][a-zA-Z0-9_]*)`)
+
 type CloudWatchProvider struct {
 	client     cloudWatchClient
 	startDelta time.Duration
@@ -71,13 +86,25 @@ func NewCloudWatchProvider(metricInterval string, provider flaggerv1.MetricTempl
 }
 
 // RunQuery executes the aws cloud watch metrics query against GetMetricData endpoint
-// and returns the the first result as float64
+// and returns the the latest value of the first query that returns data as float64.
+// If the query contains an ANOMALY_DETECTION_BAND expression, the result is the distance
+// between the latest value of the metric and the band, zero meaning the metric is within the band
 func (p *CloudWatchProvider) RunQuery(query string) (float64, error) {
 	var cq []*cloudwatch.MetricDataQuery
 	if err := json.Unmarshal([]byte(query), &cq); err != nil {
 		return 0, fmt.Errorf("error unmarshaling query: %s", err.Error())
 	}
 
+	bandID, metricID := cloudWatchAnomalyBand(cq)
+	if bandID != "" {
+		// the metric compared to the band must be returned
+		for _, q := range cq {
+			if aws.StringValue(q.Id) == metricID {
+				q.ReturnData = aws.Bool(true)
+			}
+		}
+	}
+
 	end := time.Now()
 	start := end.Add(-p.startDelta)
 	res, err := p.client.GetMetricData(&cloudwatch.GetMetricDataInput{
@@ -85,6 +112,7 @@ func (p *CloudWatchProvider) RunQuery(query string) (float64, error) {
 		MaxDatapoints:     aws.Int64(20),
 		StartTime:         aws.Time(start),
 		MetricDataQueries: cq,
+		ScanBy:            aws.String(cloudwatch.ScanByTimestampDescending),
 	})
 
 	if err != nil {
@@ -96,6 +124,35 @@ func (p *CloudWatchProvider) RunQuery(query string) (float64, error) {
 		return 0, fmt.Errorf("invalid response: %s: %w", res.String(), ErrNoValuesFound)
 	}
 
+	if bandID != "" {
+		values := cloudWatchLatestValues(mr, metricID)
+		band := cloudWatchLatestValues(mr, bandID)
+		if len(values) < 1 || len(band) != 2 {
+			return 0, fmt.Errorf("invalid reponse %s: %w", res.String(), ErrNoValuesFound)
+		}
+
+		value, lower, upper := values[0], math.Min(band[0], band[1]), math.Max(band[0], band[1])
+		switch {
+		case value > upper:
+			return value - upper, nil
+		case value < lower:
+			return lower - value, nil
+		default:
+			return 0, nil
+		}
+	}
+
+	// with metric math the metrics used by the expression usually have ReturnData set to false,
+	// if several queries return data the result of the first one is used
+	for _, q := range cq {
+		if q.ReturnData == nil || aws.BoolValue(q.ReturnData) {
+			if values := cloudWatchLatestValues(mr, aws.StringValue(q.Id)); len(values) > 0 {
+				return values[0], nil
+			}
+			break
+		}
+	}
+
 	vs := mr[0].Values
 	if len(vs) < 1 {
 		return 0, fmt.Errorf("invalid reponse %s: %w", res.String(), ErrNoValuesFound)
@@ -104,6 +161,29 @@ func (p *CloudWatchProvider) RunQuery(query string) (float64, error) {
 	return aws.Float64Value(vs[0]), nil
 }
 
+// cloudWatchAnomalyBand returns the ID of the anomaly detection band expression
+// and the ID of the metric it is computed from
+func cloudWatchAnomalyBand(queries []*cloudwatch.MetricDataQuery) (string, string) {
+	for _, q := range queries {
+		if m := cloudWatchAnomalyBandRegexp.FindStringSubmatch(aws.StringValue(q.Expression)); m != nil {
+			return aws.StringValue(q.Id), m[1]
+		}
+	}
+	return "", ""
+}
+
+// cloudWatchLatestValues returns the latest value of each time series returned for the query ID,
+// the values are sorted by timestamp in descending order
+func cloudWatchLatestValues(results []*cloudwatch.MetricDataResult, id string) []float64 {
+	var values []float64
+	for _, r := range results {
+		if aws.StringValue(r.Id) == id && len(r.Values) > 0 {
+			values = append(values, aws.Float64Value(r.Values[0]))
+		}
+	}
+	return values
+}
+
 // IsOnline calls GetMetricData endpoint with the empty query
 // and returns an error if the returned status code is NOT http.StatusBadRequests.
 // For example, if the flagger does not have permission to perform `cloudwatch:GetMetricData`,
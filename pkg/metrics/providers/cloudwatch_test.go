@@ -1,3 +1,6 @@
+//go:build !no_cloudwatch
+// +build !no_cloudwatch
+
 /*
 Copyright 2020 The Flux authors
 
@@ -172,3 +175,72 @@ func TestCloudWatchProvider_RunQuery(t *testing.T) {
 		require.True(t, errors.Is(err, ErrNoValuesFound))
 	})
 }
+
+func TestCloudWatchProvider_RunQueryMetricMath(t *testing.T) {
+	query := `
+[
+    {"Id": "m1", "MetricStat": {"Metric": {"Namespace": "MyApplication", "MetricName": "Errors"}, "Period": 60, "Stat": "Sum"}, "ReturnData": false},
+    {"Id": "e1", "Expression": "m1 * 100", "Label": "ErrorPercent"},
+    {"Id": "e2", "Expression": "m1 * 10", "Label": "ErrorPerMille"}
+]`
+
+	p := CloudWatchProvider{client: cloudWatchClientMock{
+		o: &cloudwatch.GetMetricDataOutput{
+			MetricDataResults: []*cloudwatch.MetricDataResult{
+				{Id: aws.String("e2"), Values: []*float64{aws.Float64(20), aws.Float64(10)}},
+				{Id: aws.String("e1"), Values: []*float64{aws.Float64(200), aws.Float64(100)}},
+			},
+		},
+	}}
+
+	// the latest value of the first query that returns data is used
+	actual, err := p.RunQuery(query)
+	require.NoError(t, err)
+	assert.Equal(t, float64(200), actual)
+}
+
+func TestCloudWatchProvider_RunQueryAnomalyDetectionBand(t *testing.T) {
+	query := `
+[
+    {"Id": "m1", "MetricStat": {"Metric": {"Namespace": "MyApplication", "MetricName": "Latency"}, "Period": 60, "Stat": "Average"}, "ReturnData": false},
+    {"Id": "ad1", "Expression": "ANOMALY_DETECTION_BAND(m1, 2)"}
+]`
+
+	for name, c := range map[string]struct {
+		value    float64
+		expected float64
+	}{
+		"within":      {value: 15, expected: 0},
+		"above upper": {value: 25, expected: 5},
+		"below lower": {value: 4, expected: 6},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := CloudWatchProvider{client: cloudWatchClientMock{
+				o: &cloudwatch.GetMetricDataOutput{
+					MetricDataResults: []*cloudwatch.MetricDataResult{
+						{Id: aws.String("ad1"), Values: []*float64{aws.Float64(20)}},
+						{Id: aws.String("ad1"), Values: []*float64{aws.Float64(10)}},
+						{Id: aws.String("m1"), Values: []*float64{aws.Float64(c.value)}},
+					},
+				},
+			}}
+
+			actual, err := p.RunQuery(query)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+
+	t.Run("no band", func(t *testing.T) {
+		p := CloudWatchProvider{client: cloudWatchClientMock{
+			o: &cloudwatch.GetMetricDataOutput{
+				MetricDataResults: []*cloudwatch.MetricDataResult{
+					{Id: aws.String("m1"), Values: []*float64{aws.Float64(1)}},
+				},
+			},
+		}}
+
+		_, err := p.RunQuery(query)
+		require.True(t, errors.Is(err, ErrNoValuesFound))
+	})
+}
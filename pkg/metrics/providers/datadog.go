@@ -1,3 +1,6 @@
+//go:build !no_datadog
+// +build !no_datadog
+
 /*
 Copyright 2020 The Flux authors
 
@@ -22,18 +25,34 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerProvider("datadog", func(metricInterval string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewDatadogProvider(metricInterval, provider, credentials)
+	})
+}
+
 // https://docs.datadoghq.com/api/
 const (
 	datadogDefaultHost = "https://api.datadoghq.com"
 
 	datadogMetricsQueryPath     = "/api/v1/query"
 	datadogAPIKeyValidationPath = "/api/v1/validate"
+	datadogSLOPath              = "/api/v1/slo"
+	datadogMonitorPath          = "/api/v1/monitor"
+
+	// datadogSLOQueryPrefix selects the remaining error budget of an SLO e.g. slo:<id> or slo:<id>:30d
+	datadogSLOQueryPrefix = "slo:"
+	// datadogMonitorQueryPrefix selects the state of a monitor e.g. monitor:<id>
+	datadogMonitorQueryPrefix = "monitor:"
 
 	datadogAPIKeySecretKey = "datadog_api_key"
 	datadogAPIKeyHeaderKey = "DD-API-KEY"
@@ -48,6 +67,8 @@ const (
 type DatadogProvider struct {
 	metricsQueryEndpoint     string
 	apiKeyValidationEndpoint string
+	sloEndpoint              string
+	monitorEndpoint          string
 
 	timeout        time.Duration
 	apiKey         string
@@ -61,6 +82,28 @@ type datadogResponse struct {
 	}
 }
 
+type datadogSLOHistoryResponse struct {
+	Data struct {
+		Overall struct {
+			SLIValue *float64 `json:"sli_value"`
+		} `json:"overall"`
+		Thresholds map[string]struct {
+			Target float64 `json:"target"`
+		} `json:"thresholds"`
+	} `json:"data"`
+}
+
+type datadogMonitorResponse struct {
+	OverallState string `json:"overall_state"`
+}
+
+// datadogMonitorStates maps the monitor states to the values returned by the monitor queries
+var datadogMonitorStates = map[string]float64{
+	"OK":    0,
+	"Warn":  1,
+	"Alert": 2,
+}
+
 // NewDatadogProvider takes a canary spec, a provider spec and the credentials map, and
 // returns a Datadog client ready to execute queries against the API
 func NewDatadogProvider(metricInterval string,
@@ -76,6 +119,8 @@ func NewDatadogProvider(metricInterval string,
 		timeout:                  5 * time.Second,
 		metricsQueryEndpoint:     address + datadogMetricsQueryPath,
 		apiKeyValidationEndpoint: address + datadogAPIKeyValidationPath,
+		sloEndpoint:              address + datadogSLOPath,
+		monitorEndpoint:          address + datadogMonitorPath,
 	}
 
 	if b, ok := credentials[datadogAPIKeySecretKey]; ok {
@@ -100,38 +145,26 @@ func NewDatadogProvider(metricInterval string,
 }
 
 // RunQuery executes the datadog query against DatadogProvider.metricsQueryEndpoint
-// and returns the the first result as float64
+// and returns the the first result as float64, the queries prefixed with slo: or monitor:
+// return the remaining error budget of an SLO or the state of a monitor
 func (p *DatadogProvider) RunQuery(query string) (float64, error) {
-
-	req, err := http.NewRequest("GET", p.metricsQueryEndpoint, nil)
-	if err != nil {
-		return 0, fmt.Errorf("error http.NewRequest: %w", err)
+	query = strings.TrimSpace(query)
+	switch {
+	case strings.HasPrefix(query, datadogSLOQueryPrefix):
+		return p.runSLOQuery(strings.TrimPrefix(query, datadogSLOQueryPrefix))
+	case strings.HasPrefix(query, datadogMonitorQueryPrefix):
+		return p.runMonitorQuery(strings.TrimPrefix(query, datadogMonitorQueryPrefix))
 	}
 
-	req.Header.Set(datadogAPIKeyHeaderKey, p.apiKey)
-	req.Header.Set(datadogApplicationKeyHeaderKey, p.applicationKey)
 	now := time.Now().Unix()
-	q := req.URL.Query()
+	q := url.Values{}
 	q.Add("query", query)
 	q.Add("from", strconv.FormatInt(now-p.fromDelta, 10))
 	q.Add("to", strconv.FormatInt(now, 10))
-	req.URL.RawQuery = q.Encode()
-
-	ctx, cancel := context.WithTimeout(req.Context(), p.timeout)
-	defer cancel()
-	r, err := http.DefaultClient.Do(req.WithContext(ctx))
-	if err != nil {
-		return 0, fmt.Errorf("request failed: %w", err)
-	}
 
-	defer r.Body.Close()
-	b, err := io.ReadAll(r.Body)
+	b, err := p.get(p.metricsQueryEndpoint, q)
 	if err != nil {
-		return 0, fmt.Errorf("error reading body: %w", err)
-	}
-
-	if r.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("error response: %s: %w", string(b), err)
+		return 0, err
 	}
 
 	var res datadogResponse
@@ -156,6 +189,129 @@ func (p *DatadogProvider) RunQuery(query string) (float64, error) {
 	return vs[1], nil
 }
 
+// runSLOQuery returns the percentage of the error budget left in the SLO time window,
+// the window defaults to the shortest timeframe of the SLO e.g. <id> or <id>:30d
+func (p *DatadogProvider) runSLOQuery(query string) (float64, error) {
+	id, timeframe := query, ""
+	if i := strings.Index(query, ":"); i >= 0 {
+		id, timeframe = query[:i], query[i+1:]
+	}
+	if id == "" {
+		return 0, fmt.Errorf("invalid SLO query %s: the SLO ID is required", query)
+	}
+
+	window := 7 * 24 * time.Hour
+	if timeframe != "" {
+		var err error
+		if window, err = parseDatadogTimeframe(timeframe); err != nil {
+			return 0, fmt.Errorf("invalid SLO query %s: %w", query, err)
+		}
+	}
+
+	now := time.Now()
+	q := url.Values{}
+	q.Add("from_ts", strconv.FormatInt(now.Add(-window).Unix(), 10))
+	q.Add("to_ts", strconv.FormatInt(now.Unix(), 10))
+
+	b, err := p.get(fmt.Sprintf("%s/%s/history", p.sloEndpoint, url.PathEscape(id)), q)
+	if err != nil {
+		return 0, err
+	}
+
+	var res datadogSLOHistoryResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	if res.Data.Overall.SLIValue == nil {
+		return 0, fmt.Errorf("invalid response: %s: %w", string(b), ErrNoValuesFound)
+	}
+
+	if timeframe == "" {
+		timeframes := make([]string, 0, len(res.Data.Thresholds))
+		for tf := range res.Data.Thresholds {
+			timeframes = append(timeframes, tf)
+		}
+		sort.Slice(timeframes, func(i, j int) bool {
+			a, _ := parseDatadogTimeframe(timeframes[i])
+			b, _ := parseDatadogTimeframe(timeframes[j])
+			return a < b
+		})
+		if len(timeframes) > 0 {
+			timeframe = timeframes[0]
+		}
+	}
+
+	threshold, ok := res.Data.Thresholds[timeframe]
+	if !ok || threshold.Target >= 100 {
+		return 0, fmt.Errorf("SLO %s has no target for the %s timeframe", id, timeframe)
+	}
+
+	return (*res.Data.Overall.SLIValue - threshold.Target) / (100 - threshold.Target) * 100, nil
+}
+
+// runMonitorQuery returns 0 if the monitor is OK, 1 for Warn and 2 for Alert
+func (p *DatadogProvider) runMonitorQuery(id string) (float64, error) {
+	if id == "" {
+		return 0, fmt.Errorf("invalid monitor query: the monitor ID is required")
+	}
+
+	b, err := p.get(fmt.Sprintf("%s/%s", p.monitorEndpoint, url.PathEscape(id)), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var res datadogMonitorResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	value, ok := datadogMonitorStates[res.OverallState]
+	if !ok {
+		return 0, fmt.Errorf("monitor %s state is %s: %w", id, res.OverallState, ErrNoValuesFound)
+	}
+	return value, nil
+}
+
+// get sends an authenticated request to the endpoint and returns the response body
+func (p *DatadogProvider) get(endpoint string, params url.Values) ([]byte, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error http.NewRequest: %w", err)
+	}
+
+	req.Header.Set(datadogAPIKeyHeaderKey, p.apiKey)
+	req.Header.Set(datadogApplicationKeyHeaderKey, p.applicationKey)
+	req.URL.RawQuery = params.Encode()
+
+	ctx, cancel := context.WithTimeout(req.Context(), p.timeout)
+	defer cancel()
+	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	defer r.Body.Close()
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %w", err)
+	}
+
+	if r.StatusCode != http.StatusOK {
+		return nil, newResponseError(r.StatusCode, b)
+	}
+	return b, nil
+}
+
+// parseDatadogTimeframe parses the SLO timeframes e.g. 7d, 30d or 90d
+func parseDatadogTimeframe(timeframe string) (time.Duration, error) {
+	days, err := strconv.Atoi(strings.TrimSuffix(timeframe, "d"))
+	if err != nil || !strings.HasSuffix(timeframe, "d") || days <= 0 {
+		return 0, fmt.Errorf("timeframe %s is not a number of days e.g. 30d", timeframe)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
 // IsOnline calls the Datadog's validation endpoint with api keys
 // and returns an error if the validation fails
 func (p *DatadogProvider) IsOnline() (bool, error) {
@@ -182,7 +338,7 @@ func (p *DatadogProvider) IsOnline() (bool, error) {
 	}
 
 	if r.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("error response: %s", string(b))
+		return false, newResponseError(r.StatusCode, b)
 	}
 
 	return true, nil
@@ -1,3 +1,6 @@
+//go:build !no_datadog
+// +build !no_datadog
+
 /*
 Copyright 2020 The Flux authors
 
@@ -114,6 +117,91 @@ func TestDatadogProvider_RunQuery(t *testing.T) {
 	})
 }
 
+func TestDatadogProvider_RunSLOQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/slo/abc123/history", r.URL.Path)
+		assert.Equal(t, "api-key", r.Header.Get(datadogAPIKeyHeaderKey))
+
+		from, err := strconv.ParseInt(r.URL.Query().Get("from_ts"), 10, 64)
+		require.NoError(t, err)
+		to, err := strconv.ParseInt(r.URL.Query().Get("to_ts"), 10, 64)
+		require.NoError(t, err)
+		assert.Less(t, from, to)
+
+		w.Write([]byte(`{"data": {"overall": {"sli_value": 99.75}, "thresholds": {"30d": {"target": 99.5}, "7d": {"target": 99}}}}`))
+	}))
+	defer ts.Close()
+
+	dp, err := NewDatadogProvider("1m",
+		flaggerv1.MetricTemplateProvider{Address: ts.URL},
+		map[string][]byte{
+			datadogApplicationKeySecretKey: []byte("app-key"),
+			datadogAPIKeySecretKey:         []byte("api-key"),
+		},
+	)
+	require.NoError(t, err)
+
+	// the shortest timeframe is used by default
+	f, err := dp.RunQuery("slo:abc123")
+	require.NoError(t, err)
+	assert.InDelta(t, 75, f, 0.0001)
+
+	f, err = dp.RunQuery("slo:abc123:30d")
+	require.NoError(t, err)
+	assert.InDelta(t, 50, f, 0.0001)
+
+	_, err = dp.RunQuery("slo:abc123:90d")
+	require.Error(t, err)
+
+	_, err = dp.RunQuery("slo:abc123:1m")
+	require.Error(t, err)
+}
+
+func TestDatadogProvider_RunMonitorQuery(t *testing.T) {
+	for state, expected := range map[string]float64{"OK": 0, "Warn": 1, "Alert": 2} {
+		t.Run(state, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v1/monitor/42", r.URL.Path)
+				assert.Equal(t, "app-key", r.Header.Get(datadogApplicationKeyHeaderKey))
+				w.Write([]byte(fmt.Sprintf(`{"id": 42, "overall_state": "%s"}`, state)))
+			}))
+			defer ts.Close()
+
+			dp, err := NewDatadogProvider("1m",
+				flaggerv1.MetricTemplateProvider{Address: ts.URL},
+				map[string][]byte{
+					datadogApplicationKeySecretKey: []byte("app-key"),
+					datadogAPIKeySecretKey:         []byte("api-key"),
+				},
+			)
+			require.NoError(t, err)
+
+			f, err := dp.RunQuery("monitor:42")
+			require.NoError(t, err)
+			assert.Equal(t, expected, f)
+		})
+	}
+
+	t.Run("no data", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id": 42, "overall_state": "No Data"}`))
+		}))
+		defer ts.Close()
+
+		dp, err := NewDatadogProvider("1m",
+			flaggerv1.MetricTemplateProvider{Address: ts.URL},
+			map[string][]byte{
+				datadogApplicationKeySecretKey: []byte("app-key"),
+				datadogAPIKeySecretKey:         []byte("api-key"),
+			},
+		)
+		require.NoError(t, err)
+
+		_, err = dp.RunQuery("monitor:42")
+		require.True(t, errors.Is(err, ErrNoValuesFound))
+	})
+}
+
 func TestDatadogProvider_IsOnline(t *testing.T) {
 	for _, c := range []struct {
 		code        int
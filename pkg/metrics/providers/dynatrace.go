@@ -1,3 +1,6 @@
+//go:build !no_dynatrace
+// +build !no_dynatrace
+
 /*
 Copyright 2020 The Flux authors
 
@@ -21,13 +24,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/util/yaml"
+
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerProvider("dynatrace", func(metricInterval string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewDynatraceProvider(metricInterval, provider, credentials)
+	})
+}
+
 // https://www.dynatrace.com/support/help/dynatrace-api/environment-api/metric-v2/get-all-metrics/
 const (
 	dynatraceMetricsQueryPath = "/api/v2/metrics/query"
@@ -38,6 +51,9 @@ const (
 	dynatraceAuthorizationHeaderType = "Api-Token"
 
 	dynatraceDeltaMultiplierOnMetricInterval = 10
+
+	dynatraceDefaultResolution = "Inf"
+	dynatraceDefaultReducer    = "max"
 )
 
 // DynatraceProvider executes dynatrace queries
@@ -53,12 +69,30 @@ type DynatraceProvider struct {
 type dynatraceResponse struct {
 	Result []struct {
 		Data []struct {
-			Timestamps []int64   `json:"timestamps"`
-			Values     []float64 `json:"values"`
+			Dimensions []string   `json:"dimensions"`
+			Timestamps []int64    `json:"timestamps"`
+			Values     []*float64 `json:"values"`
 		} `json:"data"`
 	} `json:"result"`
 }
 
+// dynatraceQuery holds the Metrics v2 query parameters,
+// a query made of a metric selector only is also accepted
+type dynatraceQuery struct {
+	// MetricSelector e.g. builtin:service.response.time:percentile(95)
+	MetricSelector string `json:"metricSelector"`
+	// EntitySelector scopes the metric to the matching entities
+	// e.g. type(SERVICE),entityName.equals("podinfo-canary")
+	EntitySelector string `json:"entitySelector,omitempty"`
+	// Resolution of the data points e.g. 1m, defaults to Inf (a single data point)
+	Resolution string `json:"resolution,omitempty"`
+	// From is the start of the query window relative to now e.g. 5m,
+	// defaults to ten times the metric interval
+	From string `json:"from,omitempty"`
+	// Reducer combines the latest value of each dimension tuple: max, min, avg, sum, defaults to max
+	Reducer string `json:"reducer,omitempty"`
+}
+
 // NewDynatraceProvider takes a canary spec, a provider spec and the credentials map, and
 // returns a Dynatrace client ready to execute queries against the API
 func NewDynatraceProvider(metricInterval string,
@@ -92,8 +126,21 @@ func NewDynatraceProvider(metricInterval string,
 }
 
 // RunQuery executes the dynatrace query against DynatraceProvider.metricsQueryEndpoint
-// and returns the the first result as float64
+// and returns the latest value of each dimension tuple reduced to a single float64
 func (p *DynatraceProvider) RunQuery(query string) (float64, error) {
+	dq, err := parseDynatraceQuery(query)
+	if err != nil {
+		return 0, err
+	}
+
+	fromDelta := p.fromDelta
+	if dq.From != "" {
+		d, err := time.ParseDuration(dq.From)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing from %s: %w", dq.From, err)
+		}
+		fromDelta = d.Milliseconds()
+	}
 
 	req, err := http.NewRequest("GET", p.metricsQueryEndpoint, nil)
 	if err != nil {
@@ -104,9 +151,12 @@ func (p *DynatraceProvider) RunQuery(query string) (float64, error) {
 
 	now := time.Now().Unix() * 1000
 	q := req.URL.Query()
-	q.Add("metricSelector", query)
-	q.Add("resolution", "Inf")
-	q.Add("from", strconv.FormatInt(now-p.fromDelta, 10))
+	q.Add("metricSelector", dq.MetricSelector)
+	if dq.EntitySelector != "" {
+		q.Add("entitySelector", dq.EntitySelector)
+	}
+	q.Add("resolution", dq.Resolution)
+	q.Add("from", strconv.FormatInt(now-fromDelta, 10))
 	q.Add("to", strconv.FormatInt(now, 10))
 	req.URL.RawQuery = q.Encode()
 
@@ -124,7 +174,7 @@ func (p *DynatraceProvider) RunQuery(query string) (float64, error) {
 	}
 
 	if r.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("error response: %s: %w", string(b), err)
+		return 0, newResponseError(r.StatusCode, b)
 	}
 
 	var res dynatraceResponse
@@ -136,17 +186,66 @@ func (p *DynatraceProvider) RunQuery(query string) (float64, error) {
 		return 0, fmt.Errorf("invalid response: %s: %w", string(b), ErrNoValuesFound)
 	}
 
-	data := res.Result[0].Data
-	if len(data) < 1 {
+	// the latest non-null value of each dimension tuple
+	var values []float64
+	for _, data := range res.Result[0].Data {
+		for i := len(data.Values) - 1; i >= 0; i-- {
+			if data.Values[i] != nil {
+				values = append(values, *data.Values[i])
+				break
+			}
+		}
+	}
+	if len(values) < 1 {
 		return 0, fmt.Errorf("invalid response: %s: %w", string(b), ErrNoValuesFound)
 	}
 
-	vs := data[len(data)-1]
-	if len(vs.Values) < 1 {
-		return 0, fmt.Errorf("invalid response: %s: %w", string(b), ErrNoValuesFound)
+	return reduceDynatraceValues(dq.Reducer, values), nil
+}
+
+// parseDynatraceQuery decodes the YAML query parameters or
+// uses the whole query as metric selector
+func parseDynatraceQuery(query string) (dynatraceQuery, error) {
+	dq := dynatraceQuery{}
+	if err := yaml.Unmarshal([]byte(query), &dq); err != nil || dq.MetricSelector == "" {
+		dq = dynatraceQuery{MetricSelector: strings.TrimSpace(query)}
 	}
 
-	return vs.Values[0], nil
+	if dq.Resolution == "" {
+		dq.Resolution = dynatraceDefaultResolution
+	}
+
+	switch dq.Reducer {
+	case "":
+		dq.Reducer = dynatraceDefaultReducer
+	case "max", "min", "avg", "sum":
+	default:
+		return dq, fmt.Errorf("dynatrace reducer %s is not supported, use one of max, min, avg or sum", dq.Reducer)
+	}
+	return dq, nil
+}
+
+// reduceDynatraceValues combines the values of a multi-dimensional result
+func reduceDynatraceValues(reducer string, values []float64) float64 {
+	result := values[0]
+	switch reducer {
+	case "min":
+		for _, v := range values[1:] {
+			result = math.Min(result, v)
+		}
+	case "sum", "avg":
+		for _, v := range values[1:] {
+			result += v
+		}
+		if reducer == "avg" {
+			result /= float64(len(values))
+		}
+	default:
+		for _, v := range values[1:] {
+			result = math.Max(result, v)
+		}
+	}
+	return result
 }
 
 // IsOnline calls the Dynatrace's metrics endpoint with token
@@ -174,7 +273,7 @@ func (p *DynatraceProvider) IsOnline() (bool, error) {
 	}
 
 	if r.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("error response: %s", string(b))
+		return false, newResponseError(r.StatusCode, b)
 	}
 
 	return true, nil
@@ -1,3 +1,6 @@
+//go:build !no_dynatrace
+// +build !no_dynatrace
+
 /*
 Copyright 2020 The Flux authors
 
@@ -135,6 +138,68 @@ func TestDynatraceProvider_RunQuery(t *testing.T) {
 	})
 }
 
+func TestDynatraceProvider_RunQueryEntitySelector(t *testing.T) {
+	query := `
+metricSelector: builtin:service.errors.total.rate
+entitySelector: type(SERVICE),entityName.equals("podinfo-canary")
+resolution: 1m
+from: 5m
+reducer: %s
+`
+	now := time.Now().Unix() * 1000
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "builtin:service.errors.total.rate", r.URL.Query().Get("metricSelector"))
+		assert.Equal(t, `type(SERVICE),entityName.equals("podinfo-canary")`, r.URL.Query().Get("entitySelector"))
+		assert.Equal(t, "1m", r.URL.Query().Get("resolution"))
+
+		from, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		if assert.NoError(t, err) {
+			assert.LessOrEqual(t, from, now-5*60*1000)
+			assert.Greater(t, from, now-6*60*1000)
+		}
+
+		w.Write([]byte(`
+{
+  "result": [
+    {
+      "metricId": "builtin:service.errors.total.rate",
+      "data": [
+        {
+          "dimensions": ["SERVICE-1"],
+          "timestamps": [1589455320000, 1589455380000, 1589455440000],
+          "values": [9, 2, null]
+        },
+        {
+          "dimensions": ["SERVICE-2"],
+          "timestamps": [1589455320000, 1589455380000, 1589455440000],
+          "values": [1, 3, 4]
+        }
+      ]
+    }
+  ]
+}
+`))
+	}))
+	defer ts.Close()
+
+	dp, err := NewDynatraceProvider("1m",
+		flaggerv1.MetricTemplateProvider{Address: ts.URL},
+		map[string][]byte{
+			dynatraceAPITokenSecretKey: []byte("token"),
+		},
+	)
+	require.NoError(t, err)
+
+	for reducer, expected := range map[string]float64{"max": 4, "min": 2, "sum": 6, "avg": 3} {
+		f, err := dp.RunQuery(fmt.Sprintf(query, reducer))
+		require.NoError(t, err)
+		assert.Equal(t, expected, f, reducer)
+	}
+
+	_, err = dp.RunQuery(fmt.Sprintf(query, "p99"))
+	require.Error(t, err)
+}
+
 func TestDynatraceProvider_IsOnline(t *testing.T) {
 	for _, c := range []struct {
 		code        int
@@ -16,8 +16,48 @@ limitations under the License.
 
 package providers
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
 
 var (
 	ErrNoValuesFound = errors.New("no values found")
 )
+
+// ResponseError is returned when the provider API responds with an error status code
+type ResponseError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("error response: %s", e.Body)
+}
+
+func newResponseError(statusCode int, body []byte) error {
+	return &ResponseError{StatusCode: statusCode, Body: string(body)}
+}
+
+// IsTransient returns true if the query failed due to a timeout,
+// a server error or throttling and can be retried
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var re *ResponseError
+	if errors.As(err, &re) {
+		return re.StatusCode == http.StatusTooManyRequests || re.StatusCode >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -17,34 +17,98 @@ limitations under the License.
 package providers
 
 import (
+	"fmt"
+	"sort"
+
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/logger"
 )
 
 type Factory struct{}
 
+// providerConstructor returns the metric provider for the given metric interval and provider spec
+type providerConstructor func(metricInterval string, provider flaggerv1.MetricTemplateProvider,
+	credentials map[string][]byte) (Interface, error)
+
+// providers holds the metric providers compiled in the binary, each provider registers
+// itself in a file that can be excluded with a build tag e.g. no_datadog
+var providers = map[string]providerConstructor{}
+
+// builtinProviders are the provider types implemented by Flagger
+var builtinProviders = []string{
+	"bigquery",
+	"cloudwatch",
+	"datadog",
+	"dynatrace",
+	"graphite",
+	"influxdb",
+	"newrelic",
+	"prometheus",
+	"stackdriver",
+	"victoriametrics",
+}
+
+// ValidateType returns an error if the provider type is unknown or not compiled in the binary,
+// the Prometheus provider is used when the type is not set
+func ValidateType(providerType string) error {
+	if providerType == "" {
+		return nil
+	}
+	if _, ok := providers[providerType]; ok {
+		return nil
+	}
+	for _, t := range builtinProviders {
+		if t == providerType {
+			return fmt.Errorf("metric provider %s is not included in this build of Flagger", providerType)
+		}
+	}
+	return fmt.Errorf("metric provider %s is not supported", providerType)
+}
+
+func registerProvider(providerType string, constructor providerConstructor) {
+	providers[providerType] = constructor
+}
+
+// Provider returns the metric provider for the given type, the credentials
+// are removed from the errors returned by the provider
 func (factory Factory) Provider(
 	metricInterval string,
 	provider flaggerv1.MetricTemplateProvider,
 	credentials map[string][]byte,
 ) (Interface, error) {
-	switch provider.Type {
-	case "prometheus":
-		return NewPrometheusProvider(provider, credentials)
-	case "datadog":
-		return NewDatadogProvider(metricInterval, provider, credentials)
-	case "cloudwatch":
-		return NewCloudWatchProvider(metricInterval, provider)
-	case "newrelic":
-		return NewNewRelicProvider(metricInterval, provider, credentials)
-	case "graphite":
-		return NewGraphiteProvider(provider, credentials)
-	case "stackdriver":
-		return NewStackDriverProvider(provider, credentials)
-	case "influxdb":
-		return NewInfluxdbProvider(provider, credentials)
-	case "dynatrace":
-		return NewDynatraceProvider(metricInterval, provider, credentials)
-	default:
-		return NewPrometheusProvider(provider, credentials)
+	p, err := factory.newProvider(metricInterval, provider, credentials)
+	if err != nil {
+		return nil, logger.RedactError(err, credentialValues(credentials)...)
+	}
+	if len(credentials) == 0 {
+		return p, nil
+	}
+	return redactProvider(p, credentials), nil
+}
+
+func (factory Factory) newProvider(
+	metricInterval string,
+	provider flaggerv1.MetricTemplateProvider,
+	credentials map[string][]byte,
+) (Interface, error) {
+	if constructor, ok := providers[provider.Type]; ok {
+		return constructor(metricInterval, provider, credentials)
+	}
+
+	for _, t := range builtinProviders {
+		if t == provider.Type {
+			return nil, fmt.Errorf("metric provider %s is not included in this build of Flagger", provider.Type)
+		}
+	}
+	return NewPrometheusProvider(provider, credentials)
+}
+
+// Providers returns the types of the metric providers compiled in the binary
+func Providers() []string {
+	types := make([]string, 0, len(providers))
+	for t := range providers {
+		types = append(types, t)
 	}
+	sort.Strings(types)
+	return types
 }
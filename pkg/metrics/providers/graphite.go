@@ -1,3 +1,6 @@
+//go:build !no_graphite
+// +build !no_graphite
+
 /*
 Copyright 2020 The Flux authors
 
@@ -18,7 +21,6 @@ package providers
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -33,6 +35,12 @@ import (
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerProvider("graphite", func(_ string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewGraphiteProvider(provider, credentials)
+	})
+}
+
 type graphiteDataPoint struct {
 	Value     *float64
 	TimeStamp time.Time
@@ -110,7 +118,8 @@ type GraphiteProvider struct {
 
 // NewGraphiteProvider takes a provider spec and credentials map,
 // validates the address, extracts the  credentials map's username
-// and password values if provided, and returns a Graphite client
+// and password values or the bearer token and OAuth2 client credentials
+// if provided, and returns a Graphite client
 // ready to execute queries against the Graphite render URL API.
 func NewGraphiteProvider(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (*GraphiteProvider, error) {
 	graphiteURL, err := url.Parse(provider.Address)
@@ -121,16 +130,21 @@ func NewGraphiteProvider(provider flaggerv1.MetricTemplateProvider, credentials
 	graph := GraphiteProvider{
 		url:     *graphiteURL,
 		timeout: 5 * time.Second,
-		client:  http.DefaultClient,
 	}
 
-	if provider.InsecureSkipVerify {
-		t := http.DefaultTransport.(*http.Transport).Clone()
-		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		graph.client = &http.Client{Transport: t}
+	client, tokenAuth, err := newHTTPClient(provider, credentials)
+	if err != nil {
+		return nil, err
+	}
+	graph.client = client
+
+	if provider.SecretRef == nil || tokenAuth {
+		return &graph, nil
 	}
 
-	if provider.SecretRef == nil {
+	_, uok := credentials["username"]
+	_, pok := credentials["password"]
+	if !uok && !pok && hasClientCertificate(provider, credentials) {
 		return &graph, nil
 	}
 
@@ -189,7 +203,7 @@ func (g *GraphiteProvider) RunQuery(query string) (float64, error) {
 	}
 
 	if 400 <= r.StatusCode {
-		return 0, fmt.Errorf("error response: %s", string(b))
+		return 0, newResponseError(r.StatusCode, b)
 	}
 
 	var result graphiteResponse
@@ -1,3 +1,6 @@
+//go:build !no_graphite
+// +build !no_graphite
+
 /*
 Copyright 2020 The Flux authors
 
@@ -1,3 +1,6 @@
+//go:build !no_influxdb
+// +build !no_influxdb
+
 /*
 Copyright 2021 The Flux authors
 
@@ -17,9 +20,16 @@ limitations under the License.
 package providers
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
@@ -27,21 +37,73 @@ import (
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerProvider("influxdb", func(_ string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewInfluxdbProvider(provider, credentials)
+	})
+}
+
+const (
+	influxdbFluxLanguage     = "flux"
+	influxdbSQLLanguage      = "sql"
+	influxdbInfluxQLLanguage = "influxql"
+
+	influxdbDefaultBucket = "default"
+)
+
 type InfluxdbProvider struct {
 	client influxdb2.Client
 	org    string
+	bucket string
+
+	// InfluxDB 3.x query API settings
+	language   string
+	database   string
+	url        url.URL
+	token      string
+	httpClient *http.Client
+	timeout    time.Duration
 }
 
+// NewInfluxdbProvider takes a provider spec and the credentials map,
+// validates the address and returns an InfluxDB client.
+// Flux queries are sent to the InfluxDB 2.x API while
+// SQL and InfluxQL queries are sent to the InfluxDB 3.x query API.
 func NewInfluxdbProvider(provider flaggerv1.MetricTemplateProvider,
 	credentials map[string][]byte) (*InfluxdbProvider, error) {
 	influxURL, err := url.Parse(provider.Address)
 	var token string
-	var influxProvider InfluxdbProvider
+	influxProvider := InfluxdbProvider{
+		language: influxdbFluxLanguage,
+		bucket:   influxdbDefaultBucket,
+	}
 
 	if provider.Address == "" || err != nil {
 		return nil, fmt.Errorf("%s address %s is not a valid URL", provider.Type, provider.Address)
 	}
 
+	var opts flaggerv1.InfluxDBOptions
+	if provider.InfluxDB != nil {
+		opts = *provider.InfluxDB
+	}
+	if opts.QueryLanguage != "" {
+		influxProvider.language = strings.ToLower(opts.QueryLanguage)
+	}
+	if opts.Bucket != "" {
+		influxProvider.bucket = opts.Bucket
+	}
+
+	switch influxProvider.language {
+	case influxdbFluxLanguage:
+	case influxdbSQLLanguage, influxdbInfluxQLLanguage:
+		if opts.Database == "" {
+			return nil, fmt.Errorf("%s database is required for %s queries", provider.Type, influxProvider.language)
+		}
+	default:
+		return nil, fmt.Errorf("%s query language %s is not supported, expected flux, sql or influxql",
+			provider.Type, opts.QueryLanguage)
+	}
+
 	if provider.SecretRef != nil {
 		if authToken, ok := credentials["token"]; ok {
 			token = string(authToken)
@@ -49,12 +111,30 @@ func NewInfluxdbProvider(provider flaggerv1.MetricTemplateProvider,
 			return nil, fmt.Errorf("%s credentials does not contain an authentication token", provider.Type)
 		}
 
+		// the org is used only by the Flux queries and can be set in the metric template
 		if org, ok := credentials["org"]; ok {
 			influxProvider.org = string(org)
-		} else {
+		} else if opts.Org == "" && influxProvider.language == influxdbFluxLanguage {
 			return nil, fmt.Errorf("%s credentials does not contain an organisation", provider.Type)
 		}
 	}
+	if opts.Org != "" {
+		influxProvider.org = opts.Org
+	}
+
+	if influxProvider.useV3API() {
+		influxProvider.url = *influxURL
+		influxProvider.token = token
+		influxProvider.database = opts.Database
+		influxProvider.timeout = 15 * time.Second
+		influxProvider.httpClient = http.DefaultClient
+		if provider.InsecureSkipVerify {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			influxProvider.httpClient = &http.Client{Transport: t}
+		}
+		return &influxProvider, nil
+	}
 
 	client := influxdb2.NewClient(influxURL.String(), token)
 	influxProvider.client = client
@@ -63,6 +143,10 @@ func NewInfluxdbProvider(provider flaggerv1.MetricTemplateProvider,
 }
 
 func (i *InfluxdbProvider) RunQuery(query string) (float64, error) {
+	if i.useV3API() {
+		return i.runV3Query(query)
+	}
+
 	queryAPI := i.client.QueryAPI(i.org)
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -88,12 +172,19 @@ func (i *InfluxdbProvider) RunQuery(query string) (float64, error) {
 	return 0, nil
 }
 
-// IsOnline runs a simple query against the default bucket.
+// IsOnline runs a simple query against the configured bucket or database.
 func (i *InfluxdbProvider) IsOnline() (bool, error) {
+	if i.useV3API() {
+		if _, err := i.queryV3("SELECT 1"); err != nil {
+			return false, fmt.Errorf("error accessing influxdb query api: %w", err)
+		}
+		return true, nil
+	}
+
 	queryAPI := i.client.QueryAPI(i.org)
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
-	result, err := queryAPI.Query(ctx, `from(bucket: "default") |> range(start: -2h)`)
+	result, err := queryAPI.Query(ctx, fmt.Sprintf(`from(bucket: "%s") |> range(start: -2h)`, i.bucket))
 	if err != nil {
 		return false, fmt.Errorf("error accessing influxdb query api: %s", err)
 	}
@@ -105,3 +196,100 @@ func (i *InfluxdbProvider) IsOnline() (bool, error) {
 
 	return true, nil
 }
+
+// useV3API returns true if the queries are sent to the InfluxDB 3.x query API
+func (i *InfluxdbProvider) useV3API() bool {
+	return i.language == influxdbSQLLanguage || i.language == influxdbInfluxQLLanguage
+}
+
+// runV3Query executes a SQL or InfluxQL query and returns the single value column of the first row
+func (i *InfluxdbProvider) runV3Query(query string) (float64, error) {
+	rows, err := i.queryV3(query)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) < 1 {
+		return 0, fmt.Errorf("invalid response: %w", ErrNoValuesFound)
+	}
+
+	var values []float64
+	for column, v := range rows[0] {
+		// InfluxQL results contain the measurement name and the timestamp
+		if column == "time" || column == "iox::measurement" {
+			continue
+		}
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid value for column %s: %w", column, err)
+		}
+		values = append(values, f)
+	}
+
+	switch len(values) {
+	case 0:
+		return 0, fmt.Errorf("invalid response: %v: %w", rows[0], ErrNoValuesFound)
+	case 1:
+		return values[0], nil
+	default:
+		return 0, fmt.Errorf("invalid response: %v: the query must return a single numeric column", rows[0])
+	}
+}
+
+// queryV3 calls the InfluxDB 3.x query API and returns the rows of the JSON result
+func (i *InfluxdbProvider) queryV3(query string) ([]map[string]interface{}, error) {
+	u, err := url.Parse("./api/v3/query_" + i.language)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse failed: %w", err)
+	}
+	u.Path = path.Join(i.url.Path, u.Path)
+	u = i.url.ResolveReference(u)
+
+	body, err := json.Marshal(map[string]string{
+		"db":     i.database,
+		"q":      query,
+		"format": "json",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling query: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if i.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", i.token))
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), i.timeout)
+	defer cancel()
+
+	r, err := i.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer r.Body.Close()
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %w", err)
+	}
+
+	if 400 <= r.StatusCode {
+		return nil, newResponseError(r.StatusCode, b)
+	}
+
+	var rows []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	return rows, nil
+}
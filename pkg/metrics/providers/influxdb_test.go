@@ -1,3 +1,6 @@
+//go:build !no_influxdb
+// +build !no_influxdb
+
 /*
 Copyright 2021 The Flux authors
 
@@ -17,6 +20,8 @@ limitations under the License.
 package providers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -24,6 +29,7 @@ import (
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -129,3 +135,83 @@ func TestInfluxdbProvider_RunQuery(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, float, 1.4)
 }
+
+func TestInfluxdbProvider_RunQueryV3(t *testing.T) {
+	t.Run("sql", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v3/query_sql", r.URL.Path)
+			assert.Equal(t, "Bearer x", r.Header.Get("Authorization"))
+
+			var body map[string]string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "metrics", body["db"])
+			assert.Equal(t, "SELECT count(*) FROM requests", body["q"])
+
+			w.Write([]byte(`[{"count(*)":42}]`))
+		}))
+		defer ts.Close()
+
+		provider, err := NewInfluxdbProvider(flaggerv1.MetricTemplateProvider{
+			Type:      "influxdb",
+			Address:   ts.URL,
+			SecretRef: &corev1.LocalObjectReference{Name: "test-secret"},
+			InfluxDB: &flaggerv1.InfluxDBOptions{
+				QueryLanguage: "sql",
+				Database:      "metrics",
+			},
+		}, map[string][]byte{"token": []byte("x")})
+		require.NoError(t, err)
+
+		val, err := provider.RunQuery("SELECT count(*) FROM requests")
+		require.NoError(t, err)
+		assert.Equal(t, float64(42), val)
+	})
+
+	t.Run("influxql", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v3/query_influxql", r.URL.Path)
+			w.Write([]byte(`[{"iox::measurement":"requests","time":"1970-01-01T00:00:00","mean":0.25}]`))
+		}))
+		defer ts.Close()
+
+		provider, err := NewInfluxdbProvider(flaggerv1.MetricTemplateProvider{
+			Type:    "influxdb",
+			Address: ts.URL,
+			InfluxDB: &flaggerv1.InfluxDBOptions{
+				QueryLanguage: "influxql",
+				Database:      "metrics",
+			},
+		}, nil)
+		require.NoError(t, err)
+
+		val, err := provider.RunQuery("SELECT mean(duration) FROM requests")
+		require.NoError(t, err)
+		assert.Equal(t, 0.25, val)
+	})
+
+	t.Run("no values", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[]`))
+		}))
+		defer ts.Close()
+
+		provider, err := NewInfluxdbProvider(flaggerv1.MetricTemplateProvider{
+			Type:     "influxdb",
+			Address:  ts.URL,
+			InfluxDB: &flaggerv1.InfluxDBOptions{QueryLanguage: "sql", Database: "metrics"},
+		}, nil)
+		require.NoError(t, err)
+
+		_, err = provider.RunQuery("SELECT count(*) FROM requests")
+		require.True(t, errors.Is(err, ErrNoValuesFound))
+	})
+
+	t.Run("database is required", func(t *testing.T) {
+		_, err := NewInfluxdbProvider(flaggerv1.MetricTemplateProvider{
+			Type:     "influxdb",
+			Address:  "http://localhost/",
+			InfluxDB: &flaggerv1.InfluxDBOptions{QueryLanguage: "sql"},
+		}, nil)
+		require.Error(t, err)
+	})
+}
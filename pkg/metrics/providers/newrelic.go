@@ -1,3 +1,6 @@
+//go:build !no_newrelic
+// +build !no_newrelic
+
 /*
 Copyright 2020 The Flux authors
 
@@ -17,31 +20,55 @@ limitations under the License.
 package providers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerProvider("newrelic", func(metricInterval string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewNewRelicProvider(metricInterval, provider, credentials)
+	})
+}
+
 const (
-	newrelicInsightsDefaultHost = "https://insights-api.newrelic.com"
+	newrelicInsightsDefaultHost  = "https://insights-api.newrelic.com"
+	newrelicInsightsEUHost       = "https://insights-api.eu.newrelic.com"
+	newrelicNerdGraphDefaultHost = "https://api.newrelic.com"
+	newrelicNerdGraphEUHost      = "https://api.eu.newrelic.com"
+	newrelicNerdGraphPath        = "/graphql"
+	newrelicEURegion             = "eu"
 
 	newrelicQueryKeySecretKey  = "newrelic_query_key"
+	newrelicAPIKeySecretKey    = "newrelic_api_key"
 	newrelicAccountIdSecretKey = "newrelic_account_id"
 
 	newrelicQueryKeyHeaderKey = "X-Query-Key"
+	newrelicAPIKeyHeaderKey   = "API-Key"
+
+	newrelicNerdGraphNRQLQuery    = `query($accountId: Int!, $nrql: Nrql!) { actor { account(id: $accountId) { nrql(query: $nrql) { results } } } }`
+	newrelicNerdGraphAccountQuery = `query($accountId: Int!) { actor { account(id: $accountId) { id } } }`
 )
 
-// NewRelicProvider executes newrelic queries
+// NewRelicProvider executes newrelic queries with NerdGraph when the secret
+// contains a user API key, otherwise with the Insights API
 type NewRelicProvider struct {
 	insightsQueryEndpoint string
+	nerdGraphEndpoint     string
 
 	timeout   time.Duration
 	queryKey  string
+	apiKey    string
+	accountID int64
 	fromDelta int64
 }
 
@@ -51,33 +78,69 @@ type newRelicResponse struct {
 	} `json:"results"`
 }
 
+type newRelicNerdGraphResponse struct {
+	Data struct {
+		Actor struct {
+			Account *struct {
+				NRQL *struct {
+					Results []map[string]interface{} `json:"results"`
+				} `json:"nrql"`
+			} `json:"account"`
+		} `json:"actor"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
 // NewNewRelicProvider takes a canary spec, a provider spec and the credentials map, and
-// returns a NewRelic client ready to execute queries against the Insights API
+// returns a NewRelic client ready to execute queries against NerdGraph or the Insights API,
+// the EU endpoints are used when the provider region is set to eu
 func NewNewRelicProvider(
 	metricInterval string,
 	provider flaggerv1.MetricTemplateProvider,
 	credentials map[string][]byte,
 ) (*NewRelicProvider, error) {
-	address := provider.Address
-	if address == "" {
-		address = newrelicInsightsDefaultHost
-	}
+	eu := strings.EqualFold(provider.Region, newrelicEURegion)
 
 	accountId, ok := credentials[newrelicAccountIdSecretKey]
 	if !ok {
 		return nil, fmt.Errorf("newrelic credentials does not contain the key '%s'", newrelicAccountIdSecretKey)
 	}
 
-	queryEndpoint := fmt.Sprintf("%s/v1/accounts/%s/query", address, accountId)
 	nr := NewRelicProvider{
-		timeout:               5 * time.Second,
-		insightsQueryEndpoint: queryEndpoint,
+		timeout: 5 * time.Second,
 	}
 
-	if b, ok := credentials[newrelicQueryKeySecretKey]; ok {
+	if b, ok := credentials[newrelicAPIKeySecretKey]; ok {
+		accountID, err := strconv.ParseInt(strings.TrimSpace(string(accountId)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("newrelic credentials key '%s' must be a number, got '%s'", newrelicAccountIdSecretKey, accountId)
+		}
+
+		address := provider.Address
+		if address == "" {
+			address = newrelicNerdGraphDefaultHost
+			if eu {
+				address = newrelicNerdGraphEUHost
+			}
+		}
+		nr.nerdGraphEndpoint = strings.TrimSuffix(address, "/") + newrelicNerdGraphPath
+		nr.apiKey = string(b)
+		nr.accountID = accountID
+	} else if b, ok := credentials[newrelicQueryKeySecretKey]; ok {
+		address := provider.Address
+		if address == "" {
+			address = newrelicInsightsDefaultHost
+			if eu {
+				address = newrelicInsightsEUHost
+			}
+		}
+		nr.insightsQueryEndpoint = fmt.Sprintf("%s/v1/accounts/%s/query", address, accountId)
 		nr.queryKey = string(b)
 	} else {
-		return nil, fmt.Errorf("newrelic credentials does not contain the key ''%s", newrelicQueryKeySecretKey)
+		return nil, fmt.Errorf("newrelic credentials does not contain the key '%s' or '%s'",
+			newrelicAPIKeySecretKey, newrelicQueryKeySecretKey)
 	}
 
 	md, err := time.ParseDuration(metricInterval)
@@ -89,9 +152,13 @@ func NewNewRelicProvider(
 	return &nr, nil
 }
 
-// RunQuery executes the new relic query against the New Relic Insights API
+// RunQuery executes the new relic query against NerdGraph or the New Relic Insights API
 // and returns the the first result
 func (p *NewRelicProvider) RunQuery(query string) (float64, error) {
+	if p.apiKey != "" {
+		return p.runNerdGraphQuery(query)
+	}
+
 	req, err := p.newInsightsRequest(query)
 	if err != nil {
 		return 0, err
@@ -111,7 +178,7 @@ func (p *NewRelicProvider) RunQuery(query string) (float64, error) {
 	}
 
 	if r.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("error response: %s: %w", string(b), err)
+		return 0, newResponseError(r.StatusCode, b)
 	}
 
 	var res newRelicResponse
@@ -130,9 +197,20 @@ func (p *NewRelicProvider) RunQuery(query string) (float64, error) {
 	return *res.Results[0].Result, nil
 }
 
-// IsOnline calls the NewRelic's insights API with
+// IsOnline calls the NewRelic's NerdGraph or insights API with
 // and returns an error if the request is rejected
 func (p *NewRelicProvider) IsOnline() (bool, error) {
+	if p.apiKey != "" {
+		res, err := p.nerdGraph(newrelicNerdGraphAccountQuery, map[string]interface{}{"accountId": p.accountID})
+		if err != nil {
+			return false, err
+		}
+		if res.Data.Actor.Account == nil {
+			return false, fmt.Errorf("newrelic account %d not found or not accessible with the API key", p.accountID)
+		}
+		return true, nil
+	}
+
 	req, err := p.newInsightsRequest("SELECT * FROM Metric")
 	if err != nil {
 		return false, fmt.Errorf("error http.NewRequest: %w", err)
@@ -153,7 +231,7 @@ func (p *NewRelicProvider) IsOnline() (bool, error) {
 	}
 
 	if r.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("error response: %s", string(b))
+		return false, newResponseError(r.StatusCode, b)
 	}
 
 	return true, nil
@@ -173,3 +251,146 @@ func (p *NewRelicProvider) newInsightsRequest(query string) (*http.Request, erro
 
 	return req, nil
 }
+
+// runNerdGraphQuery executes the NRQL query with NerdGraph, for the TIMESERIES queries
+// the value of the latest bucket is used and for the FACET queries the max value
+// of all the facets is returned
+func (p *NewRelicProvider) runNerdGraphQuery(query string) (float64, error) {
+	res, err := p.nerdGraph(newrelicNerdGraphNRQLQuery, map[string]interface{}{
+		"accountId": p.accountID,
+		"nrql":      fmt.Sprintf("%s SINCE %d seconds ago", query, p.fromDelta),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	account := res.Data.Actor.Account
+	if account == nil {
+		return 0, fmt.Errorf("newrelic account %d not found or not accessible with the API key", p.accountID)
+	}
+	if account.NRQL == nil || len(account.NRQL.Results) == 0 {
+		return 0, fmt.Errorf("invalid response: no results: %w", ErrNoValuesFound)
+	}
+
+	// the latest value of each facet
+	type bucket struct {
+		end   float64
+		value float64
+	}
+	facets := make(map[string]bucket)
+	for _, row := range account.NRQL.Results {
+		value, ok, err := newRelicRowValue(row)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+
+		facet := fmt.Sprint(row["facet"])
+		end, _ := row["endTimeSeconds"].(float64)
+		if b, found := facets[facet]; !found || end >= b.end {
+			facets[facet] = bucket{end: end, value: value}
+		}
+	}
+
+	if len(facets) == 0 {
+		return 0, fmt.Errorf("invalid response: %v: %w", account.NRQL.Results, ErrNoValuesFound)
+	}
+
+	result := math.Inf(-1)
+	for _, b := range facets {
+		result = math.Max(result, b.value)
+	}
+	return result, nil
+}
+
+// newRelicRowValue returns the numeric value of a NRQL result row, ignoring
+// the facet and the time buckets, the row must hold a single value
+func newRelicRowValue(row map[string]interface{}) (float64, bool, error) {
+	var values []float64
+	for key, v := range row {
+		switch key {
+		case "facet", "beginTimeSeconds", "endTimeSeconds":
+			continue
+		}
+		// the facet attributes are returned along with the facet
+		if facet, ok := row["facet"]; ok && fmt.Sprint(facet) == fmt.Sprint(v) {
+			continue
+		}
+
+		switch value := v.(type) {
+		case float64:
+			values = append(values, value)
+		case map[string]interface{}:
+			// percentile() returns the values indexed by percentile
+			for _, nested := range value {
+				if f, ok := nested.(float64); ok {
+					values = append(values, f)
+				}
+			}
+		}
+	}
+
+	switch len(values) {
+	case 0:
+		return 0, false, nil
+	case 1:
+		return values[0], true, nil
+	default:
+		return 0, false, fmt.Errorf("the NRQL query must select a single value, got %v", row)
+	}
+}
+
+// nerdGraph sends the GraphQL query and returns an error for the rejected requests and the GraphQL errors
+func (p *NewRelicProvider) nerdGraph(query string, variables map[string]interface{}) (*newRelicNerdGraphResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling query: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", p.nerdGraphEndpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("error http.NewRequest: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(newrelicAPIKeyHeaderKey, p.apiKey)
+
+	ctx, cancel := context.WithTimeout(req.Context(), p.timeout)
+	defer cancel()
+	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	defer r.Body.Close()
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %w", err)
+	}
+
+	switch {
+	case r.StatusCode == http.StatusUnauthorized || r.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("newrelic API key rejected, it must be a user key with access to account %d: %w",
+			p.accountID, newResponseError(r.StatusCode, b))
+	case r.StatusCode != http.StatusOK:
+		return nil, newResponseError(r.StatusCode, b)
+	}
+
+	var res newRelicNerdGraphResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	if len(res.Errors) > 0 {
+		messages := make([]string, 0, len(res.Errors))
+		for _, e := range res.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("newrelic query for account %d failed: %s", p.accountID, strings.Join(messages, "; "))
+	}
+	return &res, nil
+}
@@ -1,3 +1,6 @@
+//go:build !no_newrelic
+// +build !no_newrelic
+
 /*
 Copyright 2020 The Flux authors
 
@@ -17,6 +20,7 @@ limitations under the License.
 package providers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -140,3 +144,113 @@ func TestNewReelicProvider_IsOnline(t *testing.T) {
 		})
 	}
 }
+
+func TestNewNewRelicProvider_NerdGraph(t *testing.T) {
+	cs := map[string][]byte{
+		"newrelic_api_key":    []byte("api-key"),
+		"newrelic_account_id": []byte("51312"),
+	}
+
+	nr, err := NewNewRelicProvider("1m", flaggerv1.MetricTemplateProvider{}, cs)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.newrelic.com/graphql", nr.nerdGraphEndpoint)
+	assert.Equal(t, int64(51312), nr.accountID)
+
+	nr, err = NewNewRelicProvider("1m", flaggerv1.MetricTemplateProvider{Region: "EU"}, cs)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.eu.newrelic.com/graphql", nr.nerdGraphEndpoint)
+
+	nr, err = NewNewRelicProvider("1m", flaggerv1.MetricTemplateProvider{Region: "eu"}, map[string][]byte{
+		"newrelic_query_key":  []byte("query-key"),
+		"newrelic_account_id": []byte("51312"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://insights-api.eu.newrelic.com/v1/accounts/51312/query", nr.insightsQueryEndpoint)
+
+	_, err = NewNewRelicProvider("1m", flaggerv1.MetricTemplateProvider{}, map[string][]byte{
+		"newrelic_api_key":    []byte("api-key"),
+		"newrelic_account_id": []byte("my-account"),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be a number")
+}
+
+func TestNewRelicProvider_RunNerdGraphQuery(t *testing.T) {
+	newProvider := func(t *testing.T, status int, response string) *NewRelicProvider {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/graphql", r.URL.Path)
+			assert.Equal(t, "api-key", r.Header.Get(newrelicAPIKeyHeaderKey))
+
+			var body struct {
+				Variables map[string]interface{} `json:"variables"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, float64(51312), body.Variables["accountId"])
+
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+		}))
+		t.Cleanup(ts.Close)
+
+		nr, err := NewNewRelicProvider("1m", flaggerv1.MetricTemplateProvider{Address: ts.URL},
+			map[string][]byte{
+				"newrelic_api_key":    []byte("api-key"),
+				"newrelic_account_id": []byte("51312"),
+			})
+		require.NoError(t, err)
+		return nr
+	}
+
+	t.Run("single value", func(t *testing.T) {
+		nr := newProvider(t, http.StatusOK, `{"data":{"actor":{"account":{"nrql":{"results":[{"average.duration": 0.25}]}}}}}`)
+		f, err := nr.RunQuery("SELECT average(duration) FROM Transaction")
+		require.NoError(t, err)
+		assert.Equal(t, 0.25, f)
+	})
+
+	t.Run("percentile", func(t *testing.T) {
+		nr := newProvider(t, http.StatusOK, `{"data":{"actor":{"account":{"nrql":{"results":[{"percentile.duration": {"99": 0.5}}]}}}}}`)
+		f, err := nr.RunQuery("SELECT percentile(duration, 99) FROM Transaction")
+		require.NoError(t, err)
+		assert.Equal(t, 0.5, f)
+	})
+
+	t.Run("facets and timeseries", func(t *testing.T) {
+		nr := newProvider(t, http.StatusOK, `{"data":{"actor":{"account":{"nrql":{"results":[
+			{"facet": "pod-a", "podName": "pod-a", "beginTimeSeconds": 0, "endTimeSeconds": 30, "count": 9},
+			{"facet": "pod-a", "podName": "pod-a", "beginTimeSeconds": 30, "endTimeSeconds": 60, "count": 2},
+			{"facet": "pod-b", "podName": "pod-b", "beginTimeSeconds": 0, "endTimeSeconds": 30, "count": 1},
+			{"facet": "pod-b", "podName": "pod-b", "beginTimeSeconds": 30, "endTimeSeconds": 60, "count": 4}
+		]}}}}}`)
+		f, err := nr.RunQuery("SELECT count(*) FROM TransactionError FACET podName TIMESERIES")
+		require.NoError(t, err)
+		assert.Equal(t, float64(4), f)
+	})
+
+	t.Run("no values", func(t *testing.T) {
+		nr := newProvider(t, http.StatusOK, `{"data":{"actor":{"account":{"nrql":{"results":[]}}}}}`)
+		_, err := nr.RunQuery("SELECT count(*) FROM Transaction")
+		require.True(t, errors.Is(err, ErrNoValuesFound))
+	})
+
+	t.Run("graphql errors", func(t *testing.T) {
+		nr := newProvider(t, http.StatusOK, `{"data":{"actor":{"account":null}},"errors":[{"message":"NRQL Syntax Error"}]}`)
+		_, err := nr.RunQuery("SELECT")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "NRQL Syntax Error")
+	})
+
+	t.Run("account not accessible", func(t *testing.T) {
+		nr := newProvider(t, http.StatusOK, `{"data":{"actor":{"account":null}}}`)
+		_, err := nr.IsOnline()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "account 51312 not found")
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		nr := newProvider(t, http.StatusUnauthorized, `{"errors":[{"message":"Invalid API key"}]}`)
+		_, err := nr.IsOnline()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "API key rejected")
+	})
+}
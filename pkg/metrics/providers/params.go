@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// providerHeaders returns the extra HTTP headers declared in the provider spec
+// e.g. the X-Scope-OrgID tenant header of Cortex and Mimir
+func providerHeaders(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (http.Header, error) {
+	headers := http.Header{}
+	for _, param := range provider.Headers {
+		value, err := providerParamValue(provider, param, credentials)
+		if err != nil {
+			return nil, fmt.Errorf("%s header %w", provider.Type, err)
+		}
+		headers.Add(param.Name, value)
+	}
+	return headers, nil
+}
+
+// providerQueryParams returns the extra query params declared in the provider spec
+func providerQueryParams(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (url.Values, error) {
+	params := url.Values{}
+	for _, param := range provider.QueryParams {
+		value, err := providerParamValue(provider, param, credentials)
+		if err != nil {
+			return nil, fmt.Errorf("%s query param %w", provider.Type, err)
+		}
+		params.Add(param.Name, value)
+	}
+	return params, nil
+}
+
+// providerParamValue returns the inline value of the param or
+// the value found in the credentials under the param secret key
+func providerParamValue(provider flaggerv1.MetricTemplateProvider, param flaggerv1.MetricTemplateProviderParam, credentials map[string][]byte) (string, error) {
+	if param.Name == "" {
+		return "", fmt.Errorf("name cannot be empty")
+	}
+	if param.SecretKey == "" {
+		return param.Value, nil
+	}
+	if provider.SecretRef == nil {
+		return "", fmt.Errorf("%s requires a secretRef", param.Name)
+	}
+	value, ok := credentials[param.SecretKey]
+	if !ok {
+		return "", fmt.Errorf("%s credentials does not contain %s", param.Name, param.SecretKey)
+	}
+	return string(value), nil
+}
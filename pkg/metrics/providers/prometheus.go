@@ -18,7 +18,6 @@ package providers
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -33,15 +32,23 @@ import (
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerProvider("prometheus", func(_ string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewPrometheusProvider(provider, credentials)
+	})
+}
+
 const prometheusOnlineQuery = "vector(1)"
 
 // PrometheusProvider executes promQL queries
 type PrometheusProvider struct {
-	timeout  time.Duration
-	url      url.URL
-	username string
-	password string
-	client   *http.Client
+	timeout     time.Duration
+	url         url.URL
+	username    string
+	password    string
+	headers     http.Header
+	queryParams url.Values
+	client      *http.Client
 }
 
 type prometheusResponse struct {
@@ -55,8 +62,18 @@ type prometheusResponse struct {
 	}
 }
 
+type prometheusRangeResponse struct {
+	Data struct {
+		Result []struct {
+			Values [][]interface{} `json:"values"`
+		}
+	}
+}
+
 // NewPrometheusProvider takes a provider spec and the credentials map,
-// validates the address, extracts the username and password values if provided and
+// validates the address, extracts the username and password values or
+// the bearer token and OAuth2 client credentials if provided,
+// resolves the extra headers and query params and
 // returns a Prometheus client ready to execute queries against the API
 func NewPrometheusProvider(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (*PrometheusProvider, error) {
 	promURL, err := url.Parse(provider.Address)
@@ -67,26 +84,41 @@ func NewPrometheusProvider(provider flaggerv1.MetricTemplateProvider, credential
 	prom := PrometheusProvider{
 		timeout: 5 * time.Second,
 		url:     *promURL,
-		client:  http.DefaultClient,
 	}
 
-	if provider.InsecureSkipVerify {
-		t := http.DefaultTransport.(*http.Transport).Clone()
-		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		prom.client = &http.Client{Transport: t}
+	client, tokenAuth, err := newHTTPClient(provider, credentials)
+	if err != nil {
+		return nil, err
 	}
+	prom.client = client
 
-	if provider.SecretRef != nil {
-		if username, ok := credentials["username"]; ok {
+	if provider.SecretRef != nil && !tokenAuth {
+		username, uok := credentials["username"]
+		password, pok := credentials["password"]
+		switch {
+		case uok && pok:
 			prom.username = string(username)
-		} else {
+			prom.password = string(password)
+		case !uok && !pok && (len(provider.Headers) > 0 || len(provider.QueryParams) > 0):
+			// the secret holds only the values of the headers or query params e.g. the tenant ID
+		case !uok && !pok && hasClientCertificate(provider, credentials):
+			// the requests are authenticated with the TLS client certificate
+		case !uok:
 			return nil, fmt.Errorf("%s credentials does not contain a username", provider.Type)
+		default:
+			return nil, fmt.Errorf("%s credentials does not contain a password", provider.Type)
 		}
+	}
 
-		if password, ok := credentials["password"]; ok {
-			prom.password = string(password)
-		} else {
-			return nil, fmt.Errorf("%s credentials does not contain a password", provider.Type)
+	if prom.headers, err = providerHeaders(provider, credentials); err != nil {
+		return nil, err
+	}
+	if prom.queryParams, err = providerQueryParams(provider, credentials); err != nil {
+		return nil, err
+	}
+	if provider.Thanos != nil {
+		if err := setThanosQueryParams(prom.queryParams, *provider.Thanos); err != nil {
+			return nil, fmt.Errorf("%s thanos options are not valid: %w", provider.Type, err)
 		}
 	}
 
@@ -95,18 +127,108 @@ func NewPrometheusProvider(provider flaggerv1.MetricTemplateProvider, credential
 
 // RunQuery executes the promQL query and returns the the first result as float64
 func (p *PrometheusProvider) RunQuery(query string) (float64, error) {
-	query = url.QueryEscape(p.trimQuery(query))
-	u, err := url.Parse(fmt.Sprintf("./api/v1/query?query=%s", query))
+	params := url.Values{}
+	params.Set("query", p.trimQuery(query))
+
+	b, err := p.get("./api/v1/query", params)
+	if err != nil {
+		return 0, err
+	}
+
+	var result prometheusResponse
+	err = json.Unmarshal(b, &result)
+	if err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	var value *float64
+	for _, v := range result.Data.Result {
+		metricValue := v.Value[1]
+		switch metricValue.(type) {
+		case string:
+			f, err := strconv.ParseFloat(metricValue.(string), 64)
+			if err != nil {
+				return 0, err
+			}
+			value = &f
+		}
+	}
+	if value == nil || math.IsNaN(*value) {
+		return 0, fmt.Errorf("%w", ErrNoValuesFound)
+	}
+
+	return *value, nil
+}
+
+// RunRangeQuery executes the promQL range query and returns the samples of all series as float64
+func (p *PrometheusProvider) RunRangeQuery(query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	params := url.Values{}
+	params.Set("query", p.trimQuery(query))
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	b, err := p.get("./api/v1/query_range", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result prometheusRangeResponse
+	err = json.Unmarshal(b, &result)
 	if err != nil {
-		return 0, fmt.Errorf("url.Parase failed: %w", err)
+		return nil, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	var values []float64
+	for _, series := range result.Data.Result {
+		for _, v := range series.Values {
+			if len(v) < 2 {
+				continue
+			}
+			if metricValue, ok := v[1].(string); ok {
+				f, err := strconv.ParseFloat(metricValue, 64)
+				if err != nil {
+					return nil, err
+				}
+				if !math.IsNaN(f) {
+					values = append(values, f)
+				}
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w", ErrNoValuesFound)
+	}
+
+	return values, nil
+}
+
+// get calls the Prometheus API endpoint with the given params and returns the response body
+func (p *PrometheusProvider) get(apiPath string, query url.Values) ([]byte, error) {
+	u, err := url.Parse(apiPath)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parase failed: %w", err)
 	}
 	u.Path = path.Join(p.url.Path, u.Path)
 
 	u = p.url.ResolveReference(u)
 
+	params := url.Values{}
+	for k, v := range p.queryParams {
+		params[k] = append([]string{}, v...)
+	}
+	for k, v := range query {
+		params[k] = v
+	}
+	u.RawQuery = params.Encode()
+
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
-		return 0, fmt.Errorf("http.NewRequest failed: %w", err)
+		return nil, fmt.Errorf("http.NewRequest failed: %w", err)
+	}
+
+	for k, v := range p.headers {
+		req.Header[k] = v
 	}
 
 	if p.username != "" && p.password != "" {
@@ -118,42 +240,20 @@ func (p *PrometheusProvider) RunQuery(query string) (float64, error) {
 
 	r, err := p.client.Do(req.WithContext(ctx))
 	if err != nil {
-		return 0, fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer r.Body.Close()
 
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
-		return 0, fmt.Errorf("error reading body: %w", err)
+		return nil, fmt.Errorf("error reading body: %w", err)
 	}
 
 	if 400 <= r.StatusCode {
-		return 0, fmt.Errorf("error response: %s", string(b))
-	}
-
-	var result prometheusResponse
-	err = json.Unmarshal(b, &result)
-	if err != nil {
-		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+		return nil, newResponseError(r.StatusCode, b)
 	}
 
-	var value *float64
-	for _, v := range result.Data.Result {
-		metricValue := v.Value[1]
-		switch metricValue.(type) {
-		case string:
-			f, err := strconv.ParseFloat(metricValue.(string), 64)
-			if err != nil {
-				return 0, err
-			}
-			value = &f
-		}
-	}
-	if value == nil || math.IsNaN(*value) {
-		return 0, fmt.Errorf("%w", ErrNoValuesFound)
-	}
-
-	return *value, nil
+	return b, nil
 }
 
 // IsOnline run simple Prometheus query and returns an error if the API is unreachable
@@ -170,6 +270,37 @@ func (p *PrometheusProvider) IsOnline() (bool, error) {
 	return true, nil
 }
 
+// setThanosQueryParams sets the Thanos Query params, partial responses are disabled and
+// deduplication is enabled by default so that queries fail instead of returning incomplete data
+func setThanosQueryParams(params url.Values, thanos flaggerv1.ThanosOptions) error {
+	partialResponse := false
+	if thanos.PartialResponse != nil {
+		partialResponse = *thanos.PartialResponse
+	}
+	params.Set("partial_response", strconv.FormatBool(partialResponse))
+
+	dedup := true
+	if thanos.Dedup != nil {
+		dedup = *thanos.Dedup
+	}
+	params.Set("dedup", strconv.FormatBool(dedup))
+
+	switch resolution := thanos.MaxSourceResolution; resolution {
+	case "":
+	case "raw":
+		params.Set("max_source_resolution", "0s")
+	case "auto":
+		params.Set("max_source_resolution", resolution)
+	default:
+		if _, err := time.ParseDuration(resolution); err != nil {
+			return fmt.Errorf("maxSourceResolution %s is not valid, expected raw, auto or a duration", resolution)
+		}
+		params.Set("max_source_resolution", resolution)
+	}
+
+	return nil
+}
+
 // trimQuery takes a promql query and removes whitespace
 func (p *PrometheusProvider) trimQuery(query string) string {
 	space := regexp.MustCompile(`\s+`)
@@ -23,6 +23,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -226,3 +227,93 @@ func TestPrometheusProvider_IsOnline(t *testing.T) {
 		assert.Equal(t, true, ok)
 	})
 }
+
+func TestPrometheusProvider_RunRangeQuery(t *testing.T) {
+	end := time.Unix(1545905245, 0)
+	start := end.Add(-time.Minute)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query_range", r.URL.Path)
+		assert.Equal(t, `sum(envoy_cluster_upstream_rq)`, r.URL.Query().Get("query"))
+		assert.Equal(t, "1545905185", r.URL.Query().Get("start"))
+		assert.Equal(t, "1545905245", r.URL.Query().Get("end"))
+		assert.Equal(t, "15", r.URL.Query().Get("step"))
+
+		json := `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1545905215,"1"],[1545905230,"NaN"],[1545905245,"3"]]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	prom, err := NewPrometheusProvider(flaggerv1.MetricTemplateProvider{Type: "prometheus", Address: ts.URL}, nil)
+	require.NoError(t, err)
+
+	values, err := prom.RunRangeQuery(`sum(envoy_cluster_upstream_rq)`, start, end, 15*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 3}, values)
+}
+
+func TestPrometheusProvider_RunQueryWithTenantHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "sum(envoy_cluster_upstream_rq)", r.URL.Query().Get("query"))
+		assert.Equal(t, "true", r.URL.Query().Get("partial_response"))
+		assert.Equal(t, "team-a|team-b", r.Header.Get("X-Scope-OrgID"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	provider := flaggerv1.MetricTemplateProvider{
+		Type:      "prometheus",
+		Address:   ts.URL + "/prometheus",
+		SecretRef: &corev1.LocalObjectReference{Name: "mimir"},
+		Headers: []flaggerv1.MetricTemplateProviderParam{
+			{Name: "X-Scope-OrgID", SecretKey: "tenant"},
+		},
+		QueryParams: []flaggerv1.MetricTemplateProviderParam{
+			{Name: "partial_response", Value: "true"},
+		},
+	}
+
+	prom, err := NewPrometheusProvider(provider, map[string][]byte{"tenant": []byte("team-a|team-b")})
+	require.NoError(t, err)
+
+	val, err := prom.RunQuery("sum(envoy_cluster_upstream_rq)")
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), val)
+
+	_, err = NewPrometheusProvider(provider, map[string][]byte{"org": []byte("team-a")})
+	require.Error(t, err)
+}
+
+func TestPrometheusProvider_RunQueryWithThanosOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "false", r.URL.Query().Get("partial_response"))
+		assert.Equal(t, "true", r.URL.Query().Get("dedup"))
+		assert.Equal(t, "0s", r.URL.Query().Get("max_source_resolution"))
+
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	provider := flaggerv1.MetricTemplateProvider{
+		Type:    "prometheus",
+		Address: ts.URL,
+		QueryParams: []flaggerv1.MetricTemplateProviderParam{
+			{Name: "partial_response", Value: "true"},
+		},
+		Thanos: &flaggerv1.ThanosOptions{MaxSourceResolution: "raw"},
+	}
+
+	prom, err := NewPrometheusProvider(provider, nil)
+	require.NoError(t, err)
+
+	val, err := prom.RunQuery("sum(envoy_cluster_upstream_rq)")
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), val)
+
+	provider.Thanos.MaxSourceResolution = "1d"
+	_, err = NewPrometheusProvider(provider, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"time"
+
+	"github.com/fluxcd/flagger/pkg/logger"
+)
+
+// redactProvider wraps the provider to remove the credentials
+// from the errors that end up in the logs and events
+func redactProvider(p Interface, credentials map[string][]byte) Interface {
+	rp := redactedProvider{
+		Interface: p,
+		secrets:   credentialValues(credentials),
+	}
+	if rangeProvider, ok := p.(RangeInterface); ok {
+		return &redactedRangeProvider{redactedProvider: rp, rangeInterface: rangeProvider}
+	}
+	return &rp
+}
+
+func credentialValues(credentials map[string][]byte) []string {
+	secrets := make([]string, 0, len(credentials))
+	for _, v := range credentials {
+		secrets = append(secrets, string(v))
+	}
+	return secrets
+}
+
+type redactedProvider struct {
+	Interface
+	secrets []string
+}
+
+func (p *redactedProvider) RunQuery(query string) (float64, error) {
+	value, err := p.Interface.RunQuery(query)
+	return value, logger.RedactError(err, p.secrets...)
+}
+
+func (p *redactedProvider) IsOnline() (bool, error) {
+	ok, err := p.Interface.IsOnline()
+	return ok, logger.RedactError(err, p.secrets...)
+}
+
+type redactedRangeProvider struct {
+	redactedProvider
+	rangeInterface RangeInterface
+}
+
+func (p *redactedRangeProvider) RunRangeQuery(query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	values, err := p.rangeInterface.RunRangeQuery(query, start, end, step)
+	return values, logger.RedactError(err, p.secrets...)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestFactory_ProviderRedactsCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("invalid password s3cr3t-p4ss"))
+	}))
+	defer ts.Close()
+
+	p, err := Factory{}.Provider("1m", flaggerv1.MetricTemplateProvider{Type: "prometheus", Address: ts.URL},
+		map[string][]byte{"username": []byte("flagger"), "password": []byte("s3cr3t-p4ss")})
+	require.NoError(t, err)
+
+	_, err = p.RunQuery("vector(1)")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cr3t-p4ss")
+	assert.Contains(t, err.Error(), "invalid password xxxxx")
+	assert.True(t, IsTransient(err))
+
+	_, ok := p.(RangeInterface)
+	assert.True(t, ok)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"time"
+)
+
+// RetryPolicy retries the queries that failed with a transient error,
+// the delay between retries starts at Backoff and doubles after each retry
+// and no retry is made if it would start after the Timeout
+type RetryPolicy struct {
+	// Retries is the max number of retries of a query
+	Retries int
+
+	// Backoff is the delay before the first retry
+	Backoff time.Duration
+
+	// Timeout is the max duration of a query including its retries
+	Timeout time.Duration
+}
+
+// Provider wraps the provider to retry its queries, onRetry is called
+// with the transient error before each retry
+func (r RetryPolicy) Provider(p Interface, onRetry func(err error, backoff time.Duration)) Interface {
+	if r.Retries <= 0 {
+		return p
+	}
+
+	rp := retryProvider{
+		Interface: p,
+		policy:    r,
+		onRetry:   onRetry,
+		now:       time.Now,
+		sleep:     time.Sleep,
+	}
+	if rangeProvider, ok := p.(RangeInterface); ok {
+		return &retryRangeProvider{retryProvider: rp, rangeInterface: rangeProvider}
+	}
+	return &rp
+}
+
+type retryProvider struct {
+	Interface
+	policy  RetryPolicy
+	onRetry func(err error, backoff time.Duration)
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+// do runs the query until it succeeds, fails with a permanent error
+// or the retries are exhausted
+func (p *retryProvider) do(query func() error) error {
+	deadline := p.now().Add(p.policy.Timeout)
+	backoff := p.policy.Backoff
+	for retry := 0; ; retry++ {
+		err := query()
+		if err == nil || !IsTransient(err) || retry >= p.policy.Retries {
+			return err
+		}
+		if p.policy.Timeout > 0 && p.now().Add(backoff).After(deadline) {
+			return err
+		}
+
+		if p.onRetry != nil {
+			p.onRetry(err, backoff)
+		}
+		p.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (p *retryProvider) RunQuery(query string) (float64, error) {
+	var value float64
+	err := p.do(func() (err error) {
+		value, err = p.Interface.RunQuery(query)
+		return
+	})
+	return value, err
+}
+
+type retryRangeProvider struct {
+	retryProvider
+	rangeInterface RangeInterface
+}
+
+func (p *retryRangeProvider) RunRangeQuery(query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	var values []float64
+	err := p.do(func() (err error) {
+		values, err = p.rangeInterface.RunRangeQuery(query, start, end, step)
+		return
+	})
+	return values, err
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingProvider fails with the given errors before succeeding
+type failingProvider struct {
+	errs    []error
+	queries int
+}
+
+func (p *failingProvider) RunQuery(_ string) (float64, error) {
+	p.queries++
+	if p.queries <= len(p.errs) {
+		return 0, p.errs[p.queries-1]
+	}
+	return 1, nil
+}
+
+func (p *failingProvider) IsOnline() (bool, error) {
+	return true, nil
+}
+
+func newTestRetryProvider(policy RetryPolicy, p Interface) (*retryProvider, *[]time.Duration) {
+	var sleeps []time.Duration
+	now := time.Now()
+	rp := policy.Provider(p, nil).(*retryProvider)
+	rp.now = func() time.Time { return now }
+	rp.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+	}
+	return rp, &sleeps
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(newResponseError(http.StatusServiceUnavailable, nil)))
+	assert.True(t, IsTransient(newResponseError(http.StatusTooManyRequests, nil)))
+	assert.True(t, IsTransient(fmt.Errorf("request failed: %w", context.DeadlineExceeded)))
+	assert.False(t, IsTransient(newResponseError(http.StatusBadRequest, nil)))
+	assert.False(t, IsTransient(ErrNoValuesFound))
+	assert.False(t, IsTransient(errors.New("parse error")))
+	assert.False(t, IsTransient(nil))
+}
+
+func TestRetryPolicy_RunQuery(t *testing.T) {
+	p := &failingProvider{errs: []error{
+		newResponseError(http.StatusBadGateway, []byte("bad gateway")),
+		newResponseError(http.StatusTooManyRequests, []byte("throttled")),
+	}}
+	rp, sleeps := newTestRetryProvider(RetryPolicy{Retries: 3, Backoff: time.Second, Timeout: time.Minute}, p)
+
+	val, err := rp.RunQuery("test")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), val)
+	assert.Equal(t, 3, p.queries)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *sleeps)
+}
+
+func TestRetryPolicy_Limits(t *testing.T) {
+	transient := newResponseError(http.StatusInternalServerError, nil)
+
+	// permanent errors are not retried
+	p := &failingProvider{errs: []error{ErrNoValuesFound}}
+	rp, _ := newTestRetryProvider(RetryPolicy{Retries: 3, Backoff: time.Second, Timeout: time.Minute}, p)
+	_, err := rp.RunQuery("test")
+	assert.ErrorIs(t, err, ErrNoValuesFound)
+	assert.Equal(t, 1, p.queries)
+
+	// max retries
+	p = &failingProvider{errs: []error{transient, transient, transient}}
+	rp, _ = newTestRetryProvider(RetryPolicy{Retries: 2, Backoff: time.Second, Timeout: time.Minute}, p)
+	_, err = rp.RunQuery("test")
+	assert.Error(t, err)
+	assert.Equal(t, 3, p.queries)
+
+	// retries are not started after the timeout
+	p = &failingProvider{errs: []error{transient, transient, transient}}
+	rp, sleeps := newTestRetryProvider(RetryPolicy{Retries: 3, Backoff: 10 * time.Second, Timeout: 20 * time.Second}, p)
+	_, err = rp.RunQuery("test")
+	assert.Error(t, err)
+	assert.Equal(t, 2, p.queries)
+	assert.Equal(t, []time.Duration{10 * time.Second}, *sleeps)
+
+	// no retries
+	fp := &failingProvider{}
+	assert.Same(t, fp, RetryPolicy{}.Provider(fp, nil))
+}
@@ -1,3 +1,6 @@
+//go:build !no_stackdriver
+// +build !no_stackdriver
+
 /*
 Copyright 2021 The Flux authors
 
@@ -19,29 +22,64 @@ package providers
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"time"
 
 	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerProvider("stackdriver", func(metricInterval string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewStackDriverProvider(provider, credentials, metricInterval)
+	})
+}
+
+const (
+	stackdriverMQL    = "mql"
+	stackdriverPromQL = "promql"
+	stackdriverFilter = "filter"
+
+	// https://cloud.google.com/stackdriver/docs/managed-prometheus/query-api-ui
+	stackdriverPrometheusAddress = "https://monitoring.googleapis.com/v1/%s/location/global/prometheus"
+	stackdriverReadScope         = "https://www.googleapis.com/auth/monitoring.read"
+
+	// stackdriverMinFilterWindow is the shortest window of the filter queries,
+	// the Cloud Monitoring metrics are usually sampled every minute
+	stackdriverMinFilterWindow = 5 * time.Minute
+)
+
 type StackDriverProvider struct {
 	client  *monitoring.QueryClient
 	project string
+
+	queryLanguage string
+	metricClient  *monitoring.MetricClient
+	filterWindow  time.Duration
+	prometheus    *PrometheusProvider
 }
 
-// NewStackDriverProvider takes a provider spec and credential map and
-// returns a StackDriverProvider ready to execute queries against the
+// NewStackDriverProvider takes a provider spec, the credential map and the metric interval and
+// returns a StackDriverProvider ready to execute MQL, PromQL or filter queries against the
 // Cloud Monitoring API
 func NewStackDriverProvider(provider flaggerv1.MetricTemplateProvider,
 	credentials map[string][]byte,
+	metricInterval string,
 ) (*StackDriverProvider, error) {
-	stackd := &StackDriverProvider{}
+	stackd := &StackDriverProvider{queryLanguage: stackdriverMQL}
+	if provider.Stackdriver != nil && provider.Stackdriver.QueryLanguage != "" {
+		stackd.queryLanguage = provider.Stackdriver.QueryLanguage
+	}
+
 	var saKey []byte
 	if provider.SecretRef != nil {
 		if project, ok := credentials["project"]; ok {
@@ -55,27 +93,123 @@ func NewStackDriverProvider(provider flaggerv1.MetricTemplateProvider,
 		}
 	}
 
-	var client *monitoring.QueryClient
+	var opts []option.ClientOption
+	if saKey != nil {
+		opts = append(opts, option.WithCredentialsJSON(saKey))
+	}
+
 	var err error
 	ctx := context.Background()
 
+	switch stackd.queryLanguage {
+	case stackdriverMQL:
+		stackd.client, err = monitoring.NewQueryClient(ctx, opts...)
+	case stackdriverFilter:
+		stackd.filterWindow = stackdriverMinFilterWindow
+		if d, err := time.ParseDuration(metricInterval); err == nil && d > stackd.filterWindow {
+			stackd.filterWindow = d
+		}
+		stackd.metricClient, err = monitoring.NewMetricClient(ctx, opts...)
+	case stackdriverPromQL:
+		stackd.prometheus, err = newStackdriverPrometheus(ctx, provider, stackd.project, saKey)
+	default:
+		return nil, fmt.Errorf("%s query language %s is not supported, use one of mql, promql or filter",
+			provider.Type, stackd.queryLanguage)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return stackd, nil
+}
+
+// newStackdriverPrometheus returns a Prometheus client for the Cloud Monitoring PromQL API
+// authenticated with the service account key or the workload identity
+func newStackdriverPrometheus(ctx context.Context, provider flaggerv1.MetricTemplateProvider,
+	project string, saKey []byte) (*PrometheusProvider, error) {
+	address := provider.Address
+	if address == "" {
+		if project == "" {
+			return nil, fmt.Errorf("%s promql queries require a secret with the project id", provider.Type)
+		}
+		address = fmt.Sprintf(stackdriverPrometheusAddress, project)
+	}
+
+	promURL, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("%s address %s is not a valid URL", provider.Type, address)
+	}
+
+	var creds *google.Credentials
 	if saKey != nil {
-		client, err = monitoring.NewQueryClient(ctx, option.WithCredentialsJSON(saKey))
+		creds, err = google.CredentialsFromJSON(ctx, saKey, stackdriverReadScope)
 	} else {
-		client, err = monitoring.NewQueryClient(ctx)
+		creds, err = google.FindDefaultCredentials(ctx, stackdriverReadScope)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s credentials are not valid: %w", provider.Type, err)
+	}
+
+	return &PrometheusProvider{
+		timeout: 5 * time.Second,
+		url:     *promURL,
+		client:  oauth2.NewClient(ctx, creds.TokenSource),
+	}, nil
+}
+
+// RunQuery executes the query with the configured query language
+func (s *StackDriverProvider) RunQuery(query string) (float64, error) {
+	switch s.queryLanguage {
+	case stackdriverPromQL:
+		return s.prometheus.RunQuery(query)
+	case stackdriverFilter:
+		return s.runFilterQuery(query)
 	}
+	return s.runMQLQuery(query)
+}
 
+// runFilterQuery lists the time series matching the monitoring filter
+// and returns the latest point of the first time series
+func (s *StackDriverProvider) runFilterQuery(filter string) (float64, error) {
+	ctx := context.Background()
+	now := time.Now()
+	req := &monitoringpb.ListTimeSeriesRequest{
+		Name:   s.project,
+		Filter: filter,
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(now.Add(-s.filterWindow)),
+			EndTime:   timestamppb.New(now),
+		},
+		View: monitoringpb.ListTimeSeriesRequest_FULL,
+	}
+
+	it := s.metricClient.ListTimeSeries(ctx, req)
+
+	resp, err := it.Next()
+	if err == iterator.Done {
+		return 0, fmt.Errorf("invalid response: %s: %w", resp, ErrNoValuesFound)
+	}
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("error requesting stackdriver: %s", err)
 	}
 
-	stackd.client = client
-	return stackd, nil
+	// the points are returned in reverse time order
+	if len(resp.Points) < 1 {
+		return 0, fmt.Errorf("invalid response: %s: %w", resp.String(), ErrNoValuesFound)
+	}
+
+	switch v := resp.Points[0].GetValue().GetValue().(type) {
+	case *monitoringpb.TypedValue_DoubleValue:
+		return v.DoubleValue, nil
+	case *monitoringpb.TypedValue_Int64Value:
+		return float64(v.Int64Value), nil
+	}
+	return 0, fmt.Errorf("invalid response: %s: value is not numeric", resp.String())
 }
 
-// RunQuery executes Monitoring Query Language(MQL) queries against the
+// runMQLQuery executes Monitoring Query Language(MQL) queries against the
 // Cloud Monitoring API
-func (s *StackDriverProvider) RunQuery(query string) (float64, error) {
+func (s *StackDriverProvider) runMQLQuery(query string) (float64, error) {
 	ctx := context.Background()
 	req := &monitoringpb.QueryTimeSeriesRequest{
 		Name:  s.project,
@@ -114,20 +248,24 @@ func (s *StackDriverProvider) RunQuery(query string) (float64, error) {
 	return values[0].GetDoubleValue(), nil
 }
 
-// IsOnline calls QueryTimeSeries method with the empty query
+// IsOnline calls QueryTimeSeries or ListTimeSeries method with the empty query
 // and returns an error if the returned status code is NOT grpc.InvalidArgument.
 // For example, if the flagger does not the authorization scope `https://www.googleapis.com/auth/monitoring.read`,
 // the returned status code would be grpc.PermissionDenied
 func (s *StackDriverProvider) IsOnline() (bool, error) {
 	ctx := context.Background()
-	req := &monitoringpb.QueryTimeSeriesRequest{
-		Name:  s.project,
-		Query: "",
-	}
 
-	it := s.client.QueryTimeSeries(ctx, req)
+	var err error
+	switch s.queryLanguage {
+	case stackdriverPromQL:
+		// PromQL queries are checked with the Prometheus online query
+		return s.prometheus.IsOnline()
+	case stackdriverFilter:
+		_, err = s.metricClient.ListTimeSeries(ctx, &monitoringpb.ListTimeSeriesRequest{Name: s.project}).Next()
+	default:
+		_, err = s.client.QueryTimeSeries(ctx, &monitoringpb.QueryTimeSeriesRequest{Name: s.project}).Next()
+	}
 
-	_, err := it.Next()
 	if err == nil {
 		return true, nil
 	}
@@ -1,3 +1,6 @@
+//go:build !no_stackdriver
+// +build !no_stackdriver
+
 /*
 Copyright 2021 The Flux authors
 
@@ -43,6 +46,23 @@ var clientOpt option.ClientOption
 
 var mockQueryPolicy mockQueryPolicyServer
 
+var mockMetric mockMetricServer
+
+type mockMetricServer struct {
+	monitoringpb.MetricServiceServer
+
+	err error
+
+	resp *monitoringpb.ListTimeSeriesResponse
+}
+
+func (m *mockMetricServer) ListTimeSeries(ctx context.Context, req *monitoringpb.ListTimeSeriesRequest) (*monitoringpb.ListTimeSeriesResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.resp, nil
+}
+
 type mockQueryPolicyServer struct {
 	monitoringpb.QueryServiceServer
 
@@ -71,6 +91,7 @@ func (s *stackdriverClientMock) QueryTimeSeries(ctx context.Context, req *monito
 func TestMain(m *testing.M) {
 	serv := grpc.NewServer()
 	monitoringpb.RegisterQueryServiceServer(serv, &mockQueryPolicy)
+	monitoringpb.RegisterMetricServiceServer(serv, &mockMetric)
 	lis, err := net.Listen("tcp", "localhost:0")
 	if err != nil {
 		log.Fatal(err)
@@ -91,10 +112,26 @@ func TestNewStackDriverProvider(t *testing.T) {
 			SecretRef: &corev1.LocalObjectReference{
 				Name: "test-secret",
 			},
-		}, map[string][]byte{})
+		}, map[string][]byte{}, "1m")
 
 		assert.Error(t, err, "error expected since project id is not given")
 	})
+
+	t.Run("error when query language isn't supported", func(t *testing.T) {
+		_, err := NewStackDriverProvider(flaggerv1.MetricTemplateProvider{
+			Stackdriver: &flaggerv1.StackdriverOptions{QueryLanguage: "sql"},
+		}, map[string][]byte{}, "1m")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("error when promql project id isn't provided", func(t *testing.T) {
+		_, err := NewStackDriverProvider(flaggerv1.MetricTemplateProvider{
+			Stackdriver: &flaggerv1.StackdriverOptions{QueryLanguage: "promql"},
+		}, map[string][]byte{}, "1m")
+
+		assert.Error(t, err)
+	})
 }
 
 func TestStackDriverProvider_IsOnline(t *testing.T) {
@@ -188,3 +225,56 @@ func TestStackDriverProvider_RunQuery(t *testing.T) {
 		assert.ErrorIs(t, err, ErrNoValuesFound)
 	})
 }
+
+func TestStackDriverProvider_RunFilterQuery(t *testing.T) {
+	query := `metric.type="istio.io/service/server/request_count" AND resource.labels.namespace_name="test"`
+
+	t.Run("ok", func(t *testing.T) {
+		mockMetric.err = nil
+		mockMetric.resp = &monitoringpb.ListTimeSeriesResponse{
+			TimeSeries: []*monitoringpb.TimeSeries{
+				{
+					Points: []*monitoringpb.Point{
+						{Value: &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: 42}}},
+						{Value: &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: 7}}},
+					},
+				},
+			},
+		}
+
+		c, err := monitoring.NewMetricClient(context.Background(), clientOpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := StackDriverProvider{metricClient: c, queryLanguage: stackdriverFilter, filterWindow: stackdriverMinFilterWindow}
+		actual, err := p.RunQuery(query)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(42), actual)
+	})
+
+	t.Run("no values", func(t *testing.T) {
+		mockMetric.err = nil
+		mockMetric.resp = &monitoringpb.ListTimeSeriesResponse{}
+
+		c, err := monitoring.NewMetricClient(context.Background(), clientOpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := StackDriverProvider{metricClient: c, queryLanguage: stackdriverFilter, filterWindow: stackdriverMinFilterWindow}
+		_, err = p.RunQuery(query)
+		assert.ErrorIs(t, err, ErrNoValuesFound)
+	})
+
+	t.Run("online", func(t *testing.T) {
+		mockMetric.err = status.Error(codes.InvalidArgument, "invalid arg")
+
+		c, err := monitoring.NewMetricClient(context.Background(), clientOpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := StackDriverProvider{metricClient: c, queryLanguage: stackdriverFilter}
+		actual, err := p.IsOnline()
+		assert.NoError(t, err)
+		assert.True(t, actual)
+	})
+}
@@ -0,0 +1,284 @@
+//go:build !no_victoriametrics
+// +build !no_victoriametrics
+
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func init() {
+	registerProvider("victoriametrics", func(_ string, provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (Interface, error) {
+		return NewVictoriaMetricsProvider(provider, credentials)
+	})
+}
+
+const (
+	victoriaMetricsOnlineQuery = "vector(1)"
+
+	victoriaMetricsTenantSecretKey      = "tenant"
+	victoriaMetricsExtraLabelsSecretKey = "extraLabels"
+)
+
+// VictoriaMetricsProvider executes MetricsQL queries against vmselect or single-node VictoriaMetrics
+type VictoriaMetricsProvider struct {
+	timeout     time.Duration
+	url         url.URL
+	tenant      string
+	queryParams url.Values
+	headers     http.Header
+	username    string
+	password    string
+	client      *http.Client
+}
+
+// NewVictoriaMetricsProvider takes a provider spec and the credentials map,
+// validates the address and returns a VictoriaMetrics client ready to execute queries against the API.
+// Query args present in the address (e.g. extra_label, extra_filters[], nocache) are sent with every query.
+// The credentials may contain the basic-auth username and password, a bearer token or
+// the OAuth2 client credentials,
+// the tenant (accountID[:projectID]) of a cluster installation and a comma-separated list of
+// extra labels that are enforced on every query.
+// The extra headers and query params declared in the provider spec are sent with every query.
+func NewVictoriaMetricsProvider(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (*VictoriaMetricsProvider, error) {
+	vmURL, err := url.Parse(provider.Address)
+	if provider.Address == "" || err != nil {
+		return nil, fmt.Errorf("%s address %s is not a valid URL", provider.Type, provider.Address)
+	}
+
+	vm := VictoriaMetricsProvider{
+		timeout:     5 * time.Second,
+		url:         *vmURL,
+		queryParams: vmURL.Query(),
+	}
+	vm.url.RawQuery = ""
+
+	client, tokenAuth, err := newHTTPClient(provider, credentials)
+	if err != nil {
+		return nil, err
+	}
+	vm.client = client
+
+	if provider.SecretRef != nil {
+		if !tokenAuth {
+			username, uok := credentials["username"]
+			password, pok := credentials["password"]
+			if uok != pok {
+				return nil, fmt.Errorf("%s credentials must contain both username and password", provider.Type)
+			}
+			vm.username = string(username)
+			vm.password = string(password)
+		}
+
+		if tenant, ok := credentials[victoriaMetricsTenantSecretKey]; ok {
+			vm.tenant = strings.TrimSpace(string(tenant))
+			if !isValidVictoriaMetricsTenant(vm.tenant) {
+				return nil, fmt.Errorf("%s tenant %s is not valid, expected accountID[:projectID]", provider.Type, vm.tenant)
+			}
+		}
+
+		if extraLabels, ok := credentials[victoriaMetricsExtraLabelsSecretKey]; ok {
+			for _, label := range strings.Split(string(extraLabels), ",") {
+				label = strings.TrimSpace(label)
+				if label == "" {
+					continue
+				}
+				if !strings.Contains(label, "=") {
+					return nil, fmt.Errorf("%s extra label %s is not valid, expected name=value", provider.Type, label)
+				}
+				vm.queryParams.Add("extra_label", label)
+			}
+		}
+	}
+
+	headers, err := providerHeaders(provider, credentials)
+	if err != nil {
+		return nil, err
+	}
+	vm.headers = headers
+
+	queryParams, err := providerQueryParams(provider, credentials)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range queryParams {
+		vm.queryParams[k] = append(vm.queryParams[k], v...)
+	}
+
+	return &vm, nil
+}
+
+// RunQuery executes the MetricsQL query and returns the last result as float64
+func (p *VictoriaMetricsProvider) RunQuery(query string) (float64, error) {
+	u, err := url.Parse("./api/v1/query")
+	if err != nil {
+		return 0, fmt.Errorf("url.Parse failed: %w", err)
+	}
+	u.Path = path.Join(p.url.Path, p.tenantPath(), u.Path)
+	u = p.url.ResolveReference(u)
+
+	params := url.Values{}
+	for k, v := range p.queryParams {
+		params[k] = append([]string{}, v...)
+	}
+	params.Set("query", p.trimQuery(query))
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("http.NewRequest failed: %w", err)
+	}
+
+	for k, v := range p.headers {
+		req.Header[k] = v
+	}
+
+	if p.username != "" && p.password != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), p.timeout)
+	defer cancel()
+
+	r, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer r.Body.Close()
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error reading body: %w", err)
+	}
+
+	if 400 <= r.StatusCode {
+		return 0, newResponseError(r.StatusCode, b)
+	}
+
+	var result prometheusResponse
+	err = json.Unmarshal(b, &result)
+	if err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	var value *float64
+	for _, v := range result.Data.Result {
+		if len(v.Value) < 2 {
+			continue
+		}
+		metricValue, ok := v.Value[1].(string)
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			return 0, err
+		}
+		value = &f
+	}
+	if value == nil || math.IsNaN(*value) {
+		return 0, fmt.Errorf("%w", ErrNoValuesFound)
+	}
+
+	return *value, nil
+}
+
+// IsOnline runs a simple MetricsQL query and returns an error if the API is unreachable
+func (p *VictoriaMetricsProvider) IsOnline() (bool, error) {
+	value, err := p.RunQuery(victoriaMetricsOnlineQuery)
+	if err != nil {
+		return false, fmt.Errorf("running query failed: %w", err)
+	}
+
+	if value != float64(1) {
+		return false, fmt.Errorf("value is not 1 for query: %s", victoriaMetricsOnlineQuery)
+	}
+
+	return true, nil
+}
+
+// tenantPath returns the vmselect path prefix for the configured tenant,
+// for single-node installations the tenant is empty and no prefix is added
+func (p *VictoriaMetricsProvider) tenantPath() string {
+	if p.tenant == "" {
+		return ""
+	}
+	return path.Join("select", p.tenant, "prometheus")
+}
+
+// trimQuery takes a MetricsQL query, strips the `#` comments and removes whitespace
+func (p *VictoriaMetricsProvider) trimQuery(query string) string {
+	var lines []string
+	for _, line := range strings.Split(query, "\n") {
+		lines = append(lines, stripMetricsQLComment(line))
+	}
+	space := regexp.MustCompile(`\s+`)
+	return strings.TrimSpace(space.ReplaceAllString(strings.Join(lines, "\n"), " "))
+}
+
+// stripMetricsQLComment removes the trailing `# comment` from a MetricsQL line,
+// ignoring `#` characters found inside quoted strings
+func stripMetricsQLComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && quote != 0:
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// isValidVictoriaMetricsTenant checks that the tenant has the accountID[:projectID] format
+func isValidVictoriaMetricsTenant(tenant string) bool {
+	parts := strings.Split(tenant, ":")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, part := range parts {
+		if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+//go:build !no_victoriametrics
+// +build !no_victoriametrics
+
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func TestNewVictoriaMetricsProvider(t *testing.T) {
+	provider := flaggerv1.MetricTemplateProvider{
+		Type:      "victoriametrics",
+		Address:   "http://vmselect:8481?extra_label=env=prod",
+		SecretRef: &corev1.LocalObjectReference{Name: "vm"},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		vm, err := NewVictoriaMetricsProvider(provider, map[string][]byte{
+			"tenant":      []byte("42:1"),
+			"extraLabels": []byte("team=payments, cluster=eu-1"),
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "http://vmselect:8481", vm.url.String())
+		assert.Equal(t, "42:1", vm.tenant)
+		assert.Equal(t, []string{"env=prod", "team=payments", "cluster=eu-1"}, vm.queryParams["extra_label"])
+	})
+
+	t.Run("invalid tenant", func(t *testing.T) {
+		_, err := NewVictoriaMetricsProvider(provider, map[string][]byte{"tenant": []byte("team-a")})
+		require.Error(t, err)
+	})
+
+	t.Run("invalid extra label", func(t *testing.T) {
+		_, err := NewVictoriaMetricsProvider(provider, map[string][]byte{"extraLabels": []byte("team")})
+		require.Error(t, err)
+	})
+
+	t.Run("missing password", func(t *testing.T) {
+		_, err := NewVictoriaMetricsProvider(provider, map[string][]byte{"username": []byte("flagger")})
+		require.Error(t, err)
+	})
+}
+
+func TestVictoriaMetricsProvider_RunQuery(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/select/42/prometheus/api/v1/query", r.URL.Path)
+			assert.Equal(t, `histogram_quantile(0.99, sum(rate(http_request_duration_seconds_bucket{path="/#"})) by (vmrange) )`,
+				r.URL.Query().Get("query"))
+			assert.Equal(t, []string{"env=prod", "team=payments"}, r.URL.Query()["extra_label"])
+			assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+			json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"0.25"]}]}}`
+			w.Write([]byte(json))
+		}))
+		defer ts.Close()
+
+		vm, err := NewVictoriaMetricsProvider(flaggerv1.MetricTemplateProvider{
+			Type:      "victoriametrics",
+			Address:   ts.URL + "?extra_label=env=prod",
+			SecretRef: &corev1.LocalObjectReference{Name: "vm"},
+		}, map[string][]byte{
+			"token":       []byte("secret"),
+			"tenant":      []byte("42"),
+			"extraLabels": []byte("team=payments"),
+		})
+		require.NoError(t, err)
+
+		query := `
+			# p99 latency per vmrange bucket
+			histogram_quantile(0.99,
+				sum(rate(http_request_duration_seconds_bucket{path="/#"})) # default rollup window
+				by (vmrange)
+			)`
+		val, err := vm.RunQuery(query)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0.25, val)
+	})
+
+	t.Run("no values", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1/query", r.URL.Path)
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}))
+		defer ts.Close()
+
+		vm, err := NewVictoriaMetricsProvider(flaggerv1.MetricTemplateProvider{
+			Type:    "victoriametrics",
+			Address: ts.URL,
+		}, nil)
+		require.NoError(t, err)
+
+		_, err = vm.RunQuery("sum(vm_rows)")
+		require.True(t, errors.Is(err, ErrNoValuesFound))
+	})
+}
+
+func TestVictoriaMetricsProvider_IsOnline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "vector(1)", r.URL.Query().Get("query"))
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"1"]}]}}`))
+	}))
+	defer ts.Close()
+
+	vm, err := NewVictoriaMetricsProvider(flaggerv1.MetricTemplateProvider{
+		Type:    "victoriametrics",
+		Address: ts.URL,
+	}, nil)
+	require.NoError(t, err)
+
+	ok, err := vm.IsOnline()
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
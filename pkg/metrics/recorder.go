@@ -17,7 +17,6 @@ limitations under the License.
 package metrics
 
 import (
-	"fmt"
 	"time"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -26,14 +25,24 @@ import (
 
 // Recorder records the canary analysis as Prometheus metrics
 type Recorder struct {
-	info     *prometheus.GaugeVec
-	duration *prometheus.HistogramVec
-	total    *prometheus.GaugeVec
-	status   *prometheus.GaugeVec
-	weight   *prometheus.GaugeVec
-	analysis *prometheus.GaugeVec
+	info              *prometheus.GaugeVec
+	duration          *prometheus.HistogramVec
+	total             *prometheus.GaugeVec
+	status            *prometheus.GaugeVec
+	weight            *prometheus.GaugeVec
+	analysis          *prometheus.GaugeVec
+	queryDuration     *prometheus.HistogramVec
+	webhookDuration   *prometheus.HistogramVec
+	routerErrors      *prometheus.CounterVec
+	promotionDuration *prometheus.HistogramVec
 }
 
+// Status label values of the query and webhook histograms
+const (
+	successStatus = "success"
+	errorStatus   = "error"
+)
+
 // NewRecorder creates a new recorder and registers the Prometheus metrics
 func NewRecorder(controller string, register bool) Recorder {
 	info := prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -74,6 +83,34 @@ func NewRecorder(controller string, register bool) Recorder {
 		Help:      "Last canary analysis result per metric",
 	}, []string{"name", "namespace", "metric"})
 
+	queryDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: controller,
+		Name:      "canary_metric_query_duration_seconds",
+		Help:      "Seconds spent querying the metrics provider per canary metric.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"name", "namespace", "metric", "status"})
+
+	webhookDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: controller,
+		Name:      "canary_webhook_duration_seconds",
+		Help:      "Seconds spent calling the canary webhooks.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"name", "namespace", "webhook", "type", "status"})
+
+	routerErrors := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: controller,
+		Name:      "canary_router_errors_total",
+		Help:      "Total number of failed router reconciliations and traffic updates.",
+	}, []string{"name", "namespace", "operation"})
+
+	// from one minute to eight hours
+	promotionDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: controller,
+		Name:      "canary_promotion_duration_seconds",
+		Help:      "Seconds from the start of the canary analysis to the end of the promotion.",
+		Buckets:   prometheus.ExponentialBuckets(60, 2, 10),
+	}, []string{"name", "namespace"})
+
 	if register {
 		prometheus.MustRegister(info)
 		prometheus.MustRegister(duration)
@@ -81,15 +118,23 @@ func NewRecorder(controller string, register bool) Recorder {
 		prometheus.MustRegister(status)
 		prometheus.MustRegister(weight)
 		prometheus.MustRegister(analysis)
+		prometheus.MustRegister(queryDuration)
+		prometheus.MustRegister(webhookDuration)
+		prometheus.MustRegister(routerErrors)
+		prometheus.MustRegister(promotionDuration)
 	}
 
 	return Recorder{
-		info:     info,
-		duration: duration,
-		total:    total,
-		status:   status,
-		weight:   weight,
-		analysis: analysis,
+		info:              info,
+		duration:          duration,
+		total:             total,
+		status:            status,
+		weight:            weight,
+		analysis:          analysis,
+		queryDuration:     queryDuration,
+		webhookDuration:   webhookDuration,
+		routerErrors:      routerErrors,
+		promotionDuration: promotionDuration,
 	}
 }
 
@@ -126,8 +171,47 @@ func (cr *Recorder) SetStatus(cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase)
 	cr.status.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace).Set(float64(status))
 }
 
-// SetWeight sets the weight values for primary and canary destinations
+// SetWeight sets the weight values for primary and canary destinations,
+// the primary weight is skipped when the primary name can't be derived
 func (cr *Recorder) SetWeight(cd *flaggerv1.Canary, primary int, canary int) {
-	cr.weight.WithLabelValues(fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name), cd.Namespace).Set(float64(primary))
+	if primaryName, err := cd.GetPrimaryName(cd.Spec.TargetRef.Name); err == nil {
+		cr.weight.WithLabelValues(primaryName, cd.Namespace).Set(float64(primary))
+	}
 	cr.weight.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace).Set(float64(canary))
 }
+
+// SetQueryDuration records the latency of a metric provider query
+func (cr *Recorder) SetQueryDuration(cd *flaggerv1.Canary, metricName string, duration time.Duration, err error) {
+	cr.queryDuration.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace, metricName, resultStatus(err)).Observe(duration.Seconds())
+}
+
+// SetWebhookDuration records the latency and the result of a webhook call
+func (cr *Recorder) SetWebhookDuration(cd *flaggerv1.Canary, webhook flaggerv1.CanaryWebhook, duration time.Duration, err error) {
+	hookType := webhook.Type
+	if hookType == "" {
+		hookType = flaggerv1.RolloutHook
+	}
+	cr.webhookDuration.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace, webhook.Name, string(hookType), resultStatus(err)).
+		Observe(duration.Seconds())
+}
+
+// IncRouterErrors increments the errors of the router operation e.g. SetRoutes
+func (cr *Recorder) IncRouterErrors(cd *flaggerv1.Canary, operation string) {
+	cr.routerErrors.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace, operation).Inc()
+}
+
+// SetPromotionDuration records the time from the start of the analysis run to the end of the promotion
+func (cr *Recorder) SetPromotionDuration(cd *flaggerv1.Canary) {
+	if cd.Status.RunStartTime.IsZero() {
+		return
+	}
+	cr.promotionDuration.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace).
+		Observe(time.Since(cd.Status.RunStartTime.Time).Seconds())
+}
+
+func resultStatus(err error) string {
+	if err != nil {
+		return errorStatus
+	}
+	return successStatus
+}
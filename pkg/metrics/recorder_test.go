@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+type fakeRouter struct {
+	err error
+}
+
+func (r fakeRouter) Initialize(canary *flaggerv1.Canary) error { return r.err }
+func (r fakeRouter) Reconcile(canary *flaggerv1.Canary) error  { return r.err }
+func (r fakeRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int, mirrored bool) error {
+	return r.err
+}
+func (r fakeRouter) GetRoutes(canary *flaggerv1.Canary) (int, int, bool, error) {
+	return 100, 0, false, nil
+}
+func (r fakeRouter) GetServiceDrift(canary *flaggerv1.Canary) ([]string, error) {
+	return nil, r.err
+}
+func (r fakeRouter) Finalize(canary *flaggerv1.Canary) error { return r.err }
+
+type fakeProvider struct{}
+
+func (fakeProvider) RunQuery(query string) (float64, error) { return 1, nil }
+func (fakeProvider) IsOnline() (bool, error)                { return true, nil }
+
+func newTestCanary() *flaggerv1.Canary {
+	cd := &flaggerv1.Canary{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"}}
+	cd.Spec.TargetRef = flaggerv1.LocalObjectReference{Kind: "Deployment", Name: "podinfo"}
+	return cd
+}
+
+func TestRecorder_RouterErrors(t *testing.T) {
+	recorder := NewRecorder("flagger", false)
+	cd := newTestCanary()
+
+	require.NoError(t, recorder.MeshRouter(fakeRouter{}).SetRoutes(cd, 90, 10, false))
+	require.Error(t, recorder.MeshRouter(fakeRouter{err: errors.New("conflict")}).SetRoutes(cd, 90, 10, false))
+	require.Error(t, recorder.KubernetesRouter(fakeRouter{err: errors.New("conflict")}).Reconcile(cd))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.routerErrors.WithLabelValues("podinfo", "default", "SetRoutes")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.routerErrors.WithLabelValues("podinfo", "default", "Reconcile")))
+}
+
+func TestRecorder_Durations(t *testing.T) {
+	recorder := NewRecorder("flagger", false)
+	cd := newTestCanary()
+
+	_, err := recorder.Provider(cd, "error-rate", fakeProvider{}).RunQuery("sum(errors)")
+	require.NoError(t, err)
+	assert.Equal(t, 1, testutil.CollectAndCount(recorder.queryDuration))
+
+	recorder.SetWebhookDuration(cd, flaggerv1.CanaryWebhook{Name: "load-test"}, time.Second, nil)
+	recorder.SetWebhookDuration(cd, flaggerv1.CanaryWebhook{Name: "load-test"}, time.Second, errors.New("timeout"))
+	assert.Equal(t, 2, testutil.CollectAndCount(recorder.webhookDuration))
+
+	// the promotion duration is not recorded without an analysis run
+	recorder.SetPromotionDuration(cd)
+	assert.Equal(t, 0, testutil.CollectAndCount(recorder.promotionDuration))
+	cd.Status.RunStartTime = metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	recorder.SetPromotionDuration(cd)
+	assert.Equal(t, 1, testutil.CollectAndCount(recorder.promotionDuration))
+}
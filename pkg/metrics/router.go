@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// MeshRouter wraps the mesh router to count the errors of each operation
+func (cr *Recorder) MeshRouter(r router.Interface) router.Interface {
+	return &meshRouter{Interface: r, recorder: cr}
+}
+
+// KubernetesRouter wraps the Kubernetes router to count the errors of each operation
+func (cr *Recorder) KubernetesRouter(r router.KubernetesRouter) router.KubernetesRouter {
+	return &kubernetesRouter{KubernetesRouter: r, recorder: cr}
+}
+
+// countError increments the router errors of the operation if it failed
+func (cr *Recorder) countError(cd *flaggerv1.Canary, operation string, err error) error {
+	if err != nil {
+		cr.IncRouterErrors(cd, operation)
+	}
+	return err
+}
+
+type meshRouter struct {
+	router.Interface
+	recorder *Recorder
+}
+
+func (r *meshRouter) Reconcile(canary *flaggerv1.Canary) error {
+	return r.recorder.countError(canary, "Reconcile", r.Interface.Reconcile(canary))
+}
+
+func (r *meshRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int, mirrored bool) error {
+	return r.recorder.countError(canary, "SetRoutes", r.Interface.SetRoutes(canary, primaryWeight, canaryWeight, mirrored))
+}
+
+func (r *meshRouter) Finalize(canary *flaggerv1.Canary) error {
+	return r.recorder.countError(canary, "Finalize", r.Interface.Finalize(canary))
+}
+
+type kubernetesRouter struct {
+	router.KubernetesRouter
+	recorder *Recorder
+}
+
+func (r *kubernetesRouter) Initialize(canary *flaggerv1.Canary) error {
+	return r.recorder.countError(canary, "Initialize", r.KubernetesRouter.Initialize(canary))
+}
+
+func (r *kubernetesRouter) Reconcile(canary *flaggerv1.Canary) error {
+	return r.recorder.countError(canary, "Reconcile", r.KubernetesRouter.Reconcile(canary))
+}
+
+func (r *kubernetesRouter) Finalize(canary *flaggerv1.Canary) error {
+	return r.recorder.countError(canary, "Finalize", r.KubernetesRouter.Finalize(canary))
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Alternative is the alternative hypothesis of a statistical test
+type Alternative string
+
+const (
+	// GreaterAlternative tests if the first sample is stochastically greater than the second
+	GreaterAlternative Alternative = "greater"
+	// LessAlternative tests if the first sample is stochastically less than the second
+	LessAlternative Alternative = "less"
+	// TwoSidedAlternative tests if the two samples come from different distributions
+	TwoSidedAlternative Alternative = "two-sided"
+)
+
+// MannWhitneyUTest runs the Mann-Whitney U rank test of the x and y samples
+// and returns the U statistic of x and the p-value of the alternative hypothesis.
+// The p-value is computed with the normal approximation corrected for ties and continuity.
+func MannWhitneyUTest(x, y []float64, alternative Alternative) (u float64, p float64, err error) {
+	n1, n2 := float64(len(x)), float64(len(y))
+	if n1 == 0 || n2 == 0 {
+		return 0, 0, fmt.Errorf("samples must not be empty")
+	}
+
+	type sample struct {
+		value float64
+		first bool
+	}
+	samples := make([]sample, 0, len(x)+len(y))
+	for _, v := range x {
+		samples = append(samples, sample{value: v, first: true})
+	}
+	for _, v := range y {
+		samples = append(samples, sample{value: v})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].value < samples[j].value
+	})
+
+	// rank the samples, tied values get the average of their ranks
+	var r1, ties float64
+	for i := 0; i < len(samples); {
+		j := i
+		for j < len(samples) && samples[j].value == samples[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if samples[k].first {
+				r1 += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n := n1 + n2
+	u = r1 - n1*(n1+1)/2
+	mu := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		// all values are equal
+		return u, 1, nil
+	}
+
+	switch alternative {
+	case GreaterAlternative:
+		p = 1 - normalCDF((u-mu-0.5)/sigma)
+	case LessAlternative:
+		p = normalCDF((u - mu + 0.5) / sigma)
+	case TwoSidedAlternative:
+		p = math.Min(1, 2*(1-normalCDF((math.Abs(u-mu)-0.5)/sigma)))
+	default:
+		return 0, 0, fmt.Errorf("alternative %s not supported", alternative)
+	}
+
+	return u, p, nil
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMannWhitneyUTest(t *testing.T) {
+	canary := []float64{19, 22, 16, 29, 24}
+	primary := []float64{20, 11, 17, 12}
+
+	u, p, err := MannWhitneyUTest(canary, primary, GreaterAlternative)
+	require.NoError(t, err)
+	assert.Equal(t, float64(17), u)
+	assert.InDelta(t, 0.0556, p, 0.001)
+
+	_, p, err = MannWhitneyUTest(canary, primary, LessAlternative)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.9669, p, 0.001)
+
+	_, p, err = MannWhitneyUTest(canary, primary, TwoSidedAlternative)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.1111, p, 0.001)
+
+	t.Run("ties", func(t *testing.T) {
+		u, p, err := MannWhitneyUTest([]float64{1, 1, 1}, []float64{1, 1}, GreaterAlternative)
+		require.NoError(t, err)
+		assert.Equal(t, float64(3), u)
+		assert.Equal(t, float64(1), p)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := MannWhitneyUTest(nil, primary, GreaterAlternative)
+		require.Error(t, err)
+
+		_, _, err = MannWhitneyUTest(canary, primary, "other")
+		require.Error(t, err)
+	})
+}
@@ -36,6 +36,11 @@ func NewFactory(url string, proxy string, username string, channel string) *Fact
 	}
 }
 
+// Providers returns the supported alert provider types
+func Providers() []string {
+	return []string{"slack", "discord", "rocket", "msteams", "gchat"}
+}
+
 func (f Factory) Notifier(provider string) (Interface, error) {
 	if f.URL == "" {
 		return &NopNotifier{}, nil
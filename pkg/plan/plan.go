@@ -0,0 +1,417 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plan renders the objects Flagger would create or update for a canary
+// without applying them, the canary and router controllers run against in-memory
+// clients that load the live objects on first access.
+package plan
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+	flaggerfake "github.com/fluxcd/flagger/pkg/client/clientset/versioned/fake"
+	flaggerscheme "github.com/fluxcd/flagger/pkg/client/clientset/versioned/scheme"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// Fetcher returns the live object of the resource, or a NotFound error if it doesn't exist
+type Fetcher func(gvr schema.GroupVersionResource, namespace string, name string) (runtime.Object, error)
+
+// Options are the controller settings that change the generated objects
+type Options struct {
+	// MeshProvider is the default provider, the canary provider takes precedence
+	MeshProvider             string
+	Labels                   []string
+	IncludeLabelPrefix       []string
+	IngressAnnotationsPrefix string
+	IngressClass             string
+	ClusterDomain            string
+	ConfigTracking           bool
+}
+
+// Action is the change Flagger would make to an object
+type Action string
+
+const (
+	Create    Action = "create"
+	Update    Action = "update"
+	Delete    Action = "delete"
+	Unchanged Action = "unchanged"
+)
+
+// Change is an object generated for the canary
+type Change struct {
+	Action    Action
+	Kind      string
+	Namespace string
+	Name      string
+	// Diff is the unified diff of the live and the planned YAML,
+	// the status, the server side metadata and the secret values are left out
+	Diff string
+}
+
+// Planner renders the objects generated for the canaries
+type Planner struct {
+	kubeLive    Fetcher
+	flaggerLive Fetcher
+	options     Options
+	logger      *zap.SugaredLogger
+}
+
+// NewPlanner creates a planner that loads the live Kubernetes objects with kubeLive
+// and the Flagger, mesh and ingress objects with flaggerLive
+func NewPlanner(kubeLive Fetcher, flaggerLive Fetcher, options Options, logger *zap.SugaredLogger) *Planner {
+	return &Planner{
+		kubeLive:    kubeLive,
+		flaggerLive: flaggerLive,
+		options:     options,
+		logger:      logger,
+	}
+}
+
+// DynamicFetcher returns a fetcher that gets the live objects with the dynamic client
+// and converts them to the types registered in the scheme
+func DynamicFetcher(client dynamic.Interface, scheme *runtime.Scheme) Fetcher {
+	return func(gvr schema.GroupVersionResource, namespace string, name string) (runtime.Object, error) {
+		u, err := client.Resource(gvr).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		obj, err := scheme.New(u.GroupVersionKind())
+		if err != nil {
+			return nil, err
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), obj); err != nil {
+			return nil, fmt.Errorf("converting %s %s.%s failed: %w", u.GetKind(), name, namespace, err)
+		}
+		return obj, nil
+	}
+}
+
+// Plan runs the initialization and the routing reconciliation of the canary and returns
+// the objects that would be created, updated or deleted along with the unchanged ones
+func (p *Planner) Plan(cd *flaggerv1.Canary) ([]Change, error) {
+	cd = cd.DeepCopy()
+
+	kubeClient := kubefake.NewSimpleClientset()
+	flaggerClient := flaggerfake.NewSimpleClientset(cd)
+	kube := newCluster(kubeClient.Tracker(), kubescheme.Scheme, p.kubeLive)
+	kube.intercept(&kubeClient.Fake)
+	kubeClient.PrependReactor("create", "deployments", readyWorkloadReactor)
+	kubeClient.PrependReactor("create", "statefulsets", readyWorkloadReactor)
+	flagger := newCluster(flaggerClient.Tracker(), flaggerscheme.Scheme, p.flaggerLive)
+	flagger.loaded[objectKey{flaggerv1.SchemeGroupVersion.WithResource("canaries"), cd.Namespace, cd.Name}] = true
+	flagger.intercept(&flaggerClient.Fake)
+
+	var configTracker canary.Tracker
+	if p.options.ConfigTracking {
+		configTracker = &canary.ConfigTracker{
+			Logger:        p.logger,
+			KubeClient:    kubeClient,
+			FlaggerClient: flaggerClient,
+		}
+	} else {
+		configTracker = &canary.NopTracker{}
+	}
+	canaryFactory := canary.NewFactory(kubeClient, flaggerClient, configTracker,
+		p.options.Labels, p.options.IncludeLabelPrefix, p.logger)
+	routerFactory := router.NewFactory(nil, kubeClient, flaggerClient, p.options.IngressAnnotationsPrefix,
+		p.options.IngressClass, p.options.ClusterDomain, p.logger, flaggerClient)
+
+	provider := p.options.MeshProvider
+	if cd.Spec.Provider != "" {
+		provider = cd.Spec.Provider
+	}
+
+	// same order as the first analysis iteration of the controller
+	canaryController := canaryFactory.Controller(cd.Spec.TargetRef.Kind)
+	labelSelector, labelValue, ports, err := canaryController.GetMetadata(cd)
+	if err != nil {
+		return nil, err
+	}
+	kubeRouter := routerFactory.KubernetesRouter(cd.Spec.TargetRef.Kind, labelSelector, labelValue, ports)
+	if err := kubeRouter.Initialize(cd); err != nil {
+		return nil, err
+	}
+	meshRouter := routerFactory.MeshRouter(provider, labelSelector)
+	if strings.HasPrefix(provider, flaggerv1.AppMeshProvider) {
+		if err := meshRouter.Reconcile(cd); err != nil {
+			return nil, err
+		}
+	}
+	if err := canaryController.Initialize(cd); err != nil {
+		return nil, err
+	}
+	if err := kubeRouter.Reconcile(cd); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(provider, flaggerv1.AppMeshProvider) {
+		if err := meshRouter.Reconcile(cd); err != nil {
+			return nil, err
+		}
+	}
+
+	kubeChanges, err := kube.changes(kubeClient.Actions())
+	if err != nil {
+		return nil, err
+	}
+	flaggerChanges, err := flagger.changes(flaggerClient.Actions())
+	if err != nil {
+		return nil, err
+	}
+	return append(kubeChanges, flaggerChanges...), nil
+}
+
+// readyWorkloadReactor reports the created deployments and statefulsets as rolled out
+// so that the primary readiness check passes
+func readyWorkloadReactor(action k8stesting.Action) (bool, runtime.Object, error) {
+	switch obj := action.(k8stesting.CreateAction).GetObject().(type) {
+	case *appsv1.Deployment:
+		replicas := replicasOrDefault(obj.Spec.Replicas)
+		obj.Status.Replicas = replicas
+		obj.Status.UpdatedReplicas = replicas
+		obj.Status.ReadyReplicas = replicas
+		obj.Status.AvailableReplicas = replicas
+	case *appsv1.StatefulSet:
+		replicas := replicasOrDefault(obj.Spec.Replicas)
+		obj.Status.Replicas = replicas
+		obj.Status.UpdatedReplicas = replicas
+		obj.Status.ReadyReplicas = replicas
+		obj.Status.AvailableReplicas = replicas
+	}
+	return false, nil, nil
+}
+
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+type objectKey struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// cluster is an in-memory copy of the objects read by the controllers
+type cluster struct {
+	tracker   k8stesting.ObjectTracker
+	scheme    *runtime.Scheme
+	live      Fetcher
+	loaded    map[objectKey]bool
+	originals map[objectKey]runtime.Object
+}
+
+func newCluster(tracker k8stesting.ObjectTracker, scheme *runtime.Scheme, live Fetcher) *cluster {
+	return &cluster{
+		tracker:   tracker,
+		scheme:    scheme,
+		live:      live,
+		loaded:    make(map[objectKey]bool),
+		originals: make(map[objectKey]runtime.Object),
+	}
+}
+
+// intercept loads the live object before the fake client acts on it,
+// the list requests only return the objects loaded so far
+func (c *cluster) intercept(fake *k8stesting.Fake) {
+	fake.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := actionName(action)
+		if name == "" {
+			return false, nil, nil
+		}
+		if err := c.load(objectKey{action.GetResource(), action.GetNamespace(), name}); err != nil {
+			return true, nil, err
+		}
+		return false, nil, nil
+	})
+}
+
+func (c *cluster) load(key objectKey) error {
+	if c.loaded[key] {
+		return nil
+	}
+	obj, err := c.live(key.gvr, key.namespace, key.name)
+	if errors.IsNotFound(err) {
+		c.loaded[key] = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading the live %s %s.%s failed: %w", key.gvr.Resource, key.name, key.namespace, err)
+	}
+	if err := c.tracker.Create(key.gvr, obj.DeepCopyObject(), key.namespace); err != nil {
+		return err
+	}
+	c.loaded[key] = true
+	c.originals[key] = obj
+	return nil
+}
+
+// changes compares the objects written by the controllers with their live version
+func (c *cluster) changes(actions []k8stesting.Action) ([]Change, error) {
+	var keys []objectKey
+	seen := make(map[objectKey]bool)
+	for _, action := range actions {
+		switch action.GetVerb() {
+		case "create", "update", "patch", "delete":
+		default:
+			continue
+		}
+		if action.GetSubresource() != "" || action.GetResource().Resource == "canaries" {
+			continue
+		}
+		key := objectKey{action.GetResource(), action.GetNamespace(), actionName(action)}
+		if key.name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	var changes []Change
+	for _, key := range keys {
+		original := c.originals[key]
+		planned, err := c.tracker.Get(key.gvr, key.namespace, key.name)
+		if errors.IsNotFound(err) {
+			planned = nil
+		} else if err != nil {
+			return nil, err
+		}
+		if original == nil && planned == nil {
+			continue
+		}
+
+		change := Change{Namespace: key.namespace, Name: key.name}
+		var from, to string
+		if original != nil {
+			if change.Kind, from, err = c.render(original); err != nil {
+				return nil, err
+			}
+		}
+		if planned != nil {
+			if change.Kind, to, err = c.render(planned); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case original == nil:
+			change.Action = Create
+		case planned == nil:
+			change.Action = Delete
+		case from == to:
+			change.Action = Unchanged
+		default:
+			change.Action = Update
+		}
+		if from != to {
+			change.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(from),
+				B:        difflib.SplitLines(to),
+				FromFile: "live",
+				ToFile:   "planned",
+				Context:  3,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// render returns the kind and the YAML of the object without the fields set by the API server
+func (c *cluster) render(obj runtime.Object) (string, string, error) {
+	gvks, _, err := c.scheme.ObjectKinds(obj)
+	if err != nil {
+		return "", "", err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", "", err
+	}
+
+	content["apiVersion"], content["kind"] = gvks[0].GroupVersion().String(), gvks[0].Kind
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"managedFields", "resourceVersion", "uid", "creationTimestamp", "generation", "selfLink"} {
+			delete(metadata, field)
+		}
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+	}
+	if gvks[0].Kind == "Secret" {
+		redact(content, "data")
+		redact(content, "stringData")
+	}
+
+	data, err := yaml.Marshal(content)
+	if err != nil {
+		return "", "", err
+	}
+	return gvks[0].Kind, string(data), nil
+}
+
+// redact replaces the secret values with their checksum
+func redact(content map[string]interface{}, field string) {
+	values, ok := content[field].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k, v := range values {
+		sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+		values[k] = fmt.Sprintf("(redacted sha256:%x)", sum[:8])
+	}
+}
+
+// actionName returns the name of the object the action applies to
+func actionName(action k8stesting.Action) string {
+	switch a := action.(type) {
+	case interface{ GetName() string }:
+		return a.GetName()
+	case interface{ GetObject() runtime.Object }:
+		if obj, err := meta.Accessor(a.GetObject()); err == nil {
+			return obj.GetName()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	flaggerfake "github.com/fluxcd/flagger/pkg/client/clientset/versioned/fake"
+)
+
+func trackerFetcher(tracker k8stesting.ObjectTracker) Fetcher {
+	return func(gvr schema.GroupVersionResource, namespace string, name string) (runtime.Object, error) {
+		return tracker.Get(gvr, namespace, name)
+	}
+}
+
+func newTestPlanner(provider string, objects ...runtime.Object) *Planner {
+	labels := map[string]string{"app": "podinfo"}
+	replicas := int32(2)
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "podinfo", Image: "ghcr.io/stefanprodan/podinfo:6.0.0"}},
+				},
+			},
+		},
+	}
+	live := kubefake.NewSimpleClientset(append([]runtime.Object{dep}, objects...)...)
+	return NewPlanner(trackerFetcher(live.Tracker()), trackerFetcher(flaggerfake.NewSimpleClientset().Tracker()),
+		Options{MeshProvider: provider, Labels: []string{"app"}, ClusterDomain: "cluster.local"}, zap.NewNop().Sugar())
+}
+
+func newTestCanary() *flaggerv1.Canary {
+	return &flaggerv1.Canary{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec: flaggerv1.CanarySpec{
+			TargetRef: flaggerv1.LocalObjectReference{Name: "podinfo", Kind: "Deployment", APIVersion: "apps/v1"},
+			Service:   flaggerv1.CanaryService{Port: 9898},
+			Analysis:  &flaggerv1.CanaryAnalysis{Threshold: 5, StepWeight: 10, MaxWeight: 50},
+		},
+	}
+}
+
+func findChange(changes []Change, kind string, name string) *Change {
+	for i := range changes {
+		if changes[i].Kind == kind && changes[i].Name == name {
+			return &changes[i]
+		}
+	}
+	return nil
+}
+
+func TestPlanner_Initialization(t *testing.T) {
+	changes, err := newTestPlanner(flaggerv1.KubernetesProvider).Plan(newTestCanary())
+	require.NoError(t, err)
+
+	primary := findChange(changes, "Deployment", "podinfo-primary")
+	require.NotNil(t, primary)
+	assert.Equal(t, Create, primary.Action)
+	assert.Contains(t, primary.Diff, "+  name: podinfo-primary")
+
+	target := findChange(changes, "Deployment", "podinfo")
+	require.NotNil(t, target)
+	assert.Equal(t, Update, target.Action)
+	assert.Contains(t, target.Diff, "-  replicas: 2")
+	assert.Contains(t, target.Diff, "+  replicas: 0")
+
+	for _, name := range []string{"podinfo", "podinfo-canary", "podinfo-primary"} {
+		svc := findChange(changes, "Service", name)
+		require.NotNil(t, svc, name)
+		assert.Equal(t, Create, svc.Action)
+	}
+
+	for _, change := range changes {
+		assert.NotContains(t, change.Diff, "resourceVersion")
+		assert.NotContains(t, change.Diff, "status:")
+	}
+}
+
+func TestPlanner_ExistingObjects(t *testing.T) {
+	// a live apex service that doesn't select the primary pods is updated
+	apex := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "podinfo"},
+			Ports:    []corev1.ServicePort{{Name: "http", Port: 9898}},
+		},
+	}
+	changes, err := newTestPlanner(flaggerv1.KubernetesProvider, apex).Plan(newTestCanary())
+	require.NoError(t, err)
+	svc := findChange(changes, "Service", "podinfo")
+	require.NotNil(t, svc)
+	assert.Equal(t, Update, svc.Action)
+	assert.Contains(t, svc.Diff, "+    app: podinfo-primary")
+}
+
+func TestPlanner_MeshRouter(t *testing.T) {
+	cd := newTestCanary()
+	cd.Spec.Provider = flaggerv1.ContourProvider
+	changes, err := newTestPlanner(flaggerv1.KubernetesProvider).Plan(cd)
+	require.NoError(t, err)
+
+	proxy := findChange(changes, "HTTPProxy", "podinfo")
+	require.NotNil(t, proxy)
+	assert.Equal(t, Create, proxy.Action)
+	assert.Contains(t, proxy.Diff, "podinfo-primary")
+}
+
+func TestCluster_RenderSecret(t *testing.T) {
+	c := newCluster(kubefake.NewSimpleClientset().Tracker(), kubescheme.Scheme, nil)
+	kind, data, err := c.render(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", ResourceVersion: "42"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Secret", kind)
+	assert.Contains(t, data, "password: (redacted sha256:")
+	assert.NotContains(t, data, "s3cr3t")
+	assert.NotContains(t, data, "resourceVersion")
+}
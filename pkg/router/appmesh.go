@@ -1,3 +1,6 @@
+//go:build !no_appmesh
+// +build !no_appmesh
+
 /*
 Copyright 2020 The Flux authors
 
@@ -37,6 +40,17 @@ import (
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.AppMeshProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &AppMeshRouter{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			appmeshClient: factory.meshClient,
+		}
+	})
+}
+
 // AppMeshRouter is managing AppMesh virtual services
 type AppMeshRouter struct {
 	kubeClient    kubernetes.Interface
@@ -171,7 +185,7 @@ func (ar *AppMeshRouter) reconcileVirtualNode(canary *flaggerv1.Canary, name str
 			},
 			Spec: vnSpec,
 		}
-		_, err = ar.appmeshClient.AppmeshV1beta1().VirtualNodes(canary.Namespace).Create(context.TODO(), virtualnode, metav1.CreateOptions{})
+		_, err = ar.appmeshClient.AppmeshV1beta1().VirtualNodes(canary.Namespace).Create(context.TODO(), virtualnode, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("VirtualNode %s.%s create error %w", name, canary.Namespace, err)
 		}
@@ -187,7 +201,7 @@ func (ar *AppMeshRouter) reconcileVirtualNode(canary *flaggerv1.Canary, name str
 		if diff := cmp.Diff(vnSpec, virtualnode.Spec); diff != "" {
 			vnClone := virtualnode.DeepCopy()
 			vnClone.Spec = vnSpec
-			_, err = ar.appmeshClient.AppmeshV1beta1().VirtualNodes(canary.Namespace).Update(context.TODO(), vnClone, metav1.UpdateOptions{})
+			_, err = ar.appmeshClient.AppmeshV1beta1().VirtualNodes(canary.Namespace).Update(context.TODO(), vnClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("VirtualNode %s update error %w", name, err)
 			}
@@ -203,7 +217,10 @@ func (ar *AppMeshRouter) reconcileVirtualNode(canary *flaggerv1.Canary, name str
 func (ar *AppMeshRouter) reconcileVirtualService(canary *flaggerv1.Canary, name string, canaryWeight int64) error {
 	apexName, _, _ := canary.GetServiceNames()
 	canaryVirtualNode := fmt.Sprintf("%s-canary", apexName)
-	primaryVirtualNode := fmt.Sprintf("%s-primary", apexName)
+	primaryVirtualNode, err := canary.GetPrimaryName(apexName)
+	if err != nil {
+		return err
+	}
 	protocol := ar.getProtocol(canary)
 
 	routerName := apexName
@@ -333,7 +350,7 @@ func (ar *AppMeshRouter) reconcileVirtualService(canary *flaggerv1.Canary, name
 			}
 		}
 
-		_, err = ar.appmeshClient.AppmeshV1beta1().VirtualServices(canary.Namespace).Create(context.TODO(), virtualService, metav1.CreateOptions{})
+		_, err = ar.appmeshClient.AppmeshV1beta1().VirtualServices(canary.Namespace).Create(context.TODO(), virtualService, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("VirtualService %s create error %w", name, err)
 		}
@@ -359,7 +376,7 @@ func (ar *AppMeshRouter) reconcileVirtualService(canary *flaggerv1.Canary, name
 				}
 			}
 
-			_, err = ar.appmeshClient.AppmeshV1beta1().VirtualServices(canary.Namespace).Update(context.TODO(), vsClone, metav1.UpdateOptions{})
+			_, err = ar.appmeshClient.AppmeshV1beta1().VirtualServices(canary.Namespace).Update(context.TODO(), vsClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("VirtualService %s update error: %w", name, err)
 			}
@@ -391,19 +408,24 @@ func (ar *AppMeshRouter) GetRoutes(canary *flaggerv1.Canary) (
 		return
 	}
 
+	primaryVirtualNode, err := canary.GetPrimaryName(apexName)
+	if err != nil {
+		return
+	}
+	canaryVirtualNode := fmt.Sprintf("%s-canary", apexName)
 	targets := vs.Spec.Routes[0].Http.Action.WeightedTargets
 	for _, t := range targets {
-		if t.VirtualNodeName == fmt.Sprintf("%s-canary", apexName) {
+		if t.VirtualNodeName == canaryVirtualNode {
 			canaryWeight = int(t.Weight)
 		}
-		if t.VirtualNodeName == fmt.Sprintf("%s-primary", apexName) {
+		if t.VirtualNodeName == primaryVirtualNode {
 			primaryWeight = int(t.Weight)
 		}
 	}
 
 	if primaryWeight == 0 && canaryWeight == 0 {
-		err = fmt.Errorf("VirtualService %s does not contain routes for %s-primary and %s-canary",
-			vsName, apexName, apexName)
+		err = fmt.Errorf("VirtualService %s does not contain routes for %s and %s",
+			vsName, primaryVirtualNode, canaryVirtualNode)
 	}
 
 	mirrored = false
@@ -425,6 +447,11 @@ func (ar *AppMeshRouter) SetRoutes(
 		return fmt.Errorf("VirtualService %s get query error: %w", vsName, err)
 	}
 
+	primaryVirtualNode, err := canary.GetPrimaryName(apexName)
+	if err != nil {
+		return err
+	}
+
 	vsClone := vs.DeepCopy()
 	vsClone.Spec.Routes[0].Http.Action = appmeshv1.HttpRouteAction{
 		WeightedTargets: []appmeshv1.WeightedTarget{
@@ -433,13 +460,13 @@ func (ar *AppMeshRouter) SetRoutes(
 				Weight:          int64(canaryWeight),
 			},
 			{
-				VirtualNodeName: fmt.Sprintf("%s-primary", apexName),
+				VirtualNodeName: primaryVirtualNode,
 				Weight:          int64(primaryWeight),
 			},
 		},
 	}
 
-	_, err = ar.appmeshClient.AppmeshV1beta1().VirtualServices(canary.Namespace).Update(context.TODO(), vsClone, metav1.UpdateOptions{})
+	_, err = ar.appmeshClient.AppmeshV1beta1().VirtualServices(canary.Namespace).Update(context.TODO(), vsClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("VirtualService %s update error: %w", vsName, err)
 	}
@@ -526,6 +553,8 @@ func (ar *AppMeshRouter) gatewayAnnotations(canary *flaggerv1.Canary) map[string
 	return a
 }
 
+// Finalize is a no-op, the mesh clients route through the App Mesh virtual service which has
+// no original configuration to restore, the generated objects are garbage collected with the canary
 func (ar *AppMeshRouter) Finalize(_ *flaggerv1.Canary) error {
 	return nil
 }
@@ -1,3 +1,6 @@
+//go:build !no_appmesh
+// +build !no_appmesh
+
 /*
 Copyright 2020 The Flux authors
 
@@ -37,6 +40,19 @@ import (
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.AppMeshProvider+":v1beta2", func(factory *Factory, _ string, labelSelector string) Interface {
+		return &AppMeshv1beta2Router{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			appmeshClient: factory.meshClient,
+			labelSelector: labelSelector,
+			clusterDomain: factory.clusterDomain,
+		}
+	})
+}
+
 // AppMeshRouter is managing AppMesh virtual services
 type AppMeshv1beta2Router struct {
 	kubeClient    kubernetes.Interface
@@ -44,14 +60,14 @@ type AppMeshv1beta2Router struct {
 	flaggerClient clientset.Interface
 	logger        *zap.SugaredLogger
 	labelSelector string
+	clusterDomain string
 }
 
 // Reconcile creates or updates App Mesh virtual nodes and virtual services
 func (ar *AppMeshv1beta2Router) Reconcile(canary *flaggerv1.Canary) error {
-	svcSuffix := "svc.cluster.local."
 	apexName, primaryName, canaryName := canary.GetServiceNames()
-	primaryHost := fmt.Sprintf("%s.%s.%s", primaryName, canary.Namespace, svcSuffix)
-	canaryHost := fmt.Sprintf("%s.%s.%s", canaryName, canary.Namespace, svcSuffix)
+	primaryHost := serviceFQDN(canary, primaryName, ar.clusterDomain) + "."
+	canaryHost := serviceFQDN(canary, canaryName, ar.clusterDomain) + "."
 
 	// sync virtual node e.g. app-namespace
 	// DNS app.namespace
@@ -62,7 +78,11 @@ func (ar *AppMeshv1beta2Router) Reconcile(canary *flaggerv1.Canary) error {
 
 	// sync virtual node e.g. app-primary-namespace
 	// DNS app-primary.namespace
-	err := ar.reconcileVirtualNode(canary, primaryName, fmt.Sprintf("%s-primary", canary.Spec.TargetRef.Name), primaryHost)
+	primaryLabelValue, err := canary.GetPrimaryLabelValue(canary.Spec.TargetRef.Name)
+	if err != nil {
+		return err
+	}
+	err = ar.reconcileVirtualNode(canary, primaryName, primaryLabelValue, primaryHost)
 	if err != nil {
 		return fmt.Errorf("reconcileVirtualNode failed: %w", err)
 	}
@@ -185,7 +205,7 @@ func (ar *AppMeshv1beta2Router) reconcileVirtualNode(canary *flaggerv1.Canary, n
 			},
 			Spec: vnSpec,
 		}
-		_, err = ar.appmeshClient.AppmeshV1beta2().VirtualNodes(canary.Namespace).Create(context.TODO(), virtualnode, metav1.CreateOptions{})
+		_, err = ar.appmeshClient.AppmeshV1beta2().VirtualNodes(canary.Namespace).Create(context.TODO(), virtualnode, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("VirtualNode %s.%s create error %w", name, canary.Namespace, err)
 		}
@@ -204,7 +224,7 @@ func (ar *AppMeshv1beta2Router) reconcileVirtualNode(canary *flaggerv1.Canary, n
 			vnClone.Spec = vnSpec
 			vnClone.Spec.AWSName = virtualnode.Spec.AWSName
 			vnClone.Spec.MeshRef = virtualnode.Spec.MeshRef
-			_, err = ar.appmeshClient.AppmeshV1beta2().VirtualNodes(canary.Namespace).Update(context.TODO(), vnClone, metav1.UpdateOptions{})
+			_, err = ar.appmeshClient.AppmeshV1beta2().VirtualNodes(canary.Namespace).Update(context.TODO(), vnClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("VirtualNode %s update error %w", name, err)
 			}
@@ -220,7 +240,10 @@ func (ar *AppMeshv1beta2Router) reconcileVirtualNode(canary *flaggerv1.Canary, n
 func (ar *AppMeshv1beta2Router) reconcileVirtualRouter(canary *flaggerv1.Canary, name string, canaryWeight int64) error {
 	apexName, _, _ := canary.GetServiceNames()
 	canaryVirtualNode := fmt.Sprintf("%s-canary", apexName)
-	primaryVirtualNode := fmt.Sprintf("%s-primary", apexName)
+	primaryVirtualNode, err := canary.GetPrimaryName(apexName)
+	if err != nil {
+		return err
+	}
 	protocol := ar.getProtocol(canary)
 	timeout := ar.makeRouteTimeout(canary)
 
@@ -352,7 +375,7 @@ func (ar *AppMeshv1beta2Router) reconcileVirtualRouter(canary *flaggerv1.Canary,
 			Spec: vrSpec,
 		}
 
-		_, err = ar.appmeshClient.AppmeshV1beta2().VirtualRouters(canary.Namespace).Create(context.TODO(), virtualRouter, metav1.CreateOptions{})
+		_, err = ar.appmeshClient.AppmeshV1beta2().VirtualRouters(canary.Namespace).Create(context.TODO(), virtualRouter, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("VirtualRouter %s create error %w", name, err)
 		}
@@ -390,7 +413,7 @@ func (ar *AppMeshv1beta2Router) reconcileVirtualRouter(canary *flaggerv1.Canary,
 			}
 		}
 
-		_, err = ar.appmeshClient.AppmeshV1beta2().VirtualServices(canary.Namespace).Create(context.TODO(), virtualService, metav1.CreateOptions{})
+		_, err = ar.appmeshClient.AppmeshV1beta2().VirtualServices(canary.Namespace).Create(context.TODO(), virtualService, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("VirtualService %s create error %w", name, err)
 		}
@@ -412,7 +435,7 @@ func (ar *AppMeshv1beta2Router) reconcileVirtualRouter(canary *flaggerv1.Canary,
 			vrClone.Spec.Routes[0].HTTPRoute.Action = virtualRouter.Spec.Routes[0].HTTPRoute.Action
 			vrClone.Spec.AWSName = virtualRouter.Spec.AWSName
 			vrClone.Spec.MeshRef = virtualRouter.Spec.MeshRef
-			_, err = ar.appmeshClient.AppmeshV1beta2().VirtualRouters(canary.Namespace).Update(context.TODO(), vrClone, metav1.UpdateOptions{})
+			_, err = ar.appmeshClient.AppmeshV1beta2().VirtualRouters(canary.Namespace).Update(context.TODO(), vrClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("VirtualRouter %s update error: %w", name, err)
 			}
@@ -454,8 +477,8 @@ func (ar *AppMeshv1beta2Router) GetRoutes(canary *flaggerv1.Canary) (
 	}
 
 	if primaryWeight == 0 && canaryWeight == 0 {
-		err = fmt.Errorf("VirtualRouter %s does not contain routes for %s-primary and %s-canary",
-			apexName, apexName, apexName)
+		err = fmt.Errorf("VirtualRouter %s does not contain routes for %s and %s",
+			apexName, primaryName, canaryName)
 	}
 
 	mirrored = false
@@ -494,7 +517,7 @@ func (ar *AppMeshv1beta2Router) SetRoutes(
 		},
 	}
 
-	_, err = ar.appmeshClient.AppmeshV1beta2().VirtualRouters(canary.Namespace).Update(context.TODO(), vrClone, metav1.UpdateOptions{})
+	_, err = ar.appmeshClient.AppmeshV1beta2().VirtualRouters(canary.Namespace).Update(context.TODO(), vrClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("VirtualRouter %s update error: %w", apexName, err)
 	}
@@ -624,6 +647,8 @@ func (ar *AppMeshv1beta2Router) gatewayAnnotations(canary *flaggerv1.Canary) map
 	return a
 }
 
+// Finalize is a no-op, the mesh clients route through the App Mesh virtual service which has
+// no original configuration to restore, the generated objects are garbage collected with the canary
 func (ar *AppMeshv1beta2Router) Finalize(_ *flaggerv1.Canary) error {
 	return nil
 }
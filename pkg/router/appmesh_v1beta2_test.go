@@ -1,3 +1,6 @@
+//go:build !no_appmesh
+// +build !no_appmesh
+
 /*
 Copyright 2020 The Flux authors
 
@@ -1,3 +1,6 @@
+//go:build !no_contour
+// +build !no_contour
+
 /*
 Copyright 2020 The Flux authors
 
@@ -19,6 +22,8 @@ package router
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-cmp/cmp"
@@ -34,6 +39,19 @@ import (
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.ContourProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &ContourRouter{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			contourClient: factory.meshClient,
+			ingressClass:  factory.ingressClass,
+			clusterDomain: factory.clusterDomain,
+		}
+	})
+}
+
 // ContourRouter is managing HTTPProxy objects
 type ContourRouter struct {
 	kubeClient    kubernetes.Interface
@@ -41,6 +59,7 @@ type ContourRouter struct {
 	flaggerClient clientset.Interface
 	logger        *zap.SugaredLogger
 	ingressClass  string
+	clusterDomain string
 }
 
 // Reconcile creates or updates the HTTP proxy
@@ -150,6 +169,8 @@ func (cr *ContourRouter) Reconcile(canary *flaggerv1.Canary) error {
 		}
 	}
 
+	cr.makeSessionAffinity(canary, &newSpec, 0)
+
 	proxy, err := cr.contourClient.ProjectcontourV1().HTTPProxies(canary.Namespace).Get(context.TODO(), apexName, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		metadata := canary.Spec.Service.Apex
@@ -190,7 +211,7 @@ func (cr *ContourRouter) Reconcile(canary *flaggerv1.Canary) error {
 			}
 		}
 
-		_, err = cr.contourClient.ProjectcontourV1().HTTPProxies(canary.Namespace).Create(context.TODO(), proxy, metav1.CreateOptions{})
+		_, err = cr.contourClient.ProjectcontourV1().HTTPProxies(canary.Namespace).Create(context.TODO(), proxy, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("HTTPProxy %s.%s create error: %w", apexName, canary.Namespace, err)
 		}
@@ -211,7 +232,7 @@ func (cr *ContourRouter) Reconcile(canary *flaggerv1.Canary) error {
 			clone := proxy.DeepCopy()
 			clone.Spec = newSpec
 
-			_, err = cr.contourClient.ProjectcontourV1().HTTPProxies(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{})
+			_, err = cr.contourClient.ProjectcontourV1().HTTPProxies(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("HTTPProxy %s.%s update error: %w", apexName, canary.Namespace, err)
 			}
@@ -372,7 +393,9 @@ func (cr *ContourRouter) SetRoutes(
 		}
 	}
 
-	_, err = cr.contourClient.ProjectcontourV1().HTTPProxies(canary.Namespace).Update(context.TODO(), proxy, metav1.UpdateOptions{})
+	cr.makeSessionAffinity(canary, &proxy.Spec, canaryWeight)
+
+	_, err = cr.contourClient.ProjectcontourV1().HTTPProxies(canary.Namespace).Update(context.TODO(), proxy, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("HTTPProxy %s.%s update error: %w", apexName, canary.Namespace, err)
 	}
@@ -430,6 +453,62 @@ func (cr *ContourRouter) makeConditions(canary *flaggerv1.Canary) []contourv1.Ma
 	return list
 }
 
+// makeSessionAffinity sets the cookie on the canary responses of the weighted route and
+// appends the route matching the cookie, the A/B testing routes are left unchanged
+func (cr *ContourRouter) makeSessionAffinity(canary *flaggerv1.Canary, spec *contourv1.HTTPProxySpec, canaryWeight int) {
+	affinity := canary.GetSessionAffinity()
+	if affinity == nil || len(canary.GetAnalysis().Match) > 0 {
+		return
+	}
+
+	_, primaryName, canaryName := canary.GetServiceNames()
+	spec.Routes[0].Services[1].ResponseHeadersPolicy = &contourv1.HeadersPolicy{
+		Set: []contourv1.HeaderValue{
+			{
+				Name:  "Set-Cookie",
+				Value: makeSetCookie(canary, affinity),
+			},
+		},
+	}
+
+	stickyPrimaryWeight, stickyCanaryWeight := makeStickyWeights(canaryWeight)
+	spec.Routes = append(spec.Routes, contourv1.Route{
+		Conditions: []contourv1.MatchCondition{
+			{
+				Prefix: cr.makePrefix(canary),
+				Header: &contourv1.HeaderMatchCondition{
+					Name:     "Cookie",
+					Contains: fmt.Sprintf("%s=%s", affinity.CookieName, canary.GetCookieValue()),
+				},
+			},
+		},
+		TimeoutPolicy: cr.makeTimeoutPolicy(canary),
+		RetryPolicy:   cr.makeRetryPolicy(canary),
+		Services: []contourv1.Service{
+			{
+				Name:   primaryName,
+				Port:   int(canary.Spec.Service.Port),
+				Weight: int64(stickyPrimaryWeight),
+				RequestHeadersPolicy: &contourv1.HeadersPolicy{
+					Set: []contourv1.HeaderValue{
+						cr.makeLinkerdHeaderValue(canary, primaryName),
+					},
+				},
+			},
+			{
+				Name:   canaryName,
+				Port:   int(canary.Spec.Service.Port),
+				Weight: int64(stickyCanaryWeight),
+				RequestHeadersPolicy: &contourv1.HeadersPolicy{
+					Set: []contourv1.HeaderValue{
+						cr.makeLinkerdHeaderValue(canary, canaryName),
+					},
+				},
+			},
+		},
+	})
+}
+
 func (cr *ContourRouter) makeTimeoutPolicy(canary *flaggerv1.Canary) *contourv1.TimeoutPolicy {
 	if canary.Spec.Service.Timeout != "" {
 		return &contourv1.TimeoutPolicy{
@@ -464,11 +543,29 @@ func makeRetryOn(retryOnString string) []contourv1.RetryOn {
 func (cr *ContourRouter) makeLinkerdHeaderValue(canary *flaggerv1.Canary, serviceName string) contourv1.HeaderValue {
 	return contourv1.HeaderValue{
 		Name:  "l5d-dst-override",
-		Value: fmt.Sprintf("%s.%s.svc.cluster.local:%v", serviceName, canary.Namespace, canary.Spec.Service.Port),
+		Value: net.JoinHostPort(serviceFQDN(canary, serviceName, cr.clusterDomain), strconv.Itoa(int(canary.Spec.Service.Port))),
 	}
 
 }
 
-func (cr *ContourRouter) Finalize(_ *flaggerv1.Canary) error {
+// Finalize deletes the HTTPProxy generated by Flagger
+func (cr *ContourRouter) Finalize(canary *flaggerv1.Canary) error {
+	apexName, _, _ := canary.GetServiceNames()
+	client := cr.contourClient.ProjectcontourV1().HTTPProxies(canary.Namespace)
+
+	deleted, err := finalizeGeneratedObject(canary, "HTTPProxy", fmt.Sprintf("%s.%s", apexName, canary.Namespace),
+		func() (metav1.Object, error) {
+			return client.Get(context.TODO(), apexName, metav1.GetOptions{})
+		},
+		func() error {
+			return client.Delete(context.TODO(), apexName, metav1.DeleteOptions{})
+		})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		cr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("HTTPProxy %s.%s deleted", apexName, canary.Namespace)
+	}
 	return nil
 }
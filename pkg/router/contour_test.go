@@ -1,3 +1,6 @@
+//go:build !no_contour
+// +build !no_contour
+
 /*
 Copyright 2020 The Flux authors
 
@@ -20,11 +23,13 @@ import (
 	"context"
 	"testing"
 
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 	contourv1 "github.com/fluxcd/flagger/pkg/apis/projectcontour/v1"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -156,3 +161,73 @@ func TestContourRouter_Routes(t *testing.T) {
 	primary = proxy.Spec.Routes[1].Services[0]
 	assert.Equal(t, int64(100), primary.Weight)
 }
+
+func TestContourRouter_SessionAffinity(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &ContourRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		contourClient: mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	mocks.canary.Spec.Analysis.SessionAffinity = &flaggerv1.SessionAffinity{CookieName: "flagger-cookie"}
+	mocks.canary.Status.LastAppliedSpec = "abc"
+
+	err := router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	err = router.SetRoutes(mocks.canary, 60, 40, false)
+	require.NoError(t, err)
+
+	proxy, err := router.contourClient.ProjectcontourV1().HTTPProxies("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, proxy.Spec.Routes, 2)
+
+	// the cookie is set on the canary responses
+	weighted := proxy.Spec.Routes[0]
+	assert.Nil(t, weighted.Services[0].ResponseHeadersPolicy)
+	require.NotNil(t, weighted.Services[1].ResponseHeadersPolicy)
+	assert.Equal(t, "Set-Cookie", weighted.Services[1].ResponseHeadersPolicy.Set[0].Name)
+	assert.Equal(t, "flagger-cookie=abc; Max-Age=86400; Path=/", weighted.Services[1].ResponseHeadersPolicy.Set[0].Value)
+
+	// the users with the cookie are routed to the canary
+	sticky := proxy.Spec.Routes[1]
+	require.NotNil(t, sticky.Conditions[0].Header)
+	assert.Equal(t, "flagger-cookie=abc", sticky.Conditions[0].Header.Contains)
+	assert.Equal(t, int64(0), sticky.Services[0].Weight)
+	assert.Equal(t, int64(100), sticky.Services[1].Weight)
+
+	// the weights are kept on reconciliation
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	p, c, _, err := router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 60, p)
+	assert.Equal(t, 40, c)
+}
+
+func TestContourRouter_Finalize(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &ContourRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		contourClient: mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+		ingressClass:  "contour",
+	}
+
+	err := router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	err = router.Finalize(mocks.canary)
+	require.NoError(t, err)
+
+	_, err = router.contourClient.ProjectcontourV1().HTTPProxies("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	// finalize is idempotent
+	err = router.Finalize(mocks.canary)
+	require.NoError(t, err)
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package router
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
 	"go.uber.org/zap"
@@ -34,6 +36,7 @@ type Factory struct {
 	flaggerClient            clientset.Interface
 	ingressAnnotationsPrefix string
 	ingressClass             string
+	clusterDomain            string
 	logger                   *zap.SugaredLogger
 }
 
@@ -41,6 +44,7 @@ func NewFactory(kubeConfig *restclient.Config, kubeClient kubernetes.Interface,
 	flaggerClient clientset.Interface,
 	ingressAnnotationsPrefix string,
 	ingressClass string,
+	clusterDomain string,
 	logger *zap.SugaredLogger,
 	meshClient clientset.Interface) *Factory {
 	return &Factory{
@@ -50,10 +54,22 @@ func NewFactory(kubeConfig *restclient.Config, kubeClient kubernetes.Interface,
 		flaggerClient:            flaggerClient,
 		ingressAnnotationsPrefix: ingressAnnotationsPrefix,
 		ingressClass:             ingressClass,
+		clusterDomain:            clusterDomain,
 		logger:                   logger,
 	}
 }
 
+// ForCluster returns a copy of the factory that manages the services
+// and the routing objects in the cluster of the given config
+func (factory *Factory) ForCluster(kubeConfig *restclient.Config, kubeClient kubernetes.Interface,
+	meshClient clientset.Interface) *Factory {
+	f := *factory
+	f.kubeConfig = kubeConfig
+	f.kubeClient = kubeClient
+	f.meshClient = meshClient
+	return &f
+}
+
 // KubernetesRouter returns a KubernetesRouter interface implementation
 func (factory *Factory) KubernetesRouter(kind string, labelSelector string, labelValue string, ports map[string]int32) KubernetesRouter {
 	switch kind {
@@ -71,126 +87,93 @@ func (factory *Factory) KubernetesRouter(kind string, labelSelector string, labe
 	}
 }
 
-// MeshRouter returns a service mesh router
-func (factory *Factory) MeshRouter(provider string, labelSelector string) Interface {
+// meshRouterConstructor returns the router of a mesh or ingress provider
+type meshRouterConstructor func(factory *Factory, provider string, labelSelector string) Interface
+
+// meshRouters holds the routers compiled in the binary, each router registers itself
+// in a file that can be excluded with a build tag e.g. no_istio
+var meshRouters = map[string]meshRouterConstructor{}
+
+func registerMeshRouter(name string, constructor meshRouterConstructor) {
+	meshRouters[name] = constructor
+}
+
+// meshRouterName returns the name of the router implementing the provider,
+// the Istio router is used when the provider is not specified
+func meshRouterName(provider string) string {
 	switch {
 	case strings.HasPrefix(provider, flaggerv1.AppMeshProvider+":v1beta2"):
-		return &AppMeshv1beta2Router{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			appmeshClient: factory.meshClient,
-			labelSelector: labelSelector,
-		}
+		return flaggerv1.AppMeshProvider + ":v1beta2"
 	case provider == flaggerv1.AppMeshProvider:
-		return &AppMeshRouter{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			appmeshClient: factory.meshClient,
-		}
+		return flaggerv1.AppMeshProvider
 	case provider == flaggerv1.LinkerdProvider:
-		return &SmiRouter{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			smiClient:     factory.meshClient,
-			targetMesh:    flaggerv1.LinkerdProvider,
-		}
+		return flaggerv1.LinkerdProvider
 	case provider == flaggerv1.IstioProvider:
-		return &IstioRouter{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			istioClient:   factory.meshClient,
-		}
+		return flaggerv1.IstioProvider
 	case strings.HasPrefix(provider, flaggerv1.SMIProvider+":v1alpha1"):
-		mesh := strings.TrimPrefix(provider, flaggerv1.SMIProvider+":v1alpha1:")
-		return &SmiRouter{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			smiClient:     factory.meshClient,
-			targetMesh:    mesh,
-		}
+		return flaggerv1.SMIProvider + ":v1alpha1"
 	case strings.HasPrefix(provider, flaggerv1.SMIProvider+":v1alpha2"):
-		mesh := strings.TrimPrefix(provider, flaggerv1.SMIProvider+":v1alpha2:")
-		return &Smiv1alpha2Router{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			smiClient:     factory.meshClient,
-			targetMesh:    mesh,
-		}
+		return flaggerv1.SMIProvider + ":v1alpha2"
 	case strings.HasPrefix(provider, flaggerv1.SMIProvider+":v1alpha3"):
-		mesh := strings.TrimPrefix(provider, flaggerv1.SMIProvider+":v1alpha3:")
-		return &Smiv1alpha3Router{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			smiClient:     factory.meshClient,
-			targetMesh:    mesh,
-		}
+		return flaggerv1.SMIProvider + ":v1alpha3"
 	case provider == flaggerv1.ContourProvider:
-		return &ContourRouter{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			contourClient: factory.meshClient,
-			ingressClass:  factory.ingressClass,
-		}
+		return flaggerv1.ContourProvider
 	case strings.HasPrefix(provider, flaggerv1.GlooProvider):
-		return &GlooRouter{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			glooClient:    factory.meshClient,
-		}
+		return flaggerv1.GlooProvider
 	case provider == flaggerv1.NGINXProvider:
-		return &IngressRouter{
-			logger:            factory.logger,
-			kubeClient:        factory.kubeClient,
-			annotationsPrefix: factory.ingressAnnotationsPrefix,
-		}
+		return flaggerv1.NGINXProvider
 	case provider == flaggerv1.SkipperProvider:
-		return &SkipperRouter{
-			logger:     factory.logger,
-			kubeClient: factory.kubeClient,
-		}
+		return flaggerv1.SkipperProvider
 	case provider == flaggerv1.TraefikProvider:
-		return &TraefikRouter{
-			logger:        factory.logger,
-			traefikClient: factory.meshClient,
-		}
+		return flaggerv1.TraefikProvider
 	case provider == flaggerv1.OsmProvider:
-		return &Smiv1alpha2Router{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			smiClient:     factory.meshClient,
-			targetMesh:    flaggerv1.OsmProvider,
-		}
+		return flaggerv1.OsmProvider
 	case provider == flaggerv1.KumaProvider:
-		return &KumaRouter{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			kumaClient:    factory.meshClient,
-		}
+		return flaggerv1.KumaProvider
 	case strings.HasPrefix(provider, flaggerv1.GatewayAPIProvider):
-		return &GatewayAPIRouter{
-			logger:           factory.logger,
-			kubeClient:       factory.kubeClient,
-			gatewayAPIClient: factory.meshClient,
-		}
+		return flaggerv1.GatewayAPIProvider
 	case provider == flaggerv1.KubernetesProvider:
-		return &NopRouter{}
+		return flaggerv1.KubernetesProvider
 	default:
-		return &IstioRouter{
-			logger:        factory.logger,
-			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			istioClient:   factory.meshClient,
-		}
+		return flaggerv1.IstioProvider
+	}
+}
+
+// MeshRouter returns a service mesh router
+func (factory *Factory) MeshRouter(provider string, labelSelector string) Interface {
+	name := meshRouterName(provider)
+	if name == flaggerv1.KubernetesProvider {
+		return &NopRouter{}
+	}
+
+	constructor, ok := meshRouters[name]
+	if !ok {
+		return &UnsupportedRouter{provider: provider}
+	}
+	return constructor(factory, provider, labelSelector)
+}
+
+// ValidateProvider returns an error if the provider is unknown or its router is not compiled in the binary
+func ValidateProvider(provider string) error {
+	if provider == "" {
+		return nil
+	}
+	name := meshRouterName(provider)
+	if name == flaggerv1.IstioProvider && provider != flaggerv1.IstioProvider {
+		return fmt.Errorf("provider %s is not supported", provider)
+	}
+	if _, ok := meshRouters[name]; !ok && name != flaggerv1.KubernetesProvider {
+		return fmt.Errorf("provider %s is not included in this build of Flagger", provider)
+	}
+	return nil
+}
+
+// MeshRouters returns the names of the routers compiled in the binary
+func MeshRouters() []string {
+	names := make([]string, 0, len(meshRouters))
+	for name := range meshRouters {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
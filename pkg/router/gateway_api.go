@@ -1,3 +1,6 @@
+//go:build !no_gatewayapi
+// +build !no_gatewayapi
+
 /*
 Copyright 2022 The Flux authors
 
@@ -34,6 +37,16 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.GatewayAPIProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &GatewayAPIRouter{
+			logger:           factory.logger,
+			kubeClient:       factory.kubeClient,
+			gatewayAPIClient: factory.meshClient,
+		}
+	})
+}
+
 var (
 	initialPrimaryWeight = int32(100)
 	initialCanaryWeight  = int32(0)
@@ -150,7 +163,7 @@ func (gwr *GatewayAPIRouter) Reconcile(canary *flaggerv1.Canary) error {
 		}
 
 		_, err := gwr.gatewayAPIClient.GatewayapiV1alpha2().HTTPRoutes(hrNamespace).
-			Create(context.TODO(), route, metav1.CreateOptions{})
+			Create(context.TODO(), route, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 
 		if err != nil {
 			return fmt.Errorf("HTTPRoute %s.%s create error: %w", apexSvcName, hrNamespace, err)
@@ -170,7 +183,7 @@ func (gwr *GatewayAPIRouter) Reconcile(canary *flaggerv1.Canary) error {
 			hrClone := httpRoute.DeepCopy()
 			hrClone.Spec = httpRouteSpec
 			_, err := gwr.gatewayAPIClient.GatewayapiV1alpha2().HTTPRoutes(hrNamespace).
-				Update(context.TODO(), hrClone, metav1.UpdateOptions{})
+				Update(context.TODO(), hrClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("HTTPRoute %s.%s update error: %w while reconciling", hrClone.GetName(), hrNamespace, err)
 			}
@@ -278,7 +291,7 @@ func (gwr *GatewayAPIRouter) SetRoutes(
 		})
 	}
 
-	_, err = gwr.gatewayAPIClient.GatewayapiV1alpha2().HTTPRoutes(hrNamespace).Update(context.TODO(), hrClone, metav1.UpdateOptions{})
+	_, err = gwr.gatewayAPIClient.GatewayapiV1alpha2().HTTPRoutes(hrNamespace).Update(context.TODO(), hrClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("HTTPRoute %s.%s update error: %w while setting weights", hrClone.GetName(), hrNamespace, err)
 	}
@@ -286,7 +299,25 @@ func (gwr *GatewayAPIRouter) SetRoutes(
 	return nil
 }
 
-func (gwr *GatewayAPIRouter) Finalize(_ *flaggerv1.Canary) error {
+// Finalize deletes the HTTPRoute generated by Flagger
+func (gwr *GatewayAPIRouter) Finalize(canary *flaggerv1.Canary) error {
+	apexName, _, _ := canary.GetServiceNames()
+	client := gwr.gatewayAPIClient.GatewayapiV1alpha2().HTTPRoutes(canary.Namespace)
+
+	deleted, err := finalizeGeneratedObject(canary, "HTTPRoute", fmt.Sprintf("%s.%s", apexName, canary.Namespace),
+		func() (metav1.Object, error) {
+			return client.Get(context.TODO(), apexName, metav1.GetOptions{})
+		},
+		func() error {
+			return client.Delete(context.TODO(), apexName, metav1.DeleteOptions{})
+		})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		gwr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("HTTPRoute %s.%s deleted", apexName, canary.Namespace)
+	}
 	return nil
 }
 
@@ -1,3 +1,6 @@
+//go:build !no_gatewayapi
+// +build !no_gatewayapi
+
 /*
 Copyright 2020 The Flux authors
 
@@ -22,6 +25,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -70,3 +74,26 @@ func TestGatewayAPIRouter_Routes(t *testing.T) {
 	primary := httpRoute.Spec.Rules[0].BackendRefs[0]
 	assert.Equal(t, int32(50), *primary.Weight)
 }
+
+func TestGatewayAPIRouter_Finalize(t *testing.T) {
+	canary := newTestGatewayAPICanary()
+	mocks := newFixture(canary)
+	router := &GatewayAPIRouter{
+		gatewayAPIClient: mocks.meshClient,
+		kubeClient:       mocks.kubeClient,
+		logger:           mocks.logger,
+	}
+
+	err := router.Reconcile(canary)
+	require.NoError(t, err)
+
+	err = router.Finalize(canary)
+	require.NoError(t, err)
+
+	_, err = router.gatewayAPIClient.GatewayapiV1alpha2().HTTPRoutes("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	// finalize is idempotent
+	err = router.Finalize(canary)
+	require.NoError(t, err)
+}
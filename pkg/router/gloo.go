@@ -1,3 +1,6 @@
+//go:build !no_gloo
+// +build !no_gloo
+
 /*
 Copyright 2020 The Flux authors
 
@@ -36,6 +39,17 @@ import (
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.GlooProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &GlooRouter{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			glooClient:    factory.meshClient,
+		}
+	})
+}
+
 // GlooRouter is managing Gloo route tables
 type GlooRouter struct {
 	kubeClient         kubernetes.Interface
@@ -69,6 +83,7 @@ func (gr *GlooRouter) Reconcile(canary *flaggerv1.Canary) error {
 			{
 				InheritablePathMatchers: true,
 				Matchers:                getMatchers(canary),
+				Options:                 getRouteOptions(canary),
 				Action: gatewayv1.RouteAction{
 					Destination: gatewayv1.MultiDestination{
 						Destinations: []gatewayv1.WeightedDestination{
@@ -127,7 +142,7 @@ func (gr *GlooRouter) Reconcile(canary *flaggerv1.Canary) error {
 			Spec: newSpec,
 		}
 
-		_, err = gr.glooClient.GatewayV1().RouteTables(canary.Namespace).Create(context.TODO(), routeTable, metav1.CreateOptions{})
+		_, err = gr.glooClient.GatewayV1().RouteTables(canary.Namespace).Create(context.TODO(), routeTable, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("RouteTable %s.%s create error: %w", apexName, canary.Namespace, err)
 		}
@@ -148,7 +163,7 @@ func (gr *GlooRouter) Reconcile(canary *flaggerv1.Canary) error {
 			clone := routeTable.DeepCopy()
 			clone.Spec = newSpec
 
-			_, err = gr.glooClient.GatewayV1().RouteTables(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{})
+			_, err = gr.glooClient.GatewayV1().RouteTables(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("RouteTable %s.%s update error: %w", apexName, canary.Namespace, err)
 			}
@@ -217,6 +232,7 @@ func (gr *GlooRouter) SetRoutes(
 			{
 				InheritablePathMatchers: true,
 				Matchers:                getMatchers(canary),
+				Options:                 getRouteOptions(canary),
 				Action: gatewayv1.RouteAction{
 					Destination: gatewayv1.MultiDestination{
 						Destinations: []gatewayv1.WeightedDestination{
@@ -245,14 +261,46 @@ func (gr *GlooRouter) SetRoutes(
 		},
 	}
 
-	_, err = gr.glooClient.GatewayV1().RouteTables(canary.Namespace).Update(context.TODO(), routeTable, metav1.UpdateOptions{})
+	_, err = gr.glooClient.GatewayV1().RouteTables(canary.Namespace).Update(context.TODO(), routeTable, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("RouteTable %s.%s update error: %w", apexName, canary.Namespace, err)
 	}
 	return nil
 }
 
-func (gr *GlooRouter) Finalize(_ *flaggerv1.Canary) error {
+// Finalize deletes the route table and upstreams generated by Flagger
+func (gr *GlooRouter) Finalize(canary *flaggerv1.Canary) error {
+	apexName, _, _ := canary.GetServiceNames()
+	routeTables := gr.glooClient.GatewayV1().RouteTables(canary.Namespace)
+
+	deleted, err := finalizeGeneratedObject(canary, "RouteTable", fmt.Sprintf("%s.%s", apexName, canary.Namespace),
+		func() (metav1.Object, error) {
+			return routeTables.Get(context.TODO(), apexName, metav1.GetOptions{})
+		},
+		func() error {
+			return routeTables.Delete(context.TODO(), apexName, metav1.DeleteOptions{})
+		})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		gr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("RouteTable %s.%s deleted", apexName, canary.Namespace)
+	}
+
+	upstreams := gr.glooClient.GlooV1().Upstreams(canary.Namespace)
+	for _, role := range []string{"primary", "canary"} {
+		upstreamName := fmt.Sprintf("%s-%s-%supstream-%v", canary.Namespace, apexName, role, canary.Spec.Service.Port)
+		if _, err := finalizeGeneratedObject(canary, "Upstream", fmt.Sprintf("%s.%s", upstreamName, canary.Namespace),
+			func() (metav1.Object, error) {
+				return upstreams.Get(context.TODO(), upstreamName, metav1.GetOptions{})
+			},
+			func() error {
+				return upstreams.Delete(context.TODO(), upstreamName, metav1.DeleteOptions{})
+			}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -267,24 +315,36 @@ func (gr *GlooRouter) createFlaggerUpstream(canary *flaggerv1.Canary, upstreamNa
 	if err != nil {
 		return fmt.Errorf("service %s.%s get query error: %w", svcName, canary.Namespace, err)
 	}
-	_, err = upstreamClient.Get(context.TODO(), upstreamName, metav1.GetOptions{})
+	upstream, err := upstreamClient.Get(context.TODO(), upstreamName, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		glooUpstreamWithConfig, err := gr.getGlooConfigUpstream(canary)
 		if err != nil {
 			return err
 		}
-		canaryUs := gr.getGlooUpstreamKubeService(canary, svc, upstreamName, glooUpstreamWithConfig)
-		_, err = gr.glooClient.GlooV1().Upstreams(canary.Namespace).Create(context.TODO(), canaryUs, metav1.CreateOptions{})
+		canaryUs := gr.getGlooUpstreamKubeService(canary, svc, upstreamName, glooUpstreamWithConfig, isCanary)
+		_, err = gr.glooClient.GlooV1().Upstreams(canary.Namespace).Create(context.TODO(), canaryUs, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("upstream %s.%s create query error: %w", upstreamName, canary.Namespace, err)
 		}
 	} else if err != nil {
 		return fmt.Errorf("upstream %s.%s get query error: %w", upstreamName, canary.Namespace, err)
+	} else if maxRetries := getRetryBudget(canary, isCanary); maxRetries > 0 {
+		// keep the retry budget in sync with the canary spec
+		if upstream.Spec.CircuitBreakers == nil || upstream.Spec.CircuitBreakers.MaxRetries != maxRetries {
+			clone := upstream.DeepCopy()
+			clone.Spec.CircuitBreakers = withMaxRetries(clone.Spec.CircuitBreakers, maxRetries)
+			_, err = upstreamClient.Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
+			if err != nil {
+				return fmt.Errorf("upstream %s.%s update query error: %w", upstreamName, canary.Namespace, err)
+			}
+			gr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+				Infof("Upstream %s.%s retry budget set to %v", upstreamName, canary.Namespace, maxRetries)
+		}
 	}
 	return nil
 }
 
-func (gr *GlooRouter) getGlooUpstreamKubeService(canary *flaggerv1.Canary, svc *corev1.Service, upstreamName string, glooUpstreamWithConfig *gloov1.Upstream) *gloov1.Upstream {
+func (gr *GlooRouter) getGlooUpstreamKubeService(canary *flaggerv1.Canary, svc *corev1.Service, upstreamName string, glooUpstreamWithConfig *gloov1.Upstream, isCanary bool) *gloov1.Upstream {
 
 	upstreamSpec := gloov1.UpstreamSpec{}
 	if glooUpstreamWithConfig != nil {
@@ -307,6 +367,9 @@ func (gr *GlooRouter) getGlooUpstreamKubeService(canary *flaggerv1.Canary, svc *
 		ServicePort:      canary.Spec.Service.Port,
 		Selector:         svc.Spec.Selector,
 	}
+	if maxRetries := getRetryBudget(canary, isCanary); maxRetries > 0 {
+		upstreamSpec.CircuitBreakers = withMaxRetries(upstreamSpec.CircuitBreakers, maxRetries)
+	}
 
 	upstreamLabels := includeLabelsByPrefix(upstreamSpec.Labels, gr.includeLabelPrefix)
 
@@ -339,6 +402,44 @@ func (gr *GlooRouter) getGlooConfigUpstream(canary *flaggerv1.Canary) (*gloov1.U
 	return configUpstream, nil
 }
 
+// getRouteOptions maps the canary retry policy to the Gloo route retries
+func getRouteOptions(canary *flaggerv1.Canary) *gatewayv1.RouteOptions {
+	retries := canary.Spec.Service.Retries
+	if retries == nil {
+		return nil
+	}
+	return &gatewayv1.RouteOptions{
+		Retries: &gatewayv1.RetryPolicy{
+			RetryOn:       retries.RetryOn,
+			NumRetries:    uint32(retries.Attempts),
+			PerTryTimeout: retries.PerTryTimeout,
+		},
+	}
+}
+
+// getRetryBudget returns the max concurrent retries of the primary or canary upstream,
+// zero means the Gloo default is used
+func getRetryBudget(canary *flaggerv1.Canary, isCanary bool) uint32 {
+	budget := canary.Spec.Service.RetryBudget
+	if budget == nil {
+		return 0
+	}
+	if isCanary {
+		return budget.Canary
+	}
+	return budget.Primary
+}
+
+// withMaxRetries returns a copy of the circuit breakers with the max retries set
+func withMaxRetries(cb *gloov1.CircuitBreakerConfig, maxRetries uint32) *gloov1.CircuitBreakerConfig {
+	out := &gloov1.CircuitBreakerConfig{}
+	if cb != nil {
+		*out = *cb
+	}
+	out.MaxRetries = maxRetries
+	return out
+}
+
 func getMatchers(canary *flaggerv1.Canary) []gatewayv1.Matcher {
 
 	headerMatchers := getHeaderMatchers(canary)
@@ -1,3 +1,6 @@
+//go:build !no_gloo
+// +build !no_gloo
+
 /*
 Copyright 2020 The Flux authors
 
@@ -21,10 +24,13 @@ import (
 	"fmt"
 	"testing"
 
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 	gatewayv1 "github.com/fluxcd/flagger/pkg/apis/gloo/gateway/v1"
+	istiov1alpha3 "github.com/fluxcd/flagger/pkg/apis/istio/v1alpha3"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -176,3 +182,88 @@ func TestGlooRouter_GetRoutes(t *testing.T) {
 	assert.Equal(t, 0, c)
 	assert.False(t, m)
 }
+
+func TestGlooRouter_Finalize(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &GlooRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		glooClient:    mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+	svcRouter := &KubernetesDefaultRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+	}
+	err := svcRouter.Initialize(mocks.canary)
+	require.NoError(t, err)
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	err = router.Finalize(mocks.canary)
+	require.NoError(t, err)
+
+	_, err = router.glooClient.GatewayV1().RouteTables("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	upstreams, err := router.glooClient.GlooV1().Upstreams("default").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, upstreams.Items)
+
+	// finalize is idempotent
+	err = router.Finalize(mocks.canary)
+	require.NoError(t, err)
+}
+
+func TestGlooRouter_RetryBudget(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &GlooRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		glooClient:    mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+	svcRouter := &KubernetesDefaultRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+	}
+	err := svcRouter.Initialize(mocks.canary)
+	require.NoError(t, err)
+
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.Service.Retries = &istiov1alpha3.HTTPRetry{
+		Attempts:      3,
+		PerTryTimeout: "1s",
+		RetryOn:       "5xx",
+	}
+	cd.Spec.Service.RetryBudget = &flaggerv1.RetryBudget{
+		Primary: 10,
+		Canary:  1,
+	}
+	err = router.Reconcile(cd)
+	require.NoError(t, err)
+
+	rt, err := router.glooClient.GatewayV1().RouteTables("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, rt.Spec.Routes[0].Options)
+	assert.Equal(t, &gatewayv1.RetryPolicy{RetryOn: "5xx", NumRetries: 3, PerTryTimeout: "1s"}, rt.Spec.Routes[0].Options.Retries)
+
+	primaryUs, err := router.glooClient.GlooV1().Upstreams("default").Get(context.TODO(), "default-podinfo-primaryupstream-9898", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(10), primaryUs.Spec.CircuitBreakers.MaxRetries)
+
+	canaryUs, err := router.glooClient.GlooV1().Upstreams("default").Get(context.TODO(), "default-podinfo-canaryupstream-9898", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), canaryUs.Spec.CircuitBreakers.MaxRetries)
+
+	// test budget update
+	cd.Spec.Service.RetryBudget.Canary = 2
+	err = router.Reconcile(cd)
+	require.NoError(t, err)
+
+	canaryUs, err = router.glooClient.GlooV1().Upstreams("default").Get(context.TODO(), "default-podinfo-canaryupstream-9898", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), canaryUs.Spec.CircuitBreakers.MaxRetries)
+}
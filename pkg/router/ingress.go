@@ -1,3 +1,6 @@
+//go:build !no_nginx
+// +build !no_nginx
+
 /*
 Copyright 2020 The Flux authors
 
@@ -33,6 +36,16 @@ import (
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.NGINXProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &IngressRouter{
+			logger:            factory.logger,
+			kubeClient:        factory.kubeClient,
+			annotationsPrefix: factory.ingressAnnotationsPrefix,
+		}
+	})
+}
+
 type IngressRouter struct {
 	kubeClient        kubernetes.Interface
 	annotationsPrefix string
@@ -90,7 +103,7 @@ func (i *IngressRouter) Reconcile(canary *flaggerv1.Canary) error {
 			Spec: ingressClone.Spec,
 		}
 
-		_, err := i.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Create(context.TODO(), ing, metav1.CreateOptions{})
+		_, err := i.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Create(context.TODO(), ing, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("ingress %s.%s create error: %w", ing.Name, ing.Namespace, err)
 		}
@@ -106,7 +119,7 @@ func (i *IngressRouter) Reconcile(canary *flaggerv1.Canary) error {
 		iClone := canaryIngress.DeepCopy()
 		iClone.Spec = ingressClone.Spec
 
-		_, err := i.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Update(context.TODO(), iClone, metav1.UpdateOptions{})
+		_, err := i.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Update(context.TODO(), iClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("ingress %s.%s update error: %w", canaryIngressName, iClone.Namespace, err)
 		}
@@ -192,6 +205,14 @@ func (i *IngressRouter) SetRoutes(
 	} else {
 		// canary
 		iClone.Annotations[i.GetAnnotationWithPrefix("canary-weight")] = fmt.Sprintf("%v", canaryWeight)
+
+		// pin the users routed to the canary with a sticky cookie
+		if affinity := canary.GetSessionAffinity(); affinity != nil {
+			iClone.Annotations[i.GetAnnotationWithPrefix("affinity")] = "cookie"
+			iClone.Annotations[i.GetAnnotationWithPrefix("affinity-canary-behavior")] = "sticky"
+			iClone.Annotations[i.GetAnnotationWithPrefix("session-cookie-name")] = affinity.CookieName
+			iClone.Annotations[i.GetAnnotationWithPrefix("session-cookie-max-age")] = strconv.Itoa(affinity.GetMaxAge())
+		}
 	}
 
 	// toggle canary
@@ -201,7 +222,7 @@ func (i *IngressRouter) SetRoutes(
 		iClone.Annotations = i.makeAnnotations(iClone.Annotations)
 	}
 
-	_, err = i.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Update(context.TODO(), iClone, metav1.UpdateOptions{})
+	_, err = i.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Update(context.TODO(), iClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("ingress %s.%s update error %v", iClone.Name, iClone.Namespace, err)
 	}
@@ -263,6 +284,28 @@ func (i *IngressRouter) GetAnnotationWithPrefix(suffix string) string {
 	return fmt.Sprintf("%v/%v", i.annotationsPrefix, suffix)
 }
 
-func (i *IngressRouter) Finalize(_ *flaggerv1.Canary) error {
+// Finalize deletes the canary ingress generated by Flagger
+func (i *IngressRouter) Finalize(canary *flaggerv1.Canary) error {
+	if canary.Spec.IngressRef == nil || canary.Spec.IngressRef.Name == "" {
+		return nil
+	}
+
+	canaryIngressName := fmt.Sprintf("%s-canary", canary.Spec.IngressRef.Name)
+	client := i.kubeClient.NetworkingV1().Ingresses(canary.Namespace)
+
+	deleted, err := finalizeGeneratedObject(canary, "ingress", fmt.Sprintf("%s.%s", canaryIngressName, canary.Namespace),
+		func() (metav1.Object, error) {
+			return client.Get(context.TODO(), canaryIngressName, metav1.GetOptions{})
+		},
+		func() error {
+			return client.Delete(context.TODO(), canaryIngressName, metav1.DeleteOptions{})
+		})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		i.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("Ingress %s.%s deleted", canaryIngressName, canary.Namespace)
+	}
 	return nil
 }
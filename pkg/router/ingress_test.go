@@ -1,3 +1,6 @@
+//go:build !no_nginx
+// +build !no_nginx
+
 /*
 Copyright 2020 The Flux authors
 
@@ -23,6 +26,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -100,6 +104,33 @@ func TestIngressRouter_GetSetRoutes(t *testing.T) {
 	assert.Equal(t, "0", inCanary.Annotations[canaryWeightAn])
 }
 
+func TestIngressRouter_SessionAffinity(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &IngressRouter{
+		logger:            mocks.logger,
+		kubeClient:        mocks.kubeClient,
+		annotationsPrefix: "nginx.ingress.kubernetes.io",
+	}
+
+	mocks.ingressCanary.Spec.Analysis.SessionAffinity = &flaggerv1.SessionAffinity{CookieName: "flagger-cookie"}
+
+	err := router.Reconcile(mocks.ingressCanary)
+	require.NoError(t, err)
+
+	err = router.SetRoutes(mocks.ingressCanary, 90, 10, false)
+	require.NoError(t, err)
+
+	canaryName := fmt.Sprintf("%s-canary", mocks.ingressCanary.Spec.IngressRef.Name)
+	inCanary, err := router.kubeClient.NetworkingV1().Ingresses("default").Get(context.TODO(), canaryName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "10", inCanary.Annotations["nginx.ingress.kubernetes.io/canary-weight"])
+	assert.Equal(t, "cookie", inCanary.Annotations["nginx.ingress.kubernetes.io/affinity"])
+	assert.Equal(t, "sticky", inCanary.Annotations["nginx.ingress.kubernetes.io/affinity-canary-behavior"])
+	assert.Equal(t, "flagger-cookie", inCanary.Annotations["nginx.ingress.kubernetes.io/session-cookie-name"])
+	assert.Equal(t, "86400", inCanary.Annotations["nginx.ingress.kubernetes.io/session-cookie-max-age"])
+}
+
 func TestIngressRouter_ABTest(t *testing.T) {
 	mocks := newFixture(nil)
 	router := &IngressRouter{
@@ -183,3 +214,29 @@ func TestIngressRouter_ABTest(t *testing.T) {
 		assert.Equal(t, "test", inCanary.Annotations[table.annotation])
 	}
 }
+
+func TestIngressRouter_Finalize(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &IngressRouter{
+		logger:            mocks.logger,
+		kubeClient:        mocks.kubeClient,
+		annotationsPrefix: "custom.ingress.kubernetes.io",
+	}
+
+	err := router.Reconcile(mocks.ingressCanary)
+	require.NoError(t, err)
+
+	err = router.Finalize(mocks.ingressCanary)
+	require.NoError(t, err)
+
+	canaryName := fmt.Sprintf("%s-canary", mocks.ingressCanary.Spec.IngressRef.Name)
+	_, err = router.kubeClient.NetworkingV1().Ingresses("default").Get(context.TODO(), canaryName, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	_, err = router.kubeClient.NetworkingV1().Ingresses("default").Get(context.TODO(), mocks.ingressCanary.Spec.IngressRef.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// finalize is idempotent
+	err = router.Finalize(mocks.ingressCanary)
+	require.NoError(t, err)
+}
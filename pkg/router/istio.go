@@ -1,3 +1,6 @@
+//go:build !no_istio
+// +build !no_istio
+
 /*
 Copyright 2020 The Flux authors
 
@@ -20,6 +23,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"regexp"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -30,19 +34,34 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	istiov1alpha1 "github.com/fluxcd/flagger/pkg/apis/istio/common/v1alpha1"
 	istiov1alpha3 "github.com/fluxcd/flagger/pkg/apis/istio/v1alpha3"
+	telemetryv1alpha1 "github.com/fluxcd/flagger/pkg/apis/telemetry/v1alpha1"
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.IstioProvider, func(factory *Factory, _ string, labelSelector string) Interface {
+		return &IstioRouter{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			istioClient:   factory.meshClient,
+			labelSelector: labelSelector,
+		}
+	})
+}
+
 // IstioRouter is managing Istio virtual services
 type IstioRouter struct {
 	kubeClient    kubernetes.Interface
 	istioClient   clientset.Interface
 	flaggerClient clientset.Interface
 	logger        *zap.SugaredLogger
+	labelSelector string
 }
 
-// Reconcile creates or updates the Istio virtual service and destination rules
+// Reconcile creates or updates the Istio virtual service, destination rules and telemetry
 func (ir *IstioRouter) Reconcile(canary *flaggerv1.Canary) error {
 	_, primaryName, canaryName := canary.GetServiceNames()
 
@@ -57,6 +76,112 @@ func (ir *IstioRouter) Reconcile(canary *flaggerv1.Canary) error {
 	if err := ir.reconcileVirtualService(canary); err != nil {
 		return fmt.Errorf("reconcileVirtualService failed: %w", err)
 	}
+
+	if canary.Spec.Service.Telemetry != nil {
+		targetName := canary.Spec.TargetRef.Name
+		primaryLabelValue, err := canary.GetPrimaryLabelValue(targetName)
+		if err != nil {
+			return err
+		}
+		primaryTargetName, err := canary.GetPrimaryName(targetName)
+		if err != nil {
+			return err
+		}
+		if err := ir.reconcileTelemetry(canary, primaryName, primaryLabelValue, primaryTargetName); err != nil {
+			return fmt.Errorf("reconcileTelemetry failed: %w", err)
+		}
+
+		if err := ir.reconcileTelemetry(canary, canaryName, targetName, fmt.Sprintf("%s-canary", targetName)); err != nil {
+			return fmt.Errorf("reconcileTelemetry failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// reconcileTelemetry creates or updates the Istio telemetry that adds the workload name
+// as a dimension of the standard metrics reported by the pods matching the selector,
+// the value is specific to the canary so that the queries can't match other workloads in the namespace
+func (ir *IstioRouter) reconcileTelemetry(canary *flaggerv1.Canary, name string, podSelector string, value string) error {
+	providers := []telemetryv1alpha1.ProviderRef{}
+	for _, provider := range canary.Spec.Service.Telemetry.Providers {
+		providers = append(providers, telemetryv1alpha1.ProviderRef{Name: provider})
+	}
+	if len(providers) == 0 {
+		providers = append(providers, telemetryv1alpha1.ProviderRef{Name: "prometheus"})
+	}
+
+	labelSelector := ir.labelSelector
+	if labelSelector == "" {
+		labelSelector = "app"
+	}
+
+	newSpec := telemetryv1alpha1.TelemetrySpec{
+		Selector: &telemetryv1alpha1.WorkloadSelector{
+			MatchLabels: map[string]string{
+				labelSelector: podSelector,
+			},
+		},
+		Metrics: []telemetryv1alpha1.Metrics{
+			{
+				Providers: providers,
+				Overrides: []telemetryv1alpha1.MetricsOverrides{
+					{
+						Match: &telemetryv1alpha1.MetricSelector{
+							Metric: telemetryv1alpha1.AllMetrics,
+							Mode:   telemetryv1alpha1.ClientAndServerMode,
+						},
+						TagOverrides: map[string]telemetryv1alpha1.TagOverride{
+							canary.Spec.Service.Telemetry.GetTag(): {
+								Operation: telemetryv1alpha1.UpsertOperation,
+								Value:     fmt.Sprintf("'%s'", value),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	telemetry, err := ir.istioClient.TelemetryV1alpha1().Telemetries(canary.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	// insert
+	if errors.IsNotFound(err) {
+		telemetry = &telemetryv1alpha1.Telemetry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: canary.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(canary, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: newSpec,
+		}
+		_, err = ir.istioClient.TelemetryV1alpha1().Telemetries(canary.Namespace).Create(context.TODO(), telemetry, metav1.CreateOptions{FieldManager: canary.FieldManager()})
+		if err != nil {
+			return fmt.Errorf("Telemetry %s.%s create error: %w", name, canary.Namespace, err)
+		}
+		ir.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("Telemetry %s.%s created", telemetry.GetName(), canary.Namespace)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Telemetry %s.%s get query error: %w", name, canary.Namespace, err)
+	}
+
+	// update
+	if diff := cmp.Diff(newSpec, telemetry.Spec); diff != "" {
+		clone := telemetry.DeepCopy()
+		clone.Spec = newSpec
+		_, err = ir.istioClient.TelemetryV1alpha1().Telemetries(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
+		if err != nil {
+			return fmt.Errorf("Telemetry %s.%s update error: %w", name, canary.Namespace, err)
+		}
+		ir.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("Telemetry %s.%s updated", telemetry.GetName(), canary.Namespace)
+	}
+
 	return nil
 }
 
@@ -83,7 +208,7 @@ func (ir *IstioRouter) reconcileDestinationRule(canary *flaggerv1.Canary, name s
 			},
 			Spec: newSpec,
 		}
-		_, err = ir.istioClient.NetworkingV1alpha3().DestinationRules(canary.Namespace).Create(context.TODO(), destinationRule, metav1.CreateOptions{})
+		_, err = ir.istioClient.NetworkingV1alpha3().DestinationRules(canary.Namespace).Create(context.TODO(), destinationRule, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("DestinationRule %s.%s create error: %w", name, canary.Namespace, err)
 		}
@@ -99,7 +224,7 @@ func (ir *IstioRouter) reconcileDestinationRule(canary *flaggerv1.Canary, name s
 		if diff := cmp.Diff(newSpec, destinationRule.Spec); diff != "" {
 			clone := destinationRule.DeepCopy()
 			clone.Spec = newSpec
-			_, err = ir.istioClient.NetworkingV1alpha3().DestinationRules(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{})
+			_, err = ir.istioClient.NetworkingV1alpha3().DestinationRules(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("DestinationRule %s.%s update error: %w", name, canary.Namespace, err)
 			}
@@ -112,7 +237,7 @@ func (ir *IstioRouter) reconcileDestinationRule(canary *flaggerv1.Canary, name s
 }
 
 func (ir *IstioRouter) reconcileVirtualService(canary *flaggerv1.Canary) error {
-	apexName, primaryName, canaryName := canary.GetServiceNames()
+	apexName, _, _ := canary.GetServiceNames()
 
 	if canary.Spec.Service.Delegation {
 		if len(canary.Spec.Service.Hosts) > 0 || len(canary.Spec.Service.Gateways) > 0 {
@@ -149,58 +274,17 @@ func (ir *IstioRouter) reconcileVirtualService(canary *flaggerv1.Canary) error {
 		gateways = append(gateways, "mesh")
 	}
 
-	// create destinations with primary weight 100% and canary weight 0%
-	canaryRoute := []istiov1alpha3.DestinationWeight{
-		makeDestination(canary, primaryName, 100),
-		makeDestination(canary, canaryName, 0),
-	}
-
 	if canary.Spec.Service.Delegation {
 		// delegate VirtualService requires the hosts and gateway empty.
 		hosts = []string{}
 		gateways = []string{}
 	}
 
+	// create destinations with primary weight 100% and canary weight 0%
 	newSpec := istiov1alpha3.VirtualServiceSpec{
 		Hosts:    hosts,
 		Gateways: gateways,
-		Http: []istiov1alpha3.HTTPRoute{
-			{
-				Match:      canary.Spec.Service.Match,
-				Rewrite:    canary.Spec.Service.Rewrite,
-				Timeout:    canary.Spec.Service.Timeout,
-				Retries:    canary.Spec.Service.Retries,
-				CorsPolicy: canary.Spec.Service.CorsPolicy,
-				Headers:    canary.Spec.Service.Headers,
-				Route:      canaryRoute,
-			},
-		},
-	}
-
-	if len(canary.GetAnalysis().Match) > 0 {
-		canaryMatch := mergeMatchConditions(canary.GetAnalysis().Match, canary.Spec.Service.Match)
-		newSpec.Http = []istiov1alpha3.HTTPRoute{
-			{
-				Match:      canaryMatch,
-				Rewrite:    canary.Spec.Service.Rewrite,
-				Timeout:    canary.Spec.Service.Timeout,
-				Retries:    canary.Spec.Service.Retries,
-				CorsPolicy: canary.Spec.Service.CorsPolicy,
-				Headers:    canary.Spec.Service.Headers,
-				Route:      canaryRoute,
-			},
-			{
-				Match:      canary.Spec.Service.Match,
-				Rewrite:    canary.Spec.Service.Rewrite,
-				Timeout:    canary.Spec.Service.Timeout,
-				Retries:    canary.Spec.Service.Retries,
-				CorsPolicy: canary.Spec.Service.CorsPolicy,
-				Headers:    canary.Spec.Service.Headers,
-				Route: []istiov1alpha3.DestinationWeight{
-					makeDestination(canary, primaryName, 100),
-				},
-			},
-		}
+		Http:     makeHTTPRoutes(canary, 100, 0, false),
 	}
 
 	virtualService, err := ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Get(context.TODO(), apexName, metav1.GetOptions{})
@@ -233,7 +317,7 @@ func (ir *IstioRouter) reconcileVirtualService(canary *flaggerv1.Canary) error {
 			},
 			Spec: newSpec,
 		}
-		_, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Create(context.TODO(), virtualService, metav1.CreateOptions{})
+		_, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Create(context.TODO(), virtualService, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("VirtualService %s.%s create error: %w", apexName, canary.Namespace, err)
 		}
@@ -279,7 +363,7 @@ func (ir *IstioRouter) reconcileVirtualService(canary *flaggerv1.Canary) error {
 				vtClone.ObjectMeta.Annotations[configAnnotation] = string(b)
 			}
 
-			_, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Update(context.TODO(), vtClone, metav1.UpdateOptions{})
+			_, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Update(context.TODO(), vtClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("VirtualService %s.%s update error: %w", apexName, canary.Namespace, err)
 			}
@@ -329,8 +413,8 @@ func (ir *IstioRouter) GetRoutes(canary *flaggerv1.Canary) (
 	}
 
 	if primaryWeight == 0 && canaryWeight == 0 {
-		err = fmt.Errorf("VirtualService %s.%s does not contain routes for %s-primary and %s-canary",
-			apexName, canary.Namespace, apexName, apexName)
+		err = fmt.Errorf("VirtualService %s.%s does not contain routes for %s and %s",
+			apexName, canary.Namespace, primaryName, canaryName)
 	}
 
 	return
@@ -343,7 +427,7 @@ func (ir *IstioRouter) SetRoutes(
 	canaryWeight int,
 	mirrored bool,
 ) error {
-	apexName, primaryName, canaryName := canary.GetServiceNames()
+	apexName, _, _ := canary.GetServiceNames()
 
 	vs, err := ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Get(context.TODO(), apexName, metav1.GetOptions{})
 	if err != nil {
@@ -352,105 +436,195 @@ func (ir *IstioRouter) SetRoutes(
 
 	vsCopy := vs.DeepCopy()
 
+	vsCopy.Spec.Http = makeHTTPRoutes(canary, primaryWeight, canaryWeight, mirrored)
+
+	vs, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Update(context.TODO(), vsCopy, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
+	if err != nil {
+		return fmt.Errorf("VirtualService %s.%s update failed: %w", apexName, canary.Namespace, err)
+	}
+	return nil
+}
+
+// Finalize reverts the VirtualService to the original configuration stored in the annotations
+func (ir *IstioRouter) Finalize(canary *flaggerv1.Canary) error {
+	apexName, _, _ := canary.GetServiceNames()
+
+	vs, err := ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Get(context.TODO(), apexName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("VirtualService %s.%s get query error: %w", apexName, canary.Namespace, err)
+	}
+
+	var storedSpec istiov1alpha3.VirtualServiceSpec
+	if a, ok := vs.ObjectMeta.Annotations[kubectlAnnotation]; ok {
+		var storedVS istiov1alpha3.VirtualService
+		if err := json.Unmarshal([]byte(a), &storedVS); err != nil {
+			return fmt.Errorf("VirtualService %s.%s failed to unMarshal annotation %s",
+				apexName, canary.Namespace, kubectlAnnotation)
+		}
+		storedSpec = storedVS.Spec
+	} else if a, ok := vs.ObjectMeta.Annotations[configAnnotation]; ok {
+		if err := json.Unmarshal([]byte(a), &storedSpec); err != nil {
+			return fmt.Errorf("VirtualService %s.%s failed to unMarshal annotation %s",
+				apexName, canary.Namespace, configAnnotation)
+		}
+	} else {
+		ir.logger.Warnf("VirtualService %s.%s original configuration not found, unable to revert", apexName, canary.Namespace)
+		return nil
+	}
+
+	clone := vs.DeepCopy()
+	clone.Spec = storedSpec
+
+	_, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
+	if err != nil {
+		return fmt.Errorf("VirtualService %s.%s update error: %w", apexName, canary.Namespace, err)
+	}
+	return nil
+}
+
+// makeHTTPRoutes returns the weighted routes of the virtual service, when additional ports
+// are declared the routes are generated for each port so that all ports are shifted together
+func makeHTTPRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int, mirrored bool) []istiov1alpha3.HTTPRoute {
+	if len(canary.Spec.Service.AdditionalPorts) == 0 {
+		return makePortHTTPRoutes(canary, 0, primaryWeight, canaryWeight, mirrored)
+	}
+
+	var routes []istiov1alpha3.HTTPRoute
+	for _, port := range canary.GetServicePorts() {
+		routes = append(routes, makePortHTTPRoutes(canary, uint32(port.Port), primaryWeight, canaryWeight, mirrored)...)
+	}
+	return routes
+}
+
+// makePortHTTPRoutes returns the weighted routes matching the given port,
+// the routes match all ports when the port is zero
+func makePortHTTPRoutes(canary *flaggerv1.Canary, port uint32, primaryWeight int, canaryWeight int, mirrored bool) []istiov1alpha3.HTTPRoute {
+	_, primaryName, canaryName := canary.GetServiceNames()
+
 	// weighted routing (progressive canary)
-	vsCopy.Spec.Http = []istiov1alpha3.HTTPRoute{
+	routes := []istiov1alpha3.HTTPRoute{
 		{
-			Match:      canary.Spec.Service.Match,
+			Match:      matchPort(canary.Spec.Service.Match, port),
 			Rewrite:    canary.Spec.Service.Rewrite,
 			Timeout:    canary.Spec.Service.Timeout,
 			Retries:    canary.Spec.Service.Retries,
 			CorsPolicy: canary.Spec.Service.CorsPolicy,
 			Headers:    canary.Spec.Service.Headers,
 			Route: []istiov1alpha3.DestinationWeight{
-				makeDestination(canary, primaryName, primaryWeight),
-				makeDestination(canary, canaryName, canaryWeight),
+				makePortDestination(canary, primaryName, port, primaryWeight),
+				makePortDestination(canary, canaryName, port, canaryWeight),
 			},
 		},
 	}
 
 	if mirrored {
-		vsCopy.Spec.Http[0].Mirror = &istiov1alpha3.Destination{
+		routes[0].Mirror = &istiov1alpha3.Destination{
 			Host: canaryName,
 		}
+		if port > 0 {
+			routes[0].Mirror.Port = &istiov1alpha3.PortSelector{Number: port}
+		}
 
 		if mw := canary.GetAnalysis().MirrorWeight; mw > 0 {
-			vsCopy.Spec.Http[0].MirrorPercentage = &istiov1alpha3.Percent{Value: float64(mw)}
+			routes[0].MirrorPercentage = &istiov1alpha3.Percent{Value: float64(mw)}
 		}
 	}
 
+	// pin the users routed to the canary with a cookie
+	if affinity := canary.GetSessionAffinity(); affinity != nil && len(canary.GetAnalysis().Match) == 0 {
+		routes[0].Route[1].Headers = &istiov1alpha3.Headers{
+			Response: &istiov1alpha3.HeaderOperations{
+				Add: map[string]string{"Set-Cookie": makeSetCookie(canary, affinity)},
+			},
+		}
+
+		stickyPrimaryWeight, stickyCanaryWeight := makeStickyWeights(canaryWeight)
+		stickyMatch := mergeMatchConditions([]istiov1alpha3.HTTPMatchRequest{
+			{
+				Headers: map[string]istiov1alpha1.StringMatch{
+					"Cookie": {Regex: fmt.Sprintf(".*%s=%s.*", regexp.QuoteMeta(affinity.CookieName), regexp.QuoteMeta(canary.GetCookieValue()))},
+				},
+			},
+		}, canary.Spec.Service.Match)
+		routes = append([]istiov1alpha3.HTTPRoute{
+			{
+				Match:      matchPort(stickyMatch, port),
+				Rewrite:    canary.Spec.Service.Rewrite,
+				Timeout:    canary.Spec.Service.Timeout,
+				Retries:    canary.Spec.Service.Retries,
+				CorsPolicy: canary.Spec.Service.CorsPolicy,
+				Headers:    canary.Spec.Service.Headers,
+				Route: []istiov1alpha3.DestinationWeight{
+					makePortDestination(canary, primaryName, port, stickyPrimaryWeight),
+					makePortDestination(canary, canaryName, port, stickyCanaryWeight),
+				},
+			},
+		}, routes...)
+	}
+
 	// fix routing (A/B testing)
 	if len(canary.GetAnalysis().Match) > 0 {
 		// merge the common routes with the canary ones
 		canaryMatch := mergeMatchConditions(canary.GetAnalysis().Match, canary.Spec.Service.Match)
-		vsCopy.Spec.Http = []istiov1alpha3.HTTPRoute{
+		routes = []istiov1alpha3.HTTPRoute{
 			{
-				Match:      canaryMatch,
+				Match:      matchPort(canaryMatch, port),
 				Rewrite:    canary.Spec.Service.Rewrite,
 				Timeout:    canary.Spec.Service.Timeout,
 				Retries:    canary.Spec.Service.Retries,
 				CorsPolicy: canary.Spec.Service.CorsPolicy,
 				Headers:    canary.Spec.Service.Headers,
 				Route: []istiov1alpha3.DestinationWeight{
-					makeDestination(canary, primaryName, primaryWeight),
-					makeDestination(canary, canaryName, canaryWeight),
+					makePortDestination(canary, primaryName, port, primaryWeight),
+					makePortDestination(canary, canaryName, port, canaryWeight),
 				},
 			},
 			{
-				Match:      canary.Spec.Service.Match,
+				Match:      matchPort(canary.Spec.Service.Match, port),
 				Rewrite:    canary.Spec.Service.Rewrite,
 				Timeout:    canary.Spec.Service.Timeout,
 				Retries:    canary.Spec.Service.Retries,
 				CorsPolicy: canary.Spec.Service.CorsPolicy,
 				Headers:    canary.Spec.Service.Headers,
 				Route: []istiov1alpha3.DestinationWeight{
-					makeDestination(canary, primaryName, primaryWeight),
+					makePortDestination(canary, primaryName, port, primaryWeight),
 				},
 			},
 		}
 	}
 
-	vs, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Update(context.TODO(), vsCopy, metav1.UpdateOptions{})
-	if err != nil {
-		return fmt.Errorf("VirtualService %s.%s update failed: %w", apexName, canary.Namespace, err)
-	}
-	return nil
+	return routes
 }
 
-func (ir *IstioRouter) Finalize(canary *flaggerv1.Canary) error {
-	// Need to see if I can get the annotation orig-configuration
-	apexName, _, _ := canary.GetServiceNames()
-
-	vs, err := ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Get(context.TODO(), apexName, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("VirtualService %s.%s get query error: %w", apexName, canary.Namespace, err)
+// matchPort returns a copy of the match conditions restricted to the given port
+func matchPort(match []istiov1alpha3.HTTPMatchRequest, port uint32) []istiov1alpha3.HTTPMatchRequest {
+	if port == 0 {
+		return match
 	}
-
-	var storedSpec istiov1alpha3.VirtualServiceSpec
-	if a, ok := vs.ObjectMeta.Annotations[kubectlAnnotation]; ok {
-		var storedVS istiov1alpha3.VirtualService
-		if err := json.Unmarshal([]byte(a), &storedVS); err != nil {
-			return fmt.Errorf("VirtualService %s.%s failed to unMarshal annotation %s",
-				apexName, canary.Namespace, kubectlAnnotation)
-		}
-		storedSpec = storedVS.Spec
-	} else if a, ok := vs.ObjectMeta.Annotations[configAnnotation]; ok {
-		if err := json.Unmarshal([]byte(a), &storedSpec); err != nil {
-			return fmt.Errorf("VirtualService %s.%s failed to unMarshal annotation %s",
-				apexName, canary.Namespace, configAnnotation)
-		}
-	} else {
-		ir.logger.Warnf("VirtualService %s.%s original configuration not found, unable to revert", apexName, canary.Namespace)
-		return nil
+	if len(match) == 0 {
+		return []istiov1alpha3.HTTPMatchRequest{{Port: port}}
 	}
 
-	clone := vs.DeepCopy()
-	clone.Spec = storedSpec
+	matchCopy := make([]istiov1alpha3.HTTPMatchRequest, 0, len(match))
+	for _, m := range match {
+		m := *m.DeepCopy()
+		m.Port = port
+		matchCopy = append(matchCopy, m)
+	}
+	return matchCopy
+}
 
-	_, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{})
-	if err != nil {
-		return fmt.Errorf("VirtualService %s.%s update error: %w", apexName, canary.Namespace, err)
+// makePortDestination returns a destination weight for the specified host and port,
+// the port is omitted when zero
+func makePortDestination(canary *flaggerv1.Canary, host string, port uint32, weight int) istiov1alpha3.DestinationWeight {
+	dest := makeDestination(canary, host, weight)
+	if port > 0 {
+		dest.Destination.Port = &istiov1alpha3.PortSelector{Number: port}
 	}
-	return nil
+	return dest
 }
 
 // mergeMatchConditions appends the URI match rules to canary conditions
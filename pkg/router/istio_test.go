@@ -1,3 +1,6 @@
+//go:build !no_istio
+// +build !no_istio
+
 /*
 Copyright 2020 The Flux authors
 
@@ -24,6 +27,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -99,6 +103,56 @@ func TestIstioRouter_Sync(t *testing.T) {
 	assert.Len(t, vs.Spec.Gateways, totalGateways)
 }
 
+func TestIstioRouter_Telemetry(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+		labelSelector: "app",
+	}
+
+	// telemetry is disabled by default
+	err := router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	_, err = mocks.meshClient.TelemetryV1alpha1().Telemetries("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	// test insert
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.Service.Telemetry = &v1beta1.IstioTelemetry{}
+	err = router.Reconcile(cd)
+	require.NoError(t, err)
+
+	primary, err := mocks.meshClient.TelemetryV1alpha1().Telemetries("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "podinfo-primary", primary.Spec.Selector.MatchLabels["app"])
+	require.Len(t, primary.Spec.Metrics, 1)
+	assert.Equal(t, "prometheus", primary.Spec.Metrics[0].Providers[0].Name)
+	assert.Equal(t, "'podinfo-primary'", primary.Spec.Metrics[0].Overrides[0].TagOverrides["flagger_role"].Value)
+
+	canary, err := mocks.meshClient.TelemetryV1alpha1().Telemetries("default").Get(context.TODO(), "podinfo-canary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "podinfo", canary.Spec.Selector.MatchLabels["app"])
+	assert.Equal(t, "'podinfo-canary'", canary.Spec.Metrics[0].Overrides[0].TagOverrides["flagger_role"].Value)
+
+	// test update
+	cd.Spec.Service.Telemetry = &v1beta1.IstioTelemetry{
+		Tag:       "deployment_role",
+		Providers: []string{"otel"},
+	}
+	err = router.Reconcile(cd)
+	require.NoError(t, err)
+
+	canary, err = mocks.meshClient.TelemetryV1alpha1().Telemetries("default").Get(context.TODO(), "podinfo-canary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "otel", canary.Spec.Metrics[0].Providers[0].Name)
+	assert.Equal(t, "'podinfo-canary'", canary.Spec.Metrics[0].Overrides[0].TagOverrides["deployment_role"].Value)
+	assert.NotContains(t, canary.Spec.Metrics[0].Overrides[0].TagOverrides, "flagger_role")
+}
+
 func TestIstioRouter_SetRoutes(t *testing.T) {
 	mocks := newFixture(nil)
 	router := &IstioRouter{
@@ -350,6 +404,104 @@ func TestIstioRouter_GatewayPort(t *testing.T) {
 	assert.Equal(t, uint32(mocks.canary.Spec.Service.Port), port)
 }
 
+func TestIstioRouter_AdditionalPorts(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	mocks.canary.Spec.Service.AdditionalPorts = []v1beta1.CanaryServicePort{
+		{Name: "grpc", Port: 9999, AppProtocol: "grpc"},
+	}
+
+	err := router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	err = router.SetRoutes(mocks.canary, 70, 30, false)
+	require.NoError(t, err)
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// one weighted route for each port
+	require.Len(t, vs.Spec.Http, 2)
+	for i, port := range []uint32{9898, 9999} {
+		route := vs.Spec.Http[i]
+		require.Len(t, route.Match, 1)
+		assert.Equal(t, port, route.Match[0].Port)
+		require.Len(t, route.Route, 2)
+		assert.Equal(t, 70, route.Route[0].Weight)
+		assert.Equal(t, 30, route.Route[1].Weight)
+		for _, dest := range route.Route {
+			if assert.NotNil(t, dest.Destination.Port) {
+				assert.Equal(t, port, dest.Destination.Port.Number)
+			}
+		}
+	}
+
+	p, c, _, err := router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 70, p)
+	assert.Equal(t, 30, c)
+}
+
+func TestIstioRouter_SessionAffinity(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	mocks.canary.Spec.Analysis.SessionAffinity = &v1beta1.SessionAffinity{CookieName: "flagger-cookie", MaxAge: 3600}
+	mocks.canary.Status.LastAppliedSpec = "abc"
+
+	err := router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	err = router.SetRoutes(mocks.canary, 70, 30, false)
+	require.NoError(t, err)
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, vs.Spec.Http, 2)
+
+	// the users with the cookie are routed to the canary
+	sticky := vs.Spec.Http[0]
+	require.Len(t, sticky.Match, 1)
+	assert.Equal(t, ".*flagger-cookie=abc.*", sticky.Match[0].Headers["Cookie"].Regex)
+	assert.Equal(t, 0, sticky.Route[0].Weight)
+	assert.Equal(t, 100, sticky.Route[1].Weight)
+
+	// the cookie is set on the canary responses
+	weighted := vs.Spec.Http[1]
+	assert.Nil(t, weighted.Route[0].Headers)
+	require.NotNil(t, weighted.Route[1].Headers)
+	assert.Equal(t, "flagger-cookie=abc; Max-Age=3600; Path=/", weighted.Route[1].Headers.Response.Add["Set-Cookie"])
+
+	// the weights are kept on reconciliation
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	p, c, _, err := router.GetRoutes(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, 70, p)
+	assert.Equal(t, 30, c)
+
+	// the users are no longer pinned once the canary is promoted
+	err = router.SetRoutes(mocks.canary, 100, 0, false)
+	require.NoError(t, err)
+
+	vs, err = mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, vs.Spec.Http[0].Route[0].Weight)
+	assert.Equal(t, 0, vs.Spec.Http[0].Route[1].Weight)
+}
+
 func TestIstioRouter_Delegate(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		mocks := newFixture(nil)
@@ -446,7 +598,7 @@ func TestIstioRouter_Finalize(t *testing.T) {
 		annotation    string
 	}{
 		// VS not found
-		{router: router, spec: nil, shouldError: true, createVS: false, canary: mocks.canary, callReconcile: false, annotation: ""},
+		{router: router, spec: nil, shouldError: false, createVS: false, canary: mocks.canary, callReconcile: false, annotation: ""},
 		// No annotation found but still finalizes
 		{router: router, spec: nil, shouldError: false, createVS: false, canary: mocks.canary, callReconcile: true, annotation: ""},
 		// Spec should match annotation after finalize
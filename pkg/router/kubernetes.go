@@ -26,6 +26,8 @@ type KubernetesRouter interface {
 	Initialize(canary *flaggerv1.Canary) error
 	// Reconcile creates or updates the main service
 	Reconcile(canary *flaggerv1.Canary) error
+	// GetServiceDrift returns the names of the services changed out-of-band
+	GetServiceDrift(canary *flaggerv1.Canary) ([]string, error)
 	// Revert router
 	Finalize(canary *flaggerv1.Canary) error
 }
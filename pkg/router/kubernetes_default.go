@@ -56,11 +56,22 @@ func (c *KubernetesDefaultRouter) Initialize(canary *flaggerv1.Canary) error {
 	}
 
 	// primary svc
-	err = c.reconcileService(canary, primaryName, fmt.Sprintf("%s-primary", c.labelValue), canary.Spec.Service.Primary)
+	primaryLabelValue, err := canary.GetPrimaryLabelValue(c.labelValue)
+	if err != nil {
+		return err
+	}
+	err = c.reconcileService(canary, primaryName, primaryLabelValue, canary.Spec.Service.Primary)
 	if err != nil {
 		return fmt.Errorf("reconcileService failed: %w", err)
 	}
 
+	// canary copies of the existing services
+	for _, name := range canary.Spec.Service.ExistingServices {
+		if err := c.reconcileExistingServiceCanary(canary, name); err != nil {
+			return fmt.Errorf("reconcileExistingServiceCanary failed: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -69,11 +80,22 @@ func (c *KubernetesDefaultRouter) Reconcile(canary *flaggerv1.Canary) error {
 	apexName, _, _ := canary.GetServiceNames()
 
 	// main svc
-	err := c.reconcileService(canary, apexName, fmt.Sprintf("%s-primary", c.labelValue), canary.Spec.Service.Apex)
+	primaryLabelValue, err := canary.GetPrimaryLabelValue(c.labelValue)
+	if err != nil {
+		return err
+	}
+	err = c.reconcileService(canary, apexName, primaryLabelValue, canary.Spec.Service.Apex)
 	if err != nil {
 		return fmt.Errorf("reconcileService failed: %w", err)
 	}
 
+	// route the existing services to the primary pods
+	for _, name := range canary.Spec.Service.ExistingServices {
+		if err := c.routeExistingService(canary, name, primaryLabelValue); err != nil {
+			return fmt.Errorf("routeExistingService failed: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -85,7 +107,108 @@ func (c *KubernetesDefaultRouter) GetRoutes(_ *flaggerv1.Canary) (primaryRoute i
 	return 0, 0, nil
 }
 
-func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, name string, podSelector string, metadata *flaggerv1.CustomMetadata) error {
+// propagateTargetMetadata merges the target labels and annotations selected by the canary
+// metadata propagation policy with the service custom metadata, the custom metadata takes precedence
+func (c *KubernetesDefaultRouter) propagateTargetMetadata(canary *flaggerv1.Canary, metadata *flaggerv1.CustomMetadata) (*flaggerv1.CustomMetadata, error) {
+	labelSelector := canary.GetLabelPropagation()
+	annotationSelector := canary.GetAnnotationPropagation()
+	if labelSelector == nil && annotationSelector == nil {
+		return metadata, nil
+	}
+
+	var target metav1.ObjectMeta
+	targetName := canary.Spec.TargetRef.Name
+	switch canary.Spec.TargetRef.Kind {
+	case "Deployment":
+		dep, err := c.kubeClient.AppsV1().Deployments(canary.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("deployment %s.%s get query error: %w", targetName, canary.Namespace, err)
+		}
+		target = dep.ObjectMeta
+	case "DaemonSet":
+		dae, err := c.kubeClient.AppsV1().DaemonSets(canary.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("daemonset %s.%s get query error: %w", targetName, canary.Namespace, err)
+		}
+		target = dae.ObjectMeta
+	case "StatefulSet":
+		sts, err := c.kubeClient.AppsV1().StatefulSets(canary.Namespace).Get(context.TODO(), targetName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("statefulset %s.%s get query error: %w", targetName, canary.Namespace, err)
+		}
+		target = sts.ObjectMeta
+	default:
+		return metadata, nil
+	}
+
+	res := &flaggerv1.CustomMetadata{
+		Labels:      make(map[string]string),
+		Annotations: make(map[string]string),
+	}
+	if labelSelector != nil {
+		for k, v := range filterMetadata(labelSelector.Select(target.Labels)) {
+			res.Labels[k] = v
+		}
+	}
+	if annotationSelector != nil {
+		for k, v := range filterMetadata(annotationSelector.Select(target.Annotations)) {
+			res.Annotations[k] = v
+		}
+	}
+	if metadata != nil {
+		for k, v := range metadata.Labels {
+			res.Labels[k] = v
+		}
+		for k, v := range metadata.Annotations {
+			res.Annotations[k] = v
+		}
+	}
+	return res, nil
+}
+
+// GetServiceDrift returns the names of the primary and apex services owned by the canary whose
+// pod selector or ports differ from the ones applied by Flagger, the services are restored by
+// Initialize and Reconcile regardless of the canary conflict policy
+func (c *KubernetesDefaultRouter) GetServiceDrift(canary *flaggerv1.Canary) ([]string, error) {
+	apexName, primaryName, _ := canary.GetServiceNames()
+	primaryLabelValue, err := canary.GetPrimaryLabelValue(c.labelValue)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifted []string
+	for _, name := range []string{primaryName, apexName} {
+		svc, err := c.kubeClient.CoreV1().Services(canary.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("service %s.%s get query error: %w", name, canary.Namespace, err)
+		}
+		if _, owned := c.isOwnedByCanary(svc, canary.Name); !owned {
+			continue
+		}
+
+		svcSpec, err := c.serviceSpec(canary, name, primaryLabelValue)
+		if err != nil {
+			return nil, err
+		}
+		if hasServiceDrift(svcSpec, svc) {
+			drifted = append(drifted, name)
+		}
+	}
+	return drifted, nil
+}
+
+// serviceSpec returns the spec of a generated service selecting the pods with the given label value
+func (c *KubernetesDefaultRouter) serviceSpec(canary *flaggerv1.Canary, name string, podSelector string) (corev1.ServiceSpec, error) {
+	if err := canary.Spec.Service.ValidateIPFamilies(); err != nil {
+		return corev1.ServiceSpec{}, fmt.Errorf("service %s.%s invalid: %w", name, canary.Namespace, err)
+	}
+	if err := canary.Spec.Service.ValidatePorts(); err != nil {
+		return corev1.ServiceSpec{}, fmt.Errorf("service %s.%s invalid: %w", name, canary.Namespace, err)
+	}
+
 	portName := canary.Spec.Service.PortName
 	if portName == "" {
 		portName = "http"
@@ -114,8 +237,35 @@ func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, nam
 		},
 	}
 
-	// set additional ports
+	// set IP families for dual-stack clusters
+	svcSpec.IPFamilies = canary.Spec.Service.IPFamilies
+	svcSpec.IPFamilyPolicy = canary.Spec.Service.IPFamilyPolicy
+
+	// set the ports declared in the canary spec
+	for _, p := range canary.Spec.Service.AdditionalPorts {
+		cp := corev1.ServicePort{
+			Name:       p.Name,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       p.Port,
+			TargetPort: p.TargetPort,
+		}
+		if p.TargetPort.String() == "0" {
+			cp.TargetPort = intstr.FromInt(int(p.Port))
+		}
+		if p.AppProtocol != "" {
+			appProtocol := p.AppProtocol
+			cp.AppProtocol = &appProtocol
+		}
+
+		svcSpec.Ports = append(svcSpec.Ports, cp)
+	}
+
+	// set additional ports, the discovered ports declared in the canary spec are skipped
+	declared := svcSpec.Ports[1:]
 	for n, p := range c.ports {
+		if hasServicePort(declared, n, p) {
+			continue
+		}
 		cp := corev1.ServicePort{
 			Name:     n,
 			Protocol: corev1.ProtocolTCP,
@@ -129,6 +279,42 @@ func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, nam
 		svcSpec.Ports = append(svcSpec.Ports, cp)
 	}
 
+	return svcSpec, nil
+}
+
+// hasServiceDrift returns true if the pod selector or ports of the service differ from the spec,
+// the node ports allocated to the service are copied to the spec ports
+func hasServiceDrift(svcSpec corev1.ServiceSpec, svc *corev1.Service) bool {
+	sortPorts := func(a, b interface{}) bool {
+		return a.(corev1.ServicePort).Port < b.(corev1.ServicePort).Port
+	}
+
+	// copy node ports from existing service
+	for _, port := range svc.Spec.Ports {
+		for i, servicePort := range svcSpec.Ports {
+			if port.Name == servicePort.Name && port.NodePort > 0 {
+				svcSpec.Ports[i].NodePort = port.NodePort
+				break
+			}
+		}
+	}
+
+	portsDiff := cmp.Diff(svcSpec.Ports, svc.Spec.Ports, cmpopts.SortSlices(sortPorts))
+	selectorsDiff := cmp.Diff(svcSpec.Selector, svc.Spec.Selector)
+	return portsDiff != "" || selectorsDiff != ""
+}
+
+func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, name string, podSelector string, metadata *flaggerv1.CustomMetadata) error {
+	svcSpec, err := c.serviceSpec(canary, name, podSelector)
+	if err != nil {
+		return err
+	}
+
+	metadata, err = c.propagateTargetMetadata(canary, metadata)
+	if err != nil {
+		return fmt.Errorf("service %s.%s metadata propagation failed: %w", name, canary.Namespace, err)
+	}
+
 	if metadata == nil {
 		metadata = &flaggerv1.CustomMetadata{}
 	}
@@ -136,6 +322,9 @@ func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, nam
 	if metadata.Labels == nil {
 		metadata.Labels = make(map[string]string)
 	}
+	for k, v := range canary.AuditLabels() {
+		metadata.Labels[k] = v
+	}
 	metadata.Labels[c.labelSelector] = name
 
 	if metadata.Annotations == nil {
@@ -162,7 +351,7 @@ func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, nam
 			Spec: svcSpec,
 		}
 
-		_, err := c.kubeClient.CoreV1().Services(canary.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+		_, err := c.kubeClient.CoreV1().Services(canary.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("service %s.%s create error: %w", svc.Name, canary.Namespace, err)
 		}
@@ -176,31 +365,25 @@ func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, nam
 
 	// update existing service pod selector and ports
 	if svc != nil {
-		sortPorts := func(a, b interface{}) bool {
-			return a.(corev1.ServicePort).Port < b.(corev1.ServicePort).Port
-		}
-
-		// copy node ports from existing service
-		for _, port := range svc.Spec.Ports {
-			for i, servicePort := range svcSpec.Ports {
-				if port.Name == servicePort.Name && port.NodePort > 0 {
-					svcSpec.Ports[i].NodePort = port.NodePort
-					break
-				}
-			}
-		}
-
 		updateService := false
 		svcClone := svc.DeepCopy()
 
-		portsDiff := cmp.Diff(svcSpec.Ports, svc.Spec.Ports, cmpopts.SortSlices(sortPorts))
-		selectorsDiff := cmp.Diff(svcSpec.Selector, svc.Spec.Selector)
-		if portsDiff != "" || selectorsDiff != "" {
+		if hasServiceDrift(svcSpec, svc) {
 			svcClone.Spec.Ports = svcSpec.Ports
 			svcClone.Spec.Selector = svcSpec.Selector
 			updateService = true
 		}
 
+		// the API server defaults the IP families, update them only if set in the canary spec
+		if len(svcSpec.IPFamilies) > 0 && cmp.Diff(svcSpec.IPFamilies, svc.Spec.IPFamilies) != "" {
+			svcClone.Spec.IPFamilies = svcSpec.IPFamilies
+			updateService = true
+		}
+		if svcSpec.IPFamilyPolicy != nil && cmp.Diff(svcSpec.IPFamilyPolicy, svc.Spec.IPFamilyPolicy) != "" {
+			svcClone.Spec.IPFamilyPolicy = svcSpec.IPFamilyPolicy
+			updateService = true
+		}
+
 		// update annotations and labels only if the service has been created by Flagger
 		if _, owned := c.isOwnedByCanary(svc, canary.Name); owned {
 			if svc.ObjectMeta.Annotations == nil {
@@ -221,7 +404,7 @@ func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, nam
 				svcClone.ObjectMeta.Annotations = make(map[string]string)
 			}
 			svcClone.ObjectMeta.Annotations = filterMetadata(svcClone.ObjectMeta.Annotations)
-			_, err = c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), svcClone, metav1.UpdateOptions{})
+			_, err = c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), svcClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("service %s update error: %w", name, err)
 			}
@@ -237,11 +420,27 @@ func (c *KubernetesDefaultRouter) reconcileService(canary *flaggerv1.Canary, nam
 func (c *KubernetesDefaultRouter) Finalize(canary *flaggerv1.Canary) error {
 	apexName, _, _ := canary.GetServiceNames()
 
+	// route the existing services back to the target pods
+	for _, name := range canary.Spec.Service.ExistingServices {
+		err := c.routeExistingService(canary, name, c.labelValue)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("routeExistingService failed: %w", err)
+		}
+	}
+
 	svc, err := c.kubeClient.CoreV1().Services(canary.Namespace).Get(context.TODO(), apexName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("service %s.%s get query error: %w", apexName, canary.Namespace, err)
 	}
 
+	// keep the generated apex service routing to the target after the canary is deleted
+	if _, isOwned := c.isOwnedByCanary(svc, canary.Name); isOwned && canary.Spec.RevertPolicy == flaggerv1.FullRevertPolicy {
+		return c.orphanService(canary, svc)
+	}
+
 	// No need to do any reconciliation if the router is owned by the controller
 	if hasCanaryOwnerRef, isOwned := c.isOwnedByCanary(svc, canary.Name); !hasCanaryOwnerRef && !isOwned {
 		// If kubectl annotation is present that will be utilized, else reconcile
@@ -254,7 +453,7 @@ func (c *KubernetesDefaultRouter) Finalize(canary *flaggerv1.Canary) error {
 			clone := svc.DeepCopy()
 			clone.Spec.Selector = storedSvc.Spec.Selector
 
-			if _, err := c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{}); err != nil {
+			if _, err := c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()}); err != nil {
 				return fmt.Errorf("service %s update error: %w", clone.Name, err)
 			}
 		} else {
@@ -267,6 +466,142 @@ func (c *KubernetesDefaultRouter) Finalize(canary *flaggerv1.Canary) error {
 	return nil
 }
 
+// reconcileExistingServiceCanary creates or updates the canary copy of an existing service,
+// the copy has the ports of the existing service and selects the canary pods
+func (c *KubernetesDefaultRouter) reconcileExistingServiceCanary(canary *flaggerv1.Canary, name string) error {
+	existing, err := c.kubeClient.CoreV1().Services(canary.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("service %s.%s get query error: %w", name, canary.Namespace, err)
+	}
+
+	canaryName := canary.GetExistingServiceCanaryName(name)
+	ports := make([]corev1.ServicePort, 0, len(existing.Spec.Ports))
+	for _, p := range existing.Spec.Ports {
+		p.NodePort = 0
+		ports = append(ports, p)
+	}
+	svcSpec := corev1.ServiceSpec{
+		Type:           corev1.ServiceTypeClusterIP,
+		Selector:       existingServiceSelector(existing, c.labelSelector, c.labelValue),
+		Ports:          ports,
+		IPFamilies:     existing.Spec.IPFamilies,
+		IPFamilyPolicy: existing.Spec.IPFamilyPolicy,
+	}
+
+	svc, err := c.kubeClient.CoreV1().Services(canary.Namespace).Get(context.TODO(), canaryName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		labels := canary.AuditLabels()
+		labels[c.labelSelector] = canaryName
+		svc = &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      canaryName,
+				Namespace: canary.Namespace,
+				Labels:    labels,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(canary, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: svcSpec,
+		}
+
+		_, err := c.kubeClient.CoreV1().Services(canary.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{FieldManager: canary.FieldManager()})
+		if err != nil {
+			return fmt.Errorf("service %s.%s create error: %w", canaryName, canary.Namespace, err)
+		}
+
+		c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("Service %s.%s created", canaryName, canary.Namespace)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("service %s get query error: %w", canaryName, err)
+	}
+
+	if _, owned := c.isOwnedByCanary(svc, canary.Name); !owned {
+		return fmt.Errorf("service %s.%s is not owned by the canary", canaryName, canary.Namespace)
+	}
+
+	sortPorts := func(a, b interface{}) bool {
+		return a.(corev1.ServicePort).Port < b.(corev1.ServicePort).Port
+	}
+	portsDiff := cmp.Diff(svcSpec.Ports, svc.Spec.Ports, cmpopts.SortSlices(sortPorts))
+	selectorsDiff := cmp.Diff(svcSpec.Selector, svc.Spec.Selector)
+	if portsDiff == "" && selectorsDiff == "" {
+		return nil
+	}
+
+	svcClone := svc.DeepCopy()
+	svcClone.Spec.Ports = svcSpec.Ports
+	svcClone.Spec.Selector = svcSpec.Selector
+	_, err = c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), svcClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
+	if err != nil {
+		return fmt.Errorf("service %s update error: %w", canaryName, err)
+	}
+
+	c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+		Infof("Service %s updated", svc.GetName())
+	return nil
+}
+
+// routeExistingService points the selector of an existing service to the pods with the given label value,
+// the other keys of the selector are kept
+func (c *KubernetesDefaultRouter) routeExistingService(canary *flaggerv1.Canary, name string, labelValue string) error {
+	svc, err := c.kubeClient.CoreV1().Services(canary.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("service %s.%s get query error: %w", name, canary.Namespace, err)
+	}
+
+	if svc.Spec.Selector[c.labelSelector] == labelValue {
+		return nil
+	}
+
+	svcClone := svc.DeepCopy()
+	svcClone.Spec.Selector = existingServiceSelector(svc, c.labelSelector, labelValue)
+	_, err = c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), svcClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
+	if err != nil {
+		return fmt.Errorf("service %s update error: %w", name, err)
+	}
+
+	c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+		Infof("Service %s.%s routed to %s=%s", name, canary.Namespace, c.labelSelector, labelValue)
+	return nil
+}
+
+// existingServiceSelector returns the selector of the service with the label set to the given value
+func existingServiceSelector(svc *corev1.Service, label string, labelValue string) map[string]string {
+	selector := make(map[string]string, len(svc.Spec.Selector)+1)
+	for k, v := range svc.Spec.Selector {
+		selector[k] = v
+	}
+	selector[label] = labelValue
+	return selector
+}
+
+// orphanService points the service selector to the target pods
+// and removes the canary owner reference to prevent the garbage collection of the service
+func (c *KubernetesDefaultRouter) orphanService(canary *flaggerv1.Canary, svc *corev1.Service) error {
+	clone := svc.DeepCopy()
+	clone.Spec.Selector = map[string]string{c.labelSelector: c.labelValue}
+	delete(clone.Labels, flaggerv1.CanaryLabel)
+	delete(clone.Labels, flaggerv1.CanaryRunIDLabel)
+	clone.OwnerReferences = nil
+	for _, ref := range svc.OwnerReferences {
+		if ref.Kind != flaggerv1.CanaryKind || ref.Name != canary.Name {
+			clone.OwnerReferences = append(clone.OwnerReferences, ref)
+		}
+	}
+
+	if _, err := c.kubeClient.CoreV1().Services(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()}); err != nil {
+		return fmt.Errorf("service %s update error: %w", clone.Name, err)
+	}
+	c.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+		Infof("Service %s.%s released from the canary", clone.Name, canary.Namespace)
+	return nil
+}
+
 // isOwnedByCanary evaluates if an object contains an OwnerReference declaration, that is of kind Canary and
 // has the same ref name as the Canary under evaluation.  It returns two bool the first returns true if
 // an OwnerReference is present and the second returns true if it is owned by the supplied name.
@@ -287,3 +622,13 @@ func (c KubernetesDefaultRouter) isOwnedByCanary(obj interface{}, name string) (
 
 	return true, ownerRef.Name == name
 }
+
+// hasServicePort returns true if a port with the given name or number is in the list
+func hasServicePort(ports []corev1.ServicePort, name string, port int32) bool {
+	for _, p := range ports {
+		if p.Name == name || p.Port == port {
+			return true
+		}
+	}
+	return false
+}
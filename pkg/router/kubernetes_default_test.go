@@ -23,6 +23,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/stretchr/testify/assert"
@@ -128,6 +129,53 @@ func TestServiceRouter_Undo(t *testing.T) {
 	assert.Equal(t, int32(9898), canarySvc.Spec.Ports[0].Port)
 }
 
+func TestServiceRouter_GetServiceDrift(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &KubernetesDefaultRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+		labelSelector: "app",
+		labelValue:    "podinfo",
+	}
+
+	err := router.Initialize(mocks.canary)
+	require.NoError(t, err)
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	drifted, err := router.GetServiceDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.Empty(t, drifted)
+
+	// out-of-band change of the primary selector and the apex ports
+	primarySvc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	primarySvc.Spec.Selector["app"] = "podinfo"
+	_, err = mocks.kubeClient.CoreV1().Services("default").Update(context.TODO(), primarySvc, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	apexSvc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	apexSvc.Spec.Ports[0].Port = 8080
+	_, err = mocks.kubeClient.CoreV1().Services("default").Update(context.TODO(), apexSvc, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	drifted, err = router.GetServiceDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"podinfo-primary", "podinfo"}, drifted)
+
+	// the services are restored on reconciliation
+	err = router.Initialize(mocks.canary)
+	require.NoError(t, err)
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	drifted, err = router.GetServiceDrift(mocks.canary)
+	require.NoError(t, err)
+	assert.Empty(t, drifted)
+}
+
 func TestServiceRouter_isOwnedByCanary(t *testing.T) {
 	mocks := newFixture(nil)
 	router := &KubernetesDefaultRouter{
@@ -325,7 +373,7 @@ func TestServiceRouter_Finalize(t *testing.T) {
 		// Won't reconcile since it is owned and would be garbage collected
 		{router: router, callSetupMethods: true, shouldError: false, canary: mocks.canary, shouldMutate: false},
 		// Service not found
-		{router: &KubernetesDefaultRouter{kubeClient: fake.NewSimpleClientset(), logger: mocks.logger}, callSetupMethods: false, shouldError: true, canary: mocks.canary, shouldMutate: false},
+		{router: &KubernetesDefaultRouter{kubeClient: fake.NewSimpleClientset(), logger: mocks.logger}, callSetupMethods: false, shouldError: false, canary: mocks.canary, shouldMutate: false},
 		// Not owned
 		{router: &KubernetesDefaultRouter{kubeClient: fake.NewSimpleClientset(svc), logger: mocks.logger}, callSetupMethods: false, shouldError: false, canary: mocks.canary, shouldMutate: true},
 		// Kubectl annotation
@@ -364,6 +412,32 @@ func TestServiceRouter_Finalize(t *testing.T) {
 	}
 }
 
+func TestServiceRouter_FinalizeFull(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &KubernetesDefaultRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+		labelSelector: "app",
+		labelValue:    "podinfo",
+	}
+
+	err := router.Initialize(mocks.canary)
+	require.NoError(t, err)
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.RevertPolicy = flaggerv1.FullRevertPolicy
+	err = router.Finalize(cd)
+	require.NoError(t, err)
+
+	svc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "podinfo", svc.Spec.Selector["app"])
+	assert.Nil(t, metav1.GetControllerOf(svc))
+}
+
 func TestServiceRouter_InitializeMetadata(t *testing.T) {
 	mocks := newFixture(nil)
 	router := &KubernetesDefaultRouter{
@@ -445,3 +519,218 @@ func TestServiceRouter_ReconcileMetadata(t *testing.T) {
 	assert.Equal(t, "test1", apexSvc.Labels["test"])
 	assert.Equal(t, "podinfo", apexSvc.Labels["app"])
 }
+
+func TestServiceRouter_MetadataPropagation(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &KubernetesDefaultRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+		labelSelector: "app",
+	}
+
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	dep.Labels = map[string]string{"team": "a", "app": "podinfo", "other": "test"}
+	dep.Annotations = map[string]string{"policies.kyverno.io/skip": "true", "test": "target"}
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(context.TODO(), dep, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	mocks.canary.Spec.MetadataPropagation = &flaggerv1.MetadataPropagation{
+		Labels: &flaggerv1.MetadataSelector{
+			IncludePrefixes: []string{"*"},
+			ExcludePrefixes: []string{"other"},
+		},
+		Annotations: &flaggerv1.MetadataSelector{
+			IncludePrefixes: []string{"policies.kyverno.io"},
+			Keys:            []string{"test"},
+		},
+	}
+	mocks.canary.Spec.Service.Canary = &flaggerv1.CustomMetadata{
+		Annotations: map[string]string{"test": "custom"},
+	}
+
+	err = router.Initialize(mocks.canary)
+	require.NoError(t, err)
+
+	canarySvc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo-canary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "a", canarySvc.Labels["team"])
+	assert.Equal(t, "podinfo-canary", canarySvc.Labels["app"])
+	assert.NotContains(t, canarySvc.Labels, "other")
+	assert.Equal(t, "true", canarySvc.Annotations["policies.kyverno.io/skip"])
+	assert.Equal(t, "custom", canarySvc.Annotations["test"])
+
+	primarySvc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo-primary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "a", primarySvc.Labels["team"])
+	assert.Equal(t, "podinfo-primary", primarySvc.Labels["app"])
+	assert.Equal(t, "target", primarySvc.Annotations["test"])
+}
+
+func TestServiceRouter_ExistingServices(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &KubernetesDefaultRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+		labelSelector: "app",
+		labelValue:    "podinfo",
+	}
+
+	existing := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo-grpc", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeNodePort,
+			Selector: map[string]string{"app": "podinfo", "tier": "backend"},
+			Ports: []corev1.ServicePort{
+				{Name: "grpc", Port: 9999, TargetPort: intstr.FromString("grpc"), NodePort: 30999},
+			},
+		},
+	}
+	_, err := mocks.kubeClient.CoreV1().Services("default").Create(context.TODO(), existing, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	mocks.canary.Spec.Service.ExistingServices = []string{"podinfo-grpc"}
+
+	err = router.Initialize(mocks.canary)
+	require.NoError(t, err)
+
+	canarySvc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo-grpc-canary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, corev1.ServiceTypeClusterIP, canarySvc.Spec.Type)
+	assert.Equal(t, map[string]string{"app": "podinfo", "tier": "backend"}, canarySvc.Spec.Selector)
+	require.Len(t, canarySvc.Spec.Ports, 1)
+	assert.Equal(t, "grpc", canarySvc.Spec.Ports[0].Name)
+	assert.Equal(t, "grpc", canarySvc.Spec.Ports[0].TargetPort.String())
+	assert.Equal(t, int32(0), canarySvc.Spec.Ports[0].NodePort)
+
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	existingSvc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo-grpc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "podinfo-primary", "tier": "backend"}, existingSvc.Spec.Selector)
+	assert.Equal(t, int32(30999), existingSvc.Spec.Ports[0].NodePort)
+
+	err = router.Finalize(mocks.canary)
+	require.NoError(t, err)
+
+	existingSvc, err = mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo-grpc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "podinfo", "tier": "backend"}, existingSvc.Spec.Selector)
+}
+
+func TestServiceRouter_IPFamilies(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &KubernetesDefaultRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+		labelSelector: "app",
+	}
+
+	// single-stack IPv6
+	policy := corev1.IPFamilyPolicySingleStack
+	mocks.canary.Spec.Service.IPFamilies = []corev1.IPFamily{corev1.IPv6Protocol}
+	mocks.canary.Spec.Service.IPFamilyPolicy = &policy
+
+	err := router.Initialize(mocks.canary)
+	require.NoError(t, err)
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	for _, name := range []string{"podinfo", "podinfo-canary", "podinfo-primary"} {
+		svc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []corev1.IPFamily{corev1.IPv6Protocol}, svc.Spec.IPFamilies)
+		assert.Equal(t, corev1.IPFamilyPolicySingleStack, *svc.Spec.IPFamilyPolicy)
+	}
+
+	// upgrade to dual-stack
+	policy = corev1.IPFamilyPolicyPreferDualStack
+	mocks.canary.Spec.Service.IPFamilies = []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol}
+	mocks.canary.Spec.Service.IPFamilyPolicy = &policy
+
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	apexSvc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol}, apexSvc.Spec.IPFamilies)
+	assert.Equal(t, corev1.IPFamilyPolicyPreferDualStack, *apexSvc.Spec.IPFamilyPolicy)
+}
+
+func TestServiceRouter_ValidateIPFamilies(t *testing.T) {
+	singleStack := corev1.IPFamilyPolicySingleStack
+	dualStack := corev1.IPFamilyPolicyRequireDualStack
+	invalid := corev1.IPFamilyPolicyType("DualStack")
+
+	tests := []struct {
+		name     string
+		families []corev1.IPFamily
+		policy   *corev1.IPFamilyPolicyType
+		wantErr  bool
+	}{
+		{name: "default"},
+		{name: "IPv6", families: []corev1.IPFamily{corev1.IPv6Protocol}},
+		{name: "dual-stack", families: []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}, policy: &dualStack},
+		{name: "dual-stack without policy", families: []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}, wantErr: true},
+		{name: "dual-stack with single-stack policy", families: []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}, policy: &singleStack, wantErr: true},
+		{name: "duplicated family", families: []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv4Protocol}, policy: &dualStack, wantErr: true},
+		{name: "unknown family", families: []corev1.IPFamily{"IPv5"}, wantErr: true},
+		{name: "unknown policy", policy: &invalid, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := flaggerv1.CanaryService{IPFamilies: tt.families, IPFamilyPolicy: tt.policy}
+			err := svc.ValidateIPFamilies()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestServiceRouter_AdditionalPorts(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &KubernetesDefaultRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+		labelSelector: "app",
+		ports:         map[string]int32{"grpc": 9999, "metrics": 9797},
+	}
+
+	mocks.canary.Spec.Service.AdditionalPorts = []flaggerv1.CanaryServicePort{
+		{Name: "grpc", Port: 9999, TargetPort: intstr.FromString("grpc"), AppProtocol: "grpc"},
+	}
+
+	err := router.Initialize(mocks.canary)
+	require.NoError(t, err)
+	err = router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	for _, name := range []string{"podinfo", "podinfo-canary", "podinfo-primary"} {
+		svc, err := mocks.kubeClient.CoreV1().Services("default").Get(context.TODO(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Len(t, svc.Spec.Ports, 3)
+		assert.Equal(t, int32(9898), svc.Spec.Ports[0].Port)
+		assert.Equal(t, "grpc", svc.Spec.Ports[1].Name)
+		assert.Equal(t, int32(9999), svc.Spec.Ports[1].Port)
+		assert.Equal(t, "grpc", svc.Spec.Ports[1].TargetPort.String())
+		if assert.NotNil(t, svc.Spec.Ports[1].AppProtocol) {
+			assert.Equal(t, "grpc", *svc.Spec.Ports[1].AppProtocol)
+		}
+		assert.Equal(t, "metrics", svc.Spec.Ports[2].Name)
+	}
+
+	// duplicated port numbers are rejected
+	mocks.canary.Spec.Service.AdditionalPorts = append(mocks.canary.Spec.Service.AdditionalPorts,
+		flaggerv1.CanaryServicePort{Name: "grpc-web", Port: 9999})
+	err = router.Reconcile(mocks.canary)
+	require.Error(t, err)
+}
@@ -33,6 +33,10 @@ func (c *KubernetesNoopRouter) Reconcile(_ *flaggerv1.Canary) error {
 	return nil
 }
 
+func (c *KubernetesNoopRouter) GetServiceDrift(_ *flaggerv1.Canary) ([]string, error) {
+	return nil, nil
+}
+
 func (c *KubernetesNoopRouter) Finalize(_ *flaggerv1.Canary) error {
 	return nil
 }
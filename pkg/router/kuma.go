@@ -1,3 +1,6 @@
+//go:build !no_kuma
+// +build !no_kuma
+
 package router
 
 import (
@@ -19,6 +22,17 @@ import (
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.KumaProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &KumaRouter{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			kumaClient:    factory.meshClient,
+		}
+	})
+}
+
 // KumaRouter is managing TrafficRoute objects
 type KumaRouter struct {
 	kubeClient    kubernetes.Interface
@@ -101,7 +115,7 @@ func (kr *KumaRouter) Reconcile(canary *flaggerv1.Canary) error {
 			Mesh: meshName,
 		}
 
-		_, err := kr.kumaClient.KumaV1alpha1().TrafficRoutes().Create(context.TODO(), t, metav1.CreateOptions{})
+		_, err := kr.kumaClient.KumaV1alpha1().TrafficRoutes().Create(context.TODO(), t, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 
 		if err != nil {
 			return fmt.Errorf("TrafficRoute %s create error: %w", apexName, err)
@@ -119,7 +133,7 @@ func (kr *KumaRouter) Reconcile(canary *flaggerv1.Canary) error {
 		trClone := tr.DeepCopy()
 		trClone.Spec = trSpec
 
-		_, err := kr.kumaClient.KumaV1alpha1().TrafficRoutes().Update(context.TODO(), trClone, metav1.UpdateOptions{})
+		_, err := kr.kumaClient.KumaV1alpha1().TrafficRoutes().Update(context.TODO(), trClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 
 		if err != nil {
 			return fmt.Errorf("TrafficRoute %s update error: %w", apexName, err)
@@ -199,7 +213,7 @@ func (kr *KumaRouter) SetRoutes(
 	trClone := tr.DeepCopy()
 	trClone.Spec.Conf = conf
 
-	_, err = kr.kumaClient.KumaV1alpha1().TrafficRoutes().Update(context.TODO(), trClone, metav1.UpdateOptions{})
+	_, err = kr.kumaClient.KumaV1alpha1().TrafficRoutes().Update(context.TODO(), trClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("TrafficRoute %s update error %v", apexName, err)
 	}
@@ -207,6 +221,24 @@ func (kr *KumaRouter) SetRoutes(
 	return nil
 }
 
-func (kr *KumaRouter) Finalize(_ *flaggerv1.Canary) error {
+// Finalize deletes the TrafficRoute generated by Flagger
+func (kr *KumaRouter) Finalize(canary *flaggerv1.Canary) error {
+	apexName, _, _ := canary.GetServiceNames()
+	client := kr.kumaClient.KumaV1alpha1().TrafficRoutes()
+
+	deleted, err := finalizeGeneratedObject(canary, "TrafficRoute", apexName,
+		func() (metav1.Object, error) {
+			return client.Get(context.TODO(), apexName, metav1.GetOptions{})
+		},
+		func() error {
+			return client.Delete(context.TODO(), apexName, metav1.DeleteOptions{})
+		})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		kr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("TrafficRoute %s deleted", apexName)
+	}
 	return nil
 }
@@ -1,3 +1,6 @@
+//go:build !no_kuma
+// +build !no_kuma
+
 /*
 Copyright 2020 The Flux authors
 
@@ -23,6 +26,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -76,3 +80,27 @@ func TestKumaRouter_Routes(t *testing.T) {
 	assert.Equal(t, uint32(50), primary.Weight)
 
 }
+
+func TestKumaRouter_Finalize(t *testing.T) {
+	canary := newTestSMICanary()
+	mocks := newFixture(canary)
+	router := &KumaRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		kumaClient:    mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	err := router.Reconcile(canary)
+	require.NoError(t, err)
+
+	err = router.Finalize(canary)
+	require.NoError(t, err)
+
+	_, err = router.kumaClient.KumaV1alpha1().TrafficRoutes().Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	// finalize is idempotent
+	err = router.Finalize(canary)
+	require.NoError(t, err)
+}
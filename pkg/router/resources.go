@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// meshRouterResources holds the custom resources managed by each router,
+// the routers based on Kubernetes built-in types are not listed
+var meshRouterResources = map[string][]schema.GroupVersionResource{
+	flaggerv1.IstioProvider: {
+		{Group: "networking.istio.io", Version: "v1alpha3", Resource: "virtualservices"},
+		{Group: "networking.istio.io", Version: "v1alpha3", Resource: "destinationrules"},
+	},
+	flaggerv1.AppMeshProvider: {
+		{Group: "appmesh.k8s.aws", Version: "v1beta1", Resource: "virtualnodes"},
+		{Group: "appmesh.k8s.aws", Version: "v1beta1", Resource: "virtualservices"},
+	},
+	flaggerv1.AppMeshProvider + ":v1beta2": {
+		{Group: "appmesh.k8s.aws", Version: "v1beta2", Resource: "virtualnodes"},
+		{Group: "appmesh.k8s.aws", Version: "v1beta2", Resource: "virtualrouters"},
+		{Group: "appmesh.k8s.aws", Version: "v1beta2", Resource: "virtualservices"},
+	},
+	flaggerv1.LinkerdProvider: {
+		{Group: "split.smi-spec.io", Version: "v1alpha1", Resource: "trafficsplits"},
+	},
+	flaggerv1.SMIProvider + ":v1alpha1": {
+		{Group: "split.smi-spec.io", Version: "v1alpha1", Resource: "trafficsplits"},
+	},
+	flaggerv1.SMIProvider + ":v1alpha2": {
+		{Group: "split.smi-spec.io", Version: "v1alpha2", Resource: "trafficsplits"},
+	},
+	flaggerv1.SMIProvider + ":v1alpha3": {
+		{Group: "split.smi-spec.io", Version: "v1alpha3", Resource: "trafficsplits"},
+	},
+	flaggerv1.OsmProvider: {
+		{Group: "split.smi-spec.io", Version: "v1alpha2", Resource: "trafficsplits"},
+	},
+	flaggerv1.ContourProvider: {
+		{Group: "projectcontour.io", Version: "v1", Resource: "httpproxies"},
+	},
+	flaggerv1.GlooProvider: {
+		{Group: "gateway.solo.io", Version: "v1", Resource: "routetables"},
+		{Group: "gloo.solo.io", Version: "v1", Resource: "upstreams"},
+	},
+	flaggerv1.TraefikProvider: {
+		{Group: "traefik.containo.us", Version: "v1alpha1", Resource: "traefikservices"},
+	},
+	flaggerv1.KumaProvider: {
+		{Group: "kuma.io", Version: "v1alpha1", Resource: "trafficroutes"},
+	},
+	flaggerv1.GatewayAPIProvider: {
+		{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "httproutes"},
+	},
+}
+
+// MeshRouterResources returns the custom resources managed by the router of the provider
+func MeshRouterResources(provider string) []schema.GroupVersionResource {
+	return meshRouterResources[meshRouterName(provider)]
+}
+
+// MissingMeshResources returns the custom resources of the provider router
+// that are not served by the service mesh cluster e.g. the CRDs are not installed
+func (factory *Factory) MissingMeshResources(provider string) ([]schema.GroupVersionResource, error) {
+	return missingResources(factory.meshClient.Discovery(), MeshRouterResources(provider))
+}
+
+// missingResources returns the resources not served by the API server
+func missingResources(client discovery.DiscoveryInterface, resources []schema.GroupVersionResource) ([]schema.GroupVersionResource, error) {
+	if len(resources) == 0 {
+		return nil, nil
+	}
+
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("discovering the API groups failed: %w", err)
+	}
+
+	served := make(map[string]bool)
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			served[version.GroupVersion] = true
+		}
+	}
+
+	var missing []schema.GroupVersionResource
+	names := make(map[string]map[string]bool)
+	for _, resource := range resources {
+		gv := resource.GroupVersion().String()
+		if !served[gv] {
+			missing = append(missing, resource)
+			continue
+		}
+
+		if _, ok := names[gv]; !ok {
+			list, err := client.ServerResourcesForGroupVersion(gv)
+			if err != nil {
+				return nil, fmt.Errorf("discovering the %s resources failed: %w", gv, err)
+			}
+			names[gv] = make(map[string]bool)
+			for _, r := range list.APIResources {
+				names[gv][r.Name] = true
+			}
+		}
+
+		if !names[gv][resource.Resource] {
+			missing = append(missing, resource)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	fakeFlagger "github.com/fluxcd/flagger/pkg/client/clientset/versioned/fake"
+)
+
+func TestFactory_MissingMeshResources(t *testing.T) {
+	meshClient := fakeFlagger.NewSimpleClientset()
+	factory := NewFactory(nil, nil, nil, "", "", "", nil, meshClient)
+
+	// the CRDs of the Kubernetes and ingress providers are built-in
+	missing, err := factory.MissingMeshResources(flaggerv1.NGINXProvider)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	missing, err = factory.MissingMeshResources(flaggerv1.ContourProvider)
+	require.NoError(t, err)
+	assert.Equal(t, []schema.GroupVersionResource{
+		{Group: "projectcontour.io", Version: "v1", Resource: "httpproxies"},
+	}, missing)
+
+	meshClient.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "projectcontour.io/v1",
+			APIResources: []metav1.APIResource{{Name: "httpproxies"}},
+		},
+		{
+			GroupVersion: "gateway.solo.io/v1",
+			APIResources: []metav1.APIResource{{Name: "virtualservices"}},
+		},
+	}
+
+	missing, err = factory.MissingMeshResources(flaggerv1.ContourProvider)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	missing, err = factory.MissingMeshResources(flaggerv1.GlooProvider + ":v1")
+	require.NoError(t, err)
+	assert.Equal(t, []schema.GroupVersionResource{
+		{Group: "gateway.solo.io", Version: "v1", Resource: "routetables"},
+		{Group: "gloo.solo.io", Version: "v1", Resource: "upstreams"},
+	}, missing)
+}
@@ -16,7 +16,14 @@ limitations under the License.
 
 package router
 
-import flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
 
 const configAnnotation = "flagger.kubernetes.io/original-configuration"
 const kubectlAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
@@ -25,5 +32,37 @@ type Interface interface {
 	Reconcile(canary *flaggerv1.Canary) error
 	SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int, mirrored bool) error
 	GetRoutes(canary *flaggerv1.Canary) (primaryWeight int, canaryWeight int, mirrored bool, err error)
+	// Finalize restores the routing that existed before the canary was created, objects adopted
+	// by Flagger are reverted to their original configuration and the routing objects generated
+	// by Flagger are removed. Finalize must be idempotent, objects that are already reverted
+	// or not found are considered finalized.
 	Finalize(canary *flaggerv1.Canary) error
 }
+
+// finalizeGeneratedObject deletes a routing object generated by Flagger,
+// objects not found or not controlled by the canary are left untouched
+func finalizeGeneratedObject(canary *flaggerv1.Canary, kind string, name string,
+	get func() (metav1.Object, error), remove func() error) (bool, error) {
+	obj, err := get()
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s %s get query error: %w", kind, name, err)
+	}
+
+	if !isControlledByCanary(obj, canary) {
+		return false, nil
+	}
+
+	if err := remove(); err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("%s %s delete error: %w", kind, name, err)
+	}
+	return true, nil
+}
+
+// isControlledByCanary returns true if the object controller is the given canary
+func isControlledByCanary(obj metav1.Object, canary *flaggerv1.Canary) bool {
+	ownerRef := metav1.GetControllerOf(obj)
+	return ownerRef != nil && ownerRef.Kind == flaggerv1.CanaryKind && ownerRef.Name == canary.Name
+}
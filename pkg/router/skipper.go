@@ -1,3 +1,6 @@
+//go:build !no_skipper
+// +build !no_skipper
+
 /*
 Copyright 2020 The Flux authors
 
@@ -32,6 +35,15 @@ import (
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.SkipperProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &SkipperRouter{
+			logger:     factory.logger,
+			kubeClient: factory.kubeClient,
+		}
+	})
+}
+
 /*
 Skipper Principles:
 * if only one backend has a weight, only one backend will get 100% traffic
@@ -114,7 +126,7 @@ func (skp *SkipperRouter) Reconcile(canary *flaggerv1.Canary) error {
 	if errors.IsNotFound(err) {
 		// Let K8s set this. Otherwise K8s API complains with "resourceVersion should not be set on objects to be created"
 		iClone.ObjectMeta.ResourceVersion = ""
-		_, err := skp.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Create(context.TODO(), iClone, metav1.CreateOptions{})
+		_, err := skp.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Create(context.TODO(), iClone, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("ingress %s.%s create error: %w", iClone.Name, iClone.Namespace, err)
 		}
@@ -131,7 +143,7 @@ func (skp *SkipperRouter) Reconcile(canary *flaggerv1.Canary) error {
 		ingressClone.Spec = iClone.Spec
 		ingressClone.Annotations = filterMetadata(iClone.Annotations)
 
-		_, err := skp.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Update(context.TODO(), ingressClone, metav1.UpdateOptions{})
+		_, err := skp.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Update(context.TODO(), ingressClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("ingress %s.%s update error: %w", canaryIngressName, ingressClone.Namespace, err)
 		}
@@ -198,7 +210,7 @@ func (skp *SkipperRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight, can
 	}
 
 	_, err = skp.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Update(
-		context.TODO(), iClone, metav1.UpdateOptions{})
+		context.TODO(), iClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("ingress %s.%s update error %w", iClone.Name, iClone.Namespace, err)
 	}
@@ -208,7 +220,12 @@ func (skp *SkipperRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight, can
 	return err
 }
 
+// Finalize deletes the canary ingress generated by Flagger
 func (skp *SkipperRouter) Finalize(canary *flaggerv1.Canary) error {
+	if canary.Spec.IngressRef == nil || canary.Spec.IngressRef.Name == "" {
+		return nil
+	}
+
 	gracePeriodSeconds := int64(2)
 	_, canaryIngressName := skp.getIngressNames(canary.Spec.IngressRef.Name)
 	skp.logger.With("deleteCanaryIngress", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
@@ -216,7 +233,7 @@ func (skp *SkipperRouter) Finalize(canary *flaggerv1.Canary) error {
 
 	err := skp.kubeClient.NetworkingV1().Ingresses(canary.Namespace).Delete(
 		context.TODO(), canaryIngressName, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriodSeconds})
-	if err != nil {
+	if err != nil && !errors.IsNotFound(err) {
 		return fmt.Errorf("ingress %s.%s unable to remove canary ingress: %w", canaryIngressName, canary.Namespace, err)
 	}
 	return nil
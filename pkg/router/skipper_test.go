@@ -1,3 +1,6 @@
+//go:build !no_skipper
+// +build !no_skipper
+
 /*
 Copyright 2020 The Flux authors
 
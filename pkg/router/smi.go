@@ -1,3 +1,6 @@
+//go:build !no_smi
+// +build !no_smi
+
 /*
 Copyright 2020 The Flux authors
 
@@ -20,6 +23,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -36,6 +40,27 @@ import (
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.LinkerdProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &SmiRouter{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			smiClient:     factory.meshClient,
+			targetMesh:    flaggerv1.LinkerdProvider,
+		}
+	})
+	registerMeshRouter(flaggerv1.SMIProvider+":v1alpha1", func(factory *Factory, provider string, _ string) Interface {
+		return &SmiRouter{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			smiClient:     factory.meshClient,
+			targetMesh:    strings.TrimPrefix(provider, flaggerv1.SMIProvider+":v1alpha1:"),
+		}
+	})
+}
+
 type SmiRouter struct {
 	kubeClient    kubernetes.Interface
 	flaggerClient clientset.Interface
@@ -88,7 +113,7 @@ func (sr *SmiRouter) Reconcile(canary *flaggerv1.Canary) error {
 			Spec: tsSpec,
 		}
 
-		_, err := sr.smiClient.SplitV1alpha1().TrafficSplits(canary.Namespace).Create(context.TODO(), t, metav1.CreateOptions{})
+		_, err := sr.smiClient.SplitV1alpha1().TrafficSplits(canary.Namespace).Create(context.TODO(), t, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("TrafficSplit %s.%s create error: %w", apexName, canary.Namespace, err)
 		}
@@ -105,7 +130,7 @@ func (sr *SmiRouter) Reconcile(canary *flaggerv1.Canary) error {
 		tsClone := ts.DeepCopy()
 		tsClone.Spec = tsSpec
 
-		_, err := sr.smiClient.SplitV1alpha1().TrafficSplits(canary.Namespace).Update(context.TODO(), tsClone, metav1.UpdateOptions{})
+		_, err := sr.smiClient.SplitV1alpha1().TrafficSplits(canary.Namespace).Update(context.TODO(), tsClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("TrafficSplit %s.%s update error: %w", apexName, canary.Namespace, err)
 		}
@@ -179,7 +204,7 @@ func (sr *SmiRouter) SetRoutes(
 	tsClone := ts.DeepCopy()
 	tsClone.Spec.Backends = backends
 
-	_, err = sr.smiClient.SplitV1alpha1().TrafficSplits(canary.Namespace).Update(context.TODO(), tsClone, metav1.UpdateOptions{})
+	_, err = sr.smiClient.SplitV1alpha1().TrafficSplits(canary.Namespace).Update(context.TODO(), tsClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("TrafficSplit %s.%s update error %v", apexName, canary.Namespace, err)
 	}
@@ -229,7 +254,7 @@ func (sr *SmiRouter) getWithConvert(canary *flaggerv1.Canary, host string) (*smi
 			},
 		}
 
-		_, err := sr.smiClient.SplitV1alpha2().TrafficSplits(canary.Namespace).Update(context.TODO(), t, metav1.UpdateOptions{})
+		_, err := sr.smiClient.SplitV1alpha2().TrafficSplits(canary.Namespace).Update(context.TODO(), t, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return nil, fmt.Errorf("TrafficSplit %s.%s update error: %w", apexName, canary.Namespace, err)
 		}
@@ -242,6 +267,24 @@ func (sr *SmiRouter) getWithConvert(canary *flaggerv1.Canary, host string) (*smi
 	return ts, nil
 }
 
-func (sr *SmiRouter) Finalize(_ *flaggerv1.Canary) error {
+// Finalize deletes the TrafficSplit generated by Flagger
+func (sr *SmiRouter) Finalize(canary *flaggerv1.Canary) error {
+	apexName, _, _ := canary.GetServiceNames()
+	client := sr.smiClient.SplitV1alpha1().TrafficSplits(canary.Namespace)
+
+	deleted, err := finalizeGeneratedObject(canary, "TrafficSplit", fmt.Sprintf("%s.%s", apexName, canary.Namespace),
+		func() (metav1.Object, error) {
+			return client.Get(context.TODO(), apexName, metav1.GetOptions{})
+		},
+		func() error {
+			return client.Delete(context.TODO(), apexName, metav1.DeleteOptions{})
+		})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		sr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("TrafficSplit %s.%s deleted", apexName, canary.Namespace)
+	}
 	return nil
 }
@@ -1,3 +1,6 @@
+//go:build !no_smi
+// +build !no_smi
+
 /*
 Copyright 2020 The Flux authors
 
@@ -23,6 +26,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	smiv1 "github.com/fluxcd/flagger/pkg/apis/smi/v1alpha1"
@@ -137,3 +141,44 @@ func TestSmiRouter_GetRoutes(t *testing.T) {
 	assert.Equal(t, 0, c)
 	assert.False(t, m)
 }
+
+func TestSmiRouter_Finalize(t *testing.T) {
+	canary := newTestSMICanary()
+	mocks := newFixture(canary)
+	router := &SmiRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		smiClient:     mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	err := router.Reconcile(canary)
+	require.NoError(t, err)
+
+	// objects not generated by Flagger are left untouched
+	ts, err := router.smiClient.SplitV1alpha1().TrafficSplits("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+	ts.OwnerReferences = nil
+	_, err = router.smiClient.SplitV1alpha1().TrafficSplits("default").Update(context.TODO(), ts, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	err = router.Finalize(canary)
+	require.NoError(t, err)
+	_, err = router.smiClient.SplitV1alpha1().TrafficSplits("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	err = router.smiClient.SplitV1alpha1().TrafficSplits("default").Delete(context.TODO(), "podinfo", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	err = router.Reconcile(canary)
+	require.NoError(t, err)
+
+	err = router.Finalize(canary)
+	require.NoError(t, err)
+
+	_, err = router.smiClient.SplitV1alpha1().TrafficSplits("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	// finalize is idempotent
+	err = router.Finalize(canary)
+	require.NoError(t, err)
+}
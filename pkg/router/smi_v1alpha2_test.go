@@ -1,3 +1,6 @@
+//go:build !no_smi
+// +build !no_smi
+
 /*
 Copyright 2020 The Flux authors
 
@@ -1,3 +1,6 @@
+//go:build !no_smi
+// +build !no_smi
+
 /*
 Copyright 2020 The Flux authors
 
@@ -20,6 +23,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -34,6 +38,18 @@ import (
 	clientset "github.com/fluxcd/flagger/pkg/client/clientset/versioned"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.SMIProvider+":v1alpha3", func(factory *Factory, provider string, _ string) Interface {
+		return &Smiv1alpha3Router{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    factory.kubeClient,
+			smiClient:     factory.meshClient,
+			targetMesh:    strings.TrimPrefix(provider, flaggerv1.SMIProvider+":v1alpha3:"),
+		}
+	})
+}
+
 type Smiv1alpha3Router struct {
 	kubeClient    kubernetes.Interface
 	flaggerClient clientset.Interface
@@ -86,7 +102,7 @@ func (sr *Smiv1alpha3Router) Reconcile(canary *flaggerv1.Canary) error {
 			Spec: tsSpec,
 		}
 
-		_, err := sr.smiClient.SplitV1alpha3().TrafficSplits(canary.Namespace).Create(context.TODO(), t, metav1.CreateOptions{})
+		_, err := sr.smiClient.SplitV1alpha3().TrafficSplits(canary.Namespace).Create(context.TODO(), t, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("TrafficSplit %s.%s create error: %w", apexName, canary.Namespace, err)
 		}
@@ -103,7 +119,7 @@ func (sr *Smiv1alpha3Router) Reconcile(canary *flaggerv1.Canary) error {
 		tsClone := ts.DeepCopy()
 		tsClone.Spec = tsSpec
 
-		_, err := sr.smiClient.SplitV1alpha3().TrafficSplits(canary.Namespace).Update(context.TODO(), tsClone, metav1.UpdateOptions{})
+		_, err := sr.smiClient.SplitV1alpha3().TrafficSplits(canary.Namespace).Update(context.TODO(), tsClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("TrafficSplit %s.%s update error: %w", apexName, canary.Namespace, err)
 		}
@@ -176,7 +192,7 @@ func (sr *Smiv1alpha3Router) SetRoutes(
 	tsClone := ts.DeepCopy()
 	tsClone.Spec.Backends = backends
 
-	_, err = sr.smiClient.SplitV1alpha3().TrafficSplits(canary.Namespace).Update(context.TODO(), tsClone, metav1.UpdateOptions{})
+	_, err = sr.smiClient.SplitV1alpha3().TrafficSplits(canary.Namespace).Update(context.TODO(), tsClone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("TrafficSplit %s.%s update error %v", apexName, canary.Namespace, err)
 	}
@@ -193,6 +209,24 @@ func (sr *Smiv1alpha3Router) makeAnnotations(gateways []string) map[string]strin
 	return res
 }
 
-func (sr *Smiv1alpha3Router) Finalize(_ *flaggerv1.Canary) error {
+// Finalize deletes the TrafficSplit generated by Flagger
+func (sr *Smiv1alpha3Router) Finalize(canary *flaggerv1.Canary) error {
+	apexName, _, _ := canary.GetServiceNames()
+	client := sr.smiClient.SplitV1alpha3().TrafficSplits(canary.Namespace)
+
+	deleted, err := finalizeGeneratedObject(canary, "TrafficSplit", fmt.Sprintf("%s.%s", apexName, canary.Namespace),
+		func() (metav1.Object, error) {
+			return client.Get(context.TODO(), apexName, metav1.GetOptions{})
+		},
+		func() error {
+			return client.Delete(context.TODO(), apexName, metav1.DeleteOptions{})
+		})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		sr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("TrafficSplit %s.%s deleted", apexName, canary.Namespace)
+	}
 	return nil
 }
@@ -1,3 +1,6 @@
+//go:build !no_traefik
+// +build !no_traefik
+
 /*
 Copyright 2020 The Flux authors
 
@@ -31,6 +34,15 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+func init() {
+	registerMeshRouter(flaggerv1.TraefikProvider, func(factory *Factory, _ string, _ string) Interface {
+		return &TraefikRouter{
+			logger:        factory.logger,
+			traefikClient: factory.meshClient,
+		}
+	})
+}
+
 // TraefikRouter is managing Traefik service
 type TraefikRouter struct {
 	traefikClient clientset.Interface
@@ -51,6 +63,7 @@ func (tr *TraefikRouter) Reconcile(canary *flaggerv1.Canary) error {
 					Weight:    100,
 				},
 			},
+			Sticky: tr.makeSticky(canary),
 		},
 	}
 
@@ -84,7 +97,7 @@ func (tr *TraefikRouter) Reconcile(canary *flaggerv1.Canary) error {
 			Spec: newSpec,
 		}
 
-		_, err = tr.traefikClient.TraefikV1alpha1().TraefikServices(canary.Namespace).Create(context.TODO(), traefikService, metav1.CreateOptions{})
+		_, err = tr.traefikClient.TraefikV1alpha1().TraefikServices(canary.Namespace).Create(context.TODO(), traefikService, metav1.CreateOptions{FieldManager: canary.FieldManager()})
 		if err != nil {
 			return fmt.Errorf("TraefikService %s.%s create error: %w", apexName, canary.Namespace, err)
 		}
@@ -118,7 +131,7 @@ func (tr *TraefikRouter) Reconcile(canary *flaggerv1.Canary) error {
 			clone := traefikService.DeepCopy()
 			clone.Spec = newSpec
 
-			_, err = tr.traefikClient.TraefikV1alpha1().TraefikServices(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{})
+			_, err = tr.traefikClient.TraefikV1alpha1().TraefikServices(canary.Namespace).Update(context.TODO(), clone, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 			if err != nil {
 				return fmt.Errorf("TraefikService %s.%s update error: %w", apexName, canary.Namespace, err)
 			}
@@ -197,14 +210,47 @@ func (tr *TraefikRouter) SetRoutes(
 	}
 
 	traefikService.Spec.Weighted.Services = services
+	traefikService.Spec.Weighted.Sticky = tr.makeSticky(canary)
 
-	_, err = tr.traefikClient.TraefikV1alpha1().TraefikServices(canary.Namespace).Update(context.TODO(), traefikService, metav1.UpdateOptions{})
+	_, err = tr.traefikClient.TraefikV1alpha1().TraefikServices(canary.Namespace).Update(context.TODO(), traefikService, metav1.UpdateOptions{FieldManager: canary.FieldManager()})
 	if err != nil {
 		return fmt.Errorf("TraefikService %s.%s update error: %w", apexName, canary.Namespace, err)
 	}
 	return nil
 }
 
-func (tr *TraefikRouter) Finalize(_ *flaggerv1.Canary) error {
+// makeSticky returns the sticky sessions cookie that pins the users to the service they were routed to
+func (tr *TraefikRouter) makeSticky(canary *flaggerv1.Canary) *traefikv1alpha1.Sticky {
+	affinity := canary.GetSessionAffinity()
+	if affinity == nil {
+		return nil
+	}
+	return &traefikv1alpha1.Sticky{
+		Cookie: &traefikv1alpha1.Cookie{
+			Name:   affinity.CookieName,
+			MaxAge: affinity.GetMaxAge(),
+		},
+	}
+}
+
+// Finalize deletes the TraefikService generated by Flagger
+func (tr *TraefikRouter) Finalize(canary *flaggerv1.Canary) error {
+	apexName, _, _ := canary.GetServiceNames()
+	client := tr.traefikClient.TraefikV1alpha1().TraefikServices(canary.Namespace)
+
+	deleted, err := finalizeGeneratedObject(canary, "TraefikService", fmt.Sprintf("%s.%s", apexName, canary.Namespace),
+		func() (metav1.Object, error) {
+			return client.Get(context.TODO(), apexName, metav1.GetOptions{})
+		},
+		func() error {
+			return client.Delete(context.TODO(), apexName, metav1.DeleteOptions{})
+		})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		tr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("TraefikService %s.%s deleted", apexName, canary.Namespace)
+	}
 	return nil
 }
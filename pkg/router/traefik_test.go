@@ -1,3 +1,6 @@
+//go:build !no_traefik
+// +build !no_traefik
+
 /*
 Copyright 2020 The Flux authors
 
@@ -24,6 +27,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -139,6 +143,31 @@ func TestTraefikRouter_SetRoutes(t *testing.T) {
 	}
 }
 
+func TestTraefikRouter_SessionAffinity(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &TraefikRouter{
+		traefikClient: mocks.meshClient,
+		logger:        mocks.logger,
+	}
+
+	mocks.canary.Spec.Analysis.SessionAffinity = &flaggerv1.SessionAffinity{CookieName: "flagger-cookie", MaxAge: 3600}
+
+	err := router.Reconcile(mocks.canary)
+	require.NoError(t, err)
+
+	err = router.SetRoutes(mocks.canary, 80, 20, false)
+	require.NoError(t, err)
+
+	ts, err := router.traefikClient.TraefikV1alpha1().TraefikServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	require.NotNil(t, ts.Spec.Weighted.Sticky)
+	require.NotNil(t, ts.Spec.Weighted.Sticky.Cookie)
+	assert.Equal(t, "flagger-cookie", ts.Spec.Weighted.Sticky.Cookie.Name)
+	assert.Equal(t, 3600, ts.Spec.Weighted.Sticky.Cookie.MaxAge)
+	assert.Len(t, ts.Spec.Weighted.Services, 2)
+}
+
 func TestTraefikRouter_GetRoutes(t *testing.T) {
 	mocks := newFixture(nil)
 	router := &TraefikRouter{
@@ -156,3 +185,20 @@ func TestTraefikRouter_GetRoutes(t *testing.T) {
 	assert.Equal(t, 0, c)
 	assert.False(t, m)
 }
+
+func TestTraefikRouter_Finalize(t *testing.T) {
+	mocks := newFixture(nil)
+	router := &TraefikRouter{
+		traefikClient: mocks.meshClient,
+		logger:        mocks.logger,
+	}
+
+	require.NoError(t, router.Reconcile(mocks.canary))
+	require.NoError(t, router.Finalize(mocks.canary))
+
+	_, err := router.traefikClient.TraefikV1alpha1().TraefikServices("default").Get(context.TODO(), "podinfo", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	// finalize is idempotent
+	require.NoError(t, router.Finalize(mocks.canary))
+}
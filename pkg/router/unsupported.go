@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// UnsupportedRouter is returned for the providers excluded from the build,
+// all the routing operations fail
+type UnsupportedRouter struct {
+	provider string
+}
+
+func (ur *UnsupportedRouter) Reconcile(_ *flaggerv1.Canary) error {
+	return ur.err()
+}
+
+func (ur *UnsupportedRouter) SetRoutes(_ *flaggerv1.Canary, _ int, _ int, _ bool) error {
+	return ur.err()
+}
+
+func (ur *UnsupportedRouter) GetRoutes(_ *flaggerv1.Canary) (primaryWeight int, canaryWeight int, mirror bool, err error) {
+	return 0, 0, false, ur.err()
+}
+
+func (ur *UnsupportedRouter) Finalize(_ *flaggerv1.Canary) error {
+	return ur.err()
+}
+
+func (ur *UnsupportedRouter) err() error {
+	return fmt.Errorf("router for provider %s is not included in this build of Flagger", ur.provider)
+}
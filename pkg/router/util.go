@@ -1,9 +1,15 @@
 package router
 
 import (
+	"fmt"
 	"strings"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
+// DefaultClusterDomain is the DNS domain of the Kubernetes cluster
+const DefaultClusterDomain = "cluster.local"
+
 const (
 	toolkitMarker         = "toolkit.fluxcd.io"
 	toolkitReconcileKey   = "kustomize.toolkit.fluxcd.io/reconcile"
@@ -32,3 +38,29 @@ func filterMetadata(meta map[string]string) map[string]string {
 	meta[toolkitReconcileKey] = toolkitReconcileValue
 	return meta
 }
+
+// serviceFQDN returns the fully qualified domain name of the service,
+// the cluster domain of the canary takes precedence over the router one
+func serviceFQDN(canary *flaggerv1.Canary, serviceName string, clusterDomain string) string {
+	if canary.Spec.Service.ClusterDomain != "" {
+		clusterDomain = canary.Spec.Service.ClusterDomain
+	}
+	if clusterDomain == "" {
+		clusterDomain = DefaultClusterDomain
+	}
+	return fmt.Sprintf("%s.%s.svc.%s", serviceName, canary.Namespace, strings.Trim(clusterDomain, "."))
+}
+
+// makeSetCookie returns the Set-Cookie header value that pins the users to the canary
+func makeSetCookie(canary *flaggerv1.Canary, affinity *flaggerv1.SessionAffinity) string {
+	return fmt.Sprintf("%s=%s; Max-Age=%d; Path=/", affinity.CookieName, canary.GetCookieValue(), affinity.GetMaxAge())
+}
+
+// makeStickyWeights returns the weights of the route matching the session affinity cookie,
+// the pinned users are routed to the canary as long as it receives traffic
+func makeStickyWeights(canaryWeight int) (int, int) {
+	if canaryWeight > 0 {
+		return 0, 100
+	}
+	return 100, 0
+}
@@ -20,6 +20,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
 )
 
 func TestIncludeLabelsByPrefix(t *testing.T) {
@@ -56,3 +59,13 @@ func TestIncludeLabelsByPrefixWithWildcard(t *testing.T) {
 		"lorem": "ipsum",
 	})
 }
+
+func TestServiceFQDN(t *testing.T) {
+	canary := &flaggerv1.Canary{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "test"}}
+
+	assert.Equal(t, "podinfo-primary.test.svc.cluster.local", serviceFQDN(canary, "podinfo-primary", ""))
+	assert.Equal(t, "podinfo-primary.test.svc.example.org", serviceFQDN(canary, "podinfo-primary", "example.org."))
+
+	canary.Spec.Service.ClusterDomain = "cluster.internal"
+	assert.Equal(t, "podinfo-primary.test.svc.cluster.internal", serviceFQDN(canary, "podinfo-primary", "example.org"))
+}
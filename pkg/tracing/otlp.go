@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/fluxcd/flagger/pkg/logger"
+)
+
+const (
+	// maxQueuedSpans is the number of ended spans kept between two exports, the spans above are dropped
+	maxQueuedSpans = 4096
+	// exportTimeout is the timeout of the OTLP requests
+	exportTimeout = 10 * time.Second
+	// instrumentationScope is the name of the instrumentation library of the spans
+	instrumentationScope = "github.com/fluxcd/flagger"
+)
+
+// OTLP/JSON encoding of the span kind and status codes
+const (
+	spanKindInternal = 1
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+// exporter batches the ended spans and posts them as OTLP/JSON to the collector
+type exporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+	logger      *zap.SugaredLogger
+
+	mu      sync.Mutex
+	spans   []*Span
+	dropped int
+}
+
+func newExporter(endpoint string, serviceName string, logger *zap.SugaredLogger) *exporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &exporter{
+		url:         url,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+		logger:      logger,
+	}
+}
+
+func (e *exporter) add(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) >= maxQueuedSpans {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, s)
+}
+
+// flush exports the queued spans, the spans are dropped if the collector can't be reached
+func (e *exporter) flush() {
+	e.mu.Lock()
+	spans, dropped := e.spans, e.dropped
+	e.spans, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		e.logger.Warnf("Dropped %d spans, the export queue is full", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+	if err := e.export(spans); err != nil {
+		e.logger.Errorf("Exporting %d spans to %s failed: %v", len(spans), logger.RedactURL(e.url), err)
+	}
+}
+
+func (e *exporter) export(spans []*Span) error {
+	payload, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return fmt.Errorf("marshalling spans failed: %w", err)
+	}
+
+	r, err := e.client.Post(e.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return logger.RedactError(err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return fmt.Errorf("collector responded with status code %d", r.StatusCode)
+	}
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+func (e *exporter) encode(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.attrs),
+			Status:            otlpStatus{Code: statusCodeOK},
+		}
+		if s.err != "" {
+			span.Status = otlpStatus{Code: statusCodeError, Message: s.err}
+		}
+		s.mu.Unlock()
+		out = append(out, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes([]Attribute{String("service.name", e.serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: out}},
+	}}}
+}
+
+// encodeAttributes returns the attributes in the OTLP/JSON encoding,
+// where the 64-bit integers are encoded as strings
+func encodeAttributes(attrs []Attribute) []otlpAttribute {
+	out := make([]otlpAttribute, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]string
+		switch v := a.Value.(type) {
+		case int64:
+			value = map[string]string{"intValue": strconv.FormatInt(v, 10)}
+		default:
+			value = map[string]string{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttribute{Key: a.Key, Value: value})
+	}
+	return out
+}
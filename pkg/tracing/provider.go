@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"time"
+
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+)
+
+// Provider wraps the metrics provider to record a span for each query of the metric
+func (s *Span) Provider(p providers.Interface, metricName string) providers.Interface {
+	if s == nil {
+		return p
+	}
+	tp := provider{Interface: p, span: s, metricName: metricName}
+	if rp, ok := p.(providers.RangeInterface); ok {
+		return &rangeProvider{provider: tp, rangeInterface: rp}
+	}
+	return &tp
+}
+
+type provider struct {
+	providers.Interface
+	span       *Span
+	metricName string
+}
+
+func (p *provider) RunQuery(query string) (float64, error) {
+	span := p.span.Child("metric.query", String("metric.name", p.metricName), String("metric.query", query))
+	defer span.End()
+	val, err := p.Interface.RunQuery(query)
+	span.RecordError(err)
+	return val, err
+}
+
+func (p *provider) IsOnline() (bool, error) {
+	span := p.span.Child("metric.provider.online", String("metric.name", p.metricName))
+	defer span.End()
+	ok, err := p.Interface.IsOnline()
+	span.RecordError(err)
+	return ok, err
+}
+
+type rangeProvider struct {
+	provider
+	rangeInterface providers.RangeInterface
+}
+
+func (p *rangeProvider) RunRangeQuery(query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	span := p.span.Child("metric.range_query", String("metric.name", p.metricName), String("metric.query", query))
+	defer span.End()
+	samples, err := p.rangeInterface.RunRangeQuery(query, start, end, step)
+	span.RecordError(err)
+	return samples, err
+}
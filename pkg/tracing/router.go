@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// MeshRouter wraps the mesh router to record a span for each API call
+func (s *Span) MeshRouter(r router.Interface) router.Interface {
+	if s == nil {
+		return r
+	}
+	return &meshRouter{Interface: r, span: s, kind: fmt.Sprintf("%T", r)}
+}
+
+// KubernetesRouter wraps the Kubernetes router to record a span for each API call
+func (s *Span) KubernetesRouter(r router.KubernetesRouter) router.KubernetesRouter {
+	if s == nil {
+		return r
+	}
+	return &kubernetesRouter{KubernetesRouter: r, span: s, kind: fmt.Sprintf("%T", r)}
+}
+
+// trace runs the router call in a child span of the analysis run
+func trace(parent *Span, name string, kind string, call func() error, attrs ...Attribute) error {
+	span := parent.Child(name, append([]Attribute{String("router.type", kind)}, attrs...)...)
+	defer span.End()
+	err := call()
+	span.RecordError(err)
+	return err
+}
+
+type meshRouter struct {
+	router.Interface
+	span *Span
+	kind string
+}
+
+func (r *meshRouter) Reconcile(canary *flaggerv1.Canary) error {
+	return trace(r.span, "router.Reconcile", r.kind, func() error {
+		return r.Interface.Reconcile(canary)
+	})
+}
+
+func (r *meshRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int, mirrored bool) error {
+	return trace(r.span, "router.SetRoutes", r.kind, func() error {
+		return r.Interface.SetRoutes(canary, primaryWeight, canaryWeight, mirrored)
+	}, Int("router.primary_weight", primaryWeight), Int("router.canary_weight", canaryWeight))
+}
+
+func (r *meshRouter) GetRoutes(canary *flaggerv1.Canary) (primaryWeight int, canaryWeight int, mirrored bool, err error) {
+	err = trace(r.span, "router.GetRoutes", r.kind, func() error {
+		var err error
+		primaryWeight, canaryWeight, mirrored, err = r.Interface.GetRoutes(canary)
+		return err
+	})
+	return
+}
+
+func (r *meshRouter) Finalize(canary *flaggerv1.Canary) error {
+	return trace(r.span, "router.Finalize", r.kind, func() error {
+		return r.Interface.Finalize(canary)
+	})
+}
+
+type kubernetesRouter struct {
+	router.KubernetesRouter
+	span *Span
+	kind string
+}
+
+func (r *kubernetesRouter) Initialize(canary *flaggerv1.Canary) error {
+	return trace(r.span, "router.Initialize", r.kind, func() error {
+		return r.KubernetesRouter.Initialize(canary)
+	})
+}
+
+func (r *kubernetesRouter) Reconcile(canary *flaggerv1.Canary) error {
+	return trace(r.span, "router.Reconcile", r.kind, func() error {
+		return r.KubernetesRouter.Reconcile(canary)
+	})
+}
+
+func (r *kubernetesRouter) Finalize(canary *flaggerv1.Canary) error {
+	return trace(r.span, "router.Finalize", r.kind, func() error {
+		return r.KubernetesRouter.Finalize(canary)
+	})
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Attribute is a key value pair attached to a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Tracer records the spans of the reconcile and analysis loops and exports them
+// to an OpenTelemetry collector with the OTLP/HTTP protocol.
+// A nil Tracer and the spans it returns are no-ops.
+type Tracer struct {
+	exporter *exporter
+}
+
+// NewTracer returns a Tracer exporting the spans to the OTLP endpoint e.g. http://otel-collector:4318,
+// the returned Tracer is nil if the endpoint is empty
+func NewTracer(endpoint string, serviceName string, logger *zap.SugaredLogger) *Tracer {
+	if endpoint == "" {
+		return nil
+	}
+	return &Tracer{exporter: newExporter(endpoint, serviceName, logger)}
+}
+
+// Run exports the ended spans at the given interval until the stop channel is closed,
+// the remaining spans are exported on stop
+func (t *Tracer) Run(interval time.Duration, stopCh <-chan struct{}) {
+	if t == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.exporter.flush()
+		case <-stopCh:
+			t.exporter.flush()
+			return
+		}
+	}
+}
+
+// Start starts a root span
+func (t *Tracer) Start(name string, attrs ...Attribute) *Span {
+	if t == nil {
+		return nil
+	}
+	return t.newSpan(newID(16), "", name, attrs)
+}
+
+func (t *Tracer) newSpan(traceID, parentID, name string, attrs []Attribute) *Span {
+	return &Span{
+		tracer:   t,
+		traceID:  traceID,
+		spanID:   newID(8),
+		parentID: parentID,
+		name:     name,
+		start:    time.Now(),
+		attrs:    attrs,
+	}
+}
+
+// Span is a timed operation of a trace
+type Span struct {
+	tracer   *Tracer
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []Attribute
+	err   string
+}
+
+// Child starts a span nested in this span
+func (s *Span) Child(name string, attrs ...Attribute) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.newSpan(s.traceID, s.spanID, name, attrs)
+}
+
+// SetAttributes adds the attributes to the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// RecordError sets the status of the span to error
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End sets the end time of the span and queues it for export, the calls after the first one are ignored
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.exporter.add(s)
+}
+
+// TraceID returns the hex encoded ID of the trace of the span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.traceID
+}
+
+func newID(size int) string {
+	b := make([]byte, size)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
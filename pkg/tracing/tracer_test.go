@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+type fakeProvider struct{}
+
+func (fakeProvider) RunQuery(query string) (float64, error) { return 0, errors.New("timeout") }
+func (fakeProvider) IsOnline() (bool, error)                { return true, nil }
+
+type fakeRouter struct{}
+
+func (fakeRouter) Reconcile(canary *flaggerv1.Canary) error { return nil }
+func (fakeRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int, mirrored bool) error {
+	return nil
+}
+func (fakeRouter) GetRoutes(canary *flaggerv1.Canary) (int, int, bool, error) {
+	return 90, 10, false, nil
+}
+func (fakeRouter) Finalize(canary *flaggerv1.Canary) error { return nil }
+
+func TestTracer_Export(t *testing.T) {
+	var received otlpRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer ts.Close()
+
+	tracer := NewTracer(ts.URL, "flagger", zap.NewNop().Sugar())
+	root := tracer.Start("canary.advance", String("canary.name", "podinfo"))
+	_, err := root.Provider(fakeProvider{}, "error-rate").RunQuery("sum(errors)")
+	require.Error(t, err)
+	primary, _, _, err := root.MeshRouter(fakeRouter{}).GetRoutes(&flaggerv1.Canary{})
+	require.NoError(t, err)
+	assert.Equal(t, 90, primary)
+	root.End()
+	root.End()
+	tracer.exporter.flush()
+
+	require.Len(t, received.ResourceSpans, 1)
+	assert.Equal(t, "flagger", received.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 3)
+	assert.Equal(t, "metric.query", spans[0].Name)
+	assert.Equal(t, root.spanID, spans[0].ParentSpanID)
+	assert.Equal(t, root.TraceID(), spans[0].TraceID)
+	assert.Equal(t, statusCodeError, spans[0].Status.Code)
+	assert.Equal(t, "router.GetRoutes", spans[1].Name)
+	assert.Equal(t, statusCodeOK, spans[1].Status.Code)
+	assert.Equal(t, "canary.advance", spans[2].Name)
+	assert.Empty(t, spans[2].ParentSpanID)
+}
+
+func TestTracer_Disabled(t *testing.T) {
+	tracer := NewTracer("", "flagger", zap.NewNop().Sugar())
+	assert.Nil(t, tracer)
+
+	span := tracer.Start("canary.advance")
+	span.Child("webhook").End()
+	span.RecordError(errors.New("failed"))
+	span.End()
+	assert.Empty(t, span.TraceID())
+
+	// the routers and providers are not wrapped when tracing is disabled
+	assert.Equal(t, fakeProvider{}, span.Provider(fakeProvider{}, "error-rate"))
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1"
+	"github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// ValidationPath is the path of the validating admission webhook
+const ValidationPath = "/validate"
+
+// ValidationHandler rejects the canaries, metric templates and alert providers with an invalid spec
+type ValidationHandler struct {
+	Logger *zap.SugaredLogger
+}
+
+func (h *ValidationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("reading the request failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, "decoding the admission review failed", http.StatusBadRequest)
+		return
+	}
+
+	response := &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+	errs, err := validate(review.Request)
+	switch {
+	case err != nil:
+		response.Allowed = false
+		response.Result = &metav1.Status{Status: metav1.StatusFailure, Code: http.StatusBadRequest,
+			Reason: metav1.StatusReasonBadRequest, Message: err.Error()}
+	case len(errs) > 0:
+		kind := review.Request.Kind
+		status := errors.NewInvalid(v1beta1.Kind(kind.Kind), review.Request.Name, errs).ErrStatus
+		response.Allowed = false
+		response.Result = &status
+		h.Logger.With("namespace", review.Request.Namespace).
+			Debugf("Rejected %s %s: %v", kind.Kind, review.Request.Name, errs.ToAggregate())
+	}
+
+	review.Request = nil
+	review.Response = response
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		h.Logger.Errorf("Writing the admission response failed: %v", err)
+	}
+}
+
+// validate decodes the object of the admission request and checks its spec
+func validate(request *admissionv1.AdmissionRequest) (field.ErrorList, error) {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return nil, nil
+	}
+
+	switch request.Kind.Kind {
+	case v1beta1.CanaryKind:
+		cd := &v1beta1.Canary{}
+		if request.Kind.Version == flaggerv1.SchemeGroupVersion.Version {
+			src := &flaggerv1.Canary{}
+			if err := json.Unmarshal(request.Object.Raw, src); err != nil {
+				return nil, fmt.Errorf("decoding the canary failed: %w", err)
+			}
+			src.ConvertTo(cd)
+		} else if err := json.Unmarshal(request.Object.Raw, cd); err != nil {
+			return nil, fmt.Errorf("decoding the canary failed: %w", err)
+		}
+		return ValidateCanary(cd), nil
+	case v1beta1.MetricTemplateKind:
+		template := &v1beta1.MetricTemplate{}
+		if err := json.Unmarshal(request.Object.Raw, template); err != nil {
+			return nil, fmt.Errorf("decoding the metric template failed: %w", err)
+		}
+		return ValidateMetricTemplate(template), nil
+	case v1beta1.AlertProviderKind:
+		provider := &v1beta1.AlertProvider{}
+		if err := json.Unmarshal(request.Object.Raw, provider); err != nil {
+			return nil, fmt.Errorf("decoding the alert provider failed: %w", err)
+		}
+		return ValidateAlertProvider(provider), nil
+	default:
+		return nil, nil
+	}
+}
+
+// EnsureValidatingWebhook creates or updates the configuration of the validating webhook served by the service,
+// the objects of the other namespaces are not validated when the namespace is set
+func EnsureValidatingWebhook(ctx context.Context, kubeClient kubernetes.Interface, service string, namespace string,
+	caBundle []byte, watchNamespace string) error {
+	path := ValidationPath
+	failurePolicy := admissionregistrationv1.Ignore
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	timeout := int32(5)
+
+	var namespaceSelector *metav1.LabelSelector
+	if watchNamespace != "" {
+		namespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"kubernetes.io/metadata.name": watchNamespace},
+		}
+	}
+
+	var webhooks []admissionregistrationv1.ValidatingWebhook
+	for _, resource := range []string{"canaries", "metrictemplates", "alertproviders"} {
+		versions := []string{v1beta1.SchemeGroupVersion.Version}
+		if resource == "canaries" {
+			versions = append(versions, flaggerv1.SchemeGroupVersion.Version)
+		}
+		webhooks = append(webhooks, admissionregistrationv1.ValidatingWebhook{
+			Name: fmt.Sprintf("%s.%s", resource, v1beta1.SchemeGroupVersion.Group),
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service:  &admissionregistrationv1.ServiceReference{Name: service, Namespace: namespace, Path: &path},
+				CABundle: caBundle,
+			},
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{v1beta1.SchemeGroupVersion.Group},
+					APIVersions: versions,
+					Resources:   []string{resource},
+				},
+			}},
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			NamespaceSelector:       namespaceSelector,
+			AdmissionReviewVersions: []string{"v1"},
+			TimeoutSeconds:          &timeout,
+		})
+	}
+
+	name := fmt.Sprintf("%s.%s", service, namespace)
+	client := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	existing, err := client.Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		config := &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Webhooks:   webhooks,
+		}
+		if _, err := client.Create(ctx, config, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating the validating webhook %s failed: %w", name, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("getting the validating webhook %s failed: %w", name, err)
+	}
+
+	existing.Webhooks = webhooks
+	if _, err := client.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating the validating webhook %s failed: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func reviewCanary(t *testing.T, version string, canary string) *admissionv1.AdmissionResponse {
+	review := `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"123",` +
+		`"kind":{"group":"flagger.app","version":"` + version + `","kind":"Canary"},"name":"podinfo","namespace":"default",` +
+		`"operation":"CREATE","object":` + canary + `}}`
+	rec := httptest.NewRecorder()
+	handler := &ValidationHandler{Logger: zap.NewNop().Sugar()}
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ValidationPath, bytes.NewBufferString(review)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var out admissionv1.AdmissionReview
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	require.NotNil(t, out.Response)
+	assert.Equal(t, "123", string(out.Response.UID))
+	return out.Response
+}
+
+func TestValidationHandler(t *testing.T) {
+	response := reviewCanary(t, "v1beta1", testCanary)
+	assert.True(t, response.Allowed)
+
+	// the v1 canaries are validated after the conversion
+	response = reviewCanary(t, "v1", `{"apiVersion":"flagger.app/v1","kind":"Canary","metadata":{"name":"podinfo"},
+"spec":{"provider":"linkerd","targetRef":{"kind":"Deployment","name":"podinfo"},"analysis":{"steps":[{"weight":50},{"weight":20}]}}}`)
+	assert.False(t, response.Allowed)
+	require.NotNil(t, response.Result)
+	assert.Equal(t, metav1.StatusReasonInvalid, response.Result.Reason)
+	assert.Contains(t, response.Result.Message, "spec.analysis.stepWeights[1]")
+}
+
+func TestEnsureValidatingWebhook(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	err := EnsureValidatingWebhook(context.TODO(), kubeClient, "flagger", "flagger-system", []byte("ca"), "")
+	require.NoError(t, err)
+
+	// the configuration is updated on restart
+	err = EnsureValidatingWebhook(context.TODO(), kubeClient, "flagger", "flagger-system", []byte("ca"), "test")
+	require.NoError(t, err)
+
+	config, err := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().
+		Get(context.TODO(), "flagger.flagger-system", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, config.Webhooks, 3)
+	assert.Equal(t, "canaries.flagger.app", config.Webhooks[0].Name)
+	assert.Equal(t, []string{"v1beta1", "v1"}, config.Webhooks[0].Rules[0].APIVersions)
+	assert.Equal(t, ValidationPath, *config.Webhooks[0].ClientConfig.Service.Path)
+	assert.Equal(t, "test", config.Webhooks[2].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"])
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1"
+	"github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// ConversionPath is the path of the Canary conversion webhook
+const ConversionPath = "/convert"
+
+// ConversionReview describes a conversion request and response of the apiextensions.k8s.io/v1 API
+type ConversionReview struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Request  *ConversionRequest  `json:"request,omitempty"`
+	Response *ConversionResponse `json:"response,omitempty"`
+}
+
+// ConversionRequest lists the objects to convert to the desired API version
+type ConversionRequest struct {
+	UID               types.UID              `json:"uid"`
+	DesiredAPIVersion string                 `json:"desiredAPIVersion"`
+	Objects           []runtime.RawExtension `json:"objects"`
+}
+
+// ConversionResponse lists the converted objects in the order of the request
+type ConversionResponse struct {
+	UID              types.UID              `json:"uid"`
+	ConvertedObjects []runtime.RawExtension `json:"convertedObjects"`
+	Result           metav1.Status          `json:"result"`
+}
+
+// ConversionHandler converts the canaries between the v1beta1 and v1 API versions
+type ConversionHandler struct {
+	Logger *zap.SugaredLogger
+}
+
+func (h *ConversionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("reading the request failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review := ConversionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, "decoding the conversion review failed", http.StatusBadRequest)
+		return
+	}
+
+	response := &ConversionResponse{
+		UID:    review.Request.UID,
+		Result: metav1.Status{Status: metav1.StatusSuccess},
+	}
+	for _, obj := range review.Request.Objects {
+		converted, err := ConvertCanary(obj.Raw, review.Request.DesiredAPIVersion)
+		if err != nil {
+			h.Logger.Errorf("Canary conversion to %s failed: %v", review.Request.DesiredAPIVersion, err)
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			break
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+
+	review.Request = nil
+	review.Response = response
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		h.Logger.Errorf("Writing the conversion response failed: %v", err)
+	}
+}
+
+// ConvertCanary converts a JSON encoded canary to the desired API version
+func ConvertCanary(data []byte, desiredAPIVersion string) ([]byte, error) {
+	meta := metav1.TypeMeta{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("decoding the object failed: %w", err)
+	}
+	if meta.Kind != v1beta1.CanaryKind {
+		return nil, fmt.Errorf("kind %s is not supported", meta.Kind)
+	}
+	if meta.APIVersion == desiredAPIVersion {
+		return data, nil
+	}
+
+	switch {
+	case meta.APIVersion == v1beta1.SchemeGroupVersion.String() && desiredAPIVersion == flaggerv1.SchemeGroupVersion.String():
+		src := &v1beta1.Canary{}
+		if err := json.Unmarshal(data, src); err != nil {
+			return nil, fmt.Errorf("decoding the canary failed: %w", err)
+		}
+		dst := &flaggerv1.Canary{}
+		dst.ConvertFrom(src)
+		return json.Marshal(dst)
+	case meta.APIVersion == flaggerv1.SchemeGroupVersion.String() && desiredAPIVersion == v1beta1.SchemeGroupVersion.String():
+		src := &flaggerv1.Canary{}
+		if err := json.Unmarshal(data, src); err != nil {
+			return nil, fmt.Errorf("decoding the canary failed: %w", err)
+		}
+		dst := &v1beta1.Canary{}
+		src.ConvertTo(dst)
+		return json.Marshal(dst)
+	default:
+		return nil, fmt.Errorf("conversion from %s to %s is not supported", meta.APIVersion, desiredAPIVersion)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1"
+)
+
+const testCanary = `{
+  "apiVersion": "flagger.app/v1beta1",
+  "kind": "Canary",
+  "metadata": {"name": "podinfo", "namespace": "default"},
+  "spec": {
+    "targetRef": {"apiVersion": "apps/v1", "kind": "Deployment", "name": "podinfo"},
+    "service": {"port": 9898},
+    "analysis": {
+      "interval": "1m",
+      "threshold": 5,
+      "stepWeights": [10, 50],
+      "metrics": [{"name": "request-success-rate", "threshold": 99}]
+    }
+  },
+  "status": {"phase": "Succeeded"}
+}`
+
+func TestConversionHandler(t *testing.T) {
+	review := `{"apiVersion":"apiextensions.k8s.io/v1","kind":"ConversionReview","request":{"uid":"123","desiredAPIVersion":"flagger.app/v1","objects":[` + testCanary + `]}}`
+	rec := httptest.NewRecorder()
+	handler := &ConversionHandler{Logger: zap.NewNop().Sugar()}
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ConversionPath, bytes.NewBufferString(review)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var out ConversionReview
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	require.NotNil(t, out.Response)
+	assert.Equal(t, "123", string(out.Response.UID))
+	assert.Equal(t, "Success", out.Response.Result.Status)
+	require.Len(t, out.Response.ConvertedObjects, 1)
+
+	var cd flaggerv1.Canary
+	require.NoError(t, json.Unmarshal(out.Response.ConvertedObjects[0].Raw, &cd))
+	assert.Equal(t, "flagger.app/v1", cd.APIVersion)
+	assert.Equal(t, []flaggerv1.CanaryStep{{Weight: 10}, {Weight: 50}}, cd.Spec.Analysis.Steps)
+	assert.Equal(t, float64(99), *cd.Spec.Analysis.Metrics[0].ThresholdRange.Min)
+	assert.Equal(t, "Succeeded", string(cd.Status.Phase))
+
+	// the objects of an unknown version are not converted
+	review = `{"request":{"uid":"123","desiredAPIVersion":"flagger.app/v2","objects":[` + testCanary + `]}}`
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ConversionPath, bytes.NewBufferString(review)))
+	out = ConversionReview{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	assert.Equal(t, "Failure", out.Response.Result.Status)
+	assert.Empty(t, out.Response.ConvertedObjects)
+}
+
+func TestConvertCanary_RoundTrip(t *testing.T) {
+	v1, err := ConvertCanary([]byte(testCanary), "flagger.app/v1")
+	require.NoError(t, err)
+	assert.Contains(t, string(v1), `"steps":[{"weight":10},{"weight":50}]`)
+
+	v1beta1, err := ConvertCanary(v1, "flagger.app/v1beta1")
+	require.NoError(t, err)
+	assert.Contains(t, string(v1beta1), `"stepWeights":[10,50]`)
+	assert.NotContains(t, string(v1beta1), "steps")
+
+	same, err := ConvertCanary(v1beta1, "flagger.app/v1beta1")
+	require.NoError(t, err)
+	assert.Equal(t, v1beta1, same)
+}
+
+func TestPatchCanaryConversion(t *testing.T) {
+	var patch []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, CanaryCRDPath, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"spec":{"versions":[{"name":"v1beta1","served":true},{"name":"v1","served":false}]}}`))
+			return
+		}
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "application/json-patch+json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &patch))
+		w.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: ts.URL})
+	require.NoError(t, err)
+	err = PatchCanaryConversion(context.TODO(), kubeClient.Discovery().RESTClient(), "flagger", "flagger-system", []byte("ca"))
+	require.NoError(t, err)
+
+	require.Len(t, patch, 3)
+	assert.Equal(t, map[string]interface{}{"op": "test", "path": "/spec/versions/1/name", "value": "v1"}, patch[0])
+	assert.Equal(t, "/spec/conversion", patch[1]["path"])
+	webhook := patch[1]["value"].(map[string]interface{})["webhook"].(map[string]interface{})
+	clientConfig := webhook["clientConfig"].(map[string]interface{})
+	assert.Equal(t, "Y2E=", clientConfig["caBundle"])
+	assert.Equal(t, map[string]interface{}{"name": "flagger", "namespace": "flagger-system", "path": "/convert"}, clientConfig["service"])
+	assert.Equal(t, map[string]interface{}{"op": "replace", "path": "/spec/versions/1/served", "value": true}, patch[2])
+}
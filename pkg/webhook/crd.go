@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// CanaryCRDPath is the API path of the Canary custom resource definition
+const CanaryCRDPath = "/apis/apiextensions.k8s.io/v1/customresourcedefinitions/canaries.flagger.app"
+
+// canaryCRD holds the fields of the Canary CRD read to build the conversion patch
+type canaryCRD struct {
+	Spec struct {
+		Versions []struct {
+			Name string `json:"name"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// PatchCanaryConversion configures the Canary CRD to convert the API versions with the webhook
+// served by the service and starts serving the v1 version, the CA bundle is used by the API server
+// to verify the webhook certificate
+func PatchCanaryConversion(ctx context.Context, client rest.Interface, service string, namespace string, caBundle []byte) error {
+	raw, err := client.Get().AbsPath(CanaryCRDPath).Do(ctx).Raw()
+	if err != nil {
+		return fmt.Errorf("fetching the Canary CRD failed: %w", err)
+	}
+	var crd canaryCRD
+	if err := json.Unmarshal(raw, &crd); err != nil {
+		return fmt.Errorf("decoding the Canary CRD failed: %w", err)
+	}
+	index := -1
+	for i, v := range crd.Spec.Versions {
+		if v.Name == "v1" {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("the Canary CRD doesn't declare the v1 version")
+	}
+
+	path := ConversionPath
+	conversion := map[string]interface{}{
+		"strategy": "Webhook",
+		"webhook": map[string]interface{}{
+			"conversionReviewVersions": []string{"v1"},
+			"clientConfig": map[string]interface{}{
+				"caBundle": caBundle,
+				"service": map[string]interface{}{
+					"name":      service,
+					"namespace": namespace,
+					"path":      &path,
+				},
+			},
+		},
+	}
+	// the conversion and the v1 version are enabled together so that the v1 canaries are never stored unconverted,
+	// the test operation fails the patch if the versions were reordered since they were read
+	versionPath := fmt.Sprintf("/spec/versions/%d", index)
+	patch := []map[string]interface{}{
+		{"op": "test", "path": versionPath + "/name", "value": "v1"},
+		{"op": "add", "path": "/spec/conversion", "value": conversion},
+		{"op": "replace", "path": versionPath + "/served", "value": true},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	if err := client.Patch(types.JSONPatchType).AbsPath(CanaryCRDPath).Body(data).Do(ctx).Error(); err != nil {
+		return fmt.Errorf("patching the Canary CRD conversion failed: %w", err)
+	}
+	return nil
+}
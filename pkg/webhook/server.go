@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// CertName is the file name of the serving certificate in the certificate directory
+	CertName = "tls.crt"
+	// KeyName is the file name of the serving key in the certificate directory
+	KeyName = "tls.key"
+	// CAName is the file name of the CA bundle in the certificate directory
+	CAName = "ca.crt"
+)
+
+// Server serves the Kubernetes API server webhooks over TLS,
+// the certificate is read again when it is renewed
+type Server struct {
+	port    string
+	certDir string
+	logger  *zap.SugaredLogger
+	mux     *http.ServeMux
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewServer creates a webhook server with the certificate and key stored in the directory
+func NewServer(port string, certDir string, logger *zap.SugaredLogger) *Server {
+	s := &Server{
+		port:    port,
+		certDir: certDir,
+		logger:  logger,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.Handle(ConversionPath, &ConversionHandler{Logger: logger})
+	s.mux.Handle(ValidationPath, &ValidationHandler{Logger: logger})
+	return s
+}
+
+// Handle registers a webhook handler for the path
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+}
+
+// CABundle returns the PEM encoded CA bundle from the certificate directory
+func (s *Server) CABundle() ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.certDir, CAName))
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle failed: %w", err)
+	}
+	return data, nil
+}
+
+// ListenAndServe starts the webhook server and waits for SIGTERM
+func (s *Server) ListenAndServe(timeout time.Duration, stopCh <-chan struct{}) error {
+	if _, err := s.getCertificate(nil); err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:         ":" + s.port,
+		Handler:      s.mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  15 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: s.getCertificate,
+		},
+	}
+
+	s.logger.Infof("Starting webhook server on port %s", s.port)
+
+	// run server in background
+	go func() {
+		if err := srv.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+			s.logger.Fatalf("Webhook server crashed %v", err)
+		}
+	}()
+
+	// wait for SIGTERM or SIGINT
+	<-stopCh
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		s.logger.Errorf("Webhook server graceful shutdown failed %v", err)
+	} else {
+		s.logger.Info("Webhook server stopped")
+	}
+	return nil
+}
+
+// getCertificate returns the serving certificate, it is loaded again when the file changes
+func (s *Server) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	certPath := filepath.Join(s.certDir, CertName)
+	info, err := os.Stat(certPath)
+	if err != nil {
+		if s.cert != nil {
+			return s.cert, nil
+		}
+		return nil, fmt.Errorf("reading webhook certificate failed: %w", err)
+	}
+	if s.cert != nil && info.ModTime().Equal(s.modTime) {
+		return s.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, filepath.Join(s.certDir, KeyName))
+	if err != nil {
+		if s.cert != nil {
+			s.logger.Errorf("Loading the renewed webhook certificate failed: %v", err)
+			return s.cert, nil
+		}
+		return nil, fmt.Errorf("loading webhook certificate failed: %w", err)
+	}
+	if s.cert != nil {
+		s.logger.Info("Webhook certificate renewed")
+	}
+	s.cert, s.modTime = &cert, info.ModTime()
+	return s.cert, nil
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+	"github.com/fluxcd/flagger/pkg/canary"
+	"github.com/fluxcd/flagger/pkg/metrics/observers"
+	"github.com/fluxcd/flagger/pkg/metrics/providers"
+	"github.com/fluxcd/flagger/pkg/notifier"
+	"github.com/fluxcd/flagger/pkg/router"
+)
+
+// dryRunModel is used to render the queries at apply time
+var dryRunModel = v1beta1.MetricTemplateModel{
+	Name:        "dry-run",
+	Namespace:   "dry-run",
+	Target:      "dry-run",
+	Service:     "dry-run",
+	Ingress:     "dry-run",
+	Interval:    v1beta1.MetricInterval,
+	RunDuration: v1beta1.MetricInterval,
+}
+
+// ValidateCanary checks the provider, the target and the analysis of the canary
+func ValidateCanary(cd *v1beta1.Canary) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	if err := router.ValidateProvider(cd.Spec.Provider); err != nil {
+		errs = append(errs, field.Invalid(spec.Child("provider"), cd.Spec.Provider, err.Error()))
+	}
+
+	targetRef := spec.Child("targetRef")
+	if cd.Spec.TargetRef.Name == "" {
+		errs = append(errs, field.Required(targetRef.Child("name"), ""))
+	}
+	if tw := cd.Spec.TargetWorkload; tw != nil {
+		if canary.IsSupportedKind(cd.Spec.TargetRef.Kind) {
+			errs = append(errs, field.Forbidden(spec.Child("targetWorkload"),
+				fmt.Sprintf("%s targets are supported natively", cd.Spec.TargetRef.Kind)))
+		}
+		if tw.Resource == "" {
+			errs = append(errs, field.Required(spec.Child("targetWorkload", "resource"), ""))
+		}
+		if cd.Spec.TargetRef.APIVersion == "" {
+			errs = append(errs, field.Required(targetRef.Child("apiVersion"), "required for custom workloads"))
+		}
+	} else if !canary.IsSupportedKind(cd.Spec.TargetRef.Kind) {
+		errs = append(errs, field.NotSupported(targetRef.Child("kind"), cd.Spec.TargetRef.Kind,
+			[]string{"Deployment", "DaemonSet", "StatefulSet", "Rollout", "Service"}))
+	}
+
+	seen := make(map[string]bool)
+	for i, obj := range cd.Spec.TrackedObjects {
+		path := spec.Child("trackedObjects").Index(i)
+		if _, err := schema.ParseGroupVersion(obj.APIVersion); err != nil || obj.APIVersion == "" {
+			errs = append(errs, field.Invalid(path.Child("apiVersion"), obj.APIVersion, "must be a valid API version"))
+		}
+		if obj.Kind == "" {
+			errs = append(errs, field.Required(path.Child("kind"), ""))
+		}
+		if obj.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		}
+		key := fmt.Sprintf("%s/%s/%s", obj.APIVersion, obj.GetResource(), obj.Name)
+		if seen[key] {
+			errs = append(errs, field.Duplicate(path, key))
+		}
+		seen[key] = true
+	}
+
+	for i, rule := range cd.Spec.ConfigRewrites {
+		path := spec.Child("configRewrites").Index(i)
+		switch rule.Field {
+		case v1beta1.ArgsConfigRewriteField:
+			if len(rule.Keys) > 0 {
+				errs = append(errs, field.Forbidden(path.Child("keys"), "not supported for Args rules"))
+			}
+		case v1beta1.EnvConfigRewriteField:
+		case v1beta1.AnnotationsConfigRewriteField:
+			if len(rule.Containers) > 0 {
+				errs = append(errs, field.Forbidden(path.Child("containers"), "not supported for Annotations rules"))
+			}
+		default:
+			errs = append(errs, field.NotSupported(path.Child("field"), rule.Field,
+				[]string{string(v1beta1.ArgsConfigRewriteField), string(v1beta1.EnvConfigRewriteField), string(v1beta1.AnnotationsConfigRewriteField)}))
+		}
+	}
+	if len(cd.Spec.ConfigRewrites) > 0 && cd.Spec.TargetRef.Kind == "Service" {
+		errs = append(errs, field.Forbidden(spec.Child("configRewrites"), "requires a workload target"))
+	}
+
+	if it := cd.Spec.ImageTracking; it != nil {
+		for i, name := range it.Exclude {
+			for _, included := range it.Include {
+				if name == included {
+					errs = append(errs, field.Invalid(spec.Child("imageTracking", "exclude").Index(i), name,
+						"container is also included"))
+				}
+			}
+		}
+	}
+
+	if cd.Spec.NodePartition != nil {
+		if cd.Spec.TargetRef.Kind != "DaemonSet" {
+			errs = append(errs, field.Forbidden(spec.Child("nodePartition"), "requires a DaemonSet target"))
+		} else if a := cd.GetAnalysis(); a != nil && a.Iterations > 0 {
+			errs = append(errs, field.Forbidden(spec.Child("nodePartition"), "requires a progressive traffic shifting analysis"))
+		}
+	}
+
+	if cd.Spec.PrimaryNaming != nil {
+		errs = append(errs, validatePrimaryNaming(cd, spec.Child("primaryNaming"))...)
+	}
+
+	if cd.Spec.PrimaryAntiAffinity != nil && cd.Spec.TargetRef.Kind != "Deployment" && cd.Spec.TargetRef.Kind != "StatefulSet" {
+		errs = append(errs, field.Forbidden(spec.Child("primaryAntiAffinity"), "requires a Deployment or StatefulSet target"))
+	}
+
+	switch cd.Spec.ConflictPolicy {
+	case "", v1beta1.RevertConflictPolicy, v1beta1.RevertWithWarningConflictPolicy, v1beta1.AdoptConflictPolicy:
+	default:
+		errs = append(errs, field.NotSupported(spec.Child("conflictPolicy"), cd.Spec.ConflictPolicy,
+			[]string{string(v1beta1.RevertConflictPolicy), string(v1beta1.RevertWithWarningConflictPolicy), string(v1beta1.AdoptConflictPolicy)}))
+	}
+	if cd.Spec.ConflictPolicy != "" {
+		switch cd.Spec.TargetRef.Kind {
+		case "DaemonSet", "StatefulSet", "Service":
+			errs = append(errs, field.Forbidden(spec.Child("conflictPolicy"), "requires a Deployment, Rollout or custom workload target"))
+		}
+	}
+
+	if len(cd.Spec.Service.ExistingServices) > 0 {
+		errs = append(errs, validateExistingServices(cd, spec.Child("service", "existingServices"))...)
+	}
+
+	if ref := cd.Spec.AutoscalerRef; ref != nil && ref.PrimaryScalerReplicas != nil {
+		path := spec.Child("autoscalerRef", "primaryScalerReplicas")
+		if ref.Kind == "VerticalPodAutoscaler" {
+			errs = append(errs, field.Forbidden(path, "requires a HorizontalPodAutoscaler or ScaledObject reference"))
+		}
+		r := ref.PrimaryScalerReplicas
+		if r.MinReplicas != nil && *r.MinReplicas < 0 {
+			errs = append(errs, field.Invalid(path.Child("minReplicas"), *r.MinReplicas, "must be greater than or equal to 0"))
+		}
+		if r.MaxReplicas != nil && *r.MaxReplicas < 1 {
+			errs = append(errs, field.Invalid(path.Child("maxReplicas"), *r.MaxReplicas, "must be greater than or equal to 1"))
+		}
+		if r.MinReplicas != nil && r.MaxReplicas != nil && *r.MinReplicas > *r.MaxReplicas {
+			errs = append(errs, field.Invalid(path, fmt.Sprintf("%v-%v", *r.MinReplicas, *r.MaxReplicas),
+				"minReplicas is greater than maxReplicas"))
+		}
+	}
+
+	analysis := cd.GetAnalysis()
+	if analysis == nil {
+		return errs
+	}
+	path := spec.Child("analysis")
+	if cd.Spec.Analysis == nil {
+		path = spec.Child("canaryAnalysis")
+	}
+	errs = append(errs, validateWeights(analysis, path)...)
+
+	for i, metric := range analysis.Metrics {
+		metricPath := path.Child("metrics").Index(i)
+		if metric.Interval != "" {
+			if _, err := time.ParseDuration(metric.Interval); err != nil {
+				errs = append(errs, field.Invalid(metricPath.Child("interval"), metric.Interval, err.Error()))
+			}
+		}
+		if metric.Query != "" {
+			if _, err := observers.RenderQuery(metric.Query, dryRunModel); err != nil {
+				errs = append(errs, field.Invalid(metricPath.Child("query"), metric.Query, err.Error()))
+			}
+		}
+		if r := metric.ThresholdRange; r != nil && r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			errs = append(errs, field.Invalid(metricPath.Child("thresholdRange"), fmt.Sprintf("%v-%v", *r.Min, *r.Max),
+				"min is greater than max"))
+		}
+	}
+	return errs
+}
+
+// validateExistingServices checks that the existing services and their canary copies
+// don't collide with the services generated by Flagger
+func validateExistingServices(cd *v1beta1.Canary, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if cd.Spec.TargetRef.Kind == "Service" {
+		return append(errs, field.Forbidden(path, "requires a workload target"))
+	}
+
+	apexName, primaryName, canaryName := cd.GetServiceNames()
+	generated := map[string]bool{apexName: true, primaryName: true, canaryName: true}
+	seen := make(map[string]bool)
+	for i, name := range cd.Spec.Service.ExistingServices {
+		if name == "" {
+			errs = append(errs, field.Required(path.Index(i), ""))
+			continue
+		}
+		if seen[name] {
+			errs = append(errs, field.Duplicate(path.Index(i), name))
+			continue
+		}
+		seen[name] = true
+		if generated[name] {
+			errs = append(errs, field.Invalid(path.Index(i), name, "service is generated by Flagger"))
+		}
+		if copyName := cd.GetExistingServiceCanaryName(name); generated[copyName] {
+			errs = append(errs, field.Invalid(path.Index(i), name,
+				fmt.Sprintf("canary copy %s collides with a service generated by Flagger", copyName)))
+		}
+	}
+	return errs
+}
+
+// validatePrimaryNaming checks that the primary naming templates render
+// valid object names and label values that differ from the target ones
+func validatePrimaryNaming(cd *v1beta1.Canary, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	pn := cd.Spec.PrimaryNaming
+	apexName, _, _ := cd.GetServiceNames()
+
+	check := func(child string, tmpl string, name string, validate func(string) []string) bool {
+		out, err := cd.RenderPrimaryNaming(tmpl, name)
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child(child), tmpl, err.Error()))
+			return false
+		}
+		msgs := validate(out)
+		if out == name {
+			msgs = append(msgs, "must differ from the target value")
+		}
+		if len(msgs) > 0 {
+			errs = append(errs, field.Invalid(path.Child(child), tmpl,
+				fmt.Sprintf("renders %q: %s", out, strings.Join(msgs, ", "))))
+			return false
+		}
+		return true
+	}
+
+	if pn.NameTemplate != "" {
+		for _, name := range []string{cd.Spec.TargetRef.Name, apexName} {
+			if !check("nameTemplate", pn.NameTemplate, name, validation.IsDNS1123Label) {
+				break
+			}
+		}
+	}
+	if pn.LabelTemplate != "" {
+		check("labelTemplate", pn.LabelTemplate, cd.Spec.TargetRef.Name, validation.IsValidLabelValue)
+	}
+	return errs
+}
+
+// validateWeights checks that the traffic weights are consistent
+func validateWeights(analysis *v1beta1.CanaryAnalysis, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if analysis.MaxWeight < 0 || analysis.MaxWeight > 100 {
+		errs = append(errs, field.Invalid(path.Child("maxWeight"), analysis.MaxWeight, "must be between 0 and 100"))
+	}
+	if analysis.StepWeight < 0 || analysis.StepWeight > 100 {
+		errs = append(errs, field.Invalid(path.Child("stepWeight"), analysis.StepWeight, "must be between 0 and 100"))
+	}
+	if analysis.MaxWeight > 0 && analysis.StepWeight > analysis.MaxWeight {
+		errs = append(errs, field.Invalid(path.Child("stepWeight"), analysis.StepWeight,
+			fmt.Sprintf("must not be greater than the max weight %d", analysis.MaxWeight)))
+	}
+	if analysis.StepWeightPromotion < 0 || analysis.StepWeightPromotion > 100 {
+		errs = append(errs, field.Invalid(path.Child("stepWeightPromotion"), analysis.StepWeightPromotion,
+			"must be between 0 and 100"))
+	}
+
+	if len(analysis.StepWeights) == 0 {
+		if len(analysis.StepWeightDurations) > 0 {
+			errs = append(errs, field.Forbidden(path.Child("stepWeightDurations"), "requires the step weights"))
+		}
+		return errs
+	}
+
+	stepWeights := path.Child("stepWeights")
+	if analysis.StepWeight > 0 {
+		errs = append(errs, field.Forbidden(stepWeights, "can't be used with the step weight"))
+	}
+	previous := 0
+	for i, weight := range analysis.StepWeights {
+		if weight <= previous || weight > 100 {
+			errs = append(errs, field.Invalid(stepWeights.Index(i), weight,
+				"must be greater than the previous step and not greater than 100"))
+		}
+		previous = weight
+	}
+	if last := analysis.StepWeights[len(analysis.StepWeights)-1]; analysis.MaxWeight > 0 && last > analysis.MaxWeight {
+		errs = append(errs, field.Invalid(stepWeights.Index(len(analysis.StepWeights)-1), last,
+			fmt.Sprintf("must not be greater than the max weight %d", analysis.MaxWeight)))
+	}
+
+	durations := path.Child("stepWeightDurations")
+	if len(analysis.StepWeightDurations) > len(analysis.StepWeights) {
+		errs = append(errs, field.TooMany(durations, len(analysis.StepWeightDurations), len(analysis.StepWeights)))
+	}
+	for i, duration := range analysis.StepWeightDurations {
+		if _, err := time.ParseDuration(duration); err != nil {
+			errs = append(errs, field.Invalid(durations.Index(i), duration, err.Error()))
+		}
+	}
+	return errs
+}
+
+// ValidateMetricTemplate checks the provider types and renders the queries and the expression
+func ValidateMetricTemplate(template *v1beta1.MetricTemplate) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	if err := providers.ValidateType(template.Spec.Provider.Type); err != nil {
+		errs = append(errs, field.Invalid(spec.Child("provider", "type"), template.Spec.Provider.Type, err.Error()))
+	}
+
+	if len(template.Spec.Queries) == 0 {
+		if template.Spec.Query == "" {
+			errs = append(errs, field.Required(spec.Child("query"), "query or queries is required"))
+		} else if _, err := observers.RenderQuery(template.Spec.Query, dryRunModel); err != nil {
+			errs = append(errs, field.Invalid(spec.Child("query"), template.Spec.Query, err.Error()))
+		}
+		return errs
+	}
+
+	values := make(map[string]float64, len(template.Spec.Queries))
+	for i, q := range template.Spec.Queries {
+		queryPath := spec.Child("queries").Index(i)
+		if _, ok := values[q.Name]; ok {
+			errs = append(errs, field.Duplicate(queryPath.Child("name"), q.Name))
+		}
+		values[q.Name] = 1
+		if q.Provider != nil {
+			if err := providers.ValidateType(q.Provider.Type); err != nil {
+				errs = append(errs, field.Invalid(queryPath.Child("provider", "type"), q.Provider.Type, err.Error()))
+			}
+		}
+		if _, err := observers.RenderQuery(q.Query, dryRunModel); err != nil {
+			errs = append(errs, field.Invalid(queryPath.Child("query"), q.Query, err.Error()))
+		}
+	}
+
+	if template.Spec.Expression != "" {
+		if _, err := observers.EvaluateExpression(template.Spec.Expression, values); err != nil {
+			errs = append(errs, field.Invalid(spec.Child("expression"), template.Spec.Expression, err.Error()))
+		}
+	}
+	return errs
+}
+
+// ValidateAlertProvider checks the provider type and its address
+func ValidateAlertProvider(provider *v1beta1.AlertProvider) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	supported := false
+	for _, t := range notifier.Providers() {
+		if t == provider.Spec.Type {
+			supported = true
+		}
+	}
+	if !supported {
+		errs = append(errs, field.NotSupported(spec.Child("type"), provider.Spec.Type, notifier.Providers()))
+	}
+	if provider.Spec.Address == "" && provider.Spec.SecretRef == nil {
+		errs = append(errs, field.Required(spec.Child("address"), "address or secretRef is required"))
+	}
+	return errs
+}
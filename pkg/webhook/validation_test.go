@@ -0,0 +1,314 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func newValidationCanary() *v1beta1.Canary {
+	return &v1beta1.Canary{
+		Spec: v1beta1.CanarySpec{
+			TargetRef: v1beta1.LocalObjectReference{Kind: "Deployment", Name: "podinfo"},
+			Analysis: &v1beta1.CanaryAnalysis{
+				Interval:   "1m",
+				Threshold:  5,
+				MaxWeight:  50,
+				StepWeight: 10,
+				Metrics: []v1beta1.CanaryMetric{
+					{Name: "error-rate", Query: `sum(rate(http_requests_total{namespace="{{ namespace }}"}[{{ interval }}]))`},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateCanary(t *testing.T) {
+	assert.Empty(t, ValidateCanary(newValidationCanary()))
+
+	cd := newValidationCanary()
+	cd.Spec.Provider = "mesh"
+	cd.Spec.TargetRef.Kind = "ReplicaSet"
+	cd.Spec.Analysis.StepWeight = 60
+	cd.Spec.Analysis.Metrics[0].Query = "{{ namespace "
+	cd.Spec.ImageTracking = &v1beta1.ImageTracking{Include: []string{"app"}, Exclude: []string{"app"}}
+	errs := ValidateCanary(cd)
+	fields := make([]string, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"spec.provider",
+		"spec.targetRef.kind",
+		"spec.analysis.stepWeight",
+		"spec.analysis.metrics[0].query",
+		"spec.imageTracking.exclude[0]",
+	}, fields)
+}
+
+func TestValidateCanary_TargetWorkload(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Spec.TargetRef = v1beta1.LocalObjectReference{APIVersion: "apps.kruise.io/v1alpha1", Kind: "CloneSet", Name: "podinfo"}
+	cd.Spec.TargetWorkload = &v1beta1.TargetWorkload{Resource: "clonesets"}
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.TargetRef = v1beta1.LocalObjectReference{Kind: "Deployment", Name: "podinfo"}
+	cd.Spec.TargetWorkload = &v1beta1.TargetWorkload{}
+	errs := ValidateCanary(cd)
+	fields := make([]string, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"spec.targetWorkload",
+		"spec.targetWorkload.resource",
+		"spec.targetRef.apiVersion",
+	}, fields)
+}
+
+func TestValidateCanary_NodePartition(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Spec.TargetRef.Kind = "DaemonSet"
+	cd.Spec.NodePartition = &v1beta1.NodePartition{NodeSelector: map[string]string{"pool": "agents"}}
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.Analysis.Iterations = 10
+	errs := ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.nodePartition", errs[0].Field)
+	}
+
+	cd = newValidationCanary()
+	cd.Spec.NodePartition = &v1beta1.NodePartition{}
+	errs = ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.nodePartition", errs[0].Field)
+	}
+}
+
+func TestValidateCanary_PrimaryAntiAffinity(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Spec.PrimaryAntiAffinity = &v1beta1.PrimaryAntiAffinity{TopologyKey: "topology.kubernetes.io/zone"}
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.TargetRef.Kind = "DaemonSet"
+	errs := ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.primaryAntiAffinity", errs[0].Field)
+	}
+}
+
+func TestValidateCanary_TrackedObjects(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Spec.TrackedObjects = []v1beta1.TrackedObjectReference{
+		{APIVersion: "flags.example.com/v1", Kind: "FeatureFlag", Name: "podinfo"},
+		{APIVersion: "flags.example.com/v1", Kind: "FeatureFlag", Name: "frontend"},
+	}
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.TrackedObjects = append(cd.Spec.TrackedObjects,
+		v1beta1.TrackedObjectReference{APIVersion: "flags.example.com/v1", Kind: "FeatureFlag", Name: "podinfo"},
+		v1beta1.TrackedObjectReference{APIVersion: "flags.example.com/v1/beta", Kind: "FeatureFlag"})
+	errs := ValidateCanary(cd)
+	if assert.Len(t, errs, 3) {
+		assert.Equal(t, "spec.trackedObjects[2]", errs[0].Field)
+		assert.Equal(t, "spec.trackedObjects[3].apiVersion", errs[1].Field)
+		assert.Equal(t, "spec.trackedObjects[3].name", errs[2].Field)
+	}
+}
+
+func TestValidateCanary_ConfigRewrites(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Spec.ConfigRewrites = []v1beta1.ConfigRewriteRule{
+		{Field: v1beta1.ArgsConfigRewriteField, Containers: []string{"podinfo"}},
+		{Field: v1beta1.EnvConfigRewriteField, Keys: []string{"CONFIG_NAME"}},
+		{Field: v1beta1.AnnotationsConfigRewriteField},
+	}
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.ConfigRewrites = []v1beta1.ConfigRewriteRule{
+		{Field: v1beta1.ArgsConfigRewriteField, Keys: []string{"CONFIG_NAME"}},
+		{Field: v1beta1.AnnotationsConfigRewriteField, Containers: []string{"podinfo"}},
+		{Field: "Command"},
+	}
+	errs := ValidateCanary(cd)
+	if assert.Len(t, errs, 3) {
+		assert.Equal(t, "spec.configRewrites[0].keys", errs[0].Field)
+		assert.Equal(t, "spec.configRewrites[1].containers", errs[1].Field)
+		assert.Equal(t, "spec.configRewrites[2].field", errs[2].Field)
+	}
+}
+
+func TestValidateCanary_ConflictPolicy(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Spec.ConflictPolicy = v1beta1.RevertWithWarningConflictPolicy
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.ConflictPolicy = "Ignore"
+	errs := ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.conflictPolicy", errs[0].Field)
+	}
+
+	cd.Spec.ConflictPolicy = v1beta1.AdoptConflictPolicy
+	cd.Spec.TargetRef.Kind = "StatefulSet"
+	errs = ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.conflictPolicy", errs[0].Field)
+	}
+}
+
+func TestValidateCanary_ExistingServices(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Spec.Service.ExistingServices = []string{"podinfo-web", "podinfo-grpc"}
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.Service.ExistingServices = []string{"podinfo-web", "podinfo-web", "podinfo-primary"}
+	errs := ValidateCanary(cd)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "spec.service.existingServices[1]", errs[0].Field)
+		assert.Equal(t, "spec.service.existingServices[2]", errs[1].Field)
+	}
+
+	cd.Spec.Service.ExistingServices = []string{"podinfo-web"}
+	cd.Spec.TargetRef.Kind = "Service"
+	errs = ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.service.existingServices", errs[0].Field)
+	}
+}
+
+func TestValidateCanary_PrimaryScalerReplicas(t *testing.T) {
+	minReplicas, maxReplicas := int32(2), int32(10)
+	cd := newValidationCanary()
+	cd.Spec.AutoscalerRef = &v1beta1.AutoscalerReference{
+		Kind: "HorizontalPodAutoscaler",
+		Name: "podinfo",
+		PrimaryScalerReplicas: &v1beta1.ScalerReplicas{
+			MinReplicas: &minReplicas,
+			MaxReplicas: &maxReplicas,
+		},
+	}
+	assert.Empty(t, ValidateCanary(cd))
+
+	maxReplicas = 1
+	errs := ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.autoscalerRef.primaryScalerReplicas", errs[0].Field)
+	}
+
+	cd.Spec.AutoscalerRef.Kind = "VerticalPodAutoscaler"
+	cd.Spec.AutoscalerRef.PrimaryScalerReplicas.MinReplicas = nil
+	errs = ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.autoscalerRef.primaryScalerReplicas", errs[0].Field)
+	}
+}
+
+func TestValidateCanary_PrimaryNaming(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Namespace = "test"
+	cd.Spec.PrimaryNaming = &v1beta1.PrimaryNaming{
+		NameTemplate:  "{{ .Name }}-stable",
+		LabelTemplate: "{{ .Namespace }}-{{ .Name }}-stable",
+	}
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.PrimaryNaming = &v1beta1.PrimaryNaming{
+		NameTemplate:  "{{ .Name }}_Stable",
+		LabelTemplate: "{{ .Name }}",
+	}
+	errs := ValidateCanary(cd)
+	fields := make([]string, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"spec.primaryNaming.nameTemplate",
+		"spec.primaryNaming.labelTemplate",
+	}, fields)
+
+	cd.Spec.PrimaryNaming = &v1beta1.PrimaryNaming{NameTemplate: "{{ .Version }}"}
+	errs = ValidateCanary(cd)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.primaryNaming.nameTemplate", errs[0].Field)
+	}
+}
+
+func TestValidateCanary_StepWeights(t *testing.T) {
+	cd := newValidationCanary()
+	cd.Spec.Analysis.StepWeight = 0
+	cd.Spec.Analysis.StepWeights = []int{10, 30, 50}
+	cd.Spec.Analysis.StepWeightDurations = []string{"5m"}
+	assert.Empty(t, ValidateCanary(cd))
+
+	cd.Spec.Analysis.StepWeights = []int{10, 5, 80}
+	cd.Spec.Analysis.StepWeightDurations = []string{"5m", "1x", "1m", "1m"}
+	errs := ValidateCanary(cd)
+	fields := make([]string, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"spec.analysis.stepWeights[1]",
+		"spec.analysis.stepWeights[2]",
+		"spec.analysis.stepWeightDurations",
+		"spec.analysis.stepWeightDurations[1]",
+	}, fields)
+}
+
+func TestValidateMetricTemplate(t *testing.T) {
+	template := &v1beta1.MetricTemplate{
+		Spec: v1beta1.MetricTemplateSpec{
+			Provider: v1beta1.MetricTemplateProvider{Type: "prometheus"},
+			Queries: []v1beta1.MetricTemplateQuery{
+				{Name: "errors", Query: `sum(rate(errors{namespace="{{ namespace }}"}[{{ interval }}]))`},
+				{Name: "total", Query: `sum(rate(requests{namespace="{{ namespace }}"}[{{ interval }}]))`},
+			},
+			Expression: "errors / total * 100",
+		},
+	}
+	assert.Empty(t, ValidateMetricTemplate(template))
+
+	template.Spec.Provider.Type = "graphana"
+	template.Spec.Queries[1].Name = "errors"
+	template.Spec.Expression = "errors /"
+	errs := ValidateMetricTemplate(template)
+	fields := make([]string, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	assert.ElementsMatch(t, []string{"spec.provider.type", "spec.queries[1].name", "spec.expression"}, fields)
+
+	template.Spec.Queries = nil
+	assert.Len(t, ValidateMetricTemplate(template), 2)
+}
+
+func TestValidateAlertProvider(t *testing.T) {
+	provider := &v1beta1.AlertProvider{
+		Spec: v1beta1.AlertProviderSpec{Type: "slack", SecretRef: &corev1.LocalObjectReference{Name: "slack-url"}},
+	}
+	assert.Empty(t, ValidateAlertProvider(provider))
+
+	provider.Spec = v1beta1.AlertProviderSpec{Type: "teams"}
+	assert.Len(t, ValidateAlertProvider(provider), 2)
+}